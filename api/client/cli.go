@@ -11,12 +11,14 @@ import (
 	"os"
 	"reflect"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
 	"github.com/docker/docker/pkg/homedir"
 	flag "github.com/docker/docker/pkg/mflag"
 	"github.com/docker/docker/pkg/term"
+	"github.com/docker/docker/pkg/version"
 	"github.com/docker/docker/registry"
 )
 
@@ -39,6 +41,12 @@ type DockerCli struct {
 	// isTerminalOut describes if client's STDOUT is a TTY
 	isTerminalOut bool
 	transport     *http.Transport
+	// apiVersion is the API version negotiated with the daemon, it is
+	// lazily set on the first request by getAPIVersion
+	apiVersion     version.Version
+	apiVersionOnce sync.Once
+	// pluginEnv is added to the environment of the plugins
+	pluginEnv []string
 }
 
 var funcMap = template.FuncMap{
@@ -75,6 +83,9 @@ func (cli *DockerCli) Cmd(args ...string) error {
 	if len(args) > 0 {
 		method, exists := cli.getMethod(args[0])
 		if !exists {
+			if found, err := cli.runPlugin(args[0], args[1:]); found {
+				return err
+			}
 			fmt.Fprintf(cli.err, "docker: '%s' is not a docker command. See 'docker --help'.\n", args[0])
 			os.Exit(1)
 		}
@@ -34,6 +34,7 @@ import (
 	"github.com/docker/docker/opts"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/common"
+	"github.com/docker/docker/pkg/digest"
 	"github.com/docker/docker/pkg/fileutils"
 	"github.com/docker/docker/pkg/homedir"
 	flag "github.com/docker/docker/pkg/mflag"
@@ -43,7 +44,6 @@ import (
 	"github.com/docker/docker/pkg/signal"
 	"github.com/docker/docker/pkg/symlink"
 	"github.com/docker/docker/pkg/term"
-	"github.com/docker/docker/pkg/timeutils"
 	"github.com/docker/docker/pkg/units"
 	"github.com/docker/docker/pkg/urlutil"
 	"github.com/docker/docker/registry"
@@ -66,6 +66,9 @@ func (cli *DockerCli) CmdHelp(args ...string) error {
 	if len(args) > 0 {
 		method, exists := cli.getMethod(args[0])
 		if !exists {
+			if found, err := cli.runPlugin(args[0], []string{"--help"}); found {
+				return err
+			}
 			fmt.Fprintf(cli.err, "docker: '%s' is not a docker command. See 'docker --help'.\n", args[0])
 			os.Exit(1)
 		} else {
@@ -87,16 +90,41 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 	rm := cmd.Bool([]string{"#rm", "-rm"}, true, "Remove intermediate containers after a successful build")
 	forceRm := cmd.Bool([]string{"-force-rm"}, false, "Always remove intermediate containers")
 	pull := cmd.Bool([]string{"-pull"}, false, "Always attempt to pull a newer version of the image")
+	squash := cmd.Bool([]string{"-squash"}, false, "Squash the layers produced by the build into one")
+	networkMode := cmd.String([]string{"-network"}, "default", "Network of the RUN instructions")
+	parallel := cmd.Int([]string{"-parallel"}, 1, "Number of independent build stages built at once")
+	progress := cmd.String([]string{"-progress"}, "auto", "Type of progress output (auto, json)")
+	reproducible := cmd.Bool([]string{"-reproducible"}, false, "Build reproducible layers, dated $SOURCE_DATE_EPOCH")
 	dockerfileName := cmd.String([]string{"f", "-file"}, "", "Name of the Dockerfile(Default is 'Dockerfile')")
+	flBuildArg := opts.NewListOpts(opts.ValidateEnv)
+	cmd.Var(&flBuildArg, []string{"-build-arg"}, "Set build-time variables")
+	flCacheFrom := opts.NewListOpts(nil)
+	cmd.Var(&flCacheFrom, []string{"-cache-from"}, "Images to consider as cache sources")
+	flSecrets := opts.NewListOpts(nil)
+	cmd.Var(&flSecrets, []string{"-secret"}, "Secret file for RUN --mount (id=name,src=path)")
+	flExtraHosts := opts.NewListOpts(opts.ValidateExtraHost)
+	cmd.Var(&flExtraHosts, []string{"-add-host"}, "Add a custom host-to-IP mapping (host:ip)")
+	flSSH := opts.NewListOpts(nil)
+	cmd.Var(&flSSH, []string{"-ssh"}, "SSH agent socket for RUN --mount (id[=socket])")
+	flBuildContexts := opts.NewListOpts(nil)
+	cmd.Var(&flBuildContexts, []string{"-build-context"}, "Additional build context for FROM and COPY --from (name=path|url)")
+	untrusted := addTrustedFlags(cmd, true)
 
 	cmd.Require(flag.Exact, 1)
 
 	utils.ParseFlags(cmd, args, true)
 
+	if *progress != "auto" && *progress != "json" {
+		return fmt.Errorf("Invalid progress output %s, expected auto or json", *progress)
+	}
+
 	var (
 		context  archive.Archive
 		isRemote bool
 		err      error
+		// dockerfile is the content of the Dockerfile of the local
+		// contexts, to verify its FROM images with content trust
+		dockerfile []byte
 	)
 
 	_, err = exec.LookPath("git")
@@ -110,7 +138,7 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 			return fmt.Errorf("failed to peek context header from STDIN: %v", err)
 		}
 		if !archive.IsArchive(magic) {
-			dockerfile, err := ioutil.ReadAll(buf)
+			dockerfile, err = ioutil.ReadAll(buf)
 			if err != nil {
 				return fmt.Errorf("failed to read Dockerfile from STDIN: %v", err)
 			}
@@ -128,18 +156,15 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 		root := cmd.Arg(0)
 		if urlutil.IsGitURL(root) {
 			remoteURL := cmd.Arg(0)
-			if !urlutil.IsGitTransport(remoteURL) {
-				remoteURL = "https://" + remoteURL
-			}
 
-			root, err = ioutil.TempDir("", "docker-build-git")
+			cloneDir, err := ioutil.TempDir("", "docker-build-git")
 			if err != nil {
 				return err
 			}
-			defer os.RemoveAll(root)
+			defer os.RemoveAll(cloneDir)
 
-			if output, err := exec.Command("git", "clone", "--recursive", remoteURL, root).CombinedOutput(); err != nil {
-				return fmt.Errorf("Error trying to use git: %s (%s)", err, output)
+			if root, err = utils.GitClone(remoteURL, cloneDir); err != nil {
+				return err
 			}
 		}
 		if _, err := os.Stat(root); err != nil {
@@ -194,6 +219,11 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 		if _, err = os.Lstat(filename); os.IsNotExist(err) {
 			return fmt.Errorf("Cannot locate Dockerfile: %s", origDockerfile)
 		}
+		if !*untrusted {
+			if dockerfile, err = ioutil.ReadFile(filename); err != nil {
+				return err
+			}
+		}
 		var includes = []string{"."}
 
 		excludes, err := utils.ReadDockerIgnore(path.Join(root, ".dockerignore"))
@@ -226,12 +256,52 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 			return err
 		}
 	}
+
+	var buildContexts map[string]string
+	if flBuildContexts.Len() > 0 {
+		var dirs map[string]string
+		if buildContexts, dirs, err = parseBuildContexts(flBuildContexts.GetAll()); err != nil {
+			return err
+		}
+		if len(dirs) > 0 {
+			if isRemote {
+				return fmt.Errorf("The local build contexts cannot be used with a remote context %s", cmd.Arg(0))
+			}
+			if context, err = addBuildContexts(context, dirs); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !*untrusted && context != nil {
+		if dockerfile == nil {
+			fmt.Fprintf(cli.err, "WARNING: The images of the FROM instructions of a tar context are not verified\n")
+		} else {
+			exclude := make(map[string]bool)
+			for name := range buildContexts {
+				exclude[name] = true
+			}
+			translated, err := cli.translateDockerfileFrom(dockerfile, exclude)
+			if err != nil {
+				return err
+			}
+			context = archive.ReplaceFile(context, *dockerfileName, translated)
+		}
+	} else if !*untrusted {
+		fmt.Fprintf(cli.err, "WARNING: The images of the FROM instructions of a remote context are not verified\n")
+	}
+
 	var body io.Reader
 	// Setup an upload progress bar
 	// FIXME: ProgressReader shouldn't be this annoying to use
+	progressOut := cli.out
+	if *progress == "json" {
+		// keep the output parseable
+		progressOut = cli.err
+	}
 	if context != nil {
 		sf := utils.NewStreamFormatter(false)
-		body = utils.ProgressReader(context, 0, cli.out, sf, true, "", "Sending build context to Docker daemon")
+		body = utils.ProgressReader(context, 0, progressOut, sf, true, "", "Sending build context to Docker daemon")
 	}
 	// Send the build context
 	v := &url.Values{}
@@ -274,21 +344,126 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 		v.Set("pull", "1")
 	}
 
+	if *squash {
+		v.Set("squash", "1")
+	}
+
+	if *networkMode != "default" {
+		v.Set("networkmode", *networkMode)
+	}
+
+	if *parallel > 1 {
+		v.Set("parallel", strconv.Itoa(*parallel))
+	}
+
+	if *reproducible {
+		epoch := os.Getenv("SOURCE_DATE_EPOCH")
+		if epoch == "" {
+			epoch = "0"
+		}
+		if _, err := strconv.ParseInt(epoch, 10, 64); err != nil {
+			return fmt.Errorf("Invalid SOURCE_DATE_EPOCH %q, expected a number of seconds since the epoch", epoch)
+		}
+		v.Set("sourcedateepoch", epoch)
+	}
+
 	v.Set("dockerfile", *dockerfileName)
 
+	if flBuildArg.Len() > 0 {
+		buildArgs := map[string]string{}
+		for _, arg := range flBuildArg.GetAll() {
+			parts := strings.SplitN(arg, "=", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("Bad format for --build-arg: %s, expected key=value or the name of a set variable", arg)
+			}
+			buildArgs[parts[0]] = parts[1]
+		}
+		buf, err := json.Marshal(buildArgs)
+		if err != nil {
+			return err
+		}
+		v.Set("buildargs", string(buf))
+	}
+
+	if flCacheFrom.Len() > 0 {
+		buf, err := json.Marshal(flCacheFrom.GetAll())
+		if err != nil {
+			return err
+		}
+		v.Set("cachefrom", string(buf))
+	}
+
+	if flExtraHosts.Len() > 0 {
+		buf, err := json.Marshal(flExtraHosts.GetAll())
+		if err != nil {
+			return err
+		}
+		v.Set("extrahosts", string(buf))
+	}
+
+	// the SSH agents are forwarded over a session, the daemon connects
+	// to them through the client
+	if flSSH.Len() > 0 {
+		sockets, err := parseSSHSockets(flSSH.GetAll())
+		if err != nil {
+			return err
+		}
+		var agents []string
+		for id := range sockets {
+			agents = append(agents, id)
+		}
+		sort.Strings(agents)
+		buf, err := json.Marshal(agents)
+		if err != nil {
+			return err
+		}
+		sessionID := common.GenerateRandomID()
+		session, err := cli.openSession(sessionID, sockets)
+		if err != nil {
+			return err
+		}
+		defer session.Close()
+		v.Set("ssh", string(buf))
+		v.Set("session", sessionID)
+	}
+
+	if len(buildContexts) > 0 {
+		buf, err := json.Marshal(buildContexts)
+		if err != nil {
+			return err
+		}
+		v.Set("buildcontexts", string(buf))
+	}
+
 	cli.LoadConfigFile()
 
 	headers := http.Header(make(map[string][]string))
-	buf, err := json.Marshal(cli.configFile)
+	buf, err := json.Marshal(cli.allAuthConfigs())
 	if err != nil {
 		return err
 	}
 	headers.Add("X-Registry-Config", base64.URLEncoding.EncodeToString(buf))
 
+	if flSecrets.Len() > 0 {
+		secrets, err := readBuildSecrets(flSecrets.GetAll())
+		if err != nil {
+			return err
+		}
+		buf, err := json.Marshal(secrets)
+		if err != nil {
+			return err
+		}
+		headers.Add("X-Build-Secrets", base64.URLEncoding.EncodeToString(buf))
+	}
+
 	if context != nil {
 		headers.Set("Content-Type", "application/tar")
 	}
-	err = cli.stream("POST", fmt.Sprintf("/build?%s", v.Encode()), body, cli.out, headers)
+	if *progress == "json" {
+		err = cli.streamJSON("POST", fmt.Sprintf("/build?%s", v.Encode()), body, cli.out, headers)
+	} else {
+		err = cli.stream("POST", fmt.Sprintf("/build?%s", v.Encode()), body, cli.out, headers)
+	}
 	if jerr, ok := err.(*utils.JSONError); ok {
 		// If no error code is set, default to 1
 		if jerr.Code == 0 {
@@ -299,6 +474,131 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 	return err
 }
 
+// parseSSHSockets parses the --ssh id[=socket] options, the socket of an
+// agent defaults to the one of SSH_AUTH_SOCK
+func parseSSHSockets(specs []string) (map[string]string, error) {
+	sockets := make(map[string]string)
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if parts[0] == "" {
+			return nil, fmt.Errorf("Invalid ssh agent %s, expected id[=socket]", spec)
+		}
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if len(parts) == 2 {
+			socket = parts[1]
+		}
+		if socket == "" {
+			return nil, fmt.Errorf("No socket for the ssh agent %s, SSH_AUTH_SOCK is not set", parts[0])
+		}
+		abs, err := filepath.Abs(socket)
+		if err != nil {
+			return nil, err
+		}
+		if fi, err := os.Stat(abs); err != nil || fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("The ssh agent socket %s of %s is not a socket", abs, parts[0])
+		}
+		sockets[parts[0]] = abs
+	}
+	return sockets, nil
+}
+
+// parseBuildContexts parses the --build-context name=path|url options. It
+// returns the source of each context, where the local directories, which
+// are returned separately, are replaced by api.LocalBuildContext.
+func parseBuildContexts(specs []string) (map[string]string, map[string]string, error) {
+	contexts := make(map[string]string)
+	dirs := make(map[string]string)
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, nil, fmt.Errorf("Invalid build context %s, expected name=path|url", spec)
+		}
+		name, src := parts[0], parts[1]
+		if err := api.ValidateBuildContextName(name); err != nil {
+			return nil, nil, err
+		}
+		if _, exists := contexts[name]; exists {
+			return nil, nil, fmt.Errorf("The build context %s is given more than once", name)
+		}
+		if strings.HasPrefix(src, api.ImageBuildContextPrefix) || urlutil.IsURL(src) || urlutil.IsGitURL(src) {
+			contexts[name] = src
+			continue
+		}
+		fi, err := os.Stat(src)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !fi.IsDir() {
+			return nil, nil, fmt.Errorf("The build context %s must be a directory, got %s", name, src)
+		}
+		contexts[name] = api.LocalBuildContext
+		dirs[name] = src
+	}
+	return contexts, dirs, nil
+}
+
+// addBuildContexts adds the directories of the local build contexts to the
+// context, they are sent under api.BuildContextsDir and the daemon moves
+// them out of the context. The .dockerignore of a directory applies to its
+// files.
+func addBuildContexts(context archive.Archive, dirs map[string]string) (archive.Archive, error) {
+	extra := make(map[string]archive.ArchiveReader)
+	for name, dir := range dirs {
+		excludes, err := utils.ReadDockerIgnore(path.Join(dir, ".dockerignore"))
+		if err != nil {
+			return nil, err
+		}
+		if err := utils.ValidateContextDirectory(dir, excludes); err != nil {
+			return nil, fmt.Errorf("Error checking build context %s is accessible: '%s'. Please check permissions and try again.", name, err)
+		}
+		tar, err := archive.TarWithOptions(dir, &archive.TarOptions{
+			Compression:     archive.Uncompressed,
+			ExcludePatterns: excludes,
+		})
+		if err != nil {
+			return nil, err
+		}
+		extra[path.Join(api.BuildContextsDir, name)] = tar
+	}
+	return archive.AddArchives(context, extra), nil
+}
+
+// readBuildSecrets reads the files of the --secret id=name,src=path options,
+// the secrets are sent in a header so that they are never stored in the
+// build context
+func readBuildSecrets(specs []string) (map[string][]byte, error) {
+	secrets := make(map[string][]byte)
+	for _, spec := range specs {
+		var id, src string
+		for _, field := range strings.Split(spec, ",") {
+			parts := strings.SplitN(field, "=", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("Invalid secret %s, expected id=name,src=path", spec)
+			}
+			switch parts[0] {
+			case "id":
+				id = parts[1]
+			case "src", "source":
+				src = parts[1]
+			default:
+				return nil, fmt.Errorf("Invalid secret %s, unknown field %s", spec, parts[0])
+			}
+		}
+		if id == "" || src == "" {
+			return nil, fmt.Errorf("Invalid secret %s, expected id=name,src=path", spec)
+		}
+		if strings.Contains(id, "/") {
+			return nil, fmt.Errorf("Invalid secret id %s, it cannot contain a /", id)
+		}
+		data, err := ioutil.ReadFile(src)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading secret %s: %v", id, err)
+		}
+		secrets[id] = data
+	}
+	return secrets, nil
+}
+
 // 'docker login': login / register a user to registry service.
 func (cli *DockerCli) CmdLogin(args ...string) error {
 	cmd := cli.Subcmd("login", "[SERVER]", "Register or log in to a Docker registry server, if no server is\nspecified \""+registry.IndexServerAddress()+"\" is the default.", true)
@@ -336,10 +636,8 @@ func (cli *DockerCli) CmdLogin(args ...string) error {
 	}
 
 	cli.LoadConfigFile()
-	authconfig, ok := cli.configFile.Configs[serverAddress]
-	if !ok {
-		authconfig = registry.AuthConfig{}
-	}
+	helper := cli.loadConfig().credentialHelper(serverAddress)
+	authconfig := cli.authConfig(serverAddress)
 
 	if username == "" {
 		promptDefault("Username", authconfig.Username)
@@ -409,6 +707,13 @@ func (cli *DockerCli) CmdLogin(args ...string) error {
 		cli.configFile, _ = registry.LoadConfig(homedir.Get())
 		return err
 	}
+	if helper != "" {
+		if err := helperStore(helper, authconfig); err != nil {
+			return err
+		}
+		// the credentials are only kept by the helper
+		delete(cli.configFile.Configs, serverAddress)
+	}
 	registry.SaveConfig(cli.configFile)
 	if out2.Get("Status") != "" {
 		fmt.Fprintf(cli.out, "%s\n", out2.Get("Status"))
@@ -428,10 +733,20 @@ func (cli *DockerCli) CmdLogout(args ...string) error {
 	}
 
 	cli.LoadConfigFile()
-	if _, ok := cli.configFile.Configs[serverAddress]; !ok {
+	helper := cli.loadConfig().credentialHelper(serverAddress)
+	_, inFile := cli.configFile.Configs[serverAddress]
+	if !inFile && (helper == "" || cli.authConfig(serverAddress).Username == "") {
 		fmt.Fprintf(cli.out, "Not logged in to %s\n", serverAddress)
-	} else {
-		fmt.Fprintf(cli.out, "Remove login credentials for %s\n", serverAddress)
+		return nil
+	}
+
+	fmt.Fprintf(cli.out, "Remove login credentials for %s\n", serverAddress)
+	if helper != "" {
+		if err := helperErase(helper, serverAddress); err != nil {
+			return err
+		}
+	}
+	if inFile {
 		delete(cli.configFile.Configs, serverAddress)
 
 		if err := registry.SaveConfig(cli.configFile); err != nil {
@@ -444,13 +759,17 @@ func (cli *DockerCli) CmdLogout(args ...string) error {
 // 'docker wait': block until a container stops
 func (cli *DockerCli) CmdWait(args ...string) error {
 	cmd := cli.Subcmd("wait", "CONTAINER [CONTAINER...]", "Block until a container stops, then print its exit code.", true)
+	condition := cmd.String([]string{"-condition"}, "not-running", "Condition to wait for: not-running, next-exit or removed")
 	cmd.Require(flag.Min, 1)
 
 	utils.ParseFlags(cmd, args, true)
 
+	v := url.Values{}
+	v.Set("condition", *condition)
+
 	var encounteredError error
 	for _, name := range cmd.Args() {
-		status, err := waitForExit(cli, name)
+		status, err := waitForCondition(cli, name, v)
 		if err != nil {
 			fmt.Fprintf(cli.err, "%s\n", err)
 			encounteredError = fmt.Errorf("Error: failed to wait one or more containers")
@@ -472,6 +791,9 @@ func (cli *DockerCli) CmdVersion(args ...string) error {
 		fmt.Fprintf(cli.out, "Client version: %s\n", dockerversion.VERSION)
 	}
 	fmt.Fprintf(cli.out, "Client API version: %s\n", api.APIVERSION)
+	if apiVersion := cli.getAPIVersion(); apiVersion != api.APIVERSION {
+		fmt.Fprintf(cli.out, "Negotiated API version: %s\n", apiVersion)
+	}
 	fmt.Fprintf(cli.out, "Go version (client): %s\n", runtime.Version())
 	if dockerversion.GITCOMMIT != "" {
 		fmt.Fprintf(cli.out, "Git commit (client): %s\n", dockerversion.GITCOMMIT)
@@ -498,6 +820,9 @@ func (cli *DockerCli) CmdVersion(args ...string) error {
 	if apiVersion := remoteVersion.Get("ApiVersion"); apiVersion != "" {
 		fmt.Fprintf(cli.out, "Server API version: %s\n", apiVersion)
 	}
+	if minAPIVersion := remoteVersion.Get("MinAPIVersion"); minAPIVersion != "" {
+		fmt.Fprintf(cli.out, "Server minimum API version: %s\n", minAPIVersion)
+	}
 	fmt.Fprintf(cli.out, "Go version (server): %s\n", remoteVersion.Get("GoVersion"))
 	fmt.Fprintf(cli.out, "Git commit (server): %s\n", remoteVersion.Get("GitCommit"))
 	fmt.Fprintf(cli.out, "OS/Arch (server): %s/%s\n", remoteVersion.Get("Os"), remoteVersion.Get("Arch"))
@@ -602,7 +927,7 @@ func (cli *DockerCli) CmdInfo(args ...string) error {
 	}
 	if len(remoteInfo.GetList("IndexServerAddress")) != 0 {
 		cli.LoadConfigFile()
-		u := cli.configFile.Configs[remoteInfo.Get("IndexServerAddress")].Username
+		u := cli.authConfig(remoteInfo.Get("IndexServerAddress")).Username
 		if len(u) > 0 {
 			fmt.Fprintf(cli.out, "Username: %v\n", u)
 			fmt.Fprintf(cli.out, "Registry: %v\n", remoteInfo.GetList("IndexServerAddress"))
@@ -635,7 +960,9 @@ func (cli *DockerCli) CmdStop(args ...string) error {
 	utils.ParseFlags(cmd, args, true)
 
 	v := url.Values{}
-	v.Set("t", strconv.Itoa(*nSeconds))
+	if cmd.IsSet("t") || cmd.IsSet("-time") {
+		v.Set("t", strconv.Itoa(*nSeconds))
+	}
 
 	var encounteredError error
 	for _, name := range cmd.Args() {
@@ -658,7 +985,9 @@ func (cli *DockerCli) CmdRestart(args ...string) error {
 	utils.ParseFlags(cmd, args, true)
 
 	v := url.Values{}
-	v.Set("t", strconv.Itoa(*nSeconds))
+	if cmd.IsSet("t") || cmd.IsSet("-time") {
+		v.Set("t", strconv.Itoa(*nSeconds))
+	}
 
 	var encounteredError error
 	for _, name := range cmd.Args() {
@@ -879,6 +1208,7 @@ func (cli *DockerCli) CmdRename(args ...string) error {
 func (cli *DockerCli) CmdInspect(args ...string) error {
 	cmd := cli.Subcmd("inspect", "CONTAINER|IMAGE [CONTAINER|IMAGE...]", "Return low-level information on a container or image", true)
 	tmplStr := cmd.String([]string{"f", "#format", "-format"}, "", "Format the output using the given go template")
+	size := cmd.Bool([]string{"s", "-size"}, false, "Display the sizes of the containers and the layers of the images")
 	cmd.Require(flag.Min, 1)
 
 	utils.ParseFlags(cmd, args, true)
@@ -896,9 +1226,13 @@ func (cli *DockerCli) CmdInspect(args ...string) error {
 	indented := new(bytes.Buffer)
 	indented.WriteByte('[')
 	status := 0
+	query := ""
+	if *size {
+		query = "?size=1"
+	}
 
 	for _, name := range cmd.Args() {
-		obj, _, err := readBody(cli.call("GET", "/containers/"+name+"/json", nil, false))
+		obj, _, err := readBody(cli.call("GET", "/containers/"+name+"/json"+query, nil, false))
 		if err != nil {
 			if strings.Contains(err.Error(), "Too many") {
 				fmt.Fprintf(cli.err, "Error: %s", err.Error())
@@ -906,7 +1240,7 @@ func (cli *DockerCli) CmdInspect(args ...string) error {
 				continue
 			}
 
-			obj, _, err = readBody(cli.call("GET", "/images/"+name+"/json", nil, false))
+			obj, _, err = readBody(cli.call("GET", "/images/"+name+"/json"+query, nil, false))
 			if err != nil {
 				if strings.Contains(err.Error(), "No such") {
 					fmt.Fprintf(cli.err, "Error: No such image or container: %s\n", name)
@@ -1232,6 +1566,7 @@ func (cli *DockerCli) CmdImport(args ...string) error {
 
 func (cli *DockerCli) CmdPush(args ...string) error {
 	cmd := cli.Subcmd("push", "NAME[:TAG]", "Push an image or a repository to the registry", true)
+	untrusted := addTrustedFlags(cmd, false)
 	cmd.Require(flag.Exact, 1)
 
 	utils.ParseFlags(cmd, args, true)
@@ -1248,7 +1583,7 @@ func (cli *DockerCli) CmdPush(args ...string) error {
 		return err
 	}
 	// Resolve the Auth config relevant for this server
-	authConfig := cli.configFile.ResolveAuthConfig(repoInfo.Index)
+	authConfig := cli.resolveAuthConfig(repoInfo.Index)
 	// If we're not using a custom registry, we know the restrictions
 	// applied to repository names and can warn the user in advance.
 	// Custom repositories can have different rules, and we must also
@@ -1273,9 +1608,22 @@ func (cli *DockerCli) CmdPush(args ...string) error {
 			base64.URLEncoding.EncodeToString(buf),
 		}
 
-		return cli.stream("POST", "/images/"+remote+"/push?"+v.Encode(), nil, cli.out, map[string][]string{
+		if *untrusted {
+			return cli.stream("POST", "/images/"+remote+"/push?"+v.Encode(), nil, cli.out, map[string][]string{
+				"X-Registry-Auth": registryAuthHeader,
+			})
+		}
+		// sign the digests of the pushed tags
+		pushed := newPushedDigests(cli.out)
+		if err := cli.stream("POST", "/images/"+remote+"/push?"+v.Encode(), nil, pushed, map[string][]string{
 			"X-Registry-Auth": registryAuthHeader,
-		})
+		}); err != nil {
+			return err
+		}
+		if len(pushed.tags) == 0 {
+			return fmt.Errorf("No digest of %s to sign, content trust requires a v2 registry", name)
+		}
+		return cli.publishTrustedTargets(repoInfo, authConfig, pushed.tags)
 	}
 
 	if err := push(authConfig); err != nil {
@@ -1284,7 +1632,7 @@ func (cli *DockerCli) CmdPush(args ...string) error {
 			if err := cli.CmdLogin(repoInfo.Index.GetAuthConfigKey()); err != nil {
 				return err
 			}
-			authConfig := cli.configFile.ResolveAuthConfig(repoInfo.Index)
+			authConfig := cli.resolveAuthConfig(repoInfo.Index)
 			return push(authConfig)
 		}
 		return err
@@ -1293,8 +1641,9 @@ func (cli *DockerCli) CmdPush(args ...string) error {
 }
 
 func (cli *DockerCli) CmdPull(args ...string) error {
-	cmd := cli.Subcmd("pull", "NAME[:TAG]", "Pull an image or a repository from the registry", true)
+	cmd := cli.Subcmd("pull", "NAME[:TAG|@DIGEST]", "Pull an image or a repository from the registry", true)
 	allTags := cmd.Bool([]string{"a", "-all-tags"}, false, "Download all tagged images in the repository")
+	untrusted := addTrustedFlags(cmd, true)
 	cmd.Require(flag.Exact, 1)
 
 	utils.ParseFlags(cmd, args, true)
@@ -1323,9 +1672,20 @@ func (cli *DockerCli) CmdPull(args ...string) error {
 	cli.LoadConfigFile()
 
 	// Resolve the Auth config relevant for this server
-	authConfig := cli.configFile.ResolveAuthConfig(repoInfo.Index)
+	authConfig := cli.resolveAuthConfig(repoInfo.Index)
+
+	// the images pulled by digest need no verification
+	_, err = digest.ParseDigest(tag)
+	trusted := !*untrusted && err != nil
 
 	pull := func(authConfig registry.AuthConfig) error {
+		if trusted {
+			trustedTag := tag
+			if trustedTag == "" && !*allTags {
+				trustedTag = graph.DEFAULTTAG
+			}
+			return cli.trustedPull(repoInfo, taglessRemote, trustedTag, authConfig, cli.out)
+		}
 		buf, err := json.Marshal(authConfig)
 		if err != nil {
 			return err
@@ -1345,7 +1705,7 @@ func (cli *DockerCli) CmdPull(args ...string) error {
 			if err := cli.CmdLogin(repoInfo.Index.GetAuthConfigKey()); err != nil {
 				return err
 			}
-			authConfig := cli.configFile.ResolveAuthConfig(repoInfo.Index)
+			authConfig := cli.resolveAuthConfig(repoInfo.Index)
 			return pull(authConfig)
 		}
 		return err
@@ -1596,6 +1956,7 @@ func (cli *DockerCli) CmdPs(args ...string) error {
 		since    = cmd.String([]string{"#sinceId", "#-since-id", "-since"}, "", "Show created since Id or Name, include non-running")
 		before   = cmd.String([]string{"#beforeId", "#-before-id", "-before"}, "", "Show only container created before Id or Name")
 		last     = cmd.Int([]string{"n"}, -1, "Show n last created containers, include non-running")
+		format   = cmd.String([]string{"-format"}, "", "Pretty-print containers using a Go template")
 		flFilter = opts.NewListOpts(nil)
 	)
 	cmd.Require(flag.Exact, 0)
@@ -1654,6 +2015,15 @@ func (cli *DockerCli) CmdPs(args ...string) error {
 		return err
 	}
 
+	if !*quiet {
+		if *format == "" {
+			*format = cli.loadConfig().PsFormat
+		}
+		if *format != "" {
+			return formatPs(cli.out, *format, outs.Data, !*noTrunc)
+		}
+	}
+
 	w := tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
 	if !*quiet {
 		fmt.Fprint(w, "CONTAINER ID\tIMAGE\tCOMMAND\tCREATED\tSTATUS\tPORTS\tNAMES")
@@ -1803,13 +2173,13 @@ func (cli *DockerCli) CmdEvents(args ...string) error {
 	until := cmd.String([]string{"-until"}, "", "Stream events until this timestamp")
 	flFilter := opts.NewListOpts(nil)
 	cmd.Var(&flFilter, []string{"f", "-filter"}, "Filter output based on conditions provided")
+	format := cmd.String([]string{"-format"}, "", "Format the output using the given go template")
 	cmd.Require(flag.Exact, 0)
 
 	utils.ParseFlags(cmd, args, true)
 
 	var (
 		v               = url.Values{}
-		loc             = time.FixedZone(time.Now().Zone())
 		eventFilterArgs = filters.Args{}
 	)
 
@@ -1822,22 +2192,11 @@ func (cli *DockerCli) CmdEvents(args ...string) error {
 			return err
 		}
 	}
-	var setTime = func(key, value string) {
-		format := timeutils.RFC3339NanoFixed
-		if len(value) < len(format) {
-			format = format[:len(value)]
-		}
-		if t, err := time.ParseInLocation(format, value, loc); err == nil {
-			v.Set(key, strconv.FormatInt(t.Unix(), 10))
-		} else {
-			v.Set(key, value)
-		}
-	}
 	if *since != "" {
-		setTime("since", *since)
+		v.Set("since", localTimestamp(*since))
 	}
 	if *until != "" {
-		setTime("until", *until)
+		v.Set("until", localTimestamp(*until))
 	}
 	if len(eventFilterArgs) > 0 {
 		filterJson, err := filters.ToParam(eventFilterArgs)
@@ -1846,6 +2205,9 @@ func (cli *DockerCli) CmdEvents(args ...string) error {
 		}
 		v.Set("filters", filterJson)
 	}
+	if *format != "" {
+		return cli.streamEvents("/events?"+v.Encode(), *format, cli.out)
+	}
 	if err := cli.stream("GET", "/events?"+v.Encode(), nil, cli.out, nil); err != nil {
 		return err
 	}
@@ -1936,15 +2298,21 @@ func (cli *DockerCli) CmdLogs(args ...string) error {
 
 func (cli *DockerCli) CmdAttach(args ...string) error {
 	var (
-		cmd     = cli.Subcmd("attach", "CONTAINER", "Attach to a running container", true)
-		noStdin = cmd.Bool([]string{"#nostdin", "-no-stdin"}, false, "Do not attach STDIN")
-		proxy   = cmd.Bool([]string{"#sig-proxy", "-sig-proxy"}, true, "Proxy all received signals to the process")
+		cmd          = cli.Subcmd("attach", "CONTAINER", "Attach to a running container", true)
+		noStdin      = cmd.Bool([]string{"#nostdin", "-no-stdin"}, false, "Do not attach STDIN")
+		proxy        = cmd.Bool([]string{"#sig-proxy", "-sig-proxy"}, true, "Proxy all received signals to the process")
+		flDetachKeys = cmd.String([]string{"-detach-keys"}, "", "Override the key sequence for detaching a container")
 	)
 	cmd.Require(flag.Exact, 1)
 
 	utils.ParseFlags(cmd, args, true)
 	name := cmd.Arg(0)
 
+	keys, keysSet, err := cli.detachKeys(cmd, *flDetachKeys)
+	if err != nil {
+		return err
+	}
+
 	stream, _, err := cli.call("GET", "/containers/"+name+"/json", nil, false)
 	if err != nil {
 		return err
@@ -1985,6 +2353,9 @@ func (cli *DockerCli) CmdAttach(args ...string) error {
 
 	v.Set("stdout", "1")
 	v.Set("stderr", "1")
+	if keysSet {
+		v.Set("detachKeys", keys)
+	}
 
 	if *proxy && !tty {
 		sigc := cli.forwardAllSignals(cmd.Arg(0))
@@ -2110,7 +2481,7 @@ func (cli *DockerCli) pullImageCustomOut(image string, out io.Writer) error {
 	cli.LoadConfigFile()
 
 	// Resolve the Auth config relevant for this server
-	authConfig := cli.configFile.ResolveAuthConfig(repoInfo.Index)
+	authConfig := cli.resolveAuthConfig(repoInfo.Index)
 	buf, err := json.Marshal(authConfig)
 	if err != nil {
 		return err
@@ -2164,12 +2535,28 @@ func (cid *cidFile) Write(id string) error {
 	return nil
 }
 
-func (cli *DockerCli) createContainer(config *runconfig.Config, hostConfig *runconfig.HostConfig, cidfile, name string) (*types.ContainerCreateResponse, error) {
+func (cli *DockerCli) createContainer(config *runconfig.Config, hostConfig *runconfig.HostConfig, cidfile, name string, trusted bool) (*types.ContainerCreateResponse, error) {
 	containerValues := url.Values{}
 	if name != "" {
 		containerValues.Set("name", name)
 	}
 
+	// with content trust, the container is created from the digest of the
+	// tag signed by the publisher
+	repo, tag := parsers.ParseRepositoryTag(config.Image)
+	if tag == "" {
+		tag = graph.DEFAULTTAG
+	}
+	var trustedDigest digest.Digest
+	if _, err := digest.ParseDigest(tag); trusted && err != nil {
+		ref, err := cli.trustedReference(repo, tag)
+		if err != nil {
+			return nil, err
+		}
+		trustedDigest = digest.Digest(ref[len(repo)+1:])
+		config.Image = ref
+	}
+
 	mergedConfig := runconfig.MergeConfigs(config, hostConfig)
 
 	var containerIDFile *cidFile
@@ -2185,16 +2572,17 @@ func (cli *DockerCli) createContainer(config *runconfig.Config, hostConfig *runc
 	stream, statusCode, err := cli.call("POST", "/containers/create?"+containerValues.Encode(), mergedConfig, false)
 	//if image not found try to pull it
 	if statusCode == 404 {
-		repo, tag := parsers.ParseRepositoryTag(config.Image)
-		if tag == "" {
-			tag = graph.DEFAULTTAG
-		}
-		fmt.Fprintf(cli.err, "Unable to find image '%s:%s' locally\n", repo, tag)
+		fmt.Fprintf(cli.err, "Unable to find image '%s' locally\n", parsers.JoinRepositoryTag(repo, tag))
 
 		// we don't want to write to stdout anything apart from container.ID
 		if err = cli.pullImageCustomOut(config.Image, cli.err); err != nil {
 			return nil, err
 		}
+		if trustedDigest != "" {
+			if err := cli.tagTrusted(repo, tag, trustedDigest, cli.err); err != nil {
+				return nil, err
+			}
+		}
 		// Retry
 		if stream, _, err = cli.call("POST", "/containers/create?"+containerValues.Encode(), mergedConfig, false); err != nil {
 			return nil, err
@@ -2223,7 +2611,8 @@ func (cli *DockerCli) CmdCreate(args ...string) error {
 
 	// These are flags not stored in Config/HostConfig
 	var (
-		flName = cmd.String([]string{"-name"}, "", "Assign a name to the container")
+		flName    = cmd.String([]string{"-name"}, "", "Assign a name to the container")
+		untrusted = addTrustedFlags(cmd, true)
 	)
 
 	config, hostConfig, cmd, err := runconfig.Parse(cmd, args)
@@ -2234,7 +2623,7 @@ func (cli *DockerCli) CmdCreate(args ...string) error {
 		cmd.Usage()
 		return nil
 	}
-	response, err := cli.createContainer(config, hostConfig, hostConfig.ContainerIDFile, *flName)
+	response, err := cli.createContainer(config, hostConfig, hostConfig.ContainerIDFile, *flName, !*untrusted)
 	if err != nil {
 		return err
 	}
@@ -2248,15 +2637,14 @@ func (cli *DockerCli) CmdRun(args ...string) error {
 
 	// These are flags not stored in Config/HostConfig
 	var (
-		flAutoRemove = cmd.Bool([]string{"#rm", "-rm"}, false, "Automatically remove the container when it exits")
 		flDetach     = cmd.Bool([]string{"d", "-detach"}, false, "Run container in background and print container ID")
 		flSigProxy   = cmd.Bool([]string{"#sig-proxy", "-sig-proxy"}, true, "Proxy received signals to the process")
 		flName       = cmd.String([]string{"#name", "-name"}, "", "Assign a name to the container")
+		flDetachKeys = cmd.String([]string{"-detach-keys"}, "", "Override the key sequence for detaching a container")
+		untrusted    = addTrustedFlags(cmd, true)
 		flAttach     *opts.ListOpts
 
-		ErrConflictAttachDetach               = fmt.Errorf("Conflicting options: -a and -d")
-		ErrConflictRestartPolicyAndAutoRemove = fmt.Errorf("Conflicting options: --restart and --rm")
-		ErrConflictDetachAutoRemove           = fmt.Errorf("Conflicting options: --rm and -d")
+		ErrConflictAttachDetach = fmt.Errorf("Conflicting options: -a and -d")
 	)
 
 	config, hostConfig, cmd, err := runconfig.Parse(cmd, args)
@@ -2269,6 +2657,11 @@ func (cli *DockerCli) CmdRun(args ...string) error {
 		return nil
 	}
 
+	keys, keysSet, err := cli.detachKeys(cmd, *flDetachKeys)
+	if err != nil {
+		return err
+	}
+
 	if !*flDetach {
 		if err := cli.CheckTtyInput(config.AttachStdin, config.Tty); err != nil {
 			return err
@@ -2280,9 +2673,6 @@ func (cli *DockerCli) CmdRun(args ...string) error {
 				return ErrConflictAttachDetach
 			}
 		}
-		if *flAutoRemove {
-			return ErrConflictDetachAutoRemove
-		}
 
 		config.AttachStdin = false
 		config.AttachStdout = false
@@ -2296,7 +2686,7 @@ func (cli *DockerCli) CmdRun(args ...string) error {
 		sigProxy = false
 	}
 
-	createResponse, err := cli.createContainer(config, hostConfig, hostConfig.ContainerIDFile, *flName)
+	createResponse, err := cli.createContainer(config, hostConfig, hostConfig.ContainerIDFile, *flName, !*untrusted)
 	if err != nil {
 		return err
 	}
@@ -2316,9 +2706,6 @@ func (cli *DockerCli) CmdRun(args ...string) error {
 			fmt.Fprintf(cli.out, "%s\n", createResponse.ID)
 		}()
 	}
-	if *flAutoRemove && (hostConfig.RestartPolicy.Name == "always" || hostConfig.RestartPolicy.Name == "on-failure") {
-		return ErrConflictRestartPolicyAndAutoRemove
-	}
 	// We need to instantiate the chan because the select needs it. It can
 	// be closed but can't be uninitialized.
 	hijacked := make(chan io.Closer)
@@ -2340,6 +2727,9 @@ func (cli *DockerCli) CmdRun(args ...string) error {
 			v.Set("stdin", "1")
 			in = cli.in
 		}
+		if keysSet {
+			v.Set("detachKeys", keys)
+		}
 		if config.AttachStdout {
 			v.Set("stdout", "1")
 			out = cli.out
@@ -2373,6 +2763,20 @@ func (cli *DockerCli) CmdRun(args ...string) error {
 		}
 	}
 
+	// The daemon removes the container as soon as it exits, so the wait for
+	// its exit code has to be in place before it is started
+	var (
+		removedStatus int
+		removedErrCh  chan error
+	)
+	if hostConfig.AutoRemove && (config.AttachStdout || config.AttachStderr) {
+		removedErrCh = promise.Go(func() error {
+			var err error
+			removedStatus, err = waitForCondition(cli, createResponse.ID, url.Values{"condition": {"removed"}})
+			return err
+		})
+	}
+
 	//start the container
 	if _, _, err = readBody(cli.call("POST", "/containers/"+createResponse.ID+"/start", nil, false)); err != nil {
 		return err
@@ -2401,18 +2805,13 @@ func (cli *DockerCli) CmdRun(args ...string) error {
 	var status int
 
 	// Attached mode
-	if *flAutoRemove {
-		// Autoremove: wait for the container to finish, retrieve
-		// the exit code and remove the container
-		if _, _, err := readBody(cli.call("POST", "/containers/"+createResponse.ID+"/wait", nil, false)); err != nil {
-			return err
-		}
-		if _, status, err = getExitCode(cli, createResponse.ID); err != nil {
-			return err
-		}
-		if _, _, err := readBody(cli.call("DELETE", "/containers/"+createResponse.ID+"?v=1", nil, false)); err != nil {
+	if hostConfig.AutoRemove {
+		// Autoremove: the daemon removes the container, wait for it
+		// and retrieve the exit code
+		if err := <-removedErrCh; err != nil {
 			return err
 		}
+		status = removedStatus
 	} else {
 		// No Autoremove: Simply retrieve the exit code
 		if !config.Tty {
@@ -2435,7 +2834,8 @@ func (cli *DockerCli) CmdRun(args ...string) error {
 }
 
 func (cli *DockerCli) CmdCp(args ...string) error {
-	cmd := cli.Subcmd("cp", "CONTAINER:PATH HOSTPATH|-", "Copy files/folders from the PATH to the HOSTPATH. Use '-' to write the data\nas a tar file to STDOUT.", true)
+	cmd := cli.Subcmd("cp", "CONTAINER:PATH HOSTPATH|-|CONTAINER:PATH", "Copy files/folders from the PATH to the HOSTPATH, or to the PATH of another container.\nUse '-' to write the data as a tar file to STDOUT.", true)
+	archiveMode := cmd.Bool([]string{"a", "-archive"}, false, "Archive mode (copy all uid/gid information and extended attributes)")
 	cmd.Require(flag.Exact, 2)
 
 	utils.ParseFlags(cmd, args, true)
@@ -2448,6 +2848,22 @@ func (cli *DockerCli) CmdCp(args ...string) error {
 	}
 
 	copyData.Set("Resource", info[1])
+	copyData.SetBool("Archive", *archiveMode)
+
+	// the files are copied from a container to another by the daemon
+	if dstContainer, dstPath := splitCpArg(cmd.Arg(1)); dstContainer != "" {
+		if dstPath == "" {
+			return fmt.Errorf("Error: Destination path not specified")
+		}
+		copyData.Set("Container", dstContainer)
+		copyData.Set("Path", dstPath)
+		_, statusCode, err := readBody(cli.call("POST", "/containers/"+info[0]+"/copyto", copyData, false))
+		if statusCode == 404 {
+			return fmt.Errorf("No such container: %v", info[0])
+		}
+		return err
+	}
+
 	copyData.Set("HostPath", cmd.Arg(1))
 
 	stream, statusCode, err := cli.call("POST", "/containers/"+info[0]+"/copy", copyData, false)
@@ -2467,7 +2883,7 @@ func (cli *DockerCli) CmdCp(args ...string) error {
 		if dest == "-" {
 			_, err = io.Copy(cli.out, stream)
 		} else {
-			err = archive.Untar(stream, dest, &archive.TarOptions{NoLchown: true})
+			err = archive.Untar(stream, dest, &archive.TarOptions{NoLchown: !*archiveMode})
 		}
 		if err != nil {
 			return err
@@ -2476,13 +2892,42 @@ func (cli *DockerCli) CmdCp(args ...string) error {
 	return nil
 }
 
+// splitCpArg splits a CONTAINER:PATH argument of docker cp, the container is
+// empty for a host path. The host paths containing a ':' are given as
+// absolute or relative paths, like /tmp/a:b or ./a:b.
+func splitCpArg(arg string) (string, string) {
+	if filepath.IsAbs(arg) || strings.HasPrefix(arg, ".") {
+		return "", arg
+	}
+	parts := strings.SplitN(arg, ":", 2)
+	if len(parts) == 1 || strings.Contains(parts[0], "/") {
+		return "", arg
+	}
+	return parts[0], parts[1]
+}
+
 func (cli *DockerCli) CmdSave(args ...string) error {
 	cmd := cli.Subcmd("save", "IMAGE [IMAGE...]", "Save an image(s) to a tar archive (streamed to STDOUT by default)", true)
 	outfile := cmd.String([]string{"o", "-output"}, "", "Write to an file, instead of STDOUT")
+	compress := cmd.String([]string{"-compress"}, "none", "Compress the layers with none, gzip or zstd")
+	flExcludeFrom := opts.NewListOpts(nil)
+	cmd.Var(&flExcludeFrom, []string{"-exclude-from"}, "Leave out the images already in a saved archive")
 	cmd.Require(flag.Min, 1)
 
 	utils.ParseFlags(cmd, args, true)
 
+	v := url.Values{}
+	v.Set("compress", *compress)
+	for _, file := range flExcludeFrom.GetAll() {
+		ids, err := savedImageIDs(file)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			v.Add("exclude", id)
+		}
+	}
+
 	var (
 		output io.Writer = cli.out
 		err    error
@@ -2498,11 +2943,10 @@ func (cli *DockerCli) CmdSave(args ...string) error {
 
 	if len(cmd.Args()) == 1 {
 		image := cmd.Arg(0)
-		if err := cli.stream("GET", "/images/"+image+"/get", nil, output, nil); err != nil {
+		if err := cli.stream("GET", "/images/"+image+"/get?"+v.Encode(), nil, output, nil); err != nil {
 			return err
 		}
 	} else {
-		v := url.Values{}
 		for _, arg := range cmd.Args() {
 			v.Add("names", arg)
 		}
@@ -2516,6 +2960,8 @@ func (cli *DockerCli) CmdSave(args ...string) error {
 func (cli *DockerCli) CmdLoad(args ...string) error {
 	cmd := cli.Subcmd("load", "", "Load an image from a tar archive on STDIN", true)
 	infile := cmd.String([]string{"i", "-input"}, "", "Read from a tar archive file, instead of STDIN")
+	quiet := cmd.Bool([]string{"q", "-quiet"}, false, "Suppress the load progress")
+	verify := cmd.Bool([]string{"-verify"}, false, "Require a manifest signed with a trusted key")
 	cmd.Require(flag.Exact, 0)
 
 	utils.ParseFlags(cmd, args, true)
@@ -2530,7 +2976,14 @@ func (cli *DockerCli) CmdLoad(args ...string) error {
 			return err
 		}
 	}
-	if err := cli.stream("POST", "/images/load", input, cli.out, nil); err != nil {
+	v := url.Values{}
+	if !*quiet {
+		v.Set("quiet", "0")
+	}
+	if *verify {
+		v.Set("verify", "1")
+	}
+	if err := cli.stream("POST", "/images/load?"+v.Encode(), input, cli.out, nil); err != nil {
 		return err
 	}
 	return nil
@@ -2539,12 +2992,22 @@ func (cli *DockerCli) CmdLoad(args ...string) error {
 func (cli *DockerCli) CmdExec(args ...string) error {
 	cmd := cli.Subcmd("exec", "CONTAINER COMMAND [ARG...]", "Run a command in a running container", true)
 
+	flDetachKeys := cmd.String([]string{"-detach-keys"}, "", "Override the key sequence for detaching a container")
+
 	execConfig, err := runconfig.ParseExec(cmd, args)
 	// just in case the ParseExec does not exit
 	if execConfig.Container == "" || err != nil {
 		return &utils.StatusError{StatusCode: 1}
 	}
 
+	keys, keysSet, err := cli.detachKeys(cmd, *flDetachKeys)
+	if err != nil {
+		return err
+	}
+	if keysSet {
+		execConfig.DetachKeys = &keys
+	}
+
 	stream, _, err := cli.call("POST", "/containers/"+execConfig.Container+"/exec", execConfig, false)
 	if err != nil {
 		return err
@@ -2647,30 +3110,30 @@ func (cli *DockerCli) CmdExec(args ...string) error {
 }
 
 type containerStats struct {
-	Name             string
-	CpuPercentage    float64
-	Memory           float64
-	MemoryLimit      float64
-	MemoryPercentage float64
-	NetworkRx        float64
-	NetworkTx        float64
-	mu               sync.RWMutex
-	err              error
+	Name   string
+	sample types.StatsSample
+	mu     sync.RWMutex
+	err    error
 }
 
-func (s *containerStats) Collect(cli *DockerCli) {
-	stream, _, err := cli.call("GET", "/containers/"+s.Name+"/stats", nil, false)
+func (s *containerStats) Collect(cli *DockerCli, streamStats bool) {
+	v := url.Values{}
+	if !streamStats {
+		v.Set("stream", "0")
+	}
+	stream, _, err := cli.call("GET", "/containers/"+s.Name+"/stats?"+v.Encode(), nil, false)
 	if err != nil {
+		s.mu.Lock()
 		s.err = err
+		s.mu.Unlock()
 		return
 	}
 	defer stream.Close()
 	var (
-		previousCpu    uint64
-		previousSystem uint64
-		start          = true
-		dec            = json.NewDecoder(stream)
-		u              = make(chan error, 1)
+		previousCpu types.CpuStats
+		start       = true
+		dec         = json.NewDecoder(stream)
+		u           = make(chan error, 1)
 	)
 	go func() {
 		for {
@@ -2679,25 +3142,19 @@ func (s *containerStats) Collect(cli *DockerCli) {
 				u <- err
 				return
 			}
-			var (
-				memPercent = float64(v.MemoryStats.Usage) / float64(v.MemoryStats.Limit) * 100.0
-				cpuPercent = 0.0
-			)
-			if !start {
-				cpuPercent = calculateCpuPercent(previousCpu, previousSystem, v)
+			if v.PreCpuStats.SystemUsage == 0 && !start {
+				// older daemons don't report the previous cpu usage
+				v.PreCpuStats = previousCpu
 			}
 			start = false
 			s.mu.Lock()
-			s.CpuPercentage = cpuPercent
-			s.Memory = float64(v.MemoryStats.Usage)
-			s.MemoryLimit = float64(v.MemoryStats.Limit)
-			s.MemoryPercentage = memPercent
-			s.NetworkRx = float64(v.Network.RxBytes)
-			s.NetworkTx = float64(v.Network.TxBytes)
+			s.sample = *types.NewStatsSample(v)
 			s.mu.Unlock()
-			previousCpu = v.CpuStats.CpuUsage.TotalUsage
-			previousSystem = v.CpuStats.SystemUsage
+			previousCpu = v.CpuStats
 			u <- nil
+			if !streamStats {
+				return
+			}
 		}
 	}()
 	for {
@@ -2706,9 +3163,9 @@ func (s *containerStats) Collect(cli *DockerCli) {
 			// zero out the values if we have not received an update within
 			// the specified duration.
 			s.mu.Lock()
-			s.CpuPercentage = 0
-			s.Memory = 0
-			s.MemoryPercentage = 0
+			s.sample.CpuPercentage = 0
+			s.sample.Memory = 0
+			s.sample.MemoryPercentage = 0
 			s.mu.Unlock()
 		case err := <-u:
 			if err != nil {
@@ -2717,45 +3174,43 @@ func (s *containerStats) Collect(cli *DockerCli) {
 				s.mu.Unlock()
 				return
 			}
+			if !streamStats {
+				return
+			}
 		}
 	}
 }
 
-func (s *containerStats) Display(w io.Writer) error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	if s.err != nil {
-		return s.err
-	}
-	fmt.Fprintf(w, "%s\t%.2f%%\t%s/%s\t%.2f%%\t%s/%s\n",
-		s.Name,
-		s.CpuPercentage,
-		units.BytesSize(s.Memory), units.BytesSize(s.MemoryLimit),
-		s.MemoryPercentage,
-		units.BytesSize(s.NetworkRx), units.BytesSize(s.NetworkTx))
-	return nil
-}
-
 func (cli *DockerCli) CmdStats(args ...string) error {
 	cmd := cli.Subcmd("stats", "CONTAINER [CONTAINER...]", "Display a live stream of one or more containers' resource usage statistics", true)
+	noStream := cmd.Bool([]string{"-no-stream"}, false, "Disable streaming stats and only pull the first result")
+	format := cmd.String([]string{"-format"}, "", "Pretty-print the stats using a Go template")
 	cmd.Require(flag.Min, 1)
 	utils.ParseFlags(cmd, args, true)
 
+	if *format == "" {
+		*format = cli.loadConfig().StatsFormat
+	}
+	if *format == "" {
+		*format = defaultStatsFormat
+	}
+
 	names := cmd.Args()
 	sort.Strings(names)
-	var (
-		cStats []*containerStats
-		w      = tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
-	)
-	printHeader := func() {
-		fmt.Fprint(cli.out, "\033[2J")
-		fmt.Fprint(cli.out, "\033[H")
-		fmt.Fprintln(w, "CONTAINER\tCPU %\tMEM USAGE/LIMIT\tMEM %\tNET I/O")
+
+	if *noStream {
+		samples, err := cli.sampleStats(names)
+		if err != nil {
+			return err
+		}
+		return formatStats(cli.out, *format, sampledNames(names, samples), samples)
 	}
+
+	var cStats []*containerStats
 	for _, n := range names {
 		s := &containerStats{Name: n}
 		cStats = append(cStats, s)
-		go s.Collect(cli)
+		go s.Collect(cli, true)
 	}
 	// do a quick pause so that any failed connections for containers that do not exist are able to be
 	// evicted before we display the initial or default values.
@@ -2772,36 +3227,30 @@ func (cli *DockerCli) CmdStats(args ...string) error {
 		return fmt.Errorf("%s", strings.Join(errs, ", "))
 	}
 	for _ = range time.Tick(500 * time.Millisecond) {
-		printHeader()
-		toRemove := []int{}
-		for i, s := range cStats {
-			if err := s.Display(w); err != nil {
-				toRemove = append(toRemove, i)
+		var (
+			running []*containerStats
+			names   []string
+			samples []*types.StatsSample
+		)
+		for _, s := range cStats {
+			s.mu.RLock()
+			if s.err == nil {
+				sample := s.sample
+				running = append(running, s)
+				names = append(names, s.Name)
+				samples = append(samples, &sample)
 			}
+			s.mu.RUnlock()
 		}
-		for j := len(toRemove) - 1; j >= 0; j-- {
-			i := toRemove[j]
-			cStats = append(cStats[:i], cStats[i+1:]...)
-		}
+		cStats = running
 		if len(cStats) == 0 {
 			return nil
 		}
-		w.Flush()
+		fmt.Fprint(cli.out, "\033[2J")
+		fmt.Fprint(cli.out, "\033[H")
+		if err := formatStats(cli.out, *format, names, samples); err != nil {
+			return err
+		}
 	}
 	return nil
 }
-
-func calculateCpuPercent(previousCpu, previousSystem uint64, v *types.Stats) float64 {
-	var (
-		cpuPercent = 0.0
-		// calculate the change for the cpu usage of the container in between readings
-		cpuDelta = float64(v.CpuStats.CpuUsage.TotalUsage - previousCpu)
-		// calculate the change for the entire system between readings
-		systemDelta = float64(v.CpuStats.SystemUsage - previousSystem)
-	)
-
-	if systemDelta > 0.0 && cpuDelta > 0.0 {
-		cpuPercent = (cpuDelta / systemDelta) * float64(len(v.CpuStats.CpuUsage.PercpuUsage)) * 100.0
-	}
-	return cpuPercent
-}
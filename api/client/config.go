@@ -0,0 +1,58 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker/pkg/homedir"
+)
+
+// clientConfig is the configuration of the client, in ~/.docker/config.json
+type clientConfig struct {
+	// PsFormat is the default template of the output of docker ps
+	PsFormat string `json:"psFormat,omitempty"`
+	// StatsFormat is the default template of the output of docker stats
+	StatsFormat string `json:"statsFormat,omitempty"`
+	// CredsStore is the credential helper keeping the credentials of the
+	// registries instead of ~/.dockercfg
+	CredsStore string `json:"credsStore,omitempty"`
+	// CredHelpers are the credential helpers of some registries, by their
+	// hostnames, they override CredsStore
+	CredHelpers map[string]string `json:"credHelpers,omitempty"`
+	// DetachKeys is the default key sequence to detach from a container
+	DetachKeys string `json:"detachKeys,omitempty"`
+}
+
+func clientConfigPath() string {
+	return filepath.Join(homedir.Get(), ".docker", "config.json")
+}
+
+// loadClientConfig reads the configuration of the client, it is empty when
+// there is no configuration file
+func loadClientConfig() (*clientConfig, error) {
+	config := &clientConfig{}
+	b, err := ioutil.ReadFile(clientConfigPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return config, err
+	}
+	if err := json.Unmarshal(b, config); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// loadConfig returns the configuration of the client, warning when it cannot
+// be read
+func (cli *DockerCli) loadConfig() *clientConfig {
+	config, err := loadClientConfig()
+	if err != nil {
+		fmt.Fprintf(cli.err, "WARNING: Error loading %s: %s\n", clientConfigPath(), err)
+	}
+	return config
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/docker/docker/registry"
+)
+
+// credentialHelperPrefix is the prefix of the executables of the credential
+// helpers, the helper NAME is run as docker-credential-NAME
+const credentialHelperPrefix = "docker-credential-"
+
+// credentialsNotFound is the output of the helpers that have no credentials
+// for a server
+const credentialsNotFound = "credentials not found in native keychain"
+
+var errCredentialsNotFound = errors.New(credentialsNotFound)
+
+// helperCredentials are the credentials the helpers read and write
+type helperCredentials struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// runCredentialHelper runs the action of the helper with the input, and
+// returns its output
+func runCredentialHelper(helper, action string, input []byte) ([]byte, error) {
+	cmd := exec.Command(credentialHelperPrefix+helper, action)
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == credentialsNotFound {
+			return nil, errCredentialsNotFound
+		}
+		if msg == "" {
+			msg = strings.TrimSpace(stderr.String())
+		}
+		return nil, fmt.Errorf("Error running the credential helper %s %s: %v: %s", helper, action, err, msg)
+	}
+	return out, nil
+}
+
+// helperGet returns the credentials of the server kept by the helper, empty
+// when it has none
+func helperGet(helper, server string) (registry.AuthConfig, error) {
+	out, err := runCredentialHelper(helper, "get", []byte(server))
+	if err != nil {
+		if err == errCredentialsNotFound {
+			return registry.AuthConfig{}, nil
+		}
+		return registry.AuthConfig{}, err
+	}
+	var creds helperCredentials
+	if err := json.Unmarshal(out, &creds); err != nil {
+		return registry.AuthConfig{}, fmt.Errorf("Invalid output of the credential helper %s: %v", helper, err)
+	}
+	return registry.AuthConfig{
+		Username:      creds.Username,
+		Password:      creds.Secret,
+		ServerAddress: server,
+	}, nil
+}
+
+// helperStore stores the credentials of the server with the helper
+func helperStore(helper string, authConfig registry.AuthConfig) error {
+	b, err := json.Marshal(helperCredentials{
+		ServerURL: authConfig.ServerAddress,
+		Username:  authConfig.Username,
+		Secret:    authConfig.Password,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = runCredentialHelper(helper, "store", b)
+	return err
+}
+
+// helperErase removes the credentials of the server from the helper
+func helperErase(helper, server string) error {
+	_, err := runCredentialHelper(helper, "erase", []byte(server))
+	if err == errCredentialsNotFound {
+		return nil
+	}
+	return err
+}
+
+// helperList returns the servers the helper has credentials of
+func helperList(helper string) ([]string, error) {
+	out, err := runCredentialHelper(helper, "list", nil)
+	if err != nil {
+		return nil, err
+	}
+	var servers map[string]string
+	if err := json.Unmarshal(out, &servers); err != nil {
+		return nil, fmt.Errorf("Invalid output of the credential helper %s: %v", helper, err)
+	}
+	var list []string
+	for server := range servers {
+		list = append(list, server)
+	}
+	return list, nil
+}
+
+// serverHostname returns the hostname of a registry address, which may be
+// a URL
+func serverHostname(server string) string {
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "http://"), "https://")
+	return strings.SplitN(server, "/", 2)[0]
+}
+
+// credentialHelper returns the credential helper of the server, empty when
+// its credentials are kept in ~/.dockercfg
+func (config *clientConfig) credentialHelper(server string) string {
+	if helper, ok := config.CredHelpers[server]; ok {
+		return helper
+	}
+	if helper, ok := config.CredHelpers[serverHostname(server)]; ok {
+		return helper
+	}
+	return config.CredsStore
+}
+
+// authConfig returns the credentials of the server, from its credential
+// helper when it has one and from ~/.dockercfg otherwise. The configuration
+// file must be loaded.
+func (cli *DockerCli) authConfig(server string) registry.AuthConfig {
+	if helper := cli.loadConfig().credentialHelper(server); helper != "" {
+		authConfig, err := helperGet(helper, server)
+		if err != nil {
+			fmt.Fprintf(cli.err, "WARNING: %s\n", err)
+		}
+		if authConfig.Username != "" {
+			return authConfig
+		}
+	}
+	return cli.configFile.Configs[server]
+}
+
+// resolveAuthConfig returns the credentials of the registry of the index,
+// from its credential helper when it has one. The configuration file must be
+// loaded.
+func (cli *DockerCli) resolveAuthConfig(index *registry.IndexInfo) registry.AuthConfig {
+	if authConfig := cli.authConfig(index.GetAuthConfigKey()); authConfig.Username != "" {
+		return authConfig
+	}
+	return cli.configFile.ResolveAuthConfig(index)
+}
+
+// allAuthConfigs returns the configuration file with the credentials of the
+// credential helpers added, for the builds which may pull from any registry.
+// The configuration file must be loaded.
+func (cli *DockerCli) allAuthConfigs() *registry.ConfigFile {
+	config := cli.loadConfig()
+	all := &registry.ConfigFile{Configs: make(map[string]registry.AuthConfig)}
+	for server, authConfig := range cli.configFile.Configs {
+		all.Configs[server] = authConfig
+	}
+
+	servers := make(map[string]string)
+	for server, helper := range config.CredHelpers {
+		servers[server] = helper
+	}
+	if config.CredsStore != "" {
+		list, err := helperList(config.CredsStore)
+		if err != nil {
+			fmt.Fprintf(cli.err, "WARNING: %s\n", err)
+		}
+		for _, server := range list {
+			if _, ok := servers[server]; !ok {
+				servers[server] = config.CredsStore
+			}
+		}
+	}
+	for server, helper := range servers {
+		authConfig, err := helperGet(helper, server)
+		if err != nil {
+			fmt.Fprintf(cli.err, "WARNING: %s\n", err)
+			continue
+		}
+		if authConfig.Username != "" {
+			all.Configs[server] = authConfig
+		}
+	}
+	return all
+}
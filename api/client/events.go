@@ -0,0 +1,50 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/template"
+
+	"github.com/docker/docker/utils"
+)
+
+// streamEvents writes the events streamed by the daemon to out with the
+// template format, one per line.
+func (cli *DockerCli) streamEvents(path, format string, out io.Writer) error {
+	tmpl, err := template.New("").Funcs(funcMap).Parse(format)
+	if err != nil {
+		return fmt.Errorf("Template parsing error: %v", err)
+	}
+
+	resp, err := cli.streamRequest("GET", path, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var event utils.JSONMessage
+		if err := dec.Decode(&event); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if event.Error != nil {
+			return event.Error
+		}
+		// the older daemons only send the status, the id and the image
+		if event.Action == "" {
+			event.Action = event.Status
+		}
+		if event.Actor == nil {
+			event.Actor = &utils.JSONEventActor{ID: event.ID}
+		}
+		if err := tmpl.Execute(out, &event); err != nil {
+			return fmt.Errorf("Template execution error: %v", err)
+		}
+		fmt.Fprintln(out)
+	}
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+)
+
+// tableFormatPrefix makes a template print its fields as the columns of a
+// table, with a header
+const tableFormatPrefix = "table "
+
+// tableHeader records the fields used by a template, as the header of the
+// table. The contexts of the templates of --format embed it, their methods
+// add the header of their column.
+type tableHeader struct {
+	header []string
+}
+
+func (h *tableHeader) addHeader(name string) {
+	if h.header != nil {
+		h.header = append(h.header, name)
+	}
+}
+
+func (h *tableHeader) headers() *tableHeader {
+	return h
+}
+
+// formatContext is the context of a template of --format
+type formatContext interface {
+	headers() *tableHeader
+}
+
+// formatTemplate writes n rows with the template format, the context of
+// row i is returned by context, and the context of a blank row by
+// context(-1). The template prefixed with "table " writes the rows as a
+// table with a header.
+func formatTemplate(w io.Writer, format string, n int, context func(i int) formatContext) error {
+	table := strings.HasPrefix(format, tableFormatPrefix)
+	if table {
+		format = strings.TrimPrefix(format, tableFormatPrefix)
+		// the escaped tabs of the command line separate the columns
+		format = strings.Replace(format, `\t`, "\t", -1)
+	}
+	tmpl, err := template.New("").Funcs(funcMap).Parse(format)
+	if err != nil {
+		return fmt.Errorf("Template parsing error: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	var header []string
+	for i := 0; i < n; i++ {
+		c := context(i)
+		h := c.headers()
+		if table && i == 0 {
+			h.header = []string{}
+		}
+		if err := tmpl.Execute(buf, c); err != nil {
+			return fmt.Errorf("Template execution error: %v", err)
+		}
+		buf.WriteString("\n")
+		if h.header != nil {
+			header = h.header
+		}
+	}
+
+	if !table {
+		_, err := io.Copy(w, buf)
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 20, 1, 3, ' ', 0)
+	if n == 0 {
+		// the header of an empty table is written from a blank row
+		c := context(-1)
+		c.headers().header = []string{}
+		tmpl.Execute(&bytes.Buffer{}, c)
+		header = c.headers().header
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	if _, err := io.Copy(tw, buf); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/opts"
+	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/pkg/parsers/filters"
+	"github.com/docker/docker/pkg/units"
+	"github.com/docker/docker/utils"
+)
+
+var imageCommands = [][]string{
+	{"prune", "Remove unused images"},
+}
+
+// 'docker image' without a valid subcommand prints the image subcommands
+func (cli *DockerCli) CmdImage(args ...string) error {
+	cmd := cli.Subcmd("image", "COMMAND", imageUsage(), true)
+	cmd.Require(flag.Min, 1)
+
+	utils.ParseFlags(cmd, args, true)
+
+	fmt.Fprintf(cli.err, "docker: '%s' is not a docker image command. See 'docker image --help'.\n", cmd.Arg(0))
+	return &utils.StatusError{StatusCode: 1}
+}
+
+func imageUsage() string {
+	help := "Manage Docker images\n\nCommands:\n"
+	for _, command := range imageCommands {
+		help += fmt.Sprintf("  %-12.12s%s\n", command[0], command[1])
+	}
+	help += "\nRun 'docker image COMMAND --help' for more information on a command."
+	return help
+}
+
+func (cli *DockerCli) CmdImagePrune(args ...string) error {
+	cmd := cli.Subcmd("image prune", "", "Remove unused images", true)
+	all := cmd.Bool([]string{"a", "-all"}, false, "Remove all unused images, not just the dangling ones")
+	force := cmd.Bool([]string{"f", "-force"}, false, "Do not prompt for confirmation")
+	flFilter := opts.NewListOpts(nil)
+	cmd.Var(&flFilter, []string{"-filter"}, "Only remove the images matching the filters provided (until=<timestamp>, label=<key>[=<value>])")
+	cmd.Require(flag.Exact, 0)
+
+	utils.ParseFlags(cmd, args, true)
+
+	imageFilterArgs := filters.Args{}
+	for _, f := range flFilter.GetAll() {
+		var err error
+		if imageFilterArgs, err = filters.ParseFlag(f, imageFilterArgs); err != nil {
+			return err
+		}
+	}
+	for i, value := range imageFilterArgs["until"] {
+		imageFilterArgs["until"][i] = localTimestamp(value)
+	}
+	v := url.Values{}
+	if len(imageFilterArgs) > 0 {
+		filterJSON, err := filters.ToParam(imageFilterArgs)
+		if err != nil {
+			return err
+		}
+		v.Set("filters", filterJSON)
+	}
+	warning := "WARNING! This will remove all dangling images."
+	if *all {
+		v.Set("all", "1")
+		warning = "WARNING! This will remove all images without at least one container associated to them."
+	}
+	if !*force {
+		fmt.Fprintf(cli.out, "%s\nAre you sure you want to continue? [y/N] ", warning)
+		answer, _ := bufio.NewReader(cli.in).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			return nil
+		}
+	}
+
+	body, _, err := readBody(cli.call("POST", "/images/prune?"+v.Encode(), nil, false))
+	if err != nil {
+		return err
+	}
+	var report types.ImagesPruneReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return err
+	}
+	if len(report.ImagesDeleted) > 0 {
+		fmt.Fprintln(cli.out, "Deleted Images:")
+		for _, img := range report.ImagesDeleted {
+			if img.Untagged != "" {
+				fmt.Fprintf(cli.out, "untagged: %s\n", img.Untagged)
+			} else {
+				fmt.Fprintf(cli.out, "deleted: %s\n", img.Deleted)
+			}
+		}
+		fmt.Fprintln(cli.out)
+	}
+	fmt.Fprintf(cli.out, "Total reclaimed space: %s\n", units.HumanSize(float64(report.SpaceReclaimed)))
+	return nil
+}
@@ -0,0 +1,281 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/opts"
+	"github.com/docker/docker/pkg/common"
+	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/utils"
+)
+
+var networkCommands = [][]string{
+	{"connect", "Connect a container to a network"},
+	{"create", "Create a network"},
+	{"disconnect", "Disconnect a container from a network"},
+	{"inspect", "Display detailed information on one or more networks"},
+	{"ls", "List networks"},
+	{"rm", "Remove one or more networks"},
+}
+
+// 'docker network' without a valid subcommand prints the network subcommands
+func (cli *DockerCli) CmdNetwork(args ...string) error {
+	cmd := cli.Subcmd("network", "COMMAND", networkUsage(), true)
+	cmd.Require(flag.Min, 1)
+
+	utils.ParseFlags(cmd, args, true)
+
+	fmt.Fprintf(cli.err, "docker: '%s' is not a docker network command. See 'docker network --help'.\n", cmd.Arg(0))
+	return &utils.StatusError{StatusCode: 1}
+}
+
+func networkUsage() string {
+	help := "Manage Docker networks\n\nCommands:\n"
+	for _, command := range networkCommands {
+		help += fmt.Sprintf("  %-12.12s%s\n", command[0], command[1])
+	}
+	help += "\nRun 'docker network COMMAND --help' for more information on a command."
+	return help
+}
+
+func (cli *DockerCli) CmdNetworkCreate(args ...string) error {
+	cmd := cli.Subcmd("network create", "NETWORK", "Create a network", true)
+	var (
+		flDriver     = cmd.String([]string{"d", "-driver"}, "", "Driver to manage the network")
+		flSubnet     = cmd.String([]string{"-subnet"}, "", "Subnet in CIDR format")
+		flIPRange    = cmd.String([]string{"-ip-range"}, "", "Allocate container ips from a sub-range of the subnet")
+		flGateway    = cmd.String([]string{"-gateway"}, "", "Gateway of the subnet, defaults to its first address")
+		flOpts       = opts.NewListOpts(nil)
+		flDns        = opts.NewListOpts(opts.ValidateIPAddress)
+		flDnsSearch  = opts.NewListOpts(opts.ValidateDnsSearch)
+		flDnsOptions = opts.NewListOpts(nil)
+		flInternal   = cmd.Bool([]string{"-internal"}, false, "Restrict external access to the network")
+		flIPAMDriver = cmd.String([]string{"-ipam-driver"}, "", "IPAM plugin allocating the addresses")
+		flIPAMOpts   = opts.NewListOpts(nil)
+		flLabels     = opts.NewListOpts(opts.ValidateLabel)
+	)
+	cmd.Var(&flOpts, []string{"o", "-opt"}, "Set driver specific options")
+	cmd.Var(&flIPAMOpts, []string{"-ipam-opt"}, "Set IPAM driver specific options")
+	cmd.Var(&flDns, []string{"-dns"}, "Set custom DNS servers for the network")
+	cmd.Var(&flDnsSearch, []string{"-dns-search"}, "Set custom DNS search domains for the network")
+	cmd.Var(&flDnsOptions, []string{"-dns-opt"}, "Set DNS options for the network")
+	cmd.Var(&flLabels, []string{"l", "-label"}, "Set metadata on the network")
+	cmd.Require(flag.Exact, 1)
+
+	utils.ParseFlags(cmd, args, true)
+
+	options, err := parseNetworkOpts(&flOpts)
+	if err != nil {
+		return err
+	}
+	ipamOptions, err := parseNetworkOpts(&flIPAMOpts)
+	if err != nil {
+		return err
+	}
+	labels, err := parseNetworkOpts(&flLabels)
+	if err != nil {
+		return err
+	}
+
+	create := &types.NetworkCreate{
+		Name:     cmd.Arg(0),
+		Driver:   *flDriver,
+		Options:  options,
+		Internal: *flInternal,
+	}
+	if len(labels) > 0 {
+		create.Labels = labels
+	}
+	create.IPAM.Driver = *flIPAMDriver
+	if len(ipamOptions) > 0 {
+		create.IPAM.Options = ipamOptions
+	}
+	if *flSubnet != "" || *flIPRange != "" || *flGateway != "" {
+		create.IPAM.Config = []types.IPAMConfig{{Subnet: *flSubnet, IPRange: *flIPRange, Gateway: *flGateway}}
+	}
+	if flDns.Len() > 0 || flDnsSearch.Len() > 0 || flDnsOptions.Len() > 0 {
+		create.DNS = &types.DNSConfig{
+			Nameservers: flDns.GetAll(),
+			Search:      flDnsSearch.GetAll(),
+			Options:     flDnsOptions.GetAll(),
+		}
+	}
+
+	body, _, err := readBody(cli.call("POST", "/networks/create", create, false))
+	if err != nil {
+		return err
+	}
+	var response types.NetworkCreateResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.out, "%s\n", response.ID)
+	return nil
+}
+
+// parseNetworkOpts parses key=value options, options without a value, such
+// as -o encrypted, are set to "". It also parses the options of the volume
+// drivers.
+func parseNetworkOpts(list *opts.ListOpts) (map[string]string, error) {
+	options := make(map[string]string)
+	for _, opt := range list.GetAll() {
+		parts := strings.SplitN(opt, "=", 2)
+		if parts[0] == "" {
+			return nil, fmt.Errorf("Invalid option %s, expected key=value", opt)
+		}
+		if len(parts) == 1 {
+			parts = append(parts, "")
+		}
+		options[parts[0]] = parts[1]
+	}
+	return options, nil
+}
+
+func (cli *DockerCli) CmdNetworkLs(args ...string) error {
+	cmd := cli.Subcmd("network ls", "", "List networks", true)
+	quiet := cmd.Bool([]string{"q", "-quiet"}, false, "Only display numeric IDs")
+	noTrunc := cmd.Bool([]string{"-no-trunc"}, false, "Don't truncate output")
+	flFilter := opts.NewListOpts(nil)
+	cmd.Var(&flFilter, []string{"f", "-filter"}, "Filter output based on conditions provided")
+	cmd.Require(flag.Exact, 0)
+
+	utils.ParseFlags(cmd, args, true)
+
+	query, err := filterQuery(&flFilter)
+	if err != nil {
+		return err
+	}
+	body, _, err := readBody(cli.call("GET", "/networks"+query, nil, false))
+	if err != nil {
+		return err
+	}
+	var list []types.NetworkResource
+	if err := json.Unmarshal(body, &list); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
+	if !*quiet {
+		fmt.Fprintln(w, "NETWORK ID\tNAME\tDRIVER\tSUBNET")
+	}
+	for _, n := range list {
+		id := n.ID
+		if !*noTrunc {
+			id = common.TruncateID(id)
+		}
+		if *quiet {
+			fmt.Fprintln(w, id)
+			continue
+		}
+		var subnets []string
+		for _, config := range n.IPAM.Config {
+			subnets = append(subnets, config.Subnet)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", id, n.Name, n.Driver, strings.Join(subnets, ","))
+	}
+	w.Flush()
+	return nil
+}
+
+func (cli *DockerCli) CmdNetworkInspect(args ...string) error {
+	cmd := cli.Subcmd("network inspect", "NETWORK [NETWORK...]", "Display detailed information on one or more networks", true)
+	verbose := cmd.Bool([]string{"v", "-verbose"}, false, "Show the driver details of the endpoints")
+	cmd.Require(flag.Min, 1)
+
+	utils.ParseFlags(cmd, args, true)
+
+	v := url.Values{}
+	if *verbose {
+		v.Set("verbose", "1")
+	}
+
+	indented := new(bytes.Buffer)
+	indented.WriteByte('[')
+	status := 0
+	for _, name := range cmd.Args() {
+		obj, _, err := readBody(cli.call("GET", "/networks/"+name+"?"+v.Encode(), nil, false))
+		if err != nil {
+			fmt.Fprintf(cli.err, "Error: %s\n", err)
+			status = 1
+			continue
+		}
+		if err := json.Indent(indented, bytes.TrimSpace(obj), "", "    "); err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			status = 1
+			continue
+		}
+		indented.WriteString(",")
+	}
+	if indented.Len() > 1 {
+		// Remove trailing ','
+		indented.Truncate(indented.Len() - 1)
+	}
+	indented.WriteString("]\n")
+	if _, err := io.Copy(cli.out, indented); err != nil {
+		return err
+	}
+	if status != 0 {
+		return &utils.StatusError{StatusCode: status}
+	}
+	return nil
+}
+
+func (cli *DockerCli) CmdNetworkRm(args ...string) error {
+	cmd := cli.Subcmd("network rm", "NETWORK [NETWORK...]", "Remove one or more networks", true)
+	cmd.Require(flag.Min, 1)
+
+	utils.ParseFlags(cmd, args, true)
+
+	var encounteredError error
+	for _, name := range cmd.Args() {
+		if _, _, err := readBody(cli.call("DELETE", "/networks/"+name, nil, false)); err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			encounteredError = fmt.Errorf("Error: failed to remove one or more networks")
+		} else {
+			fmt.Fprintf(cli.out, "%s\n", name)
+		}
+	}
+	return encounteredError
+}
+
+func (cli *DockerCli) CmdNetworkConnect(args ...string) error {
+	cmd := cli.Subcmd("network connect", "NETWORK CONTAINER", "Connect a container to a network", true)
+	var (
+		flAliases     = opts.NewListOpts(opts.ValidateNetworkAlias)
+		flIPAddress   = cmd.String([]string{"-ip"}, "", "IPv4 address of the container on the network")
+		flIPv6Address = cmd.String([]string{"-ip6"}, "", "IPv6 address of the container on the network")
+	)
+	cmd.Var(&flAliases, []string{"-alias"}, "Add network-scoped alias for the container")
+	cmd.Require(flag.Exact, 2)
+
+	utils.ParseFlags(cmd, args, true)
+
+	connect := &types.NetworkConnect{
+		Container:   cmd.Arg(1),
+		Aliases:     flAliases.GetAll(),
+		IPAddress:   *flIPAddress,
+		IPv6Address: *flIPv6Address,
+	}
+	_, _, err := readBody(cli.call("POST", "/networks/"+cmd.Arg(0)+"/connect", connect, false))
+	return err
+}
+
+func (cli *DockerCli) CmdNetworkDisconnect(args ...string) error {
+	cmd := cli.Subcmd("network disconnect", "NETWORK CONTAINER", "Disconnect a container from a network", true)
+	cmd.Require(flag.Exact, 2)
+
+	utils.ParseFlags(cmd, args, true)
+
+	disconnect := &types.NetworkDisconnect{
+		Container: cmd.Arg(1),
+	}
+	_, _, err := readBody(cli.call("POST", "/networks/"+cmd.Arg(0)+"/disconnect", disconnect, false))
+	return err
+}
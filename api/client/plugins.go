@@ -0,0 +1,110 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/docker/docker/pkg/homedir"
+	"github.com/docker/docker/utils"
+)
+
+// pluginPrefix is the prefix of the executables of the plugins, the plugin
+// docker-NAME is run by docker NAME
+const pluginPrefix = "docker-"
+
+var validPluginName = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// PluginsDir returns the directory of the plugins of the user, which are
+// looked up before the ones on the PATH
+func PluginsDir() string {
+	return filepath.Join(homedir.Get(), ".docker", "cli-plugins")
+}
+
+// lookupPlugin returns the path of the executable of the plugin, or an
+// empty string when there is no such plugin
+func lookupPlugin(name string) string {
+	if !validPluginName.MatchString(name) {
+		return ""
+	}
+	file := pluginPrefix + name
+	if runtime.GOOS == "windows" {
+		file += ".exe"
+	}
+	if path, err := exec.LookPath(filepath.Join(PluginsDir(), file)); err == nil {
+		return path
+	}
+	if path, err := exec.LookPath(file); err == nil {
+		return path
+	}
+	return ""
+}
+
+// ListPlugins returns the names of the plugins found in the plugins
+// directory and on the PATH
+func ListPlugins() []string {
+	dirs := []string{PluginsDir()}
+	dirs = append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+
+	found := make(map[string]bool)
+	for _, dir := range dirs {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, fi := range files {
+			name := strings.TrimSuffix(fi.Name(), ".exe")
+			if fi.IsDir() || !strings.HasPrefix(name, pluginPrefix) {
+				continue
+			}
+			if runtime.GOOS != "windows" && fi.Mode()&0111 == 0 {
+				continue
+			}
+			if name = strings.TrimPrefix(name, pluginPrefix); validPluginName.MatchString(name) {
+				found[name] = true
+			}
+		}
+	}
+
+	var names []string
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetPluginEnv sets the environment passing the connection of the client,
+// its host and TLS configuration, to the plugins
+func (cli *DockerCli) SetPluginEnv(env []string) {
+	cli.pluginEnv = env
+}
+
+// runPlugin runs the plugin with the args and the streams of the client, it
+// returns false when there is no such plugin
+func (cli *DockerCli) runPlugin(name string, args []string) (bool, error) {
+	path := lookupPlugin(name)
+	if path == "" {
+		return false, nil
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = cli.in
+	cmd.Stdout = cli.out
+	cmd.Stderr = cli.err
+	cmd.Env = append(os.Environ(), cli.pluginEnv...)
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+				return true, &utils.StatusError{StatusCode: status.ExitStatus()}
+			}
+		}
+		return true, err
+	}
+	return true, nil
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api"
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/common"
+	"github.com/docker/docker/pkg/units"
+	"github.com/docker/docker/utils"
+)
+
+// psContext is a container as listed by docker ps, its methods are the
+// fields of the templates of docker ps --format
+type psContext struct {
+	tableHeader
+	out   *engine.Env
+	trunc bool
+}
+
+func (c *psContext) ID() string {
+	c.addHeader("CONTAINER ID")
+	if c.trunc {
+		return common.TruncateID(c.out.Get("Id"))
+	}
+	return c.out.Get("Id")
+}
+
+func (c *psContext) Names() string {
+	c.addHeader("NAMES")
+	var names []string
+	for _, name := range c.out.GetList("Names") {
+		name = name[1:]
+		// only the default name of the container is shown, not the
+		// names of its links, unless the output isn't truncated
+		if c.trunc && len(strings.Split(name, "/")) == 1 {
+			return name
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ",")
+}
+
+func (c *psContext) Image() string {
+	c.addHeader("IMAGE")
+	if image := c.out.Get("Image"); image != "" {
+		return image
+	}
+	return "<no image>"
+}
+
+func (c *psContext) Command() string {
+	c.addHeader("COMMAND")
+	command := strconv.Quote(c.out.Get("Command"))
+	if c.trunc {
+		command = utils.Trunc(command, 20)
+	}
+	return command
+}
+
+func (c *psContext) CreatedAt() string {
+	c.addHeader("CREATED AT")
+	return time.Unix(c.out.GetInt64("Created"), 0).String()
+}
+
+func (c *psContext) RunningFor() string {
+	c.addHeader("CREATED")
+	return units.HumanDuration(time.Now().UTC().Sub(time.Unix(c.out.GetInt64("Created"), 0))) + " ago"
+}
+
+func (c *psContext) Ports() string {
+	c.addHeader("PORTS")
+	ports := engine.NewTable("", 0)
+	ports.ReadListFrom([]byte(c.out.Get("Ports")))
+	return api.DisplayablePorts(ports)
+}
+
+func (c *psContext) Status() string {
+	c.addHeader("STATUS")
+	return c.out.Get("Status")
+}
+
+// Size is only known with docker ps --size
+func (c *psContext) Size() string {
+	c.addHeader("SIZE")
+	size := units.HumanSize(float64(c.out.GetInt64("SizeRw")))
+	if c.out.GetInt("SizeRootFs") > 0 {
+		size += fmt.Sprintf(" (virtual %s)", units.HumanSize(float64(c.out.GetInt64("SizeRootFs"))))
+	}
+	return size
+}
+
+// formatPs writes the containers with the template format, the template
+// prefixed with "table " writes them as a table with a header
+func formatPs(w io.Writer, format string, outs []*engine.Env, trunc bool) error {
+	return formatTemplate(w, format, len(outs), func(i int) formatContext {
+		if i < 0 {
+			return &psContext{out: &engine.Env{}}
+		}
+		return &psContext{out: outs[i], trunc: trunc}
+	})
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/autogen/dockerversion"
+	"github.com/docker/docker/pkg/sshforward"
+)
+
+// sessionConn is a hijacked connection, the data read by the client
+// connection before the hijack is read first
+type sessionConn struct {
+	net.Conn
+	br *bufio.Reader
+}
+
+func (c *sessionConn) Read(p []byte) (int, error) {
+	return c.br.Read(p)
+}
+
+// openSession opens the session id with the daemon, over which the builds
+// naming the session forward the connections to the SSH agents of the
+// client, the agent sockets by id. The session ends when it is closed.
+func (cli *DockerCli) openSession(id string, agents map[string]string) (io.Closer, error) {
+	req, err := http.NewRequest("POST", fmt.Sprintf("/v%s/session?id=%s", cli.getAPIVersion(), url.QueryEscape(id)), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Docker-Client/"+dockerversion.VERSION)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+	req.Host = cli.addr
+
+	dial, err := cli.dial()
+	if err != nil {
+		if strings.Contains(err.Error(), "connection refused") {
+			return nil, fmt.Errorf("Cannot connect to the Docker daemon. Is 'docker -d' running on this host?")
+		}
+		return nil, err
+	}
+	clientconn := httputil.NewClientConn(dial, nil)
+	// Server hijacks the connection, error 'connection closed' expected
+	resp, err := clientconn.Do(req)
+	if resp == nil {
+		clientconn.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		clientconn.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("The daemon doesn't support forwarding SSH agents")
+		}
+		return nil, fmt.Errorf("Error opening a session: %s", strings.TrimSpace(string(body)))
+	}
+
+	rwc, br := clientconn.Hijack()
+	conn := &sessionConn{Conn: rwc, br: br}
+	go func() {
+		err := sshforward.Serve(conn, func(agent string) (net.Conn, error) {
+			socket, exists := agents[agent]
+			if !exists {
+				return nil, fmt.Errorf("SSH agent %s was not given with --ssh", agent)
+			}
+			return net.Dial("unix", socket)
+		})
+		if err != nil {
+			log.Debugf("Session %s ended: %v", id, err)
+		}
+	}()
+	return conn, nil
+}
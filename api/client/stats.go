@@ -0,0 +1,164 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/common"
+	"github.com/docker/docker/pkg/units"
+)
+
+// defaultStatsFormat is the template of the output of docker stats when
+// neither --format nor the configuration of the client sets one
+const defaultStatsFormat = "table {{.Container}}\t{{.CPUPerc}}\t{{.MemUsage}}\t{{.MemPerc}}\t{{.NetIO}}\t{{.BlockIO}}\t{{.PIDs}}"
+
+// statsContext is a sample of the stats of a container as displayed by
+// docker stats, its methods are the fields of the templates of docker stats
+// --format
+type statsContext struct {
+	tableHeader
+	// container is the container as given on the command line
+	container string
+	s         *types.StatsSample
+}
+
+func (c *statsContext) Container() string {
+	c.addHeader("CONTAINER")
+	return c.container
+}
+
+// Name and ID are not reported by the older daemons, Name falls back to
+// the container as given on the command line
+func (c *statsContext) Name() string {
+	c.addHeader("NAME")
+	if c.s.Name == "" {
+		return c.container
+	}
+	return c.s.Name
+}
+
+func (c *statsContext) ID() string {
+	c.addHeader("CONTAINER ID")
+	return common.TruncateID(c.s.ID)
+}
+
+func (c *statsContext) CPUPerc() string {
+	c.addHeader("CPU %")
+	return fmt.Sprintf("%.2f%%", c.s.CpuPercentage)
+}
+
+func (c *statsContext) MemUsage() string {
+	c.addHeader("MEM USAGE/LIMIT")
+	return fmt.Sprintf("%s/%s", units.BytesSize(float64(c.s.Memory)), units.BytesSize(float64(c.s.MemoryLimit)))
+}
+
+func (c *statsContext) MemPerc() string {
+	c.addHeader("MEM %")
+	return fmt.Sprintf("%.2f%%", c.s.MemoryPercentage)
+}
+
+func (c *statsContext) NetIO() string {
+	c.addHeader("NET I/O")
+	return fmt.Sprintf("%s/%s", units.BytesSize(float64(c.s.NetworkRx)), units.BytesSize(float64(c.s.NetworkTx)))
+}
+
+func (c *statsContext) BlockIO() string {
+	c.addHeader("BLOCK I/O")
+	return fmt.Sprintf("%s/%s", units.BytesSize(float64(c.s.BlockRead)), units.BytesSize(float64(c.s.BlockWrite)))
+}
+
+func (c *statsContext) PIDs() string {
+	c.addHeader("PIDS")
+	pids := strconv.FormatUint(c.s.PidsCurrent, 10)
+	if c.s.PidsLimit > 0 {
+		pids = fmt.Sprintf("%s/%d", pids, c.s.PidsLimit)
+	}
+	return pids
+}
+
+// formatStats writes the samples of the containers with the template
+// format, the template is parsed by formatPs's rules
+func formatStats(w io.Writer, format string, containers []string, samples []*types.StatsSample) error {
+	return formatTemplate(w, format, len(samples), func(i int) formatContext {
+		if i < 0 {
+			return &statsContext{s: &types.StatsSample{}}
+		}
+		return &statsContext{container: containers[i], s: samples[i]}
+	})
+}
+
+// sampleStats returns a single sample of the stats of each of the
+// containers, in the same order, without the containers the daemon left out
+// as they are not running. The daemon samples them together, the older
+// daemons are sampled container by container.
+func (cli *DockerCli) sampleStats(names []string) ([]*types.StatsSample, error) {
+	v := url.Values{"name": names}
+	body, statusCode, err := readBody(cli.call("GET", "/containers/stats?"+v.Encode(), nil, false))
+	if err == nil {
+		var samples []*types.StatsSample
+		if err := json.Unmarshal(body, &samples); err != nil {
+			return nil, err
+		}
+		return samples, nil
+	}
+	if statusCode != http.StatusNotFound {
+		return nil, err
+	}
+
+	var (
+		wg     sync.WaitGroup
+		cStats []*containerStats
+	)
+	for _, n := range names {
+		s := &containerStats{Name: n}
+		cStats = append(cStats, s)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Collect(cli, false)
+		}()
+	}
+	wg.Wait()
+	var (
+		errs    []string
+		samples []*types.StatsSample
+	)
+	for _, s := range cStats {
+		if s.err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", s.Name, s.err.Error()))
+			continue
+		}
+		samples = append(samples, &s.sample)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+	return samples, nil
+}
+
+// sampledNames returns the names the containers of the samples were given
+// by on the command line, the names of the containers which were left out
+// of the samples are skipped
+func sampledNames(names []string, samples []*types.StatsSample) []string {
+	if len(samples) == len(names) {
+		return names
+	}
+	sampled := make([]string, len(samples))
+	for i, s := range samples {
+		sampled[i] = s.Name
+		for _, name := range names {
+			if name == s.Name || strings.HasPrefix(s.ID, name) {
+				sampled[i] = name
+				break
+			}
+		}
+	}
+	return sampled
+}
@@ -0,0 +1,363 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/graph"
+	"github.com/docker/docker/pkg/digest"
+	"github.com/docker/docker/pkg/homedir"
+	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/pkg/parsers"
+	"github.com/docker/docker/registry"
+	"github.com/docker/docker/trust"
+	"github.com/docker/libtrust"
+)
+
+// With content trust, enabled by DOCKER_CONTENT_TRUST=1, the client pulls
+// and runs the tags of the repositories by the digests signed by their
+// publishers, and signs the digests of the tags it pushes. The signed
+// targets of the repositories are published on the trust server set by
+// DOCKER_CONTENT_TRUST_SERVER, there is no default one. The signing keys
+// are kept in ~/.docker/trust/private, and the key which signed the targets
+// of a repository the first time they were verified is trusted for the
+// repository from then on. This is not Notary: there are no root or
+// delegation keys.
+
+// pushedDigestRegexp matches the digests of the tags printed by a push
+var pushedDigestRegexp = regexp.MustCompile(`([\w][\w.-]*): digest: (sha256:[a-f0-9]{64})`)
+
+// isTrusted returns whether content trust is enabled by default
+func isTrusted() bool {
+	trusted, _ := strconv.ParseBool(os.Getenv("DOCKER_CONTENT_TRUST"))
+	return trusted
+}
+
+// addTrustedFlags adds the flag disabling content trust to cmd, it returns
+// its value.
+func addTrustedFlags(cmd *flag.FlagSet, verify bool) *bool {
+	usage := "Skip image signing"
+	if verify {
+		usage = "Skip image verification"
+	}
+	return cmd.Bool([]string{"-disable-content-trust"}, !isTrusted(), usage)
+}
+
+// trustServer returns the trust server set by DOCKER_CONTENT_TRUST_SERVER
+func trustServer() (string, error) {
+	server := os.Getenv("DOCKER_CONTENT_TRUST_SERVER")
+	if server == "" {
+		return "", errors.New("Content trust requires the URL of the trust server in DOCKER_CONTENT_TRUST_SERVER")
+	}
+	return server, nil
+}
+
+// trustFile returns the path of the file of repository in the directory dir
+// of the trust data of the client
+func trustFile(dir, repository string) string {
+	return filepath.Join(homedir.Get(), ".docker", "trust", dir, filepath.FromSlash(repository)+".json")
+}
+
+// loadTrustedKey returns the key trusted for repository, nil when none is
+func loadTrustedKey(repository string) (libtrust.PublicKey, error) {
+	key, err := libtrust.LoadPublicKeyFile(trustFile("trusted_keys", repository))
+	if err == libtrust.ErrKeyFileDoesNotExist {
+		return nil, nil
+	}
+	return key, err
+}
+
+func saveTrustedKey(repository string, key libtrust.PublicKey) error {
+	file := trustFile("trusted_keys", repository)
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	return libtrust.SavePublicKey(file, key)
+}
+
+// loadSigningKey returns the key the client signs the targets of
+// repository with, it is created the first time.
+func loadSigningKey(repository string) (libtrust.PrivateKey, error) {
+	file := trustFile("private", repository)
+	key, err := libtrust.LoadKeyFile(file)
+	if err == nil || err != libtrust.ErrKeyFileDoesNotExist {
+		return key, err
+	}
+	if key, err = libtrust.GenerateECP256PrivateKey(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return nil, err
+	}
+	if err := libtrust.SaveKey(file, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// verifyTargets verifies the signed targets of repository with the key
+// trusted for the repository, which is the key that signed them when none
+// is trusted yet. The verified targets are cached, so that older versions
+// are refused.
+func (cli *DockerCli) verifyTargets(repository string, signed []byte) (*trust.Targets, error) {
+	trustedKey, err := loadTrustedKey(repository)
+	if err != nil {
+		return nil, err
+	}
+	cacheFile := trustFile("tuf", repository)
+	minVersion := 0
+	if cached, err := ioutil.ReadFile(cacheFile); err == nil {
+		if targets, _, err := trust.VerifyTargets(cached, repository, trustedKey, 0); err == nil {
+			minVersion = targets.Version
+		}
+	}
+	targets, signer, err := trust.VerifyTargets(signed, repository, trustedKey, minVersion)
+	if err != nil {
+		return nil, err
+	}
+	if trustedKey == nil {
+		fmt.Fprintf(cli.err, "Trusting the key %s for %s on first use\n", signer.KeyID(), repository)
+		if err := saveTrustedKey(repository, signer); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(cacheFile), 0700); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(cacheFile, signed, 0600); err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// getTrustedTargets fetches the signed targets of the repository from the
+// trust server and verifies them
+func (cli *DockerCli) getTrustedTargets(repoInfo *registry.RepositoryInfo, authConfig registry.AuthConfig) (*trust.Targets, error) {
+	server, err := trustServer()
+	if err != nil {
+		return nil, err
+	}
+	repository := repoInfo.CanonicalName
+	signed, err := trust.FetchTargets(server, repository, authConfig.Username, authConfig.Password)
+	if err != nil {
+		return nil, err
+	}
+	return cli.verifyTargets(repository, signed)
+}
+
+// publishTrustedTargets signs the digests of the tags of the repository with
+// the key of the client, along with the other tags signed before, and
+// publishes them on the trust server.
+func (cli *DockerCli) publishTrustedTargets(repoInfo *registry.RepositoryInfo, authConfig registry.AuthConfig, tags map[string]digest.Digest) error {
+	server, err := trustServer()
+	if err != nil {
+		return err
+	}
+	repository := repoInfo.CanonicalName
+	key, err := loadSigningKey(repository)
+	if err != nil {
+		return err
+	}
+	targets, err := cli.getTrustedTargets(repoInfo, authConfig)
+	if _, ok := err.(trust.NoTargetsError); ok {
+		targets = trust.NewTargets(repository)
+	} else if err != nil {
+		return err
+	}
+	// only the publisher of the repository, who has the trusted key, can
+	// sign its tags
+	trustedKey, err := loadTrustedKey(repository)
+	if err != nil {
+		return err
+	}
+	if trustedKey == nil {
+		if err := saveTrustedKey(repository, key.PublicKey()); err != nil {
+			return err
+		}
+	} else if trustedKey.KeyID() != key.KeyID() {
+		return fmt.Errorf("The tags of %s are signed with the key %s, this client has the key %s", repository, trustedKey.KeyID(), key.KeyID())
+	}
+
+	for tag, dgst := range tags {
+		targets.Tags[tag] = dgst
+	}
+	signed, err := trust.SignTargets(targets, key)
+	if err != nil {
+		return err
+	}
+	if err := trust.PublishTargets(server, repository, authConfig.Username, authConfig.Password, signed); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(trustFile("tuf", repository), signed, 0600); err != nil {
+		return err
+	}
+	for _, tag := range sortedTags(tags) {
+		fmt.Fprintf(cli.out, "Signed %s with the key %s\n", parsers.JoinRepositoryTag(repoInfo.LocalName, tag), key.KeyID())
+	}
+	return nil
+}
+
+// trustedReference returns the reference by digest of the tag of the
+// repository repos signed by its publisher
+func (cli *DockerCli) trustedReference(repos, tag string) (string, error) {
+	repoInfo, err := registry.ParseRepositoryInfo(repos)
+	if err != nil {
+		return "", err
+	}
+	cli.LoadConfigFile()
+	targets, err := cli.getTrustedTargets(repoInfo, cli.resolveAuthConfig(repoInfo.Index))
+	if err != nil {
+		return "", err
+	}
+	dgst, exists := targets.Tags[tag]
+	if !exists {
+		return "", fmt.Errorf("No trust data for %s", parsers.JoinRepositoryTag(repos, tag))
+	}
+	return repos + "@" + dgst.String(), nil
+}
+
+// trustedPull pulls the tag of the repository repos by the digest signed by
+// its publisher, or all the signed tags when tag is empty, and tags the
+// pulled images.
+func (cli *DockerCli) trustedPull(repoInfo *registry.RepositoryInfo, repos, tag string, authConfig registry.AuthConfig, out io.Writer) error {
+	targets, err := cli.getTrustedTargets(repoInfo, authConfig)
+	if err != nil {
+		return err
+	}
+	tags := []string{tag}
+	if tag == "" {
+		tags = sortedTags(targets.Tags)
+	}
+	buf, err := json.Marshal(authConfig)
+	if err != nil {
+		return err
+	}
+	registryAuthHeader := []string{
+		base64.URLEncoding.EncodeToString(buf),
+	}
+	for _, tag := range tags {
+		dgst, exists := targets.Tags[tag]
+		if !exists {
+			return fmt.Errorf("No trust data for %s", parsers.JoinRepositoryTag(repos, tag))
+		}
+		fmt.Fprintf(out, "Pull %s@%s\n", repos, dgst)
+		v := url.Values{}
+		v.Set("fromImage", repos)
+		v.Set("tag", dgst.String())
+		if err := cli.stream("POST", "/images/create?"+v.Encode(), nil, out, map[string][]string{"X-Registry-Auth": registryAuthHeader}); err != nil {
+			return err
+		}
+		if err := cli.tagTrusted(repos, tag, dgst, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagTrusted tags the image pulled by the digest dgst of the tag
+func (cli *DockerCli) tagTrusted(repos, tag string, dgst digest.Digest, out io.Writer) error {
+	ref := repos + "@" + dgst.String()
+	fmt.Fprintf(out, "Tagging %s as %s\n", ref, parsers.JoinRepositoryTag(repos, tag))
+	v := url.Values{}
+	v.Set("repo", repos)
+	v.Set("tag", tag)
+	v.Set("force", "1")
+	_, _, err := readBody(cli.call("POST", "/images/"+ref+"/tag?"+v.Encode(), nil, false))
+	return err
+}
+
+// translateDockerfileFrom replaces the images of the FROM instructions of
+// the Dockerfile by the references by digest signed by their publishers.
+// The stages of the Dockerfile, the images given by digest or with a
+// variable, scratch and the names of exclude are kept.
+func (cli *DockerCli) translateDockerfileFrom(dockerfile []byte, exclude map[string]bool) ([]byte, error) {
+	var (
+		out    bytes.Buffer
+		stages = make(map[string]bool)
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(dockerfile))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) > 1 && strings.ToUpper(fields[0]) == "FROM" {
+			i := 1
+			for i < len(fields) && strings.HasPrefix(fields[i], "--") {
+				i++
+			}
+			if i < len(fields) {
+				image := fields[i]
+				if i+2 < len(fields) && strings.ToUpper(fields[i+1]) == "AS" {
+					stages[strings.ToLower(fields[i+2])] = true
+				}
+				repos, tag := parsers.ParseRepositoryTag(image)
+				if _, err := digest.ParseDigest(tag); err != nil && !stages[strings.ToLower(image)] && !exclude[image] && image != "scratch" && !strings.Contains(image, "$") {
+					if tag == "" {
+						tag = graph.DEFAULTTAG
+					}
+					ref, err := cli.trustedReference(repos, tag)
+					if err != nil {
+						return nil, err
+					}
+					fmt.Fprintf(cli.out, "Using %s for %s\n", ref, parsers.JoinRepositoryTag(repos, tag))
+					fields[i] = ref
+					line = strings.Join(fields, " ")
+				}
+			}
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// pushedDigests records the digests of the tags printed by a push while
+// writing the output to out
+type pushedDigests struct {
+	out  io.Writer
+	line []byte
+	tags map[string]digest.Digest
+}
+
+func newPushedDigests(out io.Writer) *pushedDigests {
+	return &pushedDigests{out: out, tags: make(map[string]digest.Digest)}
+}
+
+func (p *pushedDigests) Write(b []byte) (int, error) {
+	p.line = append(p.line, b...)
+	for {
+		i := bytes.IndexByte(p.line, '\n')
+		if i < 0 {
+			break
+		}
+		if m := pushedDigestRegexp.FindSubmatch(p.line[:i]); m != nil {
+			p.tags[string(m[1])] = digest.Digest(m[2])
+		}
+		p.line = p.line[i+1:]
+	}
+	return p.out.Write(b)
+}
+
+func sortedTags(tags map[string]digest.Digest) []string {
+	sorted := make([]string, 0, len(tags))
+	for tag := range tags {
+		sorted = append(sorted, tag)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
@@ -14,16 +14,22 @@ import (
 	gosignal "os/signal"
 	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/api"
 	"github.com/docker/docker/autogen/dockerversion"
 	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/archive"
+	flag "github.com/docker/docker/pkg/mflag"
 	"github.com/docker/docker/pkg/signal"
 	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/docker/pkg/term"
+	"github.com/docker/docker/pkg/timeutils"
+	"github.com/docker/docker/pkg/version"
 	"github.com/docker/docker/registry"
 	"github.com/docker/docker/utils"
+	"github.com/docker/docker/vendor/src/code.google.com/p/go/src/pkg/archive/tar"
 )
 
 var (
@@ -34,6 +40,56 @@ func (cli *DockerCli) HTTPClient() *http.Client {
 	return &http.Client{Transport: cli.transport}
 }
 
+// getAPIVersion returns the API version used to talk to the daemon. It is
+// the client's own version unless DOCKER_API_VERSION is set, or the daemon
+// reports an older version, in which case the client negotiates down to it.
+func (cli *DockerCli) getAPIVersion() version.Version {
+	cli.apiVersionOnce.Do(func() {
+		cli.apiVersion = api.APIVERSION
+		if v := os.Getenv("DOCKER_API_VERSION"); v != "" {
+			cli.apiVersion = version.Version(v)
+			return
+		}
+		serverVersion, err := cli.getServerAPIVersion()
+		if err != nil {
+			log.Debugf("Error negotiating the API version: %s", err)
+			return
+		}
+		if serverVersion != "" && serverVersion.LessThan(api.APIVERSION) {
+			log.Debugf("Daemon API version %s is older than the client's, using it", serverVersion)
+			cli.apiVersion = serverVersion
+		}
+	})
+	return cli.apiVersion
+}
+
+// getServerAPIVersion queries the unversioned /version endpoint, which every
+// daemon serves using its own API version.
+func (cli *DockerCli) getServerAPIVersion() (version.Version, error) {
+	req, err := http.NewRequest("GET", "/version", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "Docker-Client/"+dockerversion.VERSION)
+	req.URL.Host = cli.addr
+	req.URL.Scheme = cli.scheme
+	resp, err := cli.HTTPClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	var v struct {
+		ApiVersion version.Version
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return "", err
+	}
+	return v.ApiVersion, nil
+}
+
 func (cli *DockerCli) encodeData(data interface{}) (*bytes.Buffer, error) {
 	params := bytes.NewBuffer(nil)
 	if data != nil {
@@ -59,14 +115,14 @@ func (cli *DockerCli) call(method, path string, data interface{}, passAuthInfo b
 	if err != nil {
 		return nil, -1, err
 	}
-	req, err := http.NewRequest(method, fmt.Sprintf("/v%s%s", api.APIVERSION, path), params)
+	req, err := http.NewRequest(method, fmt.Sprintf("/v%s%s", cli.getAPIVersion(), path), params)
 	if err != nil {
 		return nil, -1, err
 	}
 	if passAuthInfo {
 		cli.LoadConfigFile()
 		// Resolve the Auth config relevant for this server
-		authConfig := cli.configFile.Configs[registry.IndexServerAddress()]
+		authConfig := cli.authConfig(registry.IndexServerAddress())
 		getHeaders := func(authConfig registry.AuthConfig) (map[string][]string, error) {
 			buf, err := json.Marshal(authConfig)
 			if err != nil {
@@ -123,13 +179,65 @@ func (cli *DockerCli) stream(method, path string, in io.Reader, out io.Writer, h
 }
 
 func (cli *DockerCli) streamHelper(method, path string, setRawTerminal bool, in io.Reader, stdout, stderr io.Writer, headers map[string][]string) error {
+	resp, err := cli.streamRequest(method, path, in, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if api.MatchesContentType(resp.Header.Get("Content-Type"), "application/json") {
+		return utils.DisplayJSONMessagesStream(resp.Body, stdout, cli.outFd, cli.isTerminalOut)
+	}
+	if stdout != nil || stderr != nil {
+		// When TTY is ON, use regular copy
+		if setRawTerminal {
+			_, err = io.Copy(stdout, resp.Body)
+		} else {
+			_, err = stdcopy.StdCopy(stdout, stderr, resp.Body)
+		}
+		log.Debugf("[stream] End of stdout")
+		return err
+	}
+	return nil
+}
+
+// streamJSON writes the JSON messages streamed by the daemon to out as they
+// are, one per line, instead of displaying them. It returns the error of the
+// stream, if any.
+func (cli *DockerCli) streamJSON(method, path string, in io.Reader, out io.Writer, headers map[string][]string) error {
+	resp, err := cli.streamRequest(method, path, in, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	enc := json.NewEncoder(out)
+	for {
+		var jm utils.JSONMessage
+		if err := dec.Decode(&jm); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := enc.Encode(&jm); err != nil {
+			return err
+		}
+		if jm.Error != nil {
+			return jm.Error
+		}
+	}
+}
+
+func (cli *DockerCli) streamRequest(method, path string, in io.Reader, headers map[string][]string) (*http.Response, error) {
 	if (method == "POST" || method == "PUT") && in == nil {
 		in = bytes.NewReader([]byte{})
 	}
 
-	req, err := http.NewRequest(method, fmt.Sprintf("/v%s%s", api.APIVERSION, path), in)
+	req, err := http.NewRequest(method, fmt.Sprintf("/v%s%s", cli.getAPIVersion(), path), in)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req.Header.Set("User-Agent", "Docker-Client/"+dockerversion.VERSION)
 	req.URL.Host = cli.addr
@@ -146,37 +254,23 @@ func (cli *DockerCli) streamHelper(method, path string, setRawTerminal bool, in
 	resp, err := cli.HTTPClient().Do(req)
 	if err != nil {
 		if strings.Contains(err.Error(), "connection refused") {
-			return fmt.Errorf("Cannot connect to the Docker daemon. Is 'docker -d' running on this host?")
+			return nil, fmt.Errorf("Cannot connect to the Docker daemon. Is 'docker -d' running on this host?")
 		}
-		return err
+		return nil, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		defer resp.Body.Close()
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if len(body) == 0 {
-			return fmt.Errorf("Error :%s", http.StatusText(resp.StatusCode))
+			return nil, fmt.Errorf("Error :%s", http.StatusText(resp.StatusCode))
 		}
-		return fmt.Errorf("Error: %s", bytes.TrimSpace(body))
+		return nil, fmt.Errorf("Error: %s", bytes.TrimSpace(body))
 	}
-
-	if api.MatchesContentType(resp.Header.Get("Content-Type"), "application/json") {
-		return utils.DisplayJSONMessagesStream(resp.Body, stdout, cli.outFd, cli.isTerminalOut)
-	}
-	if stdout != nil || stderr != nil {
-		// When TTY is ON, use regular copy
-		if setRawTerminal {
-			_, err = io.Copy(stdout, resp.Body)
-		} else {
-			_, err = stdcopy.StdCopy(stdout, stderr, resp.Body)
-		}
-		log.Debugf("[stream] End of stdout")
-		return err
-	}
-	return nil
+	return resp, nil
 }
 
 func (cli *DockerCli) resizeTty(id string, isExec bool) {
@@ -200,8 +294,34 @@ func (cli *DockerCli) resizeTty(id string, isExec bool) {
 	}
 }
 
+// detachKeys returns the key sequence to detach from a container given with
+// --detach-keys, or else with the detachKeys of the configuration file of the
+// client. It returns false when none is given and the daemon default is used.
+func (cli *DockerCli) detachKeys(cmd *flag.FlagSet, flDetachKeys string) (string, bool, error) {
+	keys := flDetachKeys
+	if !cmd.IsSet("-detach-keys") {
+		if keys = cli.loadConfig().DetachKeys; keys == "" {
+			return "", false, nil
+		}
+	}
+	if _, err := term.ToBytes(keys); err != nil {
+		return "", false, fmt.Errorf("Invalid detach keys (%s): %s", keys, err)
+	}
+	return keys, true, nil
+}
+
 func waitForExit(cli *DockerCli, containerId string) (int, error) {
-	stream, _, err := cli.call("POST", "/containers/"+containerId+"/wait", nil, false)
+	return waitForCondition(cli, containerId, nil)
+}
+
+// waitForCondition blocks until the container reaches the condition passed
+// in the "condition" query parameter, then returns its exit code.
+func waitForCondition(cli *DockerCli, containerId string, v url.Values) (int, error) {
+	path := "/containers/" + containerId + "/wait"
+	if len(v) > 0 {
+		path += "?" + v.Encode()
+	}
+	stream, _, err := cli.call("POST", path, nil, false)
 	if err != nil {
 		return -1, err
 	}
@@ -281,6 +401,20 @@ func (cli *DockerCli) getTtySize() (int, int) {
 	return int(ws.Height), int(ws.Width)
 }
 
+// localTimestamp returns the Unix timestamp of a date in the local time
+// zone, as a prefix of the RFC 3339 format, other values are returned
+// unchanged for the daemon to parse
+func localTimestamp(value string) string {
+	format := timeutils.RFC3339NanoFixed
+	if len(value) < len(format) {
+		format = format[:len(value)]
+	}
+	if t, err := time.ParseInLocation(format, value, time.FixedZone(time.Now().Zone())); err == nil {
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+	return value
+}
+
 func readBody(stream io.ReadCloser, statusCode int, err error) ([]byte, int, error) {
 	if stream != nil {
 		defer stream.Close()
@@ -294,3 +428,36 @@ func readBody(stream io.ReadCloser, statusCode int, err error) ([]byte, int, err
 	}
 	return body, statusCode, nil
 }
+
+// savedImageIDs returns the IDs of the images in an archive made by docker
+// save, the directories with the json of an image. The archive is a local
+// file, docker save --exclude-from doesn't look images up in a registry.
+func savedImageIDs(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := archive.DecompressStream(f)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var ids []string
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the archive %s: %v", file, err)
+		}
+		parts := strings.Split(strings.TrimPrefix(hdr.Name, "./"), "/")
+		if len(parts) == 2 && parts[1] == "json" {
+			ids = append(ids, parts[0])
+		}
+	}
+	return ids, nil
+}
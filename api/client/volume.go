@@ -0,0 +1,248 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/opts"
+	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/pkg/parsers/filters"
+	"github.com/docker/docker/pkg/units"
+	"github.com/docker/docker/utils"
+)
+
+var volumeCommands = [][]string{
+	{"create", "Create a volume"},
+	{"inspect", "Display detailed information on one or more volumes"},
+	{"ls", "List volumes"},
+	{"prune", "Remove all unused volumes"},
+	{"rm", "Remove one or more volumes"},
+}
+
+// 'docker volume' without a valid subcommand prints the volume subcommands
+func (cli *DockerCli) CmdVolume(args ...string) error {
+	cmd := cli.Subcmd("volume", "COMMAND", volumeUsage(), true)
+	cmd.Require(flag.Min, 1)
+
+	utils.ParseFlags(cmd, args, true)
+
+	fmt.Fprintf(cli.err, "docker: '%s' is not a docker volume command. See 'docker volume --help'.\n", cmd.Arg(0))
+	return &utils.StatusError{StatusCode: 1}
+}
+
+func volumeUsage() string {
+	help := "Manage Docker volumes\n\nCommands:\n"
+	for _, command := range volumeCommands {
+		help += fmt.Sprintf("  %-12.12s%s\n", command[0], command[1])
+	}
+	help += "\nRun 'docker volume COMMAND --help' for more information on a command."
+	return help
+}
+
+func (cli *DockerCli) CmdVolumeCreate(args ...string) error {
+	cmd := cli.Subcmd("volume create", "[VOLUME]", "Create a volume, a random name is given to the volume when VOLUME is not set", true)
+	var (
+		flDriver = cmd.String([]string{"d", "-driver"}, "local", "Driver to manage the volume")
+		flOpts   = opts.NewListOpts(nil)
+		flLabels = opts.NewListOpts(opts.ValidateLabel)
+	)
+	cmd.Var(&flOpts, []string{"o", "-opt"}, "Set driver specific options")
+	cmd.Var(&flLabels, []string{"l", "-label"}, "Set metadata on the volume")
+	cmd.Require(flag.Max, 1)
+
+	utils.ParseFlags(cmd, args, true)
+
+	options, err := parseNetworkOpts(&flOpts)
+	if err != nil {
+		return err
+	}
+	create := &types.VolumeCreate{
+		Name:   cmd.Arg(0),
+		Driver: *flDriver,
+	}
+	if len(options) > 0 {
+		create.DriverOpts = options
+	}
+	// the labels are key=value pairs like the driver options
+	labels, err := parseNetworkOpts(&flLabels)
+	if err != nil {
+		return err
+	}
+	if len(labels) > 0 {
+		create.Labels = labels
+	}
+
+	body, _, err := readBody(cli.call("POST", "/volumes/create", create, false))
+	if err != nil {
+		return err
+	}
+	var volume types.Volume
+	if err := json.Unmarshal(body, &volume); err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.out, "%s\n", volume.Name)
+	return nil
+}
+
+func (cli *DockerCli) CmdVolumeLs(args ...string) error {
+	cmd := cli.Subcmd("volume ls", "", "List volumes", true)
+	quiet := cmd.Bool([]string{"q", "-quiet"}, false, "Only display volume names")
+	flFilter := opts.NewListOpts(nil)
+	cmd.Var(&flFilter, []string{"f", "-filter"}, "Filter output based on conditions provided")
+	cmd.Require(flag.Exact, 0)
+
+	utils.ParseFlags(cmd, args, true)
+
+	query, err := filterQuery(&flFilter)
+	if err != nil {
+		return err
+	}
+	body, _, err := readBody(cli.call("GET", "/volumes"+query, nil, false))
+	if err != nil {
+		return err
+	}
+	var list []types.Volume
+	if err := json.Unmarshal(body, &list); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
+	if !*quiet {
+		fmt.Fprintln(w, "DRIVER\tVOLUME NAME")
+	}
+	for _, v := range list {
+		if *quiet {
+			fmt.Fprintln(w, v.Name)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\n", v.Driver, v.Name)
+	}
+	w.Flush()
+	return nil
+}
+
+func (cli *DockerCli) CmdVolumeInspect(args ...string) error {
+	cmd := cli.Subcmd("volume inspect", "VOLUME [VOLUME...]", "Display detailed information on one or more volumes", true)
+	cmd.Require(flag.Min, 1)
+
+	utils.ParseFlags(cmd, args, true)
+
+	indented := new(bytes.Buffer)
+	indented.WriteByte('[')
+	status := 0
+	for _, name := range cmd.Args() {
+		obj, _, err := readBody(cli.call("GET", "/volumes/"+name, nil, false))
+		if err != nil {
+			fmt.Fprintf(cli.err, "Error: %s\n", err)
+			status = 1
+			continue
+		}
+		if err := json.Indent(indented, bytes.TrimSpace(obj), "", "    "); err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			status = 1
+			continue
+		}
+		indented.WriteString(",")
+	}
+	if indented.Len() > 1 {
+		// Remove trailing ','
+		indented.Truncate(indented.Len() - 1)
+	}
+	indented.WriteString("]\n")
+	if _, err := io.Copy(cli.out, indented); err != nil {
+		return err
+	}
+	if status != 0 {
+		return &utils.StatusError{StatusCode: status}
+	}
+	return nil
+}
+
+func (cli *DockerCli) CmdVolumeRm(args ...string) error {
+	cmd := cli.Subcmd("volume rm", "VOLUME [VOLUME...]", "Remove one or more volumes", true)
+	cmd.Require(flag.Min, 1)
+
+	utils.ParseFlags(cmd, args, true)
+
+	var encounteredError error
+	for _, name := range cmd.Args() {
+		if _, _, err := readBody(cli.call("DELETE", "/volumes/"+name, nil, false)); err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			encounteredError = fmt.Errorf("Error: failed to remove one or more volumes")
+		} else {
+			fmt.Fprintf(cli.out, "%s\n", name)
+		}
+	}
+	return encounteredError
+}
+
+func (cli *DockerCli) CmdVolumePrune(args ...string) error {
+	cmd := cli.Subcmd("volume prune", "", "Remove all unused volumes", true)
+	force := cmd.Bool([]string{"f", "-force"}, false, "Do not prompt for confirmation")
+	flFilter := opts.NewListOpts(nil)
+	cmd.Var(&flFilter, []string{"-filter"}, "Only remove the volumes matching the label filters provided")
+	cmd.Require(flag.Exact, 0)
+
+	utils.ParseFlags(cmd, args, true)
+
+	query, err := filterQuery(&flFilter)
+	if err != nil {
+		return err
+	}
+	if !*force {
+		fmt.Fprint(cli.out, "WARNING! This will remove all volumes not used by at least one container.\nAre you sure you want to continue? [y/N] ")
+		answer, _ := bufio.NewReader(cli.in).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			return nil
+		}
+	}
+
+	body, _, err := readBody(cli.call("POST", "/volumes/prune"+query, nil, false))
+	if err != nil {
+		return err
+	}
+	var report types.VolumesPruneReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return err
+	}
+	if len(report.VolumesDeleted) > 0 {
+		fmt.Fprintln(cli.out, "Deleted Volumes:")
+		for _, name := range report.VolumesDeleted {
+			fmt.Fprintln(cli.out, name)
+		}
+		fmt.Fprintln(cli.out)
+	}
+	fmt.Fprintf(cli.out, "Total reclaimed space: %s\n", units.HumanSize(float64(report.SpaceReclaimed)))
+	return nil
+}
+
+// filterQuery returns the query string of the filters of the -f flag of the
+// volume and network commands, the filters are checked by the daemon
+func filterQuery(flFilter *opts.ListOpts) (string, error) {
+	var (
+		filterArgs = filters.Args{}
+		err        error
+	)
+	for _, f := range flFilter.GetAll() {
+		if filterArgs, err = filters.ParseFlag(f, filterArgs); err != nil {
+			return "", err
+		}
+	}
+	if len(filterArgs) == 0 {
+		return "", nil
+	}
+	filterJson, err := filters.ToParam(filterArgs)
+	if err != nil {
+		return "", err
+	}
+	v := url.Values{}
+	v.Set("filters", filterJson)
+	return "?" + v.Encode(), nil
+}
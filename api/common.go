@@ -5,6 +5,7 @@ import (
 	"mime"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	log "github.com/Sirupsen/logrus"
@@ -16,11 +17,31 @@ import (
 
 const (
 	APIVERSION            version.Version = "1.18"
+	APIMINVERSION         version.Version = "1.0"
 	DEFAULTHTTPHOST                       = "127.0.0.1"
 	DEFAULTUNIXSOCKET                     = "/var/run/docker.sock"
 	DefaultDockerfileName string          = "Dockerfile"
+
+	// the directories of the named build contexts given with
+	// --build-context are sent in this directory of the build context, and
+	// their source is LocalBuildContext
+	BuildContextsDir  = ".dockerbuildcontexts"
+	LocalBuildContext = "local"
+	// prefix of the named build contexts referring to an image
+	ImageBuildContextPrefix = "docker-image://"
 )
 
+var validBuildContextName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.:-]*$`)
+
+// ValidateBuildContextName checks the name of a --build-context, which is
+// also the name of a directory
+func ValidateBuildContextName(name string) error {
+	if !validBuildContextName.MatchString(name) {
+		return fmt.Errorf("Invalid build context name %q, only [a-zA-Z0-9_.:-] are allowed", name)
+	}
+	return nil
+}
+
 func ValidateHost(val string) (string, error) {
 	host, err := parsers.ParseHost(DEFAULTHTTPHOST, DEFAULTUNIXSOCKET, val)
 	if err != nil {
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/utils"
+)
+
+// maxAuditError is the length of the beginning of the error responses kept
+// in the audit log
+const maxAuditError = 512
+
+// auditLog writes the audit log of the requests changing the state of the
+// daemon, it is nil when the daemon keeps no audit log
+var auditLog *auditLogger
+
+// auditLogger writes the entries of the audit log, one JSON object per line
+type auditLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// openAuditLog opens the audit log at path for appending, creating it when
+// it does not exist
+func openAuditLog(path string) (*auditLogger, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening the audit log: %v", err)
+	}
+	return &auditLogger{w: f}, nil
+}
+
+// auditEntry is the entry of the audit log of a request. The bodies of the
+// requests are not logged, they may hold secrets such as environment
+// variables.
+type auditEntry struct {
+	Time       time.Time         `json:"time"`
+	Method     string            `json:"method"`
+	Endpoint   string            `json:"endpoint"`
+	Route      string            `json:"route"`
+	Vars       map[string]string `json:"vars,omitempty"`
+	Query      string            `json:"query,omitempty"`
+	User       string            `json:"user,omitempty"`
+	AuthMethod string            `json:"authMethod,omitempty"`
+	RemoteAddr string            `json:"remoteAddr,omitempty"`
+	Status     int               `json:"status"`
+	Error      string            `json:"error,omitempty"`
+	Duration   float64           `json:"duration"` // in seconds
+}
+
+// audited returns whether the requests of the method are logged, the ones
+// changing the state of the daemon
+func audited(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS":
+		return false
+	}
+	return true
+}
+
+// log appends the entry of the request, served by the route with the
+// response recorded by w, to the audit log
+func (l *auditLogger) log(r *http.Request, route string, vars map[string]string, w *auditResponseWriter, start time.Time) {
+	user, authMethod := authorizationUser(r)
+	entry := auditEntry{
+		Time:       start.UTC(),
+		Method:     r.Method,
+		Endpoint:   r.URL.Path,
+		Route:      route,
+		Vars:       vars,
+		Query:      redactQuery(r.URL.RawQuery),
+		User:       user,
+		AuthMethod: authMethod,
+		RemoteAddr: r.RemoteAddr,
+		Status:     w.StatusCode(),
+		Duration:   time.Since(start).Seconds(),
+	}
+	if entry.Status >= http.StatusBadRequest {
+		entry.Error = strings.TrimSpace(string(w.body))
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		log.Errorf("Error encoding the audit log entry of %s %s: %v", r.Method, r.URL.Path, err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(append(b, '\n')); err != nil {
+		log.Errorf("Error writing the audit log entry of %s %s: %v", r.Method, r.URL.Path, err)
+	}
+}
+
+// redactURL hides the credentials of a URL
+func redactURL(rawURL string) string {
+	rawURL = utils.RedactGitURL(rawURL)
+	// a token may be the user of the URL, without a password
+	if u, err := url.Parse(rawURL); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); !ok {
+			u.User = url.User("xxxxx")
+			rawURL = u.String()
+		}
+	}
+	return rawURL
+}
+
+// redactQuery hides the secrets a query may hold from the audit log, the
+// values of the build arguments and the credentials of the remote URL and of
+// the URLs of the named contexts of a build
+func redactQuery(rawQuery string) string {
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return ""
+	}
+	redacted := false
+	if buildArgs := query.Get("buildargs"); buildArgs != "" {
+		args := map[string]string{}
+		if err := json.Unmarshal([]byte(buildArgs), &args); err != nil {
+			query.Set("buildargs", "xxxxx")
+		} else {
+			for name := range args {
+				args[name] = "xxxxx"
+			}
+			b, _ := json.Marshal(args)
+			query.Set("buildargs", string(b))
+		}
+		redacted = true
+	}
+	if remote := query.Get("remote"); remote != "" {
+		query.Set("remote", redactURL(remote))
+		redacted = true
+	}
+	if buildContexts := query.Get("buildcontexts"); buildContexts != "" {
+		contexts := map[string]string{}
+		if err := json.Unmarshal([]byte(buildContexts), &contexts); err != nil {
+			query.Set("buildcontexts", "xxxxx")
+		} else {
+			for name, source := range contexts {
+				contexts[name] = redactURL(source)
+			}
+			b, _ := json.Marshal(contexts)
+			query.Set("buildcontexts", string(b))
+		}
+		redacted = true
+	}
+	if !redacted {
+		return rawQuery
+	}
+	return query.Encode()
+}
+
+// auditResponseWriter records the status code of a response and the
+// beginning of its body
+type auditResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       []byte
+}
+
+func (w *auditResponseWriter) WriteHeader(code int) {
+	if w.statusCode == 0 {
+		w.statusCode = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	if n := maxAuditError - len(w.body); n > 0 {
+		if n > len(b) {
+			n = len(b)
+		}
+		w.body = append(w.body, b[:n]...)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the response when the wrapped writer can
+func (w *auditResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hijacks the connection of the wrapped writer
+func (w *auditResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("Internal response writer doesn't support the Hijacker interface")
+	}
+	return h.Hijack()
+}
+
+// StatusCode returns the status code of the response
+func (w *auditResponseWriter) StatusCode() int {
+	if w.statusCode == 0 {
+		return http.StatusOK
+	}
+	return w.statusCode
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/version"
+)
+
+func TestAuditLogStateChangingRequests(t *testing.T) {
+	var buf bytes.Buffer
+	auditLog = &auditLogger{w: &buf}
+	defer func() { auditLog = nil }()
+
+	handler := func(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		if r.URL.Query().Get("fail") != "" {
+			return errors.New("no such container: web")
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	post := makeHttpHandler(nil, false, "POST", "/containers/{name:.*}/start", handler, "", "", nil)
+	get := makeHttpHandler(nil, false, "GET", "/containers/json", handler, "", "", nil)
+
+	for _, url := range []string{"/containers/web/start", "/containers/web/start?fail=1"} {
+		req, err := http.NewRequest("POST", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = "10.0.0.1:4242"
+		post(httptest.NewRecorder(), req)
+	}
+	req, err := http.NewRequest("GET", "/containers/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(httptest.NewRecorder(), req)
+
+	var entries []auditEntry
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var entry auditEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected the 2 POST requests in the audit log, got %d", len(entries))
+	}
+	if e := entries[0]; e.Method != "POST" || e.Endpoint != "/containers/web/start" || e.Route != "/containers/{name:.*}/start" || e.RemoteAddr != "10.0.0.1:4242" || e.Status != http.StatusNoContent || e.Error != "" {
+		t.Fatalf("Unexpected audit log entry %+v", e)
+	}
+	if e := entries[1]; e.Query != "fail=1" || e.Status != http.StatusNotFound || e.Error != "no such container: web" {
+		t.Fatalf("Unexpected audit log entry of the failed request %+v", e)
+	}
+}
+
+func TestAuditLogRedactsBuildSecrets(t *testing.T) {
+	for query, expected := range map[string]string{
+		"t=10": "t=10",
+		"remote=https%3A%2F%2Fgithub.com%2Fdocker%2Fdocker.git":     "remote=https%3A%2F%2Fgithub.com%2Fdocker%2Fdocker.git",
+		"remote=https%3A%2F%2Fbob%3Asecret%40host%2Frepo.git&t=web": "remote=https%3A%2F%2Fbob%3Axxxxx%40host%2Frepo.git&t=web",
+		"remote=https%3A%2F%2Ftoken%40host%2Frepo.git":              "remote=https%3A%2F%2Fxxxxx%40host%2Frepo.git",
+		"buildargs=%7B%22PASSWORD%22%3A%22secret%22%7D&t=web":       "buildargs=%7B%22PASSWORD%22%3A%22xxxxx%22%7D&t=web",
+		"buildargs=secret": "buildargs=xxxxx",
+		"buildcontexts=%7B%22base%22%3A%22docker-image%3A%2F%2Fdebian%22%2C%22src%22%3A%22https%3A%2F%2Fbob%3Asecret%40host%2Fsrc.tar%22%7D": "buildcontexts=%7B%22base%22%3A%22docker-image%3A%2F%2Fdebian%22%2C%22src%22%3A%22https%3A%2F%2Fbob%3Axxxxx%40host%2Fsrc.tar%22%7D",
+		"buildcontexts=%7B%22docs%22%3A%22https%3A%2F%2Ftoken%40host%2Fdocs.git%22%7D":                                                       "buildcontexts=%7B%22docs%22%3A%22https%3A%2F%2Fxxxxx%40host%2Fdocs.git%22%7D",
+		"buildcontexts=secret": "buildcontexts=xxxxx",
+	} {
+		if redacted := redactQuery(query); redacted != expected {
+			t.Fatalf("Expected %q to be redacted to %q, got %q", query, expected, redacted)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net"
+	"sync"
+)
+
+// apiDrain stops the API from serving requests once the daemon shuts down
+var apiDrain = &drainer{}
+
+// drainer tracks the listeners of the API, they are closed when the daemon
+// starts shutting down
+type drainer struct {
+	mu        sync.Mutex
+	draining  bool
+	listeners []net.Listener
+}
+
+// track adds the listener to the listeners closed by drain, it is closed at
+// once when the daemon is already draining
+func (d *drainer) track(l net.Listener) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		l.Close()
+		return
+	}
+	d.listeners = append(d.listeners, l)
+}
+
+// drain closes the listeners, the requests of the connections kept alive
+// are then refused
+func (d *drainer) drain() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.draining = true
+	for _, l := range d.listeners {
+		l.Close()
+	}
+	d.listeners = nil
+}
+
+// isDraining returns whether the daemon is shutting down
+func (d *drainer) isDraining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.draining
+}
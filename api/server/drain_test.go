@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/version"
+)
+
+func TestDrainClosesListeners(t *testing.T) {
+	d := &drainer{}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.track(l)
+	if d.isDraining() {
+		t.Fatal("Expected the API not to drain before the shutdown")
+	}
+	d.drain()
+	if !d.isDraining() {
+		t.Fatal("Expected the API to drain after the shutdown")
+	}
+	if _, err := l.Accept(); err == nil {
+		t.Fatal("Expected the listener to be closed")
+	}
+
+	// the listeners of the servers started late are closed at once
+	l, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.track(l)
+	if _, err := l.Accept(); err == nil {
+		t.Fatal("Expected the listener tracked while draining to be closed")
+	}
+}
+
+func TestDrainRefusesRequests(t *testing.T) {
+	apiDrain = &drainer{}
+	defer func() { apiDrain = &drainer{} }()
+
+	var called int
+	handler := func(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		called++
+		return nil
+	}
+	h := makeHttpHandler(nil, false, "POST", "/containers/create", handler, "", "", nil)
+
+	req, err := http.NewRequest("POST", "/containers/create", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h(httptest.NewRecorder(), req)
+	if called != 1 {
+		t.Fatalf("Expected the request to be served before the shutdown")
+	}
+
+	apiDrain.drain()
+	r := httptest.NewRecorder()
+	h(r, req)
+	if called != 1 || r.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected the request to be refused while draining, got %d", r.Code)
+	}
+}
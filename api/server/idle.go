@@ -0,0 +1,111 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/parsers/filters"
+)
+
+// idleConns tracks the connections of the socket activated listeners when
+// the daemon exits after its idle timeout
+var idleConns *idleTracker
+
+// idleTracker tracks the connections serving requests and when the last one
+// was served
+type idleTracker struct {
+	timeout time.Duration
+
+	mu         sync.Mutex
+	active     map[net.Conn]struct{}
+	lastActive time.Time
+}
+
+func newIdleTracker(timeout time.Duration) *idleTracker {
+	return &idleTracker{
+		timeout:    timeout,
+		active:     make(map[net.Conn]struct{}),
+		lastActive: time.Now(),
+	}
+}
+
+// connState is the ConnState hook of the http.Server of the listeners. The
+// hijacked connections are not tracked, they attach to running containers.
+func (t *idleTracker) connState(c net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch state {
+	case http.StateNew, http.StateActive:
+		t.active[c] = struct{}{}
+	default:
+		if _, ok := t.active[c]; ok {
+			delete(t.active, c)
+			t.lastActive = time.Now()
+		}
+	}
+}
+
+// idleFor returns how long no request has been served
+func (t *idleTracker) idleFor() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.active) > 0 {
+		return 0
+	}
+	return time.Since(t.lastActive)
+}
+
+func (t *idleTracker) touch() {
+	t.mu.Lock()
+	t.lastActive = time.Now()
+	t.mu.Unlock()
+}
+
+// waitIdle returns once no request has been served for the timeout, while
+// busy returns false
+func (t *idleTracker) waitIdle(busy func() bool) {
+	for {
+		if idle := t.idleFor(); idle < t.timeout {
+			time.Sleep(t.timeout - idle)
+			continue
+		}
+		if busy() {
+			t.touch()
+			continue
+		}
+		// a request may have come while checking busy
+		if t.idleFor() >= t.timeout {
+			return
+		}
+	}
+}
+
+// runningFilters select the containers which keep running, paused or
+// restarting ones included
+var runningFilters = filters.Args{"status": {"running", "paused", "restarting"}}
+
+// hasRunningContainers returns whether the daemon runs containers, they keep
+// the daemon from exiting when it is idle
+func hasRunningContainers(eng *engine.Engine) bool {
+	job := eng.Job("containers")
+	job.SetenvInt("limit", 1)
+	// a limit alone lists the stopped containers too
+	filterParam, err := filters.ToParam(runningFilters)
+	if err != nil {
+		return true
+	}
+	job.Setenv("filters", filterParam)
+	outs, err := job.Stdout.AddListTable()
+	if err != nil {
+		return true
+	}
+	if err := job.Run(); err != nil {
+		log.Errorf("Error listing the running containers: %v", err)
+		return true
+	}
+	return outs.Len() > 0
+}
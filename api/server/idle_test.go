@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/parsers/filters"
+)
+
+func TestIdleTrackerConnections(t *testing.T) {
+	tracker := newIdleTracker(time.Hour)
+	c1, c2 := &net.TCPConn{}, &net.TCPConn{}
+
+	tracker.connState(c1, http.StateNew)
+	tracker.connState(c2, http.StateActive)
+	if idle := tracker.idleFor(); idle != 0 {
+		t.Fatalf("Expected no idle time while requests are served, got %s", idle)
+	}
+	tracker.connState(c1, http.StateIdle)
+	if idle := tracker.idleFor(); idle != 0 {
+		t.Fatalf("Expected no idle time while a request is served, got %s", idle)
+	}
+	tracker.connState(c2, http.StateHijacked)
+	if idle := tracker.idleFor(); idle <= 0 || idle > time.Minute {
+		t.Fatalf("Expected the idle time to start with the last request, got %s", idle)
+	}
+}
+
+func TestIdleTrackerWaitsWhileBusy(t *testing.T) {
+	tracker := newIdleTracker(10 * time.Millisecond)
+	checks := 0
+	done := make(chan struct{})
+	go func() {
+		tracker.waitIdle(func() bool {
+			checks++
+			return checks < 3
+		})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The tracker did not detect the idle timeout")
+	}
+	if checks != 3 {
+		t.Fatalf("Expected the tracker to wait while busy, got %d checks", checks)
+	}
+}
+
+func TestHasRunningContainers(t *testing.T) {
+	running := false
+	eng := engine.New()
+	eng.Register("containers", func(job *engine.Job) engine.Status {
+		psFilters, err := filters.FromParam(job.Getenv("filters"))
+		if err != nil {
+			return job.Error(err)
+		}
+		var list []string
+		if running {
+			list = append(list, `{"Id": "1234"}`)
+		}
+		// the stopped container is listed unless the filters leave it out
+		if psFilters.Match("status", "exited") {
+			list = append(list, `{"Id": "5678"}`)
+		}
+		job.Stdout.Write([]byte("[" + strings.Join(list, ",") + "]"))
+		return engine.StatusOK
+	})
+	if hasRunningContainers(eng) {
+		t.Fatal("Expected the stopped containers not to keep the daemon running")
+	}
+	running = true
+	if !hasRunningContainers(eng) {
+		t.Fatal("Expected the running containers to keep the daemon running")
+	}
+}
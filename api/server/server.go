@@ -16,9 +16,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
-
-	"crypto/tls"
-	"crypto/x509"
+	"time"
 
 	"code.google.com/p/go.net/websocket"
 	"github.com/docker/libcontainer/user"
@@ -29,6 +27,7 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/daemon/networkdriver/portallocator"
 	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/authorization"
 	"github.com/docker/docker/pkg/listenbuffer"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/stdcopy"
@@ -48,6 +47,7 @@ type HttpServer struct {
 }
 
 func (s *HttpServer) Serve() error {
+	apiDrain.track(s.l)
 	return s.srv.Serve(s.l)
 }
 func (s *HttpServer) Close() error {
@@ -107,6 +107,20 @@ func parseForm(r *http.Request) error {
 	return nil
 }
 
+// formFields returns the fields selected by the fields parameter of the form,
+// such as fields=Id,Names, in one or several values
+func formFields(r *http.Request) []string {
+	var fields []string
+	for _, value := range r.Form["fields"] {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
 func parseMultipartForm(r *http.Request) error {
 	if err := r.ParseMultipartForm(4096); err != nil && !strings.HasPrefix(err.Error(), "mime:") {
 		return err
@@ -280,6 +294,9 @@ func getImagesJSON(eng *engine.Engine, version version.Version, w http.ResponseW
 	// FIXME this parameter could just be a match filter
 	job.Setenv("filter", r.Form.Get("filter"))
 	job.Setenv("all", r.Form.Get("all"))
+	job.Setenv("limit", r.Form.Get("limit"))
+	job.Setenv("offset", r.Form.Get("offset"))
+	job.SetenvList("fields", formFields(r))
 
 	if version.GreaterThanOrEqualTo("1.7") {
 		streamJSON(job, w, false)
@@ -397,7 +414,9 @@ func getContainersJSON(eng *engine.Engine, version version.Version, w http.Respo
 	job.Setenv("since", r.Form.Get("since"))
 	job.Setenv("before", r.Form.Get("before"))
 	job.Setenv("limit", r.Form.Get("limit"))
+	job.Setenv("offset", r.Form.Get("offset"))
 	job.Setenv("filters", r.Form.Get("filters"))
+	job.SetenvList("fields", formFields(r))
 
 	if version.GreaterThanOrEqualTo("1.5") {
 		streamJSON(job, w, false)
@@ -430,10 +449,22 @@ func getContainersStats(eng *engine.Engine, version version.Version, w http.Resp
 	}
 	name := vars["name"]
 	job := eng.Job("container_stats", name)
+	if _, exists := r.Form["stream"]; exists {
+		job.Setenv("stream", r.Form.Get("stream"))
+	}
 	streamJSON(job, w, true)
 	return job.Run()
 }
 
+func getContainersStatsSamples(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	job := eng.Job("containers_stats", r.Form["name"]...)
+	streamJSON(job, w, false)
+	return job.Run()
+}
+
 func getContainersLogs(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := parseForm(r); err != nil {
 		return err
@@ -701,13 +732,44 @@ func getImagesGet(eng *engine.Engine, version version.Version, w http.ResponseWr
 	} else {
 		job = eng.Job("image_export", r.Form["names"]...)
 	}
+	job.Setenv("compress", r.Form.Get("compress"))
+	job.SetenvList("exclude", r.Form["exclude"])
 	job.Stdout.Add(w)
 	return job.Run()
 }
 
 func postImagesLoad(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
 	job := eng.Job("load")
 	job.Stdin.Add(r.Body)
+	job.Setenv("verify", r.Form.Get("verify"))
+	// the progress is only streamed on demand, the clients which don't
+	// know about it expect an empty response
+	if r.Form.Get("quiet") == "0" {
+		job.SetenvBool("json", true)
+		streamJSON(job, w, true)
+		if err := job.Run(); err != nil {
+			if !job.Stdout.Used() {
+				return err
+			}
+			sf := utils.NewStreamFormatter(true)
+			w.Write(sf.FormatError(err))
+		}
+		return nil
+	}
+	return job.Run()
+}
+
+func postImagesPrune(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	job := eng.Job("image_prune")
+	job.Setenv("filters", r.Form.Get("filters"))
+	job.Setenv("all", r.Form.Get("all"))
+	streamJSON(job, w, false)
 	return job.Run()
 }
 
@@ -754,7 +816,10 @@ func postContainersRestart(eng *engine.Engine, version version.Version, w http.R
 		return fmt.Errorf("Missing parameter")
 	}
 	job := eng.Job("restart", vars["name"])
-	job.Setenv("t", r.Form.Get("t"))
+	// Leave "t" unset when omitted so the container's own stop timeout applies
+	if t := r.Form.Get("t"); t != "" {
+		job.Setenv("t", t)
+	}
 	if err := job.Run(); err != nil {
 		return err
 	}
@@ -859,7 +924,10 @@ func postContainersStop(eng *engine.Engine, version version.Version, w http.Resp
 		return fmt.Errorf("Missing parameter")
 	}
 	job := eng.Job("stop", vars["name"])
-	job.Setenv("t", r.Form.Get("t"))
+	// Leave "t" unset when omitted so the container's own stop timeout applies
+	if t := r.Form.Get("t"); t != "" {
+		job.Setenv("t", t)
+	}
 	if err := job.Run(); err != nil {
 		if err.Error() == "Container already stopped" {
 			w.WriteHeader(http.StatusNotModified)
@@ -880,6 +948,10 @@ func postContainersWait(eng *engine.Engine, version version.Version, w http.Resp
 		stdoutBuffer = bytes.NewBuffer(nil)
 		job          = eng.Job("wait", vars["name"])
 	)
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	job.Setenv("condition", r.Form.Get("condition"))
 	job.Stdout.Add(stdoutBuffer)
 	if err := job.Run(); err != nil {
 		return err
@@ -948,6 +1020,9 @@ func postContainersAttach(eng *engine.Engine, version version.Version, w http.Re
 	job.Setenv("stdin", r.Form.Get("stdin"))
 	job.Setenv("stdout", r.Form.Get("stdout"))
 	job.Setenv("stderr", r.Form.Get("stderr"))
+	if detachKeys, exists := r.Form["detachKeys"]; exists {
+		job.Setenv("detachKeys", detachKeys[0])
+	}
 	job.Stdin.Add(inStream)
 	job.Stdout.Add(outStream)
 	job.Stderr.Set(errStream)
@@ -966,6 +1041,30 @@ func wsContainersAttach(eng *engine.Engine, version version.Version, w http.Resp
 		return fmt.Errorf("Missing parameter")
 	}
 
+	// the binary frames and the channels are opt-in, the browsers written
+	// for the text frames keep working
+	binaryFrames, err := getBoolParam(r.Form.Get("binary"))
+	if err != nil {
+		return err
+	}
+	channels, err := getBoolParam(r.Form.Get("channels"))
+	if err != nil {
+		return err
+	}
+	window := 0
+	if value := r.Form.Get("window"); value != "" {
+		if window, err = strconv.Atoi(value); err != nil || window < 0 {
+			return fmt.Errorf("Invalid window %q", value)
+		}
+		if window > 0 && !channels {
+			return fmt.Errorf("The flow control window requires channels")
+		}
+	}
+	stdin, err := getBoolParam(r.Form.Get("stdin"))
+	if err != nil {
+		return err
+	}
+
 	if err := eng.Job("container_inspect", vars["name"]).Run(); err != nil {
 		return err
 	}
@@ -978,9 +1077,23 @@ func wsContainersAttach(eng *engine.Engine, version version.Version, w http.Resp
 		job.Setenv("stdin", r.Form.Get("stdin"))
 		job.Setenv("stdout", r.Form.Get("stdout"))
 		job.Setenv("stderr", r.Form.Get("stderr"))
-		job.Stdin.Add(ws)
-		job.Stdout.Add(ws)
-		job.Stderr.Set(ws)
+		if detachKeys, exists := r.Form["detachKeys"]; exists {
+			job.Setenv("detachKeys", detachKeys[0])
+		}
+		if binaryFrames || channels {
+			a := newWsAttach(ws, channels, window)
+			if stdin {
+				job.Stdin.Add(a.Stdin())
+			} else {
+				a.Discard()
+			}
+			job.Stdout.Add(a.Stdout())
+			job.Stderr.Set(a.Stderr())
+		} else {
+			job.Stdin.Add(ws)
+			job.Stdout.Add(ws)
+			job.Stderr.Set(ws)
+		}
 		if err := job.Run(); err != nil {
 			log.Errorf("Error attaching websocket: %s", err)
 		}
@@ -994,10 +1107,14 @@ func getContainersByName(eng *engine.Engine, version version.Version, w http.Res
 	if vars == nil {
 		return fmt.Errorf("Missing parameter")
 	}
+	if err := parseForm(r); err != nil {
+		return err
+	}
 	var job = eng.Job("container_inspect", vars["name"])
 	if version.LessThan("1.12") {
 		job.SetenvBool("raw", true)
 	}
+	job.Setenv("size", r.Form.Get("size"))
 	streamJSON(job, w, false)
 	return job.Run()
 }
@@ -1015,14 +1132,50 @@ func getImagesByName(eng *engine.Engine, version version.Version, w http.Respons
 	if vars == nil {
 		return fmt.Errorf("Missing parameter")
 	}
+	if err := parseForm(r); err != nil {
+		return err
+	}
 	var job = eng.Job("image_inspect", vars["name"])
 	if version.LessThan("1.12") {
 		job.SetenvBool("raw", true)
 	}
+	job.Setenv("size", r.Form.Get("size"))
 	streamJSON(job, w, false)
 	return job.Run()
 }
 
+// postSession hijacks the connection for a session of the client, over
+// which the builds naming the session forward the SSH agents of the client
+func postSession(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	id := r.Form.Get("id")
+	if id == "" {
+		return fmt.Errorf("Missing parameter id")
+	}
+
+	inStream, outStream, err := hijackServer(w)
+	if err != nil {
+		return err
+	}
+	defer closeStreams(inStream, outStream)
+
+	if _, ok := r.Header["Upgrade"]; ok {
+		fmt.Fprintf(outStream, "HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	} else {
+		fmt.Fprintf(outStream, "HTTP/1.1 200 OK\r\nContent-Type: application/vnd.docker.raw-stream\r\n\r\n")
+	}
+
+	job := eng.Job("build_session", id)
+	job.Stdin.Add(inStream)
+	job.Stdout.Add(outStream)
+	if err := job.Run(); err != nil {
+		log.Errorf("Error serving session %s: %v", id, err)
+	}
+	return nil
+}
+
 func postBuild(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if version.LessThan("1.3") {
 		return fmt.Errorf("Multipart upload for build is no longer supported. Please upgrade your docker client.")
@@ -1032,6 +1185,8 @@ func postBuild(eng *engine.Engine, version version.Version, w http.ResponseWrite
 		authConfig        = &registry.AuthConfig{}
 		configFileEncoded = r.Header.Get("X-Registry-Config")
 		configFile        = &registry.ConfigFile{}
+		secretsEncoded    = r.Header.Get("X-Build-Secrets")
+		secrets           = map[string][]byte{}
 		job               = eng.Job("build")
 	)
 
@@ -1057,12 +1212,22 @@ func postBuild(eng *engine.Engine, version version.Version, w http.ResponseWrite
 		}
 	}
 
+	if secretsEncoded != "" {
+		secretsJson := base64.NewDecoder(base64.URLEncoding, strings.NewReader(secretsEncoded))
+		if err := json.NewDecoder(secretsJson).Decode(&secrets); err != nil {
+			return fmt.Errorf("Invalid X-Build-Secrets header: %v", err)
+		}
+	}
+
 	if version.GreaterThanOrEqualTo("1.8") {
 		job.SetenvBool("json", true)
 		streamJSON(job, w, true)
 	} else {
 		job.Stdout.Add(utils.NewWriteFlusher(w))
 	}
+	// the older clients would display the structured progress of the steps
+	// as empty lines
+	job.SetenvBool("buildsteps", version.GreaterThanOrEqualTo("1.18"))
 
 	if r.FormValue("forcerm") == "1" && version.GreaterThanOrEqualTo("1.12") {
 		job.Setenv("rm", "1")
@@ -1081,8 +1246,19 @@ func postBuild(eng *engine.Engine, version version.Version, w http.ResponseWrite
 	job.Setenv("q", r.FormValue("q"))
 	job.Setenv("nocache", r.FormValue("nocache"))
 	job.Setenv("forcerm", r.FormValue("forcerm"))
+	job.Setenv("buildargs", r.FormValue("buildargs"))
+	job.Setenv("cachefrom", r.FormValue("cachefrom"))
+	job.Setenv("squash", r.FormValue("squash"))
+	job.Setenv("parallel", r.FormValue("parallel"))
+	job.Setenv("sourcedateepoch", r.FormValue("sourcedateepoch"))
+	job.Setenv("ssh", r.FormValue("ssh"))
+	job.Setenv("session", r.FormValue("session"))
+	job.Setenv("buildcontexts", r.FormValue("buildcontexts"))
+	job.Setenv("networkmode", r.FormValue("networkmode"))
+	job.Setenv("extrahosts", r.FormValue("extrahosts"))
 	job.SetenvJson("authConfig", authConfig)
 	job.SetenvJson("configFile", configFile)
+	job.SetenvJson("secrets", secrets)
 
 	if err := job.Run(); err != nil {
 		if !job.Stdout.Used() {
@@ -1120,6 +1296,7 @@ func postContainersCopy(eng *engine.Engine, version version.Version, w http.Resp
 	}
 
 	job := eng.Job("container_copy", vars["name"], copyData.Get("Resource"))
+	job.SetenvBool("Archive", copyData.GetBool("Archive"))
 	job.Stdout.Add(w)
 	w.Header().Set("Content-Type", "application/x-tar")
 	if err := job.Run(); err != nil {
@@ -1133,6 +1310,40 @@ func postContainersCopy(eng *engine.Engine, version version.Version, w http.Resp
 	return nil
 }
 
+func postContainersCopyTo(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+
+	var copyData engine.Env
+
+	if err := checkForJson(r); err != nil {
+		return err
+	}
+
+	if err := copyData.Decode(r.Body); err != nil {
+		return err
+	}
+
+	if copyData.Get("Resource") == "" || copyData.Get("Path") == "" {
+		return fmt.Errorf("Path cannot be empty")
+	}
+	if copyData.Get("Container") == "" {
+		return fmt.Errorf("Destination container cannot be empty")
+	}
+
+	job := eng.Job("container_copy_to", vars["name"], strings.TrimPrefix(copyData.Get("Resource"), "/"), copyData.Get("Container"), copyData.Get("Path"))
+	job.SetenvBool("Archive", copyData.GetBool("Archive"))
+	if err := job.Run(); err != nil {
+		if strings.Contains(err.Error(), "no such file or directory") {
+			return fmt.Errorf("Could not find the file %s in container %s", copyData.Get("Resource"), vars["name"])
+		}
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
 func postContainerExecCreate(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := parseForm(r); err != nil {
 		return nil
@@ -1225,6 +1436,201 @@ func postContainerExecResize(eng *engine.Engine, version version.Version, w http
 	return nil
 }
 
+func getNetworksJSON(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	job := eng.Job("network_ls")
+	job.Setenv("filters", r.Form.Get("filters"))
+	streamJSON(job, w, false)
+	return job.Run()
+}
+
+func getNetworkByName(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	job := eng.Job("network_inspect", vars["name"])
+	job.Setenv("Verbose", r.Form.Get("verbose"))
+	streamJSON(job, w, false)
+	return job.Run()
+}
+
+func postNetworksCreate(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := checkForJson(r); err != nil {
+		return err
+	}
+	var (
+		create       types.NetworkCreate
+		stdoutBuffer = bytes.NewBuffer(nil)
+	)
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		return err
+	}
+	if len(create.IPAM.Config) > 1 {
+		return fmt.Errorf("Only one IPAM configuration is supported")
+	}
+
+	job := eng.Job("network_create", create.Name)
+	job.Setenv("Driver", create.Driver)
+	job.SetenvBool("Internal", create.Internal)
+	if create.Options != nil {
+		job.SetenvJson("Options", create.Options)
+	}
+	job.Setenv("IPAMDriver", create.IPAM.Driver)
+	if create.IPAM.Options != nil {
+		job.SetenvJson("IPAMOptions", create.IPAM.Options)
+	}
+	if create.Labels != nil {
+		job.SetenvJson("Labels", create.Labels)
+	}
+	if len(create.IPAM.Config) == 1 {
+		config := create.IPAM.Config[0]
+		job.Setenv("Subnet", config.Subnet)
+		job.Setenv("IPRange", config.IPRange)
+		job.Setenv("Gateway", config.Gateway)
+	}
+	if create.DNS != nil {
+		job.SetenvList("Dns", create.DNS.Nameservers)
+		job.SetenvList("DnsSearch", create.DNS.Search)
+		job.SetenvList("DnsOptions", create.DNS.Options)
+	}
+	job.Stdout.Add(stdoutBuffer)
+	if err := job.Run(); err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, &types.NetworkCreateResponse{
+		ID: engine.Tail(stdoutBuffer, 1),
+	})
+}
+
+func postNetworkConnect(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	if err := checkForJson(r); err != nil {
+		return err
+	}
+	var connect types.NetworkConnect
+	if err := json.NewDecoder(r.Body).Decode(&connect); err != nil {
+		return err
+	}
+
+	job := eng.Job("network_connect", vars["name"], connect.Container)
+	job.SetenvList("Aliases", connect.Aliases)
+	job.Setenv("IPAddress", connect.IPAddress)
+	job.Setenv("IPv6Address", connect.IPv6Address)
+	if err := job.Run(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func postNetworkDisconnect(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	if err := checkForJson(r); err != nil {
+		return err
+	}
+	var disconnect types.NetworkDisconnect
+	if err := json.NewDecoder(r.Body).Decode(&disconnect); err != nil {
+		return err
+	}
+
+	if err := eng.Job("network_disconnect", vars["name"], disconnect.Container).Run(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func deleteNetworks(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	if err := eng.Job("network_rm", vars["name"]).Run(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func getVolumesJSON(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	job := eng.Job("volume_ls")
+	job.Setenv("filters", r.Form.Get("filters"))
+	streamJSON(job, w, false)
+	return job.Run()
+}
+
+func getVolumeByName(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	job := eng.Job("volume_inspect", vars["name"])
+	streamJSON(job, w, false)
+	return job.Run()
+}
+
+func postVolumesCreate(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := checkForJson(r); err != nil {
+		return err
+	}
+	var (
+		create       types.VolumeCreate
+		stdoutBuffer = bytes.NewBuffer(nil)
+	)
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		return err
+	}
+
+	job := eng.Job("volume_create", create.Name)
+	job.Setenv("Driver", create.Driver)
+	if create.DriverOpts != nil {
+		job.SetenvJson("DriverOpts", create.DriverOpts)
+	}
+	if create.Labels != nil {
+		job.SetenvJson("Labels", create.Labels)
+	}
+	job.Stdout.Add(stdoutBuffer)
+	if err := job.Run(); err != nil {
+		return err
+	}
+	var volume types.Volume
+	if err := json.NewDecoder(stdoutBuffer).Decode(&volume); err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, &volume)
+}
+
+func postVolumesPrune(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	job := eng.Job("volume_prune")
+	job.Setenv("filters", r.Form.Get("filters"))
+	streamJSON(job, w, false)
+	return job.Run()
+}
+
+func deleteVolumes(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	if err := eng.Job("volume_rm", vars["name"]).Run(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
 func optionsHandler(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	w.WriteHeader(http.StatusOK)
 	return nil
@@ -1241,7 +1647,7 @@ func ping(eng *engine.Engine, version version.Version, w http.ResponseWriter, r
 	return err
 }
 
-func makeHttpHandler(eng *engine.Engine, logging bool, localMethod string, localRoute string, handlerFunc HttpApiFunc, corsHeaders string, dockerVersion version.Version) http.HandlerFunc {
+func makeHttpHandler(eng *engine.Engine, logging bool, localMethod string, localRoute string, handlerFunc HttpApiFunc, corsHeaders string, dockerVersion version.Version, authZPlugins []authorization.Plugin) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// log the request
 		log.Debugf("Calling %s %s", localMethod, localRoute)
@@ -1250,6 +1656,17 @@ func makeHttpHandler(eng *engine.Engine, logging bool, localMethod string, local
 			log.Infof("%s %s", r.Method, r.RequestURI)
 		}
 
+		if auditLog != nil && audited(r.Method) {
+			aw := &auditResponseWriter{ResponseWriter: w}
+			w = aw
+			defer auditLog.log(r, localRoute, mux.Vars(r), aw, time.Now())
+		}
+
+		if apiDrain.isDraining() {
+			http.Error(w, "The daemon is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+
 		if strings.Contains(r.Header.Get("User-Agent"), "Docker-Client/") {
 			userAgent := strings.Split(r.Header.Get("User-Agent"), "/")
 			if len(userAgent) == 2 && !dockerVersion.Equal(version.Version(userAgent[1])) {
@@ -1265,7 +1682,40 @@ func makeHttpHandler(eng *engine.Engine, logging bool, localMethod string, local
 		}
 
 		if version.GreaterThan(api.APIVERSION) {
-			http.Error(w, fmt.Errorf("client and server don't have same version (client : %s, server: %s)", version, api.APIVERSION).Error(), http.StatusNotFound)
+			http.Error(w, fmt.Errorf("client is newer than server (client API version: %s, server API version: %s)", version, api.APIVERSION).Error(), http.StatusNotFound)
+			return
+		}
+		if version.LessThan(api.APIMINVERSION) {
+			http.Error(w, fmt.Errorf("client version %s is too old. Minimum supported API version is %s, please upgrade your client to a newer version", version, api.APIMINVERSION).Error(), http.StatusBadRequest)
+			return
+		}
+
+		if len(authZPlugins) > 0 {
+			user, userAuthNMethod := authorizationUser(r)
+			authCtx := authorization.NewCtx(authZPlugins, user, userAuthNMethod, r.Method, r.RequestURI)
+			if err := authCtx.AuthZRequest(r); err != nil {
+				log.Errorf("AuthZRequest for %s %s returned error: %s", r.Method, r.RequestURI, err)
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+
+			rm := authorization.NewResponseModifier(w)
+			if err := handlerFunc(eng, version, rm, r, mux.Vars(r)); err != nil {
+				log.Errorf("Handler for %s %s returned error: %s", localMethod, localRoute, err)
+				httpError(rm, err)
+			}
+
+			if err := authCtx.AuthZResponse(rm); err != nil {
+				log.Errorf("AuthZResponse for %s %s returned error: %s", r.Method, r.RequestURI, err)
+				// streamed and hijacked responses were already returned
+				if !rm.Passthrough() {
+					http.Error(w, err.Error(), http.StatusForbidden)
+				}
+				return
+			}
+			if err := rm.FlushAll(); err != nil {
+				log.Errorf("Error writing the response of %s %s: %s", r.Method, r.RequestURI, err)
+			}
 			return
 		}
 
@@ -1276,6 +1726,15 @@ func makeHttpHandler(eng *engine.Engine, logging bool, localMethod string, local
 	}
 }
 
+// authorizationUser returns the user sending the request and how it was
+// authenticated, users are only known when the client sent a certificate.
+func authorizationUser(r *http.Request) (string, string) {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return r.TLS.PeerCertificates[0].Subject.CommonName, "TLS"
+	}
+	return "", ""
+}
+
 // Replicated from expvar.go as not public.
 func expvarHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -1304,7 +1763,7 @@ func AttachProfiler(router *mux.Router) {
 }
 
 // we keep enableCors just for legacy usage, need to be removed in the future
-func createRouter(eng *engine.Engine, logging, enableCors bool, corsHeaders string, dockerVersion string) *mux.Router {
+func createRouter(eng *engine.Engine, logging, enableCors bool, corsHeaders string, dockerVersion string, authZPlugins []string) *mux.Router {
 	r := mux.NewRouter()
 	if os.Getenv("DEBUG") != "" {
 		AttachProfiler(r)
@@ -1324,6 +1783,7 @@ func createRouter(eng *engine.Engine, logging, enableCors bool, corsHeaders stri
 			"/images/{name:.*}/json":          getImagesByName,
 			"/containers/ps":                  getContainersJSON,
 			"/containers/json":                getContainersJSON,
+			"/containers/stats":               getContainersStatsSamples,
 			"/containers/{name:.*}/export":    getContainersExport,
 			"/containers/{name:.*}/changes":   getContainersChanges,
 			"/containers/{name:.*}/json":      getContainersByName,
@@ -1332,34 +1792,48 @@ func createRouter(eng *engine.Engine, logging, enableCors bool, corsHeaders stri
 			"/containers/{name:.*}/stats":     getContainersStats,
 			"/containers/{name:.*}/attach/ws": wsContainersAttach,
 			"/exec/{id:.*}/json":              getExecByID,
+			"/networks":                       getNetworksJSON,
+			"/networks/{name:.*}":             getNetworkByName,
+			"/volumes":                        getVolumesJSON,
+			"/volumes/{name:.*}":              getVolumeByName,
 		},
 		"POST": {
-			"/auth":                         postAuth,
-			"/commit":                       postCommit,
-			"/build":                        postBuild,
-			"/images/create":                postImagesCreate,
-			"/images/load":                  postImagesLoad,
-			"/images/{name:.*}/push":        postImagesPush,
-			"/images/{name:.*}/tag":         postImagesTag,
-			"/containers/create":            postContainersCreate,
-			"/containers/{name:.*}/kill":    postContainersKill,
-			"/containers/{name:.*}/pause":   postContainersPause,
-			"/containers/{name:.*}/unpause": postContainersUnpause,
-			"/containers/{name:.*}/restart": postContainersRestart,
-			"/containers/{name:.*}/start":   postContainersStart,
-			"/containers/{name:.*}/stop":    postContainersStop,
-			"/containers/{name:.*}/wait":    postContainersWait,
-			"/containers/{name:.*}/resize":  postContainersResize,
-			"/containers/{name:.*}/attach":  postContainersAttach,
-			"/containers/{name:.*}/copy":    postContainersCopy,
-			"/containers/{name:.*}/exec":    postContainerExecCreate,
-			"/exec/{name:.*}/start":         postContainerExecStart,
-			"/exec/{name:.*}/resize":        postContainerExecResize,
-			"/containers/{name:.*}/rename":  postContainerRename,
+			"/auth":                          postAuth,
+			"/commit":                        postCommit,
+			"/build":                         postBuild,
+			"/session":                       postSession,
+			"/images/create":                 postImagesCreate,
+			"/images/load":                   postImagesLoad,
+			"/images/prune":                  postImagesPrune,
+			"/images/{name:.*}/push":         postImagesPush,
+			"/images/{name:.*}/tag":          postImagesTag,
+			"/containers/create":             postContainersCreate,
+			"/containers/{name:.*}/kill":     postContainersKill,
+			"/containers/{name:.*}/pause":    postContainersPause,
+			"/containers/{name:.*}/unpause":  postContainersUnpause,
+			"/containers/{name:.*}/restart":  postContainersRestart,
+			"/containers/{name:.*}/start":    postContainersStart,
+			"/containers/{name:.*}/stop":     postContainersStop,
+			"/containers/{name:.*}/wait":     postContainersWait,
+			"/containers/{name:.*}/resize":   postContainersResize,
+			"/containers/{name:.*}/attach":   postContainersAttach,
+			"/containers/{name:.*}/copy":     postContainersCopy,
+			"/containers/{name:.*}/copyto":   postContainersCopyTo,
+			"/containers/{name:.*}/exec":     postContainerExecCreate,
+			"/exec/{name:.*}/start":          postContainerExecStart,
+			"/exec/{name:.*}/resize":         postContainerExecResize,
+			"/containers/{name:.*}/rename":   postContainerRename,
+			"/networks/create":               postNetworksCreate,
+			"/networks/{name:.*}/connect":    postNetworkConnect,
+			"/networks/{name:.*}/disconnect": postNetworkDisconnect,
+			"/volumes/create":                postVolumesCreate,
+			"/volumes/prune":                 postVolumesPrune,
 		},
 		"DELETE": {
 			"/containers/{name:.*}": deleteContainers,
 			"/images/{name:.*}":     deleteImages,
+			"/networks/{name:.*}":   deleteNetworks,
+			"/volumes/{name:.*}":    deleteVolumes,
 		},
 		"OPTIONS": {
 			"": optionsHandler,
@@ -1372,6 +1846,8 @@ func createRouter(eng *engine.Engine, logging, enableCors bool, corsHeaders stri
 		corsHeaders = "*"
 	}
 
+	authorizationPlugins := authorization.NewPlugins(authZPlugins)
+
 	for method, routes := range m {
 		for route, fct := range routes {
 			log.Debugf("Registering %s, %s", method, route)
@@ -1381,7 +1857,7 @@ func createRouter(eng *engine.Engine, logging, enableCors bool, corsHeaders stri
 			localMethod := method
 
 			// build the handler function
-			f := makeHttpHandler(eng, logging, localMethod, localRoute, localFct, corsHeaders, version.Version(dockerVersion))
+			f := makeHttpHandler(eng, logging, localMethod, localRoute, localFct, corsHeaders, version.Version(dockerVersion), authorizationPlugins)
 
 			// add the new route
 			if localRoute == "" {
@@ -1400,7 +1876,7 @@ func createRouter(eng *engine.Engine, logging, enableCors bool, corsHeaders stri
 // FIXME: refactor this to be part of Server and not require re-creating a new
 // router each time. This requires first moving ListenAndServe into Server.
 func ServeRequest(eng *engine.Engine, apiversion version.Version, w http.ResponseWriter, req *http.Request) {
-	router := createRouter(eng, false, true, "", "")
+	router := createRouter(eng, false, true, "", "", nil)
 	// Insert APIVERSION into the request as a convenience
 	req.URL.Path = fmt.Sprintf("/v%s%s", apiversion, req.URL.Path)
 	router.ServeHTTP(w, req)
@@ -1409,13 +1885,28 @@ func ServeRequest(eng *engine.Engine, apiversion version.Version, w http.Respons
 // serveFd creates an http.Server and sets it up to serve given a socket activated
 // argument.
 func serveFd(addr string, job *engine.Job) error {
-	r := createRouter(job.Eng, job.GetenvBool("Logging"), job.GetenvBool("EnableCors"), job.Getenv("CorsHeaders"), job.Getenv("Version"))
+	r := createRouter(job.Eng, job.GetenvBool("Logging"), job.GetenvBool("EnableCors"), job.Getenv("CorsHeaders"), job.Getenv("Version"), job.GetenvList("AuthorizationPlugins"))
 
 	ls, e := systemd.ListenFD(addr)
 	if e != nil {
 		return e
 	}
 
+	if job.GetenvBool("Tls") || job.GetenvBool("TlsVerify") {
+		var tlsCa string
+		if job.GetenvBool("TlsVerify") {
+			tlsCa = job.Getenv("TlsCa")
+		}
+		for i, l := range ls {
+			if _, ok := l.Addr().(*net.TCPAddr); !ok {
+				continue
+			}
+			if ls[i], e = setupTls(job.Getenv("TlsCert"), job.Getenv("TlsKey"), tlsCa, l); e != nil {
+				return e
+			}
+		}
+	}
+
 	chErrors := make(chan error, len(ls))
 
 	// We don't want to start serving on these sockets until the
@@ -1429,13 +1920,17 @@ func serveFd(addr string, job *engine.Job) error {
 		listener := ls[i]
 		go func() {
 			httpSrv := http.Server{Handler: r}
+			if idleConns != nil {
+				httpSrv.ConnState = idleConns.connState
+			}
+			apiDrain.track(listener)
 			chErrors <- httpSrv.Serve(listener)
 		}()
 	}
 
 	for i := 0; i < len(ls); i++ {
 		err := <-chErrors
-		if err != nil {
+		if err != nil && !apiDrain.isDraining() {
 			return err
 		}
 	}
@@ -1460,36 +1955,6 @@ func lookupGidByName(nameOrGid string) (int, error) {
 	return -1, fmt.Errorf("Group %s not found", nameOrGid)
 }
 
-func setupTls(cert, key, ca string, l net.Listener) (net.Listener, error) {
-	tlsCert, err := tls.LoadX509KeyPair(cert, key)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("Could not load X509 key pair (%s, %s): %v", cert, key, err)
-		}
-		return nil, fmt.Errorf("Error reading X509 key pair (%s, %s): %q. Make sure the key is encrypted.",
-			cert, key, err)
-	}
-	tlsConfig := &tls.Config{
-		NextProtos:   []string{"http/1.1"},
-		Certificates: []tls.Certificate{tlsCert},
-		// Avoid fallback on insecure SSL protocols
-		MinVersion: tls.VersionTLS10,
-	}
-
-	if ca != "" {
-		certPool := x509.NewCertPool()
-		file, err := ioutil.ReadFile(ca)
-		if err != nil {
-			return nil, fmt.Errorf("Could not read CA certificate: %v", err)
-		}
-		certPool.AppendCertsFromPEM(file)
-		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
-		tlsConfig.ClientCAs = certPool
-	}
-
-	return tls.NewListener(l, tlsConfig), nil
-}
-
 func newListener(proto, addr string, bufferRequests bool) (net.Listener, error) {
 	if bufferRequests {
 		return listenbuffer.NewListenBuffer(proto, addr, activationLock)
@@ -1554,7 +2019,7 @@ func setupTcpHttp(addr string, job *engine.Job) (*HttpServer, error) {
 		log.Infof("/!\\ DON'T BIND ON ANY IP ADDRESS WITHOUT setting -tlsverify IF YOU DON'T KNOW WHAT YOU'RE DOING /!\\")
 	}
 
-	r := createRouter(job.Eng, job.GetenvBool("Logging"), job.GetenvBool("EnableCors"), job.Getenv("CorsHeaders"), job.Getenv("Version"))
+	r := createRouter(job.Eng, job.GetenvBool("Logging"), job.GetenvBool("EnableCors"), job.Getenv("CorsHeaders"), job.Getenv("Version"), job.GetenvList("AuthorizationPlugins"))
 
 	l, err := newListener("tcp", addr, job.GetenvBool("BufferRequests"))
 	if err != nil {
@@ -1595,6 +2060,38 @@ func ServeApi(job *engine.Job) engine.Status {
 	)
 	activationLock = make(chan struct{})
 
+	// the listeners are closed when the daemon starts shutting down
+	drain := &drainer{}
+	apiDrain = drain
+	job.Eng.OnDrain(drain.drain)
+
+	auditLog = nil
+	if path := job.Getenv("AuditLog"); path != "" {
+		l, err := openAuditLog(path)
+		if err != nil {
+			return job.Error(err)
+		}
+		auditLog = l
+	}
+
+	idleConns = nil
+	if timeout := job.Getenv("IdleTimeout"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return job.Errorf("Invalid idle timeout %s: %v", timeout, err)
+		}
+		if d > 0 {
+			// the daemon only restarts on the next connection when systemd
+			// listens on its sockets
+			for _, protoAddr := range protoAddrs {
+				if !strings.HasPrefix(protoAddr, "fd://") {
+					return job.Errorf("The idle timeout requires the daemon to be socket activated, it cannot listen on %s", protoAddr)
+				}
+			}
+			idleConns = newIdleTracker(d)
+		}
+	}
+
 	for _, protoAddr := range protoAddrs {
 		protoAddrParts := strings.SplitN(protoAddr, "://", 2)
 		if len(protoAddrParts) != 2 {
@@ -1613,7 +2110,7 @@ func ServeApi(job *engine.Job) engine.Status {
 
 	for i := 0; i < len(protoAddrs); i++ {
 		err := <-chErrors
-		if err != nil {
+		if err != nil && !apiDrain.isDraining() {
 			return job.Error(err)
 		}
 	}
@@ -1630,5 +2127,15 @@ func AcceptConnections(job *engine.Job) engine.Status {
 		close(activationLock)
 	}
 
+	if idleConns != nil {
+		eng := job.Eng
+		go func() {
+			idleConns.waitIdle(func() bool { return hasRunningContainers(eng) })
+			log.Infof("No API request and no running container for %s, exiting until the next socket activation", idleConns.timeout)
+			eng.Shutdown()
+			os.Exit(0)
+		}()
+	}
+
 	return engine.StatusOK
 }
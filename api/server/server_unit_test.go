@@ -89,6 +89,36 @@ func TestGetVersion(t *testing.T) {
 	}
 }
 
+func TestGetVersionTooOld(t *testing.T) {
+	eng := engine.New()
+	var called bool
+	eng.Register("version", func(job *engine.Job) engine.Status {
+		called = true
+		return engine.StatusOK
+	})
+	r := serveRequestUsingVersion("GET", "/version", "0.9", nil, eng, t)
+	if called {
+		t.Fatalf("handler should not have been called")
+	}
+	if r.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d", http.StatusBadRequest, r.Code)
+	}
+	if !strings.Contains(r.Body.String(), "Minimum supported API version is "+string(api.APIMINVERSION)) {
+		t.Fatalf("Unexpected error message: %q", r.Body.String())
+	}
+}
+
+func TestGetVersionTooNew(t *testing.T) {
+	eng := engine.New()
+	eng.Register("version", func(job *engine.Job) engine.Status {
+		return engine.StatusOK
+	})
+	r := serveRequestUsingVersion("GET", "/version", "99.0", nil, eng, t)
+	if r.Code != http.StatusNotFound {
+		t.Fatalf("Expected status code %d, got %d", http.StatusNotFound, r.Code)
+	}
+}
+
 func TestGetInfo(t *testing.T) {
 	eng := engine.New()
 	var called bool
@@ -181,6 +211,24 @@ func TestGetImagesJSONAll(t *testing.T) {
 	}
 }
 
+func TestGetImagesJSONPagination(t *testing.T) {
+	eng := engine.New()
+	var limit, offset string
+	var fields []string
+	eng.Register("images", func(job *engine.Job) engine.Status {
+		limit, offset = job.Getenv("limit"), job.Getenv("offset")
+		fields = job.GetenvList("fields")
+		return engine.StatusOK
+	})
+	serveRequest("GET", "/images/json?limit=10&offset=20&fields=Id,RepoTags&fields=Size", nil, eng, t)
+	if limit != "10" || offset != "20" {
+		t.Errorf("Expected the limit 10 and offset 20, got %q and %q", limit, offset)
+	}
+	if !reflect.DeepEqual(fields, []string{"Id", "RepoTags", "Size"}) {
+		t.Errorf("Expected the fields Id, RepoTags and Size, got %#v", fields)
+	}
+}
+
 func TestGetImagesJSONLegacyFormat(t *testing.T) {
 	eng := engine.New()
 	var called bool
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	gosignal "os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-fsnotify/fsnotify"
+)
+
+// setupTls returns a listener serving TLS with the certificate, key and CA,
+// they are reloaded when their files change or the daemon receives SIGHUP
+func setupTls(cert, key, ca string, l net.Listener) (net.Listener, error) {
+	reloader, err := newTLSReloader(cert, key, ca)
+	if err != nil {
+		return nil, err
+	}
+	if err := reloader.watch(); err != nil {
+		return nil, err
+	}
+	return &tlsListener{l, reloader}, nil
+}
+
+func loadTLSConfig(cert, key, ca string) (*tls.Config, error) {
+	tlsCert, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("Could not load X509 key pair (%s, %s): %v", cert, key, err)
+		}
+		return nil, fmt.Errorf("Error reading X509 key pair (%s, %s): %q. Make sure the key is encrypted.",
+			cert, key, err)
+	}
+	tlsConfig := &tls.Config{
+		NextProtos:   []string{"http/1.1"},
+		Certificates: []tls.Certificate{tlsCert},
+		// Avoid fallback on insecure SSL protocols
+		MinVersion: tls.VersionTLS10,
+	}
+
+	if ca != "" {
+		certPool := x509.NewCertPool()
+		file, err := ioutil.ReadFile(ca)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read CA certificate: %v", err)
+		}
+		certPool.AppendCertsFromPEM(file)
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		tlsConfig.ClientCAs = certPool
+	}
+	return tlsConfig, nil
+}
+
+// tlsReloader holds the TLS configuration loaded from the files of the
+// certificate, key and CA. A failed reload keeps the previous configuration,
+// so a certificate and its key can be replaced one after the other.
+type tlsReloader struct {
+	cert, key, ca string
+
+	mu     sync.RWMutex
+	config *tls.Config
+}
+
+func newTLSReloader(cert, key, ca string) (*tlsReloader, error) {
+	r := &tlsReloader{cert: cert, key: key, ca: ca}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Config returns the configuration of the new connections
+func (r *tlsReloader) Config() *tls.Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.config
+}
+
+func (r *tlsReloader) reload() error {
+	config, err := loadTLSConfig(r.cert, r.key, r.ca)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.config = config
+	r.mu.Unlock()
+	return nil
+}
+
+// watch reloads the configuration when one of its files is written or
+// replaced, and when the daemon receives SIGHUP. The directories of the files
+// are watched, the files are usually replaced by renaming a new one.
+func (r *tlsReloader) watch() error {
+	files := make(map[string]bool)
+	for _, file := range []string{r.cert, r.key, r.ca} {
+		if file == "" {
+			continue
+		}
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		files[abs] = true
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	watched := make(map[string]bool)
+	for file := range files {
+		dir := filepath.Dir(file)
+		if watched[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+		watched[dir] = true
+	}
+
+	sighup := make(chan os.Signal, 1)
+	gosignal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case event := <-watcher.Events:
+				if files[event.Name] && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					r.reloadAndLog(event.Name + " changed")
+				}
+			case <-sighup:
+				r.reloadAndLog("received SIGHUP")
+			case err := <-watcher.Errors:
+				log.Debugf("TLS files notify error: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func (r *tlsReloader) reloadAndLog(reason string) {
+	if err := r.reload(); err != nil {
+		log.Errorf("Error reloading the TLS configuration (%s), keeping the previous one: %v", reason, err)
+		return
+	}
+	log.Infof("Reloaded the TLS configuration, %s", reason)
+}
+
+// tlsListener serves TLS with the current configuration of its reloader,
+// the accepted connections keep the configuration of their handshake
+type tlsListener struct {
+	net.Listener
+	reloader *tlsReloader
+}
+
+func (l *tlsListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return tls.Server(c, l.reloader.Config()), nil
+}
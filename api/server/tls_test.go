@@ -0,0 +1,141 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestKeyPair writes a self-signed certificate of the common name and
+// its key in dir, replacing the previous ones by renaming
+func writeTestKeyPair(t *testing.T, dir, cn string) (string, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(name string, block *pem.Block) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path+".tmp", pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	keyFile := write("key.pem", &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	certFile := write("cert.pem", &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return certFile, keyFile
+}
+
+func commonName(t *testing.T, config *tls.Config) string {
+	cert, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert.Subject.CommonName
+}
+
+func TestTLSReloaderKeepsConfigOnError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-tls-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cert, key := writeTestKeyPair(t, dir, "first")
+	r, err := newTLSReloader(cert, key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(key, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reload(); err == nil {
+		t.Fatal("Expected an error reloading an invalid key")
+	}
+	if cn := commonName(t, r.Config()); cn != "first" {
+		t.Fatalf("Expected the previous certificate to be kept, got %s", cn)
+	}
+
+	writeTestKeyPair(t, dir, "second")
+	if err := r.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cn := commonName(t, r.Config()); cn != "second" {
+		t.Fatalf("Expected the new certificate, got %s", cn)
+	}
+}
+
+func TestTLSListenerReloadsOnChange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-tls-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cert, key := writeTestKeyPair(t, dir, "first")
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err = setupTls(cert, key, "", l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				c.(*tls.Conn).Handshake()
+				c.Close()
+			}()
+		}
+	}()
+
+	serverName := func() string {
+		c, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		return c.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+	if cn := serverName(); cn != "first" {
+		t.Fatalf("Expected the first certificate, got %s", cn)
+	}
+
+	writeTestKeyPair(t, dir, "second")
+	for start := time.Now(); serverName() != "second"; {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("The certificate was not reloaded")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"sync"
+
+	"code.google.com/p/go.net/websocket"
+	log "github.com/Sirupsen/logrus"
+)
+
+// The frames of a websocket attached with channels start with the byte of
+// their stream, numbered as in the multiplexed stream of the attach endpoint
+const (
+	wsStdin  byte = 0
+	wsStdout byte = 1
+	wsStderr byte = 2
+	// the client sends wsCredit frames to grant the daemon the right to
+	// send more bytes of output, their count follows as a 4 bytes big
+	// endian integer
+	wsCredit byte = 3
+)
+
+// wsStdinFrames is the number of frames of stdin buffered while the
+// container does not read them. The frames of stdin received while the
+// buffer is full are dropped, so that the credit frames which follow them
+// are still read.
+const wsStdinFrames = 64
+
+// wsMaxCredit caps the credit granted by the client, so that it fits in an
+// int on any platform
+const wsMaxCredit = math.MaxInt32
+
+// wsAttach carries the streams of an attach job over a websocket in binary
+// frames, so the output is not mangled as text. With channels the frames
+// carry the number of their stream, and with flow control the output is
+// throttled to the credit the client granted.
+type wsAttach struct {
+	ws          *websocket.Conn
+	channels    bool
+	flowControl bool
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	credit int
+	closed bool
+}
+
+// newWsAttach returns the streams of the websocket, flow control is enabled
+// when the initial window is positive
+func newWsAttach(ws *websocket.Conn, channels bool, window int) *wsAttach {
+	a := &wsAttach{
+		ws:          ws,
+		channels:    channels,
+		flowControl: window > 0,
+		credit:      window,
+	}
+	a.cond = sync.NewCond(&a.mu)
+	return a
+}
+
+// Stdin returns the input of the client, Stdin or Discard has to be called
+// for the frames of the client to be read
+func (a *wsAttach) Stdin() io.Reader {
+	r, w := io.Pipe()
+	frames := make(chan []byte, wsStdinFrames)
+	go func() {
+		for frame := range frames {
+			if _, err := w.Write(frame); err != nil {
+				break
+			}
+		}
+		w.Close()
+		// unblock the reader of the frames
+		for range frames {
+		}
+	}()
+	go a.readFrames(frames)
+	return r
+}
+
+// Discard reads the frames of the client, dropping its input, when stdin is
+// not attached
+func (a *wsAttach) Discard() {
+	go a.readFrames(nil)
+}
+
+// Stdout returns the writer of the output of the container
+func (a *wsAttach) Stdout() io.Writer {
+	return &wsStreamWriter{a, wsStdout}
+}
+
+// Stderr returns the writer of the errors of the container, they are mixed
+// with the output without channels
+func (a *wsAttach) Stderr() io.Writer {
+	return &wsStreamWriter{a, wsStderr}
+}
+
+// readFrames reads the frames of the client until the websocket is closed,
+// it passes the input to frames, unless nil, and handles the credit
+func (a *wsAttach) readFrames(frames chan<- []byte) {
+	defer a.close()
+	if frames != nil {
+		defer close(frames)
+	}
+	input := func(p []byte) {
+		if frames == nil {
+			return
+		}
+		select {
+		case frames <- p:
+		default:
+			log.Errorf("Dropping %d bytes of input of the websocket, the container doesn't read its input", len(p))
+		}
+	}
+	for {
+		var frame []byte
+		if err := websocket.Message.Receive(a.ws, &frame); err != nil {
+			if err != io.EOF {
+				log.Debugf("Error reading the websocket: %s", err)
+			}
+			return
+		}
+		if !a.channels {
+			input(frame)
+			continue
+		}
+		if len(frame) == 0 {
+			continue
+		}
+		switch frame[0] {
+		case wsStdin:
+			input(frame[1:])
+		case wsCredit:
+			if len(frame) != 5 {
+				log.Errorf("Invalid websocket credit frame of %d bytes", len(frame))
+				return
+			}
+			a.grant(binary.BigEndian.Uint32(frame[1:]))
+		default:
+			log.Debugf("Ignoring websocket frame of unknown stream %d", frame[0])
+		}
+	}
+}
+
+func (a *wsAttach) grant(credit uint32) {
+	a.mu.Lock()
+	if total := int64(a.credit) + int64(credit); total > wsMaxCredit {
+		a.credit = wsMaxCredit
+	} else {
+		a.credit = int(total)
+	}
+	a.cond.Broadcast()
+	a.mu.Unlock()
+}
+
+func (a *wsAttach) close() {
+	a.mu.Lock()
+	a.closed = true
+	a.cond.Broadcast()
+	a.mu.Unlock()
+}
+
+// reserve waits for credit and takes up to max bytes of it
+func (a *wsAttach) reserve(max int) (int, error) {
+	if !a.flowControl {
+		return max, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for a.credit == 0 && !a.closed {
+		a.cond.Wait()
+	}
+	if a.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if max > a.credit {
+		max = a.credit
+	}
+	a.credit -= max
+	return max, nil
+}
+
+// send sends p in a binary frame, prefixed with its stream with channels
+func (a *wsAttach) send(stream byte, p []byte) error {
+	if !a.channels {
+		return websocket.Message.Send(a.ws, p)
+	}
+	frame := make([]byte, len(p)+1)
+	frame[0] = stream
+	copy(frame[1:], p)
+	return websocket.Message.Send(a.ws, frame)
+}
+
+type wsStreamWriter struct {
+	a      *wsAttach
+	stream byte
+}
+
+func (w *wsStreamWriter) Write(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		size, err := w.a.reserve(len(p) - n)
+		if err != nil {
+			return n, err
+		}
+		if err := w.a.send(w.stream, p[n:n+size]); err != nil {
+			return n, err
+		}
+		n += size
+	}
+	return n, nil
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"code.google.com/p/go.net/websocket"
+)
+
+func TestWsAttachChannelsAndFlowControl(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	input := make(chan string, 1)
+	srv := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		a := newWsAttach(ws, true, 4)
+		stdin := a.Stdin()
+		go func() {
+			buf := make([]byte, 16)
+			n, _ := stdin.Read(buf)
+			input <- string(buf[:n])
+		}()
+		fmt.Fprint(a.Stdout(), "hello")
+		fmt.Fprint(a.Stderr(), "!")
+		<-done
+	}))
+	defer srv.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	receive := func(expected []byte) {
+		var frame []byte
+		ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if err := websocket.Message.Receive(ws, &frame); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(frame, expected) {
+			t.Fatalf("Expected the frame %q, got %q", expected, frame)
+		}
+	}
+
+	// the output is throttled to the window of 4 bytes
+	receive([]byte{wsStdout, 'h', 'e', 'l', 'l'})
+	if err := websocket.Message.Send(ws, []byte{wsCredit, 0, 0, 0, 2}); err != nil {
+		t.Fatal(err)
+	}
+	receive([]byte{wsStdout, 'o'})
+	receive([]byte{wsStderr, '!'})
+
+	if err := websocket.Message.Send(ws, []byte{wsStdin, 'l', 's'}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case in := <-input:
+		if in != "ls" {
+			t.Fatalf("Expected the input ls, got %q", in)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The input was not received")
+	}
+}
+
+func TestWsAttachBinary(t *testing.T) {
+	srv := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		a := newWsAttach(ws, false, 0)
+		a.Discard()
+		a.Stdout().Write([]byte{0xff, 0xfe, 0x00})
+	}))
+	defer srv.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	var (
+		frame       []byte
+		payloadType byte
+	)
+	codec := websocket.Codec{Unmarshal: func(data []byte, t byte, v interface{}) error {
+		frame, payloadType = data, t
+		return nil
+	}}
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := codec.Receive(ws, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(frame, []byte{0xff, 0xfe, 0x00}) {
+		t.Fatalf("Expected the binary output to be kept, got %q", frame)
+	}
+	if payloadType != websocket.BinaryFrame {
+		t.Fatalf("Expected a binary frame, got the payload type %d", payloadType)
+	}
+}
+
+func TestWsAttachCreditWithFullStdin(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	srv := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		a := newWsAttach(ws, true, 1)
+		// the input is never read
+		a.Stdin()
+		fmt.Fprint(a.Stdout(), "ab")
+		<-done
+	}))
+	defer srv.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var frame []byte
+	if err := websocket.Message.Receive(ws, &frame); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2*wsStdinFrames; i++ {
+		if err := websocket.Message.Send(ws, []byte{wsStdin, 'x'}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the credit frame is read behind the input which filled the buffer
+	if err := websocket.Message.Send(ws, []byte{wsCredit, 0xff, 0xff, 0xff, 0xff}); err != nil {
+		t.Fatal(err)
+	}
+	if err := websocket.Message.Receive(ws, &frame); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(frame, []byte{wsStdout, 'b'}) {
+		t.Fatalf("Expected the rest of the output, got %q", frame)
+	}
+}
+
+func TestWsAttachGrantOverflow(t *testing.T) {
+	a := newWsAttach(nil, true, 1)
+	a.grant(0xffffffff)
+	a.grant(0xffffffff)
+	if a.credit != wsMaxCredit {
+		t.Fatalf("Expected the credit to be capped to %d, got %d", wsMaxCredit, a.credit)
+	}
+}
@@ -0,0 +1,127 @@
+package types
+
+import (
+	"time"
+
+	"github.com/docker/docker/nat"
+	"github.com/docker/docker/runconfig"
+)
+
+// Container is a container as listed by the API, Created is a unix
+// timestamp and the sizes are only set when they are requested
+type Container struct {
+	ID         string `json:"Id"`
+	Names      []string
+	Image      string
+	Command    string
+	Created    int64
+	Status     string
+	Ports      []Port
+	SizeRw     int64 `json:",omitempty"`
+	SizeRootFs int64 `json:",omitempty"`
+}
+
+// Port is a port of a container, PublicPort is only set when the port is
+// published on the host
+type Port struct {
+	IP          string `json:",omitempty"`
+	PrivatePort int
+	PublicPort  int `json:",omitempty"`
+	Type        string
+}
+
+// ContainerJSON is a container as returned by the inspect of the API
+type ContainerJSON struct {
+	ID              string `json:"Id"`
+	Created         time.Time
+	Path            string
+	Args            []string
+	Config          *runconfig.Config
+	State           ContainerState
+	Image           string
+	NetworkSettings NetworkSettings
+	ResolvConfPath  string
+	HostnamePath    string
+	HostsPath       string
+	LogPath         string
+	Name            string
+	RestartCount    int
+	Driver          string
+	ExecDriver      string
+	MountLabel      string
+	ProcessLabel    string
+	Volumes         map[string]string
+	VolumesRW       map[string]bool
+	AppArmorProfile string
+	NoNewPrivileges bool
+	ExecIDs         []string
+	HostConfig      *runconfig.HostConfig
+}
+
+// ContainerState is the state of an inspected container, Health is only set
+// for a running container with a healthcheck
+type ContainerState struct {
+	Running    bool
+	Paused     bool
+	Restarting bool
+	OOMKilled  bool
+	Pid        int
+	ExitCode   int
+	Error      string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Health     *Health `json:",omitempty"`
+}
+
+// Health is the health of a container, as reported by the probes of its
+// healthcheck
+type Health struct {
+	Status        string
+	FailingStreak int
+	Log           []HealthcheckResult
+}
+
+// HealthcheckResult is the result of a probe of a healthcheck
+type HealthcheckResult struct {
+	Start    time.Time
+	End      time.Time
+	ExitCode int
+	Output   string
+}
+
+// NetworkSettings are the addresses of an inspected container on the network
+// of its network mode, and its other user-defined Networks keyed by name
+type NetworkSettings struct {
+	IPAddress              string
+	IPPrefixLen            int
+	MacAddress             string
+	LinkLocalIPv6Address   string
+	LinkLocalIPv6PrefixLen int
+	GlobalIPv6Address      string
+	GlobalIPv6PrefixLen    int
+	Gateway                string
+	IPv6Gateway            string
+	Bridge                 string
+	Ports                  nat.PortMap
+	Networks               map[string]*EndpointSettings `json:",omitempty"`
+}
+
+// EndpointSettings is the connection of a container to a user-defined
+// network, the addresses are only set while the container runs
+type EndpointSettings struct {
+	NetworkID            string
+	Aliases              []string `json:",omitempty"`
+	Interface            string
+	IPAddress            string
+	IPPrefixLen          int
+	MacAddress           string
+	GlobalIPv6Address    string
+	GlobalIPv6PrefixLen  int
+	RequestedIPAddress   string `json:",omitempty"`
+	RequestedIPv6Address string `json:",omitempty"`
+}
+
+// ContainerWaitResponse is returned when a waited container exits
+type ContainerWaitResponse struct {
+	StatusCode int
+}
@@ -0,0 +1,34 @@
+package types
+
+// ImageDelete is an image untagged or deleted, only one of the fields is set
+type ImageDelete struct {
+	Untagged string `json:",omitempty"`
+	Deleted  string `json:",omitempty"`
+}
+
+// ImageLayer is a layer of an image, as listed by the image inspect with
+// size, the command that created it is empty for an imported layer
+type ImageLayer struct {
+	ID        string `json:"Id"`
+	Size      int64
+	CreatedBy string
+}
+
+// ImagesPruneReport lists the images untagged and deleted by a prune and the
+// space reclaimed on the host
+type ImagesPruneReport struct {
+	ImagesDeleted  []ImageDelete
+	SpaceReclaimed uint64
+}
+
+// Image is an image as listed by the API, Created is a unix timestamp and
+// VirtualSize includes the size of the parent layers
+type Image struct {
+	ID          string `json:"Id"`
+	ParentID    string `json:"ParentId"`
+	RepoTags    []string
+	RepoDigests []string
+	Created     int64
+	Size        int64
+	VirtualSize int64
+}
@@ -0,0 +1,86 @@
+package types
+
+import "time"
+
+// NetworkCreate is the request to create a network
+type NetworkCreate struct {
+	Name     string
+	Driver   string
+	Options  map[string]string
+	IPAM     IPAM
+	DNS      *DNSConfig        `json:",omitempty"`
+	Internal bool              `json:",omitempty"`
+	Labels   map[string]string `json:",omitempty"`
+}
+
+// NetworkCreateResponse is returned on the creation of a network
+type NetworkCreateResponse struct {
+	ID string `json:"Id"`
+}
+
+// NetworkConnect is the request to connect a container to a network
+type NetworkConnect struct {
+	Container   string
+	Aliases     []string `json:",omitempty"`
+	IPAddress   string   `json:",omitempty"`
+	IPv6Address string   `json:",omitempty"`
+}
+
+// NetworkDisconnect is the request to disconnect a container from a network
+type NetworkDisconnect struct {
+	Container string
+}
+
+// IPAM describes the addressing of a network. The addresses are allocated
+// by the IPAM plugin Driver when it is set, which gets the Options.
+type IPAM struct {
+	Driver  string            `json:",omitempty"`
+	Options map[string]string `json:",omitempty"`
+	Config  []IPAMConfig
+}
+
+// IPAMConfig is a subnet of a network. Containers get their addresses from
+// IPRange, or from the whole subnet when it is not set.
+type IPAMConfig struct {
+	Subnet  string `json:",omitempty"`
+	IPRange string `json:",omitempty"`
+	Gateway string `json:",omitempty"`
+}
+
+// DNSConfig is the resolver configuration of the containers of a network,
+// they use the daemon or the host settings for the fields that are not set
+type DNSConfig struct {
+	Nameservers []string `json:",omitempty"`
+	Search      []string `json:",omitempty"`
+	Options     []string `json:",omitempty"`
+}
+
+// NetworkResource is a network as returned by the API
+type NetworkResource struct {
+	Name       string
+	ID         string `json:"Id"`
+	Driver     string
+	Options    map[string]string
+	IPAM       IPAM
+	Created    time.Time
+	DNS        *DNSConfig `json:",omitempty"`
+	Internal   bool
+	Labels     map[string]string `json:",omitempty"`
+	Containers map[string]EndpointResource
+	// DriverState is what the driver keeps about the network, it is only
+	// returned by a verbose inspect
+	DriverState map[string]string `json:",omitempty"`
+}
+
+// EndpointResource is a container connected to a network. Interface and
+// DriverInfo, the interface created by the driver in the running
+// container, are only returned by a verbose inspect.
+type EndpointResource struct {
+	Name        string
+	MacAddress  string
+	IPv4Address string
+	IPv6Address string            `json:",omitempty"`
+	Aliases     []string          `json:",omitempty"`
+	Interface   string            `json:",omitempty"`
+	DriverInfo  map[string]string `json:",omitempty"`
+}
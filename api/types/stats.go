@@ -2,7 +2,10 @@
 // consumers of the API stats endpoint.
 package types
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type ThrottlingData struct {
 	// Number of periods with throttling active
@@ -78,10 +81,86 @@ type Network struct {
 	TxDropped uint64 `json:"tx_dropped"`
 }
 
+type PidsStats struct {
+	// number of processes currently in the container
+	Current uint64 `json:"current"`
+	// maximum number of processes allowed, 0 when unlimited
+	Limit uint64 `json:"limit"`
+}
+
 type Stats struct {
-	Read        time.Time   `json:"read"`
-	Network     Network     `json:"network,omitempty"`
-	CpuStats    CpuStats    `json:"cpu_stats,omitempty"`
-	MemoryStats MemoryStats `json:"memory_stats,omitempty"`
-	BlkioStats  BlkioStats  `json:"blkio_stats,omitempty"`
+	// ID and Name identify the container of the stats
+	ID      string    `json:"id,omitempty"`
+	Name    string    `json:"name,omitempty"`
+	Read    time.Time `json:"read"`
+	Network Network   `json:"network,omitempty"`
+	// Networks holds the statistics of every network interface of the
+	// container, keyed by interface name
+	Networks    map[string]Network `json:"networks,omitempty"`
+	PreCpuStats CpuStats           `json:"precpu_stats,omitempty"`
+	CpuStats    CpuStats           `json:"cpu_stats,omitempty"`
+	MemoryStats MemoryStats        `json:"memory_stats,omitempty"`
+	BlkioStats  BlkioStats         `json:"blkio_stats,omitempty"`
+	PidsStats   PidsStats          `json:"pids_stats,omitempty"`
+}
+
+// StatsSample is a single sample of the resource usage of a container, with
+// the usage percentages computed from the stats and their previous cpu
+// usage.
+type StatsSample struct {
+	ID               string  `json:"id"`
+	Name             string  `json:"name"`
+	CpuPercentage    float64 `json:"cpu_percent"`
+	Memory           uint64  `json:"memory_usage"`
+	MemoryLimit      uint64  `json:"memory_limit"`
+	MemoryPercentage float64 `json:"memory_percent"`
+	NetworkRx        uint64  `json:"network_rx_bytes"`
+	NetworkTx        uint64  `json:"network_tx_bytes"`
+	BlockRead        uint64  `json:"block_read_bytes"`
+	BlockWrite       uint64  `json:"block_write_bytes"`
+	PidsCurrent      uint64  `json:"pids_current"`
+	PidsLimit        uint64  `json:"pids_limit"`
+}
+
+// NewStatsSample computes the sample of the stats v
+func NewStatsSample(v *Stats) *StatsSample {
+	s := &StatsSample{
+		ID:          v.ID,
+		Name:        v.Name,
+		Memory:      v.MemoryStats.Usage,
+		MemoryLimit: v.MemoryStats.Limit,
+		PidsCurrent: v.PidsStats.Current,
+		PidsLimit:   v.PidsStats.Limit,
+	}
+	if v.MemoryStats.Limit != 0 {
+		s.MemoryPercentage = float64(v.MemoryStats.Usage) / float64(v.MemoryStats.Limit) * 100.0
+	}
+	if v.PreCpuStats.SystemUsage != 0 {
+		var (
+			// the change of the cpu usage of the container between the samples
+			cpuDelta = float64(v.CpuStats.CpuUsage.TotalUsage) - float64(v.PreCpuStats.CpuUsage.TotalUsage)
+			// the change of the cpu usage of the entire system
+			systemDelta = float64(v.CpuStats.SystemUsage) - float64(v.PreCpuStats.SystemUsage)
+		)
+		if systemDelta > 0.0 && cpuDelta > 0.0 {
+			s.CpuPercentage = (cpuDelta / systemDelta) * float64(len(v.CpuStats.CpuUsage.PercpuUsage)) * 100.0
+		}
+	}
+	// the older daemons only report a single network interface
+	if len(v.Networks) == 0 {
+		s.NetworkRx, s.NetworkTx = v.Network.RxBytes, v.Network.TxBytes
+	}
+	for _, n := range v.Networks {
+		s.NetworkRx += n.RxBytes
+		s.NetworkTx += n.TxBytes
+	}
+	for _, entry := range v.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			s.BlockRead += entry.Value
+		case "write":
+			s.BlockWrite += entry.Value
+		}
+	}
+	return s
 }
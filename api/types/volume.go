@@ -0,0 +1,28 @@
+package types
+
+// VolumeCreate is the request to create a named volume, the DriverOpts are
+// passed to the driver
+type VolumeCreate struct {
+	Name       string
+	Driver     string
+	DriverOpts map[string]string `json:",omitempty"`
+	Labels     map[string]string `json:",omitempty"`
+}
+
+// Volume is a volume as returned by the API, the anonymous volumes are
+// named by their ID. Mountpoint is the path of the volume on the host, it
+// may be empty for a volume of a plugin which is not mounted.
+type Volume struct {
+	Name       string
+	Driver     string
+	Mountpoint string
+	Options    map[string]string `json:",omitempty"`
+	Labels     map[string]string `json:",omitempty"`
+}
+
+// VolumesPruneReport lists the volumes removed by a prune and the space
+// reclaimed on the host, which is only counted for the local volumes
+type VolumesPruneReport struct {
+	VolumesDeleted []string
+	SpaceReclaimed uint64
+}
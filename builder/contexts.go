@@ -0,0 +1,126 @@
+package builder
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/chrootarchive"
+	"github.com/docker/docker/pkg/tarsum"
+	"github.com/docker/docker/pkg/urlutil"
+	"github.com/docker/docker/utils"
+)
+
+// buildContext is a named context of the build given with --build-context,
+// either the name of an image or a directory with the hash of its content.
+type buildContext struct {
+	image string
+	dir   string
+	hash  string
+}
+
+// setupBuildContexts prepares the named contexts of the build: the image
+// contexts are only resolved by FROM and COPY --from, the directories sent
+// with the context are moved out of it and the remote ones are fetched.
+func (b *Builder) setupBuildContexts() error {
+	b.contexts = map[string]*buildContext{}
+	if len(b.BuildContexts) == 0 {
+		return nil
+	}
+
+	var err error
+	if b.contextsDir, err = ioutil.TempDir("", "docker-build-contexts"); err != nil {
+		return err
+	}
+	sent := filepath.Join(b.contextPath, api.BuildContextsDir)
+	if _, err := os.Stat(sent); err == nil {
+		if err := os.Rename(sent, filepath.Join(b.contextsDir, "local")); err != nil {
+			return err
+		}
+	}
+
+	for name, src := range b.BuildContexts {
+		if err := api.ValidateBuildContextName(name); err != nil {
+			return err
+		}
+		ctx := &buildContext{}
+		switch {
+		case strings.HasPrefix(src, api.ImageBuildContextPrefix):
+			if ctx.image = strings.TrimPrefix(src, api.ImageBuildContextPrefix); ctx.image == "" {
+				return fmt.Errorf("Invalid build context %s, no image given", name)
+			}
+		case src == api.LocalBuildContext:
+			ctx.dir = filepath.Join(b.contextsDir, "local", name)
+			if err := os.MkdirAll(ctx.dir, 0755); err != nil {
+				return err
+			}
+		case urlutil.IsGitURL(src):
+			root := filepath.Join(b.contextsDir, "git", name)
+			if err := os.MkdirAll(root, 0755); err != nil {
+				return err
+			}
+			if ctx.dir, err = utils.GitClone(src, root); err != nil {
+				return err
+			}
+		case urlutil.IsURL(src):
+			ctx.dir = filepath.Join(b.contextsDir, "remote", name)
+			if err := b.downloadBuildContext(src, ctx.dir); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("Invalid build context %s, expected an image, a git repository or the URL of an archive, got %s", name, utils.RedactGitURL(src))
+		}
+		if ctx.dir != "" {
+			if ctx.hash, err = dirHash(ctx.dir); err != nil {
+				return err
+			}
+		}
+		b.contexts[strings.ToLower(name)] = ctx
+	}
+	return nil
+}
+
+// downloadBuildContext extracts the archive of a remote build context, which
+// may be compressed, to dir
+func (b *Builder) downloadBuildContext(url, dir string) error {
+	resp, err := utils.Download(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := chrootarchive.Untar(resp.Body, dir, nil); err != nil {
+		return fmt.Errorf("Error extracting the build context %s, it must be a tar archive: %v", utils.RedactGitURL(url), err)
+	}
+	return nil
+}
+
+// findBuildContext returns the named build context name, or nil
+func (b *Builder) findBuildContext(name string) *buildContext {
+	return b.contexts[strings.ToLower(name)]
+}
+
+// dirHash returns the tarsum of the content of dir, the cache key of the
+// files copied from a named build context
+func dirHash(dir string) (string, error) {
+	tar, err := archive.Tar(dir, archive.Uncompressed)
+	if err != nil {
+		return "", err
+	}
+	defer tar.Close()
+	ts, err := tarsum.NewTarSum(tar, true, tarsum.Version1)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(ioutil.Discard, ts); err != nil {
+		return "", err
+	}
+	return ts.Sum(nil), nil
+}
@@ -10,14 +10,18 @@ package builder
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/nat"
 	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/pkg/signal"
 	"github.com/docker/docker/runconfig"
 )
 
@@ -73,6 +77,32 @@ func env(b *Builder, args []string, attributes map[string]bool, original string)
 	return b.commit("", b.Config.Cmd, commitStr)
 }
 
+// LABEL some json data describing the image
+//
+// Sets the label foo to bar, with the same forms as ENV.
+//
+func label(b *Builder, args []string, attributes map[string]bool, original string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("LABEL requires at least one argument")
+	}
+	if len(args)%2 != 0 {
+		// should never get here, but just in case
+		return fmt.Errorf("Bad input to LABEL, too many args")
+	}
+
+	commitStr := "LABEL"
+	if b.Config.Labels == nil {
+		b.Config.Labels = map[string]string{}
+	}
+	for j := 0; j < len(args); j += 2 {
+		// name  ==> args[j]
+		// value ==> args[j+1]
+		commitStr += " " + args[j] + "=" + args[j+1]
+		b.Config.Labels[args[j]] = args[j+1]
+	}
+	return b.commit("", b.Config.Cmd, commitStr)
+}
+
 // MAINTAINER some text <maybe@an.email.address>
 //
 // Sets the maintainer metadata.
@@ -99,34 +129,78 @@ func add(b *Builder, args []string, attributes map[string]bool, original string)
 }
 
 // COPY foo /path
+// COPY --from=stage foo /path
 //
-// Same as 'ADD' but without the tar and remote url handling.
+// Same as 'ADD' but without the tar and remote url handling. With --from the
+// files are copied from a previous stage of the build, a named build context
+// or an image.
 //
 func dispatchCopy(b *Builder, args []string, attributes map[string]bool, original string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("COPY requires at least two arguments")
 	}
 
+	var from string
+	for _, flag := range b.flags {
+		if !strings.HasPrefix(flag, "--from=") {
+			return fmt.Errorf("Unknown flag for COPY: %s", flag)
+		}
+		if from = strings.TrimPrefix(flag, "--from="); from == "" {
+			return fmt.Errorf("COPY --from requires a stage or an image")
+		}
+	}
+	if from != "" {
+		return b.copyFromImage(args, from)
+	}
+
 	return b.runContextCommand(args, false, false, "COPY")
 }
 
 // FROM imagename
+// FROM imagename AS name
 //
-// This sets the image the dockerfile will build on top of.
+// This sets the image the dockerfile will build on top of. Each FROM starts
+// a new stage of the build, the image of a named stage can be used by the
+// following FROM and COPY --from instructions. A named build context
+// referring to an image replaces the image of the same name.
 //
 func from(b *Builder, args []string, attributes map[string]bool, original string) error {
 	if len(args) != 1 {
 		return fmt.Errorf("FROM requires one argument")
 	}
 
-	name := args[0]
+	parts := strings.Fields(args[0])
+	if len(parts) != 1 && (len(parts) != 3 || !strings.EqualFold(parts[1], "as")) {
+		return fmt.Errorf("FROM requires either one or three arguments, the third one being the name of the stage")
+	}
+	name := parts[0]
+
+	if err := b.startStage(parts); err != nil {
+		return err
+	}
 
 	if name == NoBaseImageSpecifier {
 		b.image = ""
+		b.baseImage = ""
 		b.noBaseImage = true
 		return nil
 	}
 
+	if stage := b.findStage(name); stage != nil {
+		image, err := b.Daemon.Graph().Get(stage.image)
+		if err != nil {
+			return err
+		}
+		return b.processImageFrom(image)
+	}
+
+	if ctx := b.findBuildContext(name); ctx != nil {
+		if ctx.image == "" {
+			return fmt.Errorf("The build context %s is not an image, it can only be used with COPY --from", name)
+		}
+		name = ctx.image
+	}
+
 	image, err := b.Daemon.Repositories().LookupImage(name)
 	if b.Pull {
 		image, err = b.pullImage(name)
@@ -211,6 +285,11 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 		return fmt.Errorf("Please provide a source image with `from` prior to run")
 	}
 
+	binds, targets, mountEnv, err := b.runMounts()
+	if err != nil {
+		return err
+	}
+
 	args = handleJsonArgs(args, attributes)
 
 	if len(args) == 1 {
@@ -227,13 +306,21 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 	}
 
 	cmd := b.Config.Cmd
-	// set Cmd manually, this is special case only for Dockerfiles
-	b.Config.Cmd = config.Cmd
 	runconfig.Merge(b.Config, config)
 
 	defer func(cmd []string) { b.Config.Cmd = cmd }(cmd)
 
-	log.Debugf("[BUILDER] Command to be executed: %v", b.Config.Cmd)
+	// The build-time variables are part of the command used for the cache
+	// lookup and committed in the container config, the "|N" prefix cannot
+	// be the start of a command and keeps them apart from its arguments.
+	buildEnv := b.buildArgsEnv(false)
+	saveCmd := config.Cmd
+	if cacheEnv := b.buildArgsEnv(true); len(cacheEnv) > 0 {
+		saveCmd = append(append([]string{fmt.Sprintf("|%d", len(cacheEnv))}, cacheEnv...), config.Cmd...)
+	}
+	b.Config.Cmd = saveCmd
+
+	log.Debugf("[BUILDER] Command to be executed: %v", config.Cmd)
 
 	hit, err := b.probeCache()
 	if err != nil {
@@ -243,7 +330,14 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 		return nil
 	}
 
-	c, err := b.create()
+	// set Cmd manually, this is special case only for Dockerfiles
+	b.Config.Cmd = config.Cmd
+	env := b.Config.Env
+	if len(buildEnv) > 0 || len(mountEnv) > 0 {
+		b.Config.Env = append(append(append([]string{}, env...), buildEnv...), mountEnv...)
+	}
+
+	c, err := b.create(&runconfig.HostConfig{Binds: binds, NetworkMode: b.NetworkMode, ExtraHosts: b.ExtraHosts})
 	if err != nil {
 		return err
 	}
@@ -253,10 +347,27 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 	c.Mount()
 	defer c.Unmount()
 
+	mountpoints, err := missingMountpoints(c, targets)
+	if err != nil {
+		return err
+	}
+
 	err = b.run(c)
 	if err != nil {
 		return err
 	}
+
+	// the secrets and SSH agents are not committed, nor are their mountpoints
+	for _, p := range mountpoints {
+		if err := os.RemoveAll(p); err != nil {
+			return err
+		}
+	}
+
+	// the build-time variables are not persisted in the environment of
+	// the image
+	b.Config.Env = env
+	b.Config.Cmd = saveCmd
 	if err := b.commit(c.ID, cmd, "run"); err != nil {
 		return err
 	}
@@ -404,6 +515,114 @@ func volume(b *Builder, args []string, attributes map[string]bool, original stri
 	return nil
 }
 
+// STOPSIGNAL SIGKILL
+//
+// Set the signal that will be used to stop the container. Accepts either a
+// signal name (with or without the SIG prefix) or an unsigned number.
+func stopSignal(b *Builder, args []string, attributes map[string]bool, original string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("STOPSIGNAL requires exactly one argument")
+	}
+
+	sig := args[0]
+	if _, err := signal.ParseSignal(sig); err != nil {
+		return err
+	}
+
+	b.Config.StopSignal = sig
+	return b.commit("", b.Config.Cmd, fmt.Sprintf("STOPSIGNAL %v", args))
+}
+
+// HEALTHCHECK NONE
+// HEALTHCHECK [--interval=30s] [--timeout=30s] [--retries=3] CMD command
+//
+// Set the command which checks that the containers of the image work, the
+// command has the forms of CMD. NONE disables the healthcheck of the base
+// image.
+func healthcheck(b *Builder, args []string, attributes map[string]bool, original string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("HEALTHCHECK requires an argument")
+	}
+
+	health := &runconfig.HealthConfig{}
+	switch strings.ToUpper(args[0]) {
+	case "NONE":
+		if len(args) != 1 || len(b.flags) > 0 {
+			return fmt.Errorf("HEALTHCHECK NONE takes no arguments")
+		}
+		health.Test = []string{"NONE"}
+	case "CMD":
+		if len(args) < 2 {
+			return fmt.Errorf("HEALTHCHECK CMD requires a command")
+		}
+		if attributes["json"] {
+			health.Test = append([]string{"CMD"}, args[1:]...)
+		} else {
+			health.Test = []string{"CMD-SHELL", args[1]}
+		}
+	default:
+		return fmt.Errorf("Unknown type %q for HEALTHCHECK, it must be NONE or CMD", args[0])
+	}
+
+	for _, flag := range b.flags {
+		parts := strings.SplitN(flag, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return fmt.Errorf("HEALTHCHECK %s requires a value", parts[0])
+		}
+		var err error
+		switch parts[0] {
+		case "--interval":
+			health.Interval, err = parseHealthDuration(parts[1])
+		case "--timeout":
+			health.Timeout, err = parseHealthDuration(parts[1])
+		case "--retries":
+			health.Retries, err = strconv.Atoi(parts[1])
+			if err == nil && health.Retries < 1 {
+				err = fmt.Errorf("must be at least 1")
+			}
+		default:
+			return fmt.Errorf("Unknown flag for HEALTHCHECK: %s", parts[0])
+		}
+		if err != nil {
+			return fmt.Errorf("Invalid HEALTHCHECK %s: %v", parts[0], err)
+		}
+	}
+
+	b.Config.Healthcheck = health
+	return b.commit("", b.Config.Cmd, fmt.Sprintf("HEALTHCHECK %q", health.Test))
+}
+
+func parseHealthDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return d, nil
+}
+
+// ARG name[=default value]
+//
+// Declare a build-time variable, set with docker build --build-arg or to its
+// default value. The variable is set in the environment of the following RUN
+// instructions and is used in their cache key, it is not persisted in the
+// image.
+func arg(b *Builder, args []string, attributes map[string]bool, original string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("ARG requires exactly one argument definition")
+	}
+
+	name, value, hasDefault := parseBuildArg(args[0])
+	if name == "" {
+		return fmt.Errorf("ARG names can not be blank")
+	}
+
+	b.declareBuildArg(name, value, hasDefault)
+	return b.commit("", b.Config.Cmd, fmt.Sprintf("ARG %s", args[0]))
+}
+
 // INSERT is no longer accepted, but we still parse it.
 func insert(b *Builder, args []string, attributes map[string]bool, original string) error {
 	return fmt.Errorf("INSERT has been deprecated. Please use ADD instead")
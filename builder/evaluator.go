@@ -25,7 +25,10 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/api"
@@ -33,6 +36,7 @@ import (
 	"github.com/docker/docker/builder/parser"
 	"github.com/docker/docker/daemon"
 	"github.com/docker/docker/engine"
+	imagepkg "github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/common"
 	"github.com/docker/docker/pkg/fileutils"
 	"github.com/docker/docker/pkg/symlink"
@@ -48,33 +52,40 @@ var (
 
 // Environment variable interpolation will happen on these statements only.
 var replaceEnvAllowed = map[string]struct{}{
-	command.Env:     {},
-	command.Add:     {},
-	command.Copy:    {},
-	command.Workdir: {},
-	command.Expose:  {},
-	command.Volume:  {},
-	command.User:    {},
+	command.Env:        {},
+	command.Add:        {},
+	command.Copy:       {},
+	command.Workdir:    {},
+	command.Expose:     {},
+	command.Volume:     {},
+	command.User:       {},
+	command.StopSignal: {},
+	command.Arg:        {},
+	command.Label:      {},
 }
 
 var evaluateTable map[string]func(*Builder, []string, map[string]bool, string) error
 
 func init() {
 	evaluateTable = map[string]func(*Builder, []string, map[string]bool, string) error{
-		command.Env:        env,
-		command.Maintainer: maintainer,
-		command.Add:        add,
-		command.Copy:       dispatchCopy, // copy() is a go builtin
-		command.From:       from,
-		command.Onbuild:    onbuild,
-		command.Workdir:    workdir,
-		command.Run:        run,
-		command.Cmd:        cmd,
-		command.Entrypoint: entrypoint,
-		command.Expose:     expose,
-		command.Volume:     volume,
-		command.User:       user,
-		command.Insert:     insert,
+		command.Env:         env,
+		command.Maintainer:  maintainer,
+		command.Add:         add,
+		command.Copy:        dispatchCopy, // copy() is a go builtin
+		command.From:        from,
+		command.Onbuild:     onbuild,
+		command.Workdir:     workdir,
+		command.Run:         run,
+		command.Cmd:         cmd,
+		command.Entrypoint:  entrypoint,
+		command.Expose:      expose,
+		command.Volume:      volume,
+		command.User:        user,
+		command.Insert:      insert,
+		command.StopSignal:  stopSignal,
+		command.Arg:         arg,
+		command.Label:       label,
+		command.Healthcheck: healthcheck,
 	}
 }
 
@@ -93,11 +104,54 @@ type Builder struct {
 	UtilizeCache bool
 	cacheBusted  bool
 
+	// the images given with --cache-from, when set only their layers are
+	// used as the cache of the build
+	CacheFrom       []string
+	cacheFromLayers map[string]*imagepkg.Image
+
+	// the --secret files, they are only mounted in the RUN containers
+	// which use them and never committed
+	Secrets    map[string][]byte
+	secretsDir string
+
+	// the SSH agents forwarded with --ssh over the session SessionID of
+	// the client, and the sockets of the daemon forwarding them, by id
+	SSHAgents  []string
+	SessionID  string
+	SSHSockets map[string]string
+
+	// the named contexts given with --build-context, by name: an image
+	// (docker-image://name), a git repository, the URL of an archive or
+	// api.LocalBuildContext for the directories sent with the context
+	BuildContexts map[string]string
+	contexts      map[string]*buildContext
+	contextsDir   string
+
 	// controls how images and containers are handled between steps.
 	Remove      bool
 	ForceRemove bool
 	Pull        bool
 
+	// squash the layers produced by the build into a single layer
+	Squash bool
+
+	// the number of independent stages of a multi-stage build built at
+	// once, the stages are built one after the other when it is 1 or less
+	Parallelism int
+
+	// send the structured progress of each step to the JSON streams
+	BuildSteps bool
+
+	// build reproducible layers: when set, it is the creation time of the
+	// images and the modification time of the files of their layers, given
+	// to the RUN instructions as SOURCE_DATE_EPOCH
+	SourceDateEpoch *time.Time
+
+	// the network of the RUN containers, the default bridge when empty, and
+	// the host-to-IP mappings added to their /etc/hosts
+	NetworkMode runconfig.NetworkMode
+	ExtraHosts  []string
+
 	// set this to true if we want the builder to not commit between steps.
 	// This is useful when we only want to use the evaluator table to generate
 	// the final configs of the Dockerfile but dont want the layers
@@ -118,11 +172,58 @@ type Builder struct {
 	dockerfileName string        // name of Dockerfile
 	dockerfile     *parser.Node  // the syntax tree of the dockerfile
 	image          string        // image name for commit processing
+	baseImage      string        // the image of the FROM of the current stage
 	maintainer     string        // maintainer name. could probably be removed.
 	cmdSet         bool          // indicates is CMD was set in current Dockerfile
 	context        tarsum.TarSum // the context is a tarball that is uploaded by the client
 	contextPath    string        // the path of the temporary directory the local context is unpacked to (server side)
 	noBaseImage    bool          // indicates that this build does not start from any base image, but is being built from an empty file system.
+
+	// the build-time variables given with --build-arg, only the variables
+	// declared with ARG (or predefined) are used, and the defaults of the
+	// ARG instructions
+	buildArgs         map[string]string
+	argDefaults       map[string]string
+	allowedBuildArgs  map[string]bool // declared with ARG so far
+	declaredBuildArgs map[string]bool // declared in any stage of the build
+
+	flags       []string     // the flags of the instruction being dispatched
+	instruction string       // the instruction being dispatched, as displayed
+	stepCached  bool         // the step being dispatched used the cache
+	stages      []buildStage // the completed stages of a multi-stage build
+	stageName   string       // the name of the current stage, if any
+	stageCount  int          // the number of FROM instructions processed
+}
+
+// buildStage is a stage of a multi-stage build, each FROM instruction starts
+// a new stage and only the last stage is the result of the build. The files
+// of the previous stages are used by COPY --from.
+type buildStage struct {
+	name  string
+	image string
+}
+
+// The proxy variables can be given with --build-arg without being declared
+// with ARG in the Dockerfile. Unless they are declared, the proxy variables
+// are left out of the cache key of the RUN instructions, the same build
+// behind another proxy still uses the cache.
+var builtinAllowedBuildArgs = map[string]bool{
+	"HTTP_PROXY":  true,
+	"http_proxy":  true,
+	"HTTPS_PROXY": true,
+	"https_proxy": true,
+	"FTP_PROXY":   true,
+	"ftp_proxy":   true,
+	"NO_PROXY":    true,
+	"no_proxy":    true,
+
+	"SOURCE_DATE_EPOCH": true,
+}
+
+// isProxyBuildArg returns whether key is one of the predefined proxy
+// variables
+func isProxyBuildArg(key string) bool {
+	return builtinAllowedBuildArgs[key] && key != "SOURCE_DATE_EPOCH"
 }
 
 // Run the builder with the context. This is the lynchpin of this package. This
@@ -134,7 +235,7 @@ type Builder struct {
 // * parse the dockerfile
 // * walk the parse tree and execute it by dispatching to handlers. If Remove
 //   or ForceRemove is set, additional cleanup around containers happens after
-//   processing.
+//   processing. With Parallelism, the independent stages are built at once.
 // * Print a happy message and return the image ID.
 //
 func (b *Builder) Run(context io.Reader) (string, error) {
@@ -148,33 +249,137 @@ func (b *Builder) Run(context io.Reader) (string, error) {
 		}
 	}()
 
+	// the named contexts are moved out of the context before its
+	// .dockerignore is applied
+	defer func() {
+		if b.contextsDir != "" {
+			os.RemoveAll(b.contextsDir)
+		}
+	}()
+	if err := b.setupBuildContexts(); err != nil {
+		return "", err
+	}
+
 	if err := b.readDockerfile(); err != nil {
 		return "", err
 	}
 
+	stopForwarding, err := b.forwardSSHAgents()
+	if err != nil {
+		return "", err
+	}
+	defer stopForwarding()
+	if err := b.writeSecrets(); err != nil {
+		return "", err
+	}
+	defer func() {
+		if b.secretsDir != "" {
+			os.RemoveAll(b.secretsDir)
+		}
+	}()
+
 	// some initializations that would not have been supplied by the caller.
 	b.Config = &runconfig.Config{}
 	b.TmpContainers = map[string]struct{}{}
+	if b.buildArgs == nil {
+		b.buildArgs = map[string]string{}
+	}
+	if _, exists := b.buildArgs["SOURCE_DATE_EPOCH"]; b.SourceDateEpoch != nil && !exists {
+		b.buildArgs["SOURCE_DATE_EPOCH"] = strconv.FormatInt(b.SourceDateEpoch.Unix(), 10)
+	}
+	b.declaredBuildArgs = map[string]bool{}
+	b.resetBuildArgs()
 
-	for i, n := range b.dockerfile.Children {
-		if err := b.dispatch(i, n); err != nil {
+	if err := b.loadCacheFrom(); err != nil {
+		return "", err
+	}
+
+	if stages := splitStages(b.dockerfile.Children); b.Parallelism > 1 && len(stages) > 1 {
+		if err := b.buildStages(stages); err != nil {
+			return "", err
+		}
+	} else if err := b.dispatchNodes(0, b.dockerfile.Children); err != nil {
+		return "", err
+	}
+
+	if b.image == "" {
+		return "", fmt.Errorf("No image was generated. Is your Dockerfile empty?")
+	}
+
+	// check that all the given build-args were declared in the Dockerfile
+	unusedBuildArgs := []string{}
+	for k := range b.buildArgs {
+		if !b.declaredBuildArgs[k] && !builtinAllowedBuildArgs[k] {
+			unusedBuildArgs = append(unusedBuildArgs, k)
+		}
+	}
+	if len(unusedBuildArgs) > 0 {
+		sort.Strings(unusedBuildArgs)
+		return "", fmt.Errorf("One or more build-args %v were not consumed, failing build.", unusedBuildArgs)
+	}
+
+	if b.Squash {
+		if err := b.squash(); err != nil {
+			return "", err
+		}
+	}
+
+	fmt.Fprintf(b.OutStream, "Successfully built %s\n", common.TruncateID(b.image))
+	return b.image, nil
+}
+
+// dispatchNodes dispatches the instructions nodes, the first one being the
+// step first of the Dockerfile
+func (b *Builder) dispatchNodes(first int, nodes []*parser.Node) error {
+	for i, n := range nodes {
+		start := time.Now()
+		b.stepCached = false
+		err := b.dispatch(first+i, n)
+		b.reportStep(first+i, start, err)
+		if err != nil {
 			if b.ForceRemove {
 				b.clearTmp()
 			}
-			return "", err
+			return err
 		}
 		fmt.Fprintf(b.OutStream, " ---> %s\n", common.TruncateID(b.image))
 		if b.Remove {
 			b.clearTmp()
 		}
 	}
+	return nil
+}
 
-	if b.image == "" {
-		return "", fmt.Errorf("No image was generated. Is your Dockerfile empty?")
+// reportStep sends the structured progress of the step stepN, started at
+// start, to the JSON streams
+func (b *Builder) reportStep(stepN int, start time.Time, err error) {
+	if !b.BuildSteps || b.StreamFormatter == nil || b.OutOld == nil {
+		return
 	}
-
-	fmt.Fprintf(b.OutStream, "Successfully built %s\n", common.TruncateID(b.image))
-	return b.image, nil
+	step := &utils.JSONBuildStep{
+		Step:        stepN,
+		Instruction: b.instruction,
+		StageName:   b.stageName,
+		Cached:      b.stepCached,
+		Duration:    time.Since(start),
+	}
+	if b.stageCount > 0 {
+		step.Stage = b.stageCount - 1
+	}
+	if err != nil {
+		step.Error = err.Error()
+	} else if b.image != "" {
+		step.Image = b.image
+		if img, err := b.Daemon.Graph().Get(b.image); err == nil {
+			step.Size = img.Size
+		}
+		if b.SourceDateEpoch != nil {
+			if digest, err := b.layerDigest(b.image); err == nil {
+				step.Digest = digest
+			}
+		}
+	}
+	b.OutOld.Write(b.StreamFormatter.FormatBuildStep(step))
 }
 
 // Reads a Dockerfile from the current context. It assumes that the
@@ -262,7 +467,7 @@ func (b *Builder) dispatch(stepN int, ast *parser.Node) error {
 	attrs := ast.Attributes
 	original := ast.Original
 	strs := []string{}
-	msg := fmt.Sprintf("Step %d : %s", stepN, strings.ToUpper(cmd))
+	msg := strings.ToUpper(cmd)
 
 	if cmd == "onbuild" {
 		if ast.Next == nil {
@@ -273,6 +478,11 @@ func (b *Builder) dispatch(stepN int, ast *parser.Node) error {
 		msg += " " + ast.Value
 	}
 
+	b.flags = ast.Flags
+	if len(ast.Flags) > 0 {
+		msg += " " + strings.Join(ast.Flags, " ")
+	}
+
 	// count the number of nodes that we are going to traverse first
 	// so we can pre-create the argument and message array. This speeds up the
 	// allocation of those list a lot when they have a lot of arguments
@@ -301,7 +511,8 @@ func (b *Builder) dispatch(stepN int, ast *parser.Node) error {
 	}
 
 	msg += " " + strings.Join(msgList, " ")
-	fmt.Fprintln(b.OutStream, msg)
+	b.instruction = msg
+	fmt.Fprintf(b.OutStream, "Step %d : %s\n", stepN, msg)
 
 	// XXX yes, we skip any cmds that are not valid; the parser should have
 	// picked these out already.
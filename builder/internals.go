@@ -14,12 +14,15 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/autogen/dockerversion"
 	"github.com/docker/docker/builder/parser"
 	"github.com/docker/docker/daemon"
 	imagepkg "github.com/docker/docker/image"
@@ -33,6 +36,7 @@ import (
 	"github.com/docker/docker/pkg/tarsum"
 	"github.com/docker/docker/pkg/urlutil"
 	"github.com/docker/docker/registry"
+	"github.com/docker/docker/runconfig"
 	"github.com/docker/docker/utils"
 )
 
@@ -80,7 +84,7 @@ func (b *Builder) commit(id string, autoCmd []string, comment string) error {
 			return nil
 		}
 
-		container, err := b.create()
+		container, err := b.create(nil)
 		if err != nil {
 			return err
 		}
@@ -101,7 +105,12 @@ func (b *Builder) commit(id string, autoCmd []string, comment string) error {
 	autoConfig.Cmd = autoCmd
 
 	// Commit the container
-	image, err := b.Daemon.Commit(container, "", "", "", b.maintainer, true, &autoConfig)
+	var image *imagepkg.Image
+	if b.SourceDateEpoch != nil {
+		image, err = b.commitReproducible(container, &autoConfig)
+	} else {
+		image, err = b.Daemon.Commit(container, "", "", "", b.maintainer, true, &autoConfig)
+	}
 	if err != nil {
 		return err
 	}
@@ -109,6 +118,63 @@ func (b *Builder) commit(id string, autoCmd []string, comment string) error {
 	return nil
 }
 
+// commitReproducible commits the container like Daemon.Commit, with the
+// SourceDateEpoch as the creation time of the image and as the modification
+// time of all the files of its layer, so that the same changes always give
+// the same layer.
+func (b *Builder) commitReproducible(container *daemon.Container, config *runconfig.Config) (*imagepkg.Image, error) {
+	if err := container.Mount(); err != nil {
+		return nil, err
+	}
+	defer container.Unmount()
+
+	rwTar, err := container.ExportRw()
+	if err != nil {
+		return nil, err
+	}
+	defer rwTar.Close()
+	layer := archive.ResetModTimes(rwTar, *b.SourceDateEpoch)
+	defer layer.Close()
+
+	img := &imagepkg.Image{
+		Parent:          container.ImageID,
+		Container:       container.ID,
+		ContainerConfig: *container.Config,
+		Created:         b.SourceDateEpoch.UTC(),
+		DockerVersion:   dockerversion.VERSION,
+		Author:          b.maintainer,
+		Config:          config,
+		Architecture:    runtime.GOARCH,
+		OS:              runtime.GOOS,
+	}
+	if err := b.Daemon.Graph().Register(img, layer); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// layerDigest returns the tarsum of the layer of the image id, the digest
+// of its content regardless of the image metadata
+func (b *Builder) layerDigest(id string) (string, error) {
+	img, err := b.Daemon.Graph().Get(id)
+	if err != nil {
+		return "", err
+	}
+	layer, err := img.TarLayer()
+	if err != nil {
+		return "", err
+	}
+	defer layer.Close()
+	ts, err := tarsum.NewTarSum(layer, true, tarsum.Version1)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(ioutil.Discard, ts); err != nil {
+		return "", err
+	}
+	return ts.Sum(nil), nil
+}
+
 type copyInfo struct {
 	origPath   string
 	destPath   string
@@ -204,7 +270,7 @@ func (b *Builder) runContextCommand(args []string, allowRemote bool, allowDecomp
 	defer container.Unmount()
 
 	for _, ci := range copyInfos {
-		if err := b.addContext(container, ci.origPath, ci.destPath, ci.decompress); err != nil {
+		if err := b.addContext(container, b.contextPath, ci.origPath, ci.destPath, ci.decompress); err != nil {
 			return err
 		}
 	}
@@ -215,6 +281,174 @@ func (b *Builder) runContextCommand(args []string, allowRemote bool, allowDecomp
 	return nil
 }
 
+// copyFromImage is COPY --from, the sources are read from the filesystem of
+// a previous stage of the build, of a named build context or of an image
+// instead of the context. The cache is keyed by the ID of the source image,
+// or the hash of the named context, and the source paths.
+func (b *Builder) copyFromImage(args []string, from string) error {
+	var root, source string
+	if ctx := b.findBuildContext(from); ctx != nil && ctx.dir != "" && b.findStage(from) == nil {
+		root, source = ctx.dir, "context "+ctx.hash
+	} else {
+		image, err := b.copySourceImage(from)
+		if err != nil {
+			return err
+		}
+
+		driver := b.Daemon.GraphDriver()
+		if root, err = driver.Get(image.LayerID(), ""); err != nil {
+			return err
+		}
+		defer driver.Put(image.LayerID())
+		source = image.ID
+	}
+
+	dest := args[len(args)-1] // last one is always the dest
+	if !filepath.IsAbs(dest) {
+		hasSlash := strings.HasSuffix(dest, "/")
+		dest = filepath.Join("/", b.Config.WorkingDir, dest)
+		if hasSlash {
+			dest += "/"
+		}
+	}
+
+	origs := args[0 : len(args)-1]
+	srcs := []string{}
+	for _, orig := range origs {
+		matches, err := filepath.Glob(filepath.Join(root, filepath.Clean("/"+orig)))
+		if err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("%s: no such file or directory in %s", orig, from)
+		}
+		for _, match := range matches {
+			resolved, err := symlink.FollowSymlinkInScope(match, root)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, resolved)
+			if err != nil {
+				return err
+			}
+			srcs = append(srcs, rel)
+		}
+	}
+
+	if len(srcs) > 1 && !strings.HasSuffix(dest, "/") {
+		return fmt.Errorf("When using COPY with more than one source file, the destination must be a directory and end with a /")
+	}
+
+	b.Config.Image = b.image
+	cmd := b.Config.Cmd
+	b.Config.Cmd = []string{"/bin/sh", "-c", fmt.Sprintf("#(nop) COPY from %s %s in %s", source, strings.Join(srcs, " "), dest)}
+	defer func(cmd []string) { b.Config.Cmd = cmd }(cmd)
+
+	hit, err := b.probeCache()
+	if err != nil {
+		return err
+	}
+
+	if hit {
+		return nil
+	}
+
+	container, _, err := b.Daemon.Create(b.Config, nil, "")
+	if err != nil {
+		return err
+	}
+	b.TmpContainers[container.ID] = struct{}{}
+
+	if err := container.Mount(); err != nil {
+		return err
+	}
+	defer container.Unmount()
+
+	for _, src := range srcs {
+		if err := b.addContext(container, root, src, dest, false); err != nil {
+			return err
+		}
+	}
+
+	return b.commit(container.ID, cmd, fmt.Sprintf("COPY from %s %s in %s", from, strings.Join(origs, " "), dest))
+}
+
+// copySourceImage returns the image of COPY --from, a previous stage of the
+// build given by its name or index, or else an image, possibly given by a
+// named build context, which is pulled when it doesn't exist.
+func (b *Builder) copySourceImage(from string) (*imagepkg.Image, error) {
+	if stage := b.findStage(from); stage != nil {
+		return b.Daemon.Graph().Get(stage.image)
+	}
+	if b.stageName != "" && strings.EqualFold(from, b.stageName) {
+		return nil, fmt.Errorf("COPY --from cannot refer to the current build stage %s", b.stageName)
+	}
+	if ctx := b.findBuildContext(from); ctx != nil {
+		from = ctx.image
+	}
+
+	image, err := b.Daemon.Repositories().LookupImage(from)
+	if err != nil && b.Daemon.Graph().IsNotExist(err) {
+		image, err = b.pullImage(from)
+	}
+	return image, err
+}
+
+// startStage is called by FROM, it records the image of the previous stage
+// of the build, if any, and resets the state of the builder for the new one
+// named by the optional `AS name` of parts.
+func (b *Builder) startStage(parts []string) error {
+	var name string
+	if len(parts) == 3 {
+		name = strings.ToLower(parts[2])
+		if _, err := strconv.Atoi(name); err == nil {
+			return fmt.Errorf("Invalid name %s for a build stage, it cannot be a number", parts[2])
+		}
+		if name == b.stageName || b.findStage(name) != nil {
+			return fmt.Errorf("Duplicate name %s for a build stage", parts[2])
+		}
+	}
+
+	if len(b.stages) < b.stageCount {
+		if b.image == "" {
+			return fmt.Errorf("No image was generated for the build stage %d", b.stageCount-1)
+		}
+		b.stages = append(b.stages, buildStage{name: b.stageName, image: b.image})
+		b.Config = &runconfig.Config{}
+		b.image = ""
+		b.noBaseImage = false
+		b.maintainer = ""
+		b.cmdSet = false
+	}
+	b.stageCount++
+	b.stageName = name
+	return nil
+}
+
+// resetBuildArgs forgets the ARG instructions, only the predefined
+// variables are allowed at the start of a build
+func (b *Builder) resetBuildArgs() {
+	b.argDefaults = map[string]string{}
+	b.allowedBuildArgs = map[string]bool{}
+}
+
+// findStage returns the completed stage of the build named or numbered name
+func (b *Builder) findStage(name string) *buildStage {
+	if i, err := strconv.Atoi(name); err == nil {
+		if i >= 0 && i < len(b.stages) {
+			return &b.stages[i]
+		}
+		return nil
+	}
+	name = strings.ToLower(name)
+	for i := range b.stages {
+		if b.stages[i].name != "" && b.stages[i].name == name {
+			return &b.stages[i]
+		}
+	}
+	return nil
+}
+
 func calcCopyInfo(b *Builder, cmdName string, cInfos *[]*copyInfo, origPath string, destPath string, allowRemote bool, allowDecompression bool) error {
 
 	if origPath != "" && origPath[0] == '/' && len(origPath) > 1 {
@@ -452,6 +686,7 @@ func (b *Builder) pullImage(name string) (*imagepkg.Image, error) {
 
 func (b *Builder) processImageFrom(img *imagepkg.Image) error {
 	b.image = img.ID
+	b.baseImage = img.ID
 
 	if img.Config != nil {
 		b.Config = img.Config
@@ -470,6 +705,10 @@ func (b *Builder) processImageFrom(img *imagepkg.Image) error {
 	onBuildTriggers := b.Config.OnBuild
 	b.Config.OnBuild = []string{}
 
+	// the triggers are reported as part of the FROM step
+	instruction := b.instruction
+	defer func() { b.instruction = instruction }()
+
 	// parse the ONBUILD triggers by invoking the parser
 	for stepN, step := range onBuildTriggers {
 		ast, err := parser.Parse(strings.NewReader(step))
@@ -506,10 +745,19 @@ func (b *Builder) probeCache() (bool, error) {
 		return false, nil
 	}
 
-	cache, err := b.Daemon.ImageGetCached(b.image, b.Config)
-	if err != nil {
+	var (
+		cache *imagepkg.Image
+		err   error
+	)
+	if b.cacheFromLayers != nil {
+		cache = b.getCachedFrom()
+	} else if cache, err = b.Daemon.ImageGetCached(b.image, b.Config); err != nil {
 		return false, err
 	}
+	if cache != nil && b.SourceDateEpoch != nil && !cache.Created.Equal(*b.SourceDateEpoch) {
+		// only the images of the reproducible builds have reproducible layers
+		cache = nil
+	}
 	if cache == nil {
 		log.Debugf("[BUILDER] Cache miss")
 		b.cacheBusted = true
@@ -519,10 +767,268 @@ func (b *Builder) probeCache() (bool, error) {
 	fmt.Fprintf(b.OutStream, " ---> Using cache\n")
 	log.Debugf("[BUILDER] Use cached version")
 	b.image = cache.ID
+	b.stepCached = true
 	return true, nil
 }
 
-func (b *Builder) create() (*daemon.Container, error) {
+// squash replaces the layers produced on top of the base image of the last
+// stage of the build with a single layer. The intermediate images are kept,
+// they are still the cache of the following builds.
+func (b *Builder) squash() error {
+	if b.image == b.baseImage {
+		return nil
+	}
+	img, err := b.Daemon.Graph().Get(b.image)
+	if err != nil {
+		return err
+	}
+
+	driver := b.Daemon.GraphDriver()
+	layerFs, err := driver.Get(img.LayerID(), "")
+	if err != nil {
+		return err
+	}
+	defer driver.Put(img.LayerID())
+
+	var baseFs string
+	if b.baseImage == "" {
+		if baseFs, err = ioutil.TempDir("", "docker-squash"); err != nil {
+			return err
+		}
+		defer os.RemoveAll(baseFs)
+	} else {
+		baseImg, err := b.Daemon.Graph().Get(b.baseImage)
+		if err != nil {
+			return err
+		}
+		if baseFs, err = driver.Get(baseImg.LayerID(), ""); err != nil {
+			return err
+		}
+		defer driver.Put(baseImg.LayerID())
+	}
+
+	changes, err := archive.ChangesDirs(layerFs, baseFs)
+	if err != nil {
+		return err
+	}
+	layer, err := archive.ExportChanges(layerFs, changes)
+	if err != nil {
+		return err
+	}
+	defer layer.Close()
+	created := time.Now().UTC()
+	if b.SourceDateEpoch != nil {
+		layer = archive.ResetModTimes(layer, *b.SourceDateEpoch)
+		defer layer.Close()
+		created = b.SourceDateEpoch.UTC()
+	}
+
+	squashed := &imagepkg.Image{
+		Parent:        b.baseImage,
+		Comment:       fmt.Sprintf("squashed %s", img.ID),
+		Created:       created,
+		DockerVersion: dockerversion.VERSION,
+		Author:        img.Author,
+		Config:        img.Config,
+		Architecture:  img.Architecture,
+		OS:            img.OS,
+	}
+	if err := b.Daemon.Graph().Register(squashed, layer); err != nil {
+		return err
+	}
+	fmt.Fprintf(b.OutStream, " ---> Squashed %s into %s\n", common.TruncateID(img.ID), common.TruncateID(squashed.ID))
+	b.image = squashed.ID
+	return nil
+}
+
+// loadCacheFrom collects the layers of the images given with --cache-from,
+// the images which don't exist are skipped
+func (b *Builder) loadCacheFrom() error {
+	if len(b.CacheFrom) == 0 {
+		return nil
+	}
+	b.cacheFromLayers = map[string]*imagepkg.Image{}
+	for _, name := range b.CacheFrom {
+		img, err := b.Daemon.Repositories().LookupImage(name)
+		if err != nil {
+			if !b.Daemon.Graph().IsNotExist(err) {
+				return err
+			}
+			fmt.Fprintf(b.ErrStream, "# Skipping the missing cache image %s\n", name)
+			continue
+		}
+		for img != nil {
+			if _, exists := b.cacheFromLayers[img.ID]; exists {
+				break
+			}
+			b.cacheFromLayers[img.ID] = img
+			if img, err = img.GetParent(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// getCachedFrom is the version of ImageGetCached which only looks at the
+// layers of the --cache-from images
+func (b *Builder) getCachedFrom() *imagepkg.Image {
+	var match *imagepkg.Image
+	for _, img := range b.cacheFromLayers {
+		if img.Parent != b.image || !runconfig.Compare(&img.ContainerConfig, b.Config) {
+			continue
+		}
+		if match == nil || match.Created.Before(img.Created) {
+			match = img
+		}
+	}
+	return match
+}
+
+// writeSecrets writes the --secret files of the build to a temporary
+// directory, on tmpfs when /dev/shm is available, from which they are bind
+// mounted in the RUN containers
+func (b *Builder) writeSecrets() error {
+	if len(b.Secrets) == 0 {
+		return nil
+	}
+	root := ""
+	if fi, err := os.Stat("/dev/shm"); err == nil && fi.IsDir() {
+		root = "/dev/shm"
+	}
+	dir, err := ioutil.TempDir(root, "docker-build-secrets")
+	if err != nil {
+		return err
+	}
+	b.secretsDir = dir
+	for id, data := range b.Secrets {
+		if err := ioutil.WriteFile(filepath.Join(dir, id), data, 0444); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runMounts parses the --mount flags of a RUN instruction, which are
+// --mount=type=secret,id=name[,target=path] for the secrets of the build,
+// --mount=type=ssh[,id=name][,target=path] for the forwarded SSH agents and
+// --mount=type=cache,target=path[,id=name] for the cache directories kept
+// across builds, by id or else by target. It
+// returns the binds of the mounts, their targets and the environment of the
+// RUN, the SSH_AUTH_SOCK of the first SSH agent.
+func (b *Builder) runMounts() (binds, targets, env []string, err error) {
+	for _, flag := range b.flags {
+		if !strings.HasPrefix(flag, "--mount=") {
+			return nil, nil, nil, fmt.Errorf("Unknown flag for RUN: %s", flag)
+		}
+		var typ, id, target string
+		for _, field := range strings.Split(strings.TrimPrefix(flag, "--mount="), ",") {
+			parts := strings.SplitN(field, "=", 2)
+			if len(parts) != 2 {
+				return nil, nil, nil, fmt.Errorf("Invalid mount %s, expected key=value fields", flag)
+			}
+			switch parts[0] {
+			case "type":
+				typ = parts[1]
+			case "id":
+				id = parts[1]
+			case "target", "dst":
+				target = parts[1]
+			default:
+				return nil, nil, nil, fmt.Errorf("Invalid mount %s, unknown field %s", flag, parts[0])
+			}
+		}
+
+		if target != "" && !filepath.IsAbs(target) {
+			target = filepath.Join("/", b.Config.WorkingDir, target)
+		}
+
+		var source, mode string
+		switch typ {
+		case "secret":
+			if id == "" {
+				return nil, nil, nil, fmt.Errorf("A secret mount requires an id")
+			}
+			if _, exists := b.Secrets[id]; !exists {
+				return nil, nil, nil, fmt.Errorf("Secret %s was not given with docker build --secret", id)
+			}
+			if target == "" {
+				target = "/run/secrets/" + id
+			}
+			source, mode = filepath.Join(b.secretsDir, id), "ro"
+		case "ssh":
+			if id == "" {
+				id = "default"
+			}
+			socket, exists := b.SSHSockets[id]
+			if !exists {
+				return nil, nil, nil, fmt.Errorf("SSH agent %s was not given with docker build --ssh", id)
+			}
+			if target == "" {
+				target = "/run/ssh-agent/" + id + ".sock"
+			}
+			source, mode = socket, "rw"
+		case "cache":
+			if target == "" {
+				return nil, nil, nil, fmt.Errorf("A cache mount requires a target")
+			}
+			if id == "" {
+				id = filepath.Clean(target)
+			}
+			if source, err = b.cacheMountDir(id); err != nil {
+				return nil, nil, nil, err
+			}
+			mode = "rw"
+		default:
+			return nil, nil, nil, fmt.Errorf("Unsupported mount type %q, only secret, ssh and cache mounts are supported", typ)
+		}
+
+		target = filepath.Clean(target)
+		if typ == "ssh" && env == nil {
+			env = []string{"SSH_AUTH_SOCK=" + target}
+		}
+		binds = append(binds, fmt.Sprintf("%s:%s:%s", source, target, mode))
+		targets = append(targets, target)
+	}
+	return binds, targets, env, nil
+}
+
+// cacheMountDir returns the directory of the cache mounts with the given id,
+// it is kept by the daemon across the builds
+func (b *Builder) cacheMountDir(id string) (string, error) {
+	sum := sha256.Sum256([]byte(id))
+	dir := filepath.Join(b.Daemon.Config().Root, "builder", "cache", hex.EncodeToString(sum[:]))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// missingMountpoints returns the topmost missing paths of the targets in
+// the filesystem of the container, the mountpoints of the RUN mounts created
+// in these paths are removed before the commit
+func missingMountpoints(c *daemon.Container, targets []string) ([]string, error) {
+	var missing []string
+	for _, target := range targets {
+		var top string
+		for p := target; p != "/"; p = filepath.Dir(p) {
+			full, err := symlink.FollowSymlinkInScope(filepath.Join(c.RootfsPath(), p), c.RootfsPath())
+			if err != nil {
+				return nil, err
+			}
+			if _, err := os.Lstat(full); err == nil {
+				break
+			}
+			top = full
+		}
+		if top != "" {
+			missing = append(missing, top)
+		}
+	}
+	return missing, nil
+}
+
+func (b *Builder) create(hostConfig *runconfig.HostConfig) (*daemon.Container, error) {
 	if b.image == "" && !b.noBaseImage {
 		return nil, fmt.Errorf("Please provide a source image with `from` prior to run")
 	}
@@ -531,7 +1037,7 @@ func (b *Builder) create() (*daemon.Container, error) {
 	config := *b.Config
 
 	// Create the container
-	c, warnings, err := b.Daemon.Create(b.Config, nil, "")
+	c, warnings, err := b.Daemon.Create(b.Config, hostConfig, "")
 	if err != nil {
 		return nil, err
 	}
@@ -556,7 +1062,7 @@ func (b *Builder) create() (*daemon.Container, error) {
 func (b *Builder) run(c *daemon.Container) error {
 	var errCh chan error
 	if b.Verbose {
-		errCh = b.Daemon.Attach(&c.StreamConfig, c.Config.OpenStdin, c.Config.StdinOnce, c.Config.Tty, nil, b.OutStream, b.ErrStream)
+		errCh = b.Daemon.Attach(&c.StreamConfig, c.Config.OpenStdin, c.Config.StdinOnce, c.Config.Tty, nil, nil, b.OutStream, b.ErrStream)
 	}
 
 	//start the container
@@ -604,11 +1110,13 @@ func (b *Builder) checkPathForAddition(orig string) error {
 	return nil
 }
 
-func (b *Builder) addContext(container *daemon.Container, orig, dest string, decompress bool) error {
+// addContext copies orig, relative to root, to dest in the container. root is
+// the context of the build or the filesystem of the image of COPY --from.
+func (b *Builder) addContext(container *daemon.Container, root, orig, dest string, decompress bool) error {
 	var (
 		err        error
 		destExists = true
-		origPath   = path.Join(b.contextPath, orig)
+		origPath   = path.Join(root, orig)
 		destPath   = path.Join(container.RootfsPath(), dest)
 	)
 
@@ -6,14 +6,16 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api"
 	"github.com/docker/docker/builder/parser"
 	"github.com/docker/docker/daemon"
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/graph"
+	"github.com/docker/docker/opts"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/urlutil"
@@ -24,14 +26,17 @@ import (
 
 // whitelist of commands allowed for a commit/import
 var validCommitCommands = map[string]bool{
-	"entrypoint": true,
-	"cmd":        true,
-	"user":       true,
-	"workdir":    true,
-	"env":        true,
-	"volume":     true,
-	"expose":     true,
-	"onbuild":    true,
+	"entrypoint":  true,
+	"cmd":         true,
+	"user":        true,
+	"workdir":     true,
+	"env":         true,
+	"volume":      true,
+	"expose":      true,
+	"onbuild":     true,
+	"stopsignal":  true,
+	"label":       true,
+	"healthcheck": true,
 }
 
 type BuilderJob struct {
@@ -42,6 +47,7 @@ type BuilderJob struct {
 func (b *BuilderJob) Install() {
 	b.Engine.Register("build", b.CmdBuild)
 	b.Engine.Register("build_config", b.CmdBuildConfig)
+	b.Engine.Register("build_session", b.CmdBuildSession)
 }
 
 func (b *BuilderJob) CmdBuild(job *engine.Job) engine.Status {
@@ -57,14 +63,83 @@ func (b *BuilderJob) CmdBuild(job *engine.Job) engine.Status {
 		rm             = job.GetenvBool("rm")
 		forceRm        = job.GetenvBool("forcerm")
 		pull           = job.GetenvBool("pull")
+		squash         = job.GetenvBool("squash")
+		parallelism    = job.GetenvInt("parallel")
+		buildSteps     = job.GetenvBool("buildsteps")
+		epoch          = job.Getenv("sourcedateepoch")
+		networkMode    = runconfig.NetworkMode(job.Getenv("networkmode"))
 		authConfig     = &registry.AuthConfig{}
 		configFile     = &registry.ConfigFile{}
+		buildArgs      = map[string]string{}
+		cacheFrom      []string
+		secrets        = map[string][]byte{}
+		sshAgents      []string
+		sessionID      = job.Getenv("session")
+		buildContexts  = map[string]string{}
+		extraHosts     []string
+		epochTime      *time.Time
 		tag            string
 		context        io.ReadCloser
 	)
 
 	job.GetenvJson("authConfig", authConfig)
 	job.GetenvJson("configFile", configFile)
+	if err := job.GetenvJson("buildargs", &buildArgs); err != nil {
+		return job.Errorf("Invalid build-args: %v", err)
+	}
+	if err := job.GetenvJson("cachefrom", &cacheFrom); err != nil {
+		return job.Errorf("Invalid cache-from: %v", err)
+	}
+	if err := job.GetenvJson("secrets", &secrets); err != nil {
+		return job.Errorf("Invalid secrets: %v", err)
+	}
+	if err := job.GetenvJson("ssh", &sshAgents); err != nil {
+		return job.Errorf("Invalid ssh: %v", err)
+	}
+	for _, id := range sshAgents {
+		if id == "" || strings.Contains(id, "/") {
+			return job.Errorf("Invalid SSH agent id %q", id)
+		}
+	}
+	if len(sshAgents) > 0 && sessionID == "" {
+		return job.Errorf("The SSH agents are forwarded over a session, the build has none")
+	}
+	if err := job.GetenvJson("buildcontexts", &buildContexts); err != nil {
+		return job.Errorf("Invalid build contexts: %v", err)
+	}
+	if err := job.GetenvJson("extrahosts", &extraHosts); err != nil {
+		return job.Errorf("Invalid extra hosts: %v", err)
+	}
+	for _, host := range extraHosts {
+		if _, err := opts.ValidateExtraHost(host); err != nil {
+			return job.Error(err)
+		}
+	}
+	if epoch != "" {
+		n, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return job.Errorf("Invalid SOURCE_DATE_EPOCH %q, expected a number of seconds since the epoch", epoch)
+		}
+		t := time.Unix(n, 0).UTC()
+		epochTime = &t
+	}
+	for id := range secrets {
+		if id == "" || strings.Contains(id, "/") {
+			return job.Errorf("Invalid secret id %q", id)
+		}
+	}
+
+	if networkMode == "default" {
+		networkMode = ""
+	}
+	if networkMode.IsContainer() || strings.Contains(string(networkMode), ":") {
+		return job.Errorf("Invalid network %s for the build, expected none, host, bridge or the name of a network", networkMode)
+	}
+	if networkMode.IsUserDefined() {
+		if _, err := b.Daemon.Networks().Get(string(networkMode)); err != nil {
+			return job.Error(err)
+		}
+	}
 
 	repoName, tag = parsers.ParseRepositoryTag(repoName)
 	if repoName != "" {
@@ -81,20 +156,18 @@ func (b *BuilderJob) CmdBuild(job *engine.Job) engine.Status {
 	if remoteURL == "" {
 		context = ioutil.NopCloser(job.Stdin)
 	} else if urlutil.IsGitURL(remoteURL) {
-		if !urlutil.IsGitTransport(remoteURL) {
-			remoteURL = "https://" + remoteURL
-		}
 		root, err := ioutil.TempDir("", "docker-build-git")
 		if err != nil {
 			return job.Error(err)
 		}
 		defer os.RemoveAll(root)
 
-		if output, err := exec.Command("git", "clone", "--recursive", remoteURL, root).CombinedOutput(); err != nil {
-			return job.Errorf("Error trying to use git: %s (%s)", err, output)
+		contextDir, err := utils.GitClone(remoteURL, root)
+		if err != nil {
+			return job.Error(err)
 		}
 
-		c, err := archive.Tar(root, archive.Uncompressed)
+		c, err := archive.Tar(contextDir, archive.Uncompressed)
 		if err != nil {
 			return job.Error(err)
 		}
@@ -137,14 +210,26 @@ func (b *BuilderJob) CmdBuild(job *engine.Job) engine.Status {
 		},
 		Verbose:         !suppressOutput,
 		UtilizeCache:    !noCache,
+		CacheFrom:       cacheFrom,
+		Secrets:         secrets,
+		SSHAgents:       sshAgents,
+		SessionID:       sessionID,
+		BuildContexts:   buildContexts,
 		Remove:          rm,
 		ForceRemove:     forceRm,
 		Pull:            pull,
+		Squash:          squash,
+		Parallelism:     parallelism,
+		BuildSteps:      buildSteps,
+		SourceDateEpoch: epochTime,
+		NetworkMode:     networkMode,
+		ExtraHosts:      extraHosts,
 		OutOld:          job.Stdout,
 		StreamFormatter: sf,
 		AuthConfig:      authConfig,
 		AuthConfigFile:  configFile,
 		dockerfileName:  dockerfileName,
+		buildArgs:       buildArgs,
 	}
 
 	id, err := builder.Run(context)
@@ -45,6 +45,17 @@ func parseSubCommand(rest string) (*Node, map[string]bool, error) {
 // parse environment like statements. Note that this does *not* handle
 // variable interpolation, which will be handled in the evaluator.
 func parseEnv(rest string) (*Node, map[string]bool, error) {
+	return parseNameVal(rest, "ENV")
+}
+
+// parses LABEL statements, which have the same forms as ENV.
+func parseLabel(rest string) (*Node, map[string]bool, error) {
+	return parseNameVal(rest, "LABEL")
+}
+
+// parses the `name value` or `name=value ...` statements of the key
+// instruction.
+func parseNameVal(rest string, key string) (*Node, map[string]bool, error) {
 	// This is kind of tricky because we need to support the old
 	// variant:   ENV name value
 	// as well as the new one:    ENV name=value ...
@@ -137,10 +148,10 @@ func parseEnv(rest string) (*Node, map[string]bool, error) {
 	}
 
 	if len(words) == 0 {
-		return nil, nil, fmt.Errorf("ENV requires at least one argument")
+		return nil, nil, fmt.Errorf("%s requires at least one argument", key)
 	}
 
-	// Old format (ENV name value)
+	// Old format (KEY name value)
 	var rootnode *Node
 
 	if !strings.Contains(words[0], "=") {
@@ -149,7 +160,7 @@ func parseEnv(rest string) (*Node, map[string]bool, error) {
 		strs := TOKEN_WHITESPACE.Split(rest, 2)
 
 		if len(strs) < 2 {
-			return nil, nil, fmt.Errorf("ENV must have two arguments")
+			return nil, nil, fmt.Errorf("%s must have two arguments", key)
 		}
 
 		node.Value = strs[0]
@@ -279,3 +290,27 @@ func parseMaybeJSONToList(rest string) (*Node, map[string]bool, error) {
 
 	return parseStringsWhitespaceDelimited(rest)
 }
+
+// parses HEALTHCHECK NONE and HEALTHCHECK CMD command, the command has the
+// forms of CMD.
+func parseHealthConfig(rest string) (*Node, map[string]bool, error) {
+	parts := TOKEN_WHITESPACE.Split(rest, 2)
+	typ := strings.ToUpper(parts[0])
+	switch typ {
+	case "NONE":
+		if len(parts) > 1 {
+			return nil, nil, fmt.Errorf("HEALTHCHECK NONE takes no arguments")
+		}
+		return &Node{Value: typ}, nil, nil
+	case "CMD":
+		if len(parts) == 1 || strings.TrimSpace(parts[1]) == "" {
+			return nil, nil, fmt.Errorf("HEALTHCHECK CMD requires a command")
+		}
+		cmd, attrs, err := parseMaybeJSON(parts[1])
+		if err != nil {
+			return nil, nil, err
+		}
+		return &Node{Value: typ, Next: cmd}, attrs, nil
+	}
+	return nil, nil, fmt.Errorf("HEALTHCHECK must be NONE or CMD, not %q", parts[0])
+}
@@ -22,13 +22,13 @@ import (
 // This data structure is frankly pretty lousy for handling complex languages,
 // but lucky for us the Dockerfile isn't very complicated. This structure
 // works a little more effectively than a "proper" parse tree for our needs.
-//
 type Node struct {
 	Value      string          // actual content
 	Next       *Node           // the next item in the current sexp
 	Children   []*Node         // the children of this sexp
 	Attributes map[string]bool // special attributes for this node
 	Original   string          // original line used before parsing
+	Flags      []string        // only top Node should have this set
 }
 
 var (
@@ -38,6 +38,15 @@ var (
 	TOKEN_COMMENT           = regexp.MustCompile(`^#.*$`)
 )
 
+// The instructions which accept flags such as `COPY --from=<stage>` and
+// `RUN --mount=type=secret,id=<name>`, the
+// flags are the leading words starting with `--`.
+var flagCommands = map[string]struct{}{
+	command.Copy:        {},
+	command.Run:         {},
+	command.Healthcheck: {},
+}
+
 func init() {
 	// Dispatch Table. see line_parsers.go for the parse functions.
 	// The command is parsed and mapped to the line parser. The line parser
@@ -46,20 +55,24 @@ func init() {
 	// functions. Errors are propagated up by Parse() and the resulting AST can
 	// be incorporated directly into the existing AST as a next.
 	dispatch = map[string]func(string) (*Node, map[string]bool, error){
-		command.User:       parseString,
-		command.Onbuild:    parseSubCommand,
-		command.Workdir:    parseString,
-		command.Env:        parseEnv,
-		command.Maintainer: parseString,
-		command.From:       parseString,
-		command.Add:        parseMaybeJSONToList,
-		command.Copy:       parseMaybeJSONToList,
-		command.Run:        parseMaybeJSON,
-		command.Cmd:        parseMaybeJSON,
-		command.Entrypoint: parseMaybeJSON,
-		command.Expose:     parseStringsWhitespaceDelimited,
-		command.Volume:     parseMaybeJSONToList,
-		command.Insert:     parseIgnore,
+		command.User:        parseString,
+		command.Onbuild:     parseSubCommand,
+		command.Workdir:     parseString,
+		command.Env:         parseEnv,
+		command.Maintainer:  parseString,
+		command.From:        parseString,
+		command.Add:         parseMaybeJSONToList,
+		command.Copy:        parseMaybeJSONToList,
+		command.Run:         parseMaybeJSON,
+		command.Cmd:         parseMaybeJSON,
+		command.Entrypoint:  parseMaybeJSON,
+		command.Expose:      parseStringsWhitespaceDelimited,
+		command.Volume:      parseMaybeJSONToList,
+		command.Insert:      parseIgnore,
+		command.StopSignal:  parseString,
+		command.Arg:         parseStringsWhitespaceDelimited,
+		command.Label:       parseLabel,
+		command.Healthcheck: parseHealthConfig,
 	}
 }
 
@@ -82,6 +95,10 @@ func parseLine(line string) (string, *Node, error) {
 	node := &Node{}
 	node.Value = cmd
 
+	if _, ok := flagCommands[cmd]; ok {
+		node.Flags, args = extractBuilderFlags(args)
+	}
+
 	sexp, attrs, err := fullDispatch(cmd, args)
 	if err != nil {
 		return "", nil, err
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 )
@@ -15,6 +16,10 @@ func (node *Node) Dump() string {
 		str += "(" + n.Dump() + ")\n"
 	}
 
+	if len(node.Flags) > 0 {
+		str += fmt.Sprintf(" %q", node.Flags)
+	}
+
 	if node.Next != nil {
 		for n := node.Next; n != nil; n = n.Next {
 			if len(n.Children) > 0 {
@@ -64,6 +69,24 @@ func splitCommand(line string) (string, string, error) {
 	return cmd, args, nil
 }
 
+// extractBuilderFlags splits the leading `--flag[=value]` words of the args
+// of an instruction from the rest of its args.
+func extractBuilderFlags(args string) ([]string, string) {
+	var flags []string
+	for strings.HasPrefix(args, "--") {
+		parts := TOKEN_WHITESPACE.Split(args, 2)
+		if parts[0] == "--" {
+			break
+		}
+		flags = append(flags, parts[0])
+		args = ""
+		if len(parts) == 2 {
+			args = parts[1]
+		}
+	}
+	return flags, args
+}
+
 // covers comments and empty lines. Lines should be trimmed before passing to
 // this function.
 func stripComments(line string) string {
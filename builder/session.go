@@ -0,0 +1,145 @@
+package builder
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/common"
+	"github.com/docker/docker/pkg/sshforward"
+)
+
+// sessionWait is how long a build waits for the session it names to be
+// opened, the client opens it right before it starts the build
+const sessionWait = 10 * time.Second
+
+// sessions are the sessions opened by the clients to forward their SSH
+// agents to their builds, by id
+var sessions = &sessionStore{sessions: make(map[string]*sshforward.Session)}
+
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*sshforward.Session
+}
+
+func (s *sessionStore) add(id string, session *sshforward.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.sessions[id]; exists {
+		return fmt.Errorf("Session %s already exists", id)
+	}
+	s.sessions[id] = session
+	return nil
+}
+
+func (s *sessionStore) remove(id string) {
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+}
+
+// get returns the session id, it waits up to timeout for the session to be
+// opened
+func (s *sessionStore) get(id string, timeout time.Duration) (*sshforward.Session, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		s.mu.Lock()
+		session, exists := s.sessions[id]
+		s.mu.Unlock()
+		if exists {
+			return session, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("No session %s, the SSH agents are forwarded over a session opened by the client", id)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// CmdBuildSession serves the session of a client over the standard streams
+// of the job, until the client closes it. The builds naming the session
+// forward their SSH agents over it.
+func (b *BuilderJob) CmdBuildSession(job *engine.Job) engine.Status {
+	if len(job.Args) != 1 || job.Args[0] == "" {
+		return job.Errorf("Usage: %s ID\n", job.Name)
+	}
+	id := job.Args[0]
+	session := sshforward.NewSession(struct {
+		io.Reader
+		io.Writer
+	}{job.Stdin, job.Stdout})
+	if err := sessions.add(id, session); err != nil {
+		return job.Error(err)
+	}
+	defer sessions.remove(id)
+	<-session.Done()
+	return engine.StatusOK
+}
+
+// forwardSSHAgents listens on a socket for each SSH agent of the build, the
+// connections to the sockets are forwarded to the agents of the client over
+// its session. It returns a function closing the sockets.
+func (b *Builder) forwardSSHAgents() (func(), error) {
+	b.SSHSockets = make(map[string]string)
+	if len(b.SSHAgents) == 0 {
+		return func() {}, nil
+	}
+	session, err := sessions.get(b.SessionID, sessionWait)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(b.Daemon.Config().Root, "builder", "ssh", common.GenerateRandomID())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	var listeners []net.Listener
+	closeAll := func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+		os.RemoveAll(dir)
+	}
+	for _, agent := range b.SSHAgents {
+		socket := filepath.Join(dir, agent+".sock")
+		l, err := net.Listen("unix", socket)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		listeners = append(listeners, l)
+		b.SSHSockets[agent] = socket
+		go forwardSSHAgent(l, session, agent)
+	}
+	return closeAll, nil
+}
+
+// forwardSSHAgent forwards the connections accepted by l to the agent of
+// the session, until l is closed
+func forwardSSHAgent(l net.Listener, session *sshforward.Session, agent string) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		st, err := session.Open(agent)
+		if err != nil {
+			log.Errorf("Error forwarding the SSH agent %s: %v", agent, err)
+			conn.Close()
+			continue
+		}
+		go func() {
+			io.Copy(st, conn)
+			st.Close()
+		}()
+		go func() {
+			io.Copy(conn, st)
+			conn.Close()
+		}()
+	}
+}
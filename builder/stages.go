@@ -0,0 +1,275 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/builder/command"
+	"github.com/docker/docker/builder/parser"
+	"github.com/docker/docker/runconfig"
+)
+
+// dockerfileStage is the part of the Dockerfile starting with a FROM
+// instruction, with the stages it depends on through FROM or COPY --from.
+type dockerfileStage struct {
+	name  string
+	first int // the step number of the FROM instruction
+	nodes []*parser.Node
+	deps  []int
+}
+
+// splitStages splits the instructions of the Dockerfile into its stages, it
+// returns nil when the Dockerfile doesn't start with FROM
+func splitStages(children []*parser.Node) []*dockerfileStage {
+	if len(children) == 0 || children[0].Value != command.From {
+		return nil
+	}
+	var stages []*dockerfileStage
+	for i, n := range children {
+		if n.Value == command.From {
+			stage := &dockerfileStage{first: i}
+			if n.Next != nil {
+				if parts := strings.Fields(n.Next.Value); len(parts) == 3 && strings.EqualFold(parts[1], "as") {
+					stage.name = strings.ToLower(parts[2])
+				}
+			}
+			stages = append(stages, stage)
+		}
+		stage := stages[len(stages)-1]
+		stage.nodes = append(stage.nodes, n)
+	}
+	for i, stage := range stages {
+		for _, n := range stage.nodes {
+			var refs []string
+			switch n.Value {
+			case command.From:
+				if n.Next != nil {
+					if parts := strings.Fields(n.Next.Value); len(parts) > 0 {
+						refs = append(refs, parts[0])
+					}
+				}
+			case command.Copy:
+				for _, flag := range n.Flags {
+					if strings.HasPrefix(flag, "--from=") {
+						refs = append(refs, strings.TrimPrefix(flag, "--from="))
+					}
+				}
+			}
+			for _, ref := range refs {
+				if dep := findPreviousStage(stages[:i], ref); dep >= 0 {
+					stage.deps = append(stage.deps, dep)
+				}
+			}
+		}
+	}
+	return stages
+}
+
+// findPreviousStage returns the index of the stage named or numbered ref in
+// stages, or -1 when ref isn't a stage, like findStage does for the
+// completed stages.
+func findPreviousStage(stages []*dockerfileStage, ref string) int {
+	if i, err := strconv.Atoi(ref); err == nil {
+		if i >= 0 && i < len(stages) {
+			return i
+		}
+		return -1
+	}
+	ref = strings.ToLower(ref)
+	for i, stage := range stages {
+		if stage.name != "" && stage.name == ref {
+			return i
+		}
+	}
+	return -1
+}
+
+// buildStages builds the stages of the Dockerfile, up to b.Parallelism of
+// them at once: a stage is started as soon as the stages it depends on are
+// built. The output of each stage is prefixed with its name, or its index
+// for the unnamed ones. The state of the builder is the one of the last
+// stage once they are all built.
+func (b *Builder) buildStages(stages []*dockerfileStage) error {
+	var (
+		mu       sync.Mutex // guards the output, firstErr
+		firstErr error
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, b.Parallelism)
+		done     = make([]chan struct{}, len(stages))
+		builders = make([]*Builder, len(stages))
+	)
+	for i := range stages {
+		done[i] = make(chan struct{})
+	}
+
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+
+	for i, stage := range stages {
+		wg.Add(1)
+		go func(i int, stage *dockerfileStage) {
+			defer wg.Done()
+			defer close(done[i])
+
+			for _, dep := range stage.deps {
+				<-done[dep]
+			}
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			for _, dep := range stage.deps {
+				if builders[dep] == nil {
+					return
+				}
+			}
+			if failed() {
+				return
+			}
+
+			label := stage.name
+			if label == "" {
+				label = strconv.Itoa(i)
+			}
+			out := &stageWriter{mu: &mu, w: b.OutStream, prefix: "[" + label + "] "}
+			errOut := &stageWriter{mu: &mu, w: b.ErrStream, prefix: "[" + label + "] "}
+			sb := b.stageBuilder(stages, builders, i)
+			sb.OutStream, sb.ErrStream = out, errOut
+			sb.OutOld = &lockedWriter{mu: &mu, w: b.OutOld}
+
+			err := sb.dispatchNodes(stage.first, stage.nodes)
+			if err == nil && sb.image == "" {
+				err = fmt.Errorf("No image was generated for the build stage %d", i)
+			}
+			out.Flush()
+			errOut.Flush()
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			builders[i] = sb
+		}(i, stage)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	for i, sb := range builders {
+		for k := range sb.declaredBuildArgs {
+			b.declaredBuildArgs[k] = true
+		}
+		if i < len(stages)-1 {
+			b.stages = append(b.stages, buildStage{name: stages[i].name, image: sb.image})
+		}
+	}
+	last := builders[len(builders)-1]
+	b.Config = last.Config
+	b.image = last.image
+	b.baseImage = last.baseImage
+	b.noBaseImage = last.noBaseImage
+	b.maintainer = last.maintainer
+	b.cmdSet = last.cmdSet
+	b.stageName = last.stageName
+	b.argDefaults = last.argDefaults
+	b.allowedBuildArgs = last.allowedBuildArgs
+	b.stageCount = len(stages)
+	return nil
+}
+
+// stageBuilder returns a builder for the stage i, the stages it depends on
+// must be built. The other previous stages are only known by their name so
+// that duplicate names are still detected. The ARG instructions of the
+// previous stages are declared, as they are in a sequential build.
+func (b *Builder) stageBuilder(stages []*dockerfileStage, builders []*Builder, i int) *Builder {
+	sb := *b
+	sb.Config = &runconfig.Config{}
+	sb.TmpContainers = map[string]struct{}{}
+	sb.declaredBuildArgs = map[string]bool{}
+	sb.resetBuildArgs()
+	for _, stage := range stages[:i] {
+		for _, n := range stage.nodes {
+			if n.Value != command.Arg || n.Next == nil {
+				continue
+			}
+			if name, value, hasDefault := parseBuildArg(sb.replaceEnv(n.Next.Value)); name != "" {
+				sb.declareBuildArg(name, value, hasDefault)
+			}
+		}
+	}
+	sb.image = ""
+	sb.baseImage = ""
+	sb.noBaseImage = false
+	sb.maintainer = ""
+	sb.cmdSet = false
+	sb.stageName = ""
+	sb.stageCount = i
+	sb.stages = make([]buildStage, i)
+	for j := 0; j < i; j++ {
+		sb.stages[j].name = stages[j].name
+	}
+	for _, dep := range stages[i].deps {
+		sb.stages[dep].image = builders[dep].image
+	}
+	return &sb
+}
+
+// stageWriter prefixes each line written to w, the lines of the stages
+// built at once are written whole so that they don't get mixed.
+type stageWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    bytes.Buffer
+}
+
+func (w *stageWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		if err := w.writeLine(w.buf.Next(i + 1)); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes the last line, if it isn't terminated
+func (w *stageWriter) Flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	return w.writeLine(w.buf.Next(w.buf.Len()))
+}
+
+func (w *stageWriter) writeLine(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.w.Write(append([]byte(w.prefix), line...))
+	return err
+}
+
+// lockedWriter serializes the writes of the stages to w
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
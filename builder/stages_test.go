@@ -0,0 +1,113 @@
+package builder
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/builder/parser"
+)
+
+func parseStages(t *testing.T, dockerfile string) []*dockerfileStage {
+	ast, err := parser.Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return splitStages(ast.Children)
+}
+
+func TestSplitStages(t *testing.T) {
+	stages := parseStages(t, `FROM busybox AS Build
+RUN make
+
+FROM build
+RUN make test
+
+FROM scratch
+COPY --from=0 /app /app
+COPY --from=1 /report /report
+COPY --from=busybox /bin/sh /bin/sh
+`)
+	if len(stages) != 3 {
+		t.Fatalf("Expected 3 stages, got %d", len(stages))
+	}
+	expected := []struct {
+		name  string
+		first int
+		nodes int
+		deps  []int
+	}{
+		{"build", 0, 2, nil},
+		{"", 2, 2, []int{0}},
+		{"", 4, 4, []int{0, 1}},
+	}
+	for i, e := range expected {
+		s := stages[i]
+		if s.name != e.name || s.first != e.first || len(s.nodes) != e.nodes || !reflect.DeepEqual(s.deps, e.deps) {
+			t.Fatalf("Expected the stage %d to be %+v, got name=%q first=%d nodes=%d deps=%v", i, e, s.name, s.first, len(s.nodes), s.deps)
+		}
+	}
+
+	if stages := parseStages(t, "MAINTAINER me\nFROM busybox\n"); stages != nil {
+		t.Fatalf("Expected no stages without a leading FROM, got %d", len(stages))
+	}
+}
+
+func TestStageBuilderBuildArgs(t *testing.T) {
+	stages := parseStages(t, `FROM busybox AS first
+ARG VERSION=1.0
+ARG USER
+
+FROM busybox AS second
+ARG VERSION=2.0
+ARG TARGET=linux
+
+FROM first
+`)
+	b := &Builder{}
+	b.resetBuildArgs()
+	builders := []*Builder{{image: "firstimage"}, nil, nil}
+
+	sb := b.stageBuilder(stages, builders, 2)
+	for _, name := range []string{"VERSION", "USER", "TARGET"} {
+		if !sb.allowedBuildArgs[name] {
+			t.Fatalf("Expected %s of a previous stage to be declared", name)
+		}
+	}
+	if value, _ := sb.buildArg("VERSION"); value != "1.0" {
+		t.Fatalf("Expected the first default of VERSION, got %q", value)
+	}
+	if len(sb.stages) != 2 || sb.stages[0].image != "firstimage" || sb.stages[1].name != "second" || sb.stages[1].image != "" {
+		t.Fatalf("Expected only the image of the stage depended on, got %+v", sb.stages)
+	}
+
+	if sb := b.stageBuilder(stages, builders, 0); len(sb.allowedBuildArgs) != 0 {
+		t.Fatalf("Expected no variable declared in the first stage, got %v", sb.allowedBuildArgs)
+	}
+}
+
+func TestBuildStages(t *testing.T) {
+	stages := parseStages(t, `FROM scratch AS a
+FROM a
+FROM scratch AS c
+`)
+	var out bytes.Buffer
+	b := &Builder{Parallelism: 2, OutStream: &out, ErrStream: ioutil.Discard, OutOld: ioutil.Discard}
+	b.resetBuildArgs()
+
+	// the stages without a layer fail, the other stages are not started
+	// once one of them failed, nor is the stage depending on a failed one
+	err := b.buildStages(stages)
+	if err == nil || !strings.Contains(err.Error(), "No image was generated") {
+		t.Fatalf("Expected the build of the stages to fail, got %v", err)
+	}
+	output := out.String()
+	if !strings.HasPrefix(output, "[a] Step 0 : FROM scratch AS a\n") && !strings.HasPrefix(output, "[c] Step 2 : FROM scratch AS c\n") {
+		t.Fatalf("Expected the output of the stage to be prefixed with its name: %s", output)
+	}
+	if strings.Contains(output, "[1] ") {
+		t.Fatalf("Expected the stage depending on a failed stage not to be built: %s", output)
+	}
+}
@@ -2,6 +2,7 @@ package builder
 
 import (
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -33,16 +34,93 @@ func (b *Builder) replaceEnv(str string) string {
 		match = match[strings.Index(match, "$"):]
 		matchKey := strings.Trim(match, "${}")
 
+		if value, ok := b.lookupEnv(matchKey); ok {
+			str = strings.Replace(str, match, value, -1)
+		}
+	}
+
+	return str
+}
+
+// lookupEnv returns the value of the environment variable key, ENV variables
+// take precedence over the build-time variables declared with ARG.
+func (b *Builder) lookupEnv(key string) (string, bool) {
+	for _, keyval := range b.Config.Env {
+		tmp := strings.SplitN(keyval, "=", 2)
+		if tmp[0] == key {
+			return tmp[1], true
+		}
+	}
+	return b.buildArg(key)
+}
+
+// parseBuildArg splits the name=default argument of an ARG instruction, the
+// default is optional
+func parseBuildArg(arg string) (name, value string, hasDefault bool) {
+	if parts := strings.SplitN(arg, "=", 2); len(parts) == 2 {
+		return parts[0], parts[1], true
+	}
+	return arg, "", false
+}
+
+// declareBuildArg allows the build-time variable name in the following
+// instructions, the first default given to a variable is kept
+func (b *Builder) declareBuildArg(name, value string, hasDefault bool) {
+	b.allowedBuildArgs[name] = true
+	b.declaredBuildArgs[name] = true
+	if _, exists := b.argDefaults[name]; hasDefault && !exists {
+		b.argDefaults[name] = value
+	}
+}
+
+// buildArg returns the value of the build-time variable key, the one given
+// with --build-arg or else the default of its ARG instruction. Variables
+// which are not declared (or predefined) have no value.
+func (b *Builder) buildArg(key string) (string, bool) {
+	if !b.allowedBuildArgs[key] && !builtinAllowedBuildArgs[key] {
+		return "", false
+	}
+	if value, ok := b.buildArgs[key]; ok {
+		return value, true
+	}
+	value, ok := b.argDefaults[key]
+	return value, ok
+}
+
+// buildArgsEnv returns the build-time variables set for the RUN
+// instructions, sorted, without the ones overridden by an ENV variable.
+// The proxy variables which are not declared with ARG are left out when
+// cacheKey is set, they are only set in the environment of the command.
+func (b *Builder) buildArgsEnv(cacheKey bool) []string {
+	keys := map[string]bool{}
+	for key := range b.allowedBuildArgs {
+		keys[key] = true
+	}
+	for key := range builtinAllowedBuildArgs {
+		if !cacheKey || !isProxyBuildArg(key) {
+			keys[key] = true
+		}
+	}
+
+	env := []string{}
+	for key := range keys {
+		value, ok := b.buildArg(key)
+		if !ok {
+			continue
+		}
+		overridden := false
 		for _, keyval := range b.Config.Env {
-			tmp := strings.SplitN(keyval, "=", 2)
-			if tmp[0] == matchKey {
-				str = strings.Replace(str, match, tmp[1], -1)
+			if strings.SplitN(keyval, "=", 2)[0] == key {
+				overridden = true
 				break
 			}
 		}
+		if !overridden {
+			env = append(env, key+"="+value)
+		}
 	}
-
-	return str
+	sort.Strings(env)
+	return env
 }
 
 func handleJsonArgs(args []string, attributes map[string]bool) []string {
@@ -61,6 +61,7 @@ func dockerVersion(job *engine.Job) engine.Status {
 	v := &engine.Env{}
 	v.SetJson("Version", dockerversion.VERSION)
 	v.SetJson("ApiVersion", api.APIVERSION)
+	v.SetJson("MinAPIVersion", api.APIMINVERSION)
 	v.SetJson("GitCommit", dockerversion.GITCOMMIT)
 	v.Set("GoVersion", runtime.Version())
 	v.Set("Os", runtime.GOOS)
@@ -0,0 +1,286 @@
+// Package client is a Go client of the Docker remote API. Its methods take a
+// Context, satisfied by a context.Context, whose cancellation aborts the
+// request, including the streams returned by the daemon.
+//
+//	cli, err := client.NewClient("unix:///var/run/docker.sock", "", nil)
+//	if err != nil {
+//		return err
+//	}
+//	containers, err := cli.ContainerList(ctx, client.ContainerListOptions{All: true})
+package client
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api"
+	"github.com/docker/docker/pkg/parsers/filters"
+)
+
+// DefaultHost is the address of the daemon on the local unix socket
+const DefaultHost = "unix://" + api.DEFAULTUNIXSOCKET
+
+// UserAgent is the User-Agent of the requests of the client, it names the
+// version of the API of the package as the package may be built out of a
+// release of the daemon
+const UserAgent = "Docker-Go-Client/" + string(api.APIVERSION)
+
+// Context carries the cancellation of a request, a context.Context can be
+// passed to the methods of the client. A nil Context never cancels.
+type Context interface {
+	// Done is closed when the request is to be canceled
+	Done() <-chan struct{}
+	// Err returns why Done was closed
+	Err() error
+}
+
+// Error is a response of the daemon with an error status, Message is the
+// error it returned
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("Error response from daemon: %s", e.Message)
+}
+
+// IsNotFound returns whether err is a response of the daemon that the object
+// of the request does not exist
+func IsNotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// Client talks to the daemon listening at its host with a version of the API
+type Client struct {
+	proto     string
+	addr      string
+	scheme    string
+	version   string
+	transport *http.Transport
+	client    *http.Client
+}
+
+// NewClient returns a client of the daemon at host, either unix:///path or
+// tcp://host:port, using the version of the API, or the version of this
+// client when empty. The daemon is reached over TLS when tlsConfig is set.
+func NewClient(host, version string, tlsConfig *tls.Config) (*Client, error) {
+	parts := strings.SplitN(host, "://", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("Invalid daemon host %q, expected unix:///path or tcp://host:port", host)
+	}
+	proto, addr := parts[0], parts[1]
+	if version == "" {
+		version = string(api.APIVERSION)
+	}
+
+	tr := &http.Transport{
+		TLSClientConfig: tlsConfig,
+	}
+	timeout := 32 * time.Second
+	switch proto {
+	case "unix":
+		tr.DisableCompression = true
+		tr.Dial = func(_, _ string) (net.Conn, error) {
+			return net.DialTimeout(proto, addr, timeout)
+		}
+	case "tcp":
+		tr.Proxy = http.ProxyFromEnvironment
+		tr.Dial = (&net.Dialer{Timeout: timeout}).Dial
+	default:
+		return nil, fmt.Errorf("Unsupported protocol %s of the daemon host %q", proto, host)
+	}
+
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+	return &Client{
+		proto:     proto,
+		addr:      addr,
+		scheme:    scheme,
+		version:   version,
+		transport: tr,
+		client:    &http.Client{Transport: tr},
+	}, nil
+}
+
+// Version returns the version of the API used by the client
+func (cli *Client) Version() string {
+	return cli.version
+}
+
+// get sends a GET request and returns the response, whose body is to be
+// closed
+func (cli *Client) get(ctx Context, path string, query url.Values) (*http.Response, error) {
+	return cli.send(ctx, "GET", path, query, nil, nil)
+}
+
+// post sends a POST request with obj encoded in JSON unless nil
+func (cli *Client) post(ctx Context, path string, query url.Values, obj interface{}, headers map[string]string) (*http.Response, error) {
+	return cli.send(ctx, "POST", path, query, obj, headers)
+}
+
+func (cli *Client) delete(ctx Context, path string, query url.Values) (*http.Response, error) {
+	return cli.send(ctx, "DELETE", path, query, nil, nil)
+}
+
+func (cli *Client) send(ctx Context, method, path string, query url.Values, obj interface{}, headers map[string]string) (*http.Response, error) {
+	var body io.Reader
+	if obj != nil {
+		buf, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(buf)
+	} else if method == "POST" {
+		body = bytes.NewReader(nil)
+	}
+
+	u := &url.URL{
+		Scheme: cli.scheme,
+		Host:   cli.addr,
+		Path:   fmt.Sprintf("/v%s%s", cli.version, path),
+	}
+	if cli.proto == "unix" {
+		// the host is not sent on the socket, it only has to be valid
+		u.Host = "docker"
+	}
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	if obj != nil {
+		req.Header.Set("Content-Type", "application/json")
+	} else if method == "POST" {
+		req.Header.Set("Content-Type", "text/plain")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := cli.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		msg, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if len(msg) == 0 {
+			msg = []byte(http.StatusText(resp.StatusCode))
+		}
+		return nil, &Error{StatusCode: resp.StatusCode, Message: string(bytes.TrimSpace(msg))}
+	}
+	return resp, nil
+}
+
+// do sends the request, it is canceled when ctx is done until the body of
+// the response is closed
+func (cli *Client) do(ctx Context, req *http.Request) (*http.Response, error) {
+	if ctx == nil {
+		return cli.client.Do(req)
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			cli.transport.CancelRequest(req)
+		case <-finished:
+		}
+	}()
+	resp, err := cli.client.Do(req)
+	if err != nil {
+		close(finished)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		return nil, err
+	}
+	resp.Body = &cancelableBody{ReadCloser: resp.Body, ctx: ctx, finished: finished}
+	return resp, nil
+}
+
+// cancelableBody is the body of a response whose request is canceled with
+// its context, the read errors are then reported as the error of the context
+type cancelableBody struct {
+	io.ReadCloser
+	ctx      Context
+	finished chan struct{}
+	once     sync.Once
+}
+
+func (b *cancelableBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		select {
+		case <-b.ctx.Done():
+			err = b.ctx.Err()
+		default:
+		}
+	}
+	return n, err
+}
+
+func (b *cancelableBody) Close() error {
+	b.once.Do(func() { close(b.finished) })
+	return b.ReadCloser.Close()
+}
+
+// decode decodes the JSON body of the response into obj and closes it
+func decode(resp *http.Response, obj interface{}) error {
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(obj)
+}
+
+// discard closes the body of a response whose content is not used
+func discard(resp *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// setFilters sets the filters parameter of the query unless they are empty
+func setFilters(query url.Values, args filters.Args) error {
+	if len(args) == 0 {
+		return nil
+	}
+	param, err := filters.ToParam(args)
+	if err != nil {
+		return err
+	}
+	query.Set("filters", param)
+	return nil
+}
+
+func setBool(query url.Values, key string, value bool) {
+	if value {
+		query.Set(key, "1")
+	}
+}
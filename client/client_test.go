@@ -0,0 +1,169 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/pkg/parsers/filters"
+)
+
+type testContext struct {
+	done chan struct{}
+}
+
+var errTestCanceled = errors.New("canceled")
+
+func newTestContext() *testContext {
+	return &testContext{done: make(chan struct{})}
+}
+
+func (c *testContext) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *testContext) Err() error {
+	select {
+	case <-c.done:
+		return errTestCanceled
+	default:
+		return nil
+	}
+}
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*Client, *httptest.Server) {
+	srv := httptest.NewServer(handler)
+	cli, err := NewClient("tcp://"+strings.TrimPrefix(srv.URL, "http://"), "1.18", nil)
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	return cli, srv
+}
+
+func TestNewClientInvalidHost(t *testing.T) {
+	for _, host := range []string{"", "127.0.0.1:2375", "unix://", "udp://127.0.0.1:2375"} {
+		if _, err := NewClient(host, "", nil); err == nil {
+			t.Fatalf("Expected an error for the host %q", host)
+		}
+	}
+}
+
+func TestContainerList(t *testing.T) {
+	cli, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/v1.18/containers/json" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if ua := r.Header.Get("User-Agent"); ua != UserAgent {
+			t.Errorf("Expected the User-Agent %s, got %s", UserAgent, ua)
+		}
+		query := r.URL.Query()
+		if query.Get("all") != "1" || query.Get("limit") != "2" || query.Get("fields") != "Id,Ports" {
+			t.Errorf("Unexpected query %s", r.URL.RawQuery)
+		}
+		args, err := filters.FromParam(query.Get("filters"))
+		if err != nil || len(args["label"]) != 1 || args["label"][0] != "a=b" {
+			t.Errorf("Unexpected filters %s", query.Get("filters"))
+		}
+		fmt.Fprint(w, `[{"Id":"abc","Ports":[{"PrivatePort":80,"PublicPort":8080,"Type":"tcp"}]}]`)
+	})
+	defer srv.Close()
+
+	containers, err := cli.ContainerList(nil, ContainerListOptions{
+		All:     true,
+		Limit:   2,
+		Filters: filters.Args{"label": {"a=b"}},
+		Fields:  []string{"Id", "Ports"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 1 || containers[0].ID != "abc" {
+		t.Fatalf("Unexpected containers %v", containers)
+	}
+	if ports := containers[0].Ports; len(ports) != 1 || ports[0].PublicPort != 8080 {
+		t.Fatalf("Unexpected ports %v", ports)
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	cli, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such id: abc", http.StatusNotFound)
+	})
+	defer srv.Close()
+
+	_, err := cli.ContainerInspect(nil, "abc")
+	if !IsNotFound(err) {
+		t.Fatalf("Expected a not found error, got %v", err)
+	}
+	if msg := err.(*Error).Message; msg != "no such id: abc" {
+		t.Fatalf("Expected the message of the daemon, got %q", msg)
+	}
+}
+
+func TestCancelRequest(t *testing.T) {
+	release := make(chan struct{})
+	cli, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	defer srv.Close()
+	defer close(release)
+
+	ctx := newTestContext()
+	errs := make(chan error, 1)
+	go func() {
+		_, err := cli.ContainerWait(ctx, "abc", "")
+		errs <- err
+	}()
+	close(ctx.done)
+	select {
+	case err := <-errs:
+		if err != errTestCanceled {
+			t.Fatalf("Expected the error of the context, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The request was not canceled")
+	}
+}
+
+func TestCancelLogs(t *testing.T) {
+	release := make(chan struct{})
+	cli, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+		w.(http.Flusher).Flush()
+		<-release
+	})
+	defer srv.Close()
+	defer close(release)
+
+	ctx := newTestContext()
+	logs, err := cli.ContainerLogs(ctx, "abc", ContainerLogsOptions{Stdout: true, Follow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logs.Close()
+	buf := make([]byte, 5)
+	if _, err := logs.Read(buf); err != nil || string(buf) != "hello" {
+		t.Fatalf("Expected the logs hello, got %q, %v", buf, err)
+	}
+
+	close(ctx.done)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := ioutil.ReadAll(logs)
+		errs <- err
+	}()
+	select {
+	case err := <-errs:
+		if err != errTestCanceled {
+			t.Fatalf("Expected the error of the context, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The logs were not canceled")
+	}
+}
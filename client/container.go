@@ -0,0 +1,180 @@
+package client
+
+import (
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/parsers/filters"
+	"github.com/docker/docker/runconfig"
+)
+
+// ContainerListOptions are the options of the list of containers, the
+// running ones unless All is set. Limit and Offset paginate the list,
+// Fields restricts the fields returned for each container.
+type ContainerListOptions struct {
+	All     bool
+	Size    bool
+	Since   string
+	Before  string
+	Limit   int
+	Offset  int
+	Filters filters.Args
+	Fields  []string
+}
+
+// ContainerLogsOptions are the options of the logs of a container, Tail is
+// the number of lines shown from the end of the logs or "all"
+type ContainerLogsOptions struct {
+	Stdout     bool
+	Stderr     bool
+	Follow     bool
+	Timestamps bool
+	Tail       string
+}
+
+// ContainerRemoveOptions are the options of the removal of a container
+type ContainerRemoveOptions struct {
+	RemoveVolumes bool
+	RemoveLinks   bool
+	Force         bool
+}
+
+// ContainerList returns the containers of the daemon
+func (cli *Client) ContainerList(ctx Context, options ContainerListOptions) ([]types.Container, error) {
+	query := url.Values{}
+	setBool(query, "all", options.All)
+	setBool(query, "size", options.Size)
+	if options.Since != "" {
+		query.Set("since", options.Since)
+	}
+	if options.Before != "" {
+		query.Set("before", options.Before)
+	}
+	if options.Limit > 0 {
+		query.Set("limit", strconv.Itoa(options.Limit))
+	}
+	if options.Offset > 0 {
+		query.Set("offset", strconv.Itoa(options.Offset))
+	}
+	if len(options.Fields) > 0 {
+		query.Set("fields", strings.Join(options.Fields, ","))
+	}
+	if err := setFilters(query, options.Filters); err != nil {
+		return nil, err
+	}
+
+	resp, err := cli.get(ctx, "/containers/json", query)
+	if err != nil {
+		return nil, err
+	}
+	var containers []types.Container
+	if err := decode(resp, &containers); err != nil {
+		return nil, err
+	}
+	return containers, nil
+}
+
+// ContainerInspect returns the container with the name or ID
+func (cli *Client) ContainerInspect(ctx Context, name string) (*types.ContainerJSON, error) {
+	resp, err := cli.get(ctx, "/containers/"+name+"/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	var container types.ContainerJSON
+	if err := decode(resp, &container); err != nil {
+		return nil, err
+	}
+	return &container, nil
+}
+
+// ContainerCreate creates a container of the config, named after name unless
+// empty
+func (cli *Client) ContainerCreate(ctx Context, config *runconfig.Config, hostConfig *runconfig.HostConfig, name string) (*types.ContainerCreateResponse, error) {
+	if hostConfig == nil {
+		hostConfig = &runconfig.HostConfig{}
+	}
+	query := url.Values{}
+	if name != "" {
+		query.Set("name", name)
+	}
+	resp, err := cli.post(ctx, "/containers/create", query, runconfig.MergeConfigs(config, hostConfig), nil)
+	if err != nil {
+		return nil, err
+	}
+	var created types.ContainerCreateResponse
+	if err := decode(resp, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// ContainerStart starts the container, starting a running container is not
+// an error
+func (cli *Client) ContainerStart(ctx Context, name string) error {
+	return discard(cli.post(ctx, "/containers/"+name+"/start", nil, nil, nil))
+}
+
+// ContainerStop stops the container, it is killed when it is still running
+// after timeout seconds
+func (cli *Client) ContainerStop(ctx Context, name string, timeout int) error {
+	query := url.Values{}
+	query.Set("t", strconv.Itoa(timeout))
+	return discard(cli.post(ctx, "/containers/"+name+"/stop", query, nil, nil))
+}
+
+// ContainerKill sends the signal to the container, SIGKILL when empty
+func (cli *Client) ContainerKill(ctx Context, name, signal string) error {
+	query := url.Values{}
+	if signal != "" {
+		query.Set("signal", signal)
+	}
+	return discard(cli.post(ctx, "/containers/"+name+"/kill", query, nil, nil))
+}
+
+// ContainerRemove removes the container
+func (cli *Client) ContainerRemove(ctx Context, name string, options ContainerRemoveOptions) error {
+	query := url.Values{}
+	setBool(query, "v", options.RemoveVolumes)
+	setBool(query, "link", options.RemoveLinks)
+	setBool(query, "force", options.Force)
+	return discard(cli.delete(ctx, "/containers/"+name, query))
+}
+
+// ContainerWait waits for the container to reach the condition, or to exit
+// when empty, and returns its exit code
+func (cli *Client) ContainerWait(ctx Context, name, condition string) (int, error) {
+	query := url.Values{}
+	if condition != "" {
+		query.Set("condition", condition)
+	}
+	resp, err := cli.post(ctx, "/containers/"+name+"/wait", query, nil, nil)
+	if err != nil {
+		return -1, err
+	}
+	var wait types.ContainerWaitResponse
+	if err := decode(resp, &wait); err != nil {
+		return -1, err
+	}
+	return wait.StatusCode, nil
+}
+
+// ContainerLogs returns the logs of the container, which are multiplexed
+// with pkg/stdcopy unless it has a tty. The logs are to be closed.
+func (cli *Client) ContainerLogs(ctx Context, name string, options ContainerLogsOptions) (io.ReadCloser, error) {
+	query := url.Values{}
+	setBool(query, "stdout", options.Stdout)
+	setBool(query, "stderr", options.Stderr)
+	setBool(query, "follow", options.Follow)
+	setBool(query, "timestamps", options.Timestamps)
+	if options.Tail != "" {
+		query.Set("tail", options.Tail)
+	}
+	resp, err := cli.get(ctx, "/containers/"+name+"/logs", query)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/parsers/filters"
+)
+
+// ImageListOptions are the options of the list of images, the intermediate
+// images are only listed with All. Limit and Offset paginate the list,
+// Fields restricts the fields returned for each image.
+type ImageListOptions struct {
+	All     bool
+	Limit   int
+	Offset  int
+	Filters filters.Args
+	Fields  []string
+}
+
+// ImageRemoveOptions are the options of the removal of an image, its
+// untagged parents are kept with NoPrune
+type ImageRemoveOptions struct {
+	Force   bool
+	NoPrune bool
+}
+
+// ImagePullOptions are the options of the pull of an image, all its tags are
+// pulled when Tag is empty. RegistryAuth is the base64 encoded JSON of the
+// credentials of the registry, as sent in the X-Registry-Auth header.
+type ImagePullOptions struct {
+	Tag          string
+	RegistryAuth string
+}
+
+// ImageList returns the images of the daemon
+func (cli *Client) ImageList(ctx Context, options ImageListOptions) ([]types.Image, error) {
+	query := url.Values{}
+	setBool(query, "all", options.All)
+	if options.Limit > 0 {
+		query.Set("limit", strconv.Itoa(options.Limit))
+	}
+	if options.Offset > 0 {
+		query.Set("offset", strconv.Itoa(options.Offset))
+	}
+	if len(options.Fields) > 0 {
+		query.Set("fields", strings.Join(options.Fields, ","))
+	}
+	if err := setFilters(query, options.Filters); err != nil {
+		return nil, err
+	}
+
+	resp, err := cli.get(ctx, "/images/json", query)
+	if err != nil {
+		return nil, err
+	}
+	var images []types.Image
+	if err := decode(resp, &images); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// ImageRemove removes the image and returns the images untagged and deleted
+func (cli *Client) ImageRemove(ctx Context, name string, options ImageRemoveOptions) ([]types.ImageDelete, error) {
+	query := url.Values{}
+	setBool(query, "force", options.Force)
+	setBool(query, "noprune", options.NoPrune)
+	resp, err := cli.delete(ctx, "/images/"+name, query)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []types.ImageDelete
+	if err := decode(resp, &deleted); err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// ImagePull pulls the image and returns the JSON messages of its progress,
+// which are to be closed. The pull may have failed even though no error is
+// returned, the error is then the last message.
+func (cli *Client) ImagePull(ctx Context, name string, options ImagePullOptions) (io.ReadCloser, error) {
+	query := url.Values{}
+	query.Set("fromImage", name)
+	if options.Tag != "" {
+		query.Set("tag", options.Tag)
+	}
+	var headers map[string]string
+	if options.RegistryAuth != "" {
+		headers = map[string]string{"X-Registry-Auth": options.RegistryAuth}
+	}
+	resp, err := cli.post(ctx, "/images/create", query, nil, headers)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
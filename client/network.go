@@ -0,0 +1,69 @@
+package client
+
+import (
+	"net/url"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/parsers/filters"
+)
+
+// NetworkList returns the networks of the daemon matching the filters
+func (cli *Client) NetworkList(ctx Context, args filters.Args) ([]types.NetworkResource, error) {
+	query := url.Values{}
+	if err := setFilters(query, args); err != nil {
+		return nil, err
+	}
+	resp, err := cli.get(ctx, "/networks", query)
+	if err != nil {
+		return nil, err
+	}
+	var networks []types.NetworkResource
+	if err := decode(resp, &networks); err != nil {
+		return nil, err
+	}
+	return networks, nil
+}
+
+// NetworkInspect returns the network with the name or ID, the state of its
+// driver is only returned when verbose is set
+func (cli *Client) NetworkInspect(ctx Context, name string, verbose bool) (*types.NetworkResource, error) {
+	query := url.Values{}
+	setBool(query, "verbose", verbose)
+	resp, err := cli.get(ctx, "/networks/"+name, query)
+	if err != nil {
+		return nil, err
+	}
+	var network types.NetworkResource
+	if err := decode(resp, &network); err != nil {
+		return nil, err
+	}
+	return &network, nil
+}
+
+// NetworkCreate creates a network and returns its ID
+func (cli *Client) NetworkCreate(ctx Context, create types.NetworkCreate) (*types.NetworkCreateResponse, error) {
+	resp, err := cli.post(ctx, "/networks/create", nil, create, nil)
+	if err != nil {
+		return nil, err
+	}
+	var created types.NetworkCreateResponse
+	if err := decode(resp, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// NetworkRemove removes the network
+func (cli *Client) NetworkRemove(ctx Context, name string) error {
+	return discard(cli.delete(ctx, "/networks/"+name, nil))
+}
+
+// NetworkConnect connects a container to the network
+func (cli *Client) NetworkConnect(ctx Context, name string, connect types.NetworkConnect) error {
+	return discard(cli.post(ctx, "/networks/"+name+"/connect", nil, connect, nil))
+}
+
+// NetworkDisconnect disconnects a container from the network
+func (cli *Client) NetworkDisconnect(ctx Context, name, container string) error {
+	return discard(cli.post(ctx, "/networks/"+name+"/disconnect", nil, types.NetworkDisconnect{Container: container}, nil))
+}
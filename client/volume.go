@@ -0,0 +1,56 @@
+package client
+
+import (
+	"net/url"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/parsers/filters"
+)
+
+// VolumeList returns the volumes of the daemon matching the filters
+func (cli *Client) VolumeList(ctx Context, args filters.Args) ([]types.Volume, error) {
+	query := url.Values{}
+	if err := setFilters(query, args); err != nil {
+		return nil, err
+	}
+	resp, err := cli.get(ctx, "/volumes", query)
+	if err != nil {
+		return nil, err
+	}
+	var volumes []types.Volume
+	if err := decode(resp, &volumes); err != nil {
+		return nil, err
+	}
+	return volumes, nil
+}
+
+// VolumeInspect returns the volume with the name
+func (cli *Client) VolumeInspect(ctx Context, name string) (*types.Volume, error) {
+	resp, err := cli.get(ctx, "/volumes/"+name, nil)
+	if err != nil {
+		return nil, err
+	}
+	var volume types.Volume
+	if err := decode(resp, &volume); err != nil {
+		return nil, err
+	}
+	return &volume, nil
+}
+
+// VolumeCreate creates a volume, its name is generated when empty
+func (cli *Client) VolumeCreate(ctx Context, create types.VolumeCreate) (*types.Volume, error) {
+	resp, err := cli.post(ctx, "/volumes/create", nil, create, nil)
+	if err != nil {
+		return nil, err
+	}
+	var volume types.Volume
+	if err := decode(resp, &volume); err != nil {
+		return nil, err
+	}
+	return &volume, nil
+}
+
+// VolumeRemove removes the volume
+func (cli *Client) VolumeRemove(ctx Context, name string) error {
+	return discard(cli.delete(ctx, "/volumes/"+name, nil))
+}
@@ -0,0 +1,108 @@
+package daemon
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/execdriver/lxc"
+	"github.com/docker/libcontainer/apparmor"
+)
+
+const (
+	// defaultAppArmorProfile confines the containers without a profile
+	defaultAppArmorProfile = "docker-default"
+	// unconfinedAppArmorProfile is the profile of the privileged containers
+	unconfinedAppArmorProfile = "unconfined"
+
+	// appArmorProfilesPath lists the profiles loaded in the kernel with
+	// their mode
+	appArmorProfilesPath = "/sys/kernel/security/apparmor/profiles"
+)
+
+// loadAppArmorProfiles loads the profiles of the files of dir in the kernel,
+// replacing the profiles of the same names. Nothing is loaded when AppArmor
+// is disabled or dir does not exist.
+func loadAppArmorProfiles(dir string) error {
+	if dir == "" || !apparmor.IsEnabled() {
+		return nil
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, fi := range files {
+		// skip the editor backups and the abstractions included by the
+		// profiles
+		if !fi.Mode().IsRegular() || strings.HasPrefix(fi.Name(), ".") || strings.HasSuffix(fi.Name(), "~") {
+			continue
+		}
+		path := filepath.Join(dir, fi.Name())
+		cmd := exec.Command("/sbin/apparmor_parser", "-r", "-W", path)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("Error loading the AppArmor profiles of %s: %s (%s)", path, err, strings.TrimSpace(string(output)))
+		}
+		log.Debugf("Loaded the AppArmor profiles of %s", path)
+	}
+	return nil
+}
+
+// checkAppArmorProfile returns an error when the profile a container asks
+// for is not loaded in the kernel
+func checkAppArmorProfile(profile string) error {
+	if profile == "" || profile == unconfinedAppArmorProfile || !apparmor.IsEnabled() {
+		return nil
+	}
+	f, err := os.Open(appArmorProfilesPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if !hasAppArmorProfile(f, profile) {
+		return fmt.Errorf("The AppArmor profile %s is not loaded", profile)
+	}
+	return nil
+}
+
+// hasAppArmorProfile returns whether the profile is in the list of the
+// loaded profiles, whose lines are the names of the profiles followed by
+// their mode in parentheses
+func hasAppArmorProfile(r io.Reader, profile string) bool {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		name := s.Text()
+		if i := strings.LastIndex(name, " ("); i >= 0 {
+			name = name[:i]
+		}
+		if name == profile {
+			return true
+		}
+	}
+	return false
+}
+
+// appArmorProfile returns the profile the processes of the container run
+// with, empty when AppArmor is disabled. The lxc exec driver applies its own
+// default profiles.
+func (container *Container) appArmorProfile() string {
+	if !apparmor.IsEnabled() {
+		return ""
+	}
+	if container.AppArmorProfile != "" || strings.HasPrefix(container.daemon.execDriver.Name(), lxc.DriverName) {
+		return container.AppArmorProfile
+	}
+	if container.hostConfig != nil && container.hostConfig.Privileged {
+		return unconfinedAppArmorProfile
+	}
+	return defaultAppArmorProfile
+}
@@ -0,0 +1,20 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHasAppArmorProfile(t *testing.T) {
+	profiles := "docker-default (enforce)\n/usr/sbin/ntpd (enforce)\ncustom profile (complain)\n"
+	for _, profile := range []string{"docker-default", "/usr/sbin/ntpd", "custom profile"} {
+		if !hasAppArmorProfile(strings.NewReader(profiles), profile) {
+			t.Fatalf("Expected the profile %q to be loaded", profile)
+		}
+	}
+	for _, profile := range []string{"docker", "enforce", "custom"} {
+		if hasAppArmorProfile(strings.NewReader(profiles), profile) {
+			t.Fatalf("Expected the profile %q not to be loaded", profile)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 	"sync"
@@ -11,6 +12,7 @@ import (
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/pkg/jsonlog"
 	"github.com/docker/docker/pkg/promise"
+	"github.com/docker/docker/pkg/term"
 	"github.com/docker/docker/utils"
 )
 
@@ -33,6 +35,11 @@ func (daemon *Daemon) ContainerAttach(job *engine.Job) engine.Status {
 		return job.Error(err)
 	}
 
+	detachKeys, err := detachKeysFromJob(job, "detachKeys")
+	if err != nil {
+		return job.Error(err)
+	}
+
 	//logs
 	if logs {
 		cLog, err := container.ReadLog("json")
@@ -101,7 +108,21 @@ func (daemon *Daemon) ContainerAttach(job *engine.Job) engine.Status {
 			cStderr = job.Stderr
 		}
 
-		<-daemon.Attach(&container.StreamConfig, container.Config.OpenStdin, container.Config.StdinOnce, container.Config.Tty, cStdin, cStdout, cStderr)
+		attached := daemon.Attach(&container.StreamConfig, container.Config.OpenStdin, container.Config.StdinOnce, container.Config.Tty, detachKeys, cStdin, cStdout, cStderr)
+		select {
+		case <-attached:
+		case <-daemon.draining:
+			// tell the client the daemon is shutting down, its connection
+			// is closed once the job returns
+			if cStderr != nil {
+				eol := "\n"
+				if container.Config.Tty {
+					eol = "\r\n"
+				}
+				fmt.Fprintf(cStderr, "%s%s", drainMessage, eol)
+			}
+			return engine.StatusOK
+		}
 		// If we are in stdinonce mode, wait for the process to end
 		// otherwise, simply return
 		if container.Config.StdinOnce && !container.Config.Tty {
@@ -111,7 +132,21 @@ func (daemon *Daemon) ContainerAttach(job *engine.Job) engine.Status {
 	return engine.StatusOK
 }
 
-func (daemon *Daemon) Attach(streamConfig *StreamConfig, openStdin, stdinOnce, tty bool, stdin io.ReadCloser, stdout io.Writer, stderr io.Writer) chan error {
+// detachKeysFromJob returns the escape sequence to detach from a tty given in
+// the key job variable. The default sequence is used when the variable is not
+// set, and an empty one disables detaching.
+func detachKeysFromJob(job *engine.Job, key string) ([]byte, error) {
+	if !job.EnvExists(key) {
+		return term.ToBytes(term.DefaultDetachKeys)
+	}
+	detachKeys, err := term.ToBytes(job.Getenv(key))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid detach keys (%s): %s", job.Getenv(key), err)
+	}
+	return detachKeys, nil
+}
+
+func (daemon *Daemon) Attach(streamConfig *StreamConfig, openStdin, stdinOnce, tty bool, detachKeys []byte, stdin io.ReadCloser, stdout io.Writer, stderr io.Writer) chan error {
 	var (
 		cStdout, cStderr io.ReadCloser
 		cStdin           io.WriteCloser
@@ -158,7 +193,7 @@ func (daemon *Daemon) Attach(streamConfig *StreamConfig, openStdin, stdinOnce, t
 
 		var err error
 		if tty {
-			_, err = utils.CopyEscapable(cStdin, stdin)
+			_, err = utils.CopyEscapable(cStdin, stdin, detachKeys)
 		} else {
 			_, err = io.Copy(cStdin, stdin)
 
@@ -2,8 +2,10 @@ package daemon
 
 import (
 	"net"
+	"time"
 
 	"github.com/docker/docker/daemon/networkdriver"
+	"github.com/docker/docker/graph"
 	"github.com/docker/docker/opts"
 	flag "github.com/docker/docker/pkg/mflag"
 	"github.com/docker/docker/pkg/ulimit"
@@ -24,15 +26,18 @@ type Config struct {
 	AutoRestart                 bool
 	Dns                         []string
 	DnsSearch                   []string
+	DnsOptions                  []string
 	EnableIPv6                  bool
 	EnableIptables              bool
 	EnableIpForward             bool
 	EnableIpMasq                bool
 	DefaultIp                   net.IP
+	PortRange                   string
 	BridgeIface                 string
 	BridgeIP                    string
 	FixedCIDR                   string
 	FixedCIDRv6                 string
+	IPv6Pool                    string
 	InterContainerCommunication bool
 	GraphDriver                 string
 	GraphOptions                []string
@@ -41,12 +46,28 @@ type Config struct {
 	SocketGroup                 string
 	EnableCors                  bool
 	CorsHeaders                 string
+	AuthorizationPlugins        []string
 	DisableNetwork              bool
 	EnableSelinuxSupport        bool
 	Context                     map[string][]string
 	TrustKeyPath                string
 	Labels                      []string
 	Ulimits                     map[string]*ulimit.Ulimit
+	Init                        bool
+	CpuRtRuntime                int64
+	DefaultShmSize              string
+	ContainerGCAge              time.Duration
+	ContainerGCExcludeLabels    []string
+	CgroupParent                string
+	MaxConcurrentDownloads      int
+	MaxConcurrentUploads        int
+	ContentTrustPolicy          string
+	ContentTrustServer          string
+	IdleTimeout                 time.Duration
+	AuditLog                    string
+	ShutdownTimeout             time.Duration
+	AppArmorProfilesDir         string
+	NoNewPrivileges             bool
 }
 
 // InstallFlags adds command-line options to the top-level flag parser for
@@ -65,19 +86,38 @@ func (config *Config) InstallFlags() {
 	flag.StringVar(&config.BridgeIface, []string{"b", "-bridge"}, "", "Attach containers to a network bridge")
 	flag.StringVar(&config.FixedCIDR, []string{"-fixed-cidr"}, "", "IPv4 subnet for fixed IPs")
 	flag.StringVar(&config.FixedCIDRv6, []string{"-fixed-cidr-v6"}, "", "IPv6 subnet for fixed IPs")
+	flag.StringVar(&config.IPv6Pool, []string{"-ipv6-pool"}, "", "IPv6 pool user-defined networks get a /64 from")
 	flag.BoolVar(&config.InterContainerCommunication, []string{"#icc", "-icc"}, true, "Enable inter-container communication")
 	flag.StringVar(&config.GraphDriver, []string{"s", "-storage-driver"}, "", "Storage driver to use")
 	flag.StringVar(&config.ExecDriver, []string{"e", "-exec-driver"}, "native", "Exec driver to use")
 	flag.BoolVar(&config.EnableSelinuxSupport, []string{"-selinux-enabled"}, false, "Enable selinux support")
+	flag.StringVar(&config.AppArmorProfilesDir, []string{"-apparmor-profiles-dir"}, "/etc/docker/apparmor.d", "Directory of the AppArmor profiles loaded on startup")
+	flag.BoolVar(&config.NoNewPrivileges, []string{"-no-new-privileges"}, false, "Run the processes of containers with no-new-privileges by default")
+	flag.BoolVar(&config.Init, []string{"-init"}, false, "Run an init in containers to forward signals and reap processes")
+	flag.StringVar(&config.DefaultShmSize, []string{"-default-shm-size"}, "64m", "Default size of /dev/shm for containers")
+	flag.StringVar(&config.CgroupParent, []string{"-cgroup-parent"}, "", "Set parent cgroup for all containers")
+	flag.Int64Var(&config.CpuRtRuntime, []string{"-cpu-rt-runtime"}, 0, "Real-time runtime in microseconds reserved for containers")
+	flag.DurationVar(&config.ContainerGCAge, []string{"-container-gc-age"}, 0, "Remove containers that exited longer ago than this duration, 0 to disable")
+	opts.ListVar(&config.ContainerGCExcludeLabels, []string{"-container-gc-exclude-label"}, "Never remove exited containers with this label (key or key=value)")
+	flag.IntVar(&config.MaxConcurrentDownloads, []string{"-max-concurrent-downloads"}, graph.DefaultMaxConcurrentDownloads, "Maximum number of layers downloaded at once by the pulls")
+	flag.IntVar(&config.MaxConcurrentUploads, []string{"-max-concurrent-uploads"}, graph.DefaultMaxConcurrentUploads, "Maximum number of layers uploaded at once by the pushes")
+	flag.StringVar(&config.ContentTrustPolicy, []string{"-content-trust-policy"}, graph.TrustPolicyNone, "Images the daemon pulls, by tag or digest (none) or signed digests only (signed)")
+	flag.StringVar(&config.ContentTrustServer, []string{"-content-trust-server"}, "", "Trust server with the signed digests for the signed content trust policy")
 	flag.IntVar(&config.Mtu, []string{"#mtu", "-mtu"}, 0, "Set the containers network MTU")
 	flag.StringVar(&config.SocketGroup, []string{"G", "-group"}, "docker", "Group for the unix socket")
+	flag.DurationVar(&config.IdleTimeout, []string{"-idle-timeout"}, 0, "Exit when idle for this duration with -H fd://, 0 to disable")
+	flag.StringVar(&config.AuditLog, []string{"-audit-log"}, "", "Append the state-changing API requests to this audit log file")
+	flag.DurationVar(&config.ShutdownTimeout, []string{"-shutdown-timeout"}, DefaultStopTimeout*time.Second, "Time the containers are given to stop when the daemon shuts down before they are killed")
+	opts.ListVar(&config.AuthorizationPlugins, []string{"-authorization-plugin"}, "Authorization plugins to consult before and after every API request")
 	flag.BoolVar(&config.EnableCors, []string{"#api-enable-cors", "#-api-enable-cors"}, false, "Enable CORS headers in the remote API, this is deprecated by --api-cors-header")
 	flag.StringVar(&config.CorsHeaders, []string{"-api-cors-header"}, "", "Set CORS headers in the remote API")
 	opts.IPVar(&config.DefaultIp, []string{"#ip", "-ip"}, "0.0.0.0", "Default IP when binding container ports")
+	flag.StringVar(&config.PortRange, []string{"-port-range"}, "49153-65535", "Range of host ports for dynamically published ports")
 	opts.ListVar(&config.GraphOptions, []string{"-storage-opt"}, "Set storage driver options")
 	// FIXME: why the inconsistency between "hosts" and "sockets"?
 	opts.IPListVar(&config.Dns, []string{"#dns", "-dns"}, "DNS server to use")
 	opts.DnsSearchListVar(&config.DnsSearch, []string{"-dns-search"}, "DNS search domains to use")
+	opts.ListVar(&config.DnsOptions, []string{"-dns-opt"}, "DNS options to use")
 	opts.LabelListVar(&config.Labels, []string{"-label"}, "Set key=value labels to the daemon")
 	config.Ulimits = make(map[string]*ulimit.Ulimit)
 	opts.UlimitMapVar(config.Ulimits, []string{"-default-ulimit"}, "Set default ulimits for containers")
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"path"
 	"path/filepath"
@@ -20,24 +21,36 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/daemon/execdriver"
 	"github.com/docker/docker/engine"
+	"github.com/docker/docker/events"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/links"
 	"github.com/docker/docker/nat"
+	"github.com/docker/docker/networks"
+	"github.com/docker/docker/opts"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/broadcastwriter"
+	"github.com/docker/docker/pkg/chrootarchive"
 	"github.com/docker/docker/pkg/common"
 	"github.com/docker/docker/pkg/directory"
 	"github.com/docker/docker/pkg/ioutils"
 	"github.com/docker/docker/pkg/networkfs/etchosts"
 	"github.com/docker/docker/pkg/networkfs/resolvconf"
 	"github.com/docker/docker/pkg/promise"
+	"github.com/docker/docker/pkg/reaper"
+	"github.com/docker/docker/pkg/signal"
 	"github.com/docker/docker/pkg/symlink"
 	"github.com/docker/docker/pkg/ulimit"
 	"github.com/docker/docker/runconfig"
 	"github.com/docker/docker/utils"
 )
 
-const DefaultPathEnv = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+const (
+	DefaultPathEnv = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+	// DefaultStopTimeout is the number of seconds a container is given to
+	// exit after receiving its stop signal before it is killed.
+	DefaultStopTimeout = 10
+)
 
 var (
 	ErrNotATTY               = errors.New("The PTY is not a file")
@@ -84,6 +97,7 @@ type Container struct {
 	daemon                   *Daemon
 	MountLabel, ProcessLabel string
 	AppArmorProfile          string
+	NoNewPrivileges          bool
 	RestartCount             int
 	UpdateDns                bool
 
@@ -100,6 +114,11 @@ type Container struct {
 	monitor            *containerMonitor
 	execCommands       *execStore
 	AppliedVolumesFrom map[string]struct{}
+	// Maps container paths to the IDs of the named volumes mounted there
+	NamedVolumes map[string]string
+	// The IDs of the named volumes mounted by their driver until the
+	// container stops, they are unmounted after a restart of the daemon
+	MountedVolumes []string
 }
 
 func (container *Container) FromDisk() error {
@@ -192,7 +211,16 @@ func (container *Container) WriteHostConfig() error {
 
 func (container *Container) LogEvent(action string) {
 	d := container.daemon
-	if err := d.eng.Job("log", action, container.ID, d.Repositories().ImageName(container.ImageID)).Run(); err != nil {
+	image := d.Repositories().ImageName(container.ImageID)
+	attributes := map[string]string{}
+	if container.Config != nil {
+		for k, v := range container.Config.Labels {
+			attributes[k] = v
+		}
+	}
+	attributes["image"] = image
+	attributes["name"] = strings.TrimPrefix(container.Name, "/")
+	if err := events.LogEvent(d.eng, events.ContainerEventType, action, container.ID, image, attributes); err != nil {
 		log.Errorf("Error logging event %s for %s: %s", action, container.ID, err)
 	}
 }
@@ -240,7 +268,37 @@ func populateCommand(c *Container, env []string) error {
 		}
 		en.ContainerID = nc.ID
 	default:
-		return fmt.Errorf("invalid network mode: %s", c.hostConfig.NetworkMode)
+		if !c.hostConfig.NetworkMode.IsUserDefined() {
+			return fmt.Errorf("invalid network mode: %s", c.hostConfig.NetworkMode)
+		}
+		n, err := c.daemon.networks.Get(string(c.hostConfig.NetworkMode))
+		if err != nil {
+			return err
+		}
+		network := c.NetworkSettings
+		en.Interface = &execdriver.NetworkInterface{
+			Gateway:             network.Gateway,
+			IPAddress:           network.IPAddress,
+			IPPrefixLen:         network.IPPrefixLen,
+			MacAddress:          network.MacAddress,
+			GlobalIPv6Address:   network.GlobalIPv6Address,
+			GlobalIPv6PrefixLen: network.GlobalIPv6PrefixLen,
+			IPv6Gateway:         network.IPv6Gateway,
+		}
+		if err := joinNetwork(n, en.Interface); err != nil {
+			return err
+		}
+	}
+	for _, name := range c.endpointNames() {
+		n, err := c.daemon.networks.Get(name)
+		if err != nil {
+			return err
+		}
+		iface, err := c.endpointInterface(n, c.NetworkSettings.Networks[name])
+		if err != nil {
+			return err
+		}
+		en.Endpoints = append(en.Endpoints, iface)
 	}
 
 	ipc := &execdriver.Ipc{}
@@ -270,6 +328,15 @@ func populateCommand(c *Container, env []string) error {
 	}
 	allowedDevices := append(devices.DefaultAllowedDevices, userSpecifiedDevices...)
 
+	// Rules only grant access in the devices cgroup, no node is created for them
+	for _, rule := range c.hostConfig.DeviceCgroupRules {
+		device, err := deviceFromCgroupRule(rule)
+		if err != nil {
+			return err
+		}
+		allowedDevices = append(allowedDevices, device)
+	}
+
 	autoCreatedDevices := append(devices.DefaultAutoCreatedDevices, userSpecifiedDevices...)
 
 	// TODO: this can be removed after lxc-conf is fully deprecated
@@ -301,11 +368,14 @@ func populateCommand(c *Container, env []string) error {
 	}
 
 	resources := &execdriver.Resources{
-		Memory:     c.Config.Memory,
-		MemorySwap: c.Config.MemorySwap,
-		CpuShares:  c.Config.CpuShares,
-		Cpuset:     c.Config.Cpuset,
-		Rlimits:    rlimits,
+		Memory:       c.Config.Memory,
+		MemorySwap:   c.Config.MemorySwap,
+		CpuShares:    c.Config.CpuShares,
+		Cpuset:       c.Config.Cpuset,
+		PidsLimit:    c.hostConfig.PidsLimit,
+		CpuRtPeriod:  c.hostConfig.CpuRtPeriod,
+		CpuRtRuntime: c.hostConfig.CpuRtRuntime,
+		Rlimits:      rlimits,
 	}
 
 	processConfig := execdriver.ProcessConfig{
@@ -316,6 +386,11 @@ func populateCommand(c *Container, env []string) error {
 		User:       c.Config.User,
 	}
 
+	if c.initEnabled() {
+		processConfig.Entrypoint = reaper.InitPath
+		processConfig.Arguments = append([]string{"--", c.Path}, c.Args...)
+	}
+
 	processConfig.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
 	processConfig.Env = env
 
@@ -338,11 +413,32 @@ func populateCommand(c *Container, env []string) error {
 		MountLabel:         c.GetMountLabel(),
 		LxcConfig:          lxcConfig,
 		AppArmorProfile:    c.AppArmorProfile,
+		NoNewPrivileges:    c.NoNewPrivileges,
+		Tmpfs:              c.hostConfig.Tmpfs,
+		GroupAdd:           c.hostConfig.GroupAdd,
+		ShmSize:            c.shmSize(),
+		OomScoreAdj:        c.hostConfig.OomScoreAdj,
+		CgroupParent:       c.cgroupParent(),
 	}
 
 	return nil
 }
 
+// deviceFromCgroupRule converts a devices cgroup rule such as "c 189:* rwm"
+// into a device entry without a path.
+func deviceFromCgroupRule(val string) (*devices.Device, error) {
+	rule, err := opts.ParseDeviceCgroupRule(val)
+	if err != nil {
+		return nil, err
+	}
+	return &devices.Device{
+		Type:              rule.Type,
+		MajorNumber:       rule.Major,
+		MinorNumber:       rule.Minor,
+		CgroupPermissions: rule.Permissions,
+	}, nil
+}
+
 func (container *Container) Start() (err error) {
 	container.Lock()
 	defer container.Unlock()
@@ -378,7 +474,7 @@ func (container *Container) Start() (err error) {
 		return err
 	}
 	container.verifyDaemonSettings()
-	if err := container.prepareVolumes(); err != nil {
+	if err := container.prepareVolumes(true); err != nil {
 		return err
 	}
 	linkedEnv, err := container.setupLinkedContainers()
@@ -499,6 +595,9 @@ func (container *Container) buildHostsFiles(IP string) error {
 		extraContent = append(extraContent, etchosts.Record{Hosts: parts[0], IP: parts[1]})
 	}
 
+	if container.hostConfig.NetworkMode.IsUserDefined() || len(container.NetworkSettings.Networks) > 0 {
+		return container.buildNetworkHostsFile(IP, extraContent)
+	}
 	return etchosts.Build(container.HostsPath, IP, container.Config.Hostname, container.Config.Domainname, extraContent)
 }
 
@@ -511,10 +610,24 @@ func (container *Container) buildHostnameAndHostsFiles(IP string) error {
 }
 
 func (container *Container) AllocateNetwork() error {
+	if err := container.allocateNetworkMode(); err != nil {
+		return err
+	}
+	if err := container.allocateEndpoints(false); err != nil {
+		container.ReleaseNetwork()
+		return err
+	}
+	return nil
+}
+
+func (container *Container) allocateNetworkMode() error {
 	mode := container.hostConfig.NetworkMode
 	if container.Config.NetworkDisabled || !mode.IsPrivate() {
 		return nil
 	}
+	if mode.IsUserDefined() {
+		return container.allocateUserDefinedNetwork(container.Config.MacAddress, container.hostConfig.IPAddress, container.hostConfig.IPv6Address)
+	}
 
 	var (
 		env *engine.Env
@@ -597,12 +710,20 @@ func (container *Container) ReleaseNetwork() {
 	if container.Config.NetworkDisabled || !container.hostConfig.NetworkMode.IsPrivate() {
 		return
 	}
+	container.releaseEndpoints()
+	// the other networks of the container are kept for its next start
+	endpoints := container.NetworkSettings.Networks
+	if container.hostConfig.NetworkMode.IsUserDefined() {
+		container.releaseUserDefinedNetwork()
+		container.NetworkSettings = &NetworkSettings{Networks: endpoints}
+		return
+	}
 	eng := container.daemon.eng
 
 	job := eng.Job("release_interface", container.ID)
 	job.SetenvBool("overrideShutdown", true)
 	job.Run()
-	container.NetworkSettings = &NetworkSettings{}
+	container.NetworkSettings = &NetworkSettings{Networks: endpoints}
 }
 
 func (container *Container) isNetworkAllocated() bool {
@@ -610,6 +731,16 @@ func (container *Container) isNetworkAllocated() bool {
 }
 
 func (container *Container) RestoreNetwork() error {
+	if err := container.restoreNetworkMode(); err != nil {
+		return err
+	}
+	if !container.isNetworkAllocated() {
+		return nil
+	}
+	return container.allocateEndpoints(true)
+}
+
+func (container *Container) restoreNetworkMode() error {
 	mode := container.hostConfig.NetworkMode
 	// Don't attempt a restore if we previously didn't allocate networking.
 	// This might be a legacy container with no network allocated, in which case the
@@ -618,6 +749,10 @@ func (container *Container) RestoreNetwork() error {
 		return nil
 	}
 
+	if mode.IsUserDefined() {
+		return container.allocateUserDefinedNetwork(container.NetworkSettings.MacAddress, container.NetworkSettings.IPAddress, container.NetworkSettings.GlobalIPv6Address)
+	}
+
 	eng := container.daemon.eng
 
 	// Re-allocate the interface with the same IP and MAC address.
@@ -637,6 +772,56 @@ func (container *Container) RestoreNetwork() error {
 	return nil
 }
 
+// allocateUserDefinedNetwork connects the container to its user-defined
+// network. The containers of such networks are reachable on their own
+// address, their ports are not mapped on the host.
+func (container *Container) allocateUserDefinedNetwork(requestedMac, requestedIP, requestedIPv6 string) error {
+	if len(container.hostConfig.PortBindings) > 0 || container.hostConfig.PublishAllPorts {
+		return fmt.Errorf("Ports cannot be published on network %s, containers are directly reachable on their address", container.hostConfig.NetworkMode)
+	}
+	n, err := container.daemon.networks.Get(string(container.hostConfig.NetworkMode))
+	if err != nil {
+		return err
+	}
+
+	ip, ipv6, mac, err := container.allocateAddresses(n, requestedMac, requestedIP, requestedIPv6)
+	if err != nil {
+		return err
+	}
+	n.AddContainer(container.ID)
+
+	container.NetworkSettings.IPAddress = ip.String()
+	container.NetworkSettings.IPPrefixLen = n.PrefixLen()
+	container.NetworkSettings.MacAddress = mac.String()
+	if !n.Internal {
+		container.NetworkSettings.Gateway = n.Gateway
+	}
+	if ipv6 != nil {
+		container.NetworkSettings.GlobalIPv6Address = ipv6.String()
+		container.NetworkSettings.GlobalIPv6PrefixLen = n.IPv6PrefixLen()
+		if !n.Internal {
+			container.NetworkSettings.IPv6Gateway = n.IPv6Gateway
+		}
+	}
+	return nil
+}
+
+func (container *Container) releaseUserDefinedNetwork() {
+	n, err := container.daemon.networks.Get(string(container.hostConfig.NetworkMode))
+	if err != nil {
+		log.Errorf("Error releasing the network of %s: %v", container.ID, err)
+		return
+	}
+	if ip := net.ParseIP(container.NetworkSettings.IPAddress); ip != nil {
+		n.ReleaseIP(ip)
+	}
+	if ip := net.ParseIP(container.NetworkSettings.GlobalIPv6Address); ip != nil {
+		n.ReleaseIPv6(ip)
+	}
+	container.removeNetworkHosts(n)
+	n.RemoveContainer(container.ID)
+}
+
 // cleanup releases any network resources allocated to the container along with any rules
 // around how containers are linked together.  It also unmounts the container's root filesystem.
 func (container *Container) cleanup() {
@@ -652,10 +837,7 @@ func (container *Container) cleanup() {
 	if err := container.Unmount(); err != nil {
 		log.Errorf("%v: Failed to umount filesystem: %v", container.ID, err)
 	}
-
-	for _, eConfig := range container.execCommands.s {
-		container.daemon.unregisterExecCommand(eConfig)
-	}
+	container.unmountVolumes()
 }
 
 func (container *Container) KillSig(sig int) error {
@@ -749,9 +931,10 @@ func (container *Container) Stop(seconds int) error {
 		return nil
 	}
 
-	// 1. Send a SIGTERM
-	if err := container.killPossiblyDeadProcess(15); err != nil {
-		log.Infof("Failed to send SIGTERM to the process, force killing")
+	// 1. Send the stop signal (SIGTERM unless the container overrides it)
+	stopSignal := container.stopSignal()
+	if err := container.killPossiblyDeadProcess(stopSignal); err != nil {
+		log.Infof("Failed to send signal %d to the process, force killing", stopSignal)
 		if err := container.killPossiblyDeadProcess(9); err != nil {
 			return err
 		}
@@ -759,7 +942,7 @@ func (container *Container) Stop(seconds int) error {
 
 	// 2. Wait for the process to exit on its own
 	if _, err := container.WaitStop(time.Duration(seconds) * time.Second); err != nil {
-		log.Infof("Container %v failed to exit within %d seconds of SIGTERM - using the force", container.ID, seconds)
+		log.Infof("Container %v failed to exit within %d seconds of signal %d - using the force", container.ID, seconds, stopSignal)
 		// 3. If it doesn't, then send SIGKILL
 		if err := container.Kill(); err != nil {
 			container.WaitStop(-1 * time.Second)
@@ -769,6 +952,53 @@ func (container *Container) Stop(seconds int) error {
 	return nil
 }
 
+// stopSignal returns the signal used to ask the container's main process
+// to terminate, as configured by the image (STOPSIGNAL) or at creation.
+func (container *Container) stopSignal() int {
+	if container.Config.StopSignal != "" {
+		if sig, err := signal.ParseSignal(container.Config.StopSignal); err == nil {
+			return int(sig)
+		}
+	}
+	return int(syscall.SIGTERM)
+}
+
+// stopTimeout returns the number of seconds to wait for the container to
+// exit after its stop signal, when the caller didn't specify one.
+func (container *Container) stopTimeout() int {
+	if container.Config.StopTimeout != nil {
+		return *container.Config.StopTimeout
+	}
+	return DefaultStopTimeout
+}
+
+// shmSize returns the size of the container's /dev/shm in bytes, falling back
+// to the daemon default.
+func (container *Container) shmSize() int64 {
+	if container.hostConfig != nil && container.hostConfig.ShmSize > 0 {
+		return container.hostConfig.ShmSize
+	}
+	return container.daemon.defaultShmSize
+}
+
+// cgroupParent returns the parent cgroup of the container, falling back to
+// the daemon default.
+func (container *Container) cgroupParent() string {
+	if container.hostConfig != nil && container.hostConfig.CgroupParent != "" {
+		return container.hostConfig.CgroupParent
+	}
+	return container.daemon.config.CgroupParent
+}
+
+// initEnabled reports whether an init process should be injected as PID 1,
+// falling back to the daemon default when the container does not say.
+func (container *Container) initEnabled() bool {
+	if container.hostConfig != nil && container.hostConfig.Init != nil {
+		return *container.hostConfig.Init
+	}
+	return container.daemon.config.Init
+}
+
 func (container *Container) Restart(seconds int) error {
 	// Avoid unnecessarily unmounting and then directly mounting
 	// the container when the container stops and then starts
@@ -909,7 +1139,7 @@ func (container *Container) GetSize() (int64, int64) {
 		sizeRw = -1
 	}
 
-	if _, err = os.Stat(container.basefs); err != nil {
+	if _, err = os.Stat(container.basefs); err == nil {
 		if sizeRootfs, err = directory.Size(container.basefs); err != nil {
 			sizeRootfs = -1
 		}
@@ -917,7 +1147,9 @@ func (container *Container) GetSize() (int64, int64) {
 	return sizeRw, sizeRootfs
 }
 
-func (container *Container) Copy(resource string) (io.ReadCloser, error) {
+// Copy returns a tar of the resource of the container, archiveMode archives
+// all the extended attributes of the files
+func (container *Container) Copy(resource string, archiveMode bool) (io.ReadCloser, error) {
 	if err := container.Mount(); err != nil {
 		return nil, err
 	}
@@ -931,7 +1163,7 @@ func (container *Container) Copy(resource string) (io.ReadCloser, error) {
 	// Check if this is actually in a volume
 	for _, mnt := range container.VolumeMounts() {
 		if len(mnt.MountToPath) > 0 && strings.HasPrefix(resource, mnt.MountToPath[1:]) {
-			return mnt.Export(resource)
+			return mnt.Export(resource, archiveMode)
 		}
 	}
 
@@ -951,8 +1183,9 @@ func (container *Container) Copy(resource string) (io.ReadCloser, error) {
 	}
 
 	archive, err := archive.TarWithOptions(basePath, &archive.TarOptions{
-		Compression:  archive.Uncompressed,
-		IncludeFiles: filter,
+		Compression:   archive.Uncompressed,
+		IncludeFiles:  filter,
+		IncludeXattrs: archiveMode,
 	})
 	if err != nil {
 		container.Unmount()
@@ -966,6 +1199,52 @@ func (container *Container) Copy(resource string) (io.ReadCloser, error) {
 		nil
 }
 
+// CopyTo unpacks the tar data in the directory path of the container, which
+// is created when it doesn't exist. The files are owned by root, unless
+// archiveMode keeps the owners recorded in the tar.
+func (container *Container) CopyTo(path string, data io.Reader, archiveMode bool) error {
+	if err := container.Mount(); err != nil {
+		return err
+	}
+	defer container.Unmount()
+
+	dest, writable, err := container.getCopyDestination(path)
+	if err != nil {
+		return err
+	}
+	if !writable {
+		return fmt.Errorf("Cannot copy to %s, it is read-only in container %s", path, container.ID)
+	}
+	options := &archive.TarOptions{}
+	if !archiveMode {
+		options.ChownOpts = &archive.TarChownOptions{UID: 0, GID: 0}
+	}
+	return chrootarchive.Untar(data, dest, options)
+}
+
+// getCopyDestination returns the path on the host of the path of the
+// container and whether it is writable, the paths in the volumes of the
+// container are in the volumes
+func (container *Container) getCopyDestination(path string) (string, bool, error) {
+	path = filepath.Join("/", path)
+	var volume *Mount
+	for mountToPath, mnt := range container.VolumeMounts() {
+		if path != mountToPath && !strings.HasPrefix(path, mountToPath+"/") {
+			continue
+		}
+		// the innermost volume holds the path
+		if volume == nil || len(mountToPath) > len(volume.MountToPath) {
+			volume = mnt
+		}
+	}
+	if volume != nil {
+		dest, err := symlink.FollowSymlinkInScope(filepath.Join(volume.volume.Path, strings.TrimPrefix(path, volume.MountToPath)), volume.volume.Path)
+		return dest, volume.Writable, err
+	}
+	dest, err := container.getResourcePath(path)
+	return dest, !container.hostConfig.ReadonlyRootfs, err
+}
+
 // Returns true if the container exposes a certain port
 func (container *Container) Exposes(p nat.Port) bool {
 	_, exists := container.Config.ExposedPorts[p]
@@ -1003,6 +1282,16 @@ func (container *Container) DisableLink(name string) {
 	}
 }
 
+// firstNonEmpty returns the first of lists that is not empty, or nil
+func firstNonEmpty(lists ...[]string) []string {
+	for _, l := range lists {
+		if len(l) > 0 {
+			return l
+		}
+	}
+	return nil
+}
+
 func (container *Container) setupContainerDns() error {
 	if container.ResolvConfPath != "" {
 		// check if this is an existing container that needs DNS update:
@@ -1046,23 +1335,33 @@ func (container *Container) setupContainerDns() error {
 	}
 
 	if config.NetworkMode != "host" {
-		// check configurations for any container/daemon dns settings
-		if len(config.Dns) > 0 || len(daemon.config.Dns) > 0 || len(config.DnsSearch) > 0 || len(daemon.config.DnsSearch) > 0 {
-			var (
-				dns       = resolvconf.GetNameservers(resolvConf)
+		var networkDns networks.DNSConfig
+		if config.NetworkMode.IsUserDefined() {
+			n, err := daemon.networks.Get(string(config.NetworkMode))
+			if err != nil {
+				return err
+			}
+			networkDns = n.DNS
+		}
+
+		// check configurations for any container/network/daemon dns settings,
+		// in that order of precedence
+		var (
+			dns        = firstNonEmpty(config.Dns, networkDns.Nameservers, daemon.config.Dns)
+			dnsSearch  = firstNonEmpty(config.DnsSearch, networkDns.Search, daemon.config.DnsSearch)
+			dnsOptions = firstNonEmpty(config.DnsOptions, networkDns.Options, daemon.config.DnsOptions)
+		)
+		if dns != nil || dnsSearch != nil || dnsOptions != nil {
+			if dns == nil {
+				dns = resolvconf.GetNameservers(resolvConf)
+			}
+			if dnsSearch == nil {
 				dnsSearch = resolvconf.GetSearchDomains(resolvConf)
-			)
-			if len(config.Dns) > 0 {
-				dns = config.Dns
-			} else if len(daemon.config.Dns) > 0 {
-				dns = daemon.config.Dns
 			}
-			if len(config.DnsSearch) > 0 {
-				dnsSearch = config.DnsSearch
-			} else if len(daemon.config.DnsSearch) > 0 {
-				dnsSearch = daemon.config.DnsSearch
+			if dnsOptions == nil {
+				dnsOptions = resolvconf.GetOptions(resolvConf)
 			}
-			return resolvconf.Build(container.ResolvConfPath, dns, dnsSearch)
+			return resolvconf.Build(container.ResolvConfPath, dns, dnsSearch, dnsOptions)
 		}
 
 		// replace any localhost/127.*, and remove IPv6 nameservers if IPv6 disabled in daemon
@@ -1241,6 +1540,15 @@ func (container *Container) verifyDaemonSettings() {
 		log.Infof("WARNING: Your kernel does not support swap limit capabilities. Limitation discarded.")
 		container.Config.MemorySwap = -1
 	}
+	if container.hostConfig.PidsLimit > 0 && !container.daemon.sysInfo.PidsLimit {
+		log.Infof("WARNING: Your kernel does not support pids limit capabilities. Limitation discarded.")
+		container.hostConfig.PidsLimit = 0
+	}
+	if (container.hostConfig.CpuRtPeriod > 0 || container.hostConfig.CpuRtRuntime > 0) && !container.daemon.sysInfo.CpuRealtime {
+		log.Infof("WARNING: Your kernel does not support cgroup cpu real-time scheduling. Limitation discarded.")
+		container.hostConfig.CpuRtPeriod = 0
+		container.hostConfig.CpuRtRuntime = 0
+	}
 	if container.daemon.sysInfo.IPv4ForwardingDisabled {
 		log.Infof("WARNING: IPv4 forwarding is disabled. Networking will not work")
 	}
@@ -1400,6 +1708,7 @@ func (container *Container) allocatePort(eng *engine.Engine, port nat.Port, bind
 		job.Setenv("HostPort", b.HostPort)
 		job.Setenv("Proto", port.Proto())
 		job.Setenv("ContainerPort", port.Port())
+		job.SetenvBool("NoProxy", b.NoProxy)
 
 		portEnv, err := job.Stdout.AddEnv()
 		if err != nil {
@@ -101,7 +101,7 @@ func TestParseNetworkOptsPublicNoPort(t *testing.T) {
 		t.Fail()
 	}
 	if bindings != nil {
-		t.Logf("Expected nil got %s", bindings)
+		t.Logf("Expected nil got %v", bindings)
 		t.Fail()
 	}
 }
@@ -195,3 +195,22 @@ func TestValidContainerNames(t *testing.T) {
 		}
 	}
 }
+
+func TestDeviceFromCgroupRule(t *testing.T) {
+	device, err := deviceFromCgroupRule("c 189:* rwm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allow := device.GetCgroupAllowString(); allow != "c 189:* rwm" {
+		t.Fatalf("Expected allow string %q, got %q", "c 189:* rwm", allow)
+	}
+	if device.Path != "" {
+		t.Fatalf("Expected no device path, got %q", device.Path)
+	}
+
+	for _, rule := range []string{"c 189", "z 1:1 rwm", "c a:1 rwm", "c 1:1 rwx", "c 1:-1 r"} {
+		if _, err := deviceFromCgroupRule(rule); err == nil {
+			t.Fatalf("Expected rule %q to be rejected", rule)
+		}
+	}
+}
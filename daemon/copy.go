@@ -21,7 +21,7 @@ func (daemon *Daemon) ContainerCopy(job *engine.Job) engine.Status {
 		return job.Error(err)
 	}
 
-	data, err := container.Copy(resource)
+	data, err := container.Copy(resource, job.GetenvBool("Archive"))
 	if err != nil {
 		return job.Error(err)
 	}
@@ -32,3 +32,37 @@ func (daemon *Daemon) ContainerCopy(job *engine.Job) engine.Status {
 	}
 	return engine.StatusOK
 }
+
+// ContainerCopyTo copies the resource of a container to the directory path
+// of another container, the tar of the resource is unpacked by the daemon
+func (daemon *Daemon) ContainerCopyTo(job *engine.Job) engine.Status {
+	if len(job.Args) != 4 {
+		return job.Errorf("Usage: %s CONTAINER RESOURCE DESTCONTAINER PATH\n", job.Name)
+	}
+
+	var (
+		resource    = job.Args[1]
+		path        = job.Args[3]
+		archiveMode = job.GetenvBool("Archive")
+	)
+
+	src, err := daemon.Get(job.Args[0])
+	if err != nil {
+		return job.Error(err)
+	}
+	dst, err := daemon.Get(job.Args[2])
+	if err != nil {
+		return job.Error(err)
+	}
+
+	data, err := src.Copy(resource, archiveMode)
+	if err != nil {
+		return job.Error(err)
+	}
+	defer data.Close()
+
+	if err := dst.CopyTo(path, data, archiveMode); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
@@ -2,15 +2,23 @@ package daemon
 
 import (
 	"fmt"
+	"path"
+	"strings"
 
+	"github.com/docker/docker/daemon/graphdriver"
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/graph"
 	"github.com/docker/docker/image"
+	"github.com/docker/docker/opts"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/runconfig"
 	"github.com/docker/libcontainer/label"
 )
 
+// defaultCpuRtPeriod is the real-time period of the cgroups of the containers
+// when none is set, the one of the kernel
+const defaultCpuRtPeriod = 1000000
+
 func (daemon *Daemon) ContainerCreate(job *engine.Job) engine.Status {
 	var name string
 	if len(job.Args) == 1 {
@@ -44,6 +52,52 @@ func (daemon *Daemon) ContainerCreate(job *engine.Job) engine.Status {
 		// Older versions of the API don't provide a HostConfig.
 		hostConfig = nil
 	}
+	if hostConfig != nil && hostConfig.AutoRemove && (hostConfig.RestartPolicy.Name == "always" || hostConfig.RestartPolicy.Name == "on-failure") {
+		return job.Errorf("Conflicting options: AutoRemove and a restart policy")
+	}
+	if hostConfig != nil && (hostConfig.OomScoreAdj < -1000 || hostConfig.OomScoreAdj > 1000) {
+		return job.Errorf("Invalid value %d, range for oom score adj is [-1000, 1000]", hostConfig.OomScoreAdj)
+	}
+	if hostConfig != nil && hostConfig.ShmSize < 0 {
+		return job.Errorf("SHM size must be greater than 0")
+	}
+	if hostConfig != nil {
+		if err := verifyTmpfs(config, hostConfig); err != nil {
+			return job.Error(err)
+		}
+		for _, rule := range hostConfig.DeviceCgroupRules {
+			if _, err := opts.ParseDeviceCgroupRule(rule); err != nil {
+				return job.Error(err)
+			}
+		}
+	}
+	if hostConfig != nil && hostConfig.PidsLimit > 0 && !daemon.SystemConfig().PidsLimit {
+		job.Errorf("Your kernel does not support pids limit capabilities. Limitation discarded.\n")
+		hostConfig.PidsLimit = 0
+	}
+	if hostConfig != nil && (hostConfig.CpuRtPeriod < 0 || hostConfig.CpuRtRuntime < 0) {
+		return job.Errorf("Real-time period and runtime must not be negative")
+	}
+	if hostConfig != nil && hostConfig.CpuRtRuntime != 0 {
+		period := hostConfig.CpuRtPeriod
+		if period == 0 {
+			period = defaultCpuRtPeriod
+		}
+		if !daemon.SystemConfig().CpuRealtime {
+			job.Errorf("Your kernel does not support cgroup cpu real-time scheduling. Limitation discarded.\n")
+			hostConfig.CpuRtPeriod = 0
+			hostConfig.CpuRtRuntime = 0
+		} else if hostConfig.CpuRtRuntime > period {
+			return job.Errorf("Real-time runtime %d cannot be larger than the real-time period %d microseconds", hostConfig.CpuRtRuntime, period)
+		} else if daemon.config.CpuRtRuntime == 0 {
+			return job.Errorf("The daemon has no real-time budget for containers, start it with --cpu-rt-runtime to use --cpu-rt-runtime")
+		} else if hostConfig.CpuRtRuntime > daemon.config.CpuRtRuntime {
+			return job.Errorf("Real-time runtime %d exceeds the daemon budget of %d microseconds", hostConfig.CpuRtRuntime, daemon.config.CpuRtRuntime)
+		} else if hostConfig.CgroupParent != "" {
+			// the budget is only reserved in the docker cgroup
+			return job.Errorf("--cpu-rt-runtime cannot be used with --cgroup-parent")
+		}
+	}
 
 	container, buildWarnings, err := daemon.Create(config, hostConfig, name)
 	if err != nil {
@@ -97,21 +151,44 @@ func (daemon *Daemon) Create(config *runconfig.Config, hostConfig *runconfig.Hos
 	if hostConfig == nil {
 		hostConfig = &runconfig.HostConfig{}
 	}
+	if hostConfig.NetworkMode.IsUserDefined() {
+		n, err := daemon.networks.Get(string(hostConfig.NetworkMode))
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := checkStaticIP(n, hostConfig.IPAddress, hostConfig.IPv6Address); err != nil {
+			return nil, nil, err
+		}
+	} else if len(hostConfig.NetworkAliases) > 0 {
+		return nil, nil, runconfig.ErrConflictNetworkAliases
+	} else if hostConfig.IPAddress != "" || hostConfig.IPv6Address != "" {
+		return nil, nil, runconfig.ErrConflictNetworkIP
+	}
+	if err := daemon.verifyEndpointConfigs(hostConfig); err != nil {
+		return nil, nil, err
+	}
 	if hostConfig.SecurityOpt == nil {
 		hostConfig.SecurityOpt, err = daemon.GenerateSecurityOpt(hostConfig.IpcMode, hostConfig.PidMode)
 		if err != nil {
 			return nil, nil, err
 		}
 	}
+	if err := graphdriver.ValidateStorageOpt(daemon.driver, hostConfig.StorageOpt); err != nil {
+		return nil, nil, err
+	}
 	if container, err = daemon.newContainer(name, config, imgID); err != nil {
 		return nil, nil, err
 	}
 	if err := daemon.Register(container); err != nil {
 		return nil, nil, err
 	}
-	if err := daemon.createRootfs(container); err != nil {
+	if err := daemon.createRootfs(container, hostConfig.StorageOpt); err != nil {
+		// the driver can still refuse the storage options, the container
+		// mustn't be left registered without its layers
+		daemon.unregister(container)
 		return nil, nil, err
 	}
+	container.connectEndpoints(hostConfig.Networks)
 	if hostConfig != nil {
 		if err := daemon.setHostConfig(container, hostConfig); err != nil {
 			return nil, nil, err
@@ -121,7 +198,7 @@ func (daemon *Daemon) Create(config *runconfig.Config, hostConfig *runconfig.Hos
 		return nil, nil, err
 	}
 	defer container.Unmount()
-	if err := container.prepareVolumes(); err != nil {
+	if err := container.prepareVolumes(false); err != nil {
 		return nil, nil, err
 	}
 	if err := container.ToDisk(); err != nil {
@@ -147,3 +224,26 @@ func (daemon *Daemon) GenerateSecurityOpt(ipcMode runconfig.IpcMode, pidMode run
 	}
 	return nil, nil
 }
+
+// verifyTmpfs returns an error when a tmpfs mount of the container is invalid
+// or on the path of one of its volumes
+func verifyTmpfs(config *runconfig.Config, hostConfig *runconfig.HostConfig) error {
+	volumes := make(map[string]bool)
+	for dest := range config.Volumes {
+		volumes[path.Clean(dest)] = true
+	}
+	for _, bind := range hostConfig.Binds {
+		if arr := strings.Split(bind, ":"); len(arr) > 1 {
+			volumes[path.Clean(arr[1])] = true
+		}
+	}
+	for dest, options := range hostConfig.Tmpfs {
+		if err := runconfig.ValidateTmpfs(dest, options); err != nil {
+			return err
+		}
+		if volumes[path.Clean(dest)] {
+			return fmt.Errorf("Conflicting options: --tmpfs %s and a volume on the same path", dest)
+		}
+	}
+	return nil
+}
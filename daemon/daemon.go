@@ -5,15 +5,18 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/docker/libcontainer/cgroups"
 	"github.com/docker/libcontainer/label"
 
 	log "github.com/Sirupsen/logrus"
@@ -25,10 +28,13 @@ import (
 	"github.com/docker/docker/daemon/graphdriver"
 	_ "github.com/docker/docker/daemon/graphdriver/vfs"
 	_ "github.com/docker/docker/daemon/networkdriver/bridge"
+	_ "github.com/docker/docker/daemon/networkdriver/ipvlan"
+	_ "github.com/docker/docker/daemon/networkdriver/macvlan"
 	"github.com/docker/docker/daemon/networkdriver/portallocator"
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/graph"
 	"github.com/docker/docker/image"
+	"github.com/docker/docker/networks"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/broadcastwriter"
 	"github.com/docker/docker/pkg/common"
@@ -38,8 +44,10 @@ import (
 	"github.com/docker/docker/pkg/networkfs/resolvconf"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/parsers/kernel"
+	"github.com/docker/docker/pkg/signal"
 	"github.com/docker/docker/pkg/sysinfo"
 	"github.com/docker/docker/pkg/truncindex"
+	"github.com/docker/docker/pkg/units"
 	"github.com/docker/docker/runconfig"
 	"github.com/docker/docker/trust"
 	"github.com/docker/docker/utils"
@@ -99,6 +107,7 @@ type Daemon struct {
 	idIndex        *truncindex.TruncIndex
 	sysInfo        *sysinfo.SysInfo
 	volumes        *volumes.Repository
+	networks       *networks.Repository
 	eng            *engine.Engine
 	config         *Config
 	containerGraph *graphdb.Database
@@ -106,39 +115,59 @@ type Daemon struct {
 	execDriver     execdriver.Driver
 	trustStore     *trust.TrustStore
 	statsCollector *statsCollector
+	defaultShmSize int64
+	draining       chan struct{} // closed when the daemon starts shutting down
 }
 
+// drainMessage is the last message of the log followers and the attached
+// streams ended by the shutdown of the daemon
+const drainMessage = "The daemon is shutting down"
+
 // Install installs daemon capabilities to eng.
 func (daemon *Daemon) Install(eng *engine.Engine) error {
 	// FIXME: remove ImageDelete's dependency on Daemon, then move to graph/
 	for name, method := range map[string]engine.Handler{
-		"attach":            daemon.ContainerAttach,
-		"commit":            daemon.ContainerCommit,
-		"container_changes": daemon.ContainerChanges,
-		"container_copy":    daemon.ContainerCopy,
-		"container_rename":  daemon.ContainerRename,
-		"container_inspect": daemon.ContainerInspect,
-		"container_stats":   daemon.ContainerStats,
-		"containers":        daemon.Containers,
-		"create":            daemon.ContainerCreate,
-		"rm":                daemon.ContainerRm,
-		"export":            daemon.ContainerExport,
-		"info":              daemon.CmdInfo,
-		"kill":              daemon.ContainerKill,
-		"logs":              daemon.ContainerLogs,
-		"pause":             daemon.ContainerPause,
-		"resize":            daemon.ContainerResize,
-		"restart":           daemon.ContainerRestart,
-		"start":             daemon.ContainerStart,
-		"stop":              daemon.ContainerStop,
-		"top":               daemon.ContainerTop,
-		"unpause":           daemon.ContainerUnpause,
-		"wait":              daemon.ContainerWait,
-		"image_delete":      daemon.ImageDelete, // FIXME: see above
-		"execCreate":        daemon.ContainerExecCreate,
-		"execStart":         daemon.ContainerExecStart,
-		"execResize":        daemon.ContainerExecResize,
-		"execInspect":       daemon.ContainerExecInspect,
+		"attach":             daemon.ContainerAttach,
+		"commit":             daemon.ContainerCommit,
+		"container_changes":  daemon.ContainerChanges,
+		"container_copy":     daemon.ContainerCopy,
+		"container_copy_to":  daemon.ContainerCopyTo,
+		"container_rename":   daemon.ContainerRename,
+		"container_inspect":  daemon.ContainerInspect,
+		"container_stats":    daemon.ContainerStats,
+		"containers_stats":   daemon.ContainersStats,
+		"containers":         daemon.Containers,
+		"create":             daemon.ContainerCreate,
+		"rm":                 daemon.ContainerRm,
+		"export":             daemon.ContainerExport,
+		"info":               daemon.CmdInfo,
+		"kill":               daemon.ContainerKill,
+		"logs":               daemon.ContainerLogs,
+		"pause":              daemon.ContainerPause,
+		"resize":             daemon.ContainerResize,
+		"restart":            daemon.ContainerRestart,
+		"start":              daemon.ContainerStart,
+		"stop":               daemon.ContainerStop,
+		"top":                daemon.ContainerTop,
+		"unpause":            daemon.ContainerUnpause,
+		"wait":               daemon.ContainerWait,
+		"image_delete":       daemon.ImageDelete, // FIXME: see above
+		"image_prune":        daemon.ImagePrune,
+		"execCreate":         daemon.ContainerExecCreate,
+		"execStart":          daemon.ContainerExecStart,
+		"execResize":         daemon.ContainerExecResize,
+		"execInspect":        daemon.ContainerExecInspect,
+		"network_create":     daemon.NetworkCreate,
+		"network_ls":         daemon.NetworkList,
+		"network_inspect":    daemon.NetworkInspect,
+		"network_rm":         daemon.NetworkRm,
+		"network_connect":    daemon.NetworkConnect,
+		"network_disconnect": daemon.NetworkDisconnect,
+		"volume_create":      daemon.VolumeCreate,
+		"volume_ls":          daemon.VolumeList,
+		"volume_inspect":     daemon.VolumeInspect,
+		"volume_rm":          daemon.VolumeRm,
+		"volume_prune":       daemon.VolumePrune,
 	} {
 		if err := eng.Register(name, method); err != nil {
 			return err
@@ -284,6 +313,7 @@ func (daemon *Daemon) register(container *Container, updateSuffixarray bool) err
 		if err := container.Unmount(); err != nil {
 			log.Debugf("unmount error %s", err)
 		}
+		container.unmountVolumes()
 		if err := container.ToDisk(); err != nil {
 			log.Debugf("saving stopped state to disk %s", err)
 		}
@@ -299,6 +329,12 @@ func (daemon *Daemon) register(container *Container, updateSuffixarray bool) err
 				return err
 			}
 		}
+	} else if len(container.MountedVolumes) > 0 {
+		// the daemon stopped before the volumes were unmounted
+		container.unmountVolumes()
+		if err := container.ToDisk(); err != nil {
+			log.Debugf("saving unmounted volumes to disk %s", err)
+		}
 	}
 	return nil
 }
@@ -418,6 +454,15 @@ func (daemon *Daemon) restore() error {
 		}
 	}
 
+	// remove the containers that were meant to be removed when they exited,
+	// but were still there when the daemon went down
+	for _, container := range registeredContainers {
+		if container.hostConfig.AutoRemove && !container.IsRunning() {
+			log.Debugf("Removing container %s", container.ID)
+			daemon.autoRemove(container)
+		}
+	}
+
 	if !debug {
 		fmt.Println()
 		log.Infof("Loading containers: done.")
@@ -508,6 +553,11 @@ func (daemon *Daemon) mergeAndVerifyConfig(config *runconfig.Config, img *image.
 	if len(config.Entrypoint) == 0 && len(config.Cmd) == 0 {
 		return nil, fmt.Errorf("No command specified")
 	}
+	if config.StopSignal != "" {
+		if _, err := signal.ParseSignal(config.StopSignal); err != nil {
+			return nil, err
+		}
+	}
 	return warnings, nil
 }
 
@@ -619,15 +669,25 @@ func parseSecurityOpt(container *Container, config *runconfig.HostConfig) error
 	)
 
 	for _, opt := range config.SecurityOpt {
-		con := strings.SplitN(opt, ":", 2)
-		if len(con) == 1 {
+		if opt == "no-new-privileges" {
+			container.NoNewPrivileges = true
+			continue
+		}
+		// the options are key:value or key=value
+		i := strings.IndexAny(opt, ":=")
+		if i < 0 {
 			return fmt.Errorf("Invalid --security-opt: %q", opt)
 		}
+		con := []string{opt[:i], opt[i+1:]}
 		switch con[0] {
 		case "label":
 			labelOpts = append(labelOpts, con[1])
 		case "apparmor":
 			container.AppArmorProfile = con[1]
+		case "no-new-privileges":
+			if container.NoNewPrivileges, err = strconv.ParseBool(con[1]); err != nil {
+				return fmt.Errorf("Invalid --security-opt: %q", opt)
+			}
 		default:
 			return fmt.Errorf("Invalid --security-opt: %q", opt)
 		}
@@ -670,27 +730,39 @@ func (daemon *Daemon) newContainer(name string, config *runconfig.Config, imgID
 	return container, err
 }
 
-func (daemon *Daemon) createRootfs(container *Container) error {
+func (daemon *Daemon) createRootfs(container *Container, storageOpt map[string]string) error {
 	// Step 1: create the container directory.
 	// This doubles as a barrier to avoid race conditions.
 	if err := os.Mkdir(container.root, 0700); err != nil {
 		return err
 	}
+	imageLayerID := ""
+	if container.ImageID != "" {
+		img, err := container.GetImage()
+		if err != nil {
+			return err
+		}
+		imageLayerID = img.LayerID()
+	}
 	initID := fmt.Sprintf("%s-init", container.ID)
-	if err := daemon.driver.Create(initID, container.ImageID); err != nil {
+	if err := daemon.driver.Create(initID, imageLayerID); err != nil {
 		return err
 	}
 	initPath, err := daemon.driver.Get(initID, "")
 	if err != nil {
 		return err
 	}
-	defer daemon.driver.Put(initID)
-
-	if err := graph.SetupInitLayer(initPath); err != nil {
+	err = graph.SetupInitLayer(initPath)
+	daemon.driver.Put(initID)
+	if err != nil {
 		return err
 	}
 
-	if err := daemon.driver.Create(container.ID, initID); err != nil {
+	if err := graphdriver.CreateWithStorageOpt(daemon.driver, container.ID, initID, storageOpt); err != nil {
+		// the driver can refuse the storage options, the init layer is
+		// removed for the container to be created again
+		daemon.driver.Remove(initID)
+		os.RemoveAll(container.root)
 		return err
 	}
 	return nil
@@ -777,6 +849,9 @@ func (daemon *Daemon) RegisterLinks(container *Container, hostConfig *runconfig.
 			if child.hostConfig.NetworkMode.IsHost() {
 				return runconfig.ErrConflictHostNetworkAndLinks
 			}
+			if hostConfig.NetworkMode.IsUserDefined() || child.hostConfig.NetworkMode.IsUserDefined() {
+				return runconfig.ErrConflictUserDefinedNetworkAndLinks
+			}
 			if err := daemon.RegisterLink(container, child, parts["alias"]); err != nil {
 				return err
 			}
@@ -816,6 +891,9 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 		config.EnableIpMasq = false
 	}
 	config.DisableNetwork = config.BridgeIface == disableNetworkBridge
+	if config.ShutdownTimeout < 0 {
+		return nil, fmt.Errorf("The shutdown timeout cannot be negative: %s", config.ShutdownTimeout)
+	}
 
 	// Claim the pidfile first, to avoid any and all unexpected race conditions.
 	// Some of the init doesn't need a pidfile lock - but let's not try to be smart.
@@ -912,6 +990,16 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 		return nil, err
 	}
 
+	networks, err := networks.NewRepository(filepath.Join(config.Root, "networks"))
+	if err != nil {
+		return nil, err
+	}
+	if config.EnableIPv6 {
+		if err := networks.EnableIPv6(config.IPv6Pool); err != nil {
+			return nil, err
+		}
+	}
+
 	trustKey, err := api.LoadOrCreateTrustKey(config.TrustKeyPath)
 	if err != nil {
 		return nil, err
@@ -922,6 +1010,12 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 	if err != nil {
 		return nil, fmt.Errorf("Couldn't create Tag store: %s", err)
 	}
+	if err := repositories.SetMaxConcurrentTransfers(config.MaxConcurrentDownloads, config.MaxConcurrentUploads); err != nil {
+		return nil, err
+	}
+	if err := repositories.SetContentTrustPolicy(config.ContentTrustPolicy, config.ContentTrustServer, graph.DefaultTrustedKeysDir, path.Join(config.Root, "trust-versions.json")); err != nil {
+		return nil, err
+	}
 
 	trustDir := path.Join(config.Root, "trust")
 	if err := os.MkdirAll(trustDir, 0700); err != nil && !os.IsExist(err) {
@@ -945,10 +1039,19 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 		job.Setenv("FixedCIDR", config.FixedCIDR)
 		job.Setenv("FixedCIDRv6", config.FixedCIDRv6)
 		job.Setenv("DefaultBindingIP", config.DefaultIp.String())
+		job.Setenv("PortRange", config.PortRange)
+		out, err := job.Stdout.AddEnv()
+		if err != nil {
+			return nil, err
+		}
 
 		if err := job.Run(); err != nil {
 			return nil, err
 		}
+		// the user-defined networks share the ip allocator of the bridge
+		if _, subnet, err := net.ParseCIDR(out.Get("Subnet")); err == nil {
+			networks.SetBridgeSubnet(subnet)
+		}
 	}
 
 	graphdbPath := path.Join(config.Root, "linkgraph.db")
@@ -977,12 +1080,41 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 		sysInitPath = localCopy
 	}
 
+	shmSize, err := units.RAMInBytes(config.DefaultShmSize)
+	if err != nil || shmSize <= 0 {
+		return nil, fmt.Errorf("Invalid --default-shm-size: %q", config.DefaultShmSize)
+	}
+
 	sysInfo := sysinfo.New(false)
+	if config.CpuRtRuntime != 0 {
+		if !sysInfo.CpuRealtime {
+			return nil, fmt.Errorf("Your kernel does not support cgroup cpu real-time scheduling, --cpu-rt-runtime cannot be used")
+		}
+		// the budget is only reserved in the docker cgroup, the containers
+		// under another parent could not get any
+		if config.CgroupParent != "" {
+			return nil, fmt.Errorf("--cpu-rt-runtime cannot be used with --cgroup-parent")
+		}
+		if err := setupCpuRtBudget(config.CpuRtRuntime); err != nil {
+			return nil, fmt.Errorf("Unable to allocate the real-time budget: %v", err)
+		}
+	}
 	ed, err := execdrivers.NewDriver(config.ExecDriver, config.Root, sysInitPath, sysInfo)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := loadAppArmorProfiles(config.AppArmorProfilesDir); err != nil {
+		return nil, err
+	}
+
+	if config.NoNewPrivileges && strings.HasPrefix(ed.Name(), lxc.DriverName) {
+		return nil, fmt.Errorf("The lxc exec driver does not support --no-new-privileges")
+	}
+	if config.CgroupParent != "" && strings.HasPrefix(ed.Name(), lxc.DriverName) {
+		return nil, fmt.Errorf("The lxc exec driver does not support --cgroup-parent")
+	}
+
 	daemon := &Daemon{
 		ID:             trustKey.PublicKey().KeyID(),
 		repository:     daemonRepo,
@@ -993,6 +1125,7 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 		idIndex:        truncindex.NewTruncIndex([]string{}),
 		sysInfo:        sysInfo,
 		volumes:        volumes,
+		networks:       networks,
 		config:         config,
 		containerGraph: graph,
 		driver:         driver,
@@ -1001,6 +1134,8 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 		eng:            eng,
 		trustStore:     t,
 		statsCollector: newStatsCollector(1 * time.Second),
+		defaultShmSize: shmSize,
+		draining:       make(chan struct{}),
 	}
 	if err := daemon.restore(); err != nil {
 		return nil, err
@@ -1011,8 +1146,27 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 		return nil, err
 	}
 
+	go daemon.execCommandGC()
+
+	if config.ContainerGCAge > 0 {
+		interval := time.Minute
+		if config.ContainerGCAge < interval {
+			interval = config.ContainerGCAge
+		}
+		go daemon.containerGC(config.ContainerGCAge, interval, config.ContainerGCExcludeLabels)
+	}
+
+	// End the log followers and the attached streams before stopping the
+	// containers, their clients are told the daemon is shutting down
+	eng.OnDrain(func() {
+		close(daemon.draining)
+	})
+
 	// Setup shutdown handlers
 	// FIXME: can these shutdown handlers be registered closer to their source?
+	// The containers are given the shutdown timeout to stop, and the killed
+	// ones up to 10 more seconds to exit.
+	eng.SetShutdownTimeout(config.ShutdownTimeout + 10*time.Second)
 	eng.OnShutdown(func() {
 		// FIXME: if these cleanup steps can be called concurrently, register
 		// them as separate handlers to speed up total shutdown time
@@ -1044,10 +1198,17 @@ func (daemon *Daemon) shutdown() error {
 
 			go func() {
 				defer group.Done()
-				if err := c.KillSig(15); err != nil {
-					log.Debugf("kill 15 error for %s - %s", c.ID, err)
+				sig := c.stopSignal()
+				if err := c.KillSig(sig); err != nil {
+					log.Debugf("kill %d error for %s - %s", sig, c.ID, err)
+				}
+				timeout := daemon.shutdownStopTimeout(c)
+				if _, err := c.WaitStop(timeout); err != nil {
+					log.Infof("Container %s failed to exit within %s of signal %d - killing it", c.ID, timeout, sig)
+					if err := c.Kill(); err != nil {
+						log.Errorf("kill error for %s - %s", c.ID, err)
+					}
 				}
-				c.WaitStop(-1 * time.Second)
 				log.Debugf("container stopped %s", c.ID)
 			}()
 		}
@@ -1057,6 +1218,17 @@ func (daemon *Daemon) shutdown() error {
 	return nil
 }
 
+// shutdownStopTimeout returns how long the container is given to exit after
+// its stop signal when the daemon shuts down, its own stop timeout bounded by
+// the shutdown timeout of the daemon
+func (daemon *Daemon) shutdownStopTimeout(container *Container) time.Duration {
+	timeout := time.Duration(container.stopTimeout()) * time.Second
+	if timeout < 0 || timeout > daemon.config.ShutdownTimeout {
+		return daemon.config.ShutdownTimeout
+	}
+	return timeout
+}
+
 func (daemon *Daemon) Mount(container *Container) error {
 	dir, err := daemon.driver.Get(container.ID, container.GetMountLabel())
 	if err != nil {
@@ -1112,7 +1284,18 @@ func (daemon *Daemon) Kill(c *Container, sig int) error {
 }
 
 func (daemon *Daemon) Stats(c *Container) (*execdriver.ResourceStats, error) {
-	return daemon.execDriver.Stats(c.ID)
+	stats, err := daemon.execDriver.Stats(c.ID)
+	if err != nil {
+		return nil, err
+	}
+	if pid := c.GetPid(); pid != 0 && !c.hostConfig.NetworkMode.IsHost() {
+		interfaces, err := getInterfaceStats(fmt.Sprintf("/proc/%d/net/dev", pid))
+		if err != nil {
+			log.Debugf("collecting network interface stats for %s: %v", c.ID, err)
+		}
+		stats.Interfaces = interfaces
+	}
+	return stats, nil
 }
 
 func (daemon *Daemon) SubscribeToContainerStats(name string) (chan interface{}, error) {
@@ -1165,6 +1348,10 @@ func (daemon *Daemon) Repositories() *graph.TagStore {
 	return daemon.repositories
 }
 
+func (daemon *Daemon) Networks() *networks.Repository {
+	return daemon.networks
+}
+
 func (daemon *Daemon) Config() *Config {
 	return daemon.config
 }
@@ -1240,3 +1427,22 @@ func checkKernel() error {
 	}
 	return nil
 }
+
+// setupCpuRtBudget reserves budget microseconds of real-time CPU time per
+// period for the "docker" cgroup containers are created under, so that they
+// can in turn be given a real-time budget of their own.
+func setupCpuRtBudget(budget int64) error {
+	mountpoint, err := cgroups.FindCgroupMountpoint("cpu")
+	if err != nil {
+		return err
+	}
+	initPath, err := cgroups.GetInitCgroupDir("cpu")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(mountpoint, initPath, "docker")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "cpu.rt_runtime_us"), []byte(strconv.FormatInt(budget, 10)), 0700)
+}
@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"testing"
+	"time"
 
 	"github.com/docker/docker/runconfig"
 )
@@ -19,6 +20,36 @@ func TestParseSecurityOpt(t *testing.T) {
 		t.Fatalf("Unexpected AppArmorProfile, expected: \"test_profile\", got %q", container.AppArmorProfile)
 	}
 
+	config.SecurityOpt = []string{"apparmor=other_profile"}
+	if err := parseSecurityOpt(container, config); err != nil {
+		t.Fatalf("Unexpected parseSecurityOpt error: %v", err)
+	}
+	if container.AppArmorProfile != "other_profile" {
+		t.Fatalf("Unexpected AppArmorProfile, expected: \"other_profile\", got %q", container.AppArmorProfile)
+	}
+
+	// test no-new-privileges
+	config.SecurityOpt = []string{"no-new-privileges"}
+	if err := parseSecurityOpt(container, config); err != nil {
+		t.Fatalf("Unexpected parseSecurityOpt error: %v", err)
+	}
+	if !container.NoNewPrivileges {
+		t.Fatal("Expected NoNewPrivileges to be set")
+	}
+
+	config.SecurityOpt = []string{"no-new-privileges=false"}
+	if err := parseSecurityOpt(container, config); err != nil {
+		t.Fatalf("Unexpected parseSecurityOpt error: %v", err)
+	}
+	if container.NoNewPrivileges {
+		t.Fatal("Expected NoNewPrivileges to be unset")
+	}
+
+	config.SecurityOpt = []string{"no-new-privileges:maybe"}
+	if err := parseSecurityOpt(container, config); err == nil {
+		t.Fatal("Expected parseSecurityOpt error, got nil")
+	}
+
 	// test valid label
 	config.SecurityOpt = []string{"label:user:USER"}
 	if err := parseSecurityOpt(container, config); err != nil {
@@ -37,3 +68,26 @@ func TestParseSecurityOpt(t *testing.T) {
 		t.Fatal("Expected parseSecurityOpt error, got nil")
 	}
 }
+
+func TestShutdownStopTimeout(t *testing.T) {
+	daemon := &Daemon{config: &Config{ShutdownTimeout: 15 * time.Second}}
+	container := &Container{Config: &runconfig.Config{}}
+	if timeout := daemon.shutdownStopTimeout(container); timeout != DefaultStopTimeout*time.Second {
+		t.Fatalf("Expected the default stop timeout, got %s", timeout)
+	}
+
+	stopTimeout := 5
+	container.Config.StopTimeout = &stopTimeout
+	if timeout := daemon.shutdownStopTimeout(container); timeout != 5*time.Second {
+		t.Fatalf("Expected the stop timeout of the container, got %s", timeout)
+	}
+
+	// the containers waited for indefinitely are bounded by the shutdown
+	// timeout
+	for _, stopTimeout := range []int{60, -1} {
+		container.Config.StopTimeout = &stopTimeout
+		if timeout := daemon.shutdownStopTimeout(container); timeout != 15*time.Second {
+			t.Fatalf("Expected the shutdown timeout for a stop timeout of %d, got %s", stopTimeout, timeout)
+		}
+	}
+}
@@ -72,8 +72,24 @@ func (daemon *Daemon) ContainerRm(job *engine.Job) engine.Status {
 	return engine.StatusOK
 }
 
+// autoRemove removes a container with AutoRemove set, along with its volumes,
+// once it has exited.
+func (daemon *Daemon) autoRemove(container *Container) {
+	daemon.statsCollector.stopCollection(container)
+	if err := daemon.Rm(container); err != nil {
+		log.Errorf("Unable to remove container %s: %s", container.ID, err)
+		return
+	}
+	container.LogEvent("destroy")
+	daemon.DeleteVolumes(container.VolumePaths())
+}
+
 func (daemon *Daemon) DeleteVolumes(volumeIDs map[string]struct{}) {
 	for id := range volumeIDs {
+		// the named volumes are kept until they are removed
+		if v := daemon.volumes.Get(id); v != nil && v.Name != "" {
+			continue
+		}
 		if err := daemon.volumes.Delete(id); err != nil {
 			log.Infof("%s", err)
 			continue
@@ -81,6 +97,21 @@ func (daemon *Daemon) DeleteVolumes(volumeIDs map[string]struct{}) {
 	}
 }
 
+// unregister undoes Register, the container cannot be looked up anymore
+func (daemon *Daemon) unregister(container *Container) {
+	daemon.idIndex.Delete(container.ID)
+	daemon.containers.Delete(container.ID)
+	for _, id := range container.execCommands.List() {
+		if eConfig := container.execCommands.Get(id); eConfig != nil {
+			daemon.unregisterExecCommand(eConfig)
+		}
+	}
+	container.derefVolumes()
+	if _, err := daemon.containerGraph.Purge(container.ID); err != nil {
+		log.Debugf("Unable to remove container from link graph: %s", err)
+	}
+}
+
 // Destroy unregisters a container from the daemon and cleanly removes its contents from the filesystem.
 func (daemon *Daemon) Rm(container *Container) error {
 	if container == nil {
@@ -97,12 +128,9 @@ func (daemon *Daemon) Rm(container *Container) error {
 	}
 
 	// Deregister the container before removing its directory, to avoid race conditions
-	daemon.idIndex.Delete(container.ID)
-	daemon.containers.Delete(container.ID)
-	container.derefVolumes()
-	if _, err := daemon.containerGraph.Purge(container.ID); err != nil {
-		log.Debugf("Unable to remove container from link graph: %s", err)
-	}
+	daemon.unregister(container)
+	// The container cannot be looked up anymore, even if the cleanup below fails
+	defer container.SetRemoved()
 
 	if err := daemon.driver.Remove(container.ID); err != nil {
 		return fmt.Errorf("Driver %s failed to remove root filesystem %s: %s", daemon.driver, container.ID, err)
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"strings"
 	"sync"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/daemon/execdriver"
@@ -16,19 +17,28 @@ import (
 	"github.com/docker/docker/pkg/ioutils"
 	"github.com/docker/docker/pkg/promise"
 	"github.com/docker/docker/runconfig"
+	"github.com/docker/docker/utils"
 )
 
+// execGCInterval is how often exited exec commands are collected, and how long
+// their result is kept after they exited
+const execGCInterval = 5 * time.Minute
+
 type execConfig struct {
 	sync.Mutex
 	ID            string
 	Running       bool
 	ExitCode      int
+	Pid           int
 	ProcessConfig execdriver.ProcessConfig
 	StreamConfig
 	OpenStdin  bool
 	OpenStderr bool
 	OpenStdout bool
 	Container  *Container
+
+	// exitedAt is when the command exited, zero while it did not run or is still running
+	exitedAt time.Time
 }
 
 type execStore struct {
@@ -96,6 +106,32 @@ func (d *Daemon) unregisterExecCommand(execConfig *execConfig) {
 	d.execCommands.Delete(execConfig.ID)
 }
 
+// execCommandGC removes the exec commands that exited more than execGCInterval
+// ago, whether or not their result was retrieved, and the ones that were never
+// started and cannot be anymore because their container stopped.
+func (d *Daemon) execCommandGC() {
+	for now := range time.Tick(execGCInterval) {
+		d.collectExecCommands(now)
+	}
+}
+
+func (d *Daemon) collectExecCommands(now time.Time) {
+	for _, id := range d.execCommands.List() {
+		execConfig := d.execCommands.Get(id)
+		if execConfig == nil {
+			continue
+		}
+		execConfig.Lock()
+		exited := !execConfig.exitedAt.IsZero() && now.Sub(execConfig.exitedAt) > execGCInterval
+		stale := !execConfig.Running && execConfig.exitedAt.IsZero() && !execConfig.Container.IsRunning()
+		execConfig.Unlock()
+		if exited || stale {
+			log.Debugf("Removing exec command %s of container %s", execConfig.ID, execConfig.Container.ID)
+			d.unregisterExecCommand(execConfig)
+		}
+	}
+}
+
 func (d *Daemon) getActiveContainer(name string) (*Container, error) {
 	container, err := d.Get(name)
 	if err != nil {
@@ -135,10 +171,16 @@ func (d *Daemon) ContainerExecCreate(job *engine.Job) engine.Status {
 	entrypoint, args := d.getEntrypointAndArgs(nil, config.Cmd)
 
 	processConfig := execdriver.ProcessConfig{
+		Privileged: config.Privileged,
 		Tty:        config.Tty,
 		Entrypoint: entrypoint,
 		Arguments:  args,
 	}
+	// the environment and working directory default to the container's ones
+	if len(config.Env) > 0 {
+		processConfig.Env = utils.ReplaceOrAppendEnvValues(container.command.ProcessConfig.Env, config.Env)
+	}
+	processConfig.Dir = config.WorkingDir
 
 	execConfig := &execConfig{
 		ID:            common.GenerateRandomID(),
@@ -176,6 +218,11 @@ func (d *Daemon) ContainerExecStart(job *engine.Job) engine.Status {
 		return job.Error(err)
 	}
 
+	detachKeys, err := detachKeysFromJob(job, "DetachKeys")
+	if err != nil {
+		return job.Error(err)
+	}
+
 	func() {
 		execConfig.Lock()
 		defer execConfig.Unlock()
@@ -218,7 +265,7 @@ func (d *Daemon) ContainerExecStart(job *engine.Job) engine.Status {
 		execConfig.StreamConfig.stdinPipe = ioutils.NopWriteCloser(ioutil.Discard) // Silently drop stdin
 	}
 
-	attachErr := d.Attach(&execConfig.StreamConfig, execConfig.OpenStdin, true, execConfig.ProcessConfig.Tty, cStdin, cStdout, cStderr)
+	attachErr := d.Attach(&execConfig.StreamConfig, execConfig.OpenStdin, true, execConfig.ProcessConfig.Tty, detachKeys, cStdin, cStdout, cStderr)
 
 	execErr := make(chan error)
 
@@ -254,8 +301,11 @@ func (d *Daemon) Exec(c *Container, execConfig *execConfig, pipes *execdriver.Pi
 		exitStatus = 128
 	}
 
+	execConfig.Lock()
 	execConfig.ExitCode = exitStatus
 	execConfig.Running = false
+	execConfig.exitedAt = time.Now()
+	execConfig.Unlock()
 
 	return exitStatus, err
 }
@@ -271,6 +321,9 @@ func (container *Container) Exec(execConfig *execConfig) error {
 	waitStart := make(chan struct{})
 
 	callback := func(processConfig *execdriver.ProcessConfig, pid int) {
+		execConfig.Lock()
+		execConfig.Pid = pid
+		execConfig.Unlock()
 		if processConfig.Tty {
 			// The callback is called after the process Start()
 			// so we are in the parent process. In TTY mode, stdin/out/err is the PtySlave
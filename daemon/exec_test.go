@@ -0,0 +1,42 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCollectExecCommands(t *testing.T) {
+	now := time.Now()
+	d := &Daemon{execCommands: newExecStore()}
+	running := &Container{ID: "running", State: NewState(), execCommands: newExecStore()}
+	running.SetRunning(42)
+	stopped := &Container{ID: "stopped", State: NewState(), execCommands: newExecStore()}
+
+	execs := map[string]*execConfig{
+		"exited-long-ago": {ID: "exited-long-ago", Container: running, exitedAt: now.Add(-2 * execGCInterval)},
+		"exited-recently": {ID: "exited-recently", Container: running, exitedAt: now.Add(-time.Second)},
+		"still-running":   {ID: "still-running", Container: running, Running: true},
+		"not-started":     {ID: "not-started", Container: running},
+		"never-started":   {ID: "never-started", Container: stopped},
+	}
+	for _, execConfig := range execs {
+		d.registerExecCommand(execConfig)
+	}
+
+	d.collectExecCommands(now)
+
+	for id, kept := range map[string]bool{
+		"exited-long-ago": false,
+		"exited-recently": true,
+		"still-running":   true,
+		"not-started":     true,
+		"never-started":   false,
+	} {
+		if (d.execCommands.Get(id) != nil) != kept {
+			t.Fatalf("Expected exec command %s to be kept: %v", id, kept)
+		}
+		if (execs[id].Container.execCommands.Get(id) != nil) != kept {
+			t.Fatalf("Expected exec command %s to be kept in its container: %v", id, kept)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/docker/docker/pkg/ulimit"
 	"github.com/docker/libcontainer"
 	"github.com/docker/libcontainer/devices"
+	"github.com/docker/libcontainer/network"
 )
 
 // Context is a generic key value pair that allows
@@ -67,14 +68,19 @@ type Driver interface {
 	Terminate(c *Command) error                   // kill it with fire
 	Clean(id string) error                        // clean all traces of container exec
 	Stats(id string) (*ResourceStats, error)      // Get resource stats for a running container
+	// ConnectNetwork adds the interface to a running container
+	ConnectNetwork(c *Command, iface *NetworkInterface) error
+	// DisconnectNetwork removes the interface from a running container
+	DisconnectNetwork(c *Command, iface *NetworkInterface) error
 }
 
 // Network settings of the container
 type Network struct {
-	Interface      *NetworkInterface `json:"interface"` // if interface is nil then networking is disabled
-	Mtu            int               `json:"mtu"`
-	ContainerID    string            `json:"container_id"` // id of the container to join network.
-	HostNetworking bool              `json:"host_networking"`
+	Interface      *NetworkInterface   `json:"interface"` // if interface is nil then networking is disabled
+	Endpoints      []*NetworkInterface `json:"endpoints"` // interfaces on the other user-defined networks of the container
+	Mtu            int                 `json:"mtu"`
+	ContainerID    string              `json:"container_id"` // id of the container to join network.
+	HostNetworking bool                `json:"host_networking"`
 }
 
 // IPC settings of the container
@@ -98,14 +104,23 @@ type NetworkInterface struct {
 	LinkLocalIPv6Address string `json:"link_local_ipv6"`
 	GlobalIPv6PrefixLen  int    `json:"global_ipv6_prefix_len"`
 	IPv6Gateway          string `json:"ipv6_gateway"`
+	Type                 string `json:"type"`   // empty for a veth pair on Bridge
+	Parent               string `json:"parent"` // host interface of a macvlan or ipvlan interface
+	Mode                 string `json:"mode"`
+	Name                 string `json:"name"`             // name inside the container, eth0 when empty
+	NoDefaultRoute       bool   `json:"no_default_route"` // no route out of the subnets, on internal networks
+	Mtu                  int    `json:"mtu"`              // mtu of the network, the mtu of the container when 0
 }
 
 type Resources struct {
-	Memory     int64            `json:"memory"`
-	MemorySwap int64            `json:"memory_swap"`
-	CpuShares  int64            `json:"cpu_shares"`
-	Cpuset     string           `json:"cpuset"`
-	Rlimits    []*ulimit.Rlimit `json:"rlimits"`
+	Memory       int64            `json:"memory"`
+	MemorySwap   int64            `json:"memory_swap"`
+	CpuShares    int64            `json:"cpu_shares"`
+	CpuRtPeriod  int64            `json:"cpu_rt_period"`
+	CpuRtRuntime int64            `json:"cpu_rt_runtime"`
+	Cpuset       string           `json:"cpuset"`
+	PidsLimit    int64            `json:"pids_limit"`
+	Rlimits      []*ulimit.Rlimit `json:"rlimits"`
 }
 
 type ResourceStats struct {
@@ -113,6 +128,9 @@ type ResourceStats struct {
 	Read        time.Time `json:"read"`
 	MemoryLimit int64     `json:"memory_limit"`
 	SystemUsage uint64    `json:"system_usage"`
+	// Interfaces holds the statistics of the network interfaces of the
+	// container, keyed by interface name
+	Interfaces map[string]*network.NetworkStats `json:"interfaces,omitempty"`
 }
 
 type Mount struct {
@@ -159,6 +177,12 @@ type Command struct {
 	MountLabel         string            `json:"mount_label"`
 	LxcConfig          []string          `json:"lxc_config"`
 	AppArmorProfile    string            `json:"apparmor_profile"`
+	NoNewPrivileges    bool              `json:"no_new_privileges"`
+	Tmpfs              map[string]string `json:"tmpfs"`
+	GroupAdd           []string          `json:"group_add"`
+	ShmSize            int64             `json:"shm_size"`      // size of /dev/shm in bytes
+	OomScoreAdj        int               `json:"oom_score_adj"` // oom_score_adj of the container's processes
+	CgroupParent       string            `json:"cgroup_parent"` // parent cgroup, or systemd slice, of the container
 }
 
 func InitContainer(c *Command) *libcontainer.Config {
@@ -174,6 +198,8 @@ func InitContainer(c *Command) *libcontainer.Config {
 	container.MountConfig.DeviceNodes = c.AutoCreatedDevices
 	container.RootFs = c.Rootfs
 	container.MountConfig.ReadonlyFs = c.ReadonlyRootfs
+	container.MountConfig.ShmSize = c.ShmSize
+	container.OomScoreAdj = c.OomScoreAdj
 
 	// check to see if we are running in ramdisk to disable pivot root
 	container.MountConfig.NoPivotRoot = os.Getenv("DOCKER_RAMDISK") != ""
@@ -198,6 +224,9 @@ func SetupCgroups(container *libcontainer.Config, c *Command) error {
 		container.Cgroups.MemoryReservation = c.Resources.Memory
 		container.Cgroups.MemorySwap = c.Resources.MemorySwap
 		container.Cgroups.CpusetCpus = c.Resources.Cpuset
+		container.Cgroups.PidsLimit = c.Resources.PidsLimit
+		container.Cgroups.CpuRtPeriod = c.Resources.CpuRtPeriod
+		container.Cgroups.CpuRtRuntime = c.Resources.CpuRtRuntime
 	}
 
 	return nil
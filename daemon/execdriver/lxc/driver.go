@@ -31,6 +31,10 @@ const DriverName = "lxc"
 
 var ErrExec = errors.New("Unsupported: Exec is not supported by the lxc driver")
 
+var ErrCgroupParent = errors.New("Unsupported: cgroup-parent is not supported by the lxc driver")
+
+var ErrNetworkType = errors.New("Unsupported: user-defined networks are not supported by the lxc driver")
+
 type driver struct {
 	root             string // root path for the driver to use
 	initPath         string
@@ -77,6 +81,14 @@ func (d *driver) Run(c *execdriver.Command, pipes *execdriver.Pipes, startCallba
 		dataPath = d.containerDir(c.ID)
 	)
 
+	if c.CgroupParent != "" {
+		return execdriver.ExitStatus{ExitCode: -1}, ErrCgroupParent
+	}
+
+	if (c.Network.Interface != nil && c.Network.Interface.Type != "") || len(c.Network.Endpoints) > 0 {
+		return execdriver.ExitStatus{ExitCode: -1}, ErrNetworkType
+	}
+
 	if c.ProcessConfig.Tty {
 		term, err = NewTtyConsole(&c.ProcessConfig, pipes)
 	} else {
@@ -148,6 +160,10 @@ func (d *driver) Run(c *execdriver.Command, pipes *execdriver.Pipes, startCallba
 		params = append(params, "-u", c.ProcessConfig.User)
 	}
 
+	if len(c.GroupAdd) > 0 {
+		params = append(params, "-group-add", strings.Join(c.GroupAdd, ","))
+	}
+
 	if c.ProcessConfig.Privileged {
 		if d.apparmor {
 			params[0] = path.Join(d.root, "lxc-start-unconfined")
@@ -230,6 +246,13 @@ func (d *driver) Run(c *execdriver.Command, pipes *execdriver.Pipes, startCallba
 		return terminate(err)
 	}
 
+	// lxc has no setting for it, apply it to the init once it is running
+	if c.OomScoreAdj != 0 {
+		if err := ioutil.WriteFile(fmt.Sprintf("/proc/%d/oom_score_adj", pid), []byte(strconv.Itoa(c.OomScoreAdj)), 0700); err != nil {
+			return terminate(err)
+		}
+	}
+
 	state := &libcontainer.State{
 		InitPid:     pid,
 		CgroupPaths: cgroupPaths,
@@ -641,3 +664,11 @@ func (d *driver) Exec(c *execdriver.Command, processConfig *execdriver.ProcessCo
 func (d *driver) Stats(id string) (*execdriver.ResourceStats, error) {
 	return execdriver.Stats(d.containerDir(id), d.activeContainers[id].container.Cgroups.Memory, d.machineMemory)
 }
+
+func (d *driver) ConnectNetwork(c *execdriver.Command, iface *execdriver.NetworkInterface) error {
+	return ErrNetworkType
+}
+
+func (d *driver) DisconnectNetwork(c *execdriver.Command, iface *execdriver.NetworkInterface) error {
+	return ErrNetworkType
+}
@@ -30,6 +30,7 @@ type InitArgs struct {
 	Root       string
 	CapAdd     string
 	CapDrop    string
+	GroupAdd   []string
 }
 
 func init() {
@@ -86,10 +87,16 @@ func getArgs() *InitArgs {
 		mtu        = flag.Int("mtu", 1500, "interface mtu")
 		capAdd     = flag.String("cap-add", "", "capabilities to add")
 		capDrop    = flag.String("cap-drop", "", "capabilities to drop")
+		groupAdd   = flag.String("group-add", "", "additional groups to join")
 	)
 
 	flag.Parse()
 
+	var groups []string
+	if *groupAdd != "" {
+		groups = strings.Split(*groupAdd, ",")
+	}
+
 	return &InitArgs{
 		User:       *user,
 		Gateway:    *gateway,
@@ -100,6 +107,7 @@ func getArgs() *InitArgs {
 		Mtu:        *mtu,
 		CapAdd:     *capAdd,
 		CapDrop:    *capDrop,
+		GroupAdd:   groups,
 	}
 }
 
@@ -3,8 +3,10 @@ package lxc
 import (
 	"fmt"
 
+	"github.com/docker/docker/daemon/execdriver"
 	"github.com/docker/libcontainer"
 	"github.com/docker/libcontainer/namespaces"
+	"github.com/docker/libcontainer/user"
 	"github.com/docker/libcontainer/utils"
 )
 
@@ -12,8 +14,17 @@ func finalizeNamespace(args *InitArgs) error {
 	if err := utils.CloseExecFrom(3); err != nil {
 		return err
 	}
+	groupPath, err := user.GetGroupPath()
+	if err != nil {
+		return err
+	}
+	groups, err := execdriver.GetAdditionalGroups(args.GroupAdd, groupPath)
+	if err != nil {
+		return err
+	}
 	if err := namespaces.SetupUser(&libcontainer.Config{
-		User: args.User,
+		User:             args.User,
+		AdditionalGroups: groups,
 	}); err != nil {
 		return fmt.Errorf("setup user %s", err)
 	}
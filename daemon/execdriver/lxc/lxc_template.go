@@ -85,7 +85,11 @@ lxc.mount.entry = {{.ProcessConfig.Console}} {{escapeFstabSpaces $ROOTFS}}/dev/c
 {{end}}
 
 lxc.mount.entry = devpts {{escapeFstabSpaces $ROOTFS}}/dev/pts devpts {{formatMountLabel "newinstance,ptmxmode=0666,nosuid,noexec" ""}} 0 0
+{{if .ShmSize}}
+lxc.mount.entry = shm {{escapeFstabSpaces $ROOTFS}}/dev/shm tmpfs {{formatMountLabel (printf "size=%d,nosuid,nodev,noexec" .ShmSize) ""}} 0 0
+{{else}}
 lxc.mount.entry = shm {{escapeFstabSpaces $ROOTFS}}/dev/shm tmpfs {{formatMountLabel "size=65536k,nosuid,nodev,noexec" ""}} 0 0
+{{end}}
 
 {{range $value := .Mounts}}
 {{$createVal := isDirectory $value.Source}}
@@ -96,6 +100,10 @@ lxc.mount.entry = {{$value.Source}} {{escapeFstabSpaces $ROOTFS}}/{{escapeFstabS
 {{end}}
 {{end}}
 
+{{range $dest, $options := .Tmpfs}}
+lxc.mount.entry = tmpfs {{escapeFstabSpaces $ROOTFS}}/{{escapeFstabSpaces $dest}} tmpfs {{formatMountLabel (tmpfsOptions $options) ""}},create=dir 0 0
+{{end}}
+
 # limits
 {{if .Resources}}
 {{if .Resources.Memory}}
@@ -108,9 +116,18 @@ lxc.cgroup.memory.memsw.limit_in_bytes = {{$memSwap}}
 {{if .Resources.CpuShares}}
 lxc.cgroup.cpu.shares = {{.Resources.CpuShares}}
 {{end}}
+{{if .Resources.CpuRtPeriod}}
+lxc.cgroup.cpu.rt_period_us = {{.Resources.CpuRtPeriod}}
+{{end}}
+{{if .Resources.CpuRtRuntime}}
+lxc.cgroup.cpu.rt_runtime_us = {{.Resources.CpuRtRuntime}}
+{{end}}
 {{if .Resources.Cpuset}}
 lxc.cgroup.cpuset.cpus = {{.Resources.Cpuset}}
 {{end}}
+{{if gt .Resources.PidsLimit 0}}
+lxc.cgroup.pids.max = {{.Resources.PidsLimit}}
+{{end}}
 {{end}}
 
 {{if .LxcConfig}}
@@ -159,6 +176,15 @@ func escapeFstabSpaces(field string) string {
 	return strings.Replace(field, " ", "\\040", -1)
 }
 
+// tmpfsOptions returns the options of a tmpfs mount of the container, they
+// apply over the nosuid, nodev and noexec defaults
+func tmpfsOptions(options string) string {
+	if options == "" {
+		return "nosuid,nodev,noexec"
+	}
+	return "nosuid,nodev,noexec," + options
+}
+
 func keepCapabilities(adds []string, drops []string) ([]string, error) {
 	container := nativeTemplate.New()
 	log.Debugf("adds %s drops %s\n", adds, drops)
@@ -246,6 +272,7 @@ func init() {
 		"escapeFstabSpaces": escapeFstabSpaces,
 		"formatMountLabel":  label.FormatMountLabel,
 		"isDirectory":       isDirectory,
+		"tmpfsOptions":      tmpfsOptions,
 		"keepCapabilities":  keepCapabilities,
 		"dropList":          dropList,
 		"getHostname":       getHostname,
@@ -6,10 +6,15 @@ import (
 	"fmt"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/docker/docker/daemon/execdriver"
+	dockermount "github.com/docker/docker/pkg/mount"
+	"github.com/docker/docker/pkg/symlink"
 	"github.com/docker/libcontainer"
 	"github.com/docker/libcontainer/apparmor"
+	"github.com/docker/libcontainer/cgroups/systemd"
 	"github.com/docker/libcontainer/devices"
 	"github.com/docker/libcontainer/mount"
 	"github.com/docker/libcontainer/security/capabilities"
@@ -46,10 +51,20 @@ func (d *driver) createContainer(c *execdriver.Command) (*libcontainer.Config, e
 		container.AppArmorProfile = c.AppArmorProfile
 	}
 
+	container.NoNewPrivileges = c.NoNewPrivileges
+
+	if err := d.setupAdditionalGroups(container, c); err != nil {
+		return nil, err
+	}
+
 	if err := execdriver.SetupCgroups(container, c); err != nil {
 		return nil, err
 	}
 
+	if err := d.setupCgroupParent(container, c); err != nil {
+		return nil, err
+	}
+
 	if err := d.setupMounts(container, c); err != nil {
 		return nil, err
 	}
@@ -85,7 +100,9 @@ func (d *driver) createNetwork(container *libcontainer.Config, c *execdriver.Com
 		},
 	}
 
-	if c.Network.Interface != nil {
+	if c.Network.Interface != nil && isUserDefined(c.Network.Interface) {
+		container.Networks = append(container.Networks, userDefinedNetwork(c.Network.Mtu, c.Network.Interface))
+	} else if c.Network.Interface != nil {
 		vethNetwork := libcontainer.Network{
 			Mtu:        c.Network.Mtu,
 			Address:    fmt.Sprintf("%s/%d", c.Network.Interface.IPAddress, c.Network.Interface.IPPrefixLen),
@@ -101,6 +118,9 @@ func (d *driver) createNetwork(container *libcontainer.Config, c *execdriver.Com
 		}
 		container.Networks = append(container.Networks, &vethNetwork)
 	}
+	for _, iface := range c.Network.Endpoints {
+		container.Networks = append(container.Networks, endpointNetwork(c.Network.Mtu, iface))
+	}
 
 	if c.Network.ContainerID != "" {
 		d.Lock()
@@ -169,11 +189,47 @@ func (d *driver) setPrivileged(container *libcontainer.Config) (err error) {
 	return nil
 }
 
+// setupCgroupParent places the container under its parent cgroup. With the
+// systemd cgroup driver the parent is a slice, such as "user.slice".
+func (d *driver) setupCgroupParent(container *libcontainer.Config, c *execdriver.Command) error {
+	if c.CgroupParent == "" {
+		return nil
+	}
+	if systemd.UseSystemd() {
+		if !strings.HasSuffix(c.CgroupParent, ".slice") || strings.Contains(c.CgroupParent, "/") {
+			return fmt.Errorf("cgroup-parent for systemd cgroup should be a valid slice named as \"xxx.slice\", got %q", c.CgroupParent)
+		}
+		container.Cgroups.Slice = c.CgroupParent
+		return nil
+	}
+	container.Cgroups.Parent = c.CgroupParent
+	return nil
+}
+
 func (d *driver) setCapabilities(container *libcontainer.Config, c *execdriver.Command) (err error) {
 	container.Capabilities, err = execdriver.TweakCapabilities(container.Capabilities, c.CapAdd, c.CapDrop)
 	return err
 }
 
+// setupAdditionalGroups adds the groups of the command to the supplementary
+// groups of the processes, the names of the groups are looked up in the
+// /etc/group of the container
+func (d *driver) setupAdditionalGroups(container *libcontainer.Config, c *execdriver.Command) error {
+	if len(c.GroupAdd) == 0 {
+		return nil
+	}
+	groupPath, err := symlink.FollowSymlinkInScope(filepath.Join(c.Rootfs, "etc", "group"), c.Rootfs)
+	if err != nil {
+		return err
+	}
+	gids, err := execdriver.GetAdditionalGroups(c.GroupAdd, groupPath)
+	if err != nil {
+		return err
+	}
+	container.AdditionalGroups = gids
+	return nil
+}
+
 func (d *driver) setupRlimits(container *libcontainer.Config, c *execdriver.Command) {
 	if c.Resources == nil {
 		return
@@ -196,6 +252,25 @@ func (d *driver) setupMounts(container *libcontainer.Config, c *execdriver.Comma
 		})
 	}
 
+	// mount the parent directories first
+	var tmpfs []string
+	for dest := range c.Tmpfs {
+		tmpfs = append(tmpfs, dest)
+	}
+	sort.Strings(tmpfs)
+	for _, dest := range tmpfs {
+		flags, data, err := dockermount.ParseTmpfsOptions(c.Tmpfs[dest])
+		if err != nil {
+			return err
+		}
+		container.MountConfig.Mounts = append(container.MountConfig.Mounts, &mount.Mount{
+			Type:        "tmpfs",
+			Destination: dest,
+			Flags:       flags,
+			Data:        data,
+		})
+	}
+
 	return nil
 }
 
@@ -13,7 +13,9 @@ import (
 	"github.com/docker/docker/daemon/execdriver"
 	"github.com/docker/docker/pkg/reexec"
 	"github.com/docker/libcontainer"
+	"github.com/docker/libcontainer/apparmor"
 	"github.com/docker/libcontainer/namespaces"
+	"github.com/docker/libcontainer/security/capabilities"
 )
 
 const execCommandName = "nsenter-exec"
@@ -38,7 +40,7 @@ func nsenterExec() {
 	}
 }
 
-// TODO(vishh): Add support for running in priviledged mode and running as a different user.
+// TODO(vishh): Add support for running as a different user.
 func (d *driver) Exec(c *execdriver.Command, processConfig *execdriver.ProcessConfig, pipes *execdriver.Pipes, startCallback execdriver.StartCallback) (int, error) {
 	active := d.activeContainers[c.ID]
 	if active == nil {
@@ -61,7 +63,23 @@ func (d *driver) Exec(c *execdriver.Command, processConfig *execdriver.ProcessCo
 
 	args := append([]string{processConfig.Entrypoint}, processConfig.Arguments...)
 
-	return namespaces.ExecIn(active.container, state, args, os.Args[0], "exec", processConfig.Stdin, processConfig.Stdout, processConfig.Stderr, processConfig.Console,
+	// the exec'd process gets its own copy of the configuration of the container
+	config := *active.container
+	if processConfig.Env != nil {
+		config.Env = processConfig.Env
+	}
+	config.NoNewPrivileges = c.NoNewPrivileges
+	if processConfig.Dir != "" {
+		config.WorkingDir = processConfig.Dir
+	}
+	if processConfig.Privileged {
+		config.Capabilities = capabilities.GetAllCapabilities()
+		if apparmor.IsEnabled() {
+			config.AppArmorProfile = "unconfined"
+		}
+	}
+
+	return namespaces.ExecIn(&config, state, args, os.Args[0], "exec", processConfig.Stdin, processConfig.Stdout, processConfig.Stderr, processConfig.Console,
 		func(cmd *exec.Cmd) {
 			if startCallback != nil {
 				startCallback(&c.ProcessConfig, cmd.Process.Pid)
@@ -0,0 +1,124 @@
+// +build linux,cgo
+
+package native
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/libcontainer"
+	"github.com/docker/libcontainer/netlink"
+	"github.com/docker/libcontainer/network"
+	"github.com/docker/libcontainer/system"
+)
+
+// isUserDefined returns whether iface is on a user-defined network
+func isUserDefined(iface *execdriver.NetworkInterface) bool {
+	return iface.Type == "macvlan" || iface.Type == "ipvlan"
+}
+
+// userDefinedNetwork returns the configuration of an interface on a
+// user-defined network, the mtu of the network overrides mtu
+func userDefinedNetwork(mtu int, iface *execdriver.NetworkInterface) *libcontainer.Network {
+	if iface.Mtu > 0 {
+		mtu = iface.Mtu
+	}
+	n := &libcontainer.Network{
+		Mtu:            mtu,
+		Name:           iface.Name,
+		Address:        fmt.Sprintf("%s/%d", iface.IPAddress, iface.IPPrefixLen),
+		MacAddress:     iface.MacAddress,
+		Gateway:        iface.Gateway,
+		Type:           iface.Type,
+		Parent:         iface.Parent,
+		Mode:           iface.Mode,
+		NoDefaultRoute: iface.NoDefaultRoute,
+	}
+	if iface.GlobalIPv6Address != "" {
+		n.IPv6Address = fmt.Sprintf("%s/%d", iface.GlobalIPv6Address, iface.GlobalIPv6PrefixLen)
+		n.IPv6Gateway = iface.IPv6Gateway
+	}
+	return n
+}
+
+// endpointNetwork returns the configuration of an interface on one of the
+// other networks of a container, the default route stays on eth0
+func endpointNetwork(mtu int, iface *execdriver.NetworkInterface) *libcontainer.Network {
+	n := userDefinedNetwork(mtu, iface)
+	n.Gateway = ""
+	n.IPv6Gateway = ""
+	n.NoDefaultRoute = true
+	return n
+}
+
+func (d *driver) ConnectNetwork(c *execdriver.Command, iface *execdriver.NetworkInterface) error {
+	if !isUserDefined(iface) {
+		return fmt.Errorf("Unsupported interface type %q", iface.Type)
+	}
+	pid, err := d.containerPid(c.ID)
+	if err != nil {
+		return err
+	}
+	config := (*network.Network)(endpointNetwork(c.Network.Mtu, iface))
+	strategy, err := network.GetStrategy(config.Type)
+	if err != nil {
+		return err
+	}
+	state := &network.NetworkState{}
+	if err := strategy.Create(config, pid, state); err != nil {
+		return err
+	}
+	return inNetNS(pid, func() error {
+		if err := strategy.Initialize(config, state); err != nil {
+			netlink.NetworkLinkDel(state.Interfaces[iface.Name])
+			return err
+		}
+		return nil
+	})
+}
+
+func (d *driver) DisconnectNetwork(c *execdriver.Command, iface *execdriver.NetworkInterface) error {
+	pid, err := d.containerPid(c.ID)
+	if err != nil {
+		return err
+	}
+	return inNetNS(pid, func() error {
+		return netlink.NetworkLinkDel(iface.Name)
+	})
+}
+
+func (d *driver) containerPid(id string) (int, error) {
+	d.Lock()
+	active := d.activeContainers[id]
+	d.Unlock()
+	if active == nil || active.cmd.Process == nil {
+		return 0, fmt.Errorf("%s is not running", id)
+	}
+	return active.cmd.Process.Pid, nil
+}
+
+// inNetNS runs fn in the network namespace of the process pid
+func inNetNS(pid int, fn func() error) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	origin, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", syscall.Gettid()))
+	if err != nil {
+		return err
+	}
+	defer origin.Close()
+	target, err := os.Open(fmt.Sprintf("/proc/%d/ns/net", pid))
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	if err := system.Setns(target.Fd(), syscall.CLONE_NEWNET); err != nil {
+		return err
+	}
+	defer system.Setns(origin.Fd(), syscall.CLONE_NEWNET)
+	return fn()
+}
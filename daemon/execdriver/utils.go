@@ -2,10 +2,13 @@ package execdriver
 
 import (
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
 
 	"github.com/docker/docker/utils"
 	"github.com/docker/libcontainer/security/capabilities"
+	"github.com/docker/libcontainer/user"
 )
 
 func TweakCapabilities(basics, adds, drops []string) ([]string, error) {
@@ -61,3 +64,41 @@ func TweakCapabilities(basics, adds, drops []string) ([]string, error) {
 
 	return newCaps, nil
 }
+
+// GetAdditionalGroups returns the gids of the supplementary groups given by
+// gid or by name, the names are looked up in the group file at groupPath
+func GetAdditionalGroups(groups []string, groupPath string) ([]int, error) {
+	var (
+		gids    []int
+		entries []user.Group
+		parsed  bool
+	)
+	for _, group := range groups {
+		if gid, err := strconv.Atoi(group); err == nil {
+			if gid < 0 {
+				return nil, fmt.Errorf("Invalid group id: %d", gid)
+			}
+			gids = append(gids, gid)
+			continue
+		}
+		if !parsed {
+			var err error
+			if entries, err = user.ParseGroupFile(groupPath); err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("Unable to read the groups of the container: %v", err)
+			}
+			parsed = true
+		}
+		found := false
+		for _, entry := range entries {
+			if entry.Name == group {
+				gids = append(gids, entry.Gid)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("Unable to find the group %s", group)
+		}
+	}
+	return gids, nil
+}
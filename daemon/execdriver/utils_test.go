@@ -0,0 +1,42 @@
+package execdriver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGetAdditionalGroups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-groups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	groupPath := filepath.Join(dir, "group")
+	if err := ioutil.WriteFile(groupPath, []byte("root:x:0:\naudio:x:29:\nvideo:x:44:\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	gids, err := GetAdditionalGroups([]string{"audio", "1000", "video"}, groupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int{29, 1000, 44}; !reflect.DeepEqual(gids, expected) {
+		t.Fatalf("Expected the gids %v, got %v", expected, gids)
+	}
+
+	if _, err := GetAdditionalGroups([]string{"wheel"}, groupPath); err == nil {
+		t.Fatal("Expected an error for an unknown group")
+	}
+
+	// the gids need no group file
+	gids, err = GetAdditionalGroups([]string{"1000"}, filepath.Join(dir, "missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gids) != 1 || gids[0] != 1000 {
+		t.Fatalf("Expected the gid 1000, got %v", gids)
+	}
+}
@@ -0,0 +1,49 @@
+package daemon
+
+import (
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// containerGC removes, every interval, the containers that exited more than
+// age ago and do not carry one of the excluded labels.
+func (daemon *Daemon) containerGC(age, interval time.Duration, excludeLabels []string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		for _, container := range daemon.List() {
+			if !gcCollectable(container, now.Add(-age), excludeLabels) {
+				continue
+			}
+			log.Debugf("Collecting container %s, exited at %s", container.ID, container.FinishedAt)
+			daemon.statsCollector.stopCollection(container)
+			if err := daemon.Rm(container); err != nil {
+				log.Errorf("Unable to collect container %s: %s", container.ID, err)
+				continue
+			}
+			container.LogEvent("destroy")
+		}
+	}
+}
+
+// gcCollectable returns true if the container exited before the given time and
+// none of its labels matches the excluded labels, given as key or key=value.
+func gcCollectable(container *Container, before time.Time, excludeLabels []string) bool {
+	container.Lock()
+	defer container.Unlock()
+
+	// containers that never ran have no exit time, leave them alone
+	if container.Running || container.FinishedAt.IsZero() || !container.FinishedAt.Before(before) {
+		return false
+	}
+	for _, label := range excludeLabels {
+		kv := strings.SplitN(label, "=", 2)
+		value, exists := container.Config.Labels[kv[0]]
+		if exists && (len(kv) == 1 || value == kv[1]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/runconfig"
+)
+
+func TestGCCollectable(t *testing.T) {
+	now := time.Now()
+	newContainer := func(finishedAt time.Time, running bool, labels map[string]string) *Container {
+		container := &Container{
+			State:  NewState(),
+			Config: &runconfig.Config{Labels: labels},
+		}
+		container.FinishedAt = finishedAt
+		container.Running = running
+		return container
+	}
+	exclude := []string{"keep", "com.example.gc=never"}
+
+	tests := []struct {
+		container   *Container
+		collectable bool
+	}{
+		{newContainer(now.Add(-2*time.Hour), false, nil), true},
+		{newContainer(now.Add(-30*time.Minute), false, nil), false},
+		{newContainer(time.Time{}, false, nil), false},
+		{newContainer(now.Add(-2*time.Hour), true, nil), false},
+		{newContainer(now.Add(-2*time.Hour), false, map[string]string{"keep": ""}), false},
+		{newContainer(now.Add(-2*time.Hour), false, map[string]string{"com.example.gc": "never"}), false},
+		{newContainer(now.Add(-2*time.Hour), false, map[string]string{"com.example.gc": "always"}), true},
+	}
+	for i, test := range tests {
+		if collectable := gcCollectable(test.container, now.Add(-time.Hour), exclude); collectable != test.collectable {
+			t.Fatalf("Test %d: expected collectable to be %v, got %v", i, test.collectable, collectable)
+		}
+	}
+}
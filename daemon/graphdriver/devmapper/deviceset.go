@@ -587,7 +587,7 @@ func (devices *DeviceSet) createRegisterDevice(hash string) (*DevInfo, error) {
 	return info, nil
 }
 
-func (devices *DeviceSet) createRegisterSnapDevice(hash string, baseInfo *DevInfo) error {
+func (devices *DeviceSet) createRegisterSnapDevice(hash string, baseInfo *DevInfo, size uint64) error {
 	deviceId, err := devices.getNextFreeDeviceId()
 	if err != nil {
 		return err
@@ -622,7 +622,7 @@ func (devices *DeviceSet) createRegisterSnapDevice(hash string, baseInfo *DevInf
 		break
 	}
 
-	if _, err := devices.registerDevice(deviceId, hash, baseInfo.Size, devices.OpenTransactionId); err != nil {
+	if _, err := devices.registerDevice(deviceId, hash, size, devices.OpenTransactionId); err != nil {
 		devicemapper.DeleteDevice(devices.getPoolDevName(), deviceId)
 		devices.markDeviceIdFree(deviceId)
 		log.Debugf("Error registering device: %s", err)
@@ -1110,7 +1110,10 @@ func (devices *DeviceSet) initDevmapper(doInit bool) error {
 	return nil
 }
 
-func (devices *DeviceSet) AddDevice(hash, baseHash string) error {
+// AddDevice adds the snapshot hash of the device baseHash, with the size of
+// the base device when size is 0. The filesystem of the snapshot is grown
+// when it is bigger than the base device, it can't be smaller.
+func (devices *DeviceSet) AddDevice(hash, baseHash string, size uint64) error {
 	log.Debugf("[deviceset] AddDevice(hash=%s basehash=%s)", hash, baseHash)
 	defer log.Debugf("[deviceset] AddDevice(hash=%s basehash=%s) END", hash, baseHash)
 
@@ -1119,6 +1122,13 @@ func (devices *DeviceSet) AddDevice(hash, baseHash string) error {
 		return err
 	}
 
+	if size == 0 {
+		size = baseInfo.Size
+	}
+	if size < baseInfo.Size {
+		return fmt.Errorf("Container size %s can't be smaller than the size of the base device, %s", units.HumanSize(float64(size)), units.HumanSize(float64(baseInfo.Size)))
+	}
+
 	baseInfo.lock.Lock()
 	defer baseInfo.lock.Unlock()
 
@@ -1129,10 +1139,63 @@ func (devices *DeviceSet) AddDevice(hash, baseHash string) error {
 		return fmt.Errorf("device %s already exists", hash)
 	}
 
-	if err := devices.createRegisterSnapDevice(hash, baseInfo); err != nil {
+	if err := devices.createRegisterSnapDevice(hash, baseInfo, size); err != nil {
 		return err
 	}
 
+	if size > baseInfo.Size {
+		info, err := devices.lookupDevice(hash)
+		if err != nil {
+			return err
+		}
+		if err := devices.growFS(info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// growFS grows the filesystem of a device to the size of the device
+func (devices *DeviceSet) growFS(info *DevInfo) error {
+	if err := devices.activateDeviceIfNeeded(info); err != nil {
+		return fmt.Errorf("Error activating devmapper device for '%s': %s", info.Hash, err)
+	}
+	defer devices.deactivateDevice(info)
+
+	fstype, err := ProbeFsType(info.DevName())
+	if err != nil {
+		return err
+	}
+
+	// Both filesystems are grown online, xfs_growfs only works on
+	// mounted filesystems
+	fsMountPoint := path.Join(devices.root, "mnt", info.Hash)
+	if err := os.MkdirAll(fsMountPoint, 0755); err != nil {
+		return err
+	}
+	options := ""
+	if fstype == "xfs" {
+		options = "nouuid"
+	}
+	options = joinMountOptions(options, devices.mountOptions)
+	if err := syscall.Mount(info.DevName(), fsMountPoint, fstype, syscall.MS_MGC_VAL, options); err != nil {
+		return fmt.Errorf("Error mounting '%s' on '%s': %s", info.DevName(), fsMountPoint, err)
+	}
+	defer syscall.Unmount(fsMountPoint, syscall.MNT_DETACH)
+
+	switch fstype {
+	case "ext4":
+		if out, err := exec.Command("resize2fs", info.DevName()).CombinedOutput(); err != nil {
+			return fmt.Errorf("Failed to grow the filesystem of '%s': %s (%s)", info.DevName(), err, out)
+		}
+	case "xfs":
+		if out, err := exec.Command("xfs_growfs", fsMountPoint).CombinedOutput(); err != nil {
+			return fmt.Errorf("Failed to grow the filesystem of '%s': %s (%s)", info.DevName(), err, out)
+		}
+	default:
+		return fmt.Errorf("Unsupported filesystem type %s", fstype)
+	}
 	return nil
 }
 
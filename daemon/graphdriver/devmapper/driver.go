@@ -101,13 +101,23 @@ func (d *Driver) Cleanup() error {
 }
 
 func (d *Driver) Create(id, parent string) error {
-	if err := d.DeviceSet.AddDevice(id, parent); err != nil {
+	if err := d.DeviceSet.AddDevice(id, parent, 0); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// CreateReadWrite creates the device of a container with the size storage
+// option, or with the size of its parent device, dm.basesize by default.
+func (d *Driver) CreateReadWrite(id, parent string, storageOpt map[string]string) error {
+	size, err := graphdriver.ParseStorageOptSize(storageOpt)
+	if err != nil {
+		return err
+	}
+	return d.DeviceSet.AddDevice(id, parent, size)
+}
+
 func (d *Driver) Remove(id string) error {
 	if !d.DeviceSet.HasDevice(id) {
 		// Consider removing a non-existing device a no-op
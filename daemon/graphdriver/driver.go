@@ -9,6 +9,7 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/units"
 )
 
 type FsMagic uint32
@@ -27,6 +28,7 @@ const (
 	FsMagicJffs2Fs     = FsMagic(0x000072b6)
 	FsMagicZfs         = FsMagic(0x2fc12fc1)
 	FsMagicXfs         = FsMagic(0x58465342)
+	FsMagicOverlay     = FsMagic(0x794C7630)
 	FsMagicUnsupported = FsMagic(0x00000000)
 )
 
@@ -42,6 +44,7 @@ var (
 		"vfs",
 		// experimental, has to be enabled manually for now
 		"overlay",
+		"overlay2",
 	}
 
 	ErrNotSupported   = errors.New("driver not supported")
@@ -62,6 +65,7 @@ var (
 		FsMagicJffs2Fs:     "jffs2",
 		FsMagicZfs:         "zfs",
 		FsMagicXfs:         "xfs",
+		FsMagicOverlay:     "overlayfs",
 		FsMagicUnsupported: "unsupported",
 	}
 )
@@ -120,6 +124,64 @@ type Driver interface {
 	DiffSize(id, parent string) (size int64, err error)
 }
 
+// StorageOptDriver is implemented by the drivers which create the writable
+// layers of the containers with the storage options of the containers,
+// such as the size of the layer.
+type StorageOptDriver interface {
+	// CreateReadWrite creates a new, empty, writable layer for a
+	// container like Create, with the storage options of the container.
+	// The driver defaults are used for the options which aren't given.
+	CreateReadWrite(id, parent string, storageOpt map[string]string) error
+}
+
+// CreateWithStorageOpt creates the writable layer of a container with its
+// storage options. The drivers which aren't StorageOptDriver can only
+// create layers without options.
+func CreateWithStorageOpt(driver ProtoDriver, id, parent string, storageOpt map[string]string) error {
+	if d, ok := driver.(StorageOptDriver); ok {
+		return d.CreateReadWrite(id, parent, storageOpt)
+	}
+	if len(storageOpt) > 0 {
+		return fmt.Errorf("--storage-opt is not supported by the %s storage driver", driver)
+	}
+	return driver.Create(id, parent)
+}
+
+// ValidateStorageOpt checks the storage options of a container before it is
+// created, the driver may still refuse them when it creates the layer.
+func ValidateStorageOpt(driver ProtoDriver, storageOpt map[string]string) error {
+	if len(storageOpt) == 0 {
+		return nil
+	}
+	if _, ok := driver.(StorageOptDriver); !ok {
+		return fmt.Errorf("--storage-opt is not supported by the %s storage driver", driver)
+	}
+	_, err := ParseStorageOptSize(storageOpt)
+	return err
+}
+
+// ParseStorageOptSize returns the size in bytes of the size storage option,
+// which is the only storage option of the containers, 0 when it isn't given.
+func ParseStorageOptSize(storageOpt map[string]string) (uint64, error) {
+	var size uint64
+	for key, val := range storageOpt {
+		switch strings.ToLower(key) {
+		case "size":
+			s, err := units.RAMInBytes(val)
+			if err != nil {
+				return 0, err
+			}
+			if s <= 0 {
+				return 0, fmt.Errorf("Invalid storage option size %s, it has to be positive", val)
+			}
+			size = uint64(s)
+		default:
+			return 0, fmt.Errorf("Unknown storage option %s, the only option is size", key)
+		}
+	}
+	return size, nil
+}
+
 func init() {
 	drivers = make(map[string]InitFunc)
 }
@@ -31,6 +31,12 @@ func NaiveDiffDriver(driver ProtoDriver) Driver {
 	return &naiveDiffDriver{ProtoDriver: driver}
 }
 
+// CreateReadWrite creates the writable layer of a container with the
+// wrapped driver, which may or may not take storage options.
+func (gdw *naiveDiffDriver) CreateReadWrite(id, parent string, storageOpt map[string]string) error {
+	return CreateWithStorageOpt(gdw.ProtoDriver, id, parent, storageOpt)
+}
+
 // Diff produces an archive of the changes between the specified
 // layer and its parent layer which may be "".
 func (gdw *naiveDiffDriver) Diff(id, parent string) (arch archive.Archive, err error) {
@@ -9,13 +9,17 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"strings"
 	"sync"
 	"syscall"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/daemon/graphdriver/quota"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/chrootarchive"
+	"github.com/docker/docker/pkg/parsers"
+	"github.com/docker/docker/pkg/units"
 	"github.com/docker/libcontainer/label"
 )
 
@@ -51,6 +55,10 @@ func (d *naiveDiffDriverWithApply) ApplyDiff(id, parent string, diff archive.Arc
 	return b, err
 }
 
+func (d *naiveDiffDriverWithApply) CreateReadWrite(id, parent string, storageOpt map[string]string) error {
+	return graphdriver.CreateWithStorageOpt(d.Driver, id, parent, storageOpt)
+}
+
 // This backend uses the overlay union filesystem for containers
 // plus hard link file sharing for images.
 
@@ -84,10 +92,19 @@ type ActiveMount struct {
 	path    string
 	mounted bool
 }
+type overlayOptions struct {
+	// quota is the default size of the containers
+	quota quota.Quota
+}
+
 type Driver struct {
 	home       string
 	sync.Mutex // Protects concurrent modification to active
 	active     map[string]*ActiveMount
+	options    overlayOptions
+	// quotaCtl limits the size of the containers, nil when the backing
+	// filesystem has no project quotas
+	quotaCtl *quota.Control
 }
 
 var backingFs = "<unknown>"
@@ -97,6 +114,10 @@ func init() {
 }
 
 func Init(home string, options []string) (graphdriver.Driver, error) {
+	opts, err := parseOptions(options)
+	if err != nil {
+		return nil, err
+	}
 
 	if err := supportsOverlay(); err != nil {
 		return nil, graphdriver.ErrNotSupported
@@ -129,13 +150,44 @@ func Init(home string, options []string) (graphdriver.Driver, error) {
 	}
 
 	d := &Driver{
-		home:   home,
-		active: make(map[string]*ActiveMount),
+		home:    home,
+		active:  make(map[string]*ActiveMount),
+		options: opts,
+	}
+
+	if fsMagic == graphdriver.FsMagicXfs {
+		if d.quotaCtl, err = quota.NewControl(home); err != nil && err != quota.ErrQuotaNotSupported {
+			return nil, err
+		}
+	}
+	if d.options.quota.Size > 0 && d.quotaCtl == nil {
+		return nil, fmt.Errorf("Storage option overlay.size is only supported over xfs mounted with the pquota option")
 	}
 
 	return NaiveDiffDriverWithApply(d), nil
 }
 
+func parseOptions(options []string) (overlayOptions, error) {
+	o := overlayOptions{}
+	for _, option := range options {
+		key, val, err := parsers.ParseKeyValueOpt(option)
+		if err != nil {
+			return o, err
+		}
+		switch strings.ToLower(key) {
+		case "overlay.size":
+			size, err := units.RAMInBytes(val)
+			if err != nil {
+				return o, err
+			}
+			o.quota.Size = uint64(size)
+		default:
+			return o, fmt.Errorf("overlay: Unknown option %s", key)
+		}
+	}
+	return o, nil
+}
+
 func supportsOverlay() error {
 	// We can try to modprobe overlay first before looking at
 	// proc/filesystems for when overlay is supported
@@ -164,6 +216,7 @@ func (d *Driver) String() string {
 func (d *Driver) Status() [][2]string {
 	return [][2]string{
 		{"Backing Filesystem", backingFs},
+		{"Supports size quotas", fmt.Sprintf("%v", d.quotaCtl != nil)},
 	}
 }
 
@@ -171,7 +224,27 @@ func (d *Driver) Cleanup() error {
 	return nil
 }
 
-func (d *Driver) Create(id string, parent string) (retErr error) {
+func (d *Driver) Create(id string, parent string) error {
+	return d.create(id, parent, quota.Quota{})
+}
+
+// CreateReadWrite creates a container limited to the size storage option,
+// or the overlay.size default, when given.
+func (d *Driver) CreateReadWrite(id, parent string, storageOpt map[string]string) error {
+	size, err := graphdriver.ParseStorageOptSize(storageOpt)
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		size = d.options.quota.Size
+	}
+	if size > 0 && d.quotaCtl == nil {
+		return fmt.Errorf("--storage-opt size is only supported by overlay over xfs mounted with the pquota option")
+	}
+	return d.create(id, parent, quota.Quota{Size: size})
+}
+
+func (d *Driver) create(id, parent string, q quota.Quota) (retErr error) {
 	dir := d.dir(id)
 	if err := os.MkdirAll(path.Dir(dir), 0700); err != nil {
 		return err
@@ -187,6 +260,14 @@ func (d *Driver) Create(id string, parent string) (retErr error) {
 		}
 	}()
 
+	// The upper dir, and the files copied up to it, inherit the project
+	// of the container
+	if q.Size > 0 {
+		if err := d.quotaCtl.SetQuota(dir, q); err != nil {
+			return err
+		}
+	}
+
 	// Toplevel images are just a "root" dir
 	if parent == "" {
 		if err := os.Mkdir(path.Join(dir, "root"), 0755); err != nil {
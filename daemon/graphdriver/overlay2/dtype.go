@@ -0,0 +1,47 @@
+// +build linux
+
+package overlay2
+
+import (
+	"io/ioutil"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// supportsDType returns ErrDTypeNotSupported when the filesystem of dir
+// reports the files it lists with an unknown type, the whiteouts of overlay
+// are only found with the type of the files
+func supportsDType(dir string) error {
+	f, err := ioutil.TempFile(dir, "dtype-check")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := syscall.Getdents(int(d.Fd()), buf)
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			// all the files have a type
+			return nil
+		}
+		for off := 0; off < n; {
+			dirent := (*syscall.Dirent)(unsafe.Pointer(&buf[off]))
+			off += int(dirent.Reclen)
+			if dirent.Type == syscall.DT_UNKNOWN {
+				return ErrDTypeNotSupported
+			}
+		}
+	}
+}
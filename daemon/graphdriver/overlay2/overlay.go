@@ -0,0 +1,428 @@
+// +build linux
+
+package overlay2
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/daemon/graphdriver/quota"
+	"github.com/docker/docker/pkg/common"
+	"github.com/docker/docker/pkg/parsers"
+	"github.com/docker/docker/pkg/parsers/kernel"
+	"github.com/docker/docker/pkg/units"
+	"github.com/docker/libcontainer/label"
+)
+
+// This backend uses the overlay union filesystem with multiple lower
+// directories, which are supported by the kernels 4.0 and later.
+
+// Each layer has a "diff" directory with the files of the layer, and a
+// "link" file with the short name of the layer. The "l" directory of the
+// driver has a symlink to the "diff" directory of each layer named after
+// its short name, the short names keep the mount options of the deep
+// layers under the size of a page.
+
+// The layers with a parent have a "lower" file, with the links of the
+// layers under them from the parent down to the base layer, along with
+// the "work" and "merged" directories of the overlay. The overlay is
+// mounted in "merged" with "diff" as the upper directory. The base
+// layers have no "lower" file, their "diff" directory is used directly.
+
+// The writable layers of the containers can be limited in size with the
+// project quotas of XFS, their layer directory gets a project of its own.
+
+// Unlike the overlay driver, the layers share the files of their parents
+// through the overlay rather than through hard links, so images neither
+// multiply the inodes used nor pay for copying their parent layer.
+
+var (
+	// ErrDTypeNotSupported is returned when the backing filesystem doesn't
+	// report the type of the files it lists, overlay needs it to find the
+	// whiteouts
+	ErrDTypeNotSupported = errors.New("the backing filesystem is formatted without d_type support (for example xfs formatted with ftype=0), which overlay2 needs")
+
+	backingFs = "<unknown>"
+)
+
+const (
+	linkDir = "l"
+	// idLength is the length of the short names of the layers
+	idLength = 26
+	// maxDepth is the number of lower layers an overlay can be mounted
+	// with, the mount options of deeper layers don't fit in a page
+	maxDepth = 128
+)
+
+type ActiveMount struct {
+	count   int
+	path    string
+	mounted bool
+}
+
+type overlayOptions struct {
+	// quota is the default size of the writable layers of the containers
+	quota quota.Quota
+}
+
+type Driver struct {
+	home       string
+	sync.Mutex // Protects concurrent modification to active
+	active     map[string]*ActiveMount
+	options    overlayOptions
+	// quotaCtl is nil when the backing filesystem has no project quotas
+	quotaCtl *quota.Control
+}
+
+func init() {
+	graphdriver.Register("overlay2", Init)
+}
+
+func Init(home string, options []string) (graphdriver.Driver, error) {
+	opts, err := parseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := supportsMultipleLowerDir(); err != nil {
+		return nil, graphdriver.ErrNotSupported
+	}
+
+	fsMagic, err := graphdriver.GetFSMagic(home)
+	if err != nil {
+		return nil, err
+	}
+	if fsName, ok := graphdriver.FsNames[fsMagic]; ok {
+		backingFs = fsName
+	}
+
+	switch fsMagic {
+	case graphdriver.FsMagicBtrfs, graphdriver.FsMagicAufs, graphdriver.FsMagicZfs, graphdriver.FsMagicOverlay:
+		log.Errorf("'overlay2' is not supported over %s.", backingFs)
+		return nil, graphdriver.ErrIncompatibleFS
+	}
+
+	// Create the driver home dir
+	if err := os.MkdirAll(path.Join(home, linkDir), 0700); err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+
+	if err := supportsDType(home); err != nil {
+		log.Errorf("'overlay2' is not supported over %s: %v", backingFs, err)
+		return nil, graphdriver.ErrIncompatibleFS
+	}
+
+	if _, err := os.Stat(path.Join(path.Dir(home), "overlay")); err == nil {
+		log.Warnf("The images of the 'overlay' driver are not used by 'overlay2', save them with 'docker save' before switching drivers and load them with 'docker load', or pull them again")
+	}
+
+	d := &Driver{
+		home:    home,
+		active:  make(map[string]*ActiveMount),
+		options: opts,
+	}
+
+	if fsMagic == graphdriver.FsMagicXfs {
+		if d.quotaCtl, err = quota.NewControl(home); err != nil && err != quota.ErrQuotaNotSupported {
+			return nil, err
+		}
+	}
+	if d.options.quota.Size > 0 && d.quotaCtl == nil {
+		return nil, fmt.Errorf("Storage option overlay2.size is only supported over xfs mounted with the pquota option")
+	}
+
+	return graphdriver.NaiveDiffDriver(d), nil
+}
+
+func parseOptions(options []string) (overlayOptions, error) {
+	o := overlayOptions{}
+	for _, option := range options {
+		key, val, err := parsers.ParseKeyValueOpt(option)
+		if err != nil {
+			return o, err
+		}
+		switch strings.ToLower(key) {
+		case "overlay2.size":
+			size, err := units.RAMInBytes(val)
+			if err != nil {
+				return o, err
+			}
+			o.quota.Size = uint64(size)
+		default:
+			return o, fmt.Errorf("overlay2: Unknown option %s", key)
+		}
+	}
+	return o, nil
+}
+
+// supportsMultipleLowerDir checks that overlay is available and that the
+// kernel mounts it with several lower directories
+func supportsMultipleLowerDir() error {
+	v, err := kernel.GetKernelVersion()
+	if err != nil {
+		return err
+	}
+	if kernel.CompareKernelVersion(v, &kernel.KernelVersionInfo{Kernel: 4, Major: 0, Minor: 0}) < 0 {
+		log.Errorf("'overlay2' needs a kernel 4.0 or later for multiple lower directories, the kernel is %s.", v)
+		return graphdriver.ErrNotSupported
+	}
+
+	// We can try to modprobe overlay first before looking at
+	// proc/filesystems for when overlay is supported
+	exec.Command("modprobe", "overlay").Run()
+
+	f, err := os.Open("/proc/filesystems")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if s.Text() == "nodev\toverlay" {
+			return nil
+		}
+	}
+	log.Error("'overlay' not found as a supported filesystem on this host. Please ensure kernel is new enough and has overlay support loaded.")
+	return graphdriver.ErrNotSupported
+}
+
+func (d *Driver) String() string {
+	return "overlay2"
+}
+
+func (d *Driver) Status() [][2]string {
+	return [][2]string{
+		{"Backing Filesystem", backingFs},
+		{"Supports d_type", "true"},
+		{"Supports size quotas", fmt.Sprintf("%v", d.quotaCtl != nil)},
+	}
+}
+
+func (d *Driver) Cleanup() error {
+	return nil
+}
+
+func (d *Driver) Create(id string, parent string) error {
+	return d.create(id, parent, quota.Quota{})
+}
+
+// CreateReadWrite creates the writable layer of a container, limited to
+// the size storage option, or the overlay2.size default, when given.
+func (d *Driver) CreateReadWrite(id, parent string, storageOpt map[string]string) error {
+	size, err := graphdriver.ParseStorageOptSize(storageOpt)
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		size = d.options.quota.Size
+	}
+	if size > 0 && d.quotaCtl == nil {
+		return fmt.Errorf("--storage-opt size is only supported by overlay2 over xfs mounted with the pquota option")
+	}
+	return d.create(id, parent, quota.Quota{Size: size})
+}
+
+func (d *Driver) create(id, parent string, q quota.Quota) (retErr error) {
+	dir := d.dir(id)
+	if err := os.Mkdir(dir, 0700); err != nil {
+		return err
+	}
+
+	defer func() {
+		// Clean up on failure
+		if retErr != nil {
+			d.removeLink(id)
+			os.RemoveAll(dir)
+		}
+	}()
+
+	// The directories and files of the layer inherit its project
+	if q.Size > 0 {
+		if err := d.quotaCtl.SetQuota(dir, q); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Mkdir(path.Join(dir, "diff"), 0755); err != nil {
+		return err
+	}
+
+	link := strings.ToUpper(common.GenerateRandomID()[:idLength])
+	if err := os.Symlink(path.Join("..", id, "diff"), path.Join(d.home, linkDir, link)); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path.Join(dir, "link"), []byte(link), 0644); err != nil {
+		return err
+	}
+
+	// Toplevel images are just a "diff" dir
+	if parent == "" {
+		return nil
+	}
+
+	lower, err := d.getLower(parent)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path.Join(dir, "lower"), []byte(lower), 0644); err != nil {
+		return err
+	}
+	if err := os.Mkdir(path.Join(dir, "work"), 0700); err != nil {
+		return err
+	}
+	return os.Mkdir(path.Join(dir, "merged"), 0700)
+}
+
+// getLower returns the lower directories of a child of parent, the link of
+// parent followed by its own lower directories
+func (d *Driver) getLower(parent string) (string, error) {
+	parentDir := d.dir(parent)
+
+	// Ensure parent exists
+	if _, err := os.Lstat(parentDir); err != nil {
+		return "", err
+	}
+
+	parentLink, err := ioutil.ReadFile(path.Join(parentDir, "link"))
+	if err != nil {
+		return "", err
+	}
+	lowers := []string{path.Join(linkDir, string(parentLink))}
+
+	parentLower, err := ioutil.ReadFile(path.Join(parentDir, "lower"))
+	if err == nil {
+		lowers = append(lowers, strings.Split(string(parentLower), ":")...)
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+	if len(lowers) > maxDepth {
+		return "", fmt.Errorf("max depth exceeded: a layer can't have more than %d parents", maxDepth)
+	}
+	return strings.Join(lowers, ":"), nil
+}
+
+func (d *Driver) dir(id string) string {
+	return path.Join(d.home, id)
+}
+
+func (d *Driver) removeLink(id string) {
+	link, err := ioutil.ReadFile(path.Join(d.dir(id), "link"))
+	if err != nil {
+		return
+	}
+	if err := os.Remove(path.Join(d.home, linkDir, string(link))); err != nil && !os.IsNotExist(err) {
+		log.Debugf("Failed to remove link of layer %s: %v", id, err)
+	}
+}
+
+func (d *Driver) Remove(id string) error {
+	dir := d.dir(id)
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+	d.removeLink(id)
+	return os.RemoveAll(dir)
+}
+
+func (d *Driver) Get(id string, mountLabel string) (string, error) {
+	// Protect the d.active from concurrent access
+	d.Lock()
+	defer d.Unlock()
+
+	mount := d.active[id]
+	if mount != nil {
+		mount.count++
+		return mount.path, nil
+	}
+	mount = &ActiveMount{count: 1}
+
+	dir := d.dir(id)
+	if _, err := os.Stat(dir); err != nil {
+		return "", err
+	}
+
+	diffDir := path.Join(dir, "diff")
+	lower, err := ioutil.ReadFile(path.Join(dir, "lower"))
+	if err != nil {
+		// If id has no lower, just return the diff dir
+		if os.IsNotExist(err) {
+			mount.path = diffDir
+			d.active[id] = mount
+			return mount.path, nil
+		}
+		return "", err
+	}
+
+	mergedDir := path.Join(dir, "merged")
+	if err := d.mount(string(lower), diffDir, path.Join(dir, "work"), mergedDir, mountLabel); err != nil {
+		return "", err
+	}
+	mount.path = mergedDir
+	mount.mounted = true
+	d.active[id] = mount
+
+	return mount.path, nil
+}
+
+// mount mounts the overlay in merged, the lower directories are relative to
+// the home of the driver. They are given as absolute paths when the options
+// fit in a page, or else the overlay is mounted from the home of the driver.
+func (d *Driver) mount(lower, upperDir, workDir, merged, mountLabel string) error {
+	var absLowers []string
+	for _, l := range strings.Split(lower, ":") {
+		absLowers = append(absLowers, path.Join(d.home, l))
+	}
+	opts := label.FormatMountLabel(fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", strings.Join(absLowers, ":"), upperDir, workDir), mountLabel)
+	if len(opts) < syscall.Getpagesize() {
+		return syscall.Mount("overlay", merged, "overlay", 0, opts)
+	}
+
+	opts = label.FormatMountLabel(fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", lower, path.Join(path.Base(path.Dir(upperDir)), "diff"), path.Join(path.Base(path.Dir(workDir)), "work")), mountLabel)
+	if len(opts) >= syscall.Getpagesize() {
+		return fmt.Errorf("cannot mount layer, mount options are too long")
+	}
+	return mountFrom(d.home, "overlay", path.Join(path.Base(path.Dir(merged)), "merged"), "overlay", opts)
+}
+
+func (d *Driver) Put(id string) error {
+	// Protect the d.active from concurrent access
+	d.Lock()
+	defer d.Unlock()
+
+	mount := d.active[id]
+	if mount == nil {
+		log.Debugf("Put on a non-mounted device %s", id)
+		return nil
+	}
+
+	mount.count--
+	if mount.count > 0 {
+		return nil
+	}
+
+	defer delete(d.active, id)
+	if mount.mounted {
+		err := syscall.Unmount(mount.path, 0)
+		if err != nil {
+			log.Debugf("Failed to unmount %s overlay: %v", id, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (d *Driver) Exists(id string) bool {
+	_, err := os.Stat(d.dir(id))
+	return err == nil
+}
@@ -0,0 +1,114 @@
+// +build linux
+
+package overlay2
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/daemon/graphdriver/graphtest"
+	"github.com/docker/docker/pkg/reexec"
+)
+
+func init() {
+	reexec.Init()
+}
+
+// This avoids creating a new driver for each test if all tests are run
+// Make sure to put new tests between TestOverlaySetup and TestOverlayTeardown
+func TestOverlaySetup(t *testing.T) {
+	graphtest.GetDriver(t, "overlay2")
+}
+
+func TestOverlayCreateEmpty(t *testing.T) {
+	graphtest.DriverTestCreateEmpty(t, "overlay2")
+}
+
+func TestOverlayCreateBase(t *testing.T) {
+	graphtest.DriverTestCreateBase(t, "overlay2")
+}
+
+func TestOverlayCreateSnap(t *testing.T) {
+	graphtest.DriverTestCreateSnap(t, "overlay2")
+}
+
+func TestOverlayTeardown(t *testing.T) {
+	graphtest.PutDriver(t)
+}
+
+func TestOverlayLower(t *testing.T) {
+	home, err := ioutil.TempDir("", "overlay2-lower")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	if err := os.Mkdir(path.Join(home, linkDir), 0700); err != nil {
+		t.Fatal(err)
+	}
+	d := &Driver{home: home, active: make(map[string]*ActiveMount)}
+
+	if err := d.Create("base", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Create("child", "base"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Create("grandchild", "child"); err != nil {
+		t.Fatal(err)
+	}
+	lower, err := ioutil.ReadFile(path.Join(home, "grandchild", "lower"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lowers := strings.Split(string(lower), ":")
+	if len(lowers) != 2 {
+		t.Fatalf("Expected the layer to have 2 lower directories, got %s", lower)
+	}
+	// the lower directories are listed from the parent down to the base
+	for i, id := range []string{"child", "base"} {
+		target, err := os.Readlink(path.Join(home, lowers[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if target != path.Join("..", id, "diff") {
+			t.Fatalf("Expected the lower directory %d to be the diff of %s, got %s", i, id, target)
+		}
+	}
+
+	if err := d.Remove("child"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(path.Join(home, lowers[0])); !os.IsNotExist(err) {
+		t.Fatalf("Expected the link of the removed layer to be removed: %v", err)
+	}
+}
+
+func TestOverlaySupportsDType(t *testing.T) {
+	dir, err := ioutil.TempDir("", "overlay2-dtype")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := supportsDType(dir); err != nil && err != ErrDTypeNotSupported {
+		t.Fatal(err)
+	}
+}
+
+func TestOverlayParseOptions(t *testing.T) {
+	opts, err := parseOptions([]string{"overlay2.size=1G"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.quota.Size != 1024*1024*1024 {
+		t.Fatalf("Expected a default size of 1G, got %d", opts.quota.Size)
+	}
+
+	for _, option := range []string{"overlay2.size=big", "overlay2.unknown=1", "dm.basesize=10G"} {
+		if _, err := parseOptions([]string{option}); err == nil {
+			t.Fatalf("Expected an error for option %s", option)
+		}
+	}
+}
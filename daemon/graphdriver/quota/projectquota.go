@@ -0,0 +1,284 @@
+// +build linux
+
+// Package quota limits the size of directories with the project quotas of
+// XFS. Each directory gets a project ID, which the files and directories
+// created under it inherit, and the blocks of the project are limited.
+//
+// The filesystem has to be mounted with the pquota (or prjquota) option.
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ErrQuotaNotSupported is returned when the filesystem has no project quotas
+var ErrQuotaNotSupported = errors.New("project quotas are not supported by the backing filesystem, it has to be xfs mounted with the pquota option")
+
+const (
+	// ioctls of the xattrs of the files, _IOR('X', 31, struct fsxattr) and
+	// _IOW('X', 32, struct fsxattr)
+	fsIocFsGetXattr = 0x801c581f
+	fsIocFsSetXattr = 0x401c5820
+
+	fsXflagProjInherit = 0x200
+
+	// quotactl commands, QCMD(Q_XGETQUOTA, PRJQUOTA) and
+	// QCMD(Q_XSETQLIM, PRJQUOTA)
+	qXGetPrjQuota = 0x5803<<8 | 2
+	qXSetPrjQlim  = 0x5804<<8 | 2
+
+	fsDquotVersion = 1
+	fsProjQuota    = 2
+	fsDqBSoft      = 1 << 2
+	fsDqBHard      = 1 << 3
+)
+
+// fsxattr is struct fsxattr of linux/fs.h
+type fsxattr struct {
+	xflags     uint32
+	extsize    uint32
+	nextents   uint32
+	projid     uint32
+	cowextsize uint32
+	pad        [8]byte
+}
+
+// fsDiskQuota is struct fs_disk_quota of linux/dqblk_xfs.h, the limits
+// are in blocks of 512 bytes
+type fsDiskQuota struct {
+	version      int8
+	flags        int8
+	fieldmask    uint16
+	id           uint32
+	blkHardlimit uint64
+	blkSoftlimit uint64
+	inoHardlimit uint64
+	inoSoftlimit uint64
+	bcount       uint64
+	icount       uint64
+	itimer       int32
+	btimer       int32
+	iwarns       uint16
+	bwarns       uint16
+	padding2     int32
+	rtbHardlimit uint64
+	rtbSoftlimit uint64
+	rtbcount     uint64
+	rtbtimer     int32
+	rtbwarns     uint16
+	padding3     int16
+	padding4     [8]byte
+}
+
+// Quota is the limit of a directory
+type Quota struct {
+	Size uint64
+}
+
+// Control sets the quotas of the directories under a base directory, the
+// directories get the project IDs following the one of the base directory.
+type Control struct {
+	backingFsBlockDev string
+	nextProjectID     uint32
+	quotas            map[string]uint32
+	sync.Mutex
+}
+
+// NewControl returns the control of the quotas of the directories under
+// basePath, ErrQuotaNotSupported when the filesystem of basePath has no
+// project quotas.
+func NewControl(basePath string) (*Control, error) {
+	// the project IDs of the directories follow the one of basePath, the
+	// project 0 is the default project of the files
+	minProjectID, err := getProjectID(basePath)
+	if err != nil {
+		return nil, err
+	}
+	minProjectID++
+
+	// quotactl takes the block device of the filesystem, which isn't always
+	// available in /dev, so a device node with the number of the device of
+	// basePath is made for it
+	backingFsBlockDev, err := makeBackingFsDev(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// an empty quota on the first project ID checks that the filesystem has
+	// project quotas
+	if err := setProjectQuota(backingFsBlockDev, minProjectID, Quota{}); err != nil {
+		log.Debugf("Project quotas are not supported on %s: %v", basePath, err)
+		return nil, ErrQuotaNotSupported
+	}
+
+	q := &Control{
+		backingFsBlockDev: backingFsBlockDev,
+		nextProjectID:     minProjectID + 1,
+		quotas:            make(map[string]uint32),
+	}
+
+	// the directories from before the daemon started keep their project IDs
+	if err := q.findNextProjectID(basePath); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// SetQuota gives targetPath a project ID, when it has none yet, and limits
+// the blocks of the project to the size of quota. targetPath has to be
+// empty, the files already in it don't get the project ID.
+func (q *Control) SetQuota(targetPath string, quota Quota) error {
+	q.Lock()
+	defer q.Unlock()
+
+	projectID, ok := q.quotas[targetPath]
+	if !ok {
+		projectID = q.nextProjectID
+		if err := setProjectID(targetPath, projectID); err != nil {
+			return err
+		}
+		q.quotas[targetPath] = projectID
+		q.nextProjectID++
+	}
+
+	log.Debugf("Setting the quota of project %d (%s) to %d bytes", projectID, targetPath, quota.Size)
+	return setProjectQuota(q.backingFsBlockDev, projectID, quota)
+}
+
+// GetQuota returns the quota of targetPath
+func (q *Control) GetQuota(targetPath string) (Quota, error) {
+	q.Lock()
+	projectID, ok := q.quotas[targetPath]
+	q.Unlock()
+	if !ok {
+		return Quota{}, fmt.Errorf("no quota for %s", targetPath)
+	}
+
+	var d fsDiskQuota
+	if err := quotactl(qXGetPrjQuota, q.backingFsBlockDev, projectID, &d); err != nil {
+		return Quota{}, fmt.Errorf("Failed to get the quota of project %d (%s): %v", projectID, targetPath, err)
+	}
+	return Quota{Size: d.blkHardlimit * 512}, nil
+}
+
+// findNextProjectID records the project IDs of the directories in home
+// and moves the next project ID past them
+func (q *Control) findNextProjectID(home string) error {
+	files, err := ioutil.ReadDir(home)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if !file.IsDir() {
+			continue
+		}
+		dir := path.Join(home, file.Name())
+		projectID, err := getProjectID(dir)
+		if err != nil {
+			return err
+		}
+		if projectID == 0 {
+			continue
+		}
+		q.quotas[dir] = projectID
+		if projectID >= q.nextProjectID {
+			q.nextProjectID = projectID + 1
+		}
+	}
+	return nil
+}
+
+func setProjectQuota(backingFsBlockDev string, projectID uint32, quota Quota) error {
+	d := fsDiskQuota{
+		version:      fsDquotVersion,
+		flags:        fsProjQuota,
+		fieldmask:    fsDqBSoft | fsDqBHard,
+		id:           projectID,
+		blkHardlimit: quota.Size / 512,
+		blkSoftlimit: quota.Size / 512,
+	}
+	if err := quotactl(qXSetPrjQlim, backingFsBlockDev, projectID, &d); err != nil {
+		return fmt.Errorf("Failed to set the quota of project %d: %v", projectID, err)
+	}
+	return nil
+}
+
+func quotactl(cmd int, special string, id uint32, d *fsDiskQuota) error {
+	p, err := syscall.BytePtrFromString(special)
+	if err != nil {
+		return err
+	}
+	if _, _, errno := syscall.Syscall6(syscall.SYS_QUOTACTL, uintptr(cmd), uintptr(unsafe.Pointer(p)), uintptr(id), uintptr(unsafe.Pointer(d)), 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func getProjectID(targetPath string) (uint32, error) {
+	fsx, err := getFsxattr(targetPath)
+	if err != nil {
+		return 0, err
+	}
+	return fsx.projid, nil
+}
+
+// setProjectID sets the project ID of targetPath, with the inherit flag
+// for the files created under it
+func setProjectID(targetPath string, projectID uint32) error {
+	fsx, err := getFsxattr(targetPath)
+	if err != nil {
+		return err
+	}
+	fsx.projid = projectID
+	fsx.xflags |= fsXflagProjInherit
+
+	dir, err := os.Open(targetPath)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dir.Fd(), fsIocFsSetXattr, uintptr(unsafe.Pointer(&fsx))); errno != 0 {
+		return fmt.Errorf("Failed to set the project ID of %s: %v", targetPath, errno)
+	}
+	return nil
+}
+
+func getFsxattr(targetPath string) (fsxattr, error) {
+	var fsx fsxattr
+	dir, err := os.Open(targetPath)
+	if err != nil {
+		return fsx, err
+	}
+	defer dir.Close()
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dir.Fd(), fsIocFsGetXattr, uintptr(unsafe.Pointer(&fsx))); errno != 0 {
+		return fsx, fmt.Errorf("Failed to get the project ID of %s: %v", targetPath, errno)
+	}
+	return fsx, nil
+}
+
+// makeBackingFsDev makes the block device node of the filesystem of home
+func makeBackingFsDev(home string) (string, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(home, &stat); err != nil {
+		return "", err
+	}
+
+	backingFsBlockDev := path.Join(home, "backingFsBlockDev")
+	// the device number changes when the filesystem is mounted elsewhere
+	if err := os.Remove(backingFsBlockDev); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if err := syscall.Mknod(backingFsBlockDev, syscall.S_IFBLK|0600, int(stat.Dev)); err != nil {
+		return "", fmt.Errorf("Failed to make the block device of the backing filesystem: %v", err)
+	}
+	return backingFsBlockDev, nil
+}
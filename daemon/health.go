@@ -0,0 +1,252 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/daemon/execdriver/lxc"
+	"github.com/docker/docker/runconfig"
+)
+
+const (
+	defaultProbeInterval = 30 * time.Second
+	defaultProbeTimeout  = 30 * time.Second
+	defaultProbeRetries  = 3
+
+	// maxHealthLogEntries is the number of probe results kept in the
+	// health of a container, the oldest ones are dropped first
+	maxHealthLogEntries = 5
+	// maxProbeOutputLen is the number of bytes of the output of a probe
+	// kept in its result
+	maxProbeOutputLen = 4096
+)
+
+// The statuses of the health of a container with a healthcheck, and of a
+// container without one for the health filter of docker ps
+const (
+	HealthStarting = "starting"
+	Healthy        = "healthy"
+	Unhealthy      = "unhealthy"
+	NoHealthcheck  = "none"
+)
+
+// Health is the health of a running container, as reported by the probes
+// of the healthcheck of its config
+type Health struct {
+	Status string
+	// FailingStreak is the number of consecutive failed probes
+	FailingStreak int
+	// Log are the results of the last probes
+	Log []*HealthcheckResult
+
+	// stop stops the probes of the container when it is closed
+	stop chan struct{}
+}
+
+// HealthcheckResult is the result of a probe of the healthcheck
+type HealthcheckResult struct {
+	Start    time.Time
+	End      time.Time
+	ExitCode int
+	Output   string
+}
+
+// healthStatus returns the status of the health of the container, or
+// NoHealthcheck when it has no healthcheck
+func (s *State) healthStatus() string {
+	if s.Health == nil {
+		return NoHealthcheck
+	}
+	return s.Health.Status
+}
+
+// healthSummary returns the health of the container as shown with its
+// status by docker ps
+func (s *State) healthSummary() string {
+	if s.Health == nil {
+		return ""
+	}
+	if s.Health.Status == HealthStarting {
+		return " (health: starting)"
+	}
+	return fmt.Sprintf(" (%s)", s.Health.Status)
+}
+
+// probeCommand returns the command run by the probes of the healthcheck, nil
+// when the healthcheck is disabled
+func probeCommand(config *runconfig.HealthConfig) []string {
+	if config == nil || len(config.Test) < 2 {
+		return nil
+	}
+	switch config.Test[0] {
+	case "CMD":
+		return config.Test[1:]
+	case "CMD-SHELL":
+		return []string{"/bin/sh", "-c", config.Test[1]}
+	}
+	return nil
+}
+
+// initHealthMonitor starts the probes of the healthcheck of the container
+// when it starts, the container is locked
+func (container *Container) initHealthMonitor() {
+	container.Health = nil
+	config := container.Config.Healthcheck
+	cmd := probeCommand(config)
+	if cmd == nil {
+		return
+	}
+	if strings.HasPrefix(container.daemon.execDriver.Name(), lxc.DriverName) {
+		log.Warnf("The healthcheck of container %s is ignored, the %s exec driver cannot run its probes", container.ID, container.daemon.execDriver.Name())
+		return
+	}
+
+	interval, timeout, retries := config.Interval, config.Timeout, config.Retries
+	if interval == 0 {
+		interval = defaultProbeInterval
+	}
+	if timeout == 0 {
+		timeout = defaultProbeTimeout
+	}
+	if retries == 0 {
+		retries = defaultProbeRetries
+	}
+
+	container.Health = &Health{
+		Status: HealthStarting,
+		stop:   make(chan struct{}),
+	}
+	go container.monitorHealth(container.Health, cmd, interval, timeout, retries)
+}
+
+// stopHealthMonitor stops the probes of the healthcheck of the container
+// when it exits
+func (container *Container) stopHealthMonitor() {
+	container.Lock()
+	defer container.Unlock()
+	if container.Health != nil && container.Health.stop != nil {
+		close(container.Health.stop)
+		container.Health.stop = nil
+	}
+}
+
+// monitorHealth runs a probe every interval until the container exits, the
+// container is unhealthy after retries consecutive failed probes
+func (container *Container) monitorHealth(health *Health, cmd []string, interval, timeout time.Duration, retries int) {
+	stop := health.stop
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+		// the probes of a paused container would hang until it is unpaused
+		if container.IsPaused() {
+			continue
+		}
+
+		result := container.probe(cmd, timeout)
+
+		container.Lock()
+		select {
+		case <-stop:
+			container.Unlock()
+			return
+		default:
+		}
+		previous := health.Status
+		health.Log = append(health.Log, result)
+		if len(health.Log) > maxHealthLogEntries {
+			health.Log = health.Log[len(health.Log)-maxHealthLogEntries:]
+		}
+		if result.ExitCode == 0 {
+			health.FailingStreak = 0
+			health.Status = Healthy
+		} else {
+			health.FailingStreak++
+			if health.FailingStreak >= retries {
+				health.Status = Unhealthy
+			}
+		}
+		status := health.Status
+		container.Unlock()
+
+		if status != previous {
+			container.LogEvent("health_status: " + status)
+		}
+	}
+}
+
+// probeOutput keeps the beginning of the output of a probe
+type probeOutput struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *probeOutput) Write(p []byte) (int, error) {
+	o.Lock()
+	defer o.Unlock()
+	if n := maxProbeOutputLen - o.buf.Len(); n > 0 {
+		if len(p) > n {
+			o.buf.Write(p[:n])
+		} else {
+			o.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (o *probeOutput) String() string {
+	o.Lock()
+	defer o.Unlock()
+	return o.buf.String()
+}
+
+// probe runs the command of the healthcheck in the container, the probe
+// fails when the command exits with a non-zero code or runs longer than
+// timeout, in which case it is killed
+func (container *Container) probe(cmd []string, timeout time.Duration) *HealthcheckResult {
+	processConfig := &execdriver.ProcessConfig{
+		Entrypoint: cmd[0],
+		Arguments:  cmd[1:],
+	}
+	output := &probeOutput{}
+	pipes := execdriver.NewPipes(nil, output, output, false)
+
+	started := make(chan int, 1)
+	exited := make(chan int, 1)
+	result := &HealthcheckResult{Start: time.Now()}
+	go func() {
+		exitCode, err := container.daemon.execDriver.Exec(container.command, processConfig, pipes, func(_ *execdriver.ProcessConfig, pid int) {
+			started <- pid
+		})
+		if err != nil {
+			fmt.Fprintf(output, "%v\n", err)
+			if exitCode == 0 {
+				exitCode = -1
+			}
+		}
+		exited <- exitCode
+	}()
+
+	select {
+	case result.ExitCode = <-exited:
+	case <-time.After(timeout):
+		select {
+		case pid := <-started:
+			syscall.Kill(pid, syscall.SIGKILL)
+		default:
+		}
+		result.ExitCode = -1
+		fmt.Fprintf(output, "The probe did not complete within %s\n", timeout)
+	}
+	result.End = time.Now()
+	result.Output = output.String()
+	return result
+}
@@ -0,0 +1,59 @@
+package daemon
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/runconfig"
+)
+
+func TestProbeCommand(t *testing.T) {
+	for _, c := range []struct {
+		test     []string
+		expected []string
+	}{
+		{nil, nil},
+		{[]string{"NONE"}, nil},
+		{[]string{"CMD", "curl", "-f", "http://localhost/"}, []string{"curl", "-f", "http://localhost/"}},
+		{[]string{"CMD-SHELL", "curl -f http://localhost/ || exit 1"}, []string{"/bin/sh", "-c", "curl -f http://localhost/ || exit 1"}},
+	} {
+		if cmd := probeCommand(&runconfig.HealthConfig{Test: c.test}); !reflect.DeepEqual(cmd, c.expected) {
+			t.Fatalf("Expected the probe command of %q to be %q, got %q", c.test, c.expected, cmd)
+		}
+	}
+	if cmd := probeCommand(nil); cmd != nil {
+		t.Fatalf("Expected no probe command without a healthcheck, got %q", cmd)
+	}
+}
+
+func TestStateHealthSummary(t *testing.T) {
+	s := NewState()
+	s.SetRunning(100)
+	if status := s.healthStatus(); status != NoHealthcheck {
+		t.Fatalf("Expected the health status %s without a healthcheck, got %s", NoHealthcheck, status)
+	}
+	if str := s.String(); strings.Contains(str, "(") {
+		t.Fatalf("Expected no health summary without a healthcheck, got %s", str)
+	}
+
+	s.Health = &Health{Status: HealthStarting}
+	if str := s.String(); !strings.HasSuffix(str, " (health: starting)") {
+		t.Fatalf("Expected the starting health in the status, got %s", str)
+	}
+	s.Health.Status = Unhealthy
+	if str := s.String(); !strings.HasSuffix(str, " (unhealthy)") {
+		t.Fatalf("Expected the unhealthy health in the status, got %s", str)
+	}
+}
+
+func TestProbeOutputIsTruncated(t *testing.T) {
+	output := &probeOutput{}
+	output.Write([]byte(strings.Repeat("a", maxProbeOutputLen-1)))
+	if n, err := output.Write([]byte("bcd")); n != 3 || err != nil {
+		t.Fatalf("Expected the whole output to be consumed, got %d, %v", n, err)
+	}
+	if str := output.String(); len(str) != maxProbeOutputLen || !strings.HasSuffix(str, "ab") {
+		t.Fatalf("Expected the output to be truncated to %d bytes, got %d", maxProbeOutputLen, len(str))
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/docker/docker/engine"
+	"github.com/docker/docker/events"
 	"github.com/docker/docker/graph"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/common"
@@ -102,9 +103,9 @@ func (daemon *Daemon) DeleteImage(eng *engine.Engine, name string, imgs *engine.
 		}
 		if tagDeleted {
 			out := &engine.Env{}
-			out.Set("Untagged", repoName+":"+tag)
+			out.Set("Untagged", parsers.JoinRepositoryTag(repoName, tag))
 			imgs.Add(out)
-			eng.Job("log", "untag", img.ID, "").Run()
+			events.LogEvent(eng, events.ImageEventType, "untag", img.ID, "", map[string]string{"name": parsers.JoinRepositoryTag(repoName, tag)})
 		}
 	}
 	tags = daemon.Repositories().ByID()[img.ID]
@@ -119,7 +120,7 @@ func (daemon *Daemon) DeleteImage(eng *engine.Engine, name string, imgs *engine.
 			out := &engine.Env{}
 			out.SetJson("Deleted", img.ID)
 			imgs.Add(out)
-			eng.Job("log", "delete", img.ID, "").Run()
+			events.LogEvent(eng, events.ImageEventType, "delete", img.ID, "", nil)
 			if img.Parent != "" && !noprune {
 				err := daemon.DeleteImage(eng, img.Parent, imgs, false, force, noprune)
 				if first {
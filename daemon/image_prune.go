@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/events"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/parsers"
+	"github.com/docker/docker/pkg/parsers/filters"
+)
+
+var acceptedImagePruneFilterTags = map[string]struct{}{"label": {}, "until": {}}
+
+// ImagePrune removes the dangling images, the images which are neither
+// tagged nor referenced by digest, or all the images no container uses when
+// all is set. The filters select the images to remove by label and by
+// creation time.
+func (daemon *Daemon) ImagePrune(job *engine.Job) engine.Status {
+	imageFilters, err := filters.FromParam(job.Getenv("filters"))
+	if err != nil {
+		return job.Error(err)
+	}
+	for name := range imageFilters {
+		if _, ok := acceptedImagePruneFilterTags[name]; !ok {
+			return job.Errorf("Invalid filter '%s'", name)
+		}
+	}
+	var until time.Time
+	for _, value := range imageFilters["until"] {
+		if until, err = parseUntilFilter(value, time.Now()); err != nil {
+			return job.Error(err)
+		}
+	}
+	all := job.GetenvBool("all")
+
+	images, err := daemon.Graph().Map()
+	if err != nil {
+		return job.Error(err)
+	}
+	used := make(map[string]bool)
+	for _, container := range daemon.List() {
+		used[container.ImageID] = true
+	}
+
+	var (
+		imgs      = engine.NewTable("", 0)
+		reclaimed uint64
+		// the images which were not removed, they are not tried again
+		kept = make(map[string]bool)
+	)
+	// the parents of the removed images may become unused, the heads are
+	// pruned until no image is removed
+	for {
+		heads, err := daemon.Graph().Heads()
+		if err != nil {
+			return job.Error(err)
+		}
+		byID := daemon.Repositories().ByID()
+		removed := false
+		for id, img := range heads {
+			if kept[id] {
+				continue
+			}
+			if used[id] || !pruneMatch(img, imageFilters, until) {
+				kept[id] = true
+				continue
+			}
+			dangling := len(byID[id]) == 0 && len(daemon.Repositories().RepoDigests(id)) == 0
+			if !dangling && !all {
+				kept[id] = true
+				continue
+			}
+			n := len(imgs.Data)
+			if err := daemon.pruneImage(job.Eng, img, byID[id], imgs); err != nil {
+				// the parents of the image may be used by a container
+				log.Debugf("Error pruning image %s: %s", id, err)
+				kept[id] = true
+			}
+			if len(imgs.Data) > n {
+				removed = true
+			}
+		}
+		if !removed {
+			break
+		}
+	}
+
+	report := &types.ImagesPruneReport{ImagesDeleted: []types.ImageDelete{}}
+	// the layers shared with the images which are kept reclaim no space,
+	// and a layer shared by several removed images is counted once
+	removedLayers := make(map[string]int64)
+	for _, out := range imgs.Data {
+		deleted := out.Get("Deleted")
+		if img, exists := images[deleted]; deleted != "" && exists {
+			removedLayers[img.LayerID()] = img.Size
+		}
+		report.ImagesDeleted = append(report.ImagesDeleted, types.ImageDelete{
+			Untagged: out.Get("Untagged"),
+			Deleted:  deleted,
+		})
+	}
+	for layerID, size := range removedLayers {
+		if size > 0 && !daemon.Graph().LayerInUse(layerID) {
+			reclaimed += uint64(size)
+		}
+	}
+	report.SpaceReclaimed = reclaimed
+	if err := json.NewEncoder(job.Stdout).Encode(report); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+// pruneImage untags the image and deletes it with its untagged parents
+func (daemon *Daemon) pruneImage(eng *engine.Engine, img *image.Image, tags []string, imgs *engine.Table) error {
+	for _, repoAndTag := range tags {
+		repoName, tag := parsers.ParseRepositoryTag(repoAndTag)
+		tagDeleted, err := daemon.Repositories().Delete(repoName, tag)
+		if err != nil {
+			return err
+		}
+		if tagDeleted {
+			out := &engine.Env{}
+			out.Set("Untagged", repoAndTag)
+			imgs.Add(out)
+			events.LogEvent(eng, events.ImageEventType, "untag", img.ID, "", map[string]string{"name": repoAndTag})
+		}
+	}
+	return daemon.DeleteImage(eng, img.ID, imgs, true, false, false)
+}
+
+// pruneMatch returns whether the image is selected by the label filters and
+// was created before until, when it is set
+func pruneMatch(img *image.Image, imageFilters filters.Args, until time.Time) bool {
+	if !until.IsZero() && !img.Created.Before(until) {
+		return false
+	}
+	var labels map[string]string
+	if img.Config != nil {
+		labels = img.Config.Labels
+	}
+	return imageFilters.MatchKVList("label", labels)
+}
+
+// parseUntilFilter parses the value of the until filter, a duration before
+// now such as 24h, or a Unix timestamp, or a date in the RFC 3339 format
+func parseUntilFilter(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid filter 'until=%s', expected a duration, a timestamp or a date", value)
+}
@@ -0,0 +1,56 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/parsers/filters"
+	"github.com/docker/docker/runconfig"
+)
+
+func TestParseUntilFilter(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	tests := map[string]time.Time{
+		"24h":                  now.Add(-24 * time.Hour),
+		"1400000000":           time.Unix(1400000000, 0),
+		"2015-06-01T10:00:00Z": time.Date(2015, 6, 1, 10, 0, 0, 0, time.UTC),
+	}
+	for value, expected := range tests {
+		until, err := parseUntilFilter(value, now)
+		if err != nil {
+			t.Fatalf("Error parsing until=%s: %s", value, err)
+		}
+		if !until.Equal(expected) {
+			t.Fatalf("Expected until=%s to be %s, got %s", value, expected, until)
+		}
+	}
+	if _, err := parseUntilFilter("yesterday", now); err == nil {
+		t.Fatal("Expected an invalid until filter to be refused")
+	}
+}
+
+func TestPruneMatch(t *testing.T) {
+	now := time.Now()
+	img := &image.Image{
+		Created: now.Add(-time.Hour),
+		Config:  &runconfig.Config{Labels: map[string]string{"com.example.temporary": "true"}},
+	}
+	tests := []struct {
+		filters filters.Args
+		until   time.Time
+		match   bool
+	}{
+		{filters.Args{}, time.Time{}, true},
+		{filters.Args{}, now, true},
+		{filters.Args{}, now.Add(-2 * time.Hour), false},
+		{filters.Args{"label": {"com.example.temporary"}}, time.Time{}, true},
+		{filters.Args{"label": {"com.example.temporary=false"}}, time.Time{}, false},
+		{filters.Args{"label": {"com.example.other"}}, time.Time{}, false},
+	}
+	for _, test := range tests {
+		if match := pruneMatch(img, test.filters, test.until); match != test.match {
+			t.Fatalf("Expected the match of %v until %s to be %v, got %v", test.filters, test.until, test.match, match)
+		}
+	}
+}
@@ -53,10 +53,19 @@ func (daemon *Daemon) ContainerInspect(job *engine.Job) engine.Status {
 	out.Set("ProcessLabel", container.ProcessLabel)
 	out.SetJson("Volumes", container.Volumes)
 	out.SetJson("VolumesRW", container.VolumesRW)
-	out.SetJson("AppArmorProfile", container.AppArmorProfile)
+	out.SetJson("AppArmorProfile", container.appArmorProfile())
+	out.SetBool("NoNewPrivileges", container.NoNewPrivileges)
 
 	out.SetList("ExecIDs", container.GetExecIDs())
 
+	// the sizes are computed on demand, walking the filesystem of the
+	// container is slow
+	if job.GetenvBool("size") {
+		sizeRw, sizeRootFs := container.GetSize()
+		out.SetInt64("SizeRw", sizeRw)
+		out.SetInt64("SizeRootFs", sizeRootFs)
+	}
+
 	if children, err := daemon.Children(container.Name); err == nil {
 		for linkAlias, child := range children {
 			container.hostConfig.Links = append(container.hostConfig.Links, fmt.Sprintf("%s:%s", child.Name, linkAlias))
@@ -77,12 +86,15 @@ func (daemon *Daemon) ContainerExecInspect(job *engine.Job) engine.Status {
 		return job.Errorf("usage: %s ID", job.Name)
 	}
 	id := job.Args[0]
-	eConfig, err := daemon.getExecConfig(id)
-	if err != nil {
-		return job.Error(err)
+	// the result of the command is kept after the container stopped
+	eConfig := daemon.execCommands.Get(id)
+	if eConfig == nil {
+		return job.Errorf("No such exec instance '%s' found in daemon", id)
 	}
 
-	b, err := json.Marshal(*eConfig)
+	eConfig.Lock()
+	b, err := json.Marshal(eConfig)
+	eConfig.Unlock()
 	if err != nil {
 		return job.Error(err)
 	}
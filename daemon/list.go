@@ -27,12 +27,20 @@ func (daemon *Daemon) Containers(job *engine.Job) engine.Status {
 		since       = job.Getenv("since")
 		before      = job.Getenv("before")
 		n           = job.GetenvInt("limit")
+		offset      = job.GetenvInt("offset")
+		// the paginated requests list the stopped containers with all only,
+		// a limit alone lists them as docker ps -n always did
+		paginated   = job.Getenv("offset") != ""
 		size        = job.GetenvBool("size")
 		psFilters   filters.Args
 		filt_exited []int
 	)
 	outs := engine.NewTable("Created", 0)
 
+	if offset < 0 {
+		return job.Errorf("Invalid offset %d", offset)
+	}
+
 	psFilters, err := filters.FromParam(job.Getenv("filters"))
 	if err != nil {
 		return job.Error(err)
@@ -47,6 +55,12 @@ func (daemon *Daemon) Containers(job *engine.Job) engine.Status {
 		}
 	}
 
+	for _, value := range psFilters["health"] {
+		if value != HealthStarting && value != Healthy && value != Unhealthy && value != NoHealthcheck {
+			return job.Errorf("Invalid health filter '%s', it must be %s, %s, %s or %s", value, HealthStarting, Healthy, Unhealthy, NoHealthcheck)
+		}
+	}
+
 	if i, ok := psFilters["status"]; ok {
 		for _, value := range i {
 			if value == "exited" {
@@ -79,7 +93,7 @@ func (daemon *Daemon) Containers(job *engine.Job) engine.Status {
 	writeCont := func(container *Container) error {
 		container.Lock()
 		defer container.Unlock()
-		if !container.Running && !all && n <= 0 && since == "" && before == "" {
+		if !container.Running && !all && (n <= 0 || paginated) && since == "" && before == "" {
 			return nil
 		}
 		if !psFilters.Match("name", container.Name) {
@@ -90,13 +104,17 @@ func (daemon *Daemon) Containers(job *engine.Job) engine.Status {
 			return nil
 		}
 
+		if !psFilters.MatchKVList("label", container.Config.Labels) {
+			return nil
+		}
+
 		if before != "" && !foundBefore {
 			if container.ID == beforeCont.ID {
 				foundBefore = true
 			}
 			return nil
 		}
-		if n > 0 && displayed == n {
+		if n > 0 && displayed == offset+n {
 			return errLast
 		}
 		if since != "" {
@@ -120,6 +138,10 @@ func (daemon *Daemon) Containers(job *engine.Job) engine.Status {
 		if !psFilters.Match("status", container.State.StateString()) {
 			return nil
 		}
+
+		if !psFilters.ExactMatch("health", container.healthStatus()) {
+			return nil
+		}
 		displayed++
 		out := &engine.Env{}
 		out.SetJson("Id", container.ID)
@@ -170,6 +192,8 @@ func (daemon *Daemon) Containers(job *engine.Job) engine.Status {
 		}
 	}
 	outs.ReverseSort()
+	outs.Paginate(offset, n)
+	outs.Select(job.GetenvList("fields"))
 	if _, err := outs.WriteListTo(job.Stdout); err != nil {
 		return job.Error(err)
 	}
@@ -115,11 +115,13 @@ func (daemon *Daemon) ContainerLogs(job *engine.Job) engine.Status {
 	if follow && container.IsRunning() {
 		errors := make(chan error, 2)
 		wg := sync.WaitGroup{}
+		var pipes []io.Closer
 
 		if stdout {
 			wg.Add(1)
 			stdoutPipe := container.StdoutLogPipe()
 			defer stdoutPipe.Close()
+			pipes = append(pipes, stdoutPipe)
 			go func() {
 				errors <- jsonlog.WriteLog(stdoutPipe, job.Stdout, format)
 				wg.Done()
@@ -129,17 +131,33 @@ func (daemon *Daemon) ContainerLogs(job *engine.Job) engine.Status {
 			wg.Add(1)
 			stderrPipe := container.StderrLogPipe()
 			defer stderrPipe.Close()
+			pipes = append(pipes, stderrPipe)
 			go func() {
 				errors <- jsonlog.WriteLog(stderrPipe, job.Stderr, format)
 				wg.Done()
 			}()
 		}
 
-		wg.Wait()
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-daemon.draining:
+			// end the streams before the containers are stopped, and tell
+			// the client why they ended
+			for _, pipe := range pipes {
+				pipe.Close()
+			}
+			<-done
+			fmt.Fprintf(job.Stderr, "%s\n", drainMessage)
+		}
 		close(errors)
 
 		for err := range errors {
-			if err != nil {
+			if err != nil && err != io.ErrClosedPipe {
 				log.Errorf("%s", err)
 			}
 		}
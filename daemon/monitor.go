@@ -115,6 +115,10 @@ func (m *containerMonitor) Start() error {
 			defer m.container.Unlock()
 		}
 		m.Close()
+		// the removal stops the container first, which needs its lock
+		if afterRun && m.container.hostConfig.AutoRemove {
+			go m.container.daemon.autoRemove(m.container)
+		}
 	}()
 
 	// reset the restart count
@@ -150,6 +154,7 @@ func (m *containerMonitor) Start() error {
 
 		// here container.Lock is already lost
 		afterRun = true
+		m.container.stopHealthMonitor()
 
 		m.resetMonitor(err == nil && exitStatus.ExitCode == 0)
 
@@ -253,6 +258,7 @@ func (m *containerMonitor) callback(processConfig *execdriver.ProcessConfig, pid
 	}
 
 	m.container.setRunning(pid)
+	m.container.initHealthMonitor()
 
 	// signal that the process has started
 	// close channel only if not closed
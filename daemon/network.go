@@ -0,0 +1,757 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/daemon/networkdriver"
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/networks"
+	"github.com/docker/docker/pkg/networkfs/etchosts"
+	"github.com/docker/docker/pkg/parsers/filters"
+	"github.com/docker/docker/runconfig"
+)
+
+// hostsLock serializes the updates of the hosts files of the containers of
+// user-defined networks, each of them lists the other containers
+var hostsLock sync.Mutex
+
+// NetworkCreate creates a user-defined network, the name of the network is
+// the only argument of the job
+func (daemon *Daemon) NetworkCreate(job *engine.Job) engine.Status {
+	if len(job.Args) != 1 {
+		return job.Errorf("Usage: %s NAME", job.Name)
+	}
+	var options, ipamOptions, labels map[string]string
+	if job.EnvExists("Labels") {
+		if err := job.GetenvJson("Labels", &labels); err != nil {
+			return job.Error(err)
+		}
+	}
+	if job.EnvExists("Options") {
+		if err := job.GetenvJson("Options", &options); err != nil {
+			return job.Error(err)
+		}
+	}
+	if job.EnvExists("IPAMOptions") {
+		if err := job.GetenvJson("IPAMOptions", &ipamOptions); err != nil {
+			return job.Error(err)
+		}
+	}
+	n, err := daemon.networks.Create(job.Args[0], networks.Config{
+		Driver:  job.Getenv("Driver"),
+		Options: options,
+		Subnet:  job.Getenv("Subnet"),
+		IPRange: job.Getenv("IPRange"),
+		Gateway: job.Getenv("Gateway"),
+		DNS: networks.DNSConfig{
+			Nameservers: job.GetenvList("Dns"),
+			Search:      job.GetenvList("DnsSearch"),
+			Options:     job.GetenvList("DnsOptions"),
+		},
+		Internal:    job.GetenvBool("Internal"),
+		IPAMDriver:  job.Getenv("IPAMDriver"),
+		IPAMOptions: ipamOptions,
+		Labels:      labels,
+	})
+	if err != nil {
+		return job.Error(err)
+	}
+	job.Printf("%s\n", n.ID)
+	return engine.StatusOK
+}
+
+var acceptedNetworkFilterTags = map[string]struct{}{"label": {}}
+
+// NetworkList lists the networks, the label filters select them by their
+// labels
+func (daemon *Daemon) NetworkList(job *engine.Job) engine.Status {
+	networkFilters, err := filters.FromParam(job.Getenv("filters"))
+	if err != nil {
+		return job.Error(err)
+	}
+	for name := range networkFilters {
+		if _, ok := acceptedNetworkFilterTags[name]; !ok {
+			return job.Errorf("Invalid filter '%s'", name)
+		}
+	}
+
+	list := []*types.NetworkResource{}
+	for _, n := range daemon.networks.List() {
+		if !networkFilters.MatchKVList("label", n.Labels) {
+			continue
+		}
+		list = append(list, daemon.networkResource(n, false))
+	}
+	if err := json.NewEncoder(job.Stdout).Encode(list); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+func (daemon *Daemon) NetworkInspect(job *engine.Job) engine.Status {
+	if len(job.Args) != 1 {
+		return job.Errorf("Usage: %s NETWORK", job.Name)
+	}
+	n, err := daemon.networks.Get(job.Args[0])
+	if err != nil {
+		return job.Error(err)
+	}
+	if err := json.NewEncoder(job.Stdout).Encode(daemon.networkResource(n, job.GetenvBool("Verbose"))); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+func (daemon *Daemon) NetworkRm(job *engine.Job) engine.Status {
+	if len(job.Args) != 1 {
+		return job.Errorf("Usage: %s NETWORK", job.Name)
+	}
+	n, err := daemon.networks.Get(job.Args[0])
+	if err != nil {
+		return job.Error(err)
+	}
+	// the network only knows the running containers, the stopped ones
+	// would get an interface on it when they start again
+	var containers []string
+	for _, container := range daemon.List() {
+		if container.networkEndpoint(n) != nil {
+			containers = append(containers, container.ID)
+		}
+	}
+	if len(containers) > 0 {
+		sort.Strings(containers)
+		return job.Errorf("Network %s is being used and cannot be removed: used by containers %s", n.Name, containers)
+	}
+	if err := daemon.networks.Delete(n.ID); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+func (daemon *Daemon) networkResource(n *networks.Network, verbose bool) *types.NetworkResource {
+	r := &types.NetworkResource{
+		Name:    n.Name,
+		ID:      n.ID,
+		Driver:  n.Driver,
+		Options: n.Options,
+		IPAM: types.IPAM{
+			Driver:  n.IPAMDriver,
+			Options: n.IPAMOptions,
+			Config:  []types.IPAMConfig{{Subnet: n.Subnet, IPRange: n.IPRange, Gateway: n.Gateway}},
+		},
+		Created:    n.Created,
+		Internal:   n.Internal,
+		Labels:     n.Labels,
+		Containers: make(map[string]types.EndpointResource),
+	}
+	if n.IPv6Subnet != "" {
+		r.IPAM.Config = append(r.IPAM.Config, types.IPAMConfig{Subnet: n.IPv6Subnet, Gateway: n.IPv6Gateway})
+	}
+	if dns := n.DNS; len(dns.Nameservers) > 0 || len(dns.Search) > 0 || len(dns.Options) > 0 {
+		r.DNS = &types.DNSConfig{Nameservers: dns.Nameservers, Search: dns.Search, Options: dns.Options}
+	}
+	for _, id := range n.Containers() {
+		c := daemon.containers.Get(id)
+		if c == nil {
+			continue
+		}
+		ep := c.networkEndpoint(n)
+		if ep == nil {
+			continue
+		}
+		endpoint := types.EndpointResource{
+			Name:        strings.TrimPrefix(c.Name, "/"),
+			MacAddress:  ep.MacAddress,
+			IPv4Address: fmt.Sprintf("%s/%d", ep.IPAddress, ep.IPPrefixLen),
+			Aliases:     ep.Aliases,
+		}
+		if ep.GlobalIPv6Address != "" {
+			endpoint.IPv6Address = fmt.Sprintf("%s/%d", ep.GlobalIPv6Address, ep.GlobalIPv6PrefixLen)
+		}
+		if verbose {
+			endpoint.Interface = ep.Interface
+			if iface := c.joinedInterface(n, ep); iface != nil {
+				endpoint.DriverInfo = driverInfo(iface)
+			}
+		}
+		r.Containers[id] = endpoint
+	}
+	if verbose && len(n.DriverState) > 0 {
+		r.DriverState = n.DriverState
+	}
+	return r
+}
+
+// joinedInterface returns the interface the driver of n set up in the
+// running container, or nil when the container is not running
+func (container *Container) joinedInterface(n *networks.Network, ep *EndpointSettings) *execdriver.NetworkInterface {
+	if !container.IsRunning() || container.command == nil || container.command.Network == nil {
+		return nil
+	}
+	network := container.command.Network
+	if _, exists := container.NetworkSettings.Networks[n.Name]; !exists {
+		// n is the network mode of the container
+		return network.Interface
+	}
+	for _, iface := range network.Endpoints {
+		if iface.Name == ep.Interface {
+			return iface
+		}
+	}
+	return nil
+}
+
+// driverInfo returns the driver specific fields of iface
+func driverInfo(iface *execdriver.NetworkInterface) map[string]string {
+	info := map[string]string{"type": iface.Type, "parent": iface.Parent}
+	if iface.Mode != "" {
+		info["mode"] = iface.Mode
+	}
+	if iface.Gateway != "" {
+		info["gateway"] = iface.Gateway
+	}
+	if iface.IPv6Gateway != "" {
+		info["ipv6_gateway"] = iface.IPv6Gateway
+	}
+	if iface.NoDefaultRoute {
+		info["no_default_route"] = "true"
+	}
+	if iface.Mtu > 0 {
+		info["mtu"] = strconv.Itoa(iface.Mtu)
+	}
+	return info
+}
+
+// joinNetwork fills the driver specific fields of iface for a container
+// connected to n
+func joinNetwork(n *networks.Network, iface *execdriver.NetworkInterface) error {
+	driver, err := networks.GetDriver(n.Driver)
+	if err != nil {
+		return err
+	}
+	if err := driver.Join(n, iface); err != nil {
+		return err
+	}
+	// the parent of the interface of a plugin is only known once joined
+	if err := networkdriver.CheckParentMTU(iface.Parent, n.MTU()); err != nil {
+		return err
+	}
+	iface.Mtu = n.MTU()
+	if n.Internal {
+		iface.Gateway = ""
+		iface.IPv6Gateway = ""
+		iface.NoDefaultRoute = true
+	}
+	return nil
+}
+
+// NetworkConnect connects a container to a user-defined network besides
+// the one of its network mode. A running container gets an interface on
+// the network right away, a stopped one when it starts.
+func (daemon *Daemon) NetworkConnect(job *engine.Job) engine.Status {
+	if len(job.Args) != 2 {
+		return job.Errorf("Usage: %s NETWORK CONTAINER", job.Name)
+	}
+	n, err := daemon.networks.Get(job.Args[0])
+	if err != nil {
+		return job.Error(err)
+	}
+	container, err := daemon.Get(job.Args[1])
+	if err != nil {
+		return job.Error(err)
+	}
+	if err := container.connectNetwork(n, job.GetenvList("Aliases"), job.Getenv("IPAddress"), job.Getenv("IPv6Address")); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+// NetworkDisconnect disconnects a container from one of the networks it
+// was connected to with NetworkConnect
+func (daemon *Daemon) NetworkDisconnect(job *engine.Job) engine.Status {
+	if len(job.Args) != 2 {
+		return job.Errorf("Usage: %s NETWORK CONTAINER", job.Name)
+	}
+	n, err := daemon.networks.Get(job.Args[0])
+	if err != nil {
+		return job.Error(err)
+	}
+	container, err := daemon.Get(job.Args[1])
+	if err != nil {
+		return job.Error(err)
+	}
+	if err := container.disconnectNetwork(n); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+func (container *Container) connectNetwork(n *networks.Network, aliases []string, ipv4, ipv6 string) error {
+	container.Lock()
+	defer container.Unlock()
+
+	name := strings.TrimPrefix(container.Name, "/")
+	if container.Config.NetworkDisabled || !container.hostConfig.NetworkMode.IsPrivate() {
+		return fmt.Errorf("Container %s cannot be connected to networks, its network mode is %s", name, container.hostConfig.NetworkMode)
+	}
+	if container.networkEndpoint(n) != nil {
+		return fmt.Errorf("Container %s is already connected to network %s", name, n.Name)
+	}
+	if err := checkStaticIP(n, ipv4, ipv6); err != nil {
+		return err
+	}
+
+	if container.NetworkSettings.Networks == nil {
+		container.NetworkSettings.Networks = make(map[string]*EndpointSettings)
+	}
+	ep := &EndpointSettings{
+		NetworkID:            n.ID,
+		Aliases:              aliases,
+		Interface:            container.nextInterfaceName(),
+		RequestedIPAddress:   ipv4,
+		RequestedIPv6Address: ipv6,
+	}
+	container.NetworkSettings.Networks[n.Name] = ep
+
+	if container.Running {
+		if err := container.allocateEndpoint(n.Name, false); err != nil {
+			delete(container.NetworkSettings.Networks, n.Name)
+			return err
+		}
+		iface, err := container.endpointInterface(n, ep)
+		if err == nil {
+			err = container.daemon.execDriver.ConnectNetwork(container.command, iface)
+		}
+		if err != nil {
+			container.releaseEndpoint(n.Name)
+			delete(container.NetworkSettings.Networks, n.Name)
+			return err
+		}
+		container.command.Network.Endpoints = append(container.command.Network.Endpoints, iface)
+		container.addNetworkHosts(n)
+	}
+	return container.toDisk()
+}
+
+func (container *Container) disconnectNetwork(n *networks.Network) error {
+	container.Lock()
+	defer container.Unlock()
+
+	name := strings.TrimPrefix(container.Name, "/")
+	ep, exists := container.NetworkSettings.Networks[n.Name]
+	if !exists {
+		if container.networkEndpoint(n) != nil {
+			return fmt.Errorf("Container %s cannot be disconnected from network %s, it is its network mode", name, n.Name)
+		}
+		return fmt.Errorf("Container %s is not connected to network %s", name, n.Name)
+	}
+
+	if container.Running && ep.IPAddress != "" {
+		iface, err := container.endpointInterface(n, ep)
+		if err == nil {
+			err = container.daemon.execDriver.DisconnectNetwork(container.command, iface)
+		}
+		if err != nil {
+			return err
+		}
+		container.removeCommandEndpoint(iface.Name)
+		container.releaseEndpoint(n.Name)
+	}
+	delete(container.NetworkSettings.Networks, n.Name)
+	return container.toDisk()
+}
+
+// removeCommandEndpoint forgets the interface name of a running container
+// disconnected from one of its other networks
+func (container *Container) removeCommandEndpoint(name string) {
+	endpoints := container.command.Network.Endpoints[:0]
+	for _, iface := range container.command.Network.Endpoints {
+		if iface.Name != name {
+			endpoints = append(endpoints, iface)
+		}
+	}
+	container.command.Network.Endpoints = endpoints
+}
+
+// verifyEndpointConfigs checks the other networks a container is to be
+// connected to on creation
+func (daemon *Daemon) verifyEndpointConfigs(hostConfig *runconfig.HostConfig) error {
+	if len(hostConfig.Networks) == 0 {
+		return nil
+	}
+	if !hostConfig.NetworkMode.IsPrivate() {
+		return runconfig.ErrConflictNetworkConnect
+	}
+	connected := make(map[string]bool)
+	if hostConfig.NetworkMode.IsUserDefined() {
+		n, err := daemon.networks.Get(string(hostConfig.NetworkMode))
+		if err != nil {
+			return err
+		}
+		connected[n.ID] = true
+	}
+	for _, endpoint := range hostConfig.Networks {
+		n, err := daemon.networks.Get(endpoint.Network)
+		if err != nil {
+			return err
+		}
+		if connected[n.ID] {
+			return fmt.Errorf("Network %s is given more than once", n.Name)
+		}
+		connected[n.ID] = true
+		if err := checkStaticIP(n, endpoint.IPAddress, endpoint.IPv6Address); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkStaticIP checks that the addresses requested for a container are in
+// the subnets of n, either can be empty
+func checkStaticIP(n *networks.Network, ipv4, ipv6 string) error {
+	if ipv4 != "" {
+		_, subnet, err := net.ParseCIDR(n.Subnet)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(ipv4); ip == nil || !subnet.Contains(ip) {
+			return fmt.Errorf("Address %s is not in the subnet %s of network %s", ipv4, n.Subnet, n.Name)
+		}
+	}
+	if ipv6 != "" {
+		if n.IPv6Subnet == "" {
+			return fmt.Errorf("Network %s has no IPv6 subnet, the daemon runs without --ipv6", n.Name)
+		}
+		_, subnet6, err := net.ParseCIDR(n.IPv6Subnet)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(ipv6); ip == nil || !subnet6.Contains(ip) {
+			return fmt.Errorf("Address %s is not in the subnet %s of network %s", ipv6, n.IPv6Subnet, n.Name)
+		}
+	}
+	return nil
+}
+
+// connectEndpoints records the other networks of a new container, it gets
+// an interface on each of them, along with the one of its network mode,
+// when it starts
+func (container *Container) connectEndpoints(endpoints []runconfig.EndpointConfig) {
+	if len(endpoints) == 0 {
+		return
+	}
+	container.NetworkSettings.Networks = make(map[string]*EndpointSettings)
+	for _, endpoint := range endpoints {
+		n, err := container.daemon.networks.Get(endpoint.Network)
+		if err != nil {
+			continue
+		}
+		container.NetworkSettings.Networks[n.Name] = &EndpointSettings{
+			NetworkID:            n.ID,
+			Aliases:              endpoint.Aliases,
+			Interface:            container.nextInterfaceName(),
+			RequestedIPAddress:   endpoint.IPAddress,
+			RequestedIPv6Address: endpoint.IPv6Address,
+		}
+	}
+}
+
+// endpointNames returns the sorted names of the networks the container is
+// connected to besides the one of its network mode
+func (container *Container) endpointNames() []string {
+	names := make([]string, 0, len(container.NetworkSettings.Networks))
+	for name := range container.NetworkSettings.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// nextInterfaceName returns the first name not used by the interfaces of
+// the container, eth0 is the interface of its network mode
+func (container *Container) nextInterfaceName() string {
+	used := map[string]bool{"eth0": true}
+	for _, ep := range container.NetworkSettings.Networks {
+		used[ep.Interface] = true
+	}
+	for i := 1; ; i++ {
+		if name := fmt.Sprintf("eth%d", i); !used[name] {
+			return name
+		}
+	}
+}
+
+// networkEndpoint returns the connection of the container to n, through
+// its network mode or as one of its other networks, or nil
+func (container *Container) networkEndpoint(n *networks.Network) *EndpointSettings {
+	if ep, exists := container.NetworkSettings.Networks[n.Name]; exists {
+		return ep
+	}
+	if mode := container.hostConfig.NetworkMode; mode.IsUserDefined() {
+		if m, err := container.daemon.networks.Get(string(mode)); err == nil && m.ID == n.ID {
+			settings := container.NetworkSettings
+			return &EndpointSettings{
+				NetworkID:           n.ID,
+				Aliases:             container.hostConfig.NetworkAliases,
+				Interface:           "eth0",
+				IPAddress:           settings.IPAddress,
+				IPPrefixLen:         settings.IPPrefixLen,
+				MacAddress:          settings.MacAddress,
+				GlobalIPv6Address:   settings.GlobalIPv6Address,
+				GlobalIPv6PrefixLen: settings.GlobalIPv6PrefixLen,
+			}
+		}
+	}
+	return nil
+}
+
+// userDefinedNetworks returns the user-defined networks of the container,
+// the one of its network mode first
+func (container *Container) userDefinedNetworks() ([]*networks.Network, error) {
+	var list []*networks.Network
+	if mode := container.hostConfig.NetworkMode; mode.IsUserDefined() {
+		n, err := container.daemon.networks.Get(string(mode))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+	}
+	for _, name := range container.endpointNames() {
+		n, err := container.daemon.networks.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+// allocateAddresses allocates the addresses of the container on n, the
+// requested ones when they are set
+func (container *Container) allocateAddresses(n *networks.Network, requestedMac, requestedIP, requestedIPv6 string) (net.IP, net.IP, net.HardwareAddr, error) {
+	var requested net.IP
+	if requestedIP != "" {
+		requested = net.ParseIP(requestedIP)
+	}
+	ip, err := n.RequestIP(requested)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Failed to allocate an address on network %s: %v", n.Name, err)
+	}
+	var requestedV6 net.IP
+	if requestedIPv6 != "" {
+		requestedV6 = net.ParseIP(requestedIPv6)
+	}
+	ipv6, err := n.RequestIPv6(requestedV6)
+	if err != nil {
+		n.ReleaseIP(ip)
+		return nil, nil, nil, fmt.Errorf("Failed to allocate an IPv6 address on network %s: %v", n.Name, err)
+	}
+	mac, err := net.ParseMAC(requestedMac)
+	if err != nil {
+		mac = networkdriver.GenerateMacAddr(ip)
+	}
+	return ip, ipv6, mac, nil
+}
+
+// allocateEndpoints allocates the addresses of the container on its other
+// networks, the previous addresses are requested again on a restore
+func (container *Container) allocateEndpoints(restore bool) error {
+	for _, name := range container.endpointNames() {
+		if err := container.allocateEndpoint(name, restore); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (container *Container) allocateEndpoint(name string, restore bool) error {
+	ep := container.NetworkSettings.Networks[name]
+	n, err := container.daemon.networks.Get(name)
+	if err != nil {
+		return err
+	}
+	var requestedMac string
+	requestedIP, requestedIPv6 := ep.RequestedIPAddress, ep.RequestedIPv6Address
+	if restore {
+		requestedMac, requestedIP, requestedIPv6 = ep.MacAddress, ep.IPAddress, ep.GlobalIPv6Address
+	}
+	ip, ipv6, mac, err := container.allocateAddresses(n, requestedMac, requestedIP, requestedIPv6)
+	if err != nil {
+		return err
+	}
+	n.AddContainer(container.ID)
+
+	ep.NetworkID = n.ID
+	ep.IPAddress = ip.String()
+	ep.IPPrefixLen = n.PrefixLen()
+	ep.MacAddress = mac.String()
+	ep.GlobalIPv6Address = ""
+	ep.GlobalIPv6PrefixLen = 0
+	if ipv6 != nil {
+		ep.GlobalIPv6Address = ipv6.String()
+		ep.GlobalIPv6PrefixLen = n.IPv6PrefixLen()
+	}
+	return nil
+}
+
+func (container *Container) releaseEndpoints() {
+	for _, name := range container.endpointNames() {
+		container.releaseEndpoint(name)
+	}
+}
+
+func (container *Container) releaseEndpoint(name string) {
+	ep := container.NetworkSettings.Networks[name]
+	if ep.IPAddress == "" {
+		return
+	}
+	if n, err := container.daemon.networks.Get(name); err != nil {
+		log.Errorf("Error releasing network %s of %s: %v", name, container.ID, err)
+	} else {
+		n.ReleaseIP(net.ParseIP(ep.IPAddress))
+		if ip := net.ParseIP(ep.GlobalIPv6Address); ip != nil {
+			n.ReleaseIPv6(ip)
+		}
+		container.removeNetworkHosts(n)
+		n.RemoveContainer(container.ID)
+	}
+	ep.IPAddress = ""
+	ep.IPPrefixLen = 0
+	ep.MacAddress = ""
+	ep.GlobalIPv6Address = ""
+	ep.GlobalIPv6PrefixLen = 0
+}
+
+// endpointInterface returns the interface of the container on n
+func (container *Container) endpointInterface(n *networks.Network, ep *EndpointSettings) (*execdriver.NetworkInterface, error) {
+	iface := &execdriver.NetworkInterface{
+		Name:                ep.Interface,
+		IPAddress:           ep.IPAddress,
+		IPPrefixLen:         ep.IPPrefixLen,
+		MacAddress:          ep.MacAddress,
+		GlobalIPv6Address:   ep.GlobalIPv6Address,
+		GlobalIPv6PrefixLen: ep.GlobalIPv6PrefixLen,
+	}
+	if err := joinNetwork(n, iface); err != nil {
+		return nil, err
+	}
+	return iface, nil
+}
+
+// networkPeers returns the other containers connected to n whose hosts file
+// was built
+func (container *Container) networkPeers(n *networks.Network) []*Container {
+	var peers []*Container
+	for _, id := range n.Containers() {
+		if id == container.ID {
+			continue
+		}
+		if c := container.daemon.containers.Get(id); c != nil && c.HostsPath != "" && c.networkEndpoint(n) != nil {
+			peers = append(peers, c)
+		}
+	}
+	return peers
+}
+
+// networkHostsRecord resolves the name, the aliases and the hostname of the
+// container to its address on n
+func (container *Container) networkHostsRecord(n *networks.Network) etchosts.Record {
+	ep := container.networkEndpoint(n)
+	hosts := append([]string{strings.TrimPrefix(container.Name, "/")}, ep.Aliases...)
+	if container.Config.Hostname != "" {
+		hosts = append(hosts, container.Config.Hostname)
+	}
+	return etchosts.Record{Hosts: strings.Join(hosts, " "), IP: ep.IPAddress}
+}
+
+// buildNetworkHostsFile builds the hosts file of a container connected to
+// user-defined networks, the other containers of the networks are resolved
+// by name and by alias, and the container is added to their hosts file
+func (container *Container) buildNetworkHostsFile(IP string, extraContent []etchosts.Record) error {
+	nets, err := container.userDefinedNetworks()
+	if err != nil {
+		return err
+	}
+
+	hostsLock.Lock()
+	defer hostsLock.Unlock()
+
+	var recs []etchosts.Record
+	for _, n := range nets {
+		recs = append(recs, container.networkHostsRecord(n))
+		for _, c := range container.networkPeers(n) {
+			recs = append(recs, c.networkHostsRecord(n))
+		}
+	}
+	if err := etchosts.Build(container.HostsPath, IP, container.Config.Hostname, container.Config.Domainname, append(recs, extraContent...)); err != nil {
+		return err
+	}
+
+	for _, n := range nets {
+		container.publishNetworkHosts(n)
+	}
+	return nil
+}
+
+// publishNetworkHosts adds the container to the hosts file of the other
+// containers of n
+func (container *Container) publishNetworkHosts(n *networks.Network) {
+	rec := []etchosts.Record{container.networkHostsRecord(n)}
+	for _, c := range container.networkPeers(n) {
+		if err := etchosts.Add(c.HostsPath, rec); err != nil {
+			log.Errorf("Error adding %s to the hosts file of %s: %v", container.ID, c.ID, err)
+		}
+	}
+}
+
+// addNetworkHosts adds the containers of n to the hosts file of the
+// running container, and the container to theirs
+func (container *Container) addNetworkHosts(n *networks.Network) {
+	hostsLock.Lock()
+	defer hostsLock.Unlock()
+
+	recs := []etchosts.Record{container.networkHostsRecord(n)}
+	for _, c := range container.networkPeers(n) {
+		recs = append(recs, c.networkHostsRecord(n))
+	}
+	if err := etchosts.Add(container.HostsPath, recs); err != nil {
+		log.Errorf("Error adding network %s to the hosts file of %s: %v", n.Name, container.ID, err)
+	}
+	container.publishNetworkHosts(n)
+}
+
+// removeNetworkHosts removes the containers of n from the hosts file of the
+// container, and the container from theirs
+func (container *Container) removeNetworkHosts(n *networks.Network) {
+	ep := container.networkEndpoint(n)
+	if ep == nil || ep.IPAddress == "" {
+		return
+	}
+
+	hostsLock.Lock()
+	defer hostsLock.Unlock()
+
+	own := []etchosts.Record{container.networkHostsRecord(n)}
+	recs := own
+	for _, c := range container.networkPeers(n) {
+		if err := etchosts.Delete(c.HostsPath, own); err != nil {
+			log.Errorf("Error removing %s from the hosts file of %s: %v", container.ID, c.ID, err)
+		}
+		recs = append(recs, c.networkHostsRecord(n))
+	}
+	if container.HostsPath != "" {
+		if err := etchosts.Delete(container.HostsPath, recs); err != nil && !os.IsNotExist(err) {
+			log.Errorf("Error removing network %s from the hosts file of %s: %v", n.Name, container.ID, err)
+		}
+	}
+}
@@ -21,6 +21,27 @@ type NetworkSettings struct {
 	Bridge                 string
 	PortMapping            map[string]PortMapping // Deprecated
 	Ports                  nat.PortMap
+	// Networks are the user-defined networks the container is connected to
+	// besides the one of its network mode, keyed by name
+	Networks map[string]*EndpointSettings `json:",omitempty"`
+}
+
+// EndpointSettings is the connection of a container to one of its other
+// user-defined networks. The addresses are only set while the container
+// runs.
+type EndpointSettings struct {
+	NetworkID           string
+	Aliases             []string `json:",omitempty"`
+	Interface           string
+	IPAddress           string
+	IPPrefixLen         int
+	MacAddress          string
+	GlobalIPv6Address   string
+	GlobalIPv6PrefixLen int
+	// RequestedIPAddress and RequestedIPv6Address are the addresses asked
+	// for on the network, they are allocated from the network when empty
+	RequestedIPAddress   string `json:",omitempty"`
+	RequestedIPv6Address string `json:",omitempty"`
 }
 
 func (settings *NetworkSettings) PortMappingAPI() *engine.Table {
@@ -13,11 +13,13 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/daemon/networkdriver"
 	"github.com/docker/docker/daemon/networkdriver/ipallocator"
+	"github.com/docker/docker/daemon/networkdriver/portallocator"
 	"github.com/docker/docker/daemon/networkdriver/portmapper"
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/nat"
 	"github.com/docker/docker/pkg/iptables"
 	"github.com/docker/docker/pkg/networkfs/resolvconf"
+	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/parsers/kernel"
 	"github.com/docker/libcontainer/netlink"
 )
@@ -103,6 +105,16 @@ func InitDriver(job *engine.Job) engine.Status {
 		defaultBindingIP = net.ParseIP(defaultIP)
 	}
 
+	if portRange := job.Getenv("PortRange"); portRange != "" {
+		begin, end, err := parsers.ParsePortRange(portRange)
+		if err != nil {
+			return job.Errorf("Invalid port range %s: %v", portRange, err)
+		}
+		if err := portallocator.SetPortRange(int(begin), int(end)); err != nil {
+			return job.Error(err)
+		}
+	}
+
 	bridgeIface = job.Getenv("BridgeIface")
 	usingDefaultBridge := false
 	if bridgeIface == "" {
@@ -238,6 +250,7 @@ func InitDriver(job *engine.Job) engine.Status {
 	}
 
 	bridgeIPv4Network = networkv4
+	portmapper.SetHairpinSubnet(bridgeIPv4Network)
 	if fixedCIDR != "" {
 		_, subnet, err := net.ParseCIDR(fixedCIDR)
 		if err != nil {
@@ -267,6 +280,12 @@ func InitDriver(job *engine.Job) engine.Status {
 	// https://github.com/docker/docker/issues/2768
 	job.Eng.Hack_SetGlobalVar("httpapi.bridgeIP", bridgeIPv4Network.IP)
 
+	out := engine.Env{}
+	out.Set("Subnet", bridgeIPv4Network.String())
+	if _, err := out.WriteTo(job.Stdout); err != nil {
+		return job.Error(err)
+	}
+
 	for name, f := range map[string]engine.Handler{
 		"allocate_interface": Allocate,
 		"release_interface":  Release,
@@ -459,31 +478,6 @@ func createBridgeIface(name string) error {
 	return netlink.CreateBridge(name, setBridgeMacAddr)
 }
 
-// Generate a IEEE802 compliant MAC address from the given IP address.
-//
-// The generator is guaranteed to be consistent: the same IP will always yield the same
-// MAC address. This is to avoid ARP cache issues.
-func generateMacAddr(ip net.IP) net.HardwareAddr {
-	hw := make(net.HardwareAddr, 6)
-
-	// The first byte of the MAC address has to comply with these rules:
-	// 1. Unicast: Set the least-significant bit to 0.
-	// 2. Address is locally administered: Set the second-least-significant bit (U/L) to 1.
-	// 3. As "small" as possible: The veth address has to be "smaller" than the bridge address.
-	hw[0] = 0x02
-
-	// The first 24 bits of the MAC represent the Organizationally Unique Identifier (OUI).
-	// Since this address is locally administered, we can do whatever we want as long as
-	// it doesn't conflict with other addresses.
-	hw[1] = 0x42
-
-	// Insert the IP address into the last 32 bits of the MAC address.
-	// This is a simple way to guarantee the address will be consistent and unique.
-	copy(hw[2:], ip.To4())
-
-	return hw
-}
-
 func linkLocalIPv6FromMac(mac string) (string, error) {
 	hx := strings.Replace(mac, ":", "", -1)
 	hw, err := hex.DecodeString(hx)
@@ -515,7 +509,7 @@ func Allocate(job *engine.Job) engine.Status {
 
 	// If no explicit mac address was given, generate a random one.
 	if mac, err = net.ParseMAC(job.Getenv("RequestedMac")); err != nil {
-		mac = generateMacAddr(ip)
+		mac = networkdriver.GenerateMacAddr(ip)
 	}
 
 	if globalIPv6Network != nil {
@@ -608,9 +602,10 @@ func AllocatePort(job *engine.Job) engine.Status {
 		ip            = defaultBindingIP
 		id            = job.Args[0]
 		hostIP        = job.Getenv("HostIP")
-		hostPort      = job.GetenvInt("HostPort")
+		hostPort      = job.Getenv("HostPort")
 		containerPort = job.GetenvInt("ContainerPort")
 		proto         = job.Getenv("Proto")
+		useProxy      = !job.GetenvBool("NoProxy")
 		network       = currentInterfaces.Get(id)
 	)
 
@@ -621,6 +616,14 @@ func AllocatePort(job *engine.Job) engine.Status {
 		}
 	}
 
+	// a single host port, a range of host ports or 0 for a dynamic port
+	var hostPortStart, hostPortEnd uint64
+	if hostPort != "" {
+		if hostPortStart, hostPortEnd, err = parsers.ParsePortRange(hostPort); err != nil {
+			return job.Errorf("Bad parameter: invalid host port %s", hostPort)
+		}
+	}
+
 	// host ip, proto, and host port
 	var container net.Addr
 	switch proto {
@@ -641,13 +644,13 @@ func AllocatePort(job *engine.Job) engine.Status {
 
 	var host net.Addr
 	for i := 0; i < MaxAllocatedPortAttempts; i++ {
-		if host, err = portmapper.Map(container, ip, hostPort); err == nil {
+		if host, err = portmapper.MapRange(container, ip, int(hostPortStart), int(hostPortEnd), useProxy); err == nil {
 			break
 		}
 		// There is no point in immediately retrying to map an explicitly
 		// chosen port.
-		if hostPort != 0 {
-			job.Logf("Failed to allocate and map port %d: %s", hostPort, err)
+		if hostPortStart != 0 && hostPortStart == hostPortEnd {
+			job.Logf("Failed to allocate and map port %d: %s", hostPortStart, err)
 			break
 		}
 		job.Logf("Failed to allocate and map port: %s, retry: %d", err, i+1)
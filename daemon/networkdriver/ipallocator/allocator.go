@@ -103,6 +103,14 @@ func ReleaseIP(network *net.IPNet, ip net.IP) error {
 	return nil
 }
 
+// ReleaseNetwork forgets the network and all the ips allocated on it, so
+// that it can be registered again.
+func ReleaseNetwork(network *net.IPNet) {
+	lock.Lock()
+	delete(allocatedIPs, network.String())
+	lock.Unlock()
+}
+
 func (allocated *allocatedMap) checkIP(ip net.IP) (net.IP, error) {
 	if _, ok := allocated.p[ip.String()]; ok {
 		return nil, ErrIPAlreadyAllocated
@@ -598,6 +598,34 @@ func TestRegisterBadTwice(t *testing.T) {
 	}
 }
 
+func TestReleaseNetwork(t *testing.T) {
+	defer reset()
+	network := &net.IPNet{
+		IP:   []byte{192, 168, 1, 1},
+		Mask: []byte{255, 255, 255, 0},
+	}
+	subnet := &net.IPNet{
+		IP:   []byte{192, 168, 1, 8},
+		Mask: []byte{255, 255, 255, 248},
+	}
+	if err := RegisterSubnet(network, subnet); err != nil {
+		t.Fatal(err)
+	}
+	ip := net.ParseIP("192.168.1.9")
+	if _, err := RequestIP(network, ip); err != nil {
+		t.Fatal(err)
+	}
+
+	ReleaseNetwork(network)
+
+	if err := RegisterSubnet(network, subnet); err != nil {
+		t.Fatalf("Expected the network to be registered again, got %v", err)
+	}
+	if _, err := RequestIP(network, ip); err != nil {
+		t.Fatalf("Expected %s to be released, got %v", ip, err)
+	}
+}
+
 func TestRegisterBadRange(t *testing.T) {
 	defer reset()
 	network := &net.IPNet{
@@ -0,0 +1,85 @@
+// Package ipvlan implements the ipvlan network driver. The containers of an
+// ipvlan network share the MAC address of a parent host interface, which
+// suits networks filtering unknown MAC addresses where macvlan cannot be
+// used. In l2 mode the containers are on the network of the parent, in l3
+// mode the host routes their traffic and the subnet is not on the wire.
+package ipvlan
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/daemon/networkdriver"
+	"github.com/docker/docker/networks"
+)
+
+const (
+	// DriverName is the name of the driver in docker network create -d
+	DriverName = "ipvlan"
+
+	parentOpt = "parent"
+	modeOpt   = "ipvlan_mode"
+
+	modeL2 = "l2"
+	modeL3 = "l3"
+
+	// createdParentState is set when the driver created the 802.1q
+	// sub-interface used as parent, it is removed with the last network
+	// using it
+	createdParentState = "created_parent"
+)
+
+func init() {
+	if err := networks.Register(DriverName, &driver{}); err != nil {
+		panic(err)
+	}
+}
+
+type driver struct{}
+
+func (d *driver) Create(n *networks.Network) error {
+	parent := n.Options[parentOpt]
+	if parent == "" {
+		return fmt.Errorf("The ipvlan driver requires a parent interface, set it with -o %s=<interface>", parentOpt)
+	}
+	if mode := n.Options[modeOpt]; mode != "" && mode != modeL2 && mode != modeL3 {
+		return fmt.Errorf("Invalid ipvlan mode %s, it must be l2 or l3", mode)
+	}
+	created, err := networkdriver.SetupParentLink(parent)
+	if err != nil {
+		return err
+	}
+	if err := networkdriver.CheckParentMTU(parent, n.MTU()); err != nil {
+		networkdriver.ReleaseParentLink(parent, created)
+		return err
+	}
+	if created {
+		n.DriverState[createdParentState] = "true"
+	}
+	return nil
+}
+
+func (d *driver) Delete(n *networks.Network) error {
+	return networkdriver.ReleaseParentLink(n.Options[parentOpt], n.DriverState[createdParentState] == "true")
+}
+
+// SupportsMTU returns true, the ipvlan interfaces are given the mtu of their
+// network
+func (d *driver) SupportsMTU() bool {
+	return true
+}
+
+func (d *driver) Join(n *networks.Network, iface *execdriver.NetworkInterface) error {
+	iface.Type = DriverName
+	iface.Parent = n.Options[parentOpt]
+	iface.Mode = n.Options[modeOpt]
+	if iface.Mode == "" {
+		iface.Mode = modeL2
+	}
+	if iface.Mode == modeL3 {
+		// the default route goes through the interface itself
+		iface.Gateway = ""
+		iface.IPv6Gateway = ""
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package ipvlan
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/networks"
+)
+
+func newNetwork(options map[string]string) *networks.Network {
+	return &networks.Network{
+		Name:        "ipvlan",
+		Driver:      DriverName,
+		Options:     options,
+		DriverState: make(map[string]string),
+	}
+}
+
+func TestCreateInvalidOptions(t *testing.T) {
+	d := &driver{}
+	for _, c := range []struct {
+		options  map[string]string
+		expected string
+	}{
+		{map[string]string{}, "requires a parent interface"},
+		{map[string]string{"parent": "lo", "ipvlan_mode": "l4"}, "Invalid ipvlan mode l4"},
+		{map[string]string{"parent": "doesnotexist0"}, "does not exist"},
+		{map[string]string{"parent": "lo.0"}, "Invalid vlan id 0"},
+	} {
+		err := d.Create(newNetwork(c.options))
+		if err == nil || !strings.Contains(err.Error(), c.expected) {
+			t.Fatalf("Expected error containing %q for %v, got %v", c.expected, c.options, err)
+		}
+	}
+}
+
+func TestJoin(t *testing.T) {
+	d := &driver{}
+	iface := &execdriver.NetworkInterface{Gateway: "10.0.0.1"}
+	if err := d.Join(newNetwork(map[string]string{"parent": "eth0"}), iface); err != nil {
+		t.Fatal(err)
+	}
+	if iface.Type != "ipvlan" || iface.Parent != "eth0" || iface.Mode != "l2" || iface.Gateway != "10.0.0.1" {
+		t.Fatalf("Unexpected l2 interface %+v", iface)
+	}
+
+	iface = &execdriver.NetworkInterface{Gateway: "10.0.0.1", IPv6Gateway: "fd00::1"}
+	if err := d.Join(newNetwork(map[string]string{"parent": "eth0", "ipvlan_mode": "l3"}), iface); err != nil {
+		t.Fatal(err)
+	}
+	if iface.Mode != "l3" || iface.Gateway != "" || iface.IPv6Gateway != "" {
+		t.Fatalf("Expected no gateway in l3 mode, got %+v", iface)
+	}
+}
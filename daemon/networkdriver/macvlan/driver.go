@@ -0,0 +1,83 @@
+// Package macvlan implements the macvlan network driver. The containers of a
+// macvlan network get their own MAC address on the network of a parent host
+// interface, so they are reachable like any other host on it without NAT or
+// port mapping.
+package macvlan
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/daemon/networkdriver"
+	"github.com/docker/docker/networks"
+)
+
+const (
+	// DriverName is the name of the driver in docker network create -d
+	DriverName = "macvlan"
+
+	parentOpt = "parent"
+	modeOpt   = "macvlan_mode"
+
+	// createdParentState is set when the driver created the 802.1q
+	// sub-interface used as parent, it is removed with the last network
+	// using it
+	createdParentState = "created_parent"
+)
+
+var modes = map[string]bool{
+	"bridge":   true,
+	"private":  true,
+	"vepa":     true,
+	"passthru": true,
+}
+
+func init() {
+	if err := networks.Register(DriverName, &driver{}); err != nil {
+		panic(err)
+	}
+}
+
+type driver struct{}
+
+func (d *driver) Create(n *networks.Network) error {
+	parent := n.Options[parentOpt]
+	if parent == "" {
+		return fmt.Errorf("The macvlan driver requires a parent interface, set it with -o %s=<interface>", parentOpt)
+	}
+	if mode := n.Options[modeOpt]; mode != "" && !modes[mode] {
+		return fmt.Errorf("Invalid macvlan mode %s, it must be one of bridge, private, vepa or passthru", mode)
+	}
+	created, err := networkdriver.SetupParentLink(parent)
+	if err != nil {
+		return err
+	}
+	if err := networkdriver.CheckParentMTU(parent, n.MTU()); err != nil {
+		networkdriver.ReleaseParentLink(parent, created)
+		return err
+	}
+	if created {
+		n.DriverState[createdParentState] = "true"
+	}
+	return nil
+}
+
+func (d *driver) Delete(n *networks.Network) error {
+	return networkdriver.ReleaseParentLink(n.Options[parentOpt], n.DriverState[createdParentState] == "true")
+}
+
+// SupportsMTU returns true, the macvlan interfaces are given the mtu of their
+// network
+func (d *driver) SupportsMTU() bool {
+	return true
+}
+
+func (d *driver) Join(n *networks.Network, iface *execdriver.NetworkInterface) error {
+	iface.Type = DriverName
+	iface.Parent = n.Options[parentOpt]
+	iface.Mode = n.Options[modeOpt]
+	if iface.Mode == "" {
+		iface.Mode = "bridge"
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package macvlan
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/networks"
+)
+
+func newNetwork(options map[string]string) *networks.Network {
+	return &networks.Network{
+		Name:        "macvlan",
+		Driver:      DriverName,
+		Options:     options,
+		DriverState: make(map[string]string),
+	}
+}
+
+func TestCreateInvalidOptions(t *testing.T) {
+	d := &driver{}
+	for _, c := range []struct {
+		options  map[string]string
+		expected string
+	}{
+		{map[string]string{}, "requires a parent interface"},
+		{map[string]string{"parent": "lo", "macvlan_mode": "foo"}, "Invalid macvlan mode foo"},
+		{map[string]string{"parent": "doesnotexist0"}, "does not exist"},
+		{map[string]string{"parent": "lo.foo"}, "Invalid vlan id foo"},
+		{map[string]string{"parent": "lo.4095"}, "Invalid vlan id 4095"},
+		{map[string]string{"parent": "doesnotexist0.10"}, "Master interface doesnotexist0"},
+	} {
+		err := d.Create(newNetwork(c.options))
+		if err == nil || !strings.Contains(err.Error(), c.expected) {
+			t.Fatalf("Expected error containing %q for %v, got %v", c.expected, c.options, err)
+		}
+	}
+}
+
+func TestJoin(t *testing.T) {
+	iface := &execdriver.NetworkInterface{}
+	if err := (&driver{}).Join(newNetwork(map[string]string{"parent": "eth0"}), iface); err != nil {
+		t.Fatal(err)
+	}
+	if iface.Type != "macvlan" || iface.Parent != "eth0" || iface.Mode != "bridge" {
+		t.Fatalf("Unexpected interface %+v", iface)
+	}
+}
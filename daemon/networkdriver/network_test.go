@@ -173,3 +173,58 @@ func TestNetworkRange(t *testing.T) {
 		t.Error(last.String())
 	}
 }
+
+func TestMacAddrGeneration(t *testing.T) {
+	ip := net.ParseIP("192.168.0.1")
+	mac := GenerateMacAddr(ip).String()
+
+	// Should be consistent.
+	if GenerateMacAddr(ip).String() != mac {
+		t.Fatal("Inconsistent MAC address")
+	}
+
+	// Should be unique.
+	ip2 := net.ParseIP("192.168.0.2")
+	if GenerateMacAddr(ip2).String() == mac {
+		t.Fatal("Non-unique MAC address")
+	}
+}
+
+func TestParentLinkRefs(t *testing.T) {
+	refs := newParentLinkRefs()
+
+	// a network creates eth0.10, another one then uses it
+	refs.acquire("eth0.10")
+	refs.acquire("eth0.10")
+	if refs.release("eth0.10", true) {
+		t.Fatal("Expected the parent to be kept while another network uses it")
+	}
+	if !refs.release("eth0.10", false) {
+		t.Fatal("Expected the parent created by a network to be removed with the last one")
+	}
+
+	// a parent no network created is never removed
+	refs.acquire("eth1")
+	if refs.release("eth1", false) {
+		t.Fatal("Expected the existing parent to be kept")
+	}
+}
+
+func TestCheckParentMTU(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skip("no loopback interface")
+	}
+	if err := CheckParentMTU("lo", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckParentMTU("lo", lo.MTU); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckParentMTU("lo", lo.MTU+1); err == nil {
+		t.Fatal("Expected an mtu larger than the one of the parent to be refused")
+	}
+	if err := CheckParentMTU("nonexistent0", 1500); err == nil {
+		t.Fatal("Expected a missing parent to be refused")
+	}
+}
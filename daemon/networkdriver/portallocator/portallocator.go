@@ -10,12 +10,17 @@ import (
 type portMap struct {
 	p    map[int]struct{}
 	last int
+	// lastInRange is the last port allocated from each range of host ports,
+	// the search of the next one starts after it so that a port failing to
+	// bind is not tried again right away
+	lastInRange map[string]int
 }
 
 func newPortMap() *portMap {
 	return &portMap{
-		p:    map[int]struct{}{},
-		last: EndPortRange,
+		p:           map[int]struct{}{},
+		last:        endPortRange,
+		lastInRange: map[string]int{},
 	}
 }
 
@@ -45,6 +50,10 @@ var (
 
 	defaultIP = net.ParseIP("0.0.0.0")
 	globalMap = ipMapping{}
+
+	// the range ports are allocated from when no port is requested
+	beginPortRange = BeginPortRange
+	endPortRange   = EndPortRange
 )
 
 type ErrPortAlreadyAllocated struct {
@@ -75,10 +84,29 @@ func (e ErrPortAlreadyAllocated) Error() string {
 	return fmt.Sprintf("Bind for %s:%d failed: port is already allocated", e.ip, e.port)
 }
 
+// SetPortRange sets the range ports are allocated from when no port is
+// requested, from BeginPortRange to EndPortRange by default.
+func SetPortRange(begin, end int) error {
+	if begin <= 0 || end > 65535 || begin > end {
+		return fmt.Errorf("Invalid port range %d-%d", begin, end)
+	}
+	mutex.Lock()
+	beginPortRange, endPortRange = begin, end
+	mutex.Unlock()
+	return nil
+}
+
 // RequestPort requests new port from global ports pool for specified ip and proto.
 // If port is 0 it returns first free port. Otherwise it cheks port availability
 // in pool and return that port or error if port is already busy.
 func RequestPort(ip net.IP, proto string, port int) (int, error) {
+	return RequestPortInRange(ip, proto, port, port)
+}
+
+// RequestPortInRange requests the first free port between start and end
+// from global ports pool for specified ip and proto. If start and end are 0
+// it returns a free port of the range set by SetPortRange.
+func RequestPortInRange(ip net.IP, proto string, start, end int) (int, error) {
 	mutex.Lock()
 	defer mutex.Unlock()
 
@@ -96,12 +124,18 @@ func RequestPort(ip net.IP, proto string, port int) (int, error) {
 		globalMap[ipstr] = protomap
 	}
 	mapping := protomap[proto]
-	if port > 0 {
-		if _, ok := mapping.p[port]; !ok {
-			mapping.p[port] = struct{}{}
-			return port, nil
+	if start > 0 && start == end {
+		if _, ok := mapping.p[start]; !ok {
+			mapping.p[start] = struct{}{}
+			return start, nil
 		}
-		return 0, NewErrPortAlreadyAllocated(ipstr, port)
+		return 0, NewErrPortAlreadyAllocated(ipstr, start)
+	}
+	if start > 0 || end > 0 {
+		if start <= 0 || end > 65535 || start > end {
+			return 0, fmt.Errorf("Invalid port range %d-%d", start, end)
+		}
+		return mapping.findPortInRange(start, end)
 	}
 
 	port, err := mapping.findPort()
@@ -137,10 +171,10 @@ func ReleaseAll() error {
 
 func (pm *portMap) findPort() (int, error) {
 	port := pm.last
-	for i := 0; i <= EndPortRange-BeginPortRange; i++ {
+	for i := 0; i <= endPortRange-beginPortRange; i++ {
 		port++
-		if port > EndPortRange {
-			port = BeginPortRange
+		if port > endPortRange || port < beginPortRange {
+			port = beginPortRange
 		}
 
 		if _, ok := pm.p[port]; !ok {
@@ -151,3 +185,24 @@ func (pm *portMap) findPort() (int, error) {
 	}
 	return 0, ErrAllPortsAllocated
 }
+
+func (pm *portMap) findPortInRange(begin, end int) (int, error) {
+	key := fmt.Sprintf("%d-%d", begin, end)
+	port, ok := pm.lastInRange[key]
+	if !ok {
+		port = end
+	}
+	for i := 0; i <= end-begin; i++ {
+		port++
+		if port > end || port < begin {
+			port = begin
+		}
+
+		if _, ok := pm.p[port]; !ok {
+			pm.p[port] = struct{}{}
+			pm.lastInRange[key] = port
+			return port, nil
+		}
+	}
+	return 0, ErrAllPortsAllocated
+}
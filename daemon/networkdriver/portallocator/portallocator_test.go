@@ -243,3 +243,79 @@ func TestNoDuplicateBPR(t *testing.T) {
 		t.Fatalf("Acquire(0) allocated the same port twice: %d", port)
 	}
 }
+
+func TestRequestPortInRange(t *testing.T) {
+	defer reset()
+
+	port, err := RequestPortInRange(defaultIP, "tcp", 8000, 8001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port != 8000 {
+		t.Fatalf("Expected port 8000 got %d", port)
+	}
+	if port, err = RequestPortInRange(defaultIP, "tcp", 8000, 8001); err != nil {
+		t.Fatal(err)
+	} else if port != 8001 {
+		t.Fatalf("Expected port 8001 got %d", port)
+	}
+	if _, err := RequestPortInRange(defaultIP, "tcp", 8000, 8001); err != ErrAllPortsAllocated {
+		t.Fatalf("Expected error %s got %v", ErrAllPortsAllocated, err)
+	}
+	if _, err := RequestPortInRange(defaultIP, "tcp", 8001, 8000); err == nil {
+		t.Fatal("Expected an error for an invalid range")
+	}
+}
+
+func TestRequestPortInRangeResumes(t *testing.T) {
+	defer reset()
+
+	// a port released after failing to bind is not tried again first
+	port, err := RequestPortInRange(defaultIP, "tcp", 8000, 8002)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ReleasePort(defaultIP, "tcp", port); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []int{8001, 8002, 8000} {
+		if port, err = RequestPortInRange(defaultIP, "tcp", 8000, 8002); err != nil {
+			t.Fatal(err)
+		} else if port != expected {
+			t.Fatalf("Expected port %d got %d", expected, port)
+		}
+	}
+}
+
+func TestSetPortRange(t *testing.T) {
+	defer func() {
+		SetPortRange(BeginPortRange, EndPortRange)
+		reset()
+	}()
+
+	if err := SetPortRange(20000, 20001); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []int{20000, 20001} {
+		port, err := RequestPort(defaultIP, "tcp", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if port != expected {
+			t.Fatalf("Expected port %d got %d", expected, port)
+		}
+	}
+	if _, err := RequestPort(defaultIP, "tcp", 0); err != ErrAllPortsAllocated {
+		t.Fatalf("Expected error %s got %v", ErrAllPortsAllocated, err)
+	}
+	// ports outside of the range can still be requested
+	if port, err := RequestPort(defaultIP, "tcp", 5000); err != nil || port != 5000 {
+		t.Fatalf("Expected port 5000 got %d: %v", port, err)
+	}
+
+	for _, r := range [][2]int{{0, 100}, {100, 99}, {60000, 70000}} {
+		if err := SetPortRange(r[0], r[1]); err == nil {
+			t.Fatalf("Expected an error for range %d-%d", r[0], r[1])
+		}
+	}
+}
@@ -16,16 +16,24 @@ type mapping struct {
 	userlandProxy UserlandProxy
 	host          net.Addr
 	container     net.Addr
+	// hairpin is set when the mapping has no userland proxy, the
+	// containers of the bridge reach it through the hairpin NAT rules
+	hairpin bool
 }
 
 var (
 	chain *iptables.Chain
 	lock  sync.Mutex
 
+	// hairpinSubnet is the subnet of the bridge, its traffic to the
+	// mappings without a userland proxy is masqueraded
+	hairpinSubnet *net.IPNet
+
 	// udp:ip:port
 	currentMappings = make(map[string]*mapping)
 
-	NewProxy = NewProxyCommand
+	NewProxy      = NewProxyCommand
+	NewDummyProxy = NewDummyProxyCommand
 )
 
 var (
@@ -38,7 +46,21 @@ func SetIptablesChain(c *iptables.Chain) {
 	chain = c
 }
 
+// SetHairpinSubnet sets the subnet of the containers reaching the mappings
+// without a userland proxy through the host
+func SetHairpinSubnet(subnet *net.IPNet) {
+	hairpinSubnet = &net.IPNet{IP: subnet.IP.Mask(subnet.Mask), Mask: subnet.Mask}
+}
+
 func Map(container net.Addr, hostIP net.IP, hostPort int) (host net.Addr, err error) {
+	return MapRange(container, hostIP, hostPort, hostPort, true)
+}
+
+// MapRange maps container to the first free host port between
+// hostPortStart and hostPortEnd, or to a port of the dynamic range when
+// they are 0. Without the userland proxy the traffic is only forwarded by
+// iptables, including the hairpin traffic of the containers of the bridge.
+func MapRange(container net.Addr, hostIP net.IP, hostPortStart, hostPortEnd int, useProxy bool) (host net.Addr, err error) {
 	lock.Lock()
 	defer lock.Unlock()
 
@@ -47,12 +69,16 @@ func Map(container net.Addr, hostIP net.IP, hostPort int) (host net.Addr, err er
 		proto             string
 		allocatedHostPort int
 		proxy             UserlandProxy
+		newProxy          = NewProxy
 	)
+	if !useProxy {
+		newProxy = NewDummyProxy
+	}
 
 	switch container.(type) {
 	case *net.TCPAddr:
 		proto = "tcp"
-		if allocatedHostPort, err = portallocator.RequestPort(hostIP, proto, hostPort); err != nil {
+		if allocatedHostPort, err = portallocator.RequestPortInRange(hostIP, proto, hostPortStart, hostPortEnd); err != nil {
 			return nil, err
 		}
 
@@ -62,10 +88,10 @@ func Map(container net.Addr, hostIP net.IP, hostPort int) (host net.Addr, err er
 			container: container,
 		}
 
-		proxy = NewProxy(proto, hostIP, allocatedHostPort, container.(*net.TCPAddr).IP, container.(*net.TCPAddr).Port)
+		proxy = newProxy(proto, hostIP, allocatedHostPort, container.(*net.TCPAddr).IP, container.(*net.TCPAddr).Port)
 	case *net.UDPAddr:
 		proto = "udp"
-		if allocatedHostPort, err = portallocator.RequestPort(hostIP, proto, hostPort); err != nil {
+		if allocatedHostPort, err = portallocator.RequestPortInRange(hostIP, proto, hostPortStart, hostPortEnd); err != nil {
 			return nil, err
 		}
 
@@ -75,7 +101,7 @@ func Map(container net.Addr, hostIP net.IP, hostPort int) (host net.Addr, err er
 			container: container,
 		}
 
-		proxy = NewProxy(proto, hostIP, allocatedHostPort, container.(*net.UDPAddr).IP, container.(*net.UDPAddr).Port)
+		proxy = newProxy(proto, hostIP, allocatedHostPort, container.(*net.UDPAddr).IP, container.(*net.UDPAddr).Port)
 	default:
 		return nil, ErrUnknownBackendAddressType
 	}
@@ -91,16 +117,17 @@ func Map(container net.Addr, hostIP net.IP, hostPort int) (host net.Addr, err er
 	if _, exists := currentMappings[key]; exists {
 		return nil, ErrPortMappedForIP
 	}
+	m.hairpin = !useProxy
 
 	containerIP, containerPort := getIPAndPort(m.container)
-	if err := forward(iptables.Append, m.proto, hostIP, allocatedHostPort, containerIP.String(), containerPort); err != nil {
+	if err := forward(iptables.Append, m.proto, hostIP, allocatedHostPort, containerIP.String(), containerPort, m.hairpin); err != nil {
 		return nil, err
 	}
 
 	cleanup := func() error {
 		// need to undo the iptables rules before we return
 		proxy.Stop()
-		forward(iptables.Delete, m.proto, hostIP, allocatedHostPort, containerIP.String(), containerPort)
+		forward(iptables.Delete, m.proto, hostIP, allocatedHostPort, containerIP.String(), containerPort, m.hairpin)
 		if err := portallocator.ReleasePort(hostIP, m.proto, allocatedHostPort); err != nil {
 			return err
 		}
@@ -135,7 +162,7 @@ func Unmap(host net.Addr) error {
 
 	containerIP, containerPort := getIPAndPort(data.container)
 	hostIP, hostPort := getIPAndPort(data.host)
-	if err := forward(iptables.Delete, data.proto, hostIP, hostPort, containerIP.String(), containerPort); err != nil {
+	if err := forward(iptables.Delete, data.proto, hostIP, hostPort, containerIP.String(), containerPort, data.hairpin); err != nil {
 		log.Errorf("Error on iptables delete: %s", err)
 	}
 
@@ -168,9 +195,21 @@ func getIPAndPort(a net.Addr) (net.IP, int) {
 	return nil, 0
 }
 
-func forward(action iptables.Action, proto string, sourceIP net.IP, sourcePort int, containerIP string, containerPort int) error {
+func forward(action iptables.Action, proto string, sourceIP net.IP, sourcePort int, containerIP string, containerPort int, hairpin bool) error {
 	if chain == nil {
 		return nil
 	}
-	return chain.Forward(action, sourceIP, sourcePort, proto, containerIP, containerPort)
+	if err := chain.Forward(action, sourceIP, sourcePort, proto, containerIP, containerPort); err != nil {
+		return err
+	}
+	if !hairpin || hairpinSubnet == nil {
+		return nil
+	}
+	if err := chain.Hairpin(action, sourceIP, sourcePort, proto, hairpinSubnet, containerIP, containerPort); err != nil {
+		if action == iptables.Append {
+			chain.Forward(iptables.Delete, sourceIP, sourcePort, proto, containerIP, containerPort)
+		}
+		return err
+	}
+	return nil
 }
@@ -11,6 +11,7 @@ import (
 func init() {
 	// override this func to mock out the proxy server
 	NewProxy = NewMockProxyCommand
+	NewDummyProxy = NewMockProxyCommand
 }
 
 func reset() {
@@ -150,3 +151,25 @@ func TestMapAllPortsSingleInterface(t *testing.T) {
 		hosts = []net.Addr{}
 	}
 }
+
+func TestMapRangeWithoutProxy(t *testing.T) {
+	defer reset()
+	_, subnet, _ := net.ParseCIDR("172.16.0.1/16")
+	SetHairpinSubnet(subnet)
+	if hairpinSubnet.String() != "172.16.0.0/16" {
+		t.Fatalf("Expected the hairpin subnet 172.16.0.0/16, got %s", hairpinSubnet)
+	}
+
+	hostIP := net.ParseIP("192.168.0.3")
+	srcAddr := &net.TCPAddr{Port: 80, IP: net.ParseIP("172.16.0.3")}
+	host, err := MapRange(srcAddr, hostIP, 8080, 8080, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := currentMappings[getKey(host)]; m == nil || !m.hairpin {
+		t.Fatalf("Expected a hairpin mapping for %s, got %+v", host, m)
+	}
+	if err := Unmap(host); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,6 +3,7 @@ package portmapper
 import (
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net"
@@ -159,3 +160,46 @@ func (p *proxyCommand) Stop() error {
 	}
 	return nil
 }
+
+// dummyProxy holds the host port of a mapping without a userland proxy so
+// that no other process can bind it, the traffic is only forwarded by the
+// iptables rules
+type dummyProxy struct {
+	addr     net.Addr
+	listener io.Closer
+}
+
+func NewDummyProxyCommand(proto string, hostIP net.IP, hostPort int, containerIP net.IP, containerPort int) UserlandProxy {
+	switch proto {
+	case "tcp":
+		return &dummyProxy{addr: &net.TCPAddr{IP: hostIP, Port: hostPort}}
+	case "udp":
+		return &dummyProxy{addr: &net.UDPAddr{IP: hostIP, Port: hostPort}}
+	}
+	return &dummyProxy{}
+}
+
+func (p *dummyProxy) Start() error {
+	switch addr := p.addr.(type) {
+	case *net.TCPAddr:
+		l, err := net.ListenTCP("tcp", addr)
+		if err != nil {
+			return err
+		}
+		p.listener = l
+	case *net.UDPAddr:
+		l, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			return err
+		}
+		p.listener = l
+	}
+	return nil
+}
+
+func (p *dummyProxy) Stop() error {
+	if p.listener != nil {
+		return p.listener.Close()
+	}
+	return nil
+}
@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/docker/libcontainer/netlink"
 )
@@ -116,3 +119,148 @@ func GetDefaultRouteIface() (*net.Interface, error) {
 	}
 	return nil, ErrNoDefaultRoute
 }
+
+// Generate a IEEE802 compliant MAC address from the given IP address.
+//
+// The generator is guaranteed to be consistent: the same IP will always yield the same
+// MAC address. This is to avoid ARP cache issues.
+func GenerateMacAddr(ip net.IP) net.HardwareAddr {
+	hw := make(net.HardwareAddr, 6)
+
+	// The first byte of the MAC address has to comply with these rules:
+	// 1. Unicast: Set the least-significant bit to 0.
+	// 2. Address is locally administered: Set the second-least-significant bit (U/L) to 1.
+	// 3. As "small" as possible: The veth address has to be "smaller" than the bridge address.
+	hw[0] = 0x02
+
+	// The first 24 bits of the MAC represent the Organizationally Unique Identifier (OUI).
+	// Since this address is locally administered, we can do whatever we want as long as
+	// it doesn't conflict with other addresses.
+	hw[1] = 0x42
+
+	// Insert the IP address into the last 32 bits of the MAC address.
+	// This is a simple way to guarantee the address will be consistent and unique.
+	copy(hw[2:], ip.To4())
+
+	return hw
+}
+
+// parentLinks tracks the parent interfaces of the macvlan and ipvlan
+// networks, a parent shared by several networks is only removed with the last
+// of them
+var parentLinks = newParentLinkRefs()
+
+type parentLinkRef struct {
+	refs    int
+	created bool // the interface was created by a network
+}
+
+type parentLinkRefs struct {
+	sync.Mutex
+	links map[string]*parentLinkRef
+}
+
+func newParentLinkRefs() *parentLinkRefs {
+	return &parentLinkRefs{links: make(map[string]*parentLinkRef)}
+}
+
+// acquire counts a network using the parent interface
+func (r *parentLinkRefs) acquire(parent string) {
+	link, ok := r.links[parent]
+	if !ok {
+		link = &parentLinkRef{}
+		r.links[parent] = link
+	}
+	link.refs++
+}
+
+// release uncounts a network using the parent interface, created is true when
+// the network created it. It returns whether the interface is to be removed,
+// when no network uses it anymore and a network created it.
+func (r *parentLinkRefs) release(parent string, created bool) bool {
+	link, ok := r.links[parent]
+	if !ok {
+		return created
+	}
+	link.created = link.created || created
+	if link.refs--; link.refs > 0 {
+		return false
+	}
+	delete(r.links, parent)
+	return link.created
+}
+
+// SetupParentLink brings up the parent interface of a macvlan or ipvlan
+// network. When it does not exist, parent is created as the 802.1q
+// sub-interface named after its master interface and vlan id, as in
+// eth0.10, and created is true. Every call must be paired with a call to
+// ReleaseParentLink when the network is removed.
+func SetupParentLink(parent string) (created bool, err error) {
+	parentLinks.Lock()
+	defer parentLinks.Unlock()
+	created, err = setupParentLink(parent)
+	if err == nil {
+		parentLinks.acquire(parent)
+	}
+	return created, err
+}
+
+func setupParentLink(parent string) (created bool, err error) {
+	if iface, err := net.InterfaceByName(parent); err == nil {
+		return false, netlink.NetworkLinkUp(iface)
+	}
+	i := strings.LastIndex(parent, ".")
+	if i < 0 {
+		return false, fmt.Errorf("Parent interface %s does not exist", parent)
+	}
+	master, vlan := parent[:i], parent[i+1:]
+	vlanID, err := strconv.ParseUint(vlan, 10, 16)
+	if err != nil || vlanID < 1 || vlanID > 4094 {
+		return false, fmt.Errorf("Invalid vlan id %s in parent interface %s, it must be between 1 and 4094", vlan, parent)
+	}
+	if _, err := net.InterfaceByName(master); err != nil {
+		return false, fmt.Errorf("Master interface %s of parent interface %s does not exist", master, parent)
+	}
+	if err := netlink.NetworkLinkAddVlan(master, parent, uint16(vlanID)); err != nil {
+		return false, fmt.Errorf("Failed to create vlan interface %s: %v", parent, err)
+	}
+	iface, err := net.InterfaceByName(parent)
+	if err != nil {
+		return true, err
+	}
+	return true, netlink.NetworkLinkUp(iface)
+}
+
+// CheckParentMTU returns an error when mtu is larger than the mtu of the
+// parent interface, a macvlan or ipvlan interface cannot exceed it. An mtu
+// of 0 uses the mtu of the daemon and is not checked.
+func CheckParentMTU(parent string, mtu int) error {
+	if mtu == 0 {
+		return nil
+	}
+	iface, err := net.InterfaceByName(parent)
+	if err != nil {
+		return fmt.Errorf("Parent interface %s does not exist", parent)
+	}
+	if mtu > iface.MTU {
+		return fmt.Errorf("Mtu %d is larger than the mtu %d of the parent interface %s", mtu, iface.MTU, parent)
+	}
+	return nil
+}
+
+// ReleaseParentLink is called when a network using the parent interface is
+// removed, created is true when SetupParentLink created the interface for
+// the network. The interface is removed once no network uses it, if one of
+// them created it.
+func ReleaseParentLink(parent string, created bool) error {
+	parentLinks.Lock()
+	defer parentLinks.Unlock()
+	if !parentLinks.release(parent, created) {
+		return nil
+	}
+	if _, err := net.InterfaceByName(parent); err != nil {
+		// already gone
+		return nil
+	}
+	return netlink.NetworkLinkDel(parent)
+}
@@ -5,8 +5,10 @@ import (
 	"os"
 	"strings"
 
+	"github.com/docker/docker/daemon/execdriver/lxc"
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/runconfig"
+	"github.com/docker/docker/volumes"
 )
 
 func (daemon *Daemon) ContainerStart(job *engine.Job) engine.Status {
@@ -50,9 +52,17 @@ func (daemon *Daemon) ContainerStart(job *engine.Job) engine.Status {
 func (daemon *Daemon) setHostConfig(container *Container, hostConfig *runconfig.HostConfig) error {
 	container.Lock()
 	defer container.Unlock()
+	// the options of the container override the default of the daemon
+	container.NoNewPrivileges = daemon.config.NoNewPrivileges
 	if err := parseSecurityOpt(container, hostConfig); err != nil {
 		return err
 	}
+	if container.NoNewPrivileges && strings.HasPrefix(daemon.execDriver.Name(), lxc.DriverName) {
+		return fmt.Errorf("The lxc exec driver does not support --security-opt no-new-privileges")
+	}
+	if err := checkAppArmorProfile(container.AppArmorProfile); err != nil {
+		return err
+	}
 
 	// FIXME: this should be handled by the volume subsystem
 	// Validate the HostConfig binds. Make sure that:
@@ -60,6 +70,9 @@ func (daemon *Daemon) setHostConfig(container *Container, hostConfig *runconfig.
 	for _, bind := range hostConfig.Binds {
 		splitBind := strings.Split(bind, ":")
 		source := splitBind[0]
+		if volumes.IsNamed(source) {
+			continue
+		}
 
 		// ensure the source exists on the host
 		_, err := os.Stat(source)
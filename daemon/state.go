@@ -20,12 +20,16 @@ type State struct {
 	Error      string // contains last known error when starting the container
 	StartedAt  time.Time
 	FinishedAt time.Time
+	Health     *Health `json:",omitempty"`
 	waitChan   chan struct{}
+	removeChan chan struct{}
+	removed    bool
 }
 
 func NewState() *State {
 	return &State{
-		waitChan: make(chan struct{}),
+		waitChan:   make(chan struct{}),
+		removeChan: make(chan struct{}),
 	}
 }
 
@@ -39,7 +43,7 @@ func (s *State) String() string {
 			return fmt.Sprintf("Restarting (%d) %s ago", s.ExitCode, units.HumanDuration(time.Now().UTC().Sub(s.FinishedAt)))
 		}
 
-		return fmt.Sprintf("Up %s", units.HumanDuration(time.Now().UTC().Sub(s.StartedAt)))
+		return fmt.Sprintf("Up %s%s", units.HumanDuration(time.Now().UTC().Sub(s.StartedAt)), s.healthSummary())
 	}
 
 	if s.FinishedAt.IsZero() {
@@ -112,6 +116,54 @@ func (s *State) WaitStop(timeout time.Duration) (int, error) {
 	return s.GetExitCode(), nil
 }
 
+// WaitNextExit waits for the next time the container stops, even if it is not
+// running yet, or until it is removed. If you want wait forever you must supply
+// negative timeout. Returns exit code, that was passed to SetStopped
+func (s *State) WaitNextExit(timeout time.Duration) (int, error) {
+	var deadline time.Time
+	if timeout >= 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	s.Lock()
+	if s.removed {
+		exitCode := s.ExitCode
+		s.Unlock()
+		return exitCode, nil
+	}
+	waitChan := s.waitChan
+	s.Unlock()
+	for {
+		if timeout >= 0 {
+			timeout = deadline.Sub(time.Now())
+			if timeout < 0 {
+				timeout = 0
+			}
+		}
+		if err := wait(waitChan, timeout); err != nil {
+			return -1, err
+		}
+		s.Lock()
+		// starts and restarts fire the same channel, only an actual stop counts
+		if !s.Running || s.removed {
+			exitCode := s.ExitCode
+			s.Unlock()
+			return exitCode, nil
+		}
+		waitChan = s.waitChan
+		s.Unlock()
+	}
+}
+
+// WaitRemoved waits until the container is removed. If it is not running
+// anymore it returns the last exit code.
+func (s *State) WaitRemoved() int {
+	s.Lock()
+	removeChan := s.removeChan
+	s.Unlock()
+	<-removeChan
+	return s.GetExitCode()
+}
+
 func (s *State) IsRunning() bool {
 	s.Lock()
 	res := s.Running
@@ -185,6 +237,19 @@ func (s *State) SetRestarting(exitStatus *execdriver.ExitStatus) {
 	s.Unlock()
 }
 
+// SetRemoved marks the container as removed and fires the waiters for its
+// removal, as well as the ones still waiting for it to stop.
+func (s *State) SetRemoved() {
+	s.Lock()
+	if !s.removed {
+		s.removed = true
+		close(s.removeChan)
+		close(s.waitChan)
+		s.waitChan = make(chan struct{})
+	}
+	s.Unlock()
+}
+
 // setError sets the container's error state. This is useful when we want to
 // know the error that occurred when container transits to another state
 // when inspecting it
@@ -102,3 +102,60 @@ func TestStateTimeoutWait(t *testing.T) {
 	}
 
 }
+
+func TestStateWaitNextExit(t *testing.T) {
+	s := NewState()
+	stopped := make(chan struct{})
+	var exit int64
+	go func() {
+		exitCode, _ := s.WaitNextExit(-1 * time.Second)
+		atomic.StoreInt64(&exit, int64(exitCode))
+		close(stopped)
+	}()
+	s.SetRunning(42)
+	select {
+	case <-time.After(100 * time.Millisecond):
+	case <-stopped:
+		t.Fatal("WaitNextExit returned when the container started")
+	}
+	s.SetStopped(&execdriver.ExitStatus{ExitCode: 3})
+	select {
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("WaitNextExit doesn't fire in 100 milliseconds")
+	case <-stopped:
+	}
+	if exitCode := int(atomic.LoadInt64(&exit)); exitCode != 3 {
+		t.Fatalf("ExitCode %v, expected 3", exitCode)
+	}
+	if _, err := s.WaitNextExit(100 * time.Millisecond); err == nil {
+		t.Fatal("WaitNextExit should time out on a stopped container")
+	}
+}
+
+func TestStateWaitRemoved(t *testing.T) {
+	s := NewState()
+	s.SetRunning(42)
+	s.SetStopped(&execdriver.ExitStatus{ExitCode: 2})
+	removed := make(chan int, 1)
+	go func() {
+		removed <- s.WaitRemoved()
+	}()
+	select {
+	case <-time.After(100 * time.Millisecond):
+	case <-removed:
+		t.Fatal("WaitRemoved returned before the container was removed")
+	}
+	s.SetRemoved()
+	s.SetRemoved()
+	select {
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("WaitRemoved doesn't fire in 100 milliseconds")
+	case exitCode := <-removed:
+		if exitCode != 2 {
+			t.Fatalf("ExitCode %v, expected 2", exitCode)
+		}
+	}
+	if exitCode, err := s.WaitNextExit(100 * time.Millisecond); err != nil || exitCode != 2 {
+		t.Fatalf("WaitNextExit returned exitCode: %v, err: %v, expected exitCode: 2", exitCode, err)
+	}
+}
@@ -1,51 +1,178 @@
 package daemon
 
 import (
+	"bufio"
 	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/daemon/execdriver"
 	"github.com/docker/docker/engine"
 	"github.com/docker/libcontainer"
 	"github.com/docker/libcontainer/cgroups"
+	"github.com/docker/libcontainer/network"
 )
 
+// oneShotStatsInterval is the time between the two samples taken to
+// compute the cpu usage of a one-shot stats request
+const oneShotStatsInterval = 100 * time.Millisecond
+
 func (daemon *Daemon) ContainerStats(job *engine.Job) engine.Status {
+	stream := true
+	if job.EnvExists("stream") {
+		stream = job.GetenvBool("stream")
+	}
+	if !stream {
+		return daemon.containerStatsOnce(job)
+	}
+
+	container, err := daemon.Get(job.Args[0])
+	if err != nil {
+		return job.Error(err)
+	}
 	updates, err := daemon.SubscribeToContainerStats(job.Args[0])
 	if err != nil {
 		return job.Error(err)
 	}
-	enc := json.NewEncoder(job.Stdout)
+	var (
+		enc         = json.NewEncoder(job.Stdout)
+		preCpuStats types.CpuStats
+	)
 	for v := range updates {
-		update := v.(*execdriver.ResourceStats)
-		ss := convertToAPITypes(update.ContainerStats)
-		ss.MemoryStats.Limit = uint64(update.MemoryLimit)
-		ss.Read = update.Read
-		ss.CpuStats.SystemUsage = update.SystemUsage
+		ss := convertStatsToAPITypes(v.(*execdriver.ResourceStats))
+		ss.ID, ss.Name = container.ID, strings.TrimPrefix(container.Name, "/")
+		ss.PreCpuStats = preCpuStats
 		if err := enc.Encode(ss); err != nil {
 			// TODO: handle the specific broken pipe
 			daemon.UnsubscribeToContainerStats(job.Args[0], updates)
 			return job.Error(err)
 		}
+		preCpuStats = ss.CpuStats
+	}
+	return engine.StatusOK
+}
+
+// containerStatsOnce writes a single sample of the container's stats,
+// sampled directly instead of waiting for the stats collector.
+func (daemon *Daemon) containerStatsOnce(job *engine.Job) engine.Status {
+	container, err := daemon.Get(job.Args[0])
+	if err != nil {
+		return job.Error(err)
+	}
+	pre, err := daemon.sampleStats(container)
+	if err != nil {
+		return job.Error(err)
+	}
+	time.Sleep(oneShotStatsInterval)
+	ss, err := daemon.sampleStats(container)
+	if err != nil {
+		return job.Error(err)
+	}
+	ss.PreCpuStats = pre.CpuStats
+	if err := json.NewEncoder(job.Stdout).Encode(ss); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+// ContainersStats writes a single sample of the resource usage of each of
+// the containers, or of all the running containers when none is given, with
+// their usage percentages computed. The containers which are not running
+// are left out. The containers are sampled together, so
+// the request takes the same time for any number of containers.
+func (daemon *Daemon) ContainersStats(job *engine.Job) engine.Status {
+	var containers []*Container
+	if len(job.Args) == 0 {
+		for _, container := range daemon.List() {
+			if container.IsRunning() {
+				containers = append(containers, container)
+			}
+		}
+	}
+	for _, name := range job.Args {
+		container, err := daemon.Get(name)
+		if err != nil {
+			return job.Error(err)
+		}
+		containers = append(containers, container)
+	}
+
+	// the containers which stop between the samples are left out
+	var (
+		sampled []*Container
+		pre     []*types.Stats
+	)
+	for _, container := range containers {
+		ss, err := daemon.sampleStats(container)
+		if err != nil {
+			if !container.IsRunning() {
+				continue
+			}
+			return job.Errorf("%s: %v", strings.TrimPrefix(container.Name, "/"), err)
+		}
+		sampled = append(sampled, container)
+		pre = append(pre, ss)
+	}
+	time.Sleep(oneShotStatsInterval)
+	samples := []*types.StatsSample{}
+	for i, container := range sampled {
+		ss, err := daemon.sampleStats(container)
+		if err != nil {
+			if !container.IsRunning() {
+				continue
+			}
+			return job.Errorf("%s: %v", strings.TrimPrefix(container.Name, "/"), err)
+		}
+		ss.PreCpuStats = pre[i].CpuStats
+		samples = append(samples, types.NewStatsSample(ss))
+	}
+	if err := json.NewEncoder(job.Stdout).Encode(samples); err != nil {
+		return job.Error(err)
 	}
 	return engine.StatusOK
 }
 
+// sampleStats samples the stats of the container directly, instead of
+// waiting for the stats collector
+func (daemon *Daemon) sampleStats(container *Container) (*types.Stats, error) {
+	systemUsage, err := daemon.statsCollector.getSystemCpuUsage()
+	if err != nil {
+		return nil, err
+	}
+	stats, err := container.Stats()
+	if err != nil {
+		return nil, err
+	}
+	stats.SystemUsage = systemUsage
+	ss := convertStatsToAPITypes(stats)
+	ss.ID, ss.Name = container.ID, strings.TrimPrefix(container.Name, "/")
+	return ss, nil
+}
+
+func convertStatsToAPITypes(update *execdriver.ResourceStats) *types.Stats {
+	ss := convertToAPITypes(update.ContainerStats)
+	ss.MemoryStats.Limit = uint64(update.MemoryLimit)
+	ss.Read = update.Read
+	ss.CpuStats.SystemUsage = update.SystemUsage
+	if len(update.Interfaces) > 0 {
+		ss.Networks = make(map[string]types.Network, len(update.Interfaces))
+		for name, ns := range update.Interfaces {
+			ss.Networks[name] = copyNetworkStats(ns)
+		}
+	}
+	return ss
+}
+
 // convertToAPITypes converts the libcontainer.ContainerStats to the api specific
 // structs.  This is done to preserve API compatibility and versioning.
 func convertToAPITypes(ls *libcontainer.ContainerStats) *types.Stats {
 	s := &types.Stats{}
 	if ls.NetworkStats != nil {
-		s.Network = types.Network{
-			RxBytes:   ls.NetworkStats.RxBytes,
-			RxPackets: ls.NetworkStats.RxPackets,
-			RxErrors:  ls.NetworkStats.RxErrors,
-			RxDropped: ls.NetworkStats.RxDropped,
-			TxBytes:   ls.NetworkStats.TxBytes,
-			TxPackets: ls.NetworkStats.TxPackets,
-			TxErrors:  ls.NetworkStats.TxErrors,
-			TxDropped: ls.NetworkStats.TxDropped,
-		}
+		s.Network = copyNetworkStats(ls.NetworkStats)
 	}
 	cs := ls.CgroupStats
 	if cs != nil {
@@ -80,6 +207,10 @@ func convertToAPITypes(ls *libcontainer.ContainerStats) *types.Stats {
 			Stats:    mem.Stats,
 			Failcnt:  mem.Failcnt,
 		}
+		s.PidsStats = types.PidsStats{
+			Current: cs.PidsStats.Current,
+			Limit:   cs.PidsStats.Limit,
+		}
 	}
 	return s
 }
@@ -96,3 +227,64 @@ func copyBlkioEntry(entries []cgroups.BlkioStatEntry) []types.BlkioStatEntry {
 	}
 	return out
 }
+
+func copyNetworkStats(ns *network.NetworkStats) types.Network {
+	return types.Network{
+		RxBytes:   ns.RxBytes,
+		RxPackets: ns.RxPackets,
+		RxErrors:  ns.RxErrors,
+		RxDropped: ns.RxDropped,
+		TxBytes:   ns.TxBytes,
+		TxPackets: ns.TxPackets,
+		TxErrors:  ns.TxErrors,
+		TxDropped: ns.TxDropped,
+	}
+}
+
+// getInterfaceStats parses a /proc/<pid>/net/dev file, which lists the
+// interfaces of the network namespace of the process. The loopback
+// interface is skipped.
+func getInterfaceStats(path string) (map[string]*network.NetworkStats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	interfaces := make(map[string]*network.NetworkStats)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		parts := strings.SplitN(sc.Text(), ":", 2)
+		if len(parts) != 2 {
+			// header lines
+			continue
+		}
+		name := strings.TrimSpace(parts[0])
+		if name == "lo" {
+			continue
+		}
+		fields := strings.Fields(parts[1])
+		if len(fields) < 16 {
+			return nil, fmt.Errorf("invalid number of fields for interface %s in %s", name, path)
+		}
+		var values [16]uint64
+		for i := range values {
+			v, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for interface %s in %s: %v", name, path, err)
+			}
+			values[i] = v
+		}
+		interfaces[name] = &network.NetworkStats{
+			RxBytes:   values[0],
+			RxPackets: values[1],
+			RxErrors:  values[2],
+			RxDropped: values[3],
+			TxBytes:   values[8],
+			TxPackets: values[9],
+			TxErrors:  values[10],
+			TxDropped: values[11],
+		}
+	}
+	return interfaces, sc.Err()
+}
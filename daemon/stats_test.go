@@ -0,0 +1,47 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+const netDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:     672       8    0    0    0     0          0         0      672       8    0    0    0     0       0          0
+  eth0:    1296      16    1    2    0     0          0         0      648       8    3    4    0     0       0          0
+  eth1:      10       1    0    0    0     0          0         0       20       2    0    0    0     0       0          0
+`
+
+func TestGetInterfaceStats(t *testing.T) {
+	f, err := ioutil.TempFile("", "docker-net-dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(netDev); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	interfaces, err := getInterfaceStats(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(interfaces) != 2 {
+		t.Fatalf("Expected 2 interfaces without the loopback, got %v", interfaces)
+	}
+	eth0 := interfaces["eth0"]
+	if eth0 == nil {
+		t.Fatalf("Expected stats for eth0, got %v", interfaces)
+	}
+	if eth0.RxBytes != 1296 || eth0.RxPackets != 16 || eth0.RxErrors != 1 || eth0.RxDropped != 2 {
+		t.Fatalf("Unexpected receive stats %+v", eth0)
+	}
+	if eth0.TxBytes != 648 || eth0.TxPackets != 8 || eth0.TxErrors != 3 || eth0.TxDropped != 4 {
+		t.Fatalf("Unexpected transmit stats %+v", eth0)
+	}
+	if interfaces["eth1"].TxBytes != 20 {
+		t.Fatalf("Unexpected stats for eth1 %+v", interfaces["eth1"])
+	}
+}
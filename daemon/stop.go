@@ -8,17 +8,15 @@ func (daemon *Daemon) ContainerStop(job *engine.Job) engine.Status {
 	if len(job.Args) != 1 {
 		return job.Errorf("Usage: %s CONTAINER\n", job.Name)
 	}
-	var (
-		name = job.Args[0]
-		t    = 10
-	)
-	if job.EnvExists("t") {
-		t = job.GetenvInt("t")
-	}
+	name := job.Args[0]
 	container, err := daemon.Get(name)
 	if err != nil {
 		return job.Error(err)
 	}
+	t := container.stopTimeout()
+	if job.EnvExists("t") {
+		t = job.GetenvInt("t")
+	}
 	if !container.IsRunning() {
 		return job.Errorf("Container already stopped")
 	}
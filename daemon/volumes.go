@@ -1,6 +1,7 @@
 package daemon
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -11,8 +12,12 @@ import (
 	"syscall"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/engine"
 	"github.com/docker/docker/pkg/chrootarchive"
+	"github.com/docker/docker/pkg/parsers/filters"
+	"github.com/docker/docker/pkg/reaper"
 	"github.com/docker/docker/pkg/symlink"
 	"github.com/docker/docker/volumes"
 )
@@ -26,7 +31,7 @@ type Mount struct {
 	from        *Container
 }
 
-func (mnt *Mount) Export(resource string) (io.ReadCloser, error) {
+func (mnt *Mount) Export(resource string, archiveMode bool) (io.ReadCloser, error) {
 	var name string
 	if resource == mnt.MountToPath[1:] {
 		name = filepath.Base(resource)
@@ -35,16 +40,21 @@ func (mnt *Mount) Export(resource string) (io.ReadCloser, error) {
 	if err != nil {
 		return nil, err
 	}
-	return mnt.volume.Export(path, name)
+	return mnt.volume.Export(path, name, archiveMode)
 }
 
-func (container *Container) prepareVolumes() error {
+// prepareVolumes sets up the volumes of the container, the named volumes
+// are mounted by their driver when mount is set, as the container starts
+func (container *Container) prepareVolumes(mount bool) error {
 	if container.Volumes == nil || len(container.Volumes) == 0 {
 		container.Volumes = make(map[string]string)
 		container.VolumesRW = make(map[string]bool)
 	}
+	if container.NamedVolumes == nil {
+		container.NamedVolumes = make(map[string]string)
+	}
 
-	return container.createVolumes()
+	return container.createVolumes(mount)
 }
 
 // sortedVolumeMounts returns the list of container volume mount points sorted in lexicographic order
@@ -58,7 +68,15 @@ func (container *Container) sortedVolumeMounts() []string {
 	return mountPaths
 }
 
-func (container *Container) createVolumes() error {
+func (container *Container) createVolumes(mount bool) error {
+	// the named volumes are mounted before the content of the image is
+	// copied to them
+	if mount {
+		if err := container.mountNamedVolumes(); err != nil {
+			return err
+		}
+	}
+
 	mounts, err := container.parseVolumeMountConfig()
 	if err != nil {
 		return err
@@ -71,15 +89,26 @@ func (container *Container) createVolumes() error {
 	}
 
 	// On every start, this will apply any new `VolumesFrom` entries passed in via HostConfig, which may override volumes set in `create`
-	return container.applyVolumesFrom()
+	if err := container.applyVolumesFrom(); err != nil {
+		return err
+	}
+	if mount {
+		// the named volumes of the --volumes-from applied for the first time
+		return container.mountNamedVolumes()
+	}
+	return nil
 }
 
 func (m *Mount) initialize() error {
+	if m.volume.Name != "" {
+		m.container.NamedVolumes[m.MountToPath] = m.volume.ID
+	}
 	// No need to initialize anything since it's already been initialized
 	if hostPath, exists := m.container.Volumes[m.MountToPath]; exists {
 		// If this is a bind-mount/volumes-from, maybe it was passed in at start instead of create
 		// We need to make sure bind-mounts/volumes-from passed on start can override existing ones.
-		if !m.volume.IsBindMount && m.from == nil {
+		// The path of a named volume changes when its driver mounts it elsewhere.
+		if !m.volume.IsBindMount && m.volume.Name == "" && m.from == nil {
 			return nil
 		}
 		if m.volume.Path == hostPath {
@@ -101,6 +130,9 @@ func (m *Mount) initialize() error {
 	}
 	m.container.VolumesRW[m.MountToPath] = m.Writable
 	m.container.Volumes[m.MountToPath] = m.volume.Path
+	if m.volume.Name == "" {
+		delete(m.container.NamedVolumes, m.MountToPath)
+	}
 	m.volume.AddContainer(m.container.ID)
 	if m.Writable && m.copyData {
 		// Copy whatever is in the container at the mntToPath to the volume
@@ -162,6 +194,22 @@ func (container *Container) parseVolumeMountConfig() (map[string]*Mount, error)
 		if m, exists := mounts[mountToPath]; exists {
 			return nil, fmt.Errorf("Duplicate volume %q: %q already in use, mounted from %q", path, mountToPath, m.volume.Path)
 		}
+		if volumes.IsNamed(path) {
+			vol, err := container.daemon.volumes.FindOrCreateNamed(path)
+			if err != nil {
+				return nil, err
+			}
+			// like the anonymous volumes, an empty named volume gets the
+			// content of the image
+			mounts[mountToPath] = &Mount{
+				container:   container,
+				volume:      vol,
+				MountToPath: mountToPath,
+				Writable:    writable,
+				copyData:    true,
+			}
+			continue
+		}
 		// Check if a volume already exists for this and use it
 		vol, err := container.daemon.volumes.FindOrCreateVolume(path, writable)
 		if err != nil {
@@ -230,8 +278,8 @@ func parseBindMountSpec(spec string) (string, string, bool, error) {
 		return "", "", false, fmt.Errorf("Invalid volume specification: %s", spec)
 	}
 
-	if !filepath.IsAbs(path) {
-		return "", "", false, fmt.Errorf("cannot bind mount volume: %s volume paths must be absolute.", path)
+	if !filepath.IsAbs(path) && !volumes.IsNamed(path) {
+		return "", "", false, fmt.Errorf("cannot bind mount volume: %s volume paths must be absolute or the name of a volume.", path)
 	}
 
 	path = filepath.Clean(path)
@@ -343,14 +391,64 @@ func (container *Container) setupMounts() error {
 		mounts = append(mounts, execdriver.Mount{Source: container.HostsPath, Destination: "/etc/hosts", Writable: true, Private: true})
 	}
 
+	if container.initEnabled() {
+		mounts = append(mounts, execdriver.Mount{Source: container.daemon.SystemInitPath(), Destination: reaper.InitPath, Writable: false, Private: true})
+	}
+
 	container.command.Mounts = mounts
 	return nil
 }
 
+// mountNamedVolumes mounts the named volumes of the container which are not
+// mounted yet with their driver, until the container stops. The driver may
+// mount a volume at another path each time.
+func (container *Container) mountNamedVolumes() error {
+	mounted := make(map[string]bool)
+	for _, id := range container.MountedVolumes {
+		mounted[id] = true
+	}
+	for mountToPath, id := range container.NamedVolumes {
+		vol := container.daemon.volumes.GetByID(id)
+		if vol == nil {
+			return fmt.Errorf("The volume mounted at %s no longer exists", mountToPath)
+		}
+		if !mounted[id] {
+			if _, err := container.daemon.volumes.Mount(vol); err != nil {
+				return fmt.Errorf("Error mounting volume %s: %v", vol.Name, err)
+			}
+			mounted[id] = true
+			container.MountedVolumes = append(container.MountedVolumes, id)
+		}
+		container.Volumes[mountToPath] = vol.Path
+	}
+	return nil
+}
+
+// unmountVolumes unmounts the named volumes mounted by the container
+func (container *Container) unmountVolumes() {
+	for _, id := range container.MountedVolumes {
+		vol := container.daemon.volumes.GetByID(id)
+		if vol == nil {
+			continue
+		}
+		if err := container.daemon.volumes.Unmount(vol); err != nil {
+			log.Errorf("%v: Failed to unmount volume %s: %v", container.ID, vol.Name, err)
+		}
+	}
+	container.MountedVolumes = nil
+}
+
 func (container *Container) VolumeMounts() map[string]*Mount {
 	mounts := make(map[string]*Mount)
 
 	for mountToPath, path := range container.Volumes {
+		// the path of a named volume is the one of its last mount
+		if id, exists := container.NamedVolumes[mountToPath]; exists {
+			if v := container.daemon.volumes.GetByID(id); v != nil {
+				mounts[mountToPath] = &Mount{volume: v, container: container, MountToPath: mountToPath, Writable: container.VolumesRW[mountToPath]}
+				continue
+			}
+		}
 		if v := container.daemon.volumes.Get(path); v != nil {
 			mounts[mountToPath] = &Mount{volume: v, container: container, MountToPath: mountToPath, Writable: container.VolumesRW[mountToPath]}
 		}
@@ -397,3 +495,158 @@ func copyOwnership(source, destination string) error {
 
 	return os.Chmod(destination, os.FileMode(stat.Mode))
 }
+
+// VolumeCreate creates a named volume, the name of the volume is the only
+// argument of the job, a random name is given to the volume when it is empty
+func (daemon *Daemon) VolumeCreate(job *engine.Job) engine.Status {
+	if len(job.Args) != 1 {
+		return job.Errorf("Usage: %s NAME", job.Name)
+	}
+	var options, labels map[string]string
+	if job.EnvExists("DriverOpts") {
+		if err := job.GetenvJson("DriverOpts", &options); err != nil {
+			return job.Error(err)
+		}
+	}
+	if job.EnvExists("Labels") {
+		if err := job.GetenvJson("Labels", &labels); err != nil {
+			return job.Error(err)
+		}
+	}
+	v, err := daemon.volumes.Create(job.Args[0], job.Getenv("Driver"), options, labels)
+	if err != nil {
+		return job.Error(err)
+	}
+	if err := json.NewEncoder(job.Stdout).Encode(volumeResource(v)); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+func (daemon *Daemon) VolumeList(job *engine.Job) engine.Status {
+	volumeFilters, err := parseVolumeFilters(job.Getenv("filters"), acceptedVolumeFilterTags)
+	if err != nil {
+		return job.Error(err)
+	}
+	dangling, err := danglingFilter(volumeFilters)
+	if err != nil {
+		return job.Error(err)
+	}
+
+	list := []*types.Volume{}
+	for _, v := range daemon.volumes.List() {
+		if dangling != nil && v.IsDangling() != *dangling {
+			continue
+		}
+		if !volumeFilters.MatchKVList("label", v.Labels) {
+			continue
+		}
+		list = append(list, volumeResource(v))
+	}
+	if err := json.NewEncoder(job.Stdout).Encode(list); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+func (daemon *Daemon) VolumeInspect(job *engine.Job) engine.Status {
+	if len(job.Args) != 1 {
+		return job.Errorf("Usage: %s VOLUME", job.Name)
+	}
+	v, err := daemon.volumes.GetNamed(job.Args[0])
+	if err != nil {
+		return job.Error(err)
+	}
+	if err := json.NewEncoder(job.Stdout).Encode(volumeResource(v)); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+func (daemon *Daemon) VolumeRm(job *engine.Job) engine.Status {
+	if len(job.Args) != 1 {
+		return job.Errorf("Usage: %s VOLUME", job.Name)
+	}
+	if err := daemon.volumes.Remove(job.Args[0]); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+// VolumePrune removes the volumes no container uses, the filters select the
+// volumes to remove by label
+func (daemon *Daemon) VolumePrune(job *engine.Job) engine.Status {
+	volumeFilters, err := parseVolumeFilters(job.Getenv("filters"), acceptedVolumePruneFilterTags)
+	if err != nil {
+		return job.Error(err)
+	}
+	pruned, reclaimed := daemon.volumes.Prune(func(v *volumes.Volume) bool {
+		return volumeFilters.MatchKVList("label", v.Labels)
+	})
+	report := &types.VolumesPruneReport{
+		VolumesDeleted: []string{},
+		SpaceReclaimed: reclaimed,
+	}
+	for _, v := range pruned {
+		report.VolumesDeleted = append(report.VolumesDeleted, volumeResource(v).Name)
+	}
+	if err := json.NewEncoder(job.Stdout).Encode(report); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+var (
+	acceptedVolumeFilterTags      = map[string]struct{}{"dangling": {}, "label": {}}
+	acceptedVolumePruneFilterTags = map[string]struct{}{"label": {}}
+)
+
+func parseVolumeFilters(param string, accepted map[string]struct{}) (filters.Args, error) {
+	volumeFilters, err := filters.FromParam(param)
+	if err != nil {
+		return nil, err
+	}
+	for name := range volumeFilters {
+		if _, ok := accepted[name]; !ok {
+			return nil, fmt.Errorf("Invalid filter '%s'", name)
+		}
+	}
+	return volumeFilters, nil
+}
+
+// danglingFilter returns the value of the dangling filter, or nil when the
+// volumes are not filtered on it
+func danglingFilter(volumeFilters filters.Args) (*bool, error) {
+	values := volumeFilters["dangling"]
+	if len(values) == 0 {
+		return nil, nil
+	}
+	var dangling bool
+	for _, value := range values {
+		switch strings.ToLower(value) {
+		case "true", "1":
+			dangling = true
+		case "false", "0":
+			dangling = false
+		default:
+			return nil, fmt.Errorf("Invalid filter 'dangling=%s'", value)
+		}
+	}
+	return &dangling, nil
+}
+
+// volumeResource returns the volume as returned by the API, an anonymous
+// volume is named by its ID and kept by the local driver
+func volumeResource(v *volumes.Volume) *types.Volume {
+	name, driver := v.Name, v.Driver
+	if name == "" {
+		name, driver = v.ID, volumes.DefaultDriver
+	}
+	return &types.Volume{
+		Name:       name,
+		Driver:     driver,
+		Mountpoint: v.Path,
+		Options:    v.Options,
+		Labels:     v.Labels,
+	}
+}
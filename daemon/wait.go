@@ -15,7 +15,18 @@ func (daemon *Daemon) ContainerWait(job *engine.Job) engine.Status {
 	if err != nil {
 		return job.Errorf("%s: %s", job.Name, err.Error())
 	}
-	status, _ := container.WaitStop(-1 * time.Second)
+
+	var status int
+	switch condition := job.Getenv("condition"); condition {
+	case "", "not-running":
+		status, _ = container.WaitStop(-1 * time.Second)
+	case "next-exit":
+		status, _ = container.WaitNextExit(-1 * time.Second)
+	case "removed":
+		status = container.WaitRemoved()
+	default:
+		return job.Errorf("Invalid wait condition %q, must be one of not-running, next-exit or removed", condition)
+	}
 	job.Printf("%d\n", status)
 	return engine.StatusOK
 }
@@ -132,8 +132,11 @@ func mainDaemon() {
 	job.SetenvBool("Logging", true)
 	job.SetenvBool("EnableCors", daemonCfg.EnableCors)
 	job.Setenv("CorsHeaders", daemonCfg.CorsHeaders)
+	job.SetenvList("AuthorizationPlugins", daemonCfg.AuthorizationPlugins)
 	job.Setenv("Version", dockerversion.VERSION)
 	job.Setenv("SocketGroup", daemonCfg.SocketGroup)
+	job.Setenv("IdleTimeout", daemonCfg.IdleTimeout.String())
+	job.Setenv("AuditLog", daemonCfg.AuditLog)
 
 	job.SetenvBool("Tls", *flTls)
 	job.SetenvBool("TlsVerify", *flTlsVerify)
@@ -144,4 +147,9 @@ func mainDaemon() {
 	if err := job.Run(); err != nil {
 		log.Fatal(err)
 	}
+	// the API stops serving once the daemon starts shutting down, wait for
+	// the shutdown to exit the daemon
+	if eng.IsShutdown() {
+		select {}
+	}
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 
 	log "github.com/Sirupsen/logrus"
@@ -128,6 +129,7 @@ func main() {
 	} else {
 		cli = client.NewDockerCli(os.Stdin, os.Stdout, os.Stderr, *flTrustKey, protoAddrParts[0], protoAddrParts[1], nil)
 	}
+	cli.SetPluginEnv(pluginEnv(flHosts[0]))
 
 	if err := cli.Cmd(flag.Args()...); err != nil {
 		if sterr, ok := err.(*utils.StatusError); ok {
@@ -140,6 +142,42 @@ func main() {
 	}
 }
 
+// pluginEnv returns the environment passing the host and the TLS
+// configuration of the client to the plugins, in the variables the client
+// reads, so that the plugins running docker connect as the client did
+func pluginEnv(host string) []string {
+	env := []string{"DOCKER_HOST=" + host}
+	// an empty DOCKER_TLS_VERIFY overrides the one of the environment of
+	// the client when --tlsverify=false is given
+	if *flTlsVerify {
+		env = append(env, "DOCKER_TLS_VERIFY=1")
+	} else {
+		env = append(env, "DOCKER_TLS_VERIFY=")
+	}
+	if *flTls || *flTlsVerify {
+		if dir, ok := tlsCertPath(); ok {
+			env = append(env, "DOCKER_CERT_PATH="+dir)
+		}
+	}
+	return env
+}
+
+// tlsCertPath returns the directory of the TLS files of the client when
+// they are in the same directory with the names DOCKER_CERT_PATH expects
+func tlsCertPath() (string, bool) {
+	dir := filepath.Dir(*flCa)
+	for _, f := range []struct{ path, name string }{
+		{*flCa, defaultCaFile},
+		{*flCert, defaultCertFile},
+		{*flKey, defaultKeyFile},
+	} {
+		if filepath.Dir(f.path) != dir || filepath.Base(f.path) != f.name {
+			return "", false
+		}
+	}
+	return dir, true
+}
+
 func showVersion() {
 	fmt.Printf("Docker version %s, build %s\n", dockerversion.VERSION, dockerversion.GITCOMMIT)
 }
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"runtime"
 
+	"github.com/docker/docker/api/client"
 	"github.com/docker/docker/opts"
 	"github.com/docker/docker/pkg/homedir"
 	flag "github.com/docker/docker/pkg/mflag"
@@ -86,6 +87,7 @@ func init() {
 			{"exec", "Run a command in a running container"},
 			{"export", "Stream the contents of a container as a tar archive"},
 			{"history", "Show the history of an image"},
+			{"image", "Manage Docker images"},
 			{"images", "List images"},
 			{"import", "Create a new filesystem image from the contents of a tarball"},
 			{"info", "Display system-wide information"},
@@ -95,6 +97,7 @@ func init() {
 			{"login", "Register or log in to a Docker registry server"},
 			{"logout", "Log out from a Docker registry server"},
 			{"logs", "Fetch the logs of a container"},
+			{"network", "Manage Docker networks"},
 			{"port", "Lookup the public-facing port that is NAT-ed to PRIVATE_PORT"},
 			{"pause", "Pause all processes within a container"},
 			{"ps", "List containers"},
@@ -114,10 +117,17 @@ func init() {
 			{"top", "Lookup the running processes of a container"},
 			{"unpause", "Unpause a paused container"},
 			{"version", "Show the Docker version information"},
+			{"volume", "Manage Docker volumes"},
 			{"wait", "Block until a container stops, then print its exit code"},
 		} {
 			help += fmt.Sprintf("    %-10.10s%s\n", command[0], command[1])
 		}
+		if plugins := client.ListPlugins(); len(plugins) > 0 {
+			help += "\nPlugins:\n"
+			for _, plugin := range plugins {
+				help += fmt.Sprintf("    %s\n", plugin)
+			}
+		}
 		help += "\nRun 'docker COMMAND --help' for more information on a command."
 		fmt.Fprintf(os.Stdout, "%s\n", help)
 	}
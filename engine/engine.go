@@ -57,7 +57,11 @@ type Engine struct {
 	tasks      sync.WaitGroup
 	l          sync.RWMutex // lock for shutdown
 	shutdown   bool
+	onDrain    []func() // drain handlers
 	onShutdown []func() // shutdown handlers
+
+	// shutdownTimeout bounds the time Shutdown waits for its handlers
+	shutdownTimeout time.Duration
 }
 
 func (eng *Engine) Register(name string, handler Handler) error {
@@ -82,6 +86,8 @@ func New() *Engine {
 		Stderr:   os.Stderr,
 		Stdin:    os.Stdin,
 		Logging:  true,
+
+		shutdownTimeout: 10 * time.Second,
 	}
 	eng.Register("commands", func(job *Job) Status {
 		for _, name := range eng.commands() {
@@ -146,11 +152,30 @@ func (eng *Engine) OnShutdown(h func()) {
 	eng.l.Unlock()
 }
 
+// OnDrain registers a new callback to be called by Shutdown before it waits
+// for the active jobs. This is typically used by services to end the jobs
+// which would otherwise run until their client goes away, such as the
+// streams of logs.
+func (eng *Engine) OnDrain(h func()) {
+	eng.l.Lock()
+	eng.onDrain = append(eng.onDrain, h)
+	eng.l.Unlock()
+}
+
+// SetShutdownTimeout sets how long Shutdown waits for the shutdown handlers,
+// 10 seconds by default.
+func (eng *Engine) SetShutdownTimeout(timeout time.Duration) {
+	eng.l.Lock()
+	eng.shutdownTimeout = timeout
+	eng.l.Unlock()
+}
+
 // Shutdown permanently shuts down eng as follows:
 // - It refuses all new jobs, permanently.
-// - It waits for all active jobs to complete (with no timeout)
+// - It calls all drain handlers concurrently (if any), and waits for them
+// - It waits for all active jobs to complete (with a 5 seconds timeout)
 // - It calls all shutdown handlers concurrently (if any)
-// - It returns when all handlers complete, or after 15 seconds,
+// - It returns when all handlers complete, or after the shutdown timeout,
 //	whichever happens first.
 func (eng *Engine) Shutdown() {
 	eng.l.Lock()
@@ -159,6 +184,7 @@ func (eng *Engine) Shutdown() {
 		return
 	}
 	eng.shutdown = true
+	timeout := eng.shutdownTimeout
 	eng.l.Unlock()
 	// We don't need to protect the rest with a lock, to allow
 	// for other calls to immediately fail with "shutdown" instead
@@ -166,6 +192,9 @@ func (eng *Engine) Shutdown() {
 	// This requires all concurrent calls to check for shutdown, otherwise
 	// it might cause a race.
 
+	// Call drain handlers, if any.
+	runHandlers(eng.onDrain)
+
 	// Wait for all jobs to complete.
 	// Timeout after 5 seconds.
 	tasksDone := make(chan struct{})
@@ -179,27 +208,33 @@ func (eng *Engine) Shutdown() {
 	}
 
 	// Call shutdown handlers, if any.
-	// Timeout after 10 seconds.
-	var wg sync.WaitGroup
-	for _, h := range eng.onShutdown {
-		wg.Add(1)
-		go func(h func()) {
-			defer wg.Done()
-			h()
-		}(h)
-	}
+	// Timeout after the shutdown timeout.
 	done := make(chan struct{})
 	go func() {
-		wg.Wait()
+		runHandlers(eng.onShutdown)
 		close(done)
 	}()
 	select {
-	case <-time.After(time.Second * 10):
+	case <-time.After(timeout):
 	case <-done:
 	}
 	return
 }
 
+// runHandlers calls the handlers concurrently, and returns when they all
+// complete.
+func runHandlers(handlers []func()) {
+	var wg sync.WaitGroup
+	for _, h := range handlers {
+		wg.Add(1)
+		go func(h func()) {
+			defer wg.Done()
+			h()
+		}(h)
+	}
+	wg.Wait()
+}
+
 // IsShutdown returns true if the engine is in the process
 // of shutting down, or already shut down.
 // Otherwise it returns false.
@@ -78,3 +78,47 @@ func TestShutdownDuringRun(t *testing.T) {
 		t.Fatalf("job did not complete")
 	}
 }
+
+func TestShutdownDrainsJobs(t *testing.T) {
+	eng := New()
+	drained := make(chan struct{})
+	eng.Register("follow", func(job *Job) Status {
+		<-drained
+		return StatusOK
+	})
+	eng.OnDrain(func() { close(drained) })
+	var stopped bool
+	eng.OnShutdown(func() {
+		select {
+		case <-drained:
+			stopped = true
+		default:
+		}
+	})
+	go eng.Job("follow").Run()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	eng.Shutdown()
+	// without the drain handler, the job would run until the 5 seconds
+	// timeout
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("shutdown waited for the job to time out: %v", d)
+	}
+	if !stopped {
+		t.Fatalf("the shutdown handlers were called before the drain handlers")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	eng := New()
+	eng.SetShutdownTimeout(100 * time.Millisecond)
+	eng.OnShutdown(func() {
+		time.Sleep(time.Minute)
+	})
+	start := time.Now()
+	eng.Shutdown()
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("shutdown did not time out: %v", d)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"sort"
 	"strconv"
+	"strings"
 )
 
 type Table struct {
@@ -63,6 +64,39 @@ func (t *Table) ReverseSort() {
 	sort.Sort(sort.Reverse(t))
 }
 
+// Paginate keeps the entries of the table from offset, at most limit of
+// them when limit is positive
+func (t *Table) Paginate(offset, limit int) {
+	if offset > len(t.Data) {
+		offset = len(t.Data)
+	}
+	t.Data = t.Data[offset:]
+	if limit > 0 && limit < len(t.Data) {
+		t.Data = t.Data[:limit]
+	}
+}
+
+// Select keeps only the given keys in the entries of the table, all of them
+// when no key is given
+func (t *Table) Select(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	selected := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		selected[key] = struct{}{}
+	}
+	for i, env := range t.Data {
+		out := &Env{}
+		for _, kv := range *env {
+			if _, ok := selected[strings.SplitN(kv, "=", 2)[0]]; ok {
+				*out = append(*out, kv)
+			}
+		}
+		t.Data[i] = out
+	}
+}
+
 func (t *Table) WriteListTo(dst io.Writer) (n int64, err error) {
 	if _, err := dst.Write([]byte{'['}); err != nil {
 		return -1, err
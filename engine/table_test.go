@@ -110,3 +110,56 @@ func TestTableReverseSortStringValue(t *testing.T) {
 		t.Fatalf("Expected A, got %s", value)
 	}
 }
+
+func TestTablePaginate(t *testing.T) {
+	newTable := func() *Table {
+		table := NewTable("", 0)
+		for _, key := range []string{"A", "B", "C", "D"} {
+			e := &Env{}
+			e.Set("Key", key)
+			table.Add(e)
+		}
+		return table
+	}
+	for _, c := range []struct {
+		offset, limit int
+		expected      string
+	}{
+		{0, 0, "ABCD"},
+		{1, 0, "BCD"},
+		{1, 2, "BC"},
+		{3, 2, "D"},
+		{0, 10, "ABCD"},
+		{4, 0, ""},
+		{10, 2, ""},
+	} {
+		table := newTable()
+		table.Paginate(c.offset, c.limit)
+		keys := ""
+		for _, e := range table.Data {
+			keys += e.Get("Key")
+		}
+		if keys != c.expected {
+			t.Fatalf("Expected %q with offset %d and limit %d, got %q", c.expected, c.offset, c.limit, keys)
+		}
+	}
+}
+
+func TestTableSelect(t *testing.T) {
+	table := NewTable("", 0)
+	e := &Env{}
+	e.Set("Id", "abc")
+	e.SetList("Names", []string{"/web"})
+	e.Set("Status", "Up 2 minutes")
+	table.Add(e)
+
+	table.Select(nil)
+	if table.Data[0].Len() != 3 {
+		t.Fatalf("Expected all the keys without a selection, got %v", table.Data[0])
+	}
+
+	table.Select([]string{"Id", "Names", "Unknown"})
+	if table.Data[0].Len() != 2 || table.Data[0].Get("Id") != "abc" || table.Data[0].GetList("Names")[0] != "/web" || table.Data[0].Exists("Status") {
+		t.Fatalf("Expected only Id and Names to be selected, got %v", table.Data[0])
+	}
+}
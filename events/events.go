@@ -13,6 +13,12 @@ import (
 
 const eventsLimit = 64
 
+// The types of the objects of the events
+const (
+	ContainerEventType = "container"
+	ImageEventType     = "image"
+)
+
 type listener chan<- *utils.JSONMessage
 
 type Events struct {
@@ -88,15 +94,31 @@ func (e *Events) Get(job *engine.Job) engine.Status {
 	}
 }
 
+// Log logs the event ACTION of the object ID, FROM is the image of a
+// container. The type and the attributes of the object are set in the
+// environment of the job.
 func (e *Events) Log(job *engine.Job) engine.Status {
 	if len(job.Args) != 3 {
 		return job.Errorf("usage: %s ACTION ID FROM", job.Name)
 	}
+	var attributes map[string]string
+	if err := job.GetenvJson("attributes", &attributes); err != nil {
+		return job.Error(err)
+	}
 	// not waiting for receivers
-	go e.log(job.Args[0], job.Args[1], job.Args[2])
+	go e.log(job.Getenv("type"), job.Args[0], job.Args[1], job.Args[2], attributes)
 	return engine.StatusOK
 }
 
+// LogEvent logs the event action of the object id of type eventType with
+// the log job, from is the image of a container
+func LogEvent(eng *engine.Engine, eventType, action, id, from string, attributes map[string]string) error {
+	job := eng.Job("log", action, id, from)
+	job.Setenv("type", eventType)
+	job.SetenvJson("attributes", attributes)
+	return job.Run()
+}
+
 func (e *Events) SubscribersCount(job *engine.Job) engine.Status {
 	ret := &engine.Env{}
 	ret.SetInt("count", e.subscribersCount())
@@ -123,7 +145,14 @@ func writeEvent(job *engine.Job, event *utils.JSONMessage, eventFilters filters.
 		return true
 	}
 
-	if isFiltered(event.Status, eventFilters["event"]) || isFiltered(event.From, eventFilters["image"]) || isFiltered(event.ID, eventFilters["container"]) {
+	if isFiltered(event.Status, eventFilters["event"]) || isFiltered(event.From, eventFilters["image"]) || isFiltered(event.ID, eventFilters["container"]) || isFiltered(event.Type, eventFilters["type"]) {
+		return nil
+	}
+	var attributes map[string]string
+	if event.Actor != nil {
+		attributes = event.Actor.Attributes
+	}
+	if !eventFilters.MatchKVList("label", attributes) {
 		return nil
 	}
 
@@ -158,10 +187,18 @@ func (e *Events) subscribersCount() int {
 	return c
 }
 
-func (e *Events) log(action, id, from string) {
+func (e *Events) log(eventType, action, id, from string, attributes map[string]string) {
 	e.mu.Lock()
 	now := time.Now().UTC().Unix()
-	jm := &utils.JSONMessage{Status: action, ID: id, From: from, Time: now}
+	jm := &utils.JSONMessage{
+		Status: action,
+		ID:     id,
+		From:   from,
+		Time:   now,
+		Type:   eventType,
+		Action: action,
+		Actor:  &utils.JSONEventActor{ID: id, Attributes: attributes},
+	}
 	if len(e.events) == cap(e.events) {
 		// discard oldest event
 		copy(e.events, e.events[1:])
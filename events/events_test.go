@@ -22,7 +22,7 @@ func TestEventsPublish(t *testing.T) {
 	if count != 2 {
 		t.Fatalf("Must be 2 subscribers, got %d", count)
 	}
-	go e.log("test", "cont", "image")
+	go e.log(ContainerEventType, "test", "cont", "image", nil)
 	select {
 	case msg := <-l1:
 		if len(e.events) != 1 {
@@ -66,7 +66,7 @@ func TestEventsPublishTimeout(t *testing.T) {
 
 	c := make(chan struct{})
 	go func() {
-		e.log("test", "cont", "image")
+		e.log(ContainerEventType, "test", "cont", "image", nil)
 		close(c)
 	}()
 
@@ -152,3 +152,68 @@ func TestEventsCountJob(t *testing.T) {
 		t.Fatalf("There must be 2 subscribers, got %d", count)
 	}
 }
+
+func TestLogEventsFilters(t *testing.T) {
+	e := New()
+	eng := engine.New()
+	if err := e.Install(eng); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LogEvent(eng, ContainerEventType, "create", "cont_1", "busybox", map[string]string{"name": "web", "com.example.tier": "front"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := LogEvent(eng, ContainerEventType, "create", "cont_2", "busybox", map[string]string{"name": "db", "com.example.tier": "back"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := LogEvent(eng, ImageEventType, "untag", "image_1", "", map[string]string{"name": "busybox:latest"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	getEvents := func(filters string) []utils.JSONMessage {
+		job := eng.Job("events")
+		job.SetenvInt64("since", 1)
+		job.SetenvInt64("until", time.Now().Unix())
+		job.Setenv("filters", filters)
+		buf := bytes.NewBuffer(nil)
+		job.Stdout.Add(buf)
+		if err := job.Run(); err != nil {
+			t.Fatal(err)
+		}
+		dec := json.NewDecoder(bytes.NewBuffer(buf.Bytes()))
+		var msgs []utils.JSONMessage
+		for {
+			var jm utils.JSONMessage
+			if err := dec.Decode(&jm); err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatal(err)
+			}
+			msgs = append(msgs, jm)
+		}
+		return msgs
+	}
+
+	msgs := getEvents(`{"type":["image"]}`)
+	if len(msgs) != 1 {
+		t.Fatalf("Must be 1 image event, got %d", len(msgs))
+	}
+	if msgs[0].Type != ImageEventType || msgs[0].Action != "untag" || msgs[0].Actor == nil || msgs[0].Actor.ID != "image_1" {
+		t.Fatalf("Unexpected image event %+v", msgs[0])
+	}
+	if msgs[0].Actor.Attributes["name"] != "busybox:latest" {
+		t.Fatalf("Unexpected attributes of the image event %v", msgs[0].Actor.Attributes)
+	}
+
+	msgs = getEvents(`{"label":["com.example.tier=back"]}`)
+	if len(msgs) != 1 || msgs[0].ID != "cont_2" || msgs[0].Status != "create" || msgs[0].From != "busybox" {
+		t.Fatalf("Expected the create event of cont_2, got %+v", msgs)
+	}
+
+	msgs = getEvents(`{"type":["container"],"label":["com.example.tier"]}`)
+	if len(msgs) != 2 {
+		t.Fatalf("Must be 2 container events, got %d", len(msgs))
+	}
+}
@@ -0,0 +1,70 @@
+package graph
+
+import (
+	"github.com/docker/docker/registry"
+)
+
+// maxBlobSources is the number of repositories remembered for each layer
+// blob, the least recent ones are forgotten first
+const maxBlobSources = 5
+
+// BlobSource is a repository of a registry a layer blob was pulled from or
+// pushed to. A push to another repository of the same registry mounts the
+// blob from it instead of uploading the layer again.
+type BlobSource struct {
+	Registry   string
+	Repository string
+}
+
+// blobSums returns the sums of the layer blobs of a manifest
+func blobSums(manifest *registry.ManifestData) []string {
+	sums := make([]string, 0, len(manifest.FSLayers))
+	for _, layer := range manifest.FSLayers {
+		sums = append(sums, layer.BlobSum)
+	}
+	return sums
+}
+
+// addBlobSources records that the layer blobs were pulled from or pushed to
+// the repository remoteName of the registry indexName.
+func (store *TagStore) addBlobSources(sums []string, indexName, remoteName string) error {
+	store.Lock()
+	defer store.Unlock()
+	if err := store.reload(); err != nil {
+		return err
+	}
+	if store.BlobSources == nil {
+		store.BlobSources = make(map[string][]BlobSource)
+	}
+	source := BlobSource{Registry: indexName, Repository: remoteName}
+	for _, sum := range sums {
+		sources := []BlobSource{}
+		for _, s := range store.BlobSources[sum] {
+			if s != source {
+				sources = append(sources, s)
+			}
+		}
+		sources = append(sources, source)
+		if len(sources) > maxBlobSources {
+			sources = sources[len(sources)-maxBlobSources:]
+		}
+		store.BlobSources[sum] = sources
+	}
+	return store.save()
+}
+
+// blobSources returns the repositories of the registry indexName other than
+// remoteName the layer blob was pulled from or pushed to, the most recent
+// first.
+func (store *TagStore) blobSources(sum, indexName, remoteName string) []string {
+	store.Lock()
+	defer store.Unlock()
+	var repos []string
+	sources := store.BlobSources[sum]
+	for i := len(sources) - 1; i >= 0; i-- {
+		if sources[i].Registry == indexName && sources[i].Repository != remoteName {
+			repos = append(repos, sources[i].Repository)
+		}
+	}
+	return repos
+}
@@ -0,0 +1,157 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/digest"
+	"github.com/docker/docker/registry"
+)
+
+// RepositoryDigests are the digests of the manifests of a repository that
+// were pulled from or pushed to a registry. The images are referenced by
+// digest as repository@digest.
+type RepositoryDigests struct {
+	// Images maps the digests to the IDs of the images
+	Images map[string]string
+	// Tags maps the tags to the digest of the manifest they were last
+	// pulled or pushed with
+	Tags map[string]string
+}
+
+// SetDigest references the image imageID of the repository by the digest
+// of its manifest, which is recorded as the digest of tag when tag is set.
+func (store *TagStore) SetDigest(repoName, tag string, dgst digest.Digest, imageID string) error {
+	if err := dgst.Validate(); err != nil {
+		return err
+	}
+	store.Lock()
+	defer store.Unlock()
+	if err := store.reload(); err != nil {
+		return err
+	}
+	repoName = registry.NormalizeLocalName(repoName)
+	if store.Digests == nil {
+		store.Digests = make(map[string]*RepositoryDigests)
+	}
+	r, exists := store.Digests[repoName]
+	if !exists {
+		r = &RepositoryDigests{Images: make(map[string]string), Tags: make(map[string]string)}
+		store.Digests[repoName] = r
+	}
+	r.Images[dgst.String()] = imageID
+	if tag != "" {
+		r.Tags[tag] = dgst.String()
+	}
+	return store.save()
+}
+
+// GetDigest returns the digest of the manifest the tag of the repository
+// was last pulled or pushed with, it is empty when it is unknown.
+func (store *TagStore) GetDigest(repoName, tag string) (digest.Digest, error) {
+	store.Lock()
+	defer store.Unlock()
+	if err := store.reload(); err != nil {
+		return "", err
+	}
+	if r, exists := store.Digests[registry.NormalizeLocalName(repoName)]; exists {
+		return digest.Digest(r.Tags[tag]), nil
+	}
+	return "", nil
+}
+
+// GetImageByDigest returns the image of the repository referenced by the
+// digest of its manifest, nil when there is none.
+func (store *TagStore) GetImageByDigest(repoName string, dgst digest.Digest) (*image.Image, error) {
+	store.Lock()
+	defer store.Unlock()
+	if err := store.reload(); err != nil {
+		return nil, err
+	}
+	r, exists := store.Digests[registry.NormalizeLocalName(repoName)]
+	if !exists {
+		return nil, nil
+	}
+	id, exists := r.Images[dgst.String()]
+	if !exists {
+		return nil, nil
+	}
+	return store.graph.Get(id)
+}
+
+// RepoDigests returns the references by digest of an image, in the form
+// repository@digest.
+func (store *TagStore) RepoDigests(imageID string) []string {
+	store.Lock()
+	defer store.Unlock()
+	return store.repoDigests(imageID)
+}
+
+// repoDigests returns the references by digest of an image, the store has
+// to be locked.
+func (store *TagStore) repoDigests(imageID string) []string {
+	var refs []string
+	for repoName, r := range store.Digests {
+		for dgst, id := range r.Images {
+			if id == imageID {
+				refs = append(refs, repoName+"@"+dgst)
+			}
+		}
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// deleteDigest drops the reference of the repository by digest dgst
+func (store *TagStore) deleteDigest(repoName string, dgst digest.Digest) (bool, error) {
+	store.Lock()
+	defer store.Unlock()
+	if err := store.reload(); err != nil {
+		return false, err
+	}
+	repoName = registry.NormalizeLocalName(repoName)
+	r, exists := store.Digests[repoName]
+	if !exists {
+		return false, fmt.Errorf("No such repository: %s", repoName)
+	}
+	if _, exists := r.Images[dgst.String()]; !exists {
+		return false, fmt.Errorf("No such digest: %s@%s", repoName, dgst)
+	}
+	delete(r.Images, dgst.String())
+	for tag, tagDigest := range r.Tags {
+		if tagDigest == dgst.String() {
+			delete(r.Tags, tag)
+		}
+	}
+	if len(r.Images) == 0 {
+		delete(store.Digests, repoName)
+	}
+	return true, store.save()
+}
+
+// deleteDigests drops the references by digest of an image
+func (store *TagStore) deleteDigests(imageID string) error {
+	store.Lock()
+	defer store.Unlock()
+	if err := store.reload(); err != nil {
+		return err
+	}
+	for repoName, r := range store.Digests {
+		for dgst, id := range r.Images {
+			if id != imageID {
+				continue
+			}
+			delete(r.Images, dgst)
+			for tag, tagDigest := range r.Tags {
+				if tagDigest == dgst {
+					delete(r.Tags, tag)
+				}
+			}
+		}
+		if len(r.Images) == 0 {
+			delete(store.Digests, repoName)
+		}
+	}
+	return store.save()
+}
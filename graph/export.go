@@ -2,27 +2,85 @@ package graph
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
+	"runtime"
+	"sync"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/digest"
+	"github.com/docker/docker/pkg/ioutils"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/registry"
+	"github.com/docker/libtrust"
 )
 
+// archiveManifestFile is the name of the manifest of the tar balls of the
+// exported images
+const archiveManifestFile = "manifest.json"
+
+// archiveManifest has the digests of the files of the exported images, to
+// verify them when they are loaded. It is signed with the key of the daemon
+// which exported the images, the loads with verify only accept the
+// manifests signed with a key they trust.
+type archiveManifest struct {
+	// Repositories is the digest of the repositories file, if any
+	Repositories digest.Digest `json:",omitempty"`
+	// Images maps the IDs of the images in the tar ball to the digests of
+	// their files
+	Images map[string]archiveImage
+}
+
+type archiveImage struct {
+	JSON  digest.Digest
+	Layer digest.Digest
+}
+
+// signArchiveManifest returns the JSON of the manifest signed with key, it
+// is left unsigned without a key
+func signArchiveManifest(manifest *archiveManifest, key libtrust.PrivateKey) ([]byte, error) {
+	manifestJSON, err := json.MarshalIndent(manifest, "", "   ")
+	if err != nil || key == nil {
+		return manifestJSON, err
+	}
+	js, err := libtrust.NewJSONSignature(manifestJSON)
+	if err != nil {
+		return nil, err
+	}
+	if err := js.Sign(key); err != nil {
+		return nil, err
+	}
+	return js.PrettySignature("signatures")
+}
+
 // CmdImageExport exports all images with the given tag. All versions
 // containing the same tag are exported. The resulting output is an
-// uncompressed tar ball.
+// uncompressed tar ball, the layers in it are compressed in parallel with
+// the compression of the compress env (none, gzip or zstd).
 // name is the set of tags to export.
+// exclude is the set of IDs of the images left out of the tar ball, the
+// ones which are already where the tar ball is loaded.
+// The manifest.json of the tar ball has the digests of the files of the
+// images, which are verified when the tar ball is loaded, signed with the
+// key of the daemon.
 // out is the writer where the images are written to.
 func (s *TagStore) CmdImageExport(job *engine.Job) engine.Status {
 	if len(job.Args) < 1 {
 		return job.Errorf("Usage: %s IMAGE [IMAGE...]\n", job.Name)
 	}
+	compression, err := parseExportCompression(job.Getenv("compress"))
+	if err != nil {
+		return job.Error(err)
+	}
+	exclude := make(map[string]bool)
+	for _, id := range job.GetenvList("exclude") {
+		exclude[id] = true
+	}
 	// get image json
 	tempdir, err := ioutil.TempDir("", "docker-export-")
 	if err != nil {
@@ -30,6 +88,7 @@ func (s *TagStore) CmdImageExport(job *engine.Job) engine.Status {
 	}
 	defer os.RemoveAll(tempdir)
 
+	var layers []string
 	rootRepoMap := map[string]Repository{}
 	addKey := func(name string, tag string, id string) {
 		log.Debugf("add key [%s:%s]", name, tag)
@@ -47,7 +106,7 @@ func (s *TagStore) CmdImageExport(job *engine.Job) engine.Status {
 			// this is a base repo name, like 'busybox'
 			for tag, id := range rootRepo {
 				addKey(name, tag, id)
-				if err := s.exportImage(job.Eng, id, tempdir); err != nil {
+				if err := s.exportImage(job.Eng, id, tempdir, exclude, &layers); err != nil {
 					return job.Error(err)
 				}
 			}
@@ -66,28 +125,48 @@ func (s *TagStore) CmdImageExport(job *engine.Job) engine.Status {
 				if len(repoTag) > 0 {
 					addKey(repoName, repoTag, img.ID)
 				}
-				if err := s.exportImage(job.Eng, img.ID, tempdir); err != nil {
+				if err := s.exportImage(job.Eng, img.ID, tempdir, exclude, &layers); err != nil {
 					return job.Error(err)
 				}
 
 			} else {
 				// this must be an ID that didn't get looked up just right?
-				if err := s.exportImage(job.Eng, name, tempdir); err != nil {
+				if err := s.exportImage(job.Eng, name, tempdir, exclude, &layers); err != nil {
 					return job.Error(err)
 				}
 			}
 		}
 		log.Debugf("End Serializing %s", name)
 	}
+	layerDigests, err := s.exportLayers(job.Eng, layers, tempdir, compression)
+	if err != nil {
+		return job.Error(err)
+	}
+	manifest := archiveManifest{Images: make(map[string]archiveImage)}
+	for _, id := range layers {
+		jsonData, err := ioutil.ReadFile(path.Join(tempdir, id, "json"))
+		if err != nil {
+			return job.Error(err)
+		}
+		manifest.Images[id] = archiveImage{JSON: digest.FromBytes(jsonData), Layer: layerDigests[id]}
+	}
 	// write repositories, if there is something to write
 	if len(rootRepoMap) > 0 {
 		rootRepoJson, _ := json.Marshal(rootRepoMap)
 		if err := ioutil.WriteFile(path.Join(tempdir, "repositories"), rootRepoJson, os.FileMode(0644)); err != nil {
 			return job.Error(err)
 		}
+		manifest.Repositories = digest.FromBytes(rootRepoJson)
 	} else {
 		log.Debugf("There were no repositories to write")
 	}
+	manifestJSON, err := signArchiveManifest(&manifest, s.trustKey)
+	if err != nil {
+		return job.Error(err)
+	}
+	if err := ioutil.WriteFile(path.Join(tempdir, archiveManifestFile), manifestJSON, os.FileMode(0644)); err != nil {
+		return job.Error(err)
+	}
 
 	fs, err := archive.Tar(tempdir, archive.Uncompressed)
 	if err != nil {
@@ -103,49 +182,47 @@ func (s *TagStore) CmdImageExport(job *engine.Job) engine.Status {
 }
 
 // FIXME: this should be a top-level function, not a class method
-func (s *TagStore) exportImage(eng *engine.Engine, name, tempdir string) error {
+// exportImage writes the metadata of the image name and its parents, but
+// the excluded ones, and appends the IDs of the images to the layers to
+// write.
+func (s *TagStore) exportImage(eng *engine.Engine, name, tempdir string, exclude map[string]bool, layers *[]string) error {
 	for n := name; n != ""; {
-		// temporary directory
-		tmpImageDir := path.Join(tempdir, n)
-		if err := os.Mkdir(tmpImageDir, os.FileMode(0755)); err != nil {
-			if os.IsExist(err) {
-				return nil
+		if exclude[n] {
+			log.Debugf("Excluding %s", n)
+		} else {
+			// temporary directory
+			tmpImageDir := path.Join(tempdir, n)
+			if err := os.Mkdir(tmpImageDir, os.FileMode(0755)); err != nil {
+				if os.IsExist(err) {
+					return nil
+				}
+				return err
 			}
-			return err
-		}
 
-		var version = "1.0"
-		var versionBuf = []byte(version)
+			var version = "1.0"
+			var versionBuf = []byte(version)
 
-		if err := ioutil.WriteFile(path.Join(tmpImageDir, "VERSION"), versionBuf, os.FileMode(0644)); err != nil {
-			return err
-		}
+			if err := ioutil.WriteFile(path.Join(tmpImageDir, "VERSION"), versionBuf, os.FileMode(0644)); err != nil {
+				return err
+			}
 
-		// serialize json
-		json, err := os.Create(path.Join(tmpImageDir, "json"))
-		if err != nil {
-			return err
-		}
-		job := eng.Job("image_inspect", n)
-		job.SetenvBool("raw", true)
-		job.Stdout.Add(json)
-		if err := job.Run(); err != nil {
-			return err
-		}
+			// serialize json
+			json, err := os.Create(path.Join(tmpImageDir, "json"))
+			if err != nil {
+				return err
+			}
+			job := eng.Job("image_inspect", n)
+			job.SetenvBool("raw", true)
+			job.Stdout.Add(json)
+			if err := job.Run(); err != nil {
+				return err
+			}
 
-		// serialize filesystem
-		fsTar, err := os.Create(path.Join(tmpImageDir, "layer.tar"))
-		if err != nil {
-			return err
-		}
-		job = eng.Job("image_tarlayer", n)
-		job.Stdout.Add(fsTar)
-		if err := job.Run(); err != nil {
-			return err
+			*layers = append(*layers, n)
 		}
 
 		// find parent
-		job = eng.Job("image_get", n)
+		job := eng.Job("image_get", n)
 		info, _ := job.Stdout.AddEnv()
 		if err := job.Run(); err != nil {
 			return err
@@ -154,3 +231,72 @@ func (s *TagStore) exportImage(eng *engine.Engine, name, tempdir string) error {
 	}
 	return nil
 }
+
+// exportLayers writes the layers of the images ids, up to one per CPU at
+// a time as compressing a layer keeps a CPU busy. It returns the digests of
+// the layer files.
+func (s *TagStore) exportLayers(eng *engine.Engine, ids []string, tempdir string, compression archive.Compression) (map[string]digest.Digest, error) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		sem     = make(chan struct{}, runtime.NumCPU())
+		errs    = make(chan error, len(ids))
+		digests = make(map[string]digest.Digest)
+	)
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			dgst, err := s.exportLayer(eng, id, tempdir, compression)
+			if err != nil {
+				errs <- fmt.Errorf("Error exporting the layer of %s: %v", id, err)
+				return
+			}
+			mu.Lock()
+			digests[id] = dgst
+			mu.Unlock()
+		}(id)
+	}
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+	return digests, nil
+}
+
+func (s *TagStore) exportLayer(eng *engine.Engine, id, tempdir string, compression archive.Compression) (digest.Digest, error) {
+	// serialize filesystem
+	fsTar, err := os.Create(path.Join(tempdir, id, "layer.tar"))
+	if err != nil {
+		return "", err
+	}
+	defer fsTar.Close()
+	digester := digest.NewDigester()
+	w, err := archive.CompressStream(ioutils.NopWriteCloser(io.MultiWriter(fsTar, digester)), compression)
+	if err != nil {
+		return "", err
+	}
+	// the job closes w, which flushes the compressed data
+	job := eng.Job("image_tarlayer", id)
+	job.Stdout.Add(w)
+	if err := job.Run(); err != nil {
+		return "", err
+	}
+	return digester.Digest(), nil
+}
+
+// parseExportCompression parses the compression of the exported layers
+func parseExportCompression(compress string) (archive.Compression, error) {
+	switch compress {
+	case "", "none":
+		return archive.Uncompressed, nil
+	case "gzip":
+		return archive.Gzip, nil
+	case "zstd":
+		return archive.Zstd, nil
+	}
+	return archive.Uncompressed, fmt.Errorf("Invalid compression %q, the compressions are none, gzip and zstd", compress)
+}
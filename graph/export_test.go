@@ -0,0 +1,27 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/docker/docker/pkg/archive"
+)
+
+func TestParseExportCompression(t *testing.T) {
+	for compress, expected := range map[string]archive.Compression{
+		"":     archive.Uncompressed,
+		"none": archive.Uncompressed,
+		"gzip": archive.Gzip,
+		"zstd": archive.Zstd,
+	} {
+		compression, err := parseExportCompression(compress)
+		if err != nil {
+			t.Fatalf("Error parsing compression %q: %v", compress, err)
+		}
+		if compression != expected {
+			t.Fatalf("Expected compression %q to be %s, got %s", compress, expected.Extension(), compression.Extension())
+		}
+	}
+	if _, err := parseExportCompression("bzip2"); err == nil {
+		t.Fatal("Expected an error for an unsupported compression")
+	}
+}
@@ -28,6 +28,7 @@ type Graph struct {
 	Root    string
 	idIndex *truncindex.TruncIndex
 	driver  graphdriver.Driver
+	layers  *layerIndex
 }
 
 // NewGraph instantiates a new graph at the given root path in the filesystem.
@@ -46,6 +47,7 @@ func NewGraph(root string, driver graphdriver.Driver) (*Graph, error) {
 		Root:    abspath,
 		idIndex: truncindex.NewTruncIndex([]string{}),
 		driver:  driver,
+		layers:  newLayerIndex(),
 	}
 	if err := graph.restore(); err != nil {
 		return nil, err
@@ -61,7 +63,12 @@ func (graph *Graph) restore() error {
 	var ids = []string{}
 	for _, v := range dir {
 		id := v.Name()
-		if graph.driver.Exists(id) {
+		exists, err := graph.restoreLayer(id)
+		if err != nil {
+			log.Errorf("Failed to restore the layer of image %s: %v", id, err)
+			continue
+		}
+		if exists {
 			ids = append(ids, id)
 		}
 	}
@@ -101,7 +108,15 @@ func (graph *Graph) Get(name string) (*image.Image, error) {
 	img.SetGraph(graph)
 
 	if img.Size < 0 {
-		size, err := graph.driver.DiffSize(img.ID, img.Parent)
+		parentLayerID := ""
+		if img.Parent != "" {
+			parent, err := graph.Get(img.Parent)
+			if err != nil {
+				return nil, err
+			}
+			parentLayerID = parent.LayerID()
+		}
+		size, err := graph.driver.DiffSize(img.LayerID(), parentLayerID)
 		if err != nil {
 			return nil, fmt.Errorf("unable to calculate size of image id %q: %s", img.ID, err)
 		}
@@ -114,10 +129,10 @@ func (graph *Graph) Get(name string) (*image.Image, error) {
 	return img, nil
 }
 
-// Create creates a new image and registers it in the graph.
+// Create creates a new image and registers it in the graph, with its
+// content addressable ID.
 func (graph *Graph) Create(layerData archive.ArchiveReader, containerID, containerImage, comment, author string, containerConfig, config *runconfig.Config) (*image.Image, error) {
 	img := &image.Image{
-		ID:            common.GenerateRandomID(),
 		Comment:       comment,
 		Created:       time.Now().UTC(),
 		DockerVersion: dockerversion.VERSION,
@@ -139,50 +154,83 @@ func (graph *Graph) Create(layerData archive.ArchiveReader, containerID, contain
 	return img, nil
 }
 
-// Register imports a pre-existing image into the graph.
+// Register imports a pre-existing image into the graph. The layer of the
+// image is shared with the images with the same content. An image without
+// an ID is given its content addressable ID once its layer is applied, it
+// is left as is when an image with the same ID, and so the same content, is
+// already registered.
 func (graph *Graph) Register(img *image.Image, layerData archive.ArchiveReader) (err error) {
+	layerID := common.GenerateRandomID()
 	defer func() {
 		// If any error occurs, remove the new dir from the driver.
 		// Don't check for errors since the dir might not have been created.
 		// FIXME: this leaves a possible race condition.
 		if err != nil {
-			graph.driver.Remove(img.ID)
+			graph.driver.Remove(layerID)
 		}
 	}()
-	if err := utils.ValidateID(img.ID); err != nil {
-		return err
-	}
-	// (This is a convenience to save time. Race conditions are taken care of by os.Rename)
-	if graph.Exists(img.ID) {
-		return fmt.Errorf("Image %s already exists", img.ID)
-	}
-
-	// Ensure that the image root does not exist on the filesystem
-	// when it is not registered in the graph.
-	// This is common when you switch from one graph driver to another
-	if err := os.RemoveAll(graph.ImageRoot(img.ID)); err != nil && !os.IsNotExist(err) {
-		return err
+	if img.ID != "" {
+		if err := graph.checkNewImage(img.ID); err != nil {
+			return err
+		}
 	}
 
-	// If the driver has this ID but the graph doesn't, remove it from the driver to start fresh.
-	// (the graph is the source of truth).
-	// Ignore errors, since we don't know if the driver correctly returns ErrNotExist.
-	// (FIXME: make that mandatory for drivers).
-	graph.driver.Remove(img.ID)
-
 	tmp, err := graph.Mktemp("")
 	defer os.RemoveAll(tmp)
 	if err != nil {
 		return fmt.Errorf("Mktemp failed: %s", err)
 	}
 
+	var parent *image.Image
+	parentLayerID := ""
+	if img.Parent != "" {
+		if parent, err = graph.Get(img.Parent); err != nil {
+			return err
+		}
+		parentLayerID = parent.LayerID()
+	}
+
 	// Create root filesystem in the driver
-	if err := graph.driver.Create(img.ID, img.Parent); err != nil {
+	if err := graph.driver.Create(layerID, parentLayerID); err != nil {
 		return fmt.Errorf("Driver %s failed to create image rootfs %s: %s", graph.driver, img.ID, err)
 	}
 	// Apply the diff/layer
+	size, diffID, err := graph.applyLayer(layerID, parentLayerID, layerData)
+	if err != nil {
+		return err
+	}
+	img.Size = size
+	if img.ID == "" {
+		if img.ID, err = image.ContentID(img, diffID); err != nil {
+			return err
+		}
+		if existing, err := graph.Get(img.ID); err == nil {
+			log.Debugf("Image %s is already registered", img.ID)
+			graph.driver.Remove(layerID)
+			*img = *existing
+			return nil
+		}
+		if err := graph.checkNewImage(img.ID); err != nil {
+			return err
+		}
+	}
+	chain := chainID(parent, diffID)
+
+	// Share the layer of the images with the same content
+	sharedLayerID := graph.layers.add(layerID, chain)
+	defer func() {
+		if err != nil && graph.layers.release(sharedLayerID) && sharedLayerID != layerID {
+			graph.driver.Remove(sharedLayerID)
+		}
+	}()
+	if sharedLayerID != layerID {
+		log.Debugf("Image %s shares the layer %s of chain %s", img.ID, sharedLayerID, chain)
+		graph.driver.Remove(layerID)
+	}
+
 	img.SetGraph(graph)
-	if err := image.StoreImage(img, layerData, tmp); err != nil {
+	img.SetLayer(sharedLayerID, diffID, chain)
+	if err := image.StoreImage(img, tmp); err != nil {
 		return err
 	}
 	// Commit
@@ -193,6 +241,34 @@ func (graph *Graph) Register(img *image.Image, layerData archive.ArchiveReader)
 	return nil
 }
 
+// checkNewImage checks that the image id can be registered, it cleans up
+// what is left of an image with the same ID which isn't registered
+func (graph *Graph) checkNewImage(id string) error {
+	if err := utils.ValidateID(id); err != nil {
+		return err
+	}
+	// (This is a convenience to save time. Race conditions are taken care of by os.Rename)
+	if graph.Exists(id) {
+		return fmt.Errorf("Image %s already exists", id)
+	}
+
+	// Ensure that the image root does not exist on the filesystem
+	// when it is not registered in the graph.
+	// This is common when you switch from one graph driver to another
+	if err := os.RemoveAll(graph.ImageRoot(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// If the driver has this ID but the graph doesn't, remove it from the driver to start fresh.
+	// (the graph is the source of truth).
+	// Ignore errors, since we don't know if the driver correctly returns ErrNotExist.
+	// (FIXME: make that mandatory for drivers).
+	if !graph.layers.inUse(id) {
+		graph.driver.Remove(id)
+	}
+	return nil
+}
+
 // TempLayerArchive creates a temporary archive of the given image's filesystem layer.
 //   The archive is stored on disk and will be automatically deleted as soon as has been read.
 //   If output is not nil, a human-readable progress bar will be written to it.
@@ -232,6 +308,26 @@ func (graph *Graph) newTempFile() (*os.File, error) {
 	return ioutil.TempFile(tmp, "")
 }
 
+// partialBlob opens the file of the blob of the image id downloaded by the
+// pulls, it returns the file and the length downloaded so far, which is
+// kept when a pull is interrupted.
+func (graph *Graph) partialBlob(id string) (*os.File, int64, error) {
+	dir := path.Join(graph.Root, "_partial")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, 0, err
+	}
+	f, err := os.OpenFile(path.Join(dir, id), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, 0, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, fi.Size(), nil
+}
+
 func bufferToFile(f *os.File, src io.Reader) (int64, error) {
 	n, err := io.Copy(f, src)
 	if err != nil {
@@ -318,12 +414,17 @@ func isNotEmpty(err error) bool {
 	return strings.Contains(err.Error(), " not empty")
 }
 
-// Delete atomically removes an image from the graph.
+// Delete atomically removes an image from the graph. The layer of the
+// image is removed from the driver once no image shares it.
 func (graph *Graph) Delete(name string) error {
 	id, err := graph.idIndex.Get(name)
 	if err != nil {
 		return err
 	}
+	layerID := id
+	if img, err := graph.Get(id); err == nil {
+		layerID = img.LayerID()
+	}
 	tmp, err := graph.Mktemp("")
 	graph.idIndex.Delete(id)
 	if err == nil {
@@ -338,11 +439,19 @@ func (graph *Graph) Delete(name string) error {
 		tmp = graph.ImageRoot(id)
 	}
 	// Remove rootfs data from the driver
-	graph.driver.Remove(id)
+	if graph.layers.release(layerID) {
+		graph.driver.Remove(layerID)
+	}
 	// Remove the trashed image directory
 	return os.RemoveAll(tmp)
 }
 
+// LayerInUse returns whether an image of the graph still uses the layer
+// layerID, which is shared by the images with the same content
+func (graph *Graph) LayerInUse(layerID string) bool {
+	return graph.layers.inUse(layerID)
+}
+
 // Map returns a list of all images in the graph, addressable by ID.
 func (graph *Graph) Map() (map[string]*image.Image, error) {
 	images := make(map[string]*image.Image)
@@ -3,6 +3,7 @@ package graph
 import (
 	"strings"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/image"
 )
@@ -44,3 +45,18 @@ func (s *TagStore) CmdHistory(job *engine.Job) engine.Status {
 	}
 	return engine.StatusOK
 }
+
+// imageLayers returns the layers of an image with their size, from the
+// image to its base layer
+func imageLayers(img *image.Image) ([]types.ImageLayer, error) {
+	layers := []types.ImageLayer{}
+	err := img.WalkHistory(func(img *image.Image) error {
+		layers = append(layers, types.ImageLayer{
+			ID:        img.ID,
+			Size:      img.Size,
+			CreatedBy: strings.Join(img.ContainerConfig.Cmd, " "),
+		})
+		return nil
+	})
+	return layers, err
+}
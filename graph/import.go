@@ -8,6 +8,7 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/engine"
+	"github.com/docker/docker/events"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/runconfig"
 	"github.com/docker/docker/utils"
@@ -81,7 +82,7 @@ func (s *TagStore) CmdImport(job *engine.Job) engine.Status {
 	if tag != "" {
 		logID += ":" + tag
 	}
-	if err = job.Eng.Job("log", "import", logID, "").Run(); err != nil {
+	if err = events.LogEvent(job.Eng, events.ImageEventType, "import", logID, "", map[string]string{"name": logID}); err != nil {
 		log.Errorf("Error logging event 'import' for %s: %s", logID, err)
 	}
 	return engine.StatusOK
@@ -0,0 +1,153 @@
+package graph
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/digest"
+)
+
+// The layers of the images are stored in the graph driver under IDs of
+// their own. Each layer is identified by its chain ID, the digest of the
+// content of the layer and of all its parents, and the images with the
+// same chain ID share a single layer, whatever their image ID. The images
+// registered before layers were shared keep a layer with their image ID
+// and no chain ID.
+
+// emptyTarDigest is the diff ID of the empty layers, the digest of an
+// empty tar archive
+var emptyTarDigest = digest.FromBytes(make([]byte, 1024))
+
+// layerIndex counts the images of each layer and finds the layers by
+// chain ID
+type layerIndex struct {
+	sync.Mutex
+	byChain map[digest.Digest]string
+	refs    map[string]int
+}
+
+func newLayerIndex() *layerIndex {
+	return &layerIndex{
+		byChain: make(map[digest.Digest]string),
+		refs:    make(map[string]int),
+	}
+}
+
+// add references the layer layerID with chain ID chainID from an image,
+// the layer already registered with chainID is referenced instead when
+// there is one, and it is returned.
+func (l *layerIndex) add(layerID string, chainID digest.Digest) string {
+	l.Lock()
+	defer l.Unlock()
+	if chainID != "" {
+		if existing, exists := l.byChain[chainID]; exists {
+			layerID = existing
+		} else {
+			l.byChain[chainID] = layerID
+		}
+	}
+	l.refs[layerID]++
+	return layerID
+}
+
+// release drops the reference of an image to the layer layerID, it
+// returns true when no image references the layer anymore.
+func (l *layerIndex) release(layerID string) bool {
+	l.Lock()
+	defer l.Unlock()
+	if l.refs[layerID] > 1 {
+		l.refs[layerID]--
+		return false
+	}
+	delete(l.refs, layerID)
+	for chainID, id := range l.byChain {
+		if id == layerID {
+			delete(l.byChain, chainID)
+		}
+	}
+	return true
+}
+
+// restore references the layer layerID from an image registered before
+// the daemon started
+func (l *layerIndex) restore(layerID string, chainID digest.Digest) {
+	l.Lock()
+	defer l.Unlock()
+	if _, exists := l.byChain[chainID]; chainID != "" && !exists {
+		l.byChain[chainID] = layerID
+	}
+	l.refs[layerID]++
+}
+
+func (l *layerIndex) inUse(layerID string) bool {
+	l.Lock()
+	defer l.Unlock()
+	return l.refs[layerID] > 0
+}
+
+// chainID returns the chain ID of a layer with diff ID diffID on the
+// parent layer, the chain ID of a base layer is its diff ID. It is empty
+// when the parent has no chain ID.
+func chainID(parent *image.Image, diffID digest.Digest) digest.Digest {
+	if parent == nil {
+		return diffID
+	}
+	if parent.ChainID() == "" {
+		return ""
+	}
+	return digest.FromBytes([]byte(parent.ChainID() + " " + diffID))
+}
+
+// applyLayer applies layerData to the layer layerID on the layer
+// parentLayerID, it returns the size of the layer and the digest of the
+// uncompressed layer data
+func (graph *Graph) applyLayer(layerID, parentLayerID string, layerData archive.ArchiveReader) (int64, digest.Digest, error) {
+	if layerData == nil {
+		return 0, emptyTarDigest, nil
+	}
+
+	decompressed, err := archive.DecompressStream(layerData)
+	if err != nil {
+		return 0, "", err
+	}
+	defer decompressed.Close()
+
+	digester := digest.NewDigester()
+	size, err := graph.driver.ApplyDiff(layerID, parentLayerID, io.TeeReader(decompressed, digester))
+	if err != nil {
+		return 0, "", err
+	}
+	// the tar archive may end before the end of the stream, which is part
+	// of the digest nonetheless
+	if _, err := io.Copy(digester, decompressed); err != nil {
+		return 0, "", err
+	}
+	return size, digester.Digest(), nil
+}
+
+// restoreLayer indexes the layer of the image id from its metadata, it
+// returns false when the layer isn't in the graph driver
+func (graph *Graph) restoreLayer(id string) (bool, error) {
+	root := graph.ImageRoot(id)
+	layerID, err := ioutil.ReadFile(path.Join(root, "layer"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return false, err
+		}
+		layerID = []byte(id)
+	}
+	chain, err := ioutil.ReadFile(path.Join(root, "chainid"))
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if !graph.driver.Exists(string(layerID)) {
+		return false, nil
+	}
+	graph.layers.restore(string(layerID), digest.Digest(chain))
+	return true, nil
+}
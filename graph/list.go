@@ -11,7 +11,18 @@ import (
 	"github.com/docker/docker/pkg/parsers/filters"
 )
 
-var acceptedImageFilterTags = map[string]struct{}{"dangling": {}}
+var acceptedImageFilterTags = map[string]struct{}{
+	"dangling": {},
+	"label":    {},
+}
+
+// imageLabels returns the labels of the config of an image
+func imageLabels(img *image.Image) map[string]string {
+	if img.Config == nil {
+		return nil
+	}
+	return img.Config.Labels
+}
 
 func (s *TagStore) CmdImages(job *engine.Job) engine.Status {
 	var (
@@ -20,6 +31,11 @@ func (s *TagStore) CmdImages(job *engine.Job) engine.Status {
 		filt_tagged = true
 	)
 
+	limit, offset := job.GetenvInt("limit"), job.GetenvInt("offset")
+	if limit < 0 || offset < 0 {
+		return job.Errorf("Invalid limit %d or offset %d", limit, offset)
+	}
+
 	imageFilters, err := filters.FromParam(job.Getenv("filters"))
 	if err != nil {
 		return job.Error(err)
@@ -68,10 +84,11 @@ func (s *TagStore) CmdImages(job *engine.Job) engine.Status {
 			} else {
 				// get the boolean list for if only the untagged images are requested
 				delete(allImages, id)
-				if filt_tagged {
+				if filt_tagged && imageFilters.MatchKVList("label", imageLabels(image)) {
 					out := &engine.Env{}
 					out.SetJson("ParentId", image.Parent)
 					out.SetList("RepoTags", []string{fmt.Sprintf("%s:%s", name, tag)})
+					out.SetList("RepoDigests", s.repoDigests(id))
 					out.SetJson("Id", image.ID)
 					out.SetInt64("Created", image.Created.Unix())
 					out.SetInt64("Size", image.Size)
@@ -82,6 +99,17 @@ func (s *TagStore) CmdImages(job *engine.Job) engine.Status {
 
 		}
 	}
+	// the images referenced by digest aren't dangling
+	repoDigests := make(map[string][]string)
+	for id := range allImages {
+		if refs := s.repoDigests(id); len(refs) > 0 {
+			if !filt_tagged {
+				delete(allImages, id)
+				continue
+			}
+			repoDigests[id] = refs
+		}
+	}
 	s.Unlock()
 
 	outs := engine.NewTable("Created", len(lookup))
@@ -92,9 +120,13 @@ func (s *TagStore) CmdImages(job *engine.Job) engine.Status {
 	// Display images which aren't part of a repository/tag
 	if job.Getenv("filter") == "" {
 		for _, image := range allImages {
+			if !imageFilters.MatchKVList("label", imageLabels(image)) {
+				continue
+			}
 			out := &engine.Env{}
 			out.SetJson("ParentId", image.Parent)
 			out.SetList("RepoTags", []string{"<none>:<none>"})
+			out.SetList("RepoDigests", repoDigests[image.ID])
 			out.SetJson("Id", image.ID)
 			out.SetInt64("Created", image.Created.Unix())
 			out.SetInt64("Size", image.Size)
@@ -104,6 +136,8 @@ func (s *TagStore) CmdImages(job *engine.Job) engine.Status {
 	}
 
 	outs.ReverseSort()
+	outs.Paginate(offset, limit)
+	outs.Select(job.GetenvList("fields"))
 	if _, err := outs.WriteListTo(job.Stdout); err != nil {
 		return job.Error(err)
 	}
@@ -4,6 +4,8 @@ package graph
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
@@ -13,12 +15,22 @@ import (
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/chrootarchive"
+	"github.com/docker/docker/pkg/common"
+	"github.com/docker/docker/pkg/digest"
 	"github.com/docker/docker/utils"
+	"github.com/docker/libtrust"
 )
 
 // Loads a set of images into the repository. This is the complementary of ImageExport.
 // The input stream is an uncompressed tar ball containing images and metadata.
+// The images are verified against the manifest of the tar ball before any
+// of them is loaded, a tar ball which doesn't match its manifest is rejected.
+// With the verify env, the manifest is required and has to be signed with a
+// key the daemon trusts, its own key or one of the keys of the
+// trusted load keys file.
+// The progress of the load is written to the output.
 func (s *TagStore) CmdLoad(job *engine.Job) engine.Status {
+	sf := utils.NewStreamFormatter(job.GetenvBool("json"))
 	tmpImageDir, err := ioutil.TempDir("", "docker-import-")
 	if err != nil {
 		return job.Error(err)
@@ -51,9 +63,30 @@ func (s *TagStore) CmdLoad(job *engine.Job) engine.Status {
 		return job.Error(err)
 	}
 
+	manifest, signers, err := readArchiveManifest(repoDir)
+	if err != nil {
+		return job.Error(err)
+	}
+	if job.GetenvBool("verify") {
+		if manifest == nil {
+			return job.Errorf("The archive has no manifest, its images can't be verified")
+		}
+		if err := s.verifyManifestSigners(signers); err != nil {
+			return job.Error(err)
+		}
+	} else if manifest == nil {
+		log.Warnf("The archive has no manifest, its images can't be verified")
+		job.Stdout.Write(sf.FormatStatus("", "The archive has no manifest, its images can't be verified"))
+	}
+	if manifest != nil {
+		if err := verifyArchive(repoDir, dirs, manifest, job.Stdout, sf); err != nil {
+			return job.Error(err)
+		}
+	}
+
 	for _, d := range dirs {
 		if d.IsDir() {
-			if err := s.recursiveLoad(job.Eng, d.Name(), tmpImageDir); err != nil {
+			if err := s.recursiveLoad(job.Eng, d.Name(), tmpImageDir, job.Stdout, sf); err != nil {
 				return job.Error(err)
 			}
 		}
@@ -71,6 +104,7 @@ func (s *TagStore) CmdLoad(job *engine.Job) engine.Status {
 				if err := s.Set(imageName, tag, address, true); err != nil {
 					return job.Error(err)
 				}
+				job.Stdout.Write(sf.FormatStatus("", "Loaded image: %s:%s", imageName, tag))
 			}
 		}
 	} else if !os.IsNotExist(err) {
@@ -80,12 +114,15 @@ func (s *TagStore) CmdLoad(job *engine.Job) engine.Status {
 	return engine.StatusOK
 }
 
-func (s *TagStore) recursiveLoad(eng *engine.Engine, address, tmpImageDir string) error {
+func (s *TagStore) recursiveLoad(eng *engine.Engine, address, tmpImageDir string, out io.Writer, sf *utils.StreamFormatter) error {
 	if err := eng.Job("image_get", address).Run(); err != nil {
 		log.Debugf("Loading %s", address)
 
 		imageJson, err := ioutil.ReadFile(path.Join(tmpImageDir, "repo", address, "json"))
 		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("Image %s is neither in the archive nor loaded, it may have been excluded from the archive: load the archive it is in first", address)
+			}
 			log.Debugf("Error reading json", err)
 			return err
 		}
@@ -95,6 +132,11 @@ func (s *TagStore) recursiveLoad(eng *engine.Engine, address, tmpImageDir string
 			log.Debugf("Error reading embedded tar", err)
 			return err
 		}
+		defer layer.Close()
+		layerInfo, err := layer.Stat()
+		if err != nil {
+			return err
+		}
 		img, err := image.NewImgJSON(imageJson)
 		if err != nil {
 			log.Debugf("Error unmarshalling json", err)
@@ -120,16 +162,134 @@ func (s *TagStore) recursiveLoad(eng *engine.Engine, address, tmpImageDir string
 
 		if img.Parent != "" {
 			if !s.graph.Exists(img.Parent) {
-				if err := s.recursiveLoad(eng, img.Parent, tmpImageDir); err != nil {
+				if err := s.recursiveLoad(eng, img.Parent, tmpImageDir, out, sf); err != nil {
 					return err
 				}
 			}
 		}
-		if err := s.graph.Register(img, layer); err != nil {
+		if err := s.graph.Register(img,
+			utils.ProgressReader(layer, int(layerInfo.Size()), out, sf, false, common.TruncateID(img.ID), "Loading layer")); err != nil {
 			return err
 		}
+		out.Write(sf.FormatProgress(common.TruncateID(img.ID), "Load complete", nil))
 	}
 	log.Debugf("Completed processing %s", address)
 
 	return nil
 }
+
+// readArchiveManifest reads the manifest of the tar ball of exported images
+// extracted in repoDir, and the keys it is signed with. It returns a nil
+// manifest when there is none, and no keys when it isn't signed.
+func readArchiveManifest(repoDir string) (*archiveManifest, []libtrust.PublicKey, error) {
+	manifestJSON, err := ioutil.ReadFile(path.Join(repoDir, archiveManifestFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	var signers []libtrust.PublicKey
+	if js, err := libtrust.ParsePrettySignature(manifestJSON, "signatures"); err == nil {
+		if signers, err = js.Verify(); err != nil {
+			return nil, nil, fmt.Errorf("Invalid signature of the manifest of the archive: %v", err)
+		}
+		if manifestJSON, err = js.Payload(); err != nil {
+			return nil, nil, err
+		}
+	}
+	var manifest archiveManifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("Invalid manifest of the archive: %v", err)
+	}
+	return &manifest, signers, nil
+}
+
+// verifyManifestSigners checks that the manifest of an archive is signed
+// with the key of the daemon or with a key of the trusted load keys file
+func (s *TagStore) verifyManifestSigners(signers []libtrust.PublicKey) error {
+	if len(signers) == 0 {
+		return fmt.Errorf("The manifest of the archive is not signed, its images can't be verified")
+	}
+	var trusted []libtrust.PublicKey
+	if s.trustKey != nil {
+		trusted = append(trusted, s.trustKey.PublicKey())
+	}
+	if _, err := os.Stat(s.trustedLoadKeys); err == nil {
+		keys, err := libtrust.LoadKeySetFile(s.trustedLoadKeys)
+		if err != nil {
+			return fmt.Errorf("Error loading the trusted load keys of %s: %v", s.trustedLoadKeys, err)
+		}
+		trusted = append(trusted, keys...)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	for _, signer := range signers {
+		for _, key := range trusted {
+			if signer.KeyID() == key.KeyID() {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("The manifest of the archive is signed with %s, which isn't a key trusted in %s", signers[0].KeyID(), s.trustedLoadKeys)
+}
+
+// verifyArchive checks the files of the images in repoDir against the
+// digests of the manifest of the tar ball
+func verifyArchive(repoDir string, dirs []os.FileInfo, manifest *archiveManifest, out io.Writer, sf *utils.StreamFormatter) error {
+	repositoriesJSON, err := ioutil.ReadFile(path.Join(repoDir, "repositories"))
+	if err == nil {
+		if dgst := digest.FromBytes(repositoriesJSON); dgst != manifest.Repositories {
+			return fmt.Errorf("The archive doesn't match its manifest: the repositories file has digest %s, its manifest expects %q", dgst, manifest.Repositories)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		id := d.Name()
+		expected, exists := manifest.Images[id]
+		if !exists {
+			return fmt.Errorf("The archive doesn't match its manifest: image %s is not in its manifest", id)
+		}
+
+		imageJSON, err := ioutil.ReadFile(path.Join(repoDir, id, "json"))
+		if err != nil {
+			return err
+		}
+		if dgst := digest.FromBytes(imageJSON); dgst != expected.JSON {
+			return fmt.Errorf("The archive doesn't match its manifest: the json of image %s has digest %s, its manifest expects %s", id, dgst, expected.JSON)
+		}
+		img, err := image.NewImgJSON(imageJSON)
+		if err != nil {
+			return err
+		}
+		if img.ID != id {
+			return fmt.Errorf("The archive doesn't match its manifest: the json of image %s has ID %s", id, img.ID)
+		}
+
+		layer, err := os.Open(path.Join(repoDir, id, "layer.tar"))
+		if err != nil {
+			return err
+		}
+		layerInfo, err := layer.Stat()
+		if err != nil {
+			layer.Close()
+			return err
+		}
+		verifier := digest.NewVerifier(expected.Layer)
+		_, err = io.Copy(verifier, utils.ProgressReader(layer, int(layerInfo.Size()), out, sf, false, common.TruncateID(id), "Verifying"))
+		layer.Close()
+		if err != nil {
+			return err
+		}
+		if !verifier.Verified() {
+			return fmt.Errorf("The archive doesn't match its manifest: the layer of image %s doesn't have the digest %s of its manifest", id, expected.Layer)
+		}
+		out.Write(sf.FormatProgress(common.TruncateID(id), "Verified", nil))
+	}
+	return nil
+}
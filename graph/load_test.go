@@ -0,0 +1,156 @@
+// +build linux
+
+package graph
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/pkg/digest"
+	"github.com/docker/docker/utils"
+	"github.com/docker/libtrust"
+)
+
+func mkTestArchive(t *testing.T, repoDir, id string, layer []byte) archiveImage {
+	imageJSON := []byte(`{"id":"` + id + `"}`)
+	if err := os.Mkdir(path.Join(repoDir, id), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(repoDir, id, "json"), imageJSON, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(repoDir, id, "layer.tar"), layer, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return archiveImage{JSON: digest.FromBytes(imageJSON), Layer: digest.FromBytes(layer)}
+}
+
+func TestVerifyArchive(t *testing.T) {
+	repoDir, err := ioutil.TempDir("", "docker-test-verify-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repoDir)
+	sf := utils.NewStreamFormatter(false)
+
+	manifest := &archiveManifest{Images: map[string]archiveImage{
+		testOfficialImageID: mkTestArchive(t, repoDir, testOfficialImageID, []byte("layer content")),
+	}}
+	verify := func() error {
+		dirs, err := ioutil.ReadDir(repoDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return verifyArchive(repoDir, dirs, manifest, ioutil.Discard, sf)
+	}
+	if err := verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a layer which doesn't match the manifest
+	if err := ioutil.WriteFile(path.Join(repoDir, testOfficialImageID, "layer.tar"), []byte("corrupted content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify(); err == nil || !strings.Contains(err.Error(), "doesn't match its manifest") {
+		t.Fatalf("Expected the corrupted layer to be rejected, got %v", err)
+	}
+
+	// an image which isn't in the manifest
+	private := mkTestArchive(t, repoDir, testPrivateImageID, []byte("other layer content"))
+	if err := os.RemoveAll(path.Join(repoDir, testOfficialImageID)); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify(); err == nil || !strings.Contains(err.Error(), "not in its manifest") {
+		t.Fatalf("Expected the image missing from the manifest to be rejected, got %v", err)
+	}
+	manifest.Images[testPrivateImageID] = private
+	if err := verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a repositories file which isn't in the manifest
+	if err := ioutil.WriteFile(path.Join(repoDir, "repositories"), []byte(`{"myapp":{"latest":"`+testPrivateImageID+`"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify(); err == nil {
+		t.Fatal("Expected the repositories file missing from the manifest to be rejected")
+	}
+}
+
+func TestArchiveManifestSignature(t *testing.T) {
+	repoDir, err := ioutil.TempDir("", "docker-test-manifest-signature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repoDir)
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := &archiveManifest{Images: map[string]archiveImage{
+		testOfficialImageID: mkTestArchive(t, repoDir, testOfficialImageID, []byte("layer content")),
+	}}
+	writeManifest := func(key libtrust.PrivateKey) []byte {
+		manifestJSON, err := signArchiveManifest(manifest, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path.Join(repoDir, archiveManifestFile), manifestJSON, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return manifestJSON
+	}
+	store := &TagStore{trustKey: key, trustedLoadKeys: path.Join(repoDir, "load_keys.json")}
+
+	manifestJSON := writeManifest(key)
+	read, signers, err := readArchiveManifest(repoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Images[testOfficialImageID] != manifest.Images[testOfficialImageID] {
+		t.Fatalf("Expected the signed manifest to be read, got %+v", read)
+	}
+	if err := store.verifyManifestSigners(signers); err != nil {
+		t.Fatal(err)
+	}
+
+	// a manifest rewritten without its signature
+	tampered := strings.Replace(string(manifestJSON), string(manifest.Images[testOfficialImageID].Layer), string(digest.FromBytes([]byte("tampered"))), 1)
+	if err := ioutil.WriteFile(path.Join(repoDir, archiveManifestFile), []byte(tampered), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := readArchiveManifest(repoDir); err == nil {
+		t.Fatal("Expected the manifest which doesn't match its signature to be rejected")
+	}
+
+	// a manifest signed again with another key
+	writeManifest(otherKey)
+	if _, signers, err = readArchiveManifest(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.verifyManifestSigners(signers); err == nil {
+		t.Fatal("Expected the manifest signed with an untrusted key to be rejected")
+	}
+	if err := libtrust.AddKeySetFile(store.trustedLoadKeys, otherKey.PublicKey()); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.verifyManifestSigners(signers); err != nil {
+		t.Fatalf("Expected the manifest signed with a trusted load key to be accepted, got %v", err)
+	}
+
+	// an unsigned manifest
+	writeManifest(nil)
+	if _, signers, err = readArchiveManifest(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.verifyManifestSigners(signers); err == nil {
+		t.Fatal("Expected the unsigned manifest to be rejected")
+	}
+}
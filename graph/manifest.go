@@ -9,6 +9,7 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/digest"
 	"github.com/docker/docker/pkg/tarsum"
 	"github.com/docker/docker/registry"
 	"github.com/docker/docker/runconfig"
@@ -171,6 +172,34 @@ func (s *TagStore) loadManifest(eng *engine.Engine, manifestBytes []byte) (*regi
 	return &manifest, verified, nil
 }
 
+// digestManifest returns the digest of a signed manifest, the digest of its
+// payload without the signatures, which is the digest the registry gives
+// to the manifest.
+func digestManifest(manifestBytes []byte) (digest.Digest, error) {
+	sig, err := libtrust.ParsePrettySignature(manifestBytes, "signatures")
+	if err != nil {
+		return "", fmt.Errorf("error parsing payload: %s", err)
+	}
+	payload, err := sig.Payload()
+	if err != nil {
+		return "", fmt.Errorf("error retrieving payload: %s", err)
+	}
+	return digest.FromBytes(payload), nil
+}
+
+// verifyManifestDigest checks that the manifest has the digest reported by
+// the registry, when the registry reported one, and returns its digest.
+func verifyManifestDigest(manifestBytes []byte, remoteDigest string) (digest.Digest, error) {
+	dgst, err := digestManifest(manifestBytes)
+	if err != nil {
+		return "", err
+	}
+	if remoteDigest != "" && dgst.String() != remoteDigest {
+		return "", fmt.Errorf("manifest digest mismatch: the registry reported %s but the manifest has digest %s", remoteDigest, dgst)
+	}
+	return dgst, nil
+}
+
 func checkValidManifest(manifest *registry.ManifestData) error {
 	if len(manifest.FSLayers) != len(manifest.History) {
 		return fmt.Errorf("length of history not equal to number of layers")
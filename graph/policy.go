@@ -0,0 +1,132 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/docker/docker/pkg/digest"
+	"github.com/docker/docker/registry"
+	"github.com/docker/docker/trust"
+	"github.com/docker/libtrust"
+)
+
+const (
+	// TrustPolicyNone lets the daemon pull images by tag or by digest
+	TrustPolicyNone = "none"
+	// TrustPolicySigned lets the daemon pull images by digest only, when
+	// the digest is signed for the repository on the trust server with the
+	// key the daemon trusts for the repository
+	TrustPolicySigned = "signed"
+)
+
+// DefaultTrustedKeysDir has the public keys the daemon trusts for the
+// repositories with the signed policy, in <repository>.json files
+const DefaultTrustedKeysDir = "/etc/docker/trust/trusted_keys"
+
+// trustPolicy verifies the digests pulled by the daemon against the signed
+// targets of their repository
+type trustPolicy struct {
+	server  string
+	keysDir string
+
+	// versions are the last versions of the targets verified for the
+	// repositories, kept in versionsPath so that older targets, even
+	// validly signed, are refused after a restart too
+	mu           sync.Mutex
+	versionsPath string
+	versions     map[string]int
+}
+
+// SetContentTrustPolicy sets the images the pulls are allowed to pull,
+// policy is TrustPolicyNone or TrustPolicySigned. The signed policy
+// verifies the digests with the targets on the trust server, signed with
+// the keys of keysDir, and keeps the versions of the targets it verified in
+// versionsPath.
+func (store *TagStore) SetContentTrustPolicy(policy, server, keysDir, versionsPath string) error {
+	switch policy {
+	case TrustPolicyNone:
+		store.trustPolicy = nil
+	case TrustPolicySigned:
+		if server == "" {
+			return fmt.Errorf("The %s content trust policy requires a trust server", policy)
+		}
+		p := &trustPolicy{
+			server:       server,
+			keysDir:      keysDir,
+			versionsPath: versionsPath,
+			versions:     make(map[string]int),
+		}
+		jsonData, err := ioutil.ReadFile(versionsPath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		} else if err == nil {
+			if err := json.Unmarshal(jsonData, &p.versions); err != nil {
+				return fmt.Errorf("Error reading the trust data versions in %s: %v", versionsPath, err)
+			}
+		}
+		store.trustPolicy = p
+	default:
+		return fmt.Errorf("Invalid content trust policy %q, the policies are %s and %s", policy, TrustPolicyNone, TrustPolicySigned)
+	}
+	return nil
+}
+
+// verify checks that dgst is the digest of a tag of repository in the
+// targets signed with the key trusted for the repository. The targets older
+// than the last verified for the repository are refused, so that the trust
+// server can't roll a tag back to a digest it was signed for before.
+func (p *trustPolicy) verify(repository string, dgst digest.Digest, authConfig *registry.AuthConfig) error {
+	keyFile := filepath.Join(p.keysDir, filepath.FromSlash(repository)+".json")
+	key, err := libtrust.LoadPublicKeyFile(keyFile)
+	if err == libtrust.ErrKeyFileDoesNotExist {
+		return fmt.Errorf("The content trust policy of the daemon only pulls signed images, there is no trusted key for %s in %s", repository, keyFile)
+	} else if err != nil {
+		return err
+	}
+	signed, err := trust.FetchTargets(p.server, repository, authConfig.Username, authConfig.Password)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	targets, _, err := trust.VerifyTargets(signed, repository, key, p.versions[repository])
+	if err != nil {
+		return err
+	}
+	if targets.Version > p.versions[repository] {
+		if err := p.saveVersion(repository, targets.Version); err != nil {
+			return err
+		}
+	}
+	for _, signedDigest := range targets.Tags {
+		if signedDigest == dgst {
+			return nil
+		}
+	}
+	return fmt.Errorf("The content trust policy of the daemon only pulls signed images, %s@%s is not signed", repository, dgst)
+}
+
+// saveVersion records version as the last verified version of the targets
+// of repository, p.mu has to be held
+func (p *trustPolicy) saveVersion(repository string, version int) error {
+	previous, ok := p.versions[repository]
+	p.versions[repository] = version
+	jsonData, err := json.Marshal(p.versions)
+	if err == nil {
+		err = ioutil.WriteFile(p.versionsPath, jsonData, 0600)
+	}
+	if err != nil {
+		if ok {
+			p.versions[repository] = previous
+		} else {
+			delete(p.versions, repository)
+		}
+		return fmt.Errorf("Error saving the trust data version of %s: %v", repository, err)
+	}
+	return nil
+}
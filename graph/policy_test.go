@@ -0,0 +1,102 @@
+package graph
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/pkg/digest"
+	"github.com/docker/docker/registry"
+	"github.com/docker/docker/trust"
+	"github.com/docker/libtrust"
+)
+
+func TestTrustPolicyVerify(t *testing.T) {
+	repository := "docker.io/foo/bar"
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedDigest := digest.FromBytes([]byte("signed manifest"))
+	targets := trust.NewTargets(repository)
+	targets.Tags["latest"] = signedDigest
+	oldSigned, err := trust.SignTargets(targets, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := trust.SignTargets(targets, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/"+repository+"/_trust/targets.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(signed)
+	}))
+	defer server.Close()
+
+	keysDir, err := ioutil.TempDir("", "trusted-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(keysDir)
+	versionsPath := filepath.Join(keysDir, "versions.json")
+	store := &TagStore{}
+	if err := store.SetContentTrustPolicy(TrustPolicySigned, "", keysDir, versionsPath); err == nil {
+		t.Fatal("Expected the signed policy to require a trust server")
+	}
+	if err := store.SetContentTrustPolicy(TrustPolicySigned, server.URL, keysDir, versionsPath); err != nil {
+		t.Fatal(err)
+	}
+	authConfig := &registry.AuthConfig{}
+
+	if err := store.trustPolicy.verify(repository, signedDigest, authConfig); err == nil || !strings.Contains(err.Error(), "no trusted key") {
+		t.Fatalf("Expected the digest to be refused without a trusted key, got %v", err)
+	}
+
+	keyFile := filepath.Join(keysDir, "docker.io", "foo", "bar.json")
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := libtrust.SavePublicKey(keyFile, otherKey.PublicKey()); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.trustPolicy.verify(repository, signedDigest, authConfig); err != trust.ErrTargetsKeyMismatch {
+		t.Fatalf("Expected the targets signed with another key to be refused, got %v", err)
+	}
+
+	if err := libtrust.SavePublicKey(keyFile, key.PublicKey()); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.trustPolicy.verify(repository, signedDigest, authConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.trustPolicy.verify(repository, digest.FromBytes([]byte("other manifest")), authConfig); err == nil {
+		t.Fatal("Expected a digest which isn't signed to be refused")
+	}
+	if err := store.trustPolicy.verify("docker.io/foo/unsigned", signedDigest, authConfig); err == nil {
+		t.Fatal("Expected the digest of another repository to be refused")
+	}
+
+	// the trust server replays older targets, validly signed
+	signed = oldSigned
+	if err := store.trustPolicy.verify(repository, signedDigest, authConfig); err == nil || !strings.Contains(err.Error(), "older than the last verified") {
+		t.Fatalf("Expected older targets to be refused, got %v", err)
+	}
+	if err := store.SetContentTrustPolicy(TrustPolicySigned, server.URL, keysDir, versionsPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.trustPolicy.verify(repository, signedDigest, authConfig); err == nil || !strings.Contains(err.Error(), "older than the last verified") {
+		t.Fatalf("Expected older targets to be refused after a restart, got %v", err)
+	}
+}
@@ -12,9 +12,13 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/engine"
+	"github.com/docker/docker/events"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/common"
+	"github.com/docker/docker/pkg/digest"
+	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/tarsum"
+	"github.com/docker/docker/pkg/units"
 	"github.com/docker/docker/registry"
 	"github.com/docker/docker/utils"
 )
@@ -70,10 +74,22 @@ func (s *TagStore) CmdPull(job *engine.Job) engine.Status {
 
 	logName := repoInfo.LocalName
 	if tag != "" {
-		logName += ":" + tag
+		logName = parsers.JoinRepositoryTag(logName, tag)
+	}
+	dgst, err := digest.ParseDigest(tag)
+	byDigest := err == nil
+	if s.trustPolicy != nil {
+		if !byDigest {
+			return job.Errorf("The content trust policy of the daemon only pulls signed images by digest, pull %s with DOCKER_CONTENT_TRUST=1 or by digest", logName)
+		}
+		if err := s.trustPolicy.verify(repoInfo.CanonicalName, dgst, authConfig); err != nil {
+			return job.Error(err)
+		}
 	}
 
-	if len(repoInfo.Index.Mirrors) == 0 && ((repoInfo.Official && repoInfo.Index.Official) || endpoint.Version == registry.APIVersion2) {
+	// the mirrors only serve the v1 API, the pulls by digest go to the
+	// registry itself
+	if (len(repoInfo.Index.Mirrors) == 0 || byDigest) && ((repoInfo.Official && repoInfo.Index.Official) || endpoint.Version == registry.APIVersion2) {
 		if repoInfo.Official {
 			j := job.Eng.Job("trust_update_base")
 			if err = j.Run(); err != nil {
@@ -83,10 +99,13 @@ func (s *TagStore) CmdPull(job *engine.Job) engine.Status {
 
 		log.Debugf("pulling v2 repository with local name %q", repoInfo.LocalName)
 		if err := s.pullV2Repository(job.Eng, r, job.Stdout, repoInfo, tag, sf, job.GetenvBool("parallel")); err == nil {
-			if err = job.Eng.Job("log", "pull", logName, "").Run(); err != nil {
+			if err = events.LogEvent(job.Eng, events.ImageEventType, "pull", logName, "", map[string]string{"name": logName}); err != nil {
 				log.Errorf("Error logging event 'pull' for %s: %s", logName, err)
 			}
 			return engine.StatusOK
+		} else if byDigest {
+			// the v1 registries have no digests to fall back to
+			return job.Error(err)
 		} else if err != registry.ErrDoesNotExist && err != ErrV2RegistryUnavailable {
 			log.Errorf("Error from V2 registry: %s", err)
 		}
@@ -94,19 +113,23 @@ func (s *TagStore) CmdPull(job *engine.Job) engine.Status {
 		log.Debug("image does not exist on v2 registry, falling back to v1")
 	}
 
+	if byDigest {
+		return job.Errorf("Pulling %s by digest requires a v2 registry", logName)
+	}
+
 	log.Debugf("pulling v1 repository with local name %q", repoInfo.LocalName)
-	if err = s.pullRepository(r, job.Stdout, repoInfo, tag, sf, job.GetenvBool("parallel")); err != nil {
+	if err = s.pullRepository(job.Eng, r, job.Stdout, repoInfo, tag, sf, job.GetenvBool("parallel")); err != nil {
 		return job.Error(err)
 	}
 
-	if err = job.Eng.Job("log", "pull", logName, "").Run(); err != nil {
+	if err = events.LogEvent(job.Eng, events.ImageEventType, "pull", logName, "", map[string]string{"name": logName}); err != nil {
 		log.Errorf("Error logging event 'pull' for %s: %s", logName, err)
 	}
 
 	return engine.StatusOK
 }
 
-func (s *TagStore) pullRepository(r *registry.Session, out io.Writer, repoInfo *registry.RepositoryInfo, askedTag string, sf *utils.StreamFormatter, parallel bool) error {
+func (s *TagStore) pullRepository(eng *engine.Engine, r *registry.Session, out io.Writer, repoInfo *registry.RepositoryInfo, askedTag string, sf *utils.StreamFormatter, parallel bool) error {
 	out.Write(sf.FormatStatus("", "Pulling repository %s", repoInfo.CanonicalName))
 
 	repoData, err := r.GetRepositoryData(repoInfo.RemoteName)
@@ -189,10 +212,19 @@ func (s *TagStore) pullRepository(r *registry.Session, out io.Writer, repoInfo *
 			var lastErr, err error
 			var is_downloaded bool
 			for _, ep := range repoInfo.Index.Mirrors {
+				// skip the mirrors found unhealthy since the pull started
+				if err := eng.Job("registry_mirror_healthy", ep).Run(); err != nil {
+					log.Debugf("Skipping mirror %s: %s", ep, err)
+					continue
+				}
 				out.Write(sf.FormatProgress(common.TruncateID(img.ID), fmt.Sprintf("Pulling image (%s) from %s, mirror: %s", img.Tag, repoInfo.CanonicalName, ep), nil))
 				if is_downloaded, err = s.pullImage(r, out, img.ID, ep, repoData.Tokens, sf); err != nil {
 					// Don't report errors when pulling from mirrors.
 					log.Debugf("Error pulling image (%s) from %s, mirror: %s, %s", img.Tag, repoInfo.CanonicalName, ep, err)
+					// probe the mirror, the next images skip it when it is down
+					if err := eng.Job("registry_mirror_failed", ep).Run(); err != nil {
+						log.Debugf("%s", err)
+					}
 					continue
 				}
 				layers_downloaded = layers_downloaded || is_downloaded
@@ -315,6 +347,7 @@ func (s *TagStore) pullImage(r *registry.Session, out io.Writer, imgID, endpoint
 				}
 			}
 
+			s.downloads.acquire(id, out, sf)
 			for j := 1; j <= retries; j++ {
 				// Get the layer
 				status := "Pulling fs layer"
@@ -330,30 +363,82 @@ func (s *TagStore) pullImage(r *registry.Session, out io.Writer, imgID, endpoint
 					time.Sleep(time.Duration(j) * 500 * time.Millisecond)
 					continue
 				} else if err != nil {
+					s.downloads.release()
 					out.Write(sf.FormatProgress(common.TruncateID(id), "Error pulling dependent layers", nil))
 					return layers_downloaded, err
 				}
 				layers_downloaded = true
-				defer layer.Close()
 
 				err = s.graph.Register(img,
 					utils.ProgressReader(layer, imgSize, out, sf, false, common.TruncateID(id), "Downloading"))
+				layer.Close()
 				if terr, ok := err.(net.Error); ok && terr.Timeout() && j < retries {
 					time.Sleep(time.Duration(j) * 500 * time.Millisecond)
 					continue
 				} else if err != nil {
+					s.downloads.release()
 					out.Write(sf.FormatProgress(common.TruncateID(id), "Error downloading dependent layers", nil))
 					return layers_downloaded, err
 				} else {
 					break
 				}
 			}
+			s.downloads.release()
 		}
 		out.Write(sf.FormatProgress(common.TruncateID(id), "Download complete", nil))
 	}
 	return layers_downloaded, nil
 }
 
+// downloadV2Blob downloads the blob of the image id to f, from the offset
+// bytes already in f. The download resumes with a range request when it is
+// interrupted. It returns the length of the blob.
+func (s *TagStore) downloadV2Blob(r *registry.Session, endpoint *registry.Endpoint, remoteName, sumType, sum string, f *os.File, offset int64, id string, out io.Writer, sf *utils.StreamFormatter, auth *registry.RequestAuthorization) (int64, error) {
+	retries := 5
+	for j := 1; ; j++ {
+		if offset > 0 {
+			out.Write(sf.FormatProgress(common.TruncateID(id), fmt.Sprintf("Resuming download at %s", units.HumanSize(float64(offset))), nil))
+		}
+		body, start, length, err := r.GetV2ImageBlobReader(endpoint, remoteName, sumType, sum, offset, auth)
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		if terr, ok := err.(net.Error); ok && j < retries {
+			log.Debugf("Error pulling blob %s:%s, retrying: %s", sumType, sum, terr)
+			time.Sleep(time.Duration(j) * 500 * time.Millisecond)
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+
+		// the registry sends the whole blob when it doesn't support range
+		// requests
+		if err := f.Truncate(start); err != nil {
+			body.Close()
+			return 0, err
+		}
+		if _, err := f.Seek(start, 0); err != nil {
+			body.Close()
+			return 0, err
+		}
+		progress := utils.ProgressReader(body, int(length-start), out, sf, false, common.TruncateID(id), "Downloading")
+		n, err := io.Copy(f, progress)
+		body.Close()
+		offset = start + n
+		if err == nil && offset != length {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil {
+			return length, nil
+		}
+		if j == retries {
+			return 0, fmt.Errorf("unable to copy v2 image blob data: %s", err)
+		}
+		log.Debugf("Pull of blob %s:%s interrupted at %d bytes, resuming: %s", sumType, sum, offset, err)
+		time.Sleep(time.Duration(j) * 500 * time.Millisecond)
+	}
+}
+
 func WriteStatus(requestedTag string, out io.Writer, sf *utils.StreamFormatter, layers_downloaded bool) {
 	if layers_downloaded {
 		out.Write(sf.FormatStatus("", "Status: Downloaded newer image for %s", requestedTag))
@@ -412,7 +497,7 @@ func (s *TagStore) pullV2Repository(eng *engine.Engine, r *registry.Session, out
 
 	requestedTag := repoInfo.CanonicalName
 	if len(tag) > 0 {
-		requestedTag = repoInfo.CanonicalName + ":" + tag
+		requestedTag = parsers.JoinRepositoryTag(repoInfo.CanonicalName, tag)
 	}
 	WriteStatus(requestedTag, out, sf, layersDownloaded)
 	return nil
@@ -420,11 +505,22 @@ func (s *TagStore) pullV2Repository(eng *engine.Engine, r *registry.Session, out
 
 func (s *TagStore) pullV2Tag(eng *engine.Engine, r *registry.Session, out io.Writer, endpoint *registry.Endpoint, repoInfo *registry.RepositoryInfo, tag string, sf *utils.StreamFormatter, parallel bool, auth *registry.RequestAuthorization) (bool, error) {
 	log.Debugf("Pulling tag from V2 registry: %q", tag)
-	manifestBytes, err := r.GetV2ImageManifest(endpoint, repoInfo.RemoteName, tag, auth)
+	manifestBytes, remoteDigest, err := r.GetV2ImageManifest(endpoint, repoInfo.RemoteName, tag, auth)
 	if err != nil {
 		return false, err
 	}
 
+	manifestDigest, err := verifyManifestDigest(manifestBytes, remoteDigest)
+	if err != nil {
+		return false, err
+	}
+	// a pull by digest has the digest of the manifest as tag
+	pullDigest, err := digest.ParseDigest(tag)
+	byDigest := err == nil
+	if byDigest && manifestDigest != pullDigest {
+		return false, fmt.Errorf("manifest digest mismatch: pulled %s but the manifest has digest %s", pullDigest, manifestDigest)
+	}
+
 	manifest, verified, err := s.loadManifest(eng, manifestBytes)
 	if err != nil {
 		return false, fmt.Errorf("error verifying manifest: %s", err)
@@ -479,32 +575,42 @@ func (s *TagStore) pullV2Tag(eng *engine.Engine, r *registry.Session, out io.Wri
 				}
 			} else {
 				defer s.poolRemove("pull", "img:"+img.ID)
-				tmpFile, err := ioutil.TempFile("", "GetV2ImageBlob")
+				s.downloads.acquire(img.ID, out, sf)
+				defer s.downloads.release()
+				// the blob downloaded so far by an interrupted pull is kept
+				// and the download resumes from its end
+				tmpFile, offset, err := s.graph.partialBlob(img.ID)
 				if err != nil {
 					return err
 				}
 
-				r, l, err := r.GetV2ImageBlobReader(endpoint, repoInfo.RemoteName, sumType, checksum, auth)
+				l, err := s.downloadV2Blob(r, endpoint, repoInfo.RemoteName, sumType, checksum, tmpFile, offset, img.ID, out, sf, auth)
 				if err != nil {
+					tmpFile.Close()
 					return err
 				}
-				defer r.Close()
 
-				// Wrap the reader with the appropriate TarSum reader.
-				tarSumReader, err := tarsum.NewTarSumForLabel(r, true, sumType)
+				out.Write(sf.FormatProgress(common.TruncateID(img.ID), "Verifying Checksum", nil))
+
+				if _, err := tmpFile.Seek(0, 0); err != nil {
+					tmpFile.Close()
+					return err
+				}
+				tarSumReader, err := tarsum.NewTarSumForLabel(tmpFile, true, sumType)
 				if err != nil {
+					tmpFile.Close()
 					return fmt.Errorf("unable to wrap image blob reader with TarSum: %s", err)
 				}
-
-				if _, err := io.Copy(tmpFile, utils.ProgressReader(ioutil.NopCloser(tarSumReader), int(l), out, sf, false, common.TruncateID(img.ID), "Downloading")); err != nil {
-					return fmt.Errorf("unable to copy v2 image blob data: %s", err)
+				if _, err := io.Copy(ioutil.Discard, tarSumReader); err != nil {
+					tmpFile.Close()
+					return fmt.Errorf("unable to read the v2 image blob data: %s", err)
 				}
-
-				out.Write(sf.FormatProgress(common.TruncateID(img.ID), "Verifying Checksum", nil))
-
 				if finalChecksum := tarSumReader.Sum(nil); !strings.EqualFold(finalChecksum, sumStr) {
-					log.Infof("Image verification failed: checksum mismatch - expected %q but got %q", sumStr, finalChecksum)
-					verified = false
+					// the blob is corrupt, it is downloaded again from zero
+					// by the next pull
+					tmpFile.Close()
+					os.Remove(tmpFile.Name())
+					return fmt.Errorf("image verification failed for %s: checksum mismatch - expected %q but got %q", img.ID, sumStr, finalChecksum)
 				}
 
 				out.Write(sf.FormatProgress(common.TruncateID(img.ID), "Download complete", nil))
@@ -564,12 +670,24 @@ func (s *TagStore) pullV2Tag(eng *engine.Engine, r *registry.Session, out io.Wri
 	}
 
 	if verified && layersDownloaded {
-		out.Write(sf.FormatStatus(repoInfo.CanonicalName+":"+tag, "The image you are pulling has been verified. Important: image verification is a tech preview feature and should not be relied on to provide security."))
+		out.Write(sf.FormatStatus(parsers.JoinRepositoryTag(repoInfo.CanonicalName, tag), "The image you are pulling has been verified. Important: image verification is a tech preview feature and should not be relied on to provide security."))
 	}
 
-	if err = s.Set(repoInfo.LocalName, tag, downloads[0].img.ID, true); err != nil {
+	// an image pulled by digest is only referenced by digest, no tag is set
+	digestTag := ""
+	if !byDigest {
+		if err = s.Set(repoInfo.LocalName, tag, downloads[0].img.ID, true); err != nil {
+			return false, err
+		}
+		digestTag = tag
+	}
+	if err = s.SetDigest(repoInfo.LocalName, digestTag, manifestDigest, downloads[0].img.ID); err != nil {
 		return false, err
 	}
+	if err := s.addBlobSources(blobSums(manifest), repoInfo.Index.Name, repoInfo.RemoteName); err != nil {
+		log.Errorf("Error recording the sources of the layers of %s: %s", repoInfo.LocalName, err)
+	}
+	out.Write(sf.FormatStatus("", "Digest: %s", manifestDigest))
 
 	return layersDownloaded, nil
 }
@@ -259,7 +259,9 @@ func (s *TagStore) pushImage(r *registry.Session, out io.Writer, imgID, ep strin
 	// Send the layer
 	log.Debugf("rendered layer for %s of [%d] size", imgData.ID, layerData.Size)
 
+	s.uploads.acquire(imgData.ID, out, sf)
 	checksum, checksumPayload, err := r.PushImageLayerRegistry(imgData.ID, utils.ProgressReader(layerData, int(layerData.Size), out, sf, false, common.TruncateID(imgData.ID), "Pushing"), ep, token, jsonRaw)
+	s.uploads.release()
 	if err != nil {
 		return "", err
 	}
@@ -303,6 +305,8 @@ func (s *TagStore) pushV2Repository(r *registry.Session, eng *engine.Engine, out
 	if err != nil {
 		return fmt.Errorf("error getting authorization: %s", err)
 	}
+	// the authorizations to mount blobs, by source repository
+	mountAuths := make(map[string]*registry.RequestAuthorization)
 
 	for _, tag := range tags {
 		log.Debugf("Pushing %s:%s to v2 repository", repoInfo.LocalName, tag)
@@ -364,6 +368,9 @@ func (s *TagStore) pushV2Repository(r *registry.Session, eng *engine.Engine, out
 				return err
 			}
 
+			if !exists && s.mountV2Image(r, img, endpoint, repoInfo, sumParts[0], manifestSum, sf, out, auth, mountAuths) {
+				continue
+			}
 			if !exists {
 				if err := s.pushV2Image(r, img, endpoint, repoInfo.RemoteName, sumParts[0], manifestSum, sf, out, auth); err != nil {
 					return err
@@ -374,13 +381,52 @@ func (s *TagStore) pushV2Repository(r *registry.Session, eng *engine.Engine, out
 		}
 
 		// push the manifest
-		if err := r.PutV2ImageManifest(endpoint, repoInfo.RemoteName, tag, bytes.NewReader([]byte(manifestBytes)), auth); err != nil {
+		remoteDigest, err := r.PutV2ImageManifest(endpoint, repoInfo.RemoteName, tag, bytes.NewReader([]byte(manifestBytes)), auth)
+		if err != nil {
+			return err
+		}
+		manifestDigest, err := verifyManifestDigest(signedBody, remoteDigest)
+		if err != nil {
+			return err
+		}
+		img, err := image.NewImgJSON([]byte(manifest.History[0].V1Compatibility))
+		if err != nil {
+			return fmt.Errorf("Failed to parse json: %s", err)
+		}
+		if err := s.SetDigest(repoInfo.LocalName, tag, manifestDigest, img.ID); err != nil {
 			return err
 		}
+		if err := s.addBlobSources(blobSums(manifest), repoInfo.Index.Name, repoInfo.RemoteName); err != nil {
+			log.Errorf("Error recording the sources of the layers of %s:%s: %s", repoInfo.LocalName, tag, err)
+		}
+		out.Write(sf.FormatStatus("", "%s: digest: %s", tag, manifestDigest))
 	}
 	return nil
 }
 
+// mountV2Image mounts the layer blob of img from another repository of the
+// registry it was pulled from or pushed to, instead of uploading it again.
+// It returns false when none of the repositories could mount it.
+func (s *TagStore) mountV2Image(r *registry.Session, img *image.Image, endpoint *registry.Endpoint, repoInfo *registry.RepositoryInfo, sumType, sumStr string, sf *utils.StreamFormatter, out io.Writer, auth *registry.RequestAuthorization, mountAuths map[string]*registry.RequestAuthorization) bool {
+	for _, fromRepo := range s.blobSources(sumType+":"+sumStr, repoInfo.Index.Name, repoInfo.RemoteName) {
+		mountAuth, exists := mountAuths[fromRepo]
+		if !exists {
+			mountAuth = auth.ForMount(fromRepo)
+			mountAuths[fromRepo] = mountAuth
+		}
+		mounted, err := r.MountV2ImageBlob(endpoint, repoInfo.RemoteName, sumType, sumStr, fromRepo, mountAuth)
+		if err != nil {
+			log.Debugf("Unable to mount %s:%s from %s: %s", sumType, sumStr, fromRepo, err)
+			continue
+		}
+		if mounted {
+			out.Write(sf.FormatProgress(common.TruncateID(img.ID), fmt.Sprintf("Mounted from %s", fromRepo), nil))
+			return true
+		}
+	}
+	return false
+}
+
 // PushV2Image pushes the image content to the v2 registry, first buffering the contents to disk
 func (s *TagStore) pushV2Image(r *registry.Session, img *image.Image, endpoint *registry.Endpoint, imageName, sumType, sumStr string, sf *utils.StreamFormatter, out io.Writer, auth *registry.RequestAuthorization) error {
 	out.Write(sf.FormatProgress(common.TruncateID(img.ID), "Buffering to Disk", nil))
@@ -412,6 +458,8 @@ func (s *TagStore) pushV2Image(r *registry.Session, img *image.Image, endpoint *
 	// Send the layer
 	log.Debugf("rendered layer for %s of [%d] size", img.ID, size)
 
+	s.uploads.acquire(img.ID, out, sf)
+	defer s.uploads.release()
 	if err := r.PutV2ImageBlob(endpoint, imageName, sumType, sumStr, utils.ProgressReader(tf, int(size), out, sf, false, common.TruncateID(img.ID), "Pushing"), auth); err != nil {
 		out.Write(sf.FormatProgress(common.TruncateID(img.ID), "Image push failed", nil))
 		return err
@@ -137,6 +137,7 @@ func (s *TagStore) CmdLookup(job *engine.Job) engine.Status {
 
 		out := &engine.Env{}
 		out.SetJson("Id", image.ID)
+		out.SetList("RepoDigests", s.RepoDigests(image.ID))
 		out.SetJson("Parent", image.Parent)
 		out.SetJson("Comment", image.Comment)
 		out.SetAuto("Created", image.Created)
@@ -149,6 +150,13 @@ func (s *TagStore) CmdLookup(job *engine.Job) engine.Status {
 		out.Set("Os", image.OS)
 		out.SetInt64("Size", image.Size)
 		out.SetInt64("VirtualSize", image.GetParentsSize(0)+image.Size)
+		if job.GetenvBool("size") {
+			layers, err := imageLayers(image)
+			if err != nil {
+				return job.Error(err)
+			}
+			out.SetJson("Layers", layers)
+		}
 		if _, err = out.WriteTo(job.Stdout); err != nil {
 			return job.Error(err)
 		}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/common"
+	"github.com/docker/docker/pkg/digest"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/registry"
 	"github.com/docker/libtrust"
@@ -20,6 +21,10 @@ import (
 
 const DEFAULTTAG = "latest"
 
+// DefaultTrustedLoadKeys is the file of the public keys of the daemons whose
+// exported archives are trusted by docker load --verify
+const DefaultTrustedLoadKeys = "/etc/docker/trust/load_keys.json"
+
 var (
 	validTagName = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
 )
@@ -28,12 +33,22 @@ type TagStore struct {
 	path         string
 	graph        *Graph
 	Repositories map[string]Repository
+	Digests      map[string]*RepositoryDigests `json:",omitempty"`
+	BlobSources  map[string][]BlobSource       `json:",omitempty"`
 	trustKey     libtrust.PrivateKey
+	// trustedLoadKeys is the file of the public keys trusted for the
+	// manifests of the loaded archives, with the key of the daemon
+	trustedLoadKeys string
 	sync.Mutex
 	// FIXME: move push/pull-related fields
 	// to a helper type
 	pullingPool map[string]chan struct{}
 	pushingPool map[string]chan struct{}
+	downloads   *transferLimiter
+	uploads     *transferLimiter
+	// trustPolicy verifies the pulled digests when the content trust
+	// policy of the daemon only allows signed images
+	trustPolicy *trustPolicy
 }
 
 type Repository map[string]string
@@ -63,12 +78,16 @@ func NewTagStore(path string, graph *Graph, key libtrust.PrivateKey) (*TagStore,
 	}
 
 	store := &TagStore{
-		path:         abspath,
-		graph:        graph,
-		trustKey:     key,
-		Repositories: make(map[string]Repository),
-		pullingPool:  make(map[string]chan struct{}),
-		pushingPool:  make(map[string]chan struct{}),
+		path:            abspath,
+		graph:           graph,
+		trustKey:        key,
+		trustedLoadKeys: DefaultTrustedLoadKeys,
+		Repositories:    make(map[string]Repository),
+		Digests:         make(map[string]*RepositoryDigests),
+		pullingPool:     make(map[string]chan struct{}),
+		pushingPool:     make(map[string]chan struct{}),
+		downloads:       newTransferLimiter(DefaultMaxConcurrentDownloads),
+		uploads:         newTransferLimiter(DefaultMaxConcurrentUploads),
 	}
 	// Load the json file if it exists, otherwise create it.
 	if err := store.reload(); os.IsNotExist(err) {
@@ -152,6 +171,9 @@ func (store *TagStore) ImageName(id string) string {
 }
 
 func (store *TagStore) DeleteAll(id string) error {
+	if err := store.deleteDigests(id); err != nil {
+		return err
+	}
 	names, exists := store.ByID()[id]
 	if !exists || len(names) == 0 {
 		return nil
@@ -171,7 +193,12 @@ func (store *TagStore) DeleteAll(id string) error {
 	return nil
 }
 
+// Delete deletes a tag of the repository, or its reference by digest when
+// tag is a digest, or the whole repository when tag is empty.
 func (store *TagStore) Delete(repoName, tag string) (bool, error) {
+	if dgst, err := digest.ParseDigest(tag); err == nil {
+		return store.deleteDigest(repoName, dgst)
+	}
 	store.Lock()
 	defer store.Unlock()
 	deleted := false
@@ -186,12 +213,16 @@ func (store *TagStore) Delete(repoName, tag string) (bool, error) {
 				if len(r) == 0 {
 					delete(store.Repositories, repoName)
 				}
+				if d, exists := store.Digests[repoName]; exists {
+					delete(d.Tags, tag)
+				}
 				deleted = true
 			} else {
 				return false, fmt.Errorf("No such tag: %s:%s", repoName, tag)
 			}
 		} else {
 			delete(store.Repositories, repoName)
+			delete(store.Digests, repoName)
 			deleted = true
 		}
 	} else {
@@ -247,7 +278,12 @@ func (store *TagStore) Get(repoName string) (Repository, error) {
 	return nil, nil
 }
 
+// GetImage returns the image of the repository with the tag, digest or
+// (truncated) ID tagOrID, nil when there is none.
 func (store *TagStore) GetImage(repoName, tagOrID string) (*image.Image, error) {
+	if dgst, err := digest.ParseDigest(tagOrID); err == nil {
+		return store.GetImageByDigest(repoName, dgst)
+	}
 	repo, err := store.Get(repoName)
 	store.Lock()
 	defer store.Unlock()
@@ -2,6 +2,7 @@ package graph
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"os"
 	"path"
@@ -10,6 +11,7 @@ import (
 	"github.com/docker/docker/daemon/graphdriver"
 	_ "github.com/docker/docker/daemon/graphdriver/vfs" // import the vfs driver so it is used in the tests
 	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/digest"
 	"github.com/docker/docker/utils"
 	"github.com/docker/docker/vendor/src/code.google.com/p/go/src/pkg/archive/tar"
 )
@@ -174,3 +176,198 @@ func TestInvalidTagName(t *testing.T) {
 		}
 	}
 }
+
+func TestSharedLayers(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	defer store.graph.driver.Cleanup()
+
+	official, err := store.graph.Get(testOfficialImageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := store.graph.Get(testPrivateImageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if official.ChainID() == "" || official.ChainID() != private.ChainID() {
+		t.Fatalf("Expected the images to have the same chain ID, got %q and %q", official.ChainID(), private.ChainID())
+	}
+	if official.LayerID() != private.LayerID() {
+		t.Fatalf("Expected the images to share a layer, got %s and %s", official.LayerID(), private.LayerID())
+	}
+
+	if err := store.graph.Delete(testOfficialImageID); err != nil {
+		t.Fatal(err)
+	}
+	if !store.graph.driver.Exists(private.LayerID()) || !store.graph.LayerInUse(private.LayerID()) {
+		t.Fatal("Expected the shared layer to be kept while an image uses it")
+	}
+	if err := store.graph.Delete(testPrivateImageID); err != nil {
+		t.Fatal(err)
+	}
+	if store.graph.driver.Exists(private.LayerID()) || store.graph.LayerInUse(private.LayerID()) {
+		t.Fatal("Expected the layer to be removed with its last image")
+	}
+}
+
+func TestContentAddressableIDs(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	defer store.graph.driver.Cleanup()
+
+	register := func(comment string) *image.Image {
+		layer, err := fakeTar()
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := &image.Image{Parent: testOfficialImageID, Comment: comment}
+		if err := store.graph.Register(img, layer); err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+	img := register("content addressable")
+	diffID, err := digest.FromReader(mustFakeTar(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := image.ContentID(&image.Image{Parent: testOfficialImageID, Comment: "content addressable"}, diffID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ID != expected {
+		t.Fatalf("Expected the image ID to be the digest of its content %s, got %s", expected, img.ID)
+	}
+	if !store.graph.Exists(img.ID) {
+		t.Fatalf("Expected image %s to be registered", img.ID)
+	}
+
+	// the same content is registered once
+	if same := register("content addressable"); same.ID != img.ID {
+		t.Fatalf("Expected the same content to have the ID %s, got %s", img.ID, same.ID)
+	}
+	if other := register("other comment"); other.ID == img.ID {
+		t.Fatal("Expected another configuration to have another ID")
+	}
+}
+
+func mustFakeTar(t *testing.T) io.Reader {
+	r, err := fakeTar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestDigests(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	defer store.graph.driver.Cleanup()
+
+	dgst := digest.FromBytes([]byte("manifest"))
+	if err := store.SetDigest("docker.io/"+testOfficialImageName, DEFAULTTAG, dgst, testOfficialImageID); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := store.GetDigest(testOfficialImageName, DEFAULTTAG); err != nil {
+		t.Fatal(err)
+	} else if d != dgst {
+		t.Fatalf("Expected digest %s, got %s", dgst, d)
+	}
+	if img, err := store.GetImageByDigest(testOfficialImageName, dgst); err != nil {
+		t.Fatal(err)
+	} else if img == nil || img.ID != testOfficialImageID {
+		t.Fatalf("Expected image %s for digest %s, got %v", testOfficialImageID, dgst, img)
+	}
+	for _, name := range []string{testOfficialImageName + "@" + dgst.String(), "docker.io/library/" + testOfficialImageName + "@" + dgst.String()} {
+		if img, err := store.LookupImage(name); err != nil {
+			t.Fatalf("Error looking up %s: %s", name, err)
+		} else if img.ID != testOfficialImageID {
+			t.Fatalf("Expected ID '%s' found '%s'", testOfficialImageID, img.ID)
+		}
+	}
+	if _, err := store.LookupImage(testPrivateImageName + "@" + dgst.String()); err == nil {
+		t.Fatal("Expected an error looking up a digest of another repository")
+	}
+	if refs := store.RepoDigests(testOfficialImageID); len(refs) != 1 || refs[0] != testOfficialImageName+"@"+dgst.String() {
+		t.Fatalf("Expected the image to be referenced as %s@%s, got %v", testOfficialImageName, dgst, refs)
+	}
+
+	if _, err := store.Delete(testOfficialImageName, dgst.String()); err != nil {
+		t.Fatal(err)
+	}
+	if refs := store.RepoDigests(testOfficialImageID); len(refs) != 0 {
+		t.Fatalf("Expected no reference by digest once deleted, got %v", refs)
+	}
+	if _, err := store.Delete(testOfficialImageName, dgst.String()); err == nil {
+		t.Fatal("Expected an error deleting a deleted digest")
+	}
+	if err := store.SetDigest(testOfficialImageName, DEFAULTTAG, dgst, testOfficialImageID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Delete(testOfficialImageName, DEFAULTTAG); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := store.GetDigest(testOfficialImageName, DEFAULTTAG); err != nil {
+		t.Fatal(err)
+	} else if d != "" {
+		t.Fatalf("Expected no digest for an untagged tag, got %s", d)
+	}
+
+	if err := store.DeleteAll(testOfficialImageID); err != nil {
+		t.Fatal(err)
+	}
+	if img, err := store.GetImageByDigest(testOfficialImageName, dgst); err != nil {
+		t.Fatal(err)
+	} else if img != nil {
+		t.Fatalf("Expected no image for digest %s once the image is deleted, got %s", dgst, img.ID)
+	}
+}
+
+func TestBlobSources(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	defer store.graph.driver.Cleanup()
+
+	sum := "tarsum.v1+sha256:1234"
+	for _, repo := range []string{"foo/base", "foo/app", "foo/base"} {
+		if err := store.addBlobSources([]string{sum}, "localhost:5000", repo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.addBlobSources([]string{sum}, "docker.io", "library/busybox"); err != nil {
+		t.Fatal(err)
+	}
+	if repos := store.blobSources(sum, "localhost:5000", "foo/other"); len(repos) != 2 || repos[0] != "foo/base" || repos[1] != "foo/app" {
+		t.Fatalf("Expected the blob to be mounted from foo/base then foo/app, got %v", repos)
+	}
+	if repos := store.blobSources(sum, "localhost:5000", "foo/app"); len(repos) != 1 || repos[0] != "foo/base" {
+		t.Fatalf("Expected the blob to be mounted from foo/base only, got %v", repos)
+	}
+
+	for i := 0; i < maxBlobSources; i++ {
+		if err := store.addBlobSources([]string{sum}, "localhost:5000", fmt.Sprintf("bar/%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if repos := store.blobSources(sum, "localhost:5000", ""); len(repos) != maxBlobSources || repos[0] != fmt.Sprintf("bar/%d", maxBlobSources-1) {
+		t.Fatalf("Expected the %d most recent sources, got %v", maxBlobSources, repos)
+	}
+}
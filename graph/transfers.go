@@ -0,0 +1,58 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/pkg/common"
+	"github.com/docker/docker/utils"
+)
+
+const (
+	// DefaultMaxConcurrentDownloads is the default number of layers
+	// downloaded at once by the pulls of the daemon
+	DefaultMaxConcurrentDownloads = 3
+	// DefaultMaxConcurrentUploads is the default number of layers uploaded
+	// at once by the pushes of the daemon
+	DefaultMaxConcurrentUploads = 5
+)
+
+// transferLimiter bounds the number of layers transferred at once, across
+// all the pulls or all the pushes of the daemon
+type transferLimiter struct {
+	slots chan struct{}
+}
+
+func newTransferLimiter(max int) *transferLimiter {
+	return &transferLimiter{slots: make(chan struct{}, max)}
+}
+
+// acquire blocks until the layer id can be transferred, the layer shows as
+// waiting in out meanwhile
+func (l *transferLimiter) acquire(id string, out io.Writer, sf *utils.StreamFormatter) {
+	select {
+	case l.slots <- struct{}{}:
+		return
+	default:
+	}
+	out.Write(sf.FormatProgress(common.TruncateID(id), "Waiting", nil))
+	l.slots <- struct{}{}
+}
+
+func (l *transferLimiter) release() {
+	<-l.slots
+}
+
+// SetMaxConcurrentTransfers sets the number of layers downloaded and
+// uploaded at once by the pulls and the pushes.
+func (store *TagStore) SetMaxConcurrentTransfers(downloads, uploads int) error {
+	if downloads < 1 {
+		return fmt.Errorf("Invalid maximum of concurrent downloads %d, it must be at least 1", downloads)
+	}
+	if uploads < 1 {
+		return fmt.Errorf("Invalid maximum of concurrent uploads %d, it must be at least 1", uploads)
+	}
+	store.downloads = newTransferLimiter(downloads)
+	store.uploads = newTransferLimiter(uploads)
+	return nil
+}
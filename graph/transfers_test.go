@@ -0,0 +1,48 @@
+package graph
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/utils"
+)
+
+func TestTransferLimiter(t *testing.T) {
+	var (
+		l   = newTransferLimiter(1)
+		sf  = utils.NewStreamFormatter(false)
+		out bytes.Buffer
+	)
+	l.acquire("first", &out, sf)
+	if out.Len() != 0 {
+		t.Fatalf("Expected the first transfer not to wait, got %q", out.String())
+	}
+
+	acquired := make(chan struct{})
+	var waitOut bytes.Buffer
+	go func() {
+		l.acquire("second", &waitOut, sf)
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Expected the second transfer to wait for the first one")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	l.release()
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the second transfer to start once the first one is done")
+	}
+	if !strings.Contains(waitOut.String(), "Waiting") {
+		t.Fatalf("Expected the second transfer to show as waiting, got %q", waitOut.String())
+	}
+
+	if err := (&TagStore{}).SetMaxConcurrentTransfers(0, 1); err == nil {
+		t.Fatal("Expected an error for 0 concurrent downloads")
+	}
+}
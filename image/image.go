@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/digest"
 	"github.com/docker/docker/runconfig"
 	"github.com/docker/docker/utils"
 )
@@ -34,6 +35,16 @@ type Image struct {
 	Size            int64
 
 	graph Graph
+
+	// layerID is the layer of the image in the graph driver, shared by the
+	// images with the same chainID, it is the ID of the image for the
+	// images registered before the layers were shared
+	layerID string
+	// diffID is the digest of the uncompressed tar of the layer
+	diffID digest.Digest
+	// chainID is the digest of the diffIDs of the layer and of its parents,
+	// it identifies the content of the filesystem of the image
+	chainID digest.Digest
 }
 
 func LoadImage(root string) (*Image, error) {
@@ -73,24 +84,54 @@ func LoadImage(root string) (*Image, error) {
 		img.Size = int64(size)
 	}
 
+	// The images registered before the layer store have none of these
+	if img.layerID, err = readOptionalFile(path.Join(root, "layer")); err != nil {
+		return nil, err
+	}
+	diffID, err := readOptionalFile(path.Join(root, "diffid"))
+	if err != nil {
+		return nil, err
+	}
+	chainID, err := readOptionalFile(path.Join(root, "chainid"))
+	if err != nil {
+		return nil, err
+	}
+	img.diffID, img.chainID = digest.Digest(diffID), digest.Digest(chainID)
+
 	return img, nil
 }
 
-// StoreImage stores file system layer data for the given image to the
-// image's registered storage driver. Image metadata is stored in a file
-// at the specified root directory.
-func StoreImage(img *Image, layerData archive.ArchiveReader, root string) (err error) {
-	// Store the layer. If layerData is not nil, unpack it into the new layer
-	if layerData != nil {
-		if img.Size, err = img.graph.Driver().ApplyDiff(img.ID, img.Parent, layerData); err != nil {
-			return err
+func readOptionalFile(filename string) (string, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
 		}
+		return "", err
 	}
+	return string(buf), nil
+}
 
+// StoreImage stores the metadata of the given image in the specified root
+// directory, the layer of the image is already in the graph driver.
+func StoreImage(img *Image, root string) error {
 	if err := img.SaveSize(root); err != nil {
 		return err
 	}
 
+	for filename, content := range map[string]string{
+		"layer":   img.layerID,
+		"diffid":  img.diffID.String(),
+		"chainid": img.chainID.String(),
+	} {
+		if content == "" {
+			continue
+		}
+		if err := ioutil.WriteFile(path.Join(root, filename), []byte(content), 0600); err != nil {
+			return fmt.Errorf("Error storing image %s in %s/%s: %s", filename, root, filename, err)
+		}
+	}
+
 	f, err := os.OpenFile(jsonPath(root), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(0600))
 	if err != nil {
 		return err
@@ -105,6 +146,47 @@ func (img *Image) SetGraph(graph Graph) {
 	img.graph = graph
 }
 
+// SetLayer sets the layer of the image in the graph driver, with the
+// digests of its content
+func (img *Image) SetLayer(layerID string, diffID, chainID digest.Digest) {
+	img.layerID, img.diffID, img.chainID = layerID, diffID, chainID
+}
+
+// LayerID returns the ID of the layer of the image in the graph driver
+func (img *Image) LayerID() string {
+	if img.layerID == "" {
+		return img.ID
+	}
+	return img.layerID
+}
+
+// DiffID returns the digest of the uncompressed tar of the layer, it is
+// empty for the images registered before the layer store
+func (img *Image) DiffID() digest.Digest {
+	return img.diffID
+}
+
+// ChainID returns the digest of the content of the layer and of its
+// parents, it is empty when the image or one of its parents was registered
+// before the layer store
+func (img *Image) ChainID() digest.Digest {
+	return img.chainID
+}
+
+// ContentID returns the content addressable ID of an image with a layer of
+// diff ID diffID, the hex of the digest of its configuration, which names
+// its parent, and of the digest of its layer. The images with the same
+// configuration and content have the same ID.
+func ContentID(img *Image, diffID digest.Digest) (string, error) {
+	config := *img
+	config.ID, config.Size = "", 0
+	configJSON, err := json.Marshal(&config)
+	if err != nil {
+		return "", err
+	}
+	return digest.FromBytes(append(append(configJSON, '\n'), diffID...)).Hex()
+}
+
 // SaveSize stores the current `size` value of `img` in the directory `root`.
 func (img *Image) SaveSize(root string) error {
 	if err := ioutil.WriteFile(path.Join(root, "layersize"), []byte(strconv.Itoa(int(img.Size))), 0600); err != nil {
@@ -155,9 +237,27 @@ func (img *Image) TarLayer() (arch archive.Archive, err error) {
 		return nil, fmt.Errorf("Can't load storage driver for unregistered image %s", img.ID)
 	}
 
+	parentLayerID, err := img.parentLayerID()
+	if err != nil {
+		return nil, err
+	}
+
 	driver := img.graph.Driver()
 
-	return driver.Diff(img.ID, img.Parent)
+	return driver.Diff(img.LayerID(), parentLayerID)
+}
+
+// parentLayerID returns the layer of the parent of the image, or "" for a
+// base image
+func (img *Image) parentLayerID() (string, error) {
+	if img.Parent == "" {
+		return "", nil
+	}
+	parent, err := img.GetParent()
+	if err != nil {
+		return "", err
+	}
+	return parent.LayerID(), nil
 }
 
 // Image includes convenience proxy functions to its graph
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"os/exec"
+	"strings"
 	"testing"
 	"time"
 
@@ -64,3 +65,55 @@ func TestGetContainersAttachWebsocket(t *testing.T) {
 
 	logDone("container attach websocket - can echo input via cat")
 }
+
+func TestGetContainersAttachWebsocketChannels(t *testing.T) {
+	out, _, _ := dockerCmd(t, "run", "-di", "busybox", "sh", "-c", "read line; printf '\\377' >&2; cat")
+	defer deleteAllContainers()
+	id := strings.TrimSpace(out)
+
+	rwc, err := sockConn(time.Duration(10 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := websocket.NewConfig(
+		"/containers/"+id+"/attach/ws?stream=1&stdin=1&stdout=1&stderr=1&channels=1&window=2",
+		"http://localhost",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := websocket.NewClient(config, rwc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	send := func(frame []byte) {
+		if err := websocket.Message.Send(ws, frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	receive := func(expected []byte) {
+		var frame []byte
+		ws.SetReadDeadline(time.Now().Add(10 * time.Second))
+		if err := websocket.Message.Receive(ws, &frame); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(frame, expected) {
+			t.Fatalf("Expected the frame %q, got %q", expected, frame)
+		}
+	}
+
+	// the output which is not valid UTF-8 is kept, in a frame of stderr
+	send([]byte("\x00go\n"))
+	receive([]byte{2, 0xff})
+
+	// the output is throttled to the window of 2 bytes until more credit
+	// is granted
+	send([]byte("\x00abc"))
+	receive([]byte("\x01a"))
+	send([]byte{3, 0, 0, 0, 8})
+	receive([]byte("\x01bc"))
+
+	logDone("container attach websocket - binary frames of separate streams with flow control")
+}
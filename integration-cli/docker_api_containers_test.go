@@ -51,6 +51,48 @@ func TestContainerApiGetAll(t *testing.T) {
 	logDone("container REST API - check GET json/all=1")
 }
 
+func TestContainerApiGetJSONPagination(t *testing.T) {
+	defer deleteAllContainers()
+	for _, name := range []string{"page1", "page2", "page3"} {
+		dockerCmd(t, "run", "--name", name, "busybox", "true")
+	}
+
+	body, err := sockRequest("GET", "/containers/json?all=1&limit=2&offset=1&fields=Names,Status", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var containers []map[string]interface{}
+	if err := json.Unmarshal(body, &containers); err != nil {
+		t.Fatal(err)
+	}
+	// the containers are listed from the most recent
+	if len(containers) != 2 {
+		t.Fatalf("Expected 2 containers, got %s", body)
+	}
+	for i, expected := range []string{"/page2", "/page1"} {
+		if names := containers[i]["Names"].([]interface{}); names[0] != expected {
+			t.Fatalf("Expected %s at position %d, got %s", expected, i, body)
+		}
+		if _, exists := containers[i]["Id"]; exists || len(containers[i]) != 2 {
+			t.Fatalf("Expected only the Names and Status fields, got %s", body)
+		}
+	}
+
+	// the pages only list the stopped containers with all
+	body, err = sockRequest("GET", "/containers/json?limit=2&offset=0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(body, &containers); err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 0 {
+		t.Fatalf("Expected no running container, got %s", body)
+	}
+
+	logDone("container REST API - GET json with a limit, an offset and fields")
+}
+
 func TestContainerApiGetExport(t *testing.T) {
 	defer deleteAllContainers()
 
@@ -320,6 +362,96 @@ func TestGetContainerStats(t *testing.T) {
 	logDone("container REST API - check GET containers/stats")
 }
 
+func TestGetContainerStatsNoStream(t *testing.T) {
+	defer deleteAllContainers()
+	name := "statscontainer"
+	runCmd := exec.Command(dockerBinary, "run", "-d", "--name", name, "busybox", "top")
+	if out, _, err := runCommandWithOutput(runCmd); err != nil {
+		t.Fatalf("Error on container creation: %v, output: %q", err, out)
+	}
+
+	// a one-shot request returns a single sample and closes the stream
+	body, err := sockRequest("GET", "/containers/"+name+"/stats?stream=0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(bytes.NewBuffer(body))
+	var s *types.Stats
+	if err := dec.Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s.PreCpuStats.SystemUsage == 0 {
+		t.Fatalf("Expected the previous cpu usage to be set, got %+v", s.PreCpuStats)
+	}
+	if _, ok := s.Networks["eth0"]; !ok {
+		t.Fatalf("Expected stats for eth0, got %v", s.Networks)
+	}
+	if s.PidsStats.Current == 0 {
+		t.Fatalf("Expected the pid count to be set, got %+v", s.PidsStats)
+	}
+	if err := dec.Decode(&s); err != io.EOF {
+		t.Fatalf("Expected a single sample, got %v", err)
+	}
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "stats", "--no-stream", name))
+	if err != nil {
+		t.Fatalf("Error running stats: %v, output: %q", err, out)
+	}
+	if !strings.Contains(out, "BLOCK I/O") || !strings.Contains(out, name) {
+		t.Fatalf("Unexpected stats output: %q", out)
+	}
+	logDone("container REST API - check GET containers/stats with stream=0")
+}
+
+func TestGetContainersStatsSamples(t *testing.T) {
+	defer deleteAllContainers()
+	names := []string{"statssample1", "statssample2"}
+	for _, name := range names {
+		runCmd := exec.Command(dockerBinary, "run", "-d", "--name", name, "busybox", "top")
+		if out, _, err := runCommandWithOutput(runCmd); err != nil {
+			t.Fatalf("Error on container creation: %v, output: %q", err, out)
+		}
+	}
+
+	body, err := sockRequest("GET", "/containers/stats?name=statssample1&name=statssample2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var samples []*types.StatsSample
+	if err := json.Unmarshal(body, &samples); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != len(names) {
+		t.Fatalf("Expected %d samples, got %d", len(names), len(samples))
+	}
+	for i, s := range samples {
+		if s.Name != names[i] || s.ID == "" {
+			t.Fatalf("Expected the sample of %s, got %+v", names[i], s)
+		}
+		if s.MemoryLimit == 0 || s.PidsCurrent == 0 {
+			t.Fatalf("Expected the memory limit and the pid count to be set, got %+v", s)
+		}
+	}
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "stats", "--no-stream", "--format", "{{.Name}} {{.PIDs}}", names[1], names[0]))
+	if err != nil {
+		t.Fatalf("Error running stats: %v, output: %q", err, out)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], names[0]+" ") || !strings.HasPrefix(lines[1], names[1]+" ") {
+		t.Fatalf("Unexpected formatted stats: %q", out)
+	}
+
+	out, _, err = runCommandWithOutput(exec.Command(dockerBinary, "stats", "--no-stream", "--format", "table {{.Name}}\t{{.CPUPerc}}", names[0]))
+	if err != nil {
+		t.Fatalf("Error running stats: %v, output: %q", err, out)
+	}
+	if !strings.HasPrefix(out, "NAME") || !strings.Contains(out, "CPU %") || !strings.Contains(out, names[0]) {
+		t.Fatalf("Unexpected stats table: %q", out)
+	}
+	logDone("container REST API - check GET containers/stats samples")
+}
+
 func TestBuildApiDockerfilePath(t *testing.T) {
 	// Test to make sure we stop people from trying to leave the
 	// build context when specifying the path to the dockerfile
@@ -4,8 +4,10 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os/exec"
+	"strings"
 	"testing"
 )
 
@@ -25,3 +27,45 @@ func TestExecApiCreateNoCmd(t *testing.T) {
 
 	logDone("exec create API - returns error when missing Cmd")
 }
+
+func TestExecApiInspectAfterContainerStop(t *testing.T) {
+	defer deleteAllContainers()
+	name := "exec_inspect"
+	runCmd := exec.Command(dockerBinary, "run", "-d", "--name", name, "busybox", "top")
+	if out, _, err := runCommandWithOutput(runCmd); err != nil {
+		t.Fatal(out, err)
+	}
+
+	body, err := sockRequest("POST", fmt.Sprintf("/containers/%s/exec", name), map[string]interface{}{"Cmd": []string{"sh", "-c", "exit 3"}})
+	if err != nil {
+		t.Fatal(string(body), err)
+	}
+	var created struct{ Id string }
+	if err := json.Unmarshal(body, &created); err != nil {
+		t.Fatal(err)
+	}
+	if body, err := sockRequest("POST", "/exec/"+created.Id+"/start", map[string]interface{}{"Detach": false}); err != nil {
+		t.Fatal(string(body), err)
+	}
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "stop", name)); err != nil {
+		t.Fatal(out, err)
+	}
+
+	body, err = sockRequest("GET", "/exec/"+created.Id+"/json", nil)
+	if err != nil {
+		t.Fatal(string(body), err)
+	}
+	var inspect struct {
+		Running  bool
+		ExitCode int
+		Pid      int
+	}
+	if err := json.Unmarshal(body, &inspect); err != nil {
+		t.Fatal(err)
+	}
+	if inspect.Running || inspect.ExitCode != 3 || inspect.Pid == 0 {
+		t.Fatalf("Unexpected exec state after the container stopped: %s", strings.TrimSpace(string(body)))
+	}
+
+	logDone("exec inspect API - reports the result after the container stopped")
+}
@@ -137,3 +137,56 @@ func TestAttachAfterDetach(t *testing.T) {
 
 	logDone("attach - reconnect after detaching")
 }
+
+func TestAttachDetachKeys(t *testing.T) {
+	defer deleteAllContainers()
+
+	name := "detachkeystest"
+
+	cpty, tty, err := pty.Open()
+	if err != nil {
+		t.Fatalf("Could not open pty: %v", err)
+	}
+	cmd := exec.Command(dockerBinary, "run", "-ti", "--detach-keys=ctrl-a,a", "--name", name, "busybox")
+	cmd.Stdin = tty
+	cmd.Stdout = tty
+	cmd.Stderr = tty
+
+	detached := make(chan error, 1)
+	go func() {
+		detached <- cmd.Run()
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+	if err := waitRun(name); err != nil {
+		t.Fatal(err)
+	}
+
+	// the default sequence is sent to the container
+	cpty.Write([]byte{16})
+	time.Sleep(100 * time.Millisecond)
+	cpty.Write([]byte{17})
+	select {
+	case <-detached:
+		t.Fatal("Detached with the default sequence")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	cpty.Write([]byte{1})
+	time.Sleep(100 * time.Millisecond)
+	cpty.Write([]byte("a"))
+	select {
+	case err := <-detached:
+		if err != nil {
+			t.Fatalf("run returned error %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Did not detach with the custom sequence")
+	}
+
+	if running, err := inspectField(name, "State.Running"); err != nil || running != "true" {
+		t.Fatalf("Expected the container to keep running after detaching: %s, %v", running, err)
+	}
+
+	logDone("attach - detach with a custom key sequence")
+}
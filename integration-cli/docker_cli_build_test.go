@@ -5,7 +5,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -20,6 +22,7 @@ import (
 
 	"github.com/docker/docker/builder/command"
 	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/utils"
 )
 
 func TestBuildJSONEmptyRun(t *testing.T) {
@@ -3128,6 +3131,56 @@ func TestBuildContextTarNoCompression(t *testing.T) {
 	testContextTar(t, archive.Uncompressed)
 }
 
+// testContextTarCmd builds from a context compressed with the command
+// compressor, for the formats archive.Tar can't write
+func testContextTarCmd(t *testing.T, compressor string) {
+	if _, err := exec.LookPath(compressor); err != nil {
+		t.Skipf("%s is not installed", compressor)
+	}
+	ctx, err := fakeContext(
+		`FROM busybox
+ADD foo /foo
+CMD ["cat", "/foo"]`,
+		map[string]string{
+			"foo": "bar",
+		},
+	)
+	defer ctx.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	context, err := archive.Tar(ctx.Dir, archive.Uncompressed)
+	if err != nil {
+		t.Fatalf("failed to build context tar: %v", err)
+	}
+	compressCmd := exec.Command(compressor, "-c", "-q")
+	compressCmd.Stdin = context
+	compressed, err := compressCmd.Output()
+	if err != nil {
+		t.Fatalf("failed to compress the context with %s: %v", compressor, err)
+	}
+	name := "contexttar"
+	buildCmd := exec.Command(dockerBinary, "build", "-t", name, "-")
+	defer deleteImages(name)
+	buildCmd.Stdin = bytes.NewReader(compressed)
+
+	if out, _, err := runCommandWithOutput(buildCmd); err != nil {
+		t.Fatalf("build failed to complete: %v %v", out, err)
+	}
+	if out, _, err := dockerCmd(t, "run", "--rm", name); out != "bar" || err != nil {
+		t.Fatalf("run produced invalid output: %q, expected %q", out, "bar")
+	}
+	logDone(fmt.Sprintf("build - build an image with a context tar, compression: %s", compressor))
+}
+
+func TestBuildContextTarXz(t *testing.T) {
+	testContextTarCmd(t, "xz")
+}
+
+func TestBuildContextTarZstd(t *testing.T) {
+	testContextTarCmd(t, "zstd")
+}
+
 func TestBuildNoContext(t *testing.T) {
 	buildCmd := exec.Command(dockerBinary, "build", "-t", "nocontext", "-")
 	buildCmd.Stdin = strings.NewReader("FROM busybox\nCMD echo ok\n")
@@ -4159,6 +4212,40 @@ func TestBuildFromGIT(t *testing.T) {
 	logDone("build - build from GIT")
 }
 
+func TestBuildFromGITWithContextSubdir(t *testing.T) {
+	name := "testbuildfromgitwithcontextsubdir"
+	defer deleteImages(name)
+	git, err := fakeGIT("repo", map[string]string{
+		"Dockerfile": `FROM busybox
+					RUN false`,
+		"sub/Dockerfile": `FROM busybox
+					ADD first /first
+					RUN [ -f /first ]
+					MAINTAINER docker`,
+		"sub/first": "test git data",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer git.Close()
+
+	if _, err := buildImageFromPath(name, git.RepoURL+"#:sub", true); err != nil {
+		t.Fatal(err)
+	}
+	res, err := inspectField(name, "Author")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "docker" {
+		t.Fatalf("Maintainer should be docker, got %s", res)
+	}
+
+	if _, err := buildImageFromPath(name, git.RepoURL+"#:sub/first", true); err == nil {
+		t.Fatal("Expected a file to be refused as git context")
+	}
+	logDone("build - build from GIT with a context subdirectory")
+}
+
 func TestBuildCleanupCmdOnEntrypoint(t *testing.T) {
 	name := "testbuildcmdcleanuponentrypoint"
 	defer deleteImages(name)
@@ -5227,3 +5314,638 @@ func TestBuildNotVerbose(t *testing.T) {
 
 	logDone("build - not verbose")
 }
+
+func TestBuildStopSignal(t *testing.T) {
+	name := "testbuildstopsignal"
+	defer deleteImages(name)
+	_, err := buildImage(name,
+		`FROM busybox
+		 STOPSIGNAL SIGKILL`,
+		true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := inspectField(name, "Config.StopSignal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "SIGKILL" {
+		t.Fatalf("StopSignal %s, expected SIGKILL", res)
+	}
+
+	_, err = buildImage(name+"invalid",
+		`FROM busybox
+		 STOPSIGNAL SIGFOO`,
+		true)
+	if err == nil {
+		t.Fatal("Expected build with an invalid STOPSIGNAL to fail")
+	}
+	logDone("build - stopsignal")
+}
+
+func TestBuildLabelAndHealthcheck(t *testing.T) {
+	name := "testbuildlabelhealthcheck"
+	defer deleteImages(name)
+	_, err := buildImage(name,
+		`FROM busybox
+		 LABEL com.example.vendor ACME
+		 LABEL version=1.0 "description=a web server"
+		 HEALTHCHECK --interval=5s --retries=2 CMD wget -q -O /dev/null http://localhost/`,
+		true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"Config.Labels":               "map[com.example.vendor:ACME description:a web server version:1.0]",
+		"Config.Healthcheck.Test":     "[CMD-SHELL wget -q -O /dev/null http://localhost/]",
+		"Config.Healthcheck.Interval": "5000000000",
+		"Config.Healthcheck.Retries":  "2",
+	}
+	for conf, value := range expected {
+		res, err := inspectField(name, conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != value {
+			t.Fatalf("%s('%s'), expected %s", conf, res, value)
+		}
+	}
+
+	for _, healthcheck := range []string{"HEALTHCHECK wget http://localhost/", "HEALTHCHECK --retries=0 CMD true", "HEALTHCHECK --size=1 CMD true"} {
+		if _, err := buildImage(name+"invalid", "FROM busybox\n"+healthcheck, true); err == nil {
+			t.Fatalf("Expected build with %q to fail", healthcheck)
+		}
+	}
+	logDone("build - label and healthcheck")
+}
+
+func TestBuildBuildArgs(t *testing.T) {
+	name := "testbuildbuildargs"
+	defer deleteImages(name)
+	dockerfile := `FROM busybox
+ARG version=1.0
+ARG user
+RUN echo "version=$version user=$user" > /args
+USER $user
+`
+	build := func(args ...string) string {
+		args = append(append([]string{"build", "-t", name}, args...), "-")
+		buildCmd := exec.Command(dockerBinary, args...)
+		buildCmd.Stdin = strings.NewReader(dockerfile)
+		out, exitCode, err := runCommandWithOutput(buildCmd)
+		if err != nil || exitCode != 0 {
+			t.Fatalf("failed to build the image: %s, %v", out, err)
+		}
+		return out
+	}
+
+	out := build("--build-arg", "user=daemon")
+	if strings.Contains(out, "Using cache") {
+		t.Fatalf("Expected the first build not to use the cache: %s", out)
+	}
+	if res, _ := inspectField(name, "Config.User"); res != "daemon" {
+		t.Fatalf("Expected the user to be substituted, got %s", res)
+	}
+	if res, _ := inspectFieldJSON(name, "Config.Env"); strings.Contains(res, "version") || strings.Contains(res, "user") {
+		t.Fatalf("Expected the build args not to be persisted, got %s", res)
+	}
+	if out, _, _ := dockerCmd(t, "run", "--rm", name, "cat", "/args"); strings.TrimSpace(out) != "version=1.0 user=daemon" {
+		t.Fatalf("Unexpected build args in RUN: %s", out)
+	}
+
+	// the same values use the cache, a different value only busts the cache
+	// from the RUN that consumes it
+	if out := build("--build-arg", "user=daemon"); strings.Count(out, "Using cache") != 4 {
+		t.Fatalf("Expected all the steps to use the cache: %s", out)
+	}
+	if out := build("--build-arg", "user=daemon", "--build-arg", "version=2.0"); strings.Count(out, "Using cache") != 2 {
+		t.Fatalf("Expected the ARG steps only to use the cache: %s", out)
+	}
+	if out, _, _ := dockerCmd(t, "run", "--rm", name, "cat", "/args"); strings.TrimSpace(out) != "version=2.0 user=daemon" {
+		t.Fatalf("Unexpected build args in RUN: %s", out)
+	}
+
+	// an undeclared proxy variable is not part of the cache key
+	if out := build("--build-arg", "user=daemon", "--build-arg", "version=2.0", "--build-arg", "http_proxy=http://proxy.example.com:3128"); strings.Count(out, "Using cache") != 4 {
+		t.Fatalf("Expected the proxy variable not to bust the cache: %s", out)
+	}
+	if out, _, _ := dockerCmd(t, "history", "--no-trunc", name); strings.Contains(out, "proxy.example.com") {
+		t.Fatalf("Expected the proxy variable not to be committed: %s", out)
+	}
+
+	buildCmd := exec.Command(dockerBinary, "build", "-t", name, "--build-arg", "unknown=1", "-")
+	buildCmd.Stdin = strings.NewReader(dockerfile)
+	if out, _, err := runCommandWithOutput(buildCmd); err == nil || !strings.Contains(out, "One or more build-args [unknown] were not consumed") {
+		t.Fatalf("Expected an undeclared build arg to fail the build: %s, %v", out, err)
+	}
+	logDone("build - build args")
+}
+
+func TestBuildMultiStage(t *testing.T) {
+	name := "testbuildmultistage"
+	defer deleteImages(name)
+	dockerfile := `FROM busybox AS builder
+RUN mkdir /out && echo built > /out/artifact && echo toolchain > /toolchain
+
+FROM busybox
+COPY --from=builder /out/artifact /artifact
+COPY --from=0 /out/ /copied/
+COPY --from=busybox /bin/true /true
+`
+	if _, err := buildImage(name, dockerfile, true); err != nil {
+		t.Fatal(err)
+	}
+
+	out, _, _ := dockerCmd(t, "run", "--rm", name, "cat", "/artifact", "/copied/artifact")
+	if out != "built\nbuilt\n" {
+		t.Fatalf("Expected the artifact to be copied from the first stage, got %q", out)
+	}
+	if _, _, err := dockerCmd(t, "run", "--rm", name, "ls", "/true"); err != nil {
+		t.Fatalf("Expected a file to be copied from an image: %v", err)
+	}
+	runCmd := exec.Command(dockerBinary, "run", "--rm", name, "ls", "/toolchain")
+	if out, _, err := runCommandWithOutput(runCmd); err == nil {
+		t.Fatalf("Expected the files of the first stage not to be in the image: %s", out)
+	}
+
+	// the cache is used for all the stages on a rebuild
+	_, out, err := buildImageWithOut(name, dockerfile, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out, "Using cache") != 4 {
+		t.Fatalf("Expected all the steps to use the cache: %s", out)
+	}
+
+	if _, err := buildImage(name+"invalid", `FROM busybox AS a
+FROM busybox AS a`, true); err == nil {
+		t.Fatal("Expected duplicate stage names to fail the build")
+	}
+	logDone("build - multi-stage")
+}
+
+func TestBuildParallelStages(t *testing.T) {
+	name := "testbuildparallelstages"
+	defer deleteImages(name)
+	dockerfile := `FROM busybox AS first
+ARG VALUE=first
+RUN echo $VALUE > /first
+
+FROM busybox AS second
+RUN echo "second $VALUE" > /second
+
+FROM busybox
+COPY --from=first /first /first
+COPY --from=second /second /second
+`
+	buildCmd := exec.Command(dockerBinary, "build", "-t", name, "--parallel=2", "-")
+	buildCmd.Stdin = strings.NewReader(dockerfile)
+	out, exitCode, err := runCommandWithOutput(buildCmd)
+	if err != nil || exitCode != 0 {
+		t.Fatalf("failed to build the image: %s, %v", out, err)
+	}
+	for _, prefix := range []string{"[first] Step 0 : FROM busybox AS first", "[second] Step 3 : FROM busybox AS second", "[2] Step 5 : FROM busybox"} {
+		if !strings.Contains(out, prefix) {
+			t.Fatalf("Expected the output of the stages to be prefixed with %q: %s", prefix, out)
+		}
+	}
+
+	// the ARG of the first stage is declared in the second one, as in a
+	// sequential build
+	out, _, _ = dockerCmd(t, "run", "--rm", name, "cat", "/first", "/second")
+	if out != "first\nsecond first\n" {
+		t.Fatalf("Expected the files of both stages, got %q", out)
+	}
+	logDone("build - parallel stages")
+}
+
+func TestBuildProgressJSON(t *testing.T) {
+	name := "testbuildprogressjson"
+	defer deleteImages(name)
+	dockerfile := `FROM busybox
+RUN echo hello > /hello
+`
+	build := func() []utils.JSONBuildStep {
+		buildCmd := exec.Command(dockerBinary, "build", "-t", name, "--progress=json", "-")
+		buildCmd.Stdin = strings.NewReader(dockerfile)
+		out, err := buildCmd.Output()
+		if err != nil {
+			t.Fatalf("failed to build the image: %s, %v", out, err)
+		}
+		steps := []utils.JSONBuildStep{}
+		dec := json.NewDecoder(bytes.NewReader(out))
+		for {
+			var jm utils.JSONMessage
+			if err := dec.Decode(&jm); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("Expected JSON messages, got %s: %v", out, err)
+			}
+			if jm.BuildStep != nil {
+				steps = append(steps, *jm.BuildStep)
+			}
+		}
+		if len(steps) != 2 {
+			t.Fatalf("Expected 2 build steps, got %s", out)
+		}
+		return steps
+	}
+
+	steps := build()
+	if steps[0].Step != 0 || steps[0].Instruction != "FROM busybox" || steps[1].Instruction != "RUN echo hello > /hello" {
+		t.Fatalf("Unexpected build steps %+v", steps)
+	}
+	if steps[1].Cached || steps[1].Size == 0 || steps[1].Duration <= 0 {
+		t.Fatalf("Expected the RUN step to produce a layer, got %+v", steps[1])
+	}
+	id, err := getIDByName(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if steps[1].Image != id {
+		t.Fatalf("Expected the last step to produce %s, got %+v", id, steps[1])
+	}
+
+	if steps = build(); !steps[1].Cached || steps[1].Image != id {
+		t.Fatalf("Expected the RUN step to use the cache, got %+v", steps[1])
+	}
+	logDone("build - JSON progress")
+}
+
+func TestBuildCacheFrom(t *testing.T) {
+	name := "testbuildcachefrom"
+	defer deleteImages(name, name+"2", name+"3")
+	dockerfile := `FROM busybox
+ENV FOO bar
+RUN echo cached > /file
+`
+	id, err := buildImage(name, dockerfile, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	build := func(tag string, args ...string) (string, string) {
+		args = append(append([]string{"build", "-t", tag}, args...), "-")
+		buildCmd := exec.Command(dockerBinary, args...)
+		buildCmd.Stdin = strings.NewReader(dockerfile)
+		out, exitCode, err := runCommandWithOutput(buildCmd)
+		if err != nil || exitCode != 0 {
+			t.Fatalf("failed to build the image: %s, %v", out, err)
+		}
+		newID, err := getIDByName(tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return newID, out
+	}
+
+	if newID, out := build(name+"2", "--cache-from", name); newID != id || strings.Count(out, "Using cache") != 2 {
+		t.Fatalf("Expected the layers of %s to be used as cache: %s", name, out)
+	}
+
+	// the local history is not used when only other images are cache sources
+	if newID, out := build(name+"3", "--cache-from", "busybox", "--cache-from", "doesnotexist"); newID == id || strings.Contains(out, "Using cache") {
+		t.Fatalf("Expected the local history not to be used as cache: %s", out)
+	}
+	logDone("build - cache from")
+}
+
+func TestBuildSquash(t *testing.T) {
+	name := "testbuildsquash"
+	defer deleteImages(name)
+	dockerfile := `FROM busybox
+RUN echo hello > /hello
+RUN echo world > /world && rm /hello
+ENV FOO bar
+`
+	buildCmd := exec.Command(dockerBinary, "build", "--squash", "-t", name, "-")
+	buildCmd.Stdin = strings.NewReader(dockerfile)
+	if out, _, err := runCommandWithOutput(buildCmd); err != nil {
+		t.Fatalf("failed to build the image: %s, %v", out, err)
+	}
+
+	parent, err := inspectField(name, "Parent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	busybox, err := inspectField("busybox", "Id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent != busybox {
+		t.Fatalf("Expected the squashed image to be on top of busybox, got the parent %s", parent)
+	}
+	if res, _ := inspectFieldJSON(name, "Config.Env"); !strings.Contains(res, "FOO=bar") {
+		t.Fatalf("Expected the config of the build to be kept, got %s", res)
+	}
+	out, _, _ := dockerCmd(t, "run", "--rm", name, "sh", "-c", "cat /world; ls /hello")
+	if !strings.Contains(out, "world") || strings.Contains(out, "/hello\n") {
+		t.Fatalf("Unexpected content of the squashed image: %s", out)
+	}
+
+	// the layers of the build are still used as cache
+	_, out, err = buildImageWithOut(name, dockerfile, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out, "Using cache") != 3 {
+		t.Fatalf("Expected all the steps to use the cache: %s", out)
+	}
+	logDone("build - squash")
+}
+
+func TestBuildSecrets(t *testing.T) {
+	name := "testbuildsecrets"
+	defer deleteImages(name)
+	secret, err := ioutil.TempFile("", "docker-build-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(secret.Name())
+	secret.WriteString("s3cr3t")
+	secret.Close()
+
+	dockerfile := `FROM busybox
+RUN --mount=type=secret,id=token [ "$(cat /run/secrets/token)" = "s3cr3t" ]
+RUN --mount=type=secret,id=token,target=/tmp/token [ "$(cat /tmp/token)" = "s3cr3t" ]
+`
+	buildCmd := exec.Command(dockerBinary, "build", "-t", name, "--secret", "id=token,src="+secret.Name(), "-")
+	buildCmd.Stdin = strings.NewReader(dockerfile)
+	if out, _, err := runCommandWithOutput(buildCmd); err != nil {
+		t.Fatalf("failed to build the image: %s, %v", out, err)
+	}
+
+	runCmd := exec.Command(dockerBinary, "run", "--rm", name, "ls", "/run/secrets", "/tmp/token")
+	if out, _, err := runCommandWithOutput(runCmd); err == nil {
+		t.Fatalf("Expected the secrets not to be committed: %s", out)
+	}
+	out, _, err := dockerCmd(t, "history", "--no-trunc", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "s3cr3t") {
+		t.Fatalf("Expected the secret not to be in the history: %s", out)
+	}
+
+	buildCmd = exec.Command(dockerBinary, "build", "-t", name+"missing", "-")
+	buildCmd.Stdin = strings.NewReader(dockerfile)
+	if out, _, err := runCommandWithOutput(buildCmd); err == nil || !strings.Contains(out, "Secret token was not given") {
+		t.Fatalf("Expected a build without the secret to fail: %s, %v", out, err)
+	}
+	logDone("build - secrets")
+}
+
+func TestBuildSSHAgent(t *testing.T) {
+	name := "testbuildsshagent"
+	defer deleteImages(name)
+	dir, err := ioutil.TempDir("", "docker-build-ssh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	dockerfile := `FROM busybox
+RUN --mount=type=ssh [ "$SSH_AUTH_SOCK" = "/run/ssh-agent/default.sock" ] && [ -S "$SSH_AUTH_SOCK" ]
+RUN [ -z "$SSH_AUTH_SOCK" ]
+`
+	buildCmd := exec.Command(dockerBinary, "build", "-t", name, "--ssh", "default="+socket, "-")
+	buildCmd.Stdin = strings.NewReader(dockerfile)
+	if out, _, err := runCommandWithOutput(buildCmd); err != nil {
+		t.Fatalf("failed to build the image: %s, %v", out, err)
+	}
+
+	runCmd := exec.Command(dockerBinary, "run", "--rm", name, "ls", "/run/ssh-agent")
+	if out, _, err := runCommandWithOutput(runCmd); err == nil {
+		t.Fatalf("Expected the agent socket not to be committed: %s", out)
+	}
+
+	buildCmd = exec.Command(dockerBinary, "build", "-t", name, "--ssh", "default="+filepath.Join(dir, "missing.sock"), "-")
+	buildCmd.Stdin = strings.NewReader(dockerfile)
+	if out, _, err := runCommandWithOutput(buildCmd); err == nil || !strings.Contains(out, "is not a socket") {
+		t.Fatalf("Expected a missing agent socket to fail the build: %s, %v", out, err)
+	}
+	logDone("build - ssh agent")
+}
+
+func TestBuildCacheMount(t *testing.T) {
+	name := "testbuildcachemount"
+	defer deleteImages(name)
+	id := fmt.Sprintf("testbuildcachemount%d", time.Now().UnixNano())
+	dockerfile := fmt.Sprintf(`FROM busybox
+RUN --mount=type=cache,id=%s,target=/var/cache/app echo run >> /var/cache/app/log && cp /var/cache/app/log /log
+`, id)
+
+	for i := 0; i < 2; i++ {
+		if _, err := buildImage(name, dockerfile, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, _, _ := dockerCmd(t, "run", "--rm", name, "cat", "/log")
+	if out != "run\nrun\n" {
+		t.Fatalf("Expected the cache to be kept across the builds, got %q", out)
+	}
+	runCmd := exec.Command(dockerBinary, "run", "--rm", name, "ls", "/var/cache/app")
+	if out, _, err := runCommandWithOutput(runCmd); err == nil {
+		t.Fatalf("Expected the cache mountpoint not to be committed: %s", out)
+	}
+
+	if _, err := buildImage(name, "FROM busybox\nRUN --mount=type=cache true", false); err == nil {
+		t.Fatal("Expected a cache mount without target to fail the build")
+	}
+	logDone("build - cache mount")
+}
+
+func TestBuildNetworkMode(t *testing.T) {
+	testRequires(t, NativeExecDriver)
+	name := "testbuildnetworkmode"
+	defer deleteImages(name)
+
+	buildCmd := exec.Command(dockerBinary, "build", "--network", "none", "-t", name, "-")
+	buildCmd.Stdin = strings.NewReader(`FROM busybox
+RUN [ "$(ls /sys/class/net)" = "lo" ]`)
+	if out, _, err := runCommandWithOutput(buildCmd); err != nil {
+		t.Fatalf("Expected the RUN container to have no network: %s, %v", out, err)
+	}
+
+	buildCmd = exec.Command(dockerBinary, "build", "--network", "doesnotexist", "-t", name, "-")
+	buildCmd.Stdin = strings.NewReader(`FROM busybox
+RUN true`)
+	if out, _, err := runCommandWithOutput(buildCmd); err == nil {
+		t.Fatalf("Expected a build on a missing network to fail: %s", out)
+	}
+
+	buildCmd = exec.Command(dockerBinary, "build", "--network", "container:foo", "-t", name, "-")
+	buildCmd.Stdin = strings.NewReader(`FROM busybox
+RUN true`)
+	if out, _, err := runCommandWithOutput(buildCmd); err == nil || !strings.Contains(out, "Invalid network") {
+		t.Fatalf("Expected the container network mode to be refused: %s, %v", out, err)
+	}
+	logDone("build - network mode")
+}
+
+func TestBuildAddHost(t *testing.T) {
+	name := "testbuildaddhost"
+	defer deleteImages(name)
+	buildCmd := exec.Command(dockerBinary, "build", "--add-host", "mirror.internal:10.20.30.40", "-t", name, "-")
+	buildCmd.Stdin = strings.NewReader(`FROM busybox
+RUN grep -q "10.20.30.40[[:space:]]*mirror.internal" /etc/hosts`)
+	if out, _, err := runCommandWithOutput(buildCmd); err != nil {
+		t.Fatalf("Expected the host to be added to the RUN container: %s, %v", out, err)
+	}
+
+	out, _, err := dockerCmd(t, "run", "--rm", name, "cat", "/etc/hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "mirror.internal") {
+		t.Fatalf("Expected the host not to be in the containers of the image: %s", out)
+	}
+
+	buildCmd = exec.Command(dockerBinary, "build", "--add-host", "mirror.internal", "-t", name, "-")
+	buildCmd.Stdin = strings.NewReader("FROM busybox")
+	if out, _, err := runCommandWithOutput(buildCmd); err == nil {
+		t.Fatalf("Expected an invalid host to be refused: %s", out)
+	}
+	logDone("build - add host")
+}
+
+func TestBuildReproducible(t *testing.T) {
+	name := "testbuildreproducible"
+	defer deleteImages(name)
+	dockerfile := `FROM busybox
+RUN echo $SOURCE_DATE_EPOCH > /epoch && mkdir -p /dir && touch /dir/a /dir/b
+RUN rm /dir/a
+`
+	build := func() []utils.JSONBuildStep {
+		buildCmd := exec.Command(dockerBinary, "build", "-t", name, "--no-cache", "--reproducible", "--progress=json", "-")
+		buildCmd.Env = append(os.Environ(), "SOURCE_DATE_EPOCH=1420070400")
+		buildCmd.Stdin = strings.NewReader(dockerfile)
+		out, err := buildCmd.Output()
+		if err != nil {
+			t.Fatalf("failed to build the image: %s, %v", out, err)
+		}
+		steps := []utils.JSONBuildStep{}
+		dec := json.NewDecoder(bytes.NewReader(out))
+		for {
+			var jm utils.JSONMessage
+			if err := dec.Decode(&jm); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("Expected JSON messages, got %s: %v", out, err)
+			}
+			if jm.BuildStep != nil {
+				steps = append(steps, *jm.BuildStep)
+			}
+		}
+		if len(steps) != 3 {
+			t.Fatalf("Expected 3 build steps, got %s", out)
+		}
+		return steps
+	}
+
+	first := build()
+	created, err := inspectField(name, "Created")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created != "2015-01-01 00:00:00 +0000 UTC" {
+		t.Fatalf("Expected the image to be created at SOURCE_DATE_EPOCH, got %s", created)
+	}
+	second := build()
+	for i := 1; i < 3; i++ {
+		if first[i].Digest == "" || first[i].Digest != second[i].Digest {
+			t.Fatalf("Expected step %d to produce the same layer twice, got %+v and %+v", i, first[i], second[i])
+		}
+		if first[i].Image == second[i].Image {
+			t.Fatalf("Expected step %d not to use the cache, got %+v", i, second[i])
+		}
+	}
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--rm", name, "cat", "/epoch"))
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	if strings.TrimSpace(out) != "1420070400" {
+		t.Fatalf("Expected SOURCE_DATE_EPOCH to be set in RUN, got %q", out)
+	}
+	logDone("build - reproducible")
+}
+
+func TestBuildNamedContexts(t *testing.T) {
+	name := "testbuildnamedcontexts"
+	defer deleteImages(name)
+	ctx, err := fakeContext(`FROM base
+COPY --from=docs /guide.md /docs/guide.md
+COPY --from=docs /sub/ /docs/sub/
+RUN [ ! -e /docs/ignored ]
+`, map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+	docs, err := ioutil.TempDir("", "docker-build-context-docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(docs)
+	for file, content := range map[string]string{
+		"guide.md":      "guide",
+		"sub/notes.md":  "notes",
+		"ignored":       "ignored",
+		".dockerignore": "ignored",
+	} {
+		if err := os.MkdirAll(filepath.Join(docs, filepath.Dir(file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(docs, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	args := []string{"build", "-t", name, "--build-context", "docs=" + docs, "--build-context", "base=docker-image://busybox", "."}
+	buildCmd := exec.Command(dockerBinary, args...)
+	buildCmd.Dir = ctx.Dir
+	if out, _, err := runCommandWithOutput(buildCmd); err != nil {
+		t.Fatalf("failed to build the image: %s, %v", out, err)
+	}
+	out, _, _ := dockerCmd(t, "run", "--rm", name, "cat", "/docs/guide.md", "/docs/sub/notes.md")
+	if out != "guidenotes" {
+		t.Fatalf("Expected the files of the named context to be copied, got %q", out)
+	}
+
+	// the cache is invalidated by a change of the named context
+	buildCmd = exec.Command(dockerBinary, args...)
+	buildCmd.Dir = ctx.Dir
+	out, _, err = runCommandWithOutput(buildCmd)
+	if err != nil {
+		t.Fatalf("failed to build the image: %s, %v", out, err)
+	}
+	if strings.Count(out, "Using cache") != 3 {
+		t.Fatalf("Expected all the steps to use the cache: %s", out)
+	}
+	if err := ioutil.WriteFile(filepath.Join(docs, "guide.md"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	buildCmd = exec.Command(dockerBinary, args...)
+	buildCmd.Dir = ctx.Dir
+	out, _, err = runCommandWithOutput(buildCmd)
+	if err != nil {
+		t.Fatalf("failed to build the image: %s, %v", out, err)
+	}
+	if strings.Contains(out, "Using cache") {
+		t.Fatalf("Expected the change of the named context to invalidate the cache: %s", out)
+	}
+
+	buildCmd = exec.Command(dockerBinary, "build", "-t", name, "--build-context", "base="+docs, ".")
+	buildCmd.Dir = ctx.Dir
+	if out, _, err := runCommandWithOutput(buildCmd); err == nil || !strings.Contains(out, "is not an image") {
+		t.Fatalf("Expected FROM a local build context to fail the build: %s, %v", out, err)
+	}
+	logDone("build - named build contexts")
+}
@@ -279,3 +279,56 @@ func TestCommitChange(t *testing.T) {
 
 	logDone("commit - commit --change")
 }
+
+func TestCommitChangeInstructions(t *testing.T) {
+	defer deleteAllContainers()
+
+	cmd := exec.Command(dockerBinary, "run", "--name", "test", "busybox", "true")
+	if _, err := runCommand(cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd = exec.Command(dockerBinary, "commit",
+		"--change", `ENTRYPOINT ["/bin/sh", "-c"]`,
+		"--change", "LABEL com.example.tier=front",
+		"--change", "STOPSIGNAL SIGINT",
+		"--change", "VOLUME /data",
+		"--change", "ONBUILD RUN true",
+		"--change", "HEALTHCHECK --timeout=2s CMD [\"true\"]",
+		"test", "test-commit-instructions")
+	imageId, _, err := runCommandWithOutput(cmd)
+	if err != nil {
+		t.Fatal(imageId, err)
+	}
+	imageId = strings.Trim(imageId, "\r\n")
+	defer deleteImages(imageId)
+
+	expected := map[string]string{
+		"Config.Entrypoint":          "[/bin/sh -c]",
+		"Config.Labels":              "map[com.example.tier:front]",
+		"Config.StopSignal":          "SIGINT",
+		"Config.Volumes":             "map[/data:map[]]",
+		"Config.OnBuild":             "[RUN true]",
+		"Config.Healthcheck.Test":    "[CMD true]",
+		"Config.Healthcheck.Timeout": "2000000000",
+	}
+	for conf, value := range expected {
+		res, err := inspectField(imageId, conf)
+		if err != nil {
+			t.Errorf("failed to get value %s, error: %s", conf, err)
+		}
+		if res != value {
+			t.Errorf("%s('%s'), expected %s", conf, res, value)
+		}
+	}
+
+	for _, change := range []string{"RUN true", "STOPSIGNAL SIGFOO", "HEALTHCHECK CMD"} {
+		out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "commit", "--change", change, "test"))
+		if err == nil {
+			deleteImages(strings.TrimSpace(out))
+			t.Fatalf("Expected commit --change %q to fail", change)
+		}
+	}
+
+	logDone("commit - commit --change with the other instructions")
+}
@@ -556,3 +556,35 @@ func TestCpToStdout(t *testing.T) {
 	}
 	logDone("cp - to stdout")
 }
+
+func TestCpBetweenContainers(t *testing.T) {
+	defer deleteAllContainers()
+
+	out, _, _ := dockerCmd(t, "run", "-d", "--name", "cpsource", "busybox", "/bin/sh", "-c", "mkdir -p /some/path && echo -n '"+cpContainerContents+"' > "+cpFullPath+" && chown -R 1000:1000 /some")
+	dockerCmd(t, "wait", stripTrailingCharacters(out))
+	dockerCmd(t, "create", "--name", "cpdest", "-v", "/data", "busybox", "true")
+
+	dockerCmd(t, "cp", "cpsource:"+cpFullPath, "cpdest:/copied")
+	dockerCmd(t, "cp", "--archive", "cpsource:"+cpTestPath, "cpdest:/data")
+
+	// the files in the rootfs and in the volume are read from the container
+	dockerCmd(t, "start", "-a", "cpdest")
+	out, _, _ = dockerCmd(t, "run", "--rm", "--volumes-from", "cpdest", "busybox", "stat", "-c", "%u:%g %n", "/data/path/"+cpTestName)
+	if stripTrailingCharacters(out) != "1000:1000 /data/path/"+cpTestName {
+		t.Fatalf("Expected the owner to be kept by --archive, got %s", out)
+	}
+
+	out, _, _ = dockerCmd(t, "commit", "cpdest")
+	image := stripTrailingCharacters(out)
+	defer deleteImages(image)
+	out, _, _ = dockerCmd(t, "run", "--rm", image, "/bin/sh", "-c", "stat -c %u:%g /copied/"+cpTestName+" && cat /copied/"+cpTestName)
+	if out != "0:0\n"+cpContainerContents {
+		t.Fatalf("Expected the file to be owned by root, got %q", out)
+	}
+
+	runCmd := exec.Command(dockerBinary, "cp", "cpsource:"+cpFullPath, "nosuchcontainer:/copied")
+	if out, _, err := runCommandWithOutput(runCmd); err == nil {
+		t.Fatalf("Expected the copy to a missing container to fail: %s", out)
+	}
+	logDone("cp - between containers")
+}
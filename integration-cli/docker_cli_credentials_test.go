@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCliLogoutCredentialHelper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-cli-credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	log := filepath.Join(dir, "helper.log")
+	script := "#!/bin/sh\nread server\necho \"$1 $server\" >> " + log + "\n" +
+		"if [ \"$1\" = get ]; then echo '{\"ServerURL\":\"'$server'\",\"Username\":\"user\",\"Secret\":\"secret\"}'; fi\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "docker-credential-fake"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, ".docker"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	config := `{"credHelpers": {"registry.example.com": "fake"}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, ".docker", "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	env := append(os.Environ(), "HOME="+dir, "PATH="+dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	cmd := exec.Command(dockerBinary, "logout", "registry.example.com")
+	cmd.Env = env
+	out, _, err := runCommandWithOutput(cmd)
+	if err != nil {
+		t.Fatal(err, out)
+	}
+	if !strings.Contains(out, "Remove login credentials for registry.example.com") {
+		t.Fatalf("Expected the credentials of the helper to be removed, got %s", out)
+	}
+	b, err := ioutil.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "get registry.example.com\nerase registry.example.com\n"; string(b) != expected {
+		t.Fatalf("Expected the helper to be run with %q, got %q", expected, b)
+	}
+
+	// the other registries have no helper
+	cmd = exec.Command(dockerBinary, "logout", "other.example.com")
+	cmd.Env = env
+	out, _, err = runCommandWithOutput(cmd)
+	if err != nil {
+		t.Fatal(err, out)
+	}
+	if !strings.Contains(out, "Not logged in to other.example.com") {
+		t.Fatalf("Expected no credentials for other.example.com, got %s", out)
+	}
+
+	logDone("cli credentials - logout erases the credentials of the credential helper")
+}
@@ -292,3 +292,33 @@ func TestEventsFilterImageName(t *testing.T) {
 	logDone("events - filters using image")
 
 }
+
+func TestEventsFormat(t *testing.T) {
+	since := time.Now().Unix()
+	defer deleteAllContainers()
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--name", "format_events", "-l", "com.example.format=yes", "busybox", "true"))
+	if err != nil {
+		t.Fatal(out, err)
+	}
+
+	eventsCmd := exec.Command(dockerBinary, "events", fmt.Sprintf("--since=%d", since), fmt.Sprintf("--until=%d", time.Now().Unix()),
+		"--filter", "type=container", "--filter", "label=com.example.format=yes",
+		"--format", "{{.Type}} {{.Action}} {{.Actor.Attributes.name}} {{.Actor.Attributes.image}}")
+	out, _, err = runCommandWithOutput(eventsCmd)
+	if err != nil {
+		t.Fatalf("Failed to get events, error: %s(%s)", err, out)
+	}
+	events := strings.Split(strings.TrimSpace(out), "\n")
+	expected := []string{"create", "start", "die"}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d: %q", len(expected), len(events), events)
+	}
+	for i, action := range expected {
+		if e := fmt.Sprintf("container %s format_events busybox:latest", action); events[i] != e {
+			t.Fatalf("Expected event %q, got %q", e, events[i])
+		}
+	}
+
+	logDone("events - format the events with a template")
+}
@@ -685,3 +685,42 @@ func TestRunMutableNetworkFiles(t *testing.T) {
 	}
 	logDone("run - mutable network files")
 }
+
+func TestExecEnvWorkdirPrivileged(t *testing.T) {
+	defer deleteAllContainers()
+
+	runCmd := exec.Command(dockerBinary, "run", "-e", "LALA=value1", "-d", "--name", "testing", "busybox", "top")
+	if out, _, _, err := runCommandWithStdoutStderr(runCmd); err != nil {
+		t.Fatal(out, err)
+	}
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "exec", "-e", "LALA=value2", "-e", "FOO=bar", "-w", "/tmp", "testing", "sh", "-c", "env; pwd"))
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	if strings.Contains(out, "LALA=value1") || !strings.Contains(out, "LALA=value2") || !strings.Contains(out, "FOO=bar") {
+		t.Fatalf("Expected the exec environment to be overridden, got %q", out)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(out), "/tmp") {
+		t.Fatalf("Expected the exec to run in /tmp, got %q", out)
+	}
+
+	// the environment of the container is left untouched
+	out, _, err = runCommandWithOutput(exec.Command(dockerBinary, "exec", "testing", "env"))
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	if !strings.Contains(out, "LALA=value1") || strings.Contains(out, "FOO=bar") {
+		t.Fatalf("Expected the container environment, got %q", out)
+	}
+
+	// adding a link needs CAP_NET_ADMIN, which is only given to the privileged exec
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "exec", "testing", "sh", "-c", "ip link add dummy0 type dummy")); err == nil {
+		t.Fatalf("Expected adding a link to fail without --privileged: %s", out)
+	}
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "exec", "--privileged", "testing", "sh", "-c", "ip link add dummy0 type dummy")); err != nil {
+		t.Fatalf("Expected adding a link to succeed with --privileged: %s, %v", out, err)
+	}
+
+	logDone("exec - exec with custom env, working directory and privileges")
+}
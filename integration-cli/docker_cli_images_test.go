@@ -122,3 +122,77 @@ func TestImagesFilterWhiteSpaceTrimmingAndLowerCasingWorking(t *testing.T) {
 
 	logDone("images - white space trimming and lower casing")
 }
+
+func TestImagesPrune(t *testing.T) {
+	defer deleteAllContainers()
+	defer deleteImages("testprunedangling", "testprunetagged", "testpruneused")
+
+	// the images committed from the containers keep their labels
+	commit := func(name, label, file string) string {
+		dockerCmd(t, "run", "--name", name, "-l", label, "busybox", "touch", file)
+		out, _, _ := dockerCmd(t, "commit", name, name)
+		return strings.TrimSpace(out)
+	}
+	danglingID := commit("testprunedangling", "com.example.temporary=true", "/dangling")
+	// the image is left dangling by a commit of another image with its tag
+	dockerCmd(t, "rm", "testprunedangling")
+	commit("testprunedangling", "com.example.other=true", "/other")
+	taggedID := commit("testprunetagged", "com.example.temporary=true", "/tagged")
+	commit("testpruneused", "com.example.temporary=true", "/used")
+	dockerCmd(t, "rm", "testprunedangling", "testprunetagged")
+	dockerCmd(t, "run", "--name", "user", "testpruneused", "true")
+
+	out, _, _ := dockerCmd(t, "image", "prune", "-f", "--filter", "label=com.example.other")
+	if strings.Contains(out, danglingID) {
+		t.Fatalf("Expected the images without the label to be kept, got %s", out)
+	}
+	out, _, _ = dockerCmd(t, "image", "prune", "-f", "--filter", "until=2000-01-01")
+	if strings.Contains(out, danglingID) {
+		t.Fatalf("Expected the images created after the until filter to be kept, got %s", out)
+	}
+
+	out, _, _ = dockerCmd(t, "image", "prune", "-f", "--filter", "label=com.example.temporary")
+	if !strings.Contains(out, danglingID) || !strings.Contains(out, "Total reclaimed space") {
+		t.Fatalf("Expected the dangling image to be pruned, got %s", out)
+	}
+	if strings.Contains(out, taggedID) {
+		t.Fatalf("Expected the tagged image to be kept, got %s", out)
+	}
+
+	// the filter keeps the other images of the tests
+	out, _, _ = dockerCmd(t, "image", "prune", "-f", "-a", "--filter", "label=com.example.temporary")
+	if !strings.Contains(out, "untagged: testprunetagged:latest") || !strings.Contains(out, taggedID) {
+		t.Fatalf("Expected the unused tagged image to be pruned, got %s", out)
+	}
+	out, _, _ = dockerCmd(t, "images", "-q", "--no-trunc")
+	if strings.Contains(out, taggedID) {
+		t.Fatalf("Expected the unused tagged image to be deleted, got %s", out)
+	}
+	out, _, _ = dockerCmd(t, "images", "testpruneused")
+	if !strings.Contains(out, "testpruneused") {
+		t.Fatalf("Expected the image used by a container to be kept, got %s", out)
+	}
+	logDone("images - prune the unused images")
+}
+
+func TestImagesFilterLabel(t *testing.T) {
+	defer deleteAllContainers()
+	defer deleteImages("testlabelowned", "testlabelother")
+
+	dockerCmd(t, "run", "--name", "owned", "-l", "com.example.tool=builder", "busybox", "true")
+	out, _, _ := dockerCmd(t, "commit", "owned", "testlabelowned")
+	ownedID := strings.TrimSpace(out)
+	dockerCmd(t, "run", "--name", "other", "busybox", "true")
+	dockerCmd(t, "commit", "other", "testlabelother")
+
+	out, _, _ = dockerCmd(t, "images", "-q", "--no-trunc", "-f", "label=com.example.tool=builder")
+	if strings.TrimSpace(out) != ownedID {
+		t.Fatalf("Expected only %s to match the label, got %s", ownedID, out)
+	}
+	out, _, _ = dockerCmd(t, "images", "-q", "-f", "label=com.example.tool=other")
+	if strings.TrimSpace(out) != "" {
+		t.Fatalf("Expected no image to match the label, got %s", out)
+	}
+
+	logDone("images - filter by label")
+}
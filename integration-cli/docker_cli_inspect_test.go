@@ -2,6 +2,7 @@ package main
 
 import (
 	"os/exec"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -21,3 +22,33 @@ func TestInspectImage(t *testing.T) {
 
 	logDone("inspect - inspect an image")
 }
+
+func TestInspectSize(t *testing.T) {
+	defer deleteAllContainers()
+
+	dockerCmd(t, "run", "--name", "sized", "busybox", "dd", "if=/dev/zero", "of=/file", "bs=1k", "count=1024")
+
+	out, _, _ := dockerCmd(t, "inspect", "--format", "{{.SizeRw}}", "sized")
+	if strings.TrimSpace(out) != "<no value>" {
+		t.Fatalf("Expected no size without --size, got %s", out)
+	}
+	out, _, _ = dockerCmd(t, "inspect", "--size", "--format", "{{.SizeRw}} {{.SizeRootFs}}", "sized")
+	sizes := strings.Fields(out)
+	if len(sizes) != 2 {
+		t.Fatalf("Expected the sizes of the container, got %s", out)
+	}
+	if sizeRw, err := strconv.ParseInt(sizes[0], 10, 64); err != nil || sizeRw < 1024*1024 {
+		t.Fatalf("Expected the size of the container to count its file, got %s", out)
+	}
+	if sizeRootFs, err := strconv.ParseInt(sizes[1], 10, 64); err != nil || sizeRootFs < 1024*1024 {
+		t.Fatalf("Expected the size of the root filesystem of the container, got %s", out)
+	}
+
+	out, _, _ = dockerCmd(t, "inspect", "--size", "--format", "{{range .Layers}}{{.Id}} {{.Size}}\n{{end}}", "busybox")
+	layers := strings.Split(strings.TrimSpace(out), "\n")
+	historyOut, _, _ := dockerCmd(t, "history", "-q", "--no-trunc", "busybox")
+	if len(layers) != len(strings.Split(strings.TrimSpace(historyOut), "\n")) {
+		t.Fatalf("Expected a size for each layer of the history, got %s", out)
+	}
+	logDone("inspect - sizes of a container and of the layers of an image")
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+// createDummyLink creates a dummy host interface to be used as the parent
+// of macvlan networks
+func createDummyLink(t *testing.T, name string) {
+	if out, err := exec.Command("ip", "link", "add", name, "type", "dummy").CombinedOutput(); err != nil {
+		t.Fatalf("Failed to create dummy interface %s: %s, %v", name, out, err)
+	}
+}
+
+func deleteLink(name string) {
+	exec.Command("ip", "link", "del", name).Run()
+}
+
+func TestNetworkCreateLsInspectRm(t *testing.T) {
+	testRequires(t, SameHostDaemon, NativeExecDriver)
+	defer deleteAllContainers()
+	createDummyLink(t, "dm-dummy0")
+	defer deleteLink("dm-dummy0")
+
+	out, _, _ := dockerCmd(t, "network", "create", "-d", "macvlan", "--subnet", "192.168.210.0/24", "-o", "parent=dm-dummy0", "mvnet")
+	id := strings.TrimSpace(out)
+	defer exec.Command(dockerBinary, "network", "rm", "mvnet").Run()
+
+	out, _, _ = dockerCmd(t, "network", "ls")
+	if !strings.Contains(out, id[:12]) || !strings.Contains(out, "mvnet") || !strings.Contains(out, "192.168.210.0/24") {
+		t.Fatalf("Expected mvnet to be listed, got %s", out)
+	}
+
+	out, _, _ = dockerCmd(t, "run", "-d", "--net=mvnet", "--name=mv1", "busybox", "top")
+	containerID := strings.TrimSpace(out)
+
+	out, _, _ = dockerCmd(t, "exec", "mv1", "ip", "-o", "-4", "addr", "show", "eth0")
+	if !strings.Contains(out, "192.168.210.2/24") {
+		t.Fatalf("Expected eth0 to get 192.168.210.2/24, got %s", out)
+	}
+	ip, err := inspectField("mv1", "NetworkSettings.IPAddress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "192.168.210.2" {
+		t.Fatalf("Expected IPAddress 192.168.210.2, got %s", ip)
+	}
+
+	out, _, _ = dockerCmd(t, "network", "inspect", "mvnet")
+	var networks []types.NetworkResource
+	if err := json.Unmarshal([]byte(out), &networks); err != nil {
+		t.Fatal(err)
+	}
+	if len(networks) != 1 || networks[0].Driver != "macvlan" || networks[0].IPAM.Config[0].Gateway != "192.168.210.1" {
+		t.Fatalf("Unexpected network %s", out)
+	}
+	if c, exists := networks[0].Containers[containerID]; !exists || c.Name != "mv1" || c.IPv4Address != "192.168.210.2/24" {
+		t.Fatalf("Expected mv1 to be connected, got %v", networks[0].Containers)
+	}
+
+	out, _, err = runCommandWithOutput(exec.Command(dockerBinary, "network", "rm", "mvnet"))
+	if err == nil || !strings.Contains(out, "is being used") {
+		t.Fatalf("Expected the removal of a network in use to fail, got %s", out)
+	}
+
+	// a stopped container still uses the network
+	dockerCmd(t, "stop", "mv1")
+	out, _, err = runCommandWithOutput(exec.Command(dockerBinary, "network", "rm", "mvnet"))
+	if err == nil || !strings.Contains(out, "is being used") {
+		t.Fatalf("Expected the removal of a network used by a stopped container to fail, got %s", out)
+	}
+
+	dockerCmd(t, "rm", "-f", "mv1")
+	dockerCmd(t, "network", "rm", "mvnet")
+
+	out, _, _ = dockerCmd(t, "network", "ls", "-q")
+	if strings.Contains(out, id[:12]) {
+		t.Fatalf("Expected mvnet to be removed, got %s", out)
+	}
+
+	logDone("network - create, ls, inspect and rm a macvlan network")
+}
+
+func TestNetworkIpvlanL3(t *testing.T) {
+	testRequires(t, SameHostDaemon, NativeExecDriver)
+	defer deleteAllContainers()
+	createDummyLink(t, "dm-dummy2")
+	defer deleteLink("dm-dummy2")
+
+	dockerCmd(t, "network", "create", "-d", "ipvlan", "--subnet", "192.168.213.0/24", "-o", "parent=dm-dummy2", "-o", "ipvlan_mode=l3", "ivnet")
+	defer exec.Command(dockerBinary, "network", "rm", "ivnet").Run()
+
+	out, _, _ := dockerCmd(t, "run", "--net=ivnet", "busybox", "sh", "-c", "ip -o -4 addr show eth0; ip route")
+	if !strings.Contains(out, "192.168.213.2/24") {
+		t.Fatalf("Expected eth0 to get 192.168.213.2/24, got %s", out)
+	}
+	if !strings.Contains(out, "default dev eth0") {
+		t.Fatalf("Expected a default route through eth0 in l3 mode, got %s", out)
+	}
+
+	logDone("network - run a container on an l3 ipvlan network")
+}
+
+func TestNetworkCreateInvalid(t *testing.T) {
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "network", "create", "-d", "macvlan", "--subnet", "192.168.211.0/24", "mvnet"))
+	if err == nil || !strings.Contains(out, "requires a parent interface") {
+		t.Fatalf("Expected a macvlan network without parent to fail, got %s", out)
+	}
+
+	out, _, err = runCommandWithOutput(exec.Command(dockerBinary, "network", "create", "-d", "macvlan", "--subnet", "192.168.211.0/24", "-o", "parent=lo", "bridge"))
+	if err == nil || !strings.Contains(out, "is reserved") {
+		t.Fatalf("Expected a network named bridge to fail, got %s", out)
+	}
+
+	out, _, err = runCommandWithOutput(exec.Command(dockerBinary, "network", "create", "-d", "ipvlan", "--subnet", "192.168.211.0/24", "-o", "parent=lo", "-o", "ipvlan_mode=l4", "ivnet"))
+	if err == nil || !strings.Contains(out, "Invalid ipvlan mode l4") {
+		t.Fatalf("Expected an invalid ipvlan mode to fail, got %s", out)
+	}
+
+	logDone("network - create invalid networks")
+}
+
+func TestRunUnknownNetwork(t *testing.T) {
+	defer deleteAllContainers()
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--net=doesnotexist", "busybox", "true"))
+	if err == nil || !strings.Contains(out, "No such network: doesnotexist") {
+		t.Fatalf("Expected run on an unknown network to fail, got %s", out)
+	}
+
+	logDone("run - unknown network")
+}
+
+func TestRunNetworkPublishPorts(t *testing.T) {
+	testRequires(t, SameHostDaemon)
+	defer deleteAllContainers()
+	createDummyLink(t, "dm-dummy1")
+	defer deleteLink("dm-dummy1")
+
+	dockerCmd(t, "network", "create", "-d", "macvlan", "--subnet", "192.168.212.0/24", "-o", "parent=dm-dummy1", "mvnet2")
+	defer exec.Command(dockerBinary, "network", "rm", "mvnet2").Run()
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--net=mvnet2", "-p", "8080:80", "busybox", "true"))
+	if err == nil || !strings.Contains(out, "Ports cannot be published on network mvnet2") {
+		t.Fatalf("Expected publishing ports on a macvlan network to fail, got %s", out)
+	}
+
+	logDone("run - publish ports on a macvlan network")
+}
+
+func TestRunNetworkAlias(t *testing.T) {
+	testRequires(t, SameHostDaemon, NativeExecDriver)
+	defer deleteAllContainers()
+	createDummyLink(t, "dm-dummy3")
+	defer deleteLink("dm-dummy3")
+
+	dockerCmd(t, "network", "create", "-d", "macvlan", "--subnet", "192.168.213.0/24", "-o", "parent=dm-dummy3", "aliasnet")
+	defer exec.Command(dockerBinary, "network", "rm", "aliasnet").Run()
+
+	dockerCmd(t, "run", "-d", "--net=aliasnet", "--name=first", "--network-alias=db", "--network-alias=cache", "busybox", "top")
+	dockerCmd(t, "run", "-d", "--net=aliasnet", "--name=second", "busybox", "top")
+
+	out, _, _ := dockerCmd(t, "exec", "second", "cat", "/etc/hosts")
+	if !strings.Contains(out, "192.168.213.2\tfirst db cache") {
+		t.Fatalf("Expected first to be resolvable by its aliases from second, got %s", out)
+	}
+	out, _, _ = dockerCmd(t, "exec", "first", "cat", "/etc/hosts")
+	if !strings.Contains(out, "192.168.213.3\tsecond") {
+		t.Fatalf("Expected second to be added to the hosts file of first, got %s", out)
+	}
+
+	dockerCmd(t, "rm", "-f", "second")
+	out, _, _ = dockerCmd(t, "exec", "first", "cat", "/etc/hosts")
+	if strings.Contains(out, "second") {
+		t.Fatalf("Expected second to be removed from the hosts file of first, got %s", out)
+	}
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--network-alias=db", "busybox", "true"))
+	if err == nil || !strings.Contains(out, "only supported on user-defined networks") {
+		t.Fatalf("Expected --network-alias to fail on the bridge network, got %s", out)
+	}
+
+	logDone("network - resolve the containers of a network by their aliases")
+}
+
+func TestRunNetworkDns(t *testing.T) {
+	testRequires(t, SameHostDaemon, NativeExecDriver)
+	defer deleteAllContainers()
+	createDummyLink(t, "dm-dummy4")
+	defer deleteLink("dm-dummy4")
+
+	dockerCmd(t, "network", "create", "-d", "macvlan", "--subnet", "192.168.214.0/24", "-o", "parent=dm-dummy4",
+		"--dns=192.168.214.53", "--dns-search=corp.example.com", "dnsnet")
+	defer exec.Command(dockerBinary, "network", "rm", "dnsnet").Run()
+
+	out, _, _ := dockerCmd(t, "run", "--rm", "--net=dnsnet", "busybox", "cat", "/etc/resolv.conf")
+	if !strings.Contains(out, "nameserver 192.168.214.53") || !strings.Contains(out, "search corp.example.com") {
+		t.Fatalf("Expected the DNS settings of the network, got %s", out)
+	}
+
+	out, _, _ = dockerCmd(t, "run", "--rm", "--net=dnsnet", "--dns=192.168.214.54", "busybox", "cat", "/etc/resolv.conf")
+	if !strings.Contains(out, "nameserver 192.168.214.54") || strings.Contains(out, "192.168.214.53") || !strings.Contains(out, "search corp.example.com") {
+		t.Fatalf("Expected --dns to take precedence over the network, got %s", out)
+	}
+
+	logDone("network - containers use the DNS settings of their network")
+}
+
+func TestNetworkIPv6Pool(t *testing.T) {
+	testRequires(t, SameHostDaemon, NativeExecDriver)
+	createDummyLink(t, "dm-dummy5")
+	defer deleteLink("dm-dummy5")
+
+	d := NewDaemon(t)
+	if err := d.StartWithBusybox("--ipv6", "--ipv6-pool=fd00:dead:beef::/48"); err != nil {
+		t.Fatalf("Could not start daemon with busybox: %v", err)
+	}
+	defer d.Stop()
+
+	if out, err := d.Cmd("network", "create", "-d", "macvlan", "--subnet", "192.168.215.0/24", "-o", "parent=dm-dummy5", "v6net"); err != nil {
+		t.Fatalf("Could not create v6net: err=%v\n%s", err, out)
+	}
+
+	out, err := d.Cmd("run", "--rm", "--net=v6net", "busybox", "ip", "-o", "-6", "addr", "show", "eth0", "scope", "global")
+	if err != nil {
+		t.Fatalf("Could not run a container on v6net: err=%v\n%s", err, out)
+	}
+	if !strings.Contains(out, "fd00:dead:beef::2/64") {
+		t.Fatalf("Expected eth0 to get fd00:dead:beef::2/64, got %s", out)
+	}
+
+	out, err = d.Cmd("network", "inspect", "v6net")
+	if err != nil {
+		t.Fatal(err, out)
+	}
+	if !strings.Contains(out, "fd00:dead:beef::/64") {
+		t.Fatalf("Expected the IPv6 subnet of v6net to be listed, got %s", out)
+	}
+
+	logDone("network - allocate IPv6 subnets to networks from the daemon pool")
+}
+
+func TestNetworkConnectDisconnect(t *testing.T) {
+	testRequires(t, SameHostDaemon, NativeExecDriver)
+	defer deleteAllContainers()
+	createDummyLink(t, "dm-dummy6")
+	defer deleteLink("dm-dummy6")
+
+	dockerCmd(t, "network", "create", "-d", "macvlan", "--subnet", "192.168.216.0/24", "-o", "parent=dm-dummy6", "connectnet")
+	defer exec.Command(dockerBinary, "network", "rm", "connectnet").Run()
+
+	dockerCmd(t, "run", "-d", "--net=connectnet", "--name=first", "busybox", "top")
+	dockerCmd(t, "run", "-d", "--name=second", "busybox", "top")
+	dockerCmd(t, "network", "connect", "--alias=web", "connectnet", "second")
+
+	out, _, _ := dockerCmd(t, "exec", "second", "ip", "-o", "-4", "addr", "show", "eth1")
+	if !strings.Contains(out, "192.168.216.3/24") {
+		t.Fatalf("Expected second to get an interface on the network, got %s", out)
+	}
+	out, _, _ = dockerCmd(t, "exec", "first", "cat", "/etc/hosts")
+	if !strings.Contains(out, "192.168.216.3\tsecond web") {
+		t.Fatalf("Expected second to be added to the hosts file of first, got %s", out)
+	}
+	out, _, _ = dockerCmd(t, "exec", "second", "cat", "/etc/hosts")
+	if !strings.Contains(out, "192.168.216.2\tfirst") {
+		t.Fatalf("Expected first to be added to the hosts file of second, got %s", out)
+	}
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "network", "connect", "connectnet", "second"))
+	if err == nil || !strings.Contains(out, "already connected") {
+		t.Fatalf("Expected connecting twice to fail, got %s", out)
+	}
+	out, _, err = runCommandWithOutput(exec.Command(dockerBinary, "network", "disconnect", "connectnet", "first"))
+	if err == nil || !strings.Contains(out, "it is its network mode") {
+		t.Fatalf("Expected disconnecting from the network mode to fail, got %s", out)
+	}
+
+	dockerCmd(t, "network", "disconnect", "connectnet", "second")
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "exec", "second", "ip", "link", "show", "eth1")); err == nil {
+		t.Fatalf("Expected the interface of second to be removed, got %s", out)
+	}
+	out, _, _ = dockerCmd(t, "exec", "first", "cat", "/etc/hosts")
+	if strings.Contains(out, "second") {
+		t.Fatalf("Expected second to be removed from the hosts file of first, got %s", out)
+	}
+
+	logDone("network - connect and disconnect a running container")
+}
+
+func TestRunNetworkConnectOnCreate(t *testing.T) {
+	testRequires(t, SameHostDaemon, NativeExecDriver)
+	defer deleteAllContainers()
+	createDummyLink(t, "dm-dummy7")
+	defer deleteLink("dm-dummy7")
+
+	dockerCmd(t, "network", "create", "-d", "macvlan", "--subnet", "192.168.217.0/24", "-o", "parent=dm-dummy7", "frontnet")
+	defer exec.Command(dockerBinary, "network", "rm", "frontnet").Run()
+	dockerCmd(t, "network", "create", "-d", "macvlan", "--subnet", "192.168.218.0/24", "-o", "parent=dm-dummy7", "backnet")
+	defer exec.Command(dockerBinary, "network", "rm", "backnet").Run()
+
+	dockerCmd(t, "run", "-d", "--net=frontnet", "--net-connect=backnet,ip=192.168.218.10,alias=db", "--name=first", "busybox", "top")
+	dockerCmd(t, "run", "-d", "--net=backnet", "--name=second", "busybox", "top")
+
+	out, _, _ := dockerCmd(t, "exec", "first", "ip", "-o", "-4", "addr")
+	if !strings.Contains(out, "eth0") || !strings.Contains(out, "192.168.217.2/24") || !strings.Contains(out, "192.168.218.10/24") {
+		t.Fatalf("Expected first to have an interface on both networks, got %s", out)
+	}
+	out, _, _ = dockerCmd(t, "exec", "second", "cat", "/etc/hosts")
+	if !strings.Contains(out, "192.168.218.10\tfirst db") {
+		t.Fatalf("Expected first to be resolved by its alias on backnet, got %s", out)
+	}
+
+	// the networks are connected again on restart
+	dockerCmd(t, "restart", "first")
+	out, _, _ = dockerCmd(t, "exec", "first", "ip", "-o", "-4", "addr", "show", "eth1")
+	if !strings.Contains(out, "192.168.218.10/24") {
+		t.Fatalf("Expected first to keep its address on backnet, got %s", out)
+	}
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--net-connect=backnet,ip=10.0.0.1", "busybox", "true"))
+	if err == nil || !strings.Contains(out, "is not in the subnet") {
+		t.Fatalf("Expected an address outside of the subnet to fail, got %s", out)
+	}
+
+	logDone("network - connect a container to several networks on creation")
+}
+
+func TestRunNetworkStaticIP(t *testing.T) {
+	testRequires(t, SameHostDaemon, NativeExecDriver)
+	defer deleteAllContainers()
+	createDummyLink(t, "dm-dummy8")
+	defer deleteLink("dm-dummy8")
+
+	dockerCmd(t, "network", "create", "-d", "macvlan", "--subnet", "192.168.219.0/24", "--ip-range", "192.168.219.128/25",
+		"-o", "parent=dm-dummy8", "staticnet")
+	defer exec.Command(dockerBinary, "network", "rm", "staticnet").Run()
+
+	dockerCmd(t, "run", "-d", "--net=staticnet", "--ip=192.168.219.53", "--name=first", "busybox", "top")
+	out, _, _ := dockerCmd(t, "exec", "first", "ip", "-o", "-4", "addr", "show", "eth0")
+	if !strings.Contains(out, "192.168.219.53/24") {
+		t.Fatalf("Expected first to get its fixed address, got %s", out)
+	}
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--net=staticnet", "--ip=192.168.219.53", "busybox", "true"))
+	if err == nil || !strings.Contains(out, "already allocated") {
+		t.Fatalf("Expected an allocated address to be refused, got %s", out)
+	}
+	out, _, err = runCommandWithOutput(exec.Command(dockerBinary, "run", "--net=staticnet", "--ip=10.0.0.1", "busybox", "true"))
+	if err == nil || !strings.Contains(out, "is not in the subnet") {
+		t.Fatalf("Expected an address outside of the subnet to be refused, got %s", out)
+	}
+
+	// the address stays out of the dynamic range while first is stopped
+	dockerCmd(t, "stop", "first")
+	out, _, _ = dockerCmd(t, "run", "--rm", "--net=staticnet", "busybox", "ip", "-o", "-4", "addr", "show", "eth0")
+	if !strings.Contains(out, "192.168.219.129/24") {
+		t.Fatalf("Expected a dynamic address from the range, got %s", out)
+	}
+	dockerCmd(t, "start", "first")
+	out, _, _ = dockerCmd(t, "exec", "first", "ip", "-o", "-4", "addr", "show", "eth0")
+	if !strings.Contains(out, "192.168.219.53/24") {
+		t.Fatalf("Expected first to get its fixed address again, got %s", out)
+	}
+
+	dockerCmd(t, "run", "-d", "--name=second", "busybox", "top")
+	dockerCmd(t, "network", "connect", "--ip=192.168.219.54", "staticnet", "second")
+	out, _, _ = dockerCmd(t, "exec", "second", "ip", "-o", "-4", "addr", "show", "eth1")
+	if !strings.Contains(out, "192.168.219.54/24") {
+		t.Fatalf("Expected second to be connected with its fixed address, got %s", out)
+	}
+
+	logDone("network - containers with fixed addresses on a user-defined network")
+}
+
+func TestNetworkCreateEncrypted(t *testing.T) {
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "network", "create", "-d", "macvlan", "--subnet", "192.168.220.0/24",
+		"-o", "parent=eth0", "-o", "encrypted", "encryptednet"))
+	if err == nil || !strings.Contains(out, "cannot encrypt the traffic") {
+		exec.Command(dockerBinary, "network", "rm", "encryptednet").Run()
+		t.Fatalf("Expected the encrypted option to be refused, got %s", out)
+	}
+
+	logDone("network - create refuses to encrypt networks")
+}
+
+func TestNetworkInternal(t *testing.T) {
+	testRequires(t, SameHostDaemon, NativeExecDriver)
+	defer deleteAllContainers()
+	createDummyLink(t, "dm-dummy9")
+	defer deleteLink("dm-dummy9")
+
+	dockerCmd(t, "network", "create", "-d", "macvlan", "--subnet", "192.168.221.0/24", "--internal",
+		"-o", "parent=dm-dummy9", "internalnet")
+	defer exec.Command(dockerBinary, "network", "rm", "internalnet").Run()
+
+	out, _, _ := dockerCmd(t, "network", "inspect", "internalnet")
+	if !strings.Contains(out, `"Internal": true`) {
+		t.Fatalf("Expected the network to be internal, got %s", out)
+	}
+
+	dockerCmd(t, "run", "-d", "--net=internalnet", "--name=first", "busybox", "top")
+	out, _, _ = dockerCmd(t, "exec", "first", "ip", "route")
+	if strings.Contains(out, "default") {
+		t.Fatalf("Expected no default route on an internal network, got %s", out)
+	}
+	if !strings.Contains(out, "192.168.221.0/24") {
+		t.Fatalf("Expected a route to the subnet, got %s", out)
+	}
+	if gw, _, _ := dockerCmd(t, "inspect", "-f", "{{.NetworkSettings.Gateway}}", "first"); strings.TrimSpace(gw) != "" {
+		t.Fatalf("Expected no gateway on an internal network, got %s", gw)
+	}
+
+	dockerCmd(t, "run", "-d", "--net=internalnet", "--name=second", "busybox", "top")
+	out, _, _ = dockerCmd(t, "exec", "second", "cat", "/etc/hosts")
+	if !strings.Contains(out, "first") {
+		t.Fatalf("Expected second to resolve first, got %s", out)
+	}
+
+	logDone("network - internal networks have no route out of their subnets")
+}
+
+func TestNetworkInspectVerbose(t *testing.T) {
+	testRequires(t, SameHostDaemon, NativeExecDriver)
+	defer deleteAllContainers()
+	createDummyLink(t, "dm-dummy10")
+	defer deleteLink("dm-dummy10")
+
+	dockerCmd(t, "network", "create", "-d", "macvlan", "--subnet", "192.168.222.0/24", "-o", "parent=dm-dummy10.22", "verbosenet")
+	defer exec.Command(dockerBinary, "network", "rm", "verbosenet").Run()
+	out, _, _ := dockerCmd(t, "run", "-d", "--net=verbosenet", "busybox", "top")
+	id := strings.TrimSpace(out)
+
+	out, _, _ = dockerCmd(t, "network", "inspect", "verbosenet")
+	if strings.Contains(out, "DriverInfo") || strings.Contains(out, "DriverState") {
+		t.Fatalf("Expected no driver details without --verbose, got %s", out)
+	}
+
+	out, _, _ = dockerCmd(t, "network", "inspect", "-v", "verbosenet")
+	var networks []types.NetworkResource
+	if err := json.Unmarshal([]byte(out), &networks); err != nil {
+		t.Fatal(err)
+	}
+	if len(networks) != 1 || networks[0].DriverState["created_parent"] != "true" {
+		t.Fatalf("Expected the driver state of the network, got %s", out)
+	}
+	ep := networks[0].Containers[id]
+	if ep.Interface != "eth0" || ep.DriverInfo["type"] != "macvlan" || ep.DriverInfo["parent"] != "dm-dummy10.22" || ep.DriverInfo["mode"] != "bridge" {
+		t.Fatalf("Expected the driver details of the endpoint, got %s", out)
+	}
+
+	logDone("network - verbose inspect shows the driver details")
+}
+
+func TestNetworkMTU(t *testing.T) {
+	testRequires(t, SameHostDaemon, NativeExecDriver)
+	defer deleteAllContainers()
+	createDummyLink(t, "dm-dummy11")
+	defer deleteLink("dm-dummy11")
+
+	dockerCmd(t, "network", "create", "-d", "macvlan", "--subnet", "192.168.223.0/24", "-o", "parent=dm-dummy11",
+		"-o", "com.docker.network.driver.mtu=1400", "mtunet")
+	defer exec.Command(dockerBinary, "network", "rm", "mtunet").Run()
+
+	out, _, _ := dockerCmd(t, "run", "--rm", "--net=mtunet", "busybox", "ip", "link", "show", "eth0")
+	if !strings.Contains(out, "mtu 1400") {
+		t.Fatalf("Expected eth0 to have the mtu of the network, got %s", out)
+	}
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "network", "create", "-d", "macvlan", "--subnet", "192.168.224.0/24",
+		"-o", "parent=dm-dummy11", "-o", "com.docker.network.driver.mtu=tiny", "badmtunet"))
+	if err == nil || !strings.Contains(out, "Invalid mtu") {
+		exec.Command(dockerBinary, "network", "rm", "badmtunet").Run()
+		t.Fatalf("Expected an invalid mtu to be refused, got %s", out)
+	}
+
+	logDone("network - containers get the mtu of their network")
+}
+
+func TestNetworkLsFilterLabel(t *testing.T) {
+	testRequires(t, SameHostDaemon, NativeExecDriver)
+	createDummyLink(t, "dm-dummy12")
+	defer deleteLink("dm-dummy12")
+
+	dockerCmd(t, "network", "create", "-d", "macvlan", "--subnet", "192.168.225.0/24", "-o", "parent=dm-dummy12",
+		"--label", "com.example.tenant=a", "labelnet")
+	defer exec.Command(dockerBinary, "network", "rm", "labelnet").Run()
+
+	out, _, _ := dockerCmd(t, "network", "ls", "-f", "label=com.example.tenant=a")
+	if !strings.Contains(out, "labelnet") {
+		t.Fatalf("Expected labelnet to match the label, got %s", out)
+	}
+	out, _, _ = dockerCmd(t, "network", "ls", "-f", "label=com.example.tenant=b")
+	if strings.Contains(out, "labelnet") {
+		t.Fatalf("Expected labelnet not to match another label value, got %s", out)
+	}
+
+	out, _, _ = dockerCmd(t, "network", "inspect", "labelnet")
+	var networks []types.NetworkResource
+	if err := json.Unmarshal([]byte(out), &networks); err != nil {
+		t.Fatal(err)
+	}
+	if len(networks) != 1 || networks[0].Labels["com.example.tenant"] != "a" {
+		t.Fatalf("Expected the labels in the inspect output, got %s", out)
+	}
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "network", "ls", "-f", "driver=macvlan"))
+	if err == nil || !strings.Contains(out, "Invalid filter") {
+		t.Fatalf("Expected an unknown filter to be refused: %s, %v", out, err)
+	}
+
+	logDone("network - ls filtered by label")
+}
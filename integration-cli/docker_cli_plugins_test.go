@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCliPluginRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-cli-plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := "#!/bin/sh\necho \"$* $DOCKER_HOST\"\nexit 3\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "docker-hello"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	env := append(os.Environ(), "PATH="+dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	cmd := exec.Command(dockerBinary, "-H", "tcp://127.0.0.1:2375", "hello", "world", "--flag")
+	cmd.Env = env
+	out, exitCode, _ := runCommandWithOutput(cmd)
+	if exitCode != 3 {
+		t.Fatalf("Expected the exit code of the plugin, got %d: %s", exitCode, out)
+	}
+	if strings.TrimSpace(out) != "world --flag tcp://127.0.0.1:2375" {
+		t.Fatalf("Expected the args and the host passed to the plugin, got %q", out)
+	}
+
+	cmd = exec.Command(dockerBinary, "help")
+	cmd.Env = env
+	out, _, err = runCommandWithOutput(cmd)
+	if err != nil {
+		t.Fatal(err, out)
+	}
+	if !strings.Contains(out, "Plugins:\n    hello\n") {
+		t.Fatalf("Expected the plugin in the help, got %s", out)
+	}
+
+	logDone("cli plugins - run a docker-NAME executable as docker NAME")
+}
+
+func TestCliPluginDoesNotShadowCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-cli-plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "docker-version"), []byte("#!/bin/sh\necho plugin\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(dockerBinary, "version")
+	cmd.Env = append(os.Environ(), "PATH="+dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	out, _, _ := runCommandWithOutput(cmd)
+	if strings.Contains(out, "plugin") {
+		t.Fatalf("Expected the version command to be run instead of the plugin, got %s", out)
+	}
+
+	logDone("cli plugins - the docker commands are not shadowed by the plugins")
+}
@@ -145,3 +145,52 @@ func assertPortList(t *testing.T, out string, expected []string) bool {
 
 	return true
 }
+
+func TestPortHostRange(t *testing.T) {
+	defer deleteAllContainers()
+
+	for _, expected := range []string{"0.0.0.0:9880", "0.0.0.0:9881"} {
+		out, _, _ := dockerCmd(t, "run", "-d", "-p", "9880-9881:80", "busybox", "top")
+		id := stripTrailingCharacters(out)
+
+		out, _, _ = dockerCmd(t, "port", id, "80")
+		if !assertPortList(t, out, []string{expected}) {
+			t.Errorf("Expected port 80 to be published on %s, got %s", expected, out)
+		}
+	}
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "-d", "-p", "9880-9881:80", "busybox", "top"))
+	if err == nil || !strings.Contains(out, "all ports are allocated") {
+		t.Fatalf("Expected the range to be exhausted, got %s", out)
+	}
+
+	logDone("port - publish a container port on a range of host ports")
+}
+
+func TestPortNoProxy(t *testing.T) {
+	testRequires(t, SameHostDaemon)
+	defer deleteAllContainers()
+
+	out, _, _ := dockerCmd(t, "run", "-d", "-p", "9890:80,noproxy", "busybox", "sh", "-c", "echo hello | nc -l -p 80")
+	id := stripTrailingCharacters(out)
+
+	out, _, _ = dockerCmd(t, "inspect", "--format", "{{range .NetworkSettings.Ports}}{{range .}}{{.HostPort}} {{.NoProxy}}{{end}}{{end}}", id)
+	if strings.TrimSpace(out) != "9890 true" {
+		t.Fatalf("Expected port 80 to be published on 9890 without proxy, got %s", out)
+	}
+
+	listPs := exec.Command("sh", "-c", "ps ax | grep docker-proxy")
+	out, _, _ = runCommandWithOutput(listPs)
+	if strings.Contains(out, "-host-port 9890") {
+		t.Fatalf("Expected no userland proxy for port 9890, got %s", out)
+	}
+
+	// the other containers reach the port through the host with hairpin NAT
+	gateway, _, _ := dockerCmd(t, "inspect", "--format", "{{.NetworkSettings.Gateway}}", id)
+	out, _, _ = dockerCmd(t, "run", "busybox", "nc", strings.TrimSpace(gateway), "9890")
+	if strings.TrimSpace(out) != "hello" {
+		t.Fatalf("Expected to reach the port through the host, got %s", out)
+	}
+
+	logDone("port - publish a port without userland proxy")
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
@@ -588,3 +591,117 @@ func TestPsGroupPortRange(t *testing.T) {
 
 	logDone("ps - port range")
 }
+
+func TestPsFormat(t *testing.T) {
+	defer deleteAllContainers()
+
+	out, _, _ := dockerCmd(t, "run", "-d", "--name", "formatted", "busybox", "top")
+	id := strings.TrimSpace(out)
+
+	out, _, _ = dockerCmd(t, "ps", "--no-trunc", "--format", "{{.ID}}:{{.Names}}")
+	if !strings.Contains(out, id+":formatted\n") {
+		t.Fatalf("Expected the container to be formatted as %s:formatted, got %s", id, out)
+	}
+
+	out, _, _ = dockerCmd(t, "ps", "--format", `table {{.Names}}\t{{.Image}}`)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if fields := strings.Fields(lines[0]); !reflect.DeepEqual(fields, []string{"NAMES", "IMAGE"}) {
+		t.Fatalf("Expected the header of the table to be NAMES IMAGE, got %s", out)
+	}
+	if !strings.Contains(out, "formatted") {
+		t.Fatalf("Expected the container in the table, got %s", out)
+	}
+
+	// the configuration of the client sets the default template
+	home, err := ioutil.TempDir("", "docker-ps-format")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	if err := os.Mkdir(filepath.Join(home, ".docker"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(home, ".docker", "config.json"), []byte(`{"psFormat": "name={{.Names}}"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	psCmd := exec.Command(dockerBinary, "ps")
+	psCmd.Env = append(os.Environ(), "HOME="+home)
+	if out, _, err = runCommandWithOutput(psCmd); err != nil {
+		t.Fatal(out, err)
+	}
+	if !strings.Contains(out, "name=formatted") {
+		t.Fatalf("Expected the default template of the configuration, got %s", out)
+	}
+
+	logDone("ps - format with a Go template")
+}
+
+func TestPsListContainersFilterLabel(t *testing.T) {
+	defer deleteAllContainers()
+
+	out, _, _ := dockerCmd(t, "run", "-d", "-l", "com.example.tenant=a", "-l", "com.example.tool", "busybox", "true")
+	firstID := strings.TrimSpace(out)
+	out, _, _ = dockerCmd(t, "run", "-d", "-l", "com.example.tenant=b", "busybox", "true")
+	secondID := strings.TrimSpace(out)
+
+	out, _, _ = dockerCmd(t, "ps", "-a", "-q", "--no-trunc", "--filter=label=com.example.tenant=a")
+	if strings.TrimSpace(out) != firstID {
+		t.Fatalf("Expected only %s to match the label, got %s", firstID, out)
+	}
+	out, _, _ = dockerCmd(t, "ps", "-a", "-q", "--no-trunc", "--filter=label=com.example.tenant")
+	if !strings.Contains(out, firstID) || !strings.Contains(out, secondID) {
+		t.Fatalf("Expected both containers to have the label, got %s", out)
+	}
+	// all the label filters have to match
+	out, _, _ = dockerCmd(t, "ps", "-a", "-q", "--no-trunc", "--filter=label=com.example.tenant", "--filter=label=com.example.tool")
+	if strings.TrimSpace(out) != firstID {
+		t.Fatalf("Expected only %s to match both labels, got %s", firstID, out)
+	}
+
+	logDone("ps - test ps filter label")
+}
+
+func TestPsListContainersFilterHealth(t *testing.T) {
+	name := "testpshealth"
+	defer deleteAllContainers()
+	defer deleteImages(name)
+	if _, err := buildImage(name,
+		`FROM busybox
+		 HEALTHCHECK --interval=1s --retries=1 CMD cat /tmp/healthy`,
+		true); err != nil {
+		t.Fatal(err)
+	}
+
+	dockerCmd(t, "run", "-d", "--name=healthy", name, "sh", "-c", "touch /tmp/healthy && top")
+	dockerCmd(t, "run", "-d", "--name=unhealthy", name, "top")
+	dockerCmd(t, "run", "-d", "--name=nohealthcheck", "busybox", "top")
+	if err := waitInspect("healthy", "{{.State.Health.Status}}", "healthy", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitInspect("unhealthy", "{{.State.Health.Status}}", "unhealthy", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	for filter, expected := range map[string]string{
+		"healthy":   "healthy",
+		"unhealthy": "unhealthy",
+		"none":      "nohealthcheck",
+	} {
+		out, _, _ := dockerCmd(t, "ps", "--format", "{{.Names}}", "--filter", "health="+filter)
+		if strings.TrimSpace(out) != expected {
+			t.Fatalf("Expected only %s to match the health filter %s, got %s", expected, filter, out)
+		}
+	}
+
+	out, _, _ := dockerCmd(t, "ps", "--format", "{{.Status}}", "--filter", "name=unhealthy")
+	if !strings.HasSuffix(strings.TrimSpace(out), "(unhealthy)") {
+		t.Fatalf("Expected the health in the status of the container, got %s", out)
+	}
+
+	runCmd := exec.Command(dockerBinary, "ps", "--filter", "health=sick")
+	if out, _, err := runCommandWithOutput(runCmd); err == nil || !strings.Contains(out, "Invalid health filter") {
+		t.Fatalf("Expected an invalid health filter to be refused: %s, %v", out, err)
+	}
+
+	logDone("ps - test ps filter health")
+}
@@ -3249,3 +3249,174 @@ func TestRunVolumesFromRestartAfterRemoved(t *testing.T) {
 
 	logDone("run - can restart a volumes-from container after producer is removed")
 }
+
+func TestRunInit(t *testing.T) {
+	defer deleteAllContainers()
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--init", "busybox", "cat", "/proc/1/cmdline"))
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	if !strings.HasPrefix(out, "/dev/init") {
+		t.Fatalf("Expected PID 1 to be the injected init, got %q", out)
+	}
+
+	_, exitCode, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--init", "busybox", "sh", "-c", "exit 3"))
+	if err == nil || exitCode != 3 {
+		t.Fatalf("Expected the command's exit code 3 through the init, got %d: %v", exitCode, err)
+	}
+
+	logDone("run - --init runs an init process as PID 1")
+}
+
+func TestRunPidsLimit(t *testing.T) {
+	if _, err := os.Stat("/sys/fs/cgroup/pids"); err != nil {
+		t.Skip("pids cgroup controller is not available")
+	}
+	defer deleteAllContainers()
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--pids-limit=4", "busybox", "sh", "-c", "for i in 1 2 3 4 5; do sleep 10 & done; wait"))
+	if err == nil {
+		t.Fatalf("Expected forking past the pids limit to fail: %s", out)
+	}
+
+	logDone("run - --pids-limit limits the number of processes")
+}
+
+func TestRunShmSize(t *testing.T) {
+	defer deleteAllContainers()
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--shm-size=128m", "busybox", "grep", "/dev/shm", "/proc/self/mounts"))
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	if !strings.Contains(out, "size=131072k") {
+		t.Fatalf("Expected /dev/shm to be 128m, got %q", out)
+	}
+
+	logDone("run - --shm-size sets the size of /dev/shm")
+}
+
+func TestRunOomScoreAdj(t *testing.T) {
+	defer deleteAllContainers()
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--oom-score-adj=642", "busybox", "cat", "/proc/self/oom_score_adj"))
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	if strings.TrimSpace(out) != "642" {
+		t.Fatalf("Expected oom_score_adj to be 642, got %q", out)
+	}
+
+	logDone("run - --oom-score-adj sets the oom score adjustment")
+}
+
+func TestRunDnsResolverOptions(t *testing.T) {
+	defer deleteAllContainers()
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--dns-opt=ndots:2", "--dns-opt=rotate", "busybox", "cat", "/etc/resolv.conf"))
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	if !strings.Contains(out, "options ndots:2 rotate\n") {
+		t.Fatalf("Expected resolv.conf to contain the dns options, got %q", out)
+	}
+
+	logDone("run - dns options are written to resolv.conf")
+}
+
+func TestRunAutoRemove(t *testing.T) {
+	defer deleteAllContainers()
+
+	_, exitCode, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--rm", "--name=autoremove", "busybox", "sh", "-c", "exit 3"))
+	if err == nil || exitCode != 3 {
+		t.Fatalf("Expected the exit code 3 of the removed container, got %d: %v", exitCode, err)
+	}
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "inspect", "autoremove")); err == nil {
+		t.Fatalf("Expected the container to be removed: %s", out)
+	}
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "-d", "--rm", "busybox", "true"))
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	id := strings.TrimSpace(out)
+	removed := false
+	for i := 0; i < 50 && !removed; i++ {
+		if _, _, err := runCommandWithOutput(exec.Command(dockerBinary, "inspect", id)); err != nil {
+			removed = true
+		} else {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	if !removed {
+		t.Fatal("Expected the detached container to be removed by the daemon")
+	}
+
+	logDone("run - --rm containers are removed by the daemon, also when detached")
+}
+
+func TestRunCgroupParent(t *testing.T) {
+	if _, err := os.Stat("/run/systemd/system"); err == nil {
+		t.Skip("the systemd cgroup driver expects a slice")
+	}
+	defer deleteAllContainers()
+
+	cgroupParent := "test-cgroup-parent"
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--cgroup-parent="+cgroupParent, "busybox", "cat", "/proc/self/cgroup"))
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	found := false
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) == 3 && strings.Contains(parts[1], "memory") {
+			found = strings.HasPrefix(parts[2], "/"+cgroupParent+"/")
+		}
+	}
+	if !found {
+		t.Fatalf("Expected the container to be under the %s cgroup, got %q", cgroupParent, out)
+	}
+
+	logDone("run - --cgroup-parent sets the parent cgroup of the container")
+}
+
+func TestRunTmpfs(t *testing.T) {
+	defer deleteAllContainers()
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--read-only", "--tmpfs", "/scratch:size=1m,exec", "busybox", "sh", "-c", "touch /scratch/file && grep /scratch /proc/self/mounts"))
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	if !strings.Contains(out, "tmpfs") || !strings.Contains(out, "size=1024k") {
+		t.Fatalf("Expected a 1m tmpfs on /scratch, got %q", out)
+	}
+	if strings.Contains(out, "noexec") || !strings.Contains(out, "nosuid") {
+		t.Fatalf("Expected /scratch to be mounted exec,nosuid, got %q", out)
+	}
+
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--tmpfs", "/scratch:foo=bar", "busybox", "true")); err == nil {
+		t.Fatalf("Expected an error for an invalid tmpfs option, got %q", out)
+	}
+
+	logDone("run - --tmpfs mounts a tmpfs writable with a read-only root")
+}
+
+func TestRunGroupAdd(t *testing.T) {
+	defer deleteAllContainers()
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--user", "nobody", "--group-add", "audio", "--group-add", "777", "busybox", "id"))
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	if !strings.Contains(out, "29(audio)") || !strings.Contains(out, "777") {
+		t.Fatalf("Expected the audio group and the gid 777 in the groups, got %q", out)
+	}
+
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--group-add", "nosuchgroup", "busybox", "true")); err == nil {
+		t.Fatalf("Expected an error for an unknown group, got %q", out)
+	}
+
+	logDone("run - --group-add adds supplementary groups")
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestVolumeCreateLsInspectRm(t *testing.T) {
+	defer deleteAllContainers()
+
+	out, _, _ := dockerCmd(t, "volume", "create", "testvolume")
+	if strings.TrimSpace(out) != "testvolume" {
+		t.Fatalf("Expected the name of the volume, got %s", out)
+	}
+	defer exec.Command(dockerBinary, "volume", "rm", "testvolume").Run()
+
+	out, _, _ = dockerCmd(t, "volume", "ls")
+	if !strings.Contains(out, "testvolume") {
+		t.Fatalf("Expected testvolume to be listed, got %s", out)
+	}
+
+	out, _, _ = dockerCmd(t, "volume", "inspect", "testvolume")
+	var volumes []types.Volume
+	if err := json.Unmarshal([]byte(out), &volumes); err != nil {
+		t.Fatal(err)
+	}
+	if len(volumes) != 1 || volumes[0].Driver != "local" || volumes[0].Mountpoint == "" {
+		t.Fatalf("Unexpected volume %s", out)
+	}
+
+	// the data of a named volume outlives its containers
+	dockerCmd(t, "run", "--name", "writer", "-v", "testvolume:/data", "busybox", "sh", "-c", "echo hello > /data/file")
+	dockerCmd(t, "rm", "-v", "writer")
+	out, _, _ = dockerCmd(t, "run", "--name", "reader", "-v", "testvolume:/data:ro", "busybox", "cat", "/data/file")
+	if strings.TrimSpace(out) != "hello" {
+		t.Fatalf("Expected the data of the volume to be kept, got %s", out)
+	}
+
+	runCmd := exec.Command(dockerBinary, "volume", "rm", "testvolume")
+	if out, _, err := runCommandWithOutput(runCmd); err == nil || !strings.Contains(out, "is being used") {
+		t.Fatalf("Expected a volume used by a container not to be removed: %s, %v", out, err)
+	}
+	dockerCmd(t, "rm", "reader")
+	dockerCmd(t, "volume", "rm", "testvolume")
+
+	runCmd = exec.Command(dockerBinary, "volume", "inspect", "testvolume")
+	if out, _, err := runCommandWithOutput(runCmd); err == nil || !strings.Contains(out, "No such volume") {
+		t.Fatalf("Expected the volume to be removed: %s, %v", out, err)
+	}
+	logDone("volume - create, ls, inspect and rm")
+}
+
+func TestVolumeCreateInvalid(t *testing.T) {
+	for _, args := range [][]string{
+		{"volume", "create", "-d", "unknown-volume-driver", "invalid1"},
+		{"volume", "create", "-o", "size=1G", "invalid2"},
+		{"volume", "create", "/invalid"},
+	} {
+		runCmd := exec.Command(dockerBinary, args...)
+		if out, _, err := runCommandWithOutput(runCmd); err == nil {
+			exec.Command(dockerBinary, "volume", "rm", args[len(args)-1]).Run()
+			t.Fatalf("Expected %v to fail: %s", args, out)
+		}
+	}
+	logDone("volume - create with invalid arguments")
+}
+
+func TestRunNamedVolumeCreated(t *testing.T) {
+	defer deleteAllContainers()
+	defer exec.Command(dockerBinary, "volume", "rm", "testautovolume").Run()
+
+	out, _, _ := dockerCmd(t, "run", "-v", "testautovolume:/etc", "busybox", "ls", "/etc/passwd")
+	if strings.TrimSpace(out) != "/etc/passwd" {
+		t.Fatalf("Expected the new volume to get the content of the image, got %s", out)
+	}
+	out, _, _ = dockerCmd(t, "volume", "ls", "-q")
+	if !strings.Contains(out, "testautovolume") {
+		t.Fatalf("Expected the volume to be created, got %s", out)
+	}
+	logDone("run - named volume created on first use")
+}
+
+func TestVolumeLsFilterDanglingAndLabel(t *testing.T) {
+	defer deleteAllContainers()
+	defer exec.Command(dockerBinary, "volume", "rm", "testunused", "testused").Run()
+
+	dockerCmd(t, "volume", "create", "--label", "com.example.team=storage", "testunused")
+	dockerCmd(t, "volume", "create", "testused")
+	dockerCmd(t, "run", "--name", "user", "-v", "testused:/data", "busybox", "true")
+
+	out, _, _ := dockerCmd(t, "volume", "ls", "-q", "-f", "dangling=true")
+	if !strings.Contains(out, "testunused") || strings.Contains(out, "testused") {
+		t.Fatalf("Expected only the unused volume to be dangling, got %s", out)
+	}
+	out, _, _ = dockerCmd(t, "volume", "ls", "-q", "-f", "dangling=false")
+	if strings.Contains(out, "testunused") || !strings.Contains(out, "testused") {
+		t.Fatalf("Expected only the used volume not to be dangling, got %s", out)
+	}
+	out, _, _ = dockerCmd(t, "volume", "ls", "-q", "-f", "label=com.example.team=storage")
+	if strings.TrimSpace(out) != "testunused" {
+		t.Fatalf("Expected the labeled volume to be listed, got %s", out)
+	}
+
+	runCmd := exec.Command(dockerBinary, "volume", "ls", "-f", "size=0")
+	if out, _, err := runCommandWithOutput(runCmd); err == nil || !strings.Contains(out, "Invalid filter") {
+		t.Fatalf("Expected an unknown filter to be refused: %s, %v", out, err)
+	}
+	logDone("volume - ls filtered by dangling and label")
+}
+
+func TestVolumePrune(t *testing.T) {
+	defer deleteAllContainers()
+	defer exec.Command(dockerBinary, "volume", "rm", "testpruneused").Run()
+
+	dockerCmd(t, "volume", "create", "--label", "com.example.temporary", "testprunetemp")
+	dockerCmd(t, "volume", "create", "testprunekept")
+	dockerCmd(t, "run", "--name", "user", "-v", "testpruneused:/data", "busybox", "true")
+	// the anonymous volume of the container is left behind by docker rm
+	out, _, _ := dockerCmd(t, "run", "-d", "-v", "/data", "busybox", "true")
+	dockerCmd(t, "rm", "-f", strings.TrimSpace(out))
+
+	// the prune is cancelled unless confirmed
+	runCmd := exec.Command(dockerBinary, "volume", "prune")
+	runCmd.Stdin = strings.NewReader("n\n")
+	if out, _, err := runCommandWithOutput(runCmd); err != nil {
+		t.Fatal(out, err)
+	}
+	out, _, _ = dockerCmd(t, "volume", "ls", "-q")
+	if !strings.Contains(out, "testprunetemp") {
+		t.Fatalf("Expected the prune to be cancelled, got %s", out)
+	}
+
+	out, _, _ = dockerCmd(t, "volume", "prune", "-f", "--filter", "label=com.example.temporary")
+	if !strings.Contains(out, "testprunetemp") || strings.Contains(out, "testprunekept") {
+		t.Fatalf("Expected only the labeled volume to be pruned, got %s", out)
+	}
+
+	out, _, _ = dockerCmd(t, "volume", "prune", "-f")
+	if !strings.Contains(out, "testprunekept") || !strings.Contains(out, "Total reclaimed space") {
+		t.Fatalf("Expected the unused volumes to be pruned, got %s", out)
+	}
+	out, _, _ = dockerCmd(t, "volume", "ls", "-q", "-f", "dangling=true")
+	if strings.TrimSpace(out) != "" {
+		t.Fatalf("Expected no unused volume to be left, got %s", out)
+	}
+	out, _, _ = dockerCmd(t, "volume", "ls", "-q")
+	if !strings.Contains(out, "testpruneused") {
+		t.Fatalf("Expected the used volume to be kept, got %s", out)
+	}
+	logDone("volume - prune the unused volumes")
+}
+
+func TestVolumeLocalTmpfs(t *testing.T) {
+	defer deleteAllContainers()
+	defer exec.Command(dockerBinary, "volume", "rm", "testtmpfs").Run()
+
+	dockerCmd(t, "volume", "create", "-o", "type=tmpfs", "-o", "o=size=1m", "testtmpfs")
+	out, _, _ := dockerCmd(t, "run", "-v", "testtmpfs:/data", "busybox", "sh", "-c", "grep ' /data ' /proc/mounts")
+	if !strings.Contains(out, "tmpfs") || !strings.Contains(out, "size=1024k") {
+		t.Fatalf("Expected a tmpfs of 1m to be mounted, got %s", out)
+	}
+
+	runCmd := exec.Command(dockerBinary, "run", "-v", "testtmpfs:/data", "busybox", "dd", "if=/dev/zero", "of=/data/file", "bs=1k", "count=2048")
+	if out, _, err := runCommandWithOutput(runCmd); err == nil {
+		t.Fatalf("Expected the size of the tmpfs to be limited: %s", out)
+	}
+
+	for _, args := range [][]string{
+		{"volume", "create", "-o", "type=ext4", "testnodevice"},
+		{"volume", "create", "-o", "device=/dev/sdb1", "testnotype"},
+	} {
+		runCmd := exec.Command(dockerBinary, args...)
+		if out, _, err := runCommandWithOutput(runCmd); err == nil {
+			exec.Command(dockerBinary, "volume", "rm", args[len(args)-1]).Run()
+			t.Fatalf("Expected %v to fail: %s", args, out)
+		}
+	}
+	logDone("volume - local volume on a tmpfs")
+}
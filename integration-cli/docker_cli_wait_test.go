@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitConditionNextExit(t *testing.T) {
+	defer deleteAllContainers()
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "create", "busybox", "sh", "-c", "exit 5"))
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	id := strings.TrimSpace(out)
+
+	waitCmd := exec.Command(dockerBinary, "wait", "--condition=next-exit", id)
+	done := make(chan error, 1)
+	var waitOut []byte
+	go func() {
+		var err error
+		waitOut, err = waitCmd.CombinedOutput()
+		done <- err
+	}()
+
+	// give the wait request time to reach the daemon before the container starts
+	time.Sleep(500 * time.Millisecond)
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "start", id)); err != nil {
+		t.Fatal(out, err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(string(waitOut), err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("docker wait --condition=next-exit did not return")
+	}
+	if status := strings.TrimSpace(string(waitOut)); status != "5" {
+		t.Fatalf("Expected exit code 5, got %q", status)
+	}
+
+	logDone("wait - --condition=next-exit waits for a container not started yet")
+}
+
+func TestWaitConditionRemoved(t *testing.T) {
+	defer deleteAllContainers()
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "-d", "busybox", "true"))
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	id := strings.TrimSpace(out)
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "wait", id)); err != nil {
+		t.Fatal(out, err)
+	}
+
+	waitCmd := exec.Command(dockerBinary, "wait", "--condition=removed", id)
+	done := make(chan error, 1)
+	var waitOut []byte
+	go func() {
+		var err error
+		waitOut, err = waitCmd.CombinedOutput()
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("docker wait --condition=removed returned before the container was removed")
+	case <-time.After(500 * time.Millisecond):
+	}
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "rm", id)); err != nil {
+		t.Fatal(out, err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(string(waitOut), err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("docker wait --condition=removed did not return")
+	}
+
+	logDone("wait - --condition=removed waits for the container removal")
+}
+
+func TestWaitInvalidCondition(t *testing.T) {
+	defer deleteAllContainers()
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "-d", "busybox", "true"))
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	id := strings.TrimSpace(out)
+
+	out, _, err = runCommandWithOutput(exec.Command(dockerBinary, "wait", "--condition=bogus", id))
+	if err == nil || !strings.Contains(out, "Invalid wait condition") {
+		t.Fatalf("Expected an invalid wait condition error, got %q: %v", out, err)
+	}
+
+	logDone("wait - an invalid --condition is rejected")
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/docker/docker/builtins"
 	"github.com/docker/docker/daemon"
 	"github.com/docker/docker/engine"
+	"github.com/docker/docker/graph"
 	flag "github.com/docker/docker/pkg/mflag"
 	"github.com/docker/docker/registry"
 	"github.com/docker/docker/runconfig"
@@ -191,6 +192,9 @@ func newTestEngine(t Fataler, autorestart bool, root string) *engine.Engine {
 		// otherwise NewDaemon will fail because of conflicting settings.
 		InterContainerCommunication: true,
 		TrustKeyPath:                filepath.Join(root, "key.json"),
+		MaxConcurrentDownloads:      graph.DefaultMaxConcurrentDownloads,
+		MaxConcurrentUploads:        graph.DefaultMaxConcurrentUploads,
+		ContentTrustPolicy:          graph.TrustPolicyNone,
 	}
 	d, err := daemon.NewDaemon(cfg, eng)
 	if err != nil {
@@ -20,6 +20,9 @@ const (
 type PortBinding struct {
 	HostIp   string
 	HostPort string
+	// NoProxy forwards the port with iptables only, without a userland
+	// proxy, the containers of the bridge reach it through hairpin NAT
+	NoProxy bool
 }
 
 type PortMap map[Port][]PortBinding
@@ -95,6 +98,18 @@ func ParsePortSpecs(ports []string) (map[Port]struct{}, map[Port][]PortBinding,
 	for _, rawPort := range ports {
 		proto := "tcp"
 
+		// options follow the port spec, as in 8080:80/tcp,noproxy
+		var noProxy bool
+		if i := strings.Index(rawPort, ","); i != -1 {
+			for _, opt := range strings.Split(rawPort[i+1:], ",") {
+				if opt != "noproxy" {
+					return nil, nil, fmt.Errorf("Invalid port option %s, only noproxy is supported", opt)
+				}
+				noProxy = true
+			}
+			rawPort = rawPort[:i]
+		}
+
 		if i := strings.LastIndex(rawPort, "/"); i != -1 {
 			proto = rawPort[i+1:]
 			rawPort = rawPort[:i]
@@ -136,7 +151,10 @@ func ParsePortSpecs(ports []string) (map[Port]struct{}, map[Port][]PortBinding,
 			}
 		}
 
-		if hostPort != "" && (endPort-startPort) != (endHostPort-startHostPort) {
+		// a single container port can be published on any port of a host
+		// range, a range of container ports on a host range of the same size
+		hostPortRange := hostPort != "" && startPort == endPort && startHostPort != endHostPort
+		if hostPort != "" && !hostPortRange && (endPort-startPort) != (endHostPort-startHostPort) {
 			return nil, nil, fmt.Errorf("Invalid ranges specified for container and host Ports: %s and %s", containerPort, hostPort)
 		}
 
@@ -146,7 +164,7 @@ func ParsePortSpecs(ports []string) (map[Port]struct{}, map[Port][]PortBinding,
 
 		for i := uint64(0); i <= (endPort - startPort); i++ {
 			containerPort = strconv.FormatUint(startPort+i, 10)
-			if len(hostPort) > 0 {
+			if len(hostPort) > 0 && !hostPortRange {
 				hostPort = strconv.FormatUint(startHostPort+i, 10)
 			}
 			port := NewPort(strings.ToLower(proto), containerPort)
@@ -157,6 +175,7 @@ func ParsePortSpecs(ports []string) (map[Port]struct{}, map[Port][]PortBinding,
 			binding := PortBinding{
 				HostIp:   rawIp,
 				HostPort: hostPort,
+				NoProxy:  noProxy,
 			}
 			bslice, exists := bindings[port]
 			if !exists {
@@ -281,7 +281,7 @@ func TestParsePortSpecsWithRange(t *testing.T) {
 	for portspec, bindings := range bindingMap {
 		_, port := SplitProtoPort(string(portspec))
 		if len(bindings) != 1 || bindings[0].HostIp != "0.0.0.0" || bindings[0].HostPort != port {
-			t.Fatalf("Expect single binding to port %s but found %s", port, bindings)
+			t.Fatalf("Expect single binding to port %s but found %v", port, bindings)
 		}
 	}
 
@@ -291,3 +291,38 @@ func TestParsePortSpecsWithRange(t *testing.T) {
 		t.Fatal("Received no error while trying to parse a hostname instead of ip")
 	}
 }
+
+func TestParsePortSpecsWithHostRange(t *testing.T) {
+	portMap, bindingMap, err := ParsePortSpecs([]string{"8000-8100:80/tcp"})
+	if err != nil {
+		t.Fatalf("Error while processing ParsePortSpecs: %s", err)
+	}
+	if _, ok := portMap[Port("80/tcp")]; !ok || len(portMap) != 1 {
+		t.Fatalf("Expected only 80/tcp to be exposed, got %v", portMap)
+	}
+	bindings := bindingMap[Port("80/tcp")]
+	if len(bindings) != 1 || bindings[0].HostPort != "8000-8100" {
+		t.Fatalf("Expected 80/tcp to be published on the range 8000-8100, got %v", bindings)
+	}
+
+	if _, _, err := ParsePortSpecs([]string{"8000-8100:80-81/tcp"}); err == nil {
+		t.Fatal("Received no error while trying to publish ranges of different sizes")
+	}
+}
+
+func TestParsePortSpecsWithoutProxy(t *testing.T) {
+	_, bindingMap, err := ParsePortSpecs([]string{"8080:80/tcp,noproxy", "1234/udp"})
+	if err != nil {
+		t.Fatalf("Error while processing ParsePortSpecs: %s", err)
+	}
+	if bindings := bindingMap[Port("80/tcp")]; len(bindings) != 1 || bindings[0].HostPort != "8080" || !bindings[0].NoProxy {
+		t.Fatalf("Expected 80/tcp to be published on 8080 without proxy, got %v", bindings)
+	}
+	if bindings := bindingMap[Port("1234/udp")]; len(bindings) != 1 || bindings[0].NoProxy {
+		t.Fatalf("Expected 1234/udp to be published with the proxy, got %v", bindings)
+	}
+
+	if _, _, err := ParsePortSpecs([]string{"8080:80,fast"}); err == nil {
+		t.Fatal("Received no error while parsing an unknown port option")
+	}
+}
@@ -0,0 +1,114 @@
+package networks
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/pkg/plugins"
+)
+
+// Driver sets up the host side of the networks of a given type
+type Driver interface {
+	// Create validates the options of the network and prepares the host
+	// for it. It is called again for the existing networks of the built-in
+	// drivers when the daemon starts, not for the network driver plugins.
+	Create(n *Network) error
+	// Delete undoes what Create did on the host
+	Delete(n *Network) error
+	// Join fills the driver specific fields of the interface of a
+	// container connected to the network
+	Join(n *Network, iface *execdriver.NetworkInterface) error
+}
+
+// EncryptionDriver is implemented by the drivers able to encrypt the
+// traffic of their networks, they are passed the EncryptedOption in the
+// options of the network
+type EncryptionDriver interface {
+	SupportsEncryption() bool
+}
+
+func supportsEncryption(driver Driver) bool {
+	d, ok := driver.(EncryptionDriver)
+	return ok && d.SupportsEncryption()
+}
+
+// MTUDriver is implemented by the drivers whose interfaces are given the
+// mtu of the MTUOption of their networks
+type MTUDriver interface {
+	SupportsMTU() bool
+}
+
+func supportsMTU(driver Driver) bool {
+	d, ok := driver.(MTUDriver)
+	return ok && d.SupportsMTU()
+}
+
+var (
+	drivers     = make(map[string]Driver)
+	driversLock sync.Mutex
+)
+
+// Register makes a network driver available under name
+func Register(name string, driver Driver) error {
+	driversLock.Lock()
+	defer driversLock.Unlock()
+	if _, exists := drivers[name]; exists {
+		return fmt.Errorf("Network driver already registered %s", name)
+	}
+	drivers[name] = driver
+	return nil
+}
+
+// GetDriver returns the network driver registered under name, or the
+// network driver plugin name, which is registered on its first use
+func GetDriver(name string) (Driver, error) {
+	if driver := getRegisteredDriver(name); driver != nil {
+		return driver, nil
+	}
+	// the plugin is activated without the lock, the activation can take
+	// a while and must not hold up the other networks
+	driver, err := getRemoteDriver(name)
+	switch err {
+	case nil:
+	case plugins.ErrNotFound:
+		return nil, fmt.Errorf("Unknown network driver %s, available drivers: %v", name, availableDrivers())
+	case plugins.ErrNotImplements:
+		return nil, fmt.Errorf("Plugin %s is not a network driver", name)
+	default:
+		return nil, fmt.Errorf("Network driver %s: %v", name, err)
+	}
+
+	driversLock.Lock()
+	defer driversLock.Unlock()
+	if registered, exists := drivers[name]; exists {
+		return registered, nil
+	}
+	drivers[name] = driver
+	return driver, nil
+}
+
+// getRegisteredDriver returns the built-in driver or the already activated
+// plugin registered under name, nil if there is none
+func getRegisteredDriver(name string) Driver {
+	driversLock.Lock()
+	defer driversLock.Unlock()
+	return drivers[name]
+}
+
+func availableDrivers() []string {
+	driversLock.Lock()
+	defer driversLock.Unlock()
+	return driverNames()
+}
+
+// driverNames must be called with driversLock held
+func driverNames() []string {
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
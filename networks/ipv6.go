@@ -0,0 +1,87 @@
+package networks
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ipv6PoolFile is where the generated pool of the repository is kept, so
+// that networks get their subnet from the same pool across restarts
+const ipv6PoolFile = "ipv6-pool"
+
+// EnableIPv6 gives every network created afterwards an IPv6 /64 allocated
+// from pool. A unique local /48 is generated, as described in RFC 4193,
+// when pool is empty.
+func (r *Repository) EnableIPv6(pool string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if pool == "" {
+		var err error
+		if pool, err = r.loadIPv6Pool(); err != nil {
+			return err
+		}
+	}
+	_, ipv6Pool, err := net.ParseCIDR(pool)
+	if err != nil {
+		return fmt.Errorf("Invalid IPv6 pool %s: %v", pool, err)
+	}
+	if ones, bits := ipv6Pool.Mask.Size(); bits != 128 || ones > 64 {
+		return fmt.Errorf("Invalid IPv6 pool %s: an IPv6 prefix of at most 64 bits is required", pool)
+	}
+	r.ipv6Pool = ipv6Pool
+	return nil
+}
+
+// loadIPv6Pool returns the generated pool of the repository, generating it
+// on first use
+func (r *Repository) loadIPv6Pool() (string, error) {
+	path := filepath.Join(r.configPath, ipv6PoolFile)
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	prefix := make(net.IP, net.IPv6len)
+	prefix[0] = 0xfd
+	if _, err := rand.Read(prefix[1:6]); err != nil {
+		return "", err
+	}
+	pool := (&net.IPNet{IP: prefix, Mask: net.CIDRMask(48, 128)}).String()
+	if err := ioutil.WriteFile(path, []byte(pool+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return pool, nil
+}
+
+// allocateIPv6Subnet returns the first /64 of the pool that is not used by
+// another network
+func (r *Repository) allocateIPv6Subnet() (string, error) {
+	used := make(map[uint64]bool)
+	for _, n := range r.networks {
+		if n.subnet6 != nil {
+			used[binary.BigEndian.Uint64(n.subnet6.IP.To16()[:8])] = true
+		}
+	}
+
+	ones, _ := r.ipv6Pool.Mask.Size()
+	base := binary.BigEndian.Uint64(r.ipv6Pool.IP.To16()[:8])
+	for i := uint64(0); i < 1<<uint(64-ones); i++ {
+		if used[base+i] {
+			continue
+		}
+		ip := make(net.IP, net.IPv6len)
+		binary.BigEndian.PutUint64(ip[:8], base+i)
+		return (&net.IPNet{IP: ip, Mask: net.CIDRMask(64, 128)}).String(), nil
+	}
+	return "", fmt.Errorf("No IPv6 subnet left in pool %s", r.ipv6Pool)
+}
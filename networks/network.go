@@ -0,0 +1,379 @@
+package networks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/networkdriver"
+	"github.com/docker/docker/daemon/networkdriver/ipallocator"
+)
+
+const (
+	// MTUOption sets the mtu of the interfaces of the containers of a
+	// network, such as a network on an encapsulated underlay
+	MTUOption = "com.docker.network.driver.mtu"
+
+	// minMTU is the smallest mtu of an IPv4 interface
+	minMTU = 68
+	maxMTU = 65535
+)
+
+// Network is a user-defined network containers can be connected to
+type Network struct {
+	ID      string
+	Name    string
+	Driver  string
+	Options map[string]string
+	Subnet  string
+	IPRange string
+	Gateway string
+	// IPv6Subnet is the /64 allocated to the network when IPv6 is enabled
+	IPv6Subnet  string `json:",omitempty"`
+	IPv6Gateway string `json:",omitempty"`
+	Created     time.Time
+	DNS         DNSConfig
+	// Internal networks give their containers no route out of their
+	// subnets, the gateway is not used
+	Internal bool `json:",omitempty"`
+	// IPAMDriver is the IPAM plugin allocating the IPv4 addresses of the
+	// network, they are allocated by the daemon when it is empty
+	IPAMDriver  string            `json:",omitempty"`
+	IPAMOptions map[string]string `json:",omitempty"`
+	IPAMPoolID  string            `json:",omitempty"`
+	// Labels are the metadata of the network, to select it with the label
+	// filters
+	Labels map[string]string `json:",omitempty"`
+	// DriverState is where the driver keeps what it needs to remember
+	// about the network, such as the host interfaces it created
+	DriverState map[string]string
+
+	subnet     *net.IPNet
+	subnet6    *net.IPNet
+	containers map[string]struct{}
+	// static are the addresses allocated in the subnet but out of the ip
+	// range, addresses are only allocated dynamically from the ip range
+	static     map[string]struct{}
+	configPath string
+	lock       sync.Mutex
+}
+
+// DNSConfig is the resolver configuration of the containers connected to a
+// network, unset fields fall back to the daemon and then the host settings
+type DNSConfig struct {
+	Nameservers []string `json:",omitempty"`
+	Search      []string `json:",omitempty"`
+	Options     []string `json:",omitempty"`
+}
+
+// Containers returns the IDs of the containers connected to the network
+func (n *Network) Containers() []string {
+	n.lock.Lock()
+	containers := make([]string, 0, len(n.containers))
+	for id := range n.containers {
+		containers = append(containers, id)
+	}
+	n.lock.Unlock()
+	sort.Strings(containers)
+	return containers
+}
+
+func (n *Network) AddContainer(containerID string) {
+	n.lock.Lock()
+	n.containers[containerID] = struct{}{}
+	n.lock.Unlock()
+}
+
+func (n *Network) RemoveContainer(containerID string) {
+	n.lock.Lock()
+	delete(n.containers, containerID)
+	n.lock.Unlock()
+}
+
+// MTU returns the mtu set with the MTUOption of the network, or 0 when the
+// network uses the mtu of the daemon
+func (n *Network) MTU() int {
+	mtu, _ := strconv.Atoi(n.Options[MTUOption])
+	return mtu
+}
+
+// PrefixLen returns the prefix length of the subnet of the network
+func (n *Network) PrefixLen() int {
+	size, _ := n.subnet.Mask.Size()
+	return size
+}
+
+// RequestIP allocates ip on the network, or the next free ip of the ip
+// range when ip is nil. An ip of the subnet out of the ip range can be
+// requested, it is never allocated dynamically.
+func (n *Network) RequestIP(ip net.IP) (net.IP, error) {
+	if n.IPAMDriver != "" {
+		ipam, err := getIPAM(n.IPAMDriver)
+		if err != nil {
+			return nil, err
+		}
+		return ipam.requestAddress(n, ip, nil)
+	}
+	allocated, err := ipallocator.RequestIP(n.subnet, ip)
+	if err != ipallocator.ErrIPOutOfRange || ip == nil {
+		return allocated, err
+	}
+	return n.requestStaticIP(ip)
+}
+
+// ReleaseIP gives ip back to the network
+func (n *Network) ReleaseIP(ip net.IP) error {
+	if n.IPAMDriver != "" {
+		ipam, err := getIPAM(n.IPAMDriver)
+		if err != nil {
+			return err
+		}
+		return ipam.releaseAddress(n, ip)
+	}
+	n.lock.Lock()
+	if _, exists := n.static[ip.String()]; exists {
+		delete(n.static, ip.String())
+		n.lock.Unlock()
+		return nil
+	}
+	n.lock.Unlock()
+	return ipallocator.ReleaseIP(n.subnet, ip)
+}
+
+func (n *Network) requestStaticIP(ip net.IP) (net.IP, error) {
+	first, last := networkdriver.NetworkRange(n.subnet)
+	if !n.subnet.Contains(ip) || ip.Equal(first) || ip.Equal(last) {
+		return nil, ipallocator.ErrIPOutOfRange
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if _, exists := n.static[ip.String()]; exists || ip.Equal(net.ParseIP(n.Gateway)) {
+		return nil, ipallocator.ErrIPAlreadyAllocated
+	}
+	if n.static == nil {
+		n.static = make(map[string]struct{})
+	}
+	n.static[ip.String()] = struct{}{}
+	return ip, nil
+}
+
+// IPv6PrefixLen returns the prefix length of the IPv6 subnet of the
+// network, or 0 when it has none
+func (n *Network) IPv6PrefixLen() int {
+	if n.subnet6 == nil {
+		return 0
+	}
+	size, _ := n.subnet6.Mask.Size()
+	return size
+}
+
+// RequestIPv6 allocates ip on the IPv6 subnet of the network, or the next
+// free ip when ip is nil. It returns nil when the network has no IPv6
+// subnet.
+func (n *Network) RequestIPv6(ip net.IP) (net.IP, error) {
+	if n.subnet6 == nil {
+		return nil, nil
+	}
+	return ipallocator.RequestIP(n.subnet6, ip)
+}
+
+// ReleaseIPv6 gives ip back to the IPv6 subnet of the network
+func (n *Network) ReleaseIPv6(ip net.IP) error {
+	if n.subnet6 == nil {
+		return nil
+	}
+	return ipallocator.ReleaseIP(n.subnet6, ip)
+}
+
+// validate checks the addressing of the network and fills the default
+// gateway, the first address of the subnet
+func (n *Network) validate() error {
+	if n.Subnet == "" {
+		return fmt.Errorf("A subnet is required for network %s", n.Name)
+	}
+	_, subnet, err := net.ParseCIDR(n.Subnet)
+	if err != nil {
+		return fmt.Errorf("Invalid subnet %s: %v", n.Subnet, err)
+	}
+	if subnet.IP.To4() == nil {
+		return fmt.Errorf("Invalid subnet %s: only IPv4 subnets are supported", n.Subnet)
+	}
+	n.Subnet = subnet.String()
+
+	if n.IPRange != "" {
+		_, ipRange, err := net.ParseCIDR(n.IPRange)
+		if err != nil {
+			return fmt.Errorf("Invalid ip range %s: %v", n.IPRange, err)
+		}
+		rangeSize, _ := ipRange.Mask.Size()
+		subnetSize, _ := subnet.Mask.Size()
+		if !subnet.Contains(ipRange.IP) || rangeSize < subnetSize {
+			return fmt.Errorf("Invalid ip range %s: it is not within subnet %s", n.IPRange, n.Subnet)
+		}
+		n.IPRange = ipRange.String()
+	}
+
+	if n.Gateway == "" {
+		gateway, _ := networkdriver.NetworkRange(subnet)
+		gateway[len(gateway)-1]++
+		n.Gateway = gateway.String()
+	}
+	gateway := net.ParseIP(n.Gateway)
+	if gateway == nil {
+		return fmt.Errorf("Invalid gateway %s", n.Gateway)
+	}
+	if !subnet.Contains(gateway) {
+		return fmt.Errorf("Invalid gateway %s: it is not within subnet %s", n.Gateway, n.Subnet)
+	}
+	n.Gateway = gateway.String()
+
+	if n.IPv6Subnet != "" {
+		_, subnet6, err := net.ParseCIDR(n.IPv6Subnet)
+		if err != nil || subnet6.IP.To4() != nil {
+			return fmt.Errorf("Invalid IPv6 subnet %s", n.IPv6Subnet)
+		}
+		n.IPv6Subnet = subnet6.String()
+		if n.IPv6Gateway == "" {
+			gateway := make(net.IP, net.IPv6len)
+			copy(gateway, subnet6.IP)
+			gateway[len(gateway)-1]++
+			n.IPv6Gateway = gateway.String()
+		}
+	}
+
+	for _, ns := range n.DNS.Nameservers {
+		if net.ParseIP(ns) == nil {
+			return fmt.Errorf("Invalid DNS server %s", ns)
+		}
+	}
+	for _, opt := range n.DNS.Options {
+		// the options are written to resolv.conf, there is no embedded DNS
+		// server whose answers a TTL would apply to
+		if name := strings.SplitN(opt, ":", 2)[0]; name == "ttl" {
+			return fmt.Errorf("Invalid DNS option %s, the TTL of the DNS answers cannot be set", opt)
+		}
+	}
+
+	if value, exists := n.Options[MTUOption]; exists {
+		mtu, err := strconv.Atoi(value)
+		if err != nil || mtu < minMTU || mtu > maxMTU {
+			return fmt.Errorf("Invalid mtu %s, it must be between %d and %d", value, minMTU, maxMTU)
+		}
+	}
+	return nil
+}
+
+// initialize sets up the allocation of the addresses of the network, the
+// gateway is never given to a container. The IPAM driver of the network
+// keeps track of its addresses itself.
+func (n *Network) initialize() error {
+	_, subnet, err := net.ParseCIDR(n.Subnet)
+	if err != nil {
+		return err
+	}
+	n.subnet = subnet
+	if n.IPAMDriver != "" {
+		return n.initializeIPv6()
+	}
+	if n.IPRange != "" {
+		_, ipRange, err := net.ParseCIDR(n.IPRange)
+		if err != nil {
+			return err
+		}
+		if err := ipallocator.RegisterSubnet(subnet, ipRange); err != nil {
+			return err
+		}
+	}
+	if _, err := ipallocator.RequestIP(subnet, net.ParseIP(n.Gateway)); err != nil && err != ipallocator.ErrIPOutOfRange {
+		ipallocator.ReleaseNetwork(subnet)
+		return err
+	}
+	if err := n.initializeIPv6(); err != nil {
+		ipallocator.ReleaseNetwork(subnet)
+		return err
+	}
+	return nil
+}
+
+func (n *Network) initializeIPv6() error {
+	if n.IPv6Subnet == "" {
+		return nil
+	}
+	_, subnet6, err := net.ParseCIDR(n.IPv6Subnet)
+	if err != nil {
+		return err
+	}
+	n.subnet6 = subnet6
+	if _, err := n.RequestIPv6(net.ParseIP(n.IPv6Gateway)); err != nil {
+		ipallocator.ReleaseNetwork(subnet6)
+		n.subnet6 = nil
+		return err
+	}
+	return nil
+}
+
+func (n *Network) release() {
+	if n.IPAMDriver != "" {
+		n.releasePool()
+	} else {
+		ipallocator.ReleaseNetwork(n.subnet)
+	}
+	if n.subnet6 != nil {
+		ipallocator.ReleaseNetwork(n.subnet6)
+	}
+}
+
+// releasePool gives the subnet of the network back to its IPAM driver
+func (n *Network) releasePool() {
+	if n.IPAMDriver == "" || n.IPAMPoolID == "" {
+		return
+	}
+	ipam, err := getIPAM(n.IPAMDriver)
+	if err == nil {
+		err = ipam.releasePool(n)
+	}
+	if err != nil {
+		log.Errorf("Error releasing the pool of network %s: %v", n.Name, err)
+	}
+	n.IPAMPoolID = ""
+}
+
+func (n *Network) ToDisk() error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.toDisk()
+}
+
+func (n *Network) toDisk() error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(n.configPath, 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(n.jsonPath(), data, 0600)
+}
+
+func (n *Network) FromDisk() error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	data, err := ioutil.ReadFile(n.jsonPath())
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, n)
+}
+
+func (n *Network) jsonPath() string {
+	return filepath.Join(n.configPath, "config.json")
+}
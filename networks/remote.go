@@ -0,0 +1,273 @@
+package networks
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/pkg/plugins"
+)
+
+const (
+	// NetworkDriverImplements is the subsystem implemented by the network
+	// driver plugins, they are used like the built-in drivers with -d
+	NetworkDriverImplements = "NetworkDriver"
+	// IPAMDriverImplements is the subsystem implemented by the IPAM
+	// plugins, which allocate the addresses of a network instead of the
+	// daemon
+	IPAMDriverImplements = "IpamDriver"
+
+	// gatewayAddressType is set in the options of the address request of
+	// the gateway of a network
+	gatewayAddressType = "com.docker.network.gateway"
+)
+
+// remoteDriver is a network driver plugin. The plugin prepares the host
+// for its networks, the interfaces of the containers are macvlan or ipvlan
+// interfaces created by the daemon on the parent the plugin chose.
+type remoteDriver struct {
+	name           string
+	plugin         *plugins.Plugin
+	encryption     bool
+	interfaceTypes map[string]bool
+}
+
+type capabilitiesResponse struct {
+	Scope          string
+	Encryption     bool
+	InterfaceTypes []string
+}
+
+// remoteInterfaceTypes are the types of the interfaces the daemon can create
+// for the network driver plugins
+var remoteInterfaceTypes = map[string]bool{
+	"macvlan": true,
+	"ipvlan":  true,
+}
+
+type createNetworkRequest struct {
+	NetworkID   string
+	Name        string
+	Options     map[string]string
+	Subnet      string
+	Gateway     string
+	IPv6Subnet  string `json:",omitempty"`
+	IPv6Gateway string `json:",omitempty"`
+	Internal    bool
+}
+
+type deleteNetworkRequest struct {
+	NetworkID string
+}
+
+type joinRequest struct {
+	NetworkID   string
+	Options     map[string]string
+	MacAddress  string
+	Address     string
+	IPv6Address string `json:",omitempty"`
+}
+
+type joinResponse struct {
+	InterfaceType string
+	Parent        string
+	Mode          string
+}
+
+// getRemoteDriver activates the network driver plugin name and checks its
+// capabilities, only local drivers whose interfaces are macvlan or ipvlan
+// interfaces are supported
+func getRemoteDriver(name string) (Driver, error) {
+	p, err := plugins.Get(name, NetworkDriverImplements)
+	if err != nil {
+		return nil, err
+	}
+	return newRemoteDriver(name, p)
+}
+
+func newRemoteDriver(name string, p *plugins.Plugin) (*remoteDriver, error) {
+	var caps capabilitiesResponse
+	if err := p.Client.Call("NetworkDriver.GetCapabilities", nil, &caps); err != nil {
+		return nil, err
+	}
+	if caps.Scope != "" && caps.Scope != "local" {
+		return nil, fmt.Errorf("Network driver %s has scope %s, only local drivers are supported", name, caps.Scope)
+	}
+	if len(caps.InterfaceTypes) == 0 {
+		return nil, fmt.Errorf("Network driver %s declares no InterfaceTypes, only macvlan and ipvlan interfaces are supported", name)
+	}
+	interfaceTypes := make(map[string]bool)
+	for _, typ := range caps.InterfaceTypes {
+		if !remoteInterfaceTypes[typ] {
+			return nil, fmt.Errorf("Network driver %s uses %s interfaces, only macvlan and ipvlan interfaces are supported", name, typ)
+		}
+		interfaceTypes[typ] = true
+	}
+	return &remoteDriver{name: name, plugin: p, encryption: caps.Encryption, interfaceTypes: interfaceTypes}, nil
+}
+
+// SupportsEncryption returns whether the plugin encrypts the traffic of the
+// networks created with the encrypted option
+func (d *remoteDriver) SupportsEncryption() bool {
+	return d.encryption
+}
+
+// SupportsMTU returns true, the daemon creates the interfaces of the
+// containers and sets their mtu
+func (d *remoteDriver) SupportsMTU() bool {
+	return true
+}
+
+func (d *remoteDriver) Create(n *Network) error {
+	req := &createNetworkRequest{
+		NetworkID:   n.ID,
+		Name:        n.Name,
+		Options:     n.Options,
+		Subnet:      n.Subnet,
+		Gateway:     n.Gateway,
+		IPv6Subnet:  n.IPv6Subnet,
+		IPv6Gateway: n.IPv6Gateway,
+		Internal:    n.Internal,
+	}
+	return d.plugin.Client.Call("NetworkDriver.CreateNetwork", req, nil)
+}
+
+func (d *remoteDriver) Delete(n *Network) error {
+	return d.plugin.Client.Call("NetworkDriver.DeleteNetwork", &deleteNetworkRequest{NetworkID: n.ID}, nil)
+}
+
+func (d *remoteDriver) Join(n *Network, iface *execdriver.NetworkInterface) error {
+	req := &joinRequest{
+		NetworkID:  n.ID,
+		Options:    n.Options,
+		MacAddress: iface.MacAddress,
+		Address:    fmt.Sprintf("%s/%d", iface.IPAddress, iface.IPPrefixLen),
+	}
+	if iface.GlobalIPv6Address != "" {
+		req.IPv6Address = fmt.Sprintf("%s/%d", iface.GlobalIPv6Address, iface.GlobalIPv6PrefixLen)
+	}
+	var res joinResponse
+	if err := d.plugin.Client.Call("NetworkDriver.Join", req, &res); err != nil {
+		return err
+	}
+	if !d.interfaceTypes[res.InterfaceType] {
+		return fmt.Errorf("Network driver %s returned the unsupported interface type %q, it declared %v", d.name, res.InterfaceType, d.declaredInterfaceTypes())
+	}
+	if res.Parent == "" {
+		return fmt.Errorf("Network driver %s returned no parent interface", d.name)
+	}
+	iface.Type = res.InterfaceType
+	iface.Parent = res.Parent
+	iface.Mode = res.Mode
+	return nil
+}
+
+func (d *remoteDriver) declaredInterfaceTypes() []string {
+	var types []string
+	for typ := range d.interfaceTypes {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// remoteIPAM is an IPAM plugin, it allocates the IPv4 addresses of the
+// networks created with it. The IPv6 subnets are still allocated by the
+// daemon.
+type remoteIPAM struct {
+	name   string
+	plugin *plugins.Plugin
+}
+
+type requestPoolRequest struct {
+	Pool    string
+	SubPool string
+	Options map[string]string
+}
+
+type requestPoolResponse struct {
+	PoolID  string
+	Pool    string
+	Gateway string
+}
+
+type releasePoolRequest struct {
+	PoolID string
+}
+
+type requestAddressRequest struct {
+	PoolID  string
+	Address string
+	Options map[string]string
+}
+
+type requestAddressResponse struct {
+	Address string
+}
+
+type releaseAddressRequest struct {
+	PoolID  string
+	Address string
+}
+
+func getIPAM(name string) (*remoteIPAM, error) {
+	p, err := plugins.Get(name, IPAMDriverImplements)
+	if err == plugins.ErrNotFound {
+		return nil, fmt.Errorf("Unknown IPAM driver %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("IPAM driver %s: %v", name, err)
+	}
+	return &remoteIPAM{name: name, plugin: p}, nil
+}
+
+// requestPool asks the plugin for the subnet of n, the requested one when
+// n has a subnet, and fills the unset subnet and gateway of n
+func (ipam *remoteIPAM) requestPool(n *Network) error {
+	req := &requestPoolRequest{Pool: n.Subnet, SubPool: n.IPRange, Options: n.IPAMOptions}
+	var res requestPoolResponse
+	if err := ipam.plugin.Client.Call("IpamDriver.RequestPool", req, &res); err != nil {
+		return err
+	}
+	if res.PoolID == "" {
+		return fmt.Errorf("IPAM driver %s returned no pool", ipam.name)
+	}
+	n.IPAMPoolID = res.PoolID
+	if n.Subnet == "" {
+		n.Subnet = res.Pool
+	}
+	if n.Gateway == "" {
+		n.Gateway = res.Gateway
+	}
+	return nil
+}
+
+func (ipam *remoteIPAM) releasePool(n *Network) error {
+	return ipam.plugin.Client.Call("IpamDriver.ReleasePool", &releasePoolRequest{PoolID: n.IPAMPoolID}, nil)
+}
+
+// requestAddress allocates ip on n, or any address when ip is nil
+func (ipam *remoteIPAM) requestAddress(n *Network, ip net.IP, options map[string]string) (net.IP, error) {
+	req := &requestAddressRequest{PoolID: n.IPAMPoolID, Options: options}
+	if ip != nil {
+		req.Address = ip.String()
+	}
+	var res requestAddressResponse
+	if err := ipam.plugin.Client.Call("IpamDriver.RequestAddress", req, &res); err != nil {
+		return nil, err
+	}
+	// the address may be returned with the prefix length of the pool
+	allocated := net.ParseIP(res.Address)
+	if allocated == nil {
+		allocated, _, _ = net.ParseCIDR(res.Address)
+	}
+	if allocated == nil || allocated.To4() == nil || !n.subnet.Contains(allocated) {
+		return nil, fmt.Errorf("IPAM driver %s returned the invalid address %q for subnet %s", ipam.name, res.Address, n.Subnet)
+	}
+	return allocated.To4(), nil
+}
+
+func (ipam *remoteIPAM) releaseAddress(n *Network, ip net.IP) error {
+	return ipam.plugin.Client.Call("IpamDriver.ReleaseAddress", &releaseAddressRequest{PoolID: n.IPAMPoolID, Address: ip.String()}, nil)
+}
@@ -0,0 +1,147 @@
+package networks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/pkg/plugins/pluginstest"
+)
+
+func TestRemoteDriver(t *testing.T) {
+	var created createNetworkRequest
+	scope := "local"
+	encryption := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/NetworkDriver.GetCapabilities", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"Scope": %q, "Encryption": %t, "InterfaceTypes": ["macvlan", "ipvlan"]}`, scope, encryption)
+	})
+	mux.HandleFunc("/NetworkDriver.CreateNetwork", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&created)
+		fmt.Fprintln(w, "{}")
+	})
+	mux.HandleFunc("/NetworkDriver.Join", func(w http.ResponseWriter, r *http.Request) {
+		var req joinRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Address != "10.80.0.2/24" || req.Options["vni"] != "42" {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, `{"Err": "unexpected join %+v"}`, req)
+			return
+		}
+		fmt.Fprintln(w, `{"InterfaceType": "ipvlan", "Parent": "vxlan42", "Mode": "l2"}`)
+	})
+	server, p := pluginstest.NewPlugin(t, mux)
+	defer server.Close()
+
+	d, err := newRemoteDriver("fake", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &Network{ID: "net1id", Name: "net1", Options: map[string]string{"vni": "42"}, Subnet: "10.80.0.0/24", Gateway: "10.80.0.1"}
+	if err := d.Create(n); err != nil {
+		t.Fatal(err)
+	}
+	if created.NetworkID != "net1id" || created.Subnet != "10.80.0.0/24" || created.Options["vni"] != "42" {
+		t.Fatalf("Unexpected create request %+v", created)
+	}
+
+	iface := &execdriver.NetworkInterface{IPAddress: "10.80.0.2", IPPrefixLen: 24}
+	if err := d.Join(n, iface); err != nil {
+		t.Fatal(err)
+	}
+	if iface.Type != "ipvlan" || iface.Parent != "vxlan42" || iface.Mode != "l2" {
+		t.Fatalf("Unexpected interface %+v", iface)
+	}
+	if supportsEncryption(d) {
+		t.Fatal("Expected the plugin not to support encryption")
+	}
+
+	encryption = true
+	if d, err = newRemoteDriver("fake", p); err != nil || !supportsEncryption(d) {
+		t.Fatalf("Expected the plugin to support encryption, got %v", err)
+	}
+
+	scope = "global"
+	if _, err := newRemoteDriver("fake", p); err == nil || !strings.Contains(err.Error(), "only local drivers are supported") {
+		t.Fatalf("Expected a global driver to be refused, got %v", err)
+	}
+}
+
+func TestRemoteDriverUnsupportedInterface(t *testing.T) {
+	caps := "{}"
+	mux := http.NewServeMux()
+	mux.HandleFunc("/NetworkDriver.GetCapabilities", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, caps)
+	})
+	mux.HandleFunc("/NetworkDriver.Join", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"InterfaceType": "ipvlan", "Parent": "br0"}`)
+	})
+	server, p := pluginstest.NewPlugin(t, mux)
+	defer server.Close()
+
+	if _, err := newRemoteDriver("fake", p); err == nil || !strings.Contains(err.Error(), "declares no InterfaceTypes") {
+		t.Fatalf("Expected a plugin declaring no interface types to be refused, got %v", err)
+	}
+	caps = `{"InterfaceTypes": ["macvlan", "veth"]}`
+	if _, err := newRemoteDriver("fake", p); err == nil || !strings.Contains(err.Error(), "uses veth interfaces") {
+		t.Fatalf("Expected a plugin using veth interfaces to be refused, got %v", err)
+	}
+
+	caps = `{"InterfaceTypes": ["macvlan"]}`
+	d, err := newRemoteDriver("fake", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.Join(&Network{ID: "net1id"}, &execdriver.NetworkInterface{IPAddress: "10.80.0.2", IPPrefixLen: 24})
+	if err == nil || !strings.Contains(err.Error(), "unsupported interface type") {
+		t.Fatalf("Expected an undeclared interface type to be refused, got %v", err)
+	}
+}
+
+func TestRemoteIPAM(t *testing.T) {
+	var requested requestAddressRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("/IpamDriver.RequestPool", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"PoolID": "pool1", "Pool": "10.90.0.0/16", "Gateway": "10.90.0.254"}`)
+	})
+	mux.HandleFunc("/IpamDriver.RequestAddress", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&requested)
+		if requested.Address == "10.91.0.1" {
+			fmt.Fprintln(w, `{"Address": "10.91.0.1/16"}`)
+			return
+		}
+		fmt.Fprintln(w, `{"Address": "10.90.3.4/16"}`)
+	})
+	server, p := pluginstest.NewPlugin(t, mux)
+	defer server.Close()
+	ipam := &remoteIPAM{name: "fake", plugin: p}
+
+	n := &Network{Name: "net1", IPAMDriver: "fake"}
+	if err := ipam.requestPool(n); err != nil {
+		t.Fatal(err)
+	}
+	if n.IPAMPoolID != "pool1" || n.Subnet != "10.90.0.0/16" || n.Gateway != "10.90.0.254" {
+		t.Fatalf("Unexpected pool %+v", n)
+	}
+	if err := n.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	ip, err := ipam.requestAddress(n, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.Equal(net.ParseIP("10.90.3.4")) || requested.PoolID != "pool1" {
+		t.Fatalf("Unexpected address %s requested with %+v", ip, requested)
+	}
+	if _, err := ipam.requestAddress(n, net.ParseIP("10.91.0.1"), nil); err == nil || !strings.Contains(err.Error(), "invalid address") {
+		t.Fatalf("Expected an address out of the subnet to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,360 @@
+package networks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/networkdriver"
+	"github.com/docker/docker/pkg/common"
+)
+
+const validNetworkNameChars = `[a-zA-Z0-9][a-zA-Z0-9_.-]`
+
+// EncryptedOption asks for the traffic of a network to be encrypted. It is
+// only accepted by the drivers implementing EncryptionDriver, the built-in
+// macvlan and ipvlan drivers put the containers straight on the network of
+// a host interface and have no tunnel between hosts to encrypt.
+const EncryptedOption = "encrypted"
+
+// parseEncryptedOption returns whether the options ask for the traffic of
+// a network to be encrypted, -o encrypted without a value is true
+func parseEncryptedOption(options map[string]string) (bool, error) {
+	value, ok := options[EncryptedOption]
+	if !ok {
+		return false, nil
+	}
+	if value == "" {
+		return true, nil
+	}
+	encrypted, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("Invalid %s option %q, it is true or false", EncryptedOption, value)
+	}
+	return encrypted, nil
+}
+
+var (
+	validNetworkNamePattern = regexp.MustCompile(`^` + validNetworkNameChars + `+$`)
+
+	// reservedNames are the network modes that are not user-defined
+	// networks
+	reservedNames = map[string]bool{
+		"bridge":    true,
+		"host":      true,
+		"none":      true,
+		"container": true,
+		"default":   true,
+	}
+)
+
+// Repository stores the user-defined networks
+type Repository struct {
+	configPath string
+	networks   map[string]*Network
+	ipv6Pool   *net.IPNet
+	// bridgeSubnet is the subnet of the default bridge, the user-defined
+	// networks share its ip allocator
+	bridgeSubnet *net.IPNet
+	lock         sync.Mutex
+}
+
+func NewRepository(configPath string) (*Repository, error) {
+	abspath, err := filepath.Abs(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(abspath, 0700); err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+
+	repo := &Repository{
+		configPath: abspath,
+		networks:   make(map[string]*Network),
+	}
+
+	return repo, repo.restore()
+}
+
+func (r *Repository) restore() error {
+	dir, err := ioutil.ReadDir(r.configPath)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range dir {
+		if !v.IsDir() {
+			continue
+		}
+		id := v.Name()
+		n := &Network{
+			ID:         id,
+			configPath: filepath.Join(r.configPath, id),
+			containers: make(map[string]struct{}),
+		}
+		if err := n.FromDisk(); err != nil {
+			log.Debugf("Error restoring network %s: %v", id, err)
+			continue
+		}
+		if n.DriverState == nil {
+			n.DriverState = make(map[string]string)
+		}
+		if err := n.initialize(); err != nil {
+			log.Errorf("Error restoring network %s: %v", n.Name, err)
+			continue
+		}
+		// Interfaces created by the built-in drivers do not survive a
+		// reboot. The network driver plugins keep their own state and are
+		// only activated when the network is used.
+		if driver := getRegisteredDriver(n.Driver); driver != nil {
+			err := driver.Create(n)
+			if err == nil {
+				err = n.ToDisk()
+			}
+			if err != nil {
+				log.Errorf("Error restoring network %s: %v", n.Name, err)
+			}
+		}
+		r.networks[n.ID] = n
+	}
+	return nil
+}
+
+// Config is the configuration of a network created by Repository.Create
+type Config struct {
+	// Driver is the name of the network driver, it is required
+	Driver  string
+	Options map[string]string
+	Subnet  string
+	// IPRange is the range of the subnet the addresses of the containers
+	// are allocated within
+	IPRange string
+	// Gateway defaults to the first address of the subnet
+	Gateway string
+	DNS     DNSConfig
+	// Internal networks give their containers no route out of their
+	// subnets
+	Internal bool
+	// IPAMDriver is the IPAM plugin allocating the subnet and the addresses
+	// of the network, the subnet is then optional
+	IPAMDriver  string
+	IPAMOptions map[string]string
+	Labels      map[string]string
+}
+
+// Create creates a network named name with the configuration config
+func (r *Repository) Create(name string, config Config) (*Network, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !validNetworkNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("Invalid network name (%s), only %s are allowed", name, validNetworkNameChars)
+	}
+	if reservedNames[name] {
+		return nil, fmt.Errorf("Network name %s is reserved", name)
+	}
+	if n := r.getByName(name); n != nil {
+		return nil, fmt.Errorf("Network with name %s already exists", name)
+	}
+	driverName, options := config.Driver, config.Options
+	if driverName == "" {
+		return nil, fmt.Errorf("A network driver is required, available drivers: %v", availableDrivers())
+	}
+	driver, err := GetDriver(driverName)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := parseEncryptedOption(options)
+	if err != nil {
+		return nil, err
+	}
+	if encrypted && !supportsEncryption(driver) {
+		return nil, fmt.Errorf("Network driver %s cannot encrypt the traffic of its networks, remove the %s option", driverName, EncryptedOption)
+	}
+	if _, exists := options[MTUOption]; exists && !supportsMTU(driver) {
+		return nil, fmt.Errorf("Network driver %s cannot set the mtu of its interfaces, remove the %s option", driverName, MTUOption)
+	}
+	if options == nil {
+		options = make(map[string]string)
+	}
+
+	n := &Network{
+		ID:          common.GenerateRandomID(),
+		Name:        name,
+		Driver:      driverName,
+		Options:     options,
+		Subnet:      config.Subnet,
+		IPRange:     config.IPRange,
+		Gateway:     config.Gateway,
+		Created:     time.Now().UTC(),
+		DNS:         config.DNS,
+		Internal:    config.Internal,
+		IPAMDriver:  config.IPAMDriver,
+		IPAMOptions: config.IPAMOptions,
+		Labels:      config.Labels,
+		DriverState: make(map[string]string),
+		containers:  make(map[string]struct{}),
+	}
+	n.configPath = filepath.Join(r.configPath, n.ID)
+	if r.ipv6Pool != nil {
+		if n.IPv6Subnet, err = r.allocateIPv6Subnet(); err != nil {
+			return nil, err
+		}
+	}
+	var ipam *remoteIPAM
+	if config.IPAMDriver != "" {
+		if ipam, err = getIPAM(config.IPAMDriver); err != nil {
+			return nil, err
+		}
+		if err := ipam.requestPool(n); err != nil {
+			return nil, err
+		}
+	}
+	if err := n.validate(); err != nil {
+		n.releasePool()
+		return nil, err
+	}
+	if err := r.checkOverlap(n); err != nil {
+		n.releasePool()
+		return nil, err
+	}
+	if err := n.initialize(); err != nil {
+		n.releasePool()
+		return nil, err
+	}
+	if ipam != nil {
+		if _, err := ipam.requestAddress(n, net.ParseIP(n.Gateway), map[string]string{"RequestAddressType": gatewayAddressType}); err != nil {
+			n.release()
+			return nil, err
+		}
+	}
+	if err := driver.Create(n); err != nil {
+		n.release()
+		return nil, err
+	}
+	if err := n.ToDisk(); err != nil {
+		driver.Delete(n)
+		n.release()
+		return nil, err
+	}
+	r.networks[n.ID] = n
+	return n, nil
+}
+
+// SetBridgeSubnet sets the subnet of the default bridge, the subnets of the
+// networks created then cannot overlap it
+func (r *Repository) SetBridgeSubnet(subnet *net.IPNet) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.bridgeSubnet = subnet
+	for _, n := range r.networks {
+		if n.subnet != nil && networkdriver.NetworkOverlaps(subnet, n.subnet) {
+			log.Warnf("The subnet %s of network %s overlaps with the default bridge network (%s)", n.Subnet, n.Name, subnet)
+		}
+	}
+}
+
+func (r *Repository) checkOverlap(n *Network) error {
+	_, subnet, _ := net.ParseCIDR(n.Subnet)
+	if r.bridgeSubnet != nil && networkdriver.NetworkOverlaps(subnet, r.bridgeSubnet) {
+		return fmt.Errorf("Subnet %s overlaps with the default bridge network (%s)", n.Subnet, r.bridgeSubnet)
+	}
+	for _, other := range r.networks {
+		if networkdriver.NetworkOverlaps(subnet, other.subnet) {
+			return fmt.Errorf("Subnet %s overlaps with network %s (%s)", n.Subnet, other.Name, other.Subnet)
+		}
+	}
+	return nil
+}
+
+// Get looks a network up by name, ID or unique ID prefix
+func (r *Repository) Get(nameOrID string) (*Network, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.get(nameOrID)
+}
+
+func (r *Repository) get(nameOrID string) (*Network, error) {
+	if n := r.getByName(nameOrID); n != nil {
+		return n, nil
+	}
+	if n, exists := r.networks[nameOrID]; exists {
+		return n, nil
+	}
+	var found *Network
+	for id, n := range r.networks {
+		if strings.HasPrefix(id, nameOrID) {
+			if found != nil {
+				return nil, fmt.Errorf("Network %s is ambiguous", nameOrID)
+			}
+			found = n
+		}
+	}
+	if found == nil || nameOrID == "" {
+		return nil, fmt.Errorf("No such network: %s", nameOrID)
+	}
+	return found, nil
+}
+
+func (r *Repository) getByName(name string) *Network {
+	for _, n := range r.networks {
+		if n.Name == name {
+			return n
+		}
+	}
+	return nil
+}
+
+// List returns the networks sorted by name
+func (r *Repository) List() []*Network {
+	r.lock.Lock()
+	networks := make([]*Network, 0, len(r.networks))
+	for _, n := range r.networks {
+		networks = append(networks, n)
+	}
+	r.lock.Unlock()
+	sort.Sort(byName(networks))
+	return networks
+}
+
+// Delete removes a network no container is connected to
+func (r *Repository) Delete(nameOrID string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	n, err := r.get(nameOrID)
+	if err != nil {
+		return err
+	}
+	if containers := n.Containers(); len(containers) > 0 {
+		return fmt.Errorf("Network %s is being used and cannot be removed: used by containers %s", n.Name, containers)
+	}
+	driver, err := GetDriver(n.Driver)
+	if err == nil {
+		err = driver.Delete(n)
+	}
+	if err != nil {
+		log.Errorf("Error cleaning up network %s: %v", n.Name, err)
+	}
+	if err := os.RemoveAll(n.configPath); err != nil {
+		return err
+	}
+	n.release()
+	delete(r.networks, n.ID)
+	return nil
+}
+
+type byName []*Network
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byName) Less(i, j int) bool { return s[i].Name < s[j].Name }
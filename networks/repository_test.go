@@ -0,0 +1,367 @@
+package networks
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/daemon/execdriver"
+)
+
+type fakeDriver struct{}
+
+func (d *fakeDriver) Create(n *Network) error {
+	n.DriverState["created"] = "true"
+	return nil
+}
+
+func (d *fakeDriver) Delete(n *Network) error {
+	return nil
+}
+
+func (d *fakeDriver) Join(n *Network, iface *execdriver.NetworkInterface) error {
+	iface.Type = "fake"
+	return nil
+}
+
+// fakeMTUDriver is a fakeDriver honoring the MTUOption
+type fakeMTUDriver struct {
+	fakeDriver
+}
+
+func (d *fakeMTUDriver) SupportsMTU() bool {
+	return true
+}
+
+func init() {
+	Register("fake", &fakeDriver{})
+	Register("fake-mtu", &fakeMTUDriver{})
+}
+
+func newRepo(t *testing.T) (*Repository, string) {
+	root, err := ioutil.TempDir(os.TempDir(), "networks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo, root
+}
+
+func TestRepositoryCreate(t *testing.T) {
+	repo, root := newRepo(t)
+	defer os.RemoveAll(root)
+
+	n, err := repo.Create("net1", Config{Driver: "fake", Subnet: "10.10.0.0/16"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Delete("net1")
+	if n.Gateway != "10.10.0.1" {
+		t.Fatalf("Expected the gateway to default to 10.10.0.1, got %s", n.Gateway)
+	}
+	if n.PrefixLen() != 16 {
+		t.Fatalf("Expected prefix length 16, got %d", n.PrefixLen())
+	}
+	if n.DriverState["created"] != "true" {
+		t.Fatal("Expected the driver to create the network")
+	}
+
+	// the gateway is never given to a container
+	ip, err := n.RequestIP(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip.String() != "10.10.0.2" {
+		t.Fatalf("Expected 10.10.0.2, got %s", ip)
+	}
+
+	for _, nameOrID := range []string{"net1", n.ID, n.ID[:12]} {
+		found, err := repo.Get(nameOrID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found != n {
+			t.Fatalf("Expected to find net1 with %s", nameOrID)
+		}
+	}
+	if _, err := repo.Get("unknown"); err == nil {
+		t.Fatal("Expected an error for an unknown network")
+	}
+}
+
+func TestRepositoryCreateInvalid(t *testing.T) {
+	repo, root := newRepo(t)
+	defer os.RemoveAll(root)
+
+	if _, err := repo.Create("net1", Config{Driver: "fake", Subnet: "10.20.0.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Delete("net1")
+	_, bridgeSubnet, _ := net.ParseCIDR("172.17.0.0/16")
+	repo.SetBridgeSubnet(bridgeSubnet)
+
+	for _, c := range []struct {
+		name, driver, subnet, ipRange, gateway, expected string
+	}{
+		{"net1", "fake", "10.30.0.0/24", "", "", "already exists"},
+		{"bridge", "fake", "10.30.0.0/24", "", "", "is reserved"},
+		{"a:b", "fake", "10.30.0.0/24", "", "", "Invalid network name"},
+		{"net2", "", "10.30.0.0/24", "", "", "driver is required"},
+		{"net2", "unknown", "10.30.0.0/24", "", "", "Unknown network driver"},
+		{"net2", "fake", "", "", "", "subnet is required"},
+		{"net2", "fake", "10.30.0.0", "", "", "Invalid subnet"},
+		{"net2", "fake", "fd00::/64", "", "", "only IPv4"},
+		{"net2", "fake", "10.30.0.0/24", "10.40.0.0/28", "", "not within subnet"},
+		{"net2", "fake", "10.30.0.0/24", "", "10.40.0.1", "not within subnet"},
+		{"net2", "fake", "10.20.0.0/16", "", "", "overlaps with network net1"},
+		{"net2", "fake", "172.17.5.0/24", "", "", "overlaps with the default bridge"},
+	} {
+		_, err := repo.Create(c.name, Config{Driver: c.driver, Subnet: c.subnet, IPRange: c.ipRange, Gateway: c.gateway})
+		if err == nil || !strings.Contains(err.Error(), c.expected) {
+			t.Fatalf("Expected error containing %q for %+v, got %v", c.expected, c, err)
+		}
+	}
+
+	if _, err := repo.Create("net2", Config{Driver: "fake", Subnet: "10.30.0.0/24", DNS: DNSConfig{Nameservers: []string{"dns.example.com"}}}); err == nil || !strings.Contains(err.Error(), "Invalid DNS server") {
+		t.Fatalf("Expected an invalid DNS server error, got %v", err)
+	}
+	if _, err := repo.Create("net2", Config{Driver: "fake", Subnet: "10.30.0.0/24", DNS: DNSConfig{Options: []string{"ttl:30"}}}); err == nil || !strings.Contains(err.Error(), "TTL of the DNS answers cannot be set") {
+		t.Fatalf("Expected the ttl DNS option to be refused, got %v", err)
+	}
+	if _, err := repo.Create("net2", Config{Driver: "fake", Options: map[string]string{EncryptedOption: ""}, Subnet: "10.30.0.0/24"}); err == nil || !strings.Contains(err.Error(), "cannot encrypt") {
+		t.Fatalf("Expected the encrypted option to be refused, got %v", err)
+	}
+	if _, err := repo.Create("net2", Config{Driver: "fake", Options: map[string]string{EncryptedOption: "true"}, Subnet: "10.30.0.0/24"}); err == nil || !strings.Contains(err.Error(), "cannot encrypt") {
+		t.Fatalf("Expected encrypted=true to be refused, got %v", err)
+	}
+	if _, err := repo.Create("net2", Config{Driver: "fake", Options: map[string]string{EncryptedOption: "maybe"}, Subnet: "10.30.0.0/24"}); err == nil || !strings.Contains(err.Error(), "Invalid encrypted option") {
+		t.Fatalf("Expected an invalid encrypted option error, got %v", err)
+	}
+	if encrypted, err := parseEncryptedOption(map[string]string{EncryptedOption: "false"}); err != nil || encrypted {
+		t.Fatalf("Expected encrypted=false not to ask for encryption, got %v, %v", encrypted, err)
+	}
+	for _, mtu := range []string{"", "jumbo", "67", "65536"} {
+		if _, err := repo.Create("net2", Config{Driver: "fake-mtu", Options: map[string]string{MTUOption: mtu}, Subnet: "10.30.0.0/24"}); err == nil || !strings.Contains(err.Error(), "Invalid mtu") {
+			t.Fatalf("Expected the mtu %q to be refused, got %v", mtu, err)
+		}
+	}
+}
+
+func TestRepositoryMTU(t *testing.T) {
+	repo, root := newRepo(t)
+	defer os.RemoveAll(root)
+
+	if _, err := repo.Create("net1", Config{Driver: "fake", Options: map[string]string{MTUOption: "1450"}, Subnet: "10.25.0.0/24"}); err == nil || !strings.Contains(err.Error(), "cannot set the mtu") {
+		t.Fatalf("Expected the mtu option to be refused by a driver not honoring it, got %v", err)
+	}
+
+	n, err := repo.Create("net1", Config{Driver: "fake-mtu", Options: map[string]string{MTUOption: "1450"}, Subnet: "10.25.0.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Delete("net1")
+	if n.MTU() != 1450 {
+		t.Fatalf("Expected the mtu 1450, got %d", n.MTU())
+	}
+
+	other, err := repo.Create("net2", Config{Driver: "fake", Subnet: "10.26.0.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Delete("net2")
+	if other.MTU() != 0 {
+		t.Fatalf("Expected no mtu for a network without the option, got %d", other.MTU())
+	}
+}
+
+func TestRepositoryIPRange(t *testing.T) {
+	repo, root := newRepo(t)
+	defer os.RemoveAll(root)
+
+	n, err := repo.Create("net1", Config{Driver: "fake", Subnet: "10.50.0.0/16", IPRange: "10.50.1.0/24", Gateway: "10.50.0.254"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Delete("net1")
+
+	ip, err := n.RequestIP(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip.String() != "10.50.1.1" {
+		t.Fatalf("Expected an ip from the range, got %s", ip)
+	}
+
+	// addresses of the subnet out of the range can be requested
+	ip, err = n.RequestIP(net.ParseIP("10.50.2.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip.String() != "10.50.2.1" {
+		t.Fatalf("Expected 10.50.2.1, got %s", ip)
+	}
+	if _, err := n.RequestIP(net.ParseIP("10.50.2.1")); err == nil {
+		t.Fatal("Expected an error for an ip allocated twice")
+	}
+	if err := n.ReleaseIP(ip); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.RequestIP(net.ParseIP("10.50.2.1")); err != nil {
+		t.Fatalf("Expected a released ip to be allocated again, got %v", err)
+	}
+	for _, ip := range []string{"10.60.0.1", "10.50.0.254", "10.50.0.0", "10.50.255.255"} {
+		if _, err := n.RequestIP(net.ParseIP(ip)); err == nil {
+			t.Fatalf("Expected an error for ip %s", ip)
+		}
+	}
+}
+
+func TestRepositoryRestore(t *testing.T) {
+	repo, root := newRepo(t)
+	defer os.RemoveAll(root)
+
+	n, err := repo.Create("net1", Config{Driver: "fake", Options: map[string]string{"parent": "eth0"}, Subnet: "10.60.0.0/24", DNS: DNSConfig{Nameservers: []string{"10.60.0.53"}, Search: []string{"example.com"}}, Internal: true, Labels: map[string]string{"com.example.tenant": "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.release()
+
+	repo, err = NewRepository(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := repo.Get("net1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Delete("net1")
+	if restored.ID != n.ID || restored.Subnet != n.Subnet || restored.Gateway != n.Gateway || restored.Options["parent"] != "eth0" || len(restored.DNS.Nameservers) != 1 || restored.DNS.Search[0] != "example.com" || !restored.Internal || restored.Labels["com.example.tenant"] != "a" {
+		t.Fatalf("Expected %+v to be restored, got %+v", n, restored)
+	}
+	if _, err := restored.RequestIP(net.ParseIP(n.Gateway)); err == nil {
+		t.Fatal("Expected the gateway to be reserved after a restore")
+	}
+
+	// the networks of the plugins are restored without activating them
+	restored.Driver = "unregisteredplugin"
+	delete(restored.DriverState, "created")
+	if err := restored.ToDisk(); err != nil {
+		t.Fatal(err)
+	}
+	restored.release()
+	repo, err = NewRepository(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err = repo.Get("net1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.DriverState["created"] != "" {
+		t.Fatal("Expected the network of a plugin not to be created again")
+	}
+	restored.Driver = "fake"
+}
+
+func TestRepositoryDelete(t *testing.T) {
+	repo, root := newRepo(t)
+	defer os.RemoveAll(root)
+
+	n, err := repo.Create("net1", Config{Driver: "fake", Subnet: "10.70.0.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.AddContainer("container1")
+	if err := repo.Delete("net1"); err == nil || !strings.Contains(err.Error(), "is being used") {
+		t.Fatalf("Expected an error removing a network in use, got %v", err)
+	}
+	n.RemoveContainer("container1")
+	if err := repo.Delete("net1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Get("net1"); err == nil {
+		t.Fatal("Expected net1 to be removed")
+	}
+	if _, err := os.Stat(n.configPath); !os.IsNotExist(err) {
+		t.Fatalf("Expected the config of net1 to be removed, got %v", err)
+	}
+
+	// the subnet can be used again
+	if _, err := repo.Create("net1", Config{Driver: "fake", Subnet: "10.70.0.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+	repo.Delete("net1")
+}
+
+func TestRepositoryIPv6(t *testing.T) {
+	repo, root := newRepo(t)
+	defer os.RemoveAll(root)
+
+	if err := repo.EnableIPv6("fd00:1::/63"); err != nil {
+		t.Fatal(err)
+	}
+	n1, err := repo.Create("net1", Config{Driver: "fake", Subnet: "10.80.0.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Delete("net1")
+	if n1.IPv6Subnet != "fd00:1::/64" || n1.IPv6Gateway != "fd00:1::1" || n1.IPv6PrefixLen() != 64 {
+		t.Fatalf("Expected fd00:1::/64 with gateway fd00:1::1, got %s %s", n1.IPv6Subnet, n1.IPv6Gateway)
+	}
+	ip, err := n1.RequestIPv6(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip.String() != "fd00:1::2" {
+		t.Fatalf("Expected the first container to get fd00:1::2, got %s", ip)
+	}
+
+	n2, err := repo.Create("net2", Config{Driver: "fake", Subnet: "10.80.1.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Delete("net2")
+	if n2.IPv6Subnet != "fd00:1:0:1::/64" {
+		t.Fatalf("Expected the next /64 of the pool, got %s", n2.IPv6Subnet)
+	}
+
+	if _, err := repo.Create("net3", Config{Driver: "fake", Subnet: "10.80.2.0/24"}); err == nil || !strings.Contains(err.Error(), "No IPv6 subnet left") {
+		t.Fatalf("Expected the pool to be exhausted, got %v", err)
+	}
+
+	for _, pool := range []string{"10.0.0.0/8", "fd00::/96", "fd00::"} {
+		if err := repo.EnableIPv6(pool); err == nil {
+			t.Fatalf("Expected pool %s to be invalid", pool)
+		}
+	}
+}
+
+func TestRepositoryIPv6GeneratedPool(t *testing.T) {
+	repo, root := newRepo(t)
+	defer os.RemoveAll(root)
+
+	if err := repo.EnableIPv6(""); err != nil {
+		t.Fatal(err)
+	}
+	pool := repo.ipv6Pool.String()
+	if !strings.HasPrefix(pool, "fd") || !strings.HasSuffix(pool, "::/48") {
+		t.Fatalf("Expected a unique local /48, got %s", pool)
+	}
+
+	// the generated pool is kept across restarts
+	repo, err := NewRepository(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.EnableIPv6(""); err != nil {
+		t.Fatal(err)
+	}
+	if repo.ipv6Pool.String() != pool {
+		t.Fatalf("Expected pool %s to be kept, got %s", pool, repo.ipv6Pool)
+	}
+}
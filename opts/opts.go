@@ -6,6 +6,7 @@ import (
 	"os"
 	"path"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/docker/docker/api"
@@ -16,8 +17,10 @@ import (
 )
 
 var (
-	alphaRegexp  = regexp.MustCompile(`[a-zA-Z]`)
-	domainRegexp = regexp.MustCompile(`^(:?(:?[a-zA-Z0-9]|(:?[a-zA-Z0-9][a-zA-Z0-9\-]*[a-zA-Z0-9]))(:?\.(:?[a-zA-Z0-9]|(:?[a-zA-Z0-9][a-zA-Z0-9\-]*[a-zA-Z0-9])))*)\.?\s*$`)
+	alphaRegexp            = regexp.MustCompile(`[a-zA-Z]`)
+	deviceCgroupRuleRegexp = regexp.MustCompile(`^([acb]) ([0-9]+|\*):([0-9]+|\*) ([rwm]{1,3})$`)
+	networkAliasRegexp     = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+	domainRegexp           = regexp.MustCompile(`^(:?(:?[a-zA-Z0-9]|(:?[a-zA-Z0-9][a-zA-Z0-9\-]*[a-zA-Z0-9]))(:?\.(:?[a-zA-Z0-9]|(:?[a-zA-Z0-9][a-zA-Z0-9\-]*[a-zA-Z0-9])))*)\.?\s*$`)
 )
 
 func ListVar(values *[]string, names []string, usage string) {
@@ -229,9 +232,61 @@ func ValidateExtraHost(val string) (string, error) {
 	return val, nil
 }
 
+// ValidateNetworkAlias validates an extra name of a container on its
+// network, aliases are written to the hosts file of the other containers.
+func ValidateNetworkAlias(val string) (string, error) {
+	if !networkAliasRegexp.MatchString(val) {
+		return "", fmt.Errorf("invalid network alias: %q", val)
+	}
+	return val, nil
+}
+
 func ValidateLabel(val string) (string, error) {
 	if strings.Count(val, "=") != 1 {
 		return "", fmt.Errorf("bad attribute format: %s", val)
 	}
 	return val, nil
 }
+
+// DeviceCgroupRule is a rule of the devices cgroup, a Major or Minor number
+// of -1 matches any number
+type DeviceCgroupRule struct {
+	Type        rune
+	Major       int64
+	Minor       int64
+	Permissions string
+}
+
+// ParseDeviceCgroupRule parses a device cgroup rule in the format used by
+// the devices.allow cgroup file, such as "c 189:* rwm".
+func ParseDeviceCgroupRule(val string) (*DeviceCgroupRule, error) {
+	match := deviceCgroupRuleRegexp.FindStringSubmatch(val)
+	if match == nil {
+		return nil, fmt.Errorf("invalid device cgroup rule format: %q", val)
+	}
+	rule := &DeviceCgroupRule{
+		Type:        rune(match[1][0]),
+		Permissions: match[4],
+	}
+	for i, number := range []*int64{&rule.Major, &rule.Minor} {
+		if match[i+2] == "*" {
+			*number = -1
+			continue
+		}
+		n, err := strconv.ParseInt(match[i+2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid device number %q in device cgroup rule %q", match[i+2], val)
+		}
+		*number = n
+	}
+	return rule, nil
+}
+
+// ValidateDeviceCgroupRule validates a device cgroup rule in the format used
+// by the devices.allow cgroup file, such as "c 189:* rwm".
+func ValidateDeviceCgroupRule(val string) (string, error) {
+	if _, err := ParseDeviceCgroupRule(val); err != nil {
+		return "", err
+	}
+	return val, nil
+}
@@ -152,3 +152,44 @@ func TestValidateExtraHosts(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateDeviceCgroupRule(t *testing.T) {
+	valid := []string{
+		"a 7:* rwm",
+		"c 189:* rwm",
+		"b 8:1 r",
+		"c *:* m",
+	}
+	invalid := []string{
+		"",
+		"c 189",
+		"x 7:* rwm",
+		"c 189:* rwx",
+		"c 189:* ",
+		"c189:*rwm",
+	}
+
+	for _, rule := range valid {
+		if _, err := ValidateDeviceCgroupRule(rule); err != nil {
+			t.Fatalf("ValidateDeviceCgroupRule(%q) should succeed: error %v", rule, err)
+		}
+	}
+	for _, rule := range invalid {
+		if _, err := ValidateDeviceCgroupRule(rule); err == nil {
+			t.Fatalf("ValidateDeviceCgroupRule(%q) should have failed validation", rule)
+		}
+	}
+}
+
+func TestValidateNetworkAlias(t *testing.T) {
+	for _, alias := range []string{"db", "web.example.com", "my_service-1"} {
+		if _, err := ValidateNetworkAlias(alias); err != nil {
+			t.Fatalf("ValidateNetworkAlias(%q) should succeed: error %v", alias, err)
+		}
+	}
+	for _, alias := range []string{"", "-db", "two words", "db\tweb"} {
+		if _, err := ValidateNetworkAlias(alias); err == nil {
+			t.Fatalf("ValidateNetworkAlias(%q) should have failed validation", alias)
+		}
+	}
+}
@@ -13,8 +13,10 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/docker/docker/vendor/src/code.google.com/p/go/src/pkg/archive/tar"
 
@@ -35,6 +37,17 @@ type (
 		Compression     Compression
 		NoLchown        bool
 		Name            string
+		// IncludeXattrs archives all the extended attributes of the files,
+		// rather than only their capabilities
+		IncludeXattrs bool
+		// ChownOpts sets the owner of the unpacked files, rather than the
+		// owner recorded in the archive
+		ChownOpts *TarChownOptions
+	}
+
+	// TarChownOptions is the owner given to the unpacked files
+	TarChownOptions struct {
+		UID, GID int
 	}
 
 	// Archiver allows the reuse of most utility functions of this package
@@ -59,6 +72,7 @@ const (
 	Bzip2
 	Gzip
 	Xz
+	Zstd
 )
 
 func IsArchive(header []byte) bool {
@@ -76,6 +90,7 @@ func DetectCompression(source []byte) Compression {
 		Bzip2: {0x42, 0x5A, 0x68},
 		Gzip:  {0x1F, 0x8B, 0x08},
 		Xz:    {0xFD, 0x37, 0x7A, 0x58, 0x5A, 0x00},
+		Zstd:  {0x28, 0xB5, 0x2F, 0xFD},
 	} {
 		if len(source) < len(m) {
 			log.Debugf("Len too short")
@@ -89,9 +104,57 @@ func DetectCompression(source []byte) Compression {
 }
 
 func xzDecompress(archive io.Reader) (io.ReadCloser, error) {
-	args := []string{"xz", "-d", "-c", "-q"}
+	return cmdDecompress(archive, "xz", "-d", "-c", "-q")
+}
+
+func zstdDecompress(archive io.Reader) (io.ReadCloser, error) {
+	return cmdDecompress(archive, "zstd", "-d", "-c", "-q")
+}
+
+// cmdDecompress decompresses archive with the command name, which must be
+// installed on the host as there is no Go implementation of the format
+func cmdDecompress(archive io.Reader, name string, args ...string) (io.ReadCloser, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, fmt.Errorf("%s is required to decompress the archive: %v", name, err)
+	}
+	return CmdStream(exec.Command(name, args...), archive)
+}
 
-	return CmdStream(exec.Command(args[0], args[1:]...), archive)
+// cmdCompress compresses the data written to the returned writer into dest
+// with the command name, which must be installed on the host. Closing the
+// writer waits for the command to exit.
+func cmdCompress(dest io.Writer, name string, args ...string) (io.WriteCloser, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, fmt.Errorf("%s is required to compress the archive: %v", name, err)
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = dest
+	errBuf := new(bytes.Buffer)
+	cmd.Stderr = errBuf
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &cmdWriter{WriteCloser: stdin, cmd: cmd, errBuf: errBuf}, nil
+}
+
+type cmdWriter struct {
+	io.WriteCloser
+	cmd    *exec.Cmd
+	errBuf *bytes.Buffer
+}
+
+func (w *cmdWriter) Close() error {
+	if err := w.WriteCloser.Close(); err != nil {
+		return err
+	}
+	if err := w.cmd.Wait(); err != nil {
+		return fmt.Errorf("%s: %s", err, w.errBuf.String())
+	}
+	return nil
 }
 
 func DecompressStream(archive io.Reader) (io.ReadCloser, error) {
@@ -125,6 +188,13 @@ func DecompressStream(archive io.Reader) (io.ReadCloser, error) {
 		}
 		readBufWrapper := p.NewReadCloserWrapper(buf, xzReader)
 		return readBufWrapper, nil
+	case Zstd:
+		zstdReader, err := zstdDecompress(buf)
+		if err != nil {
+			return nil, err
+		}
+		readBufWrapper := p.NewReadCloserWrapper(buf, zstdReader)
+		return readBufWrapper, nil
 	default:
 		return nil, fmt.Errorf("Unsupported compression format %s", (&compression).Extension())
 	}
@@ -141,6 +211,9 @@ func CompressStream(dest io.WriteCloser, compression Compression) (io.WriteClose
 		gzWriter := gzip.NewWriter(dest)
 		writeBufWrapper := p.NewWriteCloserWrapper(buf, gzWriter)
 		return writeBufWrapper, nil
+	case Zstd:
+		p.Put(buf)
+		return cmdCompress(dest, "zstd", "-c", "-q")
 	case Bzip2, Xz:
 		// archive/bzip2 does not support writing, and there is no xz support at all
 		// However, this is not a problem as docker only currently generates gzipped tars
@@ -160,6 +233,8 @@ func (compression *Compression) Extension() string {
 		return "tar.gz"
 	case Xz:
 		return "tar.xz"
+	case Zstd:
+		return "tar.zst"
 	}
 	return ""
 }
@@ -170,6 +245,28 @@ type tarAppender struct {
 
 	// for hardlink mapping
 	SeenFiles map[uint64]string
+
+	// Xattrs archives all the extended attributes
+	Xattrs bool
+}
+
+// addXattrs records all the extended attributes of path in hdr
+func addXattrs(hdr *tar.Header, path string) error {
+	names, err := system.Llistxattr(path)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		value, err := system.Lgetxattr(path, name)
+		if err != nil {
+			return err
+		}
+		if hdr.Xattrs == nil {
+			hdr.Xattrs = make(map[string]string)
+		}
+		hdr.Xattrs[name] = string(value)
+	}
+	return nil
 }
 
 // canonicalTarName provides a platform-independent and consistent posix-style
@@ -236,6 +333,11 @@ func (ta *tarAppender) addTarFile(path, name string) error {
 		hdr.Xattrs = make(map[string]string)
 		hdr.Xattrs["security.capability"] = string(capability)
 	}
+	if ta.Xattrs {
+		if err := addXattrs(hdr, path); err != nil {
+			return err
+		}
+	}
 
 	if err := ta.TarWriter.WriteHeader(hdr); err != nil {
 		return err
@@ -407,6 +509,7 @@ func TarWithOptions(srcPath string, options *TarOptions) (io.ReadCloser, error)
 			TarWriter: tar.NewWriter(compressWriter),
 			Buffer:    pools.BufioWriter32KPool.Get(nil),
 			SeenFiles: make(map[uint64]string),
+			Xattrs:    options.IncludeXattrs,
 		}
 		// this buffer is needed for the duration of this piped stream
 		defer pools.BufioWriter32KPool.Put(ta.Buffer)
@@ -559,6 +662,9 @@ loop:
 				}
 			}
 		}
+		if options.ChownOpts != nil {
+			hdr.Uid, hdr.Gid = options.ChownOpts.UID, options.ChownOpts.GID
+		}
 		trBuf.Reset(tr)
 		if err := createTarFile(path, dest, hdr, trBuf, !options.NoLchown); err != nil {
 			return err
@@ -727,6 +833,144 @@ func CopyFileWithTar(src, dst string) (err error) {
 	return defaultArchiver.CopyFileWithTar(src, dst)
 }
 
+// ResetModTimes returns the uncompressed archive in with the modification
+// time of all its entries set to t, so that archives of the same files are
+// identical whenever they were modified.
+func ResetModTimes(in ArchiveReader, t time.Time) Archive {
+	pr, pw := io.Pipe()
+	go func() {
+		tr := tar.NewReader(in)
+		tw := tar.NewWriter(pw)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			hdr.ModTime = t
+			hdr.AccessTime = t
+			hdr.ChangeTime = t
+			if err := tw.WriteHeader(hdr); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(tw.Close())
+	}()
+	return pr
+}
+
+// AddArchives returns the archive base, which may be compressed, followed by
+// the entries of each of the uncompressed archives of extra under the
+// directory it is mapped to, in the order of the directories.
+func AddArchives(base io.Reader, extra map[string]ArchiveReader) Archive {
+	pr, pw := io.Pipe()
+	go func() {
+		in, err := DecompressStream(base)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		defer in.Close()
+		tw := tar.NewWriter(pw)
+		if err := copyTarEntries(tw, tar.NewReader(in), ""); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		dirs := []string{}
+		for dir := range extra {
+			dirs = append(dirs, dir)
+		}
+		sort.Strings(dirs)
+		for _, dir := range dirs {
+			if err := copyTarEntries(tw, tar.NewReader(extra[dir]), dir); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(tw.Close())
+	}()
+	return pr
+}
+
+// ReplaceFile returns the archive base with content as the content of the
+// file name
+func ReplaceFile(base io.Reader, name string, content []byte) Archive {
+	pr, pw := io.Pipe()
+	go func() {
+		in, err := DecompressStream(base)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		defer in.Close()
+		tw := tar.NewWriter(pw)
+		tr := tar.NewReader(in)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			var src io.Reader = tr
+			if hdr.Typeflag == tar.TypeReg && path.Clean(hdr.Name) == path.Clean(name) {
+				hdr.Size = int64(len(content))
+				src = bytes.NewReader(content)
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := io.Copy(tw, src); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(tw.Close())
+	}()
+	return pr
+}
+
+// copyTarEntries copies the entries of tr to tw, under the directory prefix
+// when it is not empty
+func copyTarEntries(tw *tar.Writer, tr *tar.Reader, prefix string) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if prefix != "" {
+			name := path.Join(prefix, hdr.Name)
+			if hdr.Typeflag == tar.TypeDir {
+				name += "/"
+			}
+			hdr.Name = name
+			if hdr.Typeflag == tar.TypeLink {
+				hdr.Linkname = path.Join(prefix, hdr.Linkname)
+			}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
 // CmdStream executes a command, and returns its stdout as a stream.
 // If the command fails to run or doesn't complete successfully, an error
 // will be returned, including anything written on stderr.
@@ -14,6 +14,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/docker/docker/pkg/ioutils"
+	"github.com/docker/docker/pkg/system"
 	"github.com/docker/docker/vendor/src/code.google.com/p/go/src/pkg/archive/tar"
 )
 
@@ -66,6 +68,78 @@ func TestCmdStreamGood(t *testing.T) {
 	}
 }
 
+func TestDecompressStreamCmd(t *testing.T) {
+	for compression, name := range map[Compression]string{Xz: "xz", Zstd: "zstd"} {
+		if _, err := exec.LookPath(name); err != nil {
+			t.Logf("%s is not installed, skipping", name)
+			continue
+		}
+		cmd := exec.Command(name, "-c", "-q")
+		cmd.Stdin = strings.NewReader("hello world")
+		compressed, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c := DetectCompression(compressed); c != compression {
+			t.Fatalf("Expected %s to be detected, got %s", compression.Extension(), c.Extension())
+		}
+		r, err := DecompressStream(bytes.NewReader(compressed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != "hello world" {
+			t.Fatalf("Expected the %s stream to be decompressed, got %q", name, out)
+		}
+	}
+}
+
+func TestCompressStreamZstd(t *testing.T) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd is not installed")
+	}
+	buf := new(bytes.Buffer)
+	w, err := CompressStream(ioutils.NopWriteCloser(buf), Zstd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("hello world")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if c := DetectCompression(buf.Bytes()); c != Zstd {
+		t.Fatalf("Expected a zstd stream, got %s", c.Extension())
+	}
+	r, err := DecompressStream(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "hello world" {
+		t.Fatalf("Expected the zstd stream to be decompressed, got %q", out)
+	}
+}
+
+func TestDetectCompressionZstd(t *testing.T) {
+	header := []byte{0x28, 0xB5, 0x2F, 0xFD, 0x24, 0x0b, 0x59, 0x00, 0x00, 0x68}
+	if c := DetectCompression(header); c != Zstd {
+		t.Fatalf("Expected zstd to be detected, got %s", c.Extension())
+	}
+	if !IsArchive(header) {
+		t.Fatal("Expected a zstd stream to be an archive")
+	}
+}
+
 func TestTarFiles(t *testing.T) {
 	// try without hardlinks
 	if err := checkNoChanges(1000, false); err != nil {
@@ -211,6 +285,55 @@ func TestTarWithOptions(t *testing.T) {
 	}
 }
 
+func TestTarUntarXattrsAndOwner(t *testing.T) {
+	origin, err := ioutil.TempDir("", "docker-test-untar-origin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(origin)
+	file := path.Join(origin, "1")
+	if err := ioutil.WriteFile(file, []byte("hello world"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := system.Lsetxattr(file, "user.origin", []byte("test"), 0); err != nil {
+		t.Skipf("The filesystem doesn't support user xattrs: %v", err)
+	}
+
+	for _, includeXattrs := range []bool{false, true} {
+		archive, err := TarWithOptions(origin, &TarOptions{IncludeXattrs: includeXattrs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		dest, err := ioutil.TempDir("", "docker-test-untar-dest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dest)
+		if err := Untar(archive, dest, &TarOptions{ChownOpts: &TarChownOptions{UID: 1, GID: 2}}); err != nil {
+			t.Fatal(err)
+		}
+		archive.Close()
+
+		value, err := system.Lgetxattr(path.Join(dest, "1"), "user.origin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if includeXattrs && string(value) != "test" || !includeXattrs && value != nil {
+			t.Fatalf("Unexpected xattr %q with IncludeXattrs %v", value, includeXattrs)
+		}
+		if os.Getuid() != 0 {
+			continue
+		}
+		fi, err := os.Lstat(path.Join(dest, "1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st := fi.Sys().(*syscall.Stat_t); st.Uid != 1 || st.Gid != 2 {
+			t.Fatalf("Expected the file to be owned by 1:2, got %d:%d", st.Uid, st.Gid)
+		}
+	}
+}
+
 // Some tar archives such as http://haproxy.1wt.eu/download/1.5/src/devel/haproxy-1.5-dev21.tar.gz
 // use PAX Global Extended Headers.
 // Failing prevents the archives from being uncompressed during ADD
@@ -623,3 +746,140 @@ func TestTempArchiveCloseMultipleTimes(t *testing.T) {
 		}
 	}
 }
+
+func TestResetModTimes(t *testing.T) {
+	epoch := time.Unix(1400000000, 0)
+	archives := [][]byte{}
+	for i := 0; i < 2; i++ {
+		dir, err := ioutil.TempDir("", "docker-test-reset-mtimes")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		if err := os.Mkdir(filepath.Join(dir, "dir"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "dir", "file"), []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Now().Add(time.Duration(i) * time.Hour)
+		for _, p := range []string{filepath.Join(dir, "dir", "file"), filepath.Join(dir, "dir")} {
+			if err := os.Chtimes(p, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		tarball, err := TarWithOptions(dir, &TarOptions{Compression: Uncompressed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(ResetModTimes(tarball, epoch))
+		tarball.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		archives = append(archives, b)
+	}
+
+	if !bytes.Equal(archives[0], archives[1]) {
+		t.Fatal("Expected the archives of the same files to be identical")
+	}
+	tr := tar.NewReader(bytes.NewReader(archives[0]))
+	entries := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !hdr.ModTime.Equal(epoch) {
+			t.Fatalf("Expected %s to be modified at %s, got %s", hdr.Name, epoch, hdr.ModTime)
+		}
+		entries++
+	}
+	if entries != 2 {
+		t.Fatalf("Expected 2 entries, got %d", entries)
+	}
+}
+
+func TestAddArchives(t *testing.T) {
+	src, err := ioutil.TempDir("", "docker-test-add-archives")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+	if err := os.Mkdir(filepath.Join(src, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "dir", "file"), []byte("extra"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(src, "dir", "file"), filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := Generate("Dockerfile", "FROM scratch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	extra, err := TarWithOptions(src, &TarOptions{Compression: Uncompressed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest, err := ioutil.TempDir("", "docker-test-add-archives-dest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dest)
+	added := AddArchives(base, map[string]ArchiveReader{"extra/src": extra})
+	defer added.Close()
+	if err := Untar(added, dest, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{
+		"Dockerfile":         "FROM scratch",
+		"extra/src/dir/file": "extra",
+		"extra/src/link":     "extra",
+	} {
+		content, err := ioutil.ReadFile(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != expected {
+			t.Fatalf("Expected %s to be %q, got %q", name, expected, content)
+		}
+	}
+}
+
+func TestReplaceFile(t *testing.T) {
+	base, err := Generate("Dockerfile", "FROM busybox", "other", "unchanged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest, err := ioutil.TempDir("", "docker-test-replace-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dest)
+	replaced := ReplaceFile(base, "./Dockerfile", []byte("FROM busybox@sha256:0123"))
+	defer replaced.Close()
+	if err := Untar(replaced, dest, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{
+		"Dockerfile": "FROM busybox@sha256:0123",
+		"other":      "unchanged",
+	} {
+		content, err := ioutil.ReadFile(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != expected {
+			t.Fatalf("Expected %s to be %q, got %q", name, expected, content)
+		}
+	}
+}
@@ -392,7 +392,9 @@ func ExportChanges(dir string, changes []Change) (Archive, error) {
 				whiteOutDir := filepath.Dir(change.Path)
 				whiteOutBase := filepath.Base(change.Path)
 				whiteOut := filepath.Join(whiteOutDir, ".wh."+whiteOutBase)
-				timestamp := time.Now()
+				// a whiteout only records a deletion, it has a fixed
+				// timestamp so that the same changes give the same archive
+				timestamp := time.Unix(0, 0)
 				hdr := &tar.Header{
 					Name:       whiteOut[1:],
 					Size:       0,
@@ -0,0 +1,45 @@
+package authorization
+
+const (
+	// AuthZApiImplements is the subsystem authorization plugins implement
+	AuthZApiImplements = "authz"
+
+	// AuthZApiRequest is called before the daemon handles a request
+	AuthZApiRequest = "AuthZPlugin.AuthZReq"
+
+	// AuthZApiResponse is called after the daemon handled a request,
+	// before the response is returned to the client
+	AuthZApiResponse = "AuthZPlugin.AuthZRes"
+)
+
+// Request is sent to the authorization plugins. The response fields are
+// only set when calling AuthZApiResponse.
+type Request struct {
+	// User is the user who issued the request, as identified by the
+	// client certificate when TLS verification is enabled
+	User string `json:"User,omitempty"`
+
+	// UserAuthNMethod is the method used to authenticate the user
+	UserAuthNMethod string `json:"UserAuthNMethod,omitempty"`
+
+	RequestMethod  string            `json:"RequestMethod,omitempty"`
+	RequestURI     string            `json:"RequestUri,omitempty"`
+	RequestBody    []byte            `json:"RequestBody,omitempty"`
+	RequestHeaders map[string]string `json:"RequestHeaders,omitempty"`
+
+	ResponseStatusCode int               `json:"ResponseStatusCode,omitempty"`
+	ResponseBody       []byte            `json:"ResponseBody,omitempty"`
+	ResponseHeaders    map[string]string `json:"ResponseHeaders,omitempty"`
+}
+
+// Response is the answer of an authorization plugin
+type Response struct {
+	// Allow is whether the request is allowed
+	Allow bool `json:"Allow"`
+
+	// Msg explains to the user why the request was denied
+	Msg string `json:"Msg,omitempty"`
+
+	// Err is set when the plugin failed to process the request
+	Err string `json:"Err,omitempty"`
+}
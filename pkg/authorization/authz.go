@@ -0,0 +1,140 @@
+// Package authorization runs API requests through a chain of authorization
+// plugins. Every plugin is consulted before the request is handled and
+// again before the response is returned, the request is denied as soon
+// as one of them refuses it.
+package authorization
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxBodySize is the largest request or response body sent to the
+// plugins, larger bodies are not sent
+const maxBodySize = 1048576 // 1MB
+
+// Ctx holds the authorization context of a single request
+type Ctx struct {
+	plugins         []Plugin
+	user            string
+	userAuthNMethod string
+	requestMethod   string
+	requestURI      string
+	authReq         *Request
+}
+
+// NewCtx creates the authorization context of a request
+func NewCtx(authZPlugins []Plugin, user, userAuthNMethod, requestMethod, requestURI string) *Ctx {
+	return &Ctx{
+		plugins:         authZPlugins,
+		user:            user,
+		userAuthNMethod: userAuthNMethod,
+		requestMethod:   requestMethod,
+		requestURI:      requestURI,
+	}
+}
+
+// AuthZRequest asks every plugin whether the request is allowed. The
+// request body is restored so that it can still be read by the handler.
+func (ctx *Ctx) AuthZRequest(r *http.Request) error {
+	var body []byte
+	if sendBody(r.Header) && r.Body != nil {
+		var err error
+		body, r.Body, err = peekBody(r.Body)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx.authReq = &Request{
+		User:            ctx.user,
+		UserAuthNMethod: ctx.userAuthNMethod,
+		RequestMethod:   ctx.requestMethod,
+		RequestURI:      ctx.requestURI,
+		RequestBody:     body,
+		RequestHeaders:  headers(r.Header),
+	}
+
+	for _, plugin := range ctx.plugins {
+		authRes, err := plugin.AuthZRequest(ctx.authReq)
+		if err != nil {
+			return fmt.Errorf("plugin %s failed with error: %s", plugin.Name(), err)
+		}
+		if err := checkResponse(plugin, authRes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AuthZResponse asks every plugin whether the response is allowed to be
+// returned to the client
+func (ctx *Ctx) AuthZResponse(rm *ResponseModifier) error {
+	if ctx.authReq == nil {
+		return fmt.Errorf("authorization of the request is missing")
+	}
+	ctx.authReq.ResponseStatusCode = rm.StatusCode()
+	ctx.authReq.ResponseHeaders = headers(rm.Header())
+	if sendBody(rm.Header()) {
+		ctx.authReq.ResponseBody = rm.RawBody()
+	}
+
+	for _, plugin := range ctx.plugins {
+		authRes, err := plugin.AuthZResponse(ctx.authReq)
+		if err != nil {
+			return fmt.Errorf("plugin %s failed with error: %s", plugin.Name(), err)
+		}
+		if err := checkResponse(plugin, authRes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkResponse(plugin Plugin, authRes *Response) error {
+	if authRes.Err != "" {
+		return fmt.Errorf("plugin %s failed with error: %s", plugin.Name(), authRes.Err)
+	}
+	if !authRes.Allow {
+		return fmt.Errorf("authorization denied by plugin %s: %s", plugin.Name(), authRes.Msg)
+	}
+	return nil
+}
+
+// sendBody returns whether the body is JSON and can be sent to the plugins
+func sendBody(header http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+func headers(header http.Header) map[string]string {
+	h := make(map[string]string, len(header))
+	for k, v := range header {
+		h[k] = strings.Join(v, ",")
+	}
+	return h
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// peekBody reads the body if it is smaller than maxBodySize and returns a
+// reader yielding the whole body again
+func peekBody(body io.ReadCloser) ([]byte, io.ReadCloser, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(body, maxBodySize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	rc := &readCloser{Reader: io.MultiReader(bytes.NewReader(b), body), Closer: body}
+	if len(b) > maxBodySize {
+		return nil, rc, nil
+	}
+	return b, rc, nil
+}
@@ -0,0 +1,162 @@
+package authorization
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakePlugin struct {
+	name     string
+	requests []*Request
+	allowReq bool
+	allowRes bool
+}
+
+func (p *fakePlugin) Name() string {
+	return p.name
+}
+
+func (p *fakePlugin) AuthZRequest(authReq *Request) (*Response, error) {
+	p.requests = append(p.requests, authReq)
+	return &Response{Allow: p.allowReq, Msg: "request denied"}, nil
+}
+
+func (p *fakePlugin) AuthZResponse(authReq *Request) (*Response, error) {
+	return &Response{Allow: p.allowRes, Msg: "response denied"}, nil
+}
+
+func TestAuthZRequest(t *testing.T) {
+	plugin := &fakePlugin{name: "test", allowReq: true}
+	body := `{"Image": "busybox"}`
+	r, err := http.NewRequest("POST", "/containers/create", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", "application/json")
+
+	ctx := NewCtx([]Plugin{plugin}, "alice", "TLS", r.Method, "/containers/create")
+	if err := ctx.AuthZRequest(r); err != nil {
+		t.Fatal(err)
+	}
+	if len(plugin.requests) != 1 {
+		t.Fatalf("Expected the plugin to be called once, got %d", len(plugin.requests))
+	}
+	authReq := plugin.requests[0]
+	if authReq.User != "alice" || authReq.UserAuthNMethod != "TLS" || authReq.RequestMethod != "POST" || authReq.RequestURI != "/containers/create" {
+		t.Fatalf("Unexpected request %+v", authReq)
+	}
+	if string(authReq.RequestBody) != body {
+		t.Fatalf("Expected body %s, got %s", body, authReq.RequestBody)
+	}
+	if authReq.RequestHeaders["Content-Type"] != "application/json" {
+		t.Fatalf("Unexpected headers %v", authReq.RequestHeaders)
+	}
+
+	// the handler still reads the whole body
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != body {
+		t.Fatalf("Expected body %s to be restored, got %s", body, b)
+	}
+
+	plugin.allowReq = false
+	if err := ctx.AuthZRequest(r); err == nil || !strings.Contains(err.Error(), "authorization denied by plugin test: request denied") {
+		t.Fatalf("Expected the request to be denied, got %v", err)
+	}
+}
+
+func TestAuthZRequestSkipsNonJSONBody(t *testing.T) {
+	plugin := &fakePlugin{name: "test", allowReq: true}
+	r, err := http.NewRequest("POST", "/build", strings.NewReader("a tarball"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", "application/tar")
+
+	if err := NewCtx([]Plugin{plugin}, "", "", r.Method, "/build").AuthZRequest(r); err != nil {
+		t.Fatal(err)
+	}
+	if plugin.requests[0].RequestBody != nil {
+		t.Fatalf("Expected no body, got %s", plugin.requests[0].RequestBody)
+	}
+}
+
+func TestPeekBodyTooLarge(t *testing.T) {
+	large := bytes.Repeat([]byte("a"), maxBodySize+10)
+	b, rc, err := peekBody(ioutil.NopCloser(bytes.NewReader(large)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b != nil {
+		t.Fatalf("Expected no body for a large request, got %d bytes", len(b))
+	}
+	restored, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(restored, large) {
+		t.Fatalf("Expected the body to be restored, got %d bytes", len(restored))
+	}
+}
+
+func TestAuthZResponse(t *testing.T) {
+	plugin := &fakePlugin{name: "test", allowReq: true, allowRes: false}
+	r, err := http.NewRequest("GET", "/containers/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewCtx([]Plugin{plugin}, "", "", r.Method, "/containers/json")
+	if err := ctx.AuthZRequest(r); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	rm := NewResponseModifier(w)
+	rm.Header().Set("Content-Type", "application/json")
+	rm.WriteHeader(http.StatusCreated)
+	rm.Write([]byte("[]"))
+	if w.Body.Len() != 0 {
+		t.Fatalf("Expected the response to be buffered, got %q", w.Body.String())
+	}
+
+	if err := ctx.AuthZResponse(rm); err == nil || !strings.Contains(err.Error(), "response denied") {
+		t.Fatalf("Expected the response to be denied, got %v", err)
+	}
+	if string(ctx.authReq.ResponseBody) != "[]" || ctx.authReq.ResponseStatusCode != http.StatusCreated {
+		t.Fatalf("Unexpected response sent to the plugin %+v", ctx.authReq)
+	}
+
+	plugin.allowRes = true
+	if err := ctx.AuthZResponse(rm); err != nil {
+		t.Fatal(err)
+	}
+	if err := rm.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusCreated || w.Body.String() != "[]" || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Unexpected response %d %q %v", w.Code, w.Body.String(), w.HeaderMap)
+	}
+}
+
+func TestResponseModifierFlush(t *testing.T) {
+	w := httptest.NewRecorder()
+	rm := NewResponseModifier(w)
+	rm.Write([]byte("first"))
+	rm.Flush()
+	if !rm.Passthrough() {
+		t.Fatal("Expected the response to be passed through after a flush")
+	}
+	rm.Write([]byte(" second"))
+	if w.Body.String() != "first second" || !w.Flushed {
+		t.Fatalf("Unexpected response %q", w.Body.String())
+	}
+	if string(rm.RawBody()) != "first second" {
+		t.Fatalf("Unexpected captured body %q", rm.RawBody())
+	}
+}
@@ -0,0 +1,70 @@
+package authorization
+
+import (
+	"sync"
+
+	"github.com/docker/docker/pkg/plugins"
+)
+
+// Plugin authorizes requests and responses
+type Plugin interface {
+	Name() string
+	AuthZRequest(*Request) (*Response, error)
+	AuthZResponse(*Request) (*Response, error)
+}
+
+// NewPlugins returns the authorization plugins registered under the given
+// names. Plugins are activated on first use so that the daemon can start
+// before them.
+func NewPlugins(names []string) []Plugin {
+	plugins := make([]Plugin, 0, len(names))
+	for _, name := range names {
+		plugins = append(plugins, &authorizationPlugin{name: name})
+	}
+	return plugins
+}
+
+type authorizationPlugin struct {
+	name   string
+	plugin *plugins.Plugin
+	mu     sync.Mutex
+}
+
+func (a *authorizationPlugin) Name() string {
+	return a.name
+}
+
+func (a *authorizationPlugin) AuthZRequest(authReq *Request) (*Response, error) {
+	return a.call(AuthZApiRequest, authReq)
+}
+
+func (a *authorizationPlugin) AuthZResponse(authReq *Request) (*Response, error) {
+	return a.call(AuthZApiResponse, authReq)
+}
+
+func (a *authorizationPlugin) call(serviceMethod string, authReq *Request) (*Response, error) {
+	if err := a.initPlugin(); err != nil {
+		return nil, err
+	}
+	authRes := &Response{}
+	if err := a.plugin.Client.Call(serviceMethod, authReq, authRes); err != nil {
+		return nil, err
+	}
+	return authRes, nil
+}
+
+// initPlugin activates the plugin, a failed activation is retried on the
+// next request
+func (a *authorizationPlugin) initPlugin() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.plugin != nil {
+		return nil
+	}
+	p, err := plugins.Get(a.name, AuthZApiImplements)
+	if err != nil {
+		return err
+	}
+	a.plugin = p
+	return nil
+}
@@ -0,0 +1,132 @@
+package authorization
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// ResponseModifier buffers the response of a handler so that it can be
+// authorized before it is returned to the client. Streamed (flushed),
+// hijacked and large responses cannot be held back, they are passed
+// through and only their beginning is sent to the plugins.
+type ResponseModifier struct {
+	rw          http.ResponseWriter
+	header      http.Header
+	statusCode  int
+	body        bytes.Buffer
+	passthrough bool
+}
+
+// NewResponseModifier wraps rw
+func NewResponseModifier(rw http.ResponseWriter) *ResponseModifier {
+	return &ResponseModifier{rw: rw, header: make(http.Header)}
+}
+
+// Header returns the response headers
+func (rm *ResponseModifier) Header() http.Header {
+	if rm.passthrough {
+		return rm.rw.Header()
+	}
+	return rm.header
+}
+
+// WriteHeader records the status code of the response
+func (rm *ResponseModifier) WriteHeader(code int) {
+	if rm.statusCode != 0 {
+		return
+	}
+	rm.statusCode = code
+	if rm.passthrough {
+		rm.rw.WriteHeader(code)
+	}
+}
+
+// Write buffers b, or writes it through once the response is passed
+// through
+func (rm *ResponseModifier) Write(b []byte) (int, error) {
+	if rm.statusCode == 0 {
+		rm.WriteHeader(http.StatusOK)
+	}
+	if !rm.passthrough && rm.body.Len()+len(b) > maxBodySize {
+		if err := rm.startPassthrough(); err != nil {
+			return 0, err
+		}
+	}
+	if !rm.passthrough {
+		return rm.body.Write(b)
+	}
+	if rm.body.Len() < maxBodySize {
+		n := maxBodySize - rm.body.Len()
+		if n > len(b) {
+			n = len(b)
+		}
+		rm.body.Write(b[:n])
+	}
+	return rm.rw.Write(b)
+}
+
+// Flush passes the response through and flushes it
+func (rm *ResponseModifier) Flush() {
+	if err := rm.startPassthrough(); err != nil {
+		return
+	}
+	if f, ok := rm.rw.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack passes the response through and hijacks the connection
+func (rm *ResponseModifier) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rm.rw.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("Internal response writer doesn't support the Hijacker interface")
+	}
+	rm.passthrough = true
+	return h.Hijack()
+}
+
+// StatusCode returns the status code of the response
+func (rm *ResponseModifier) StatusCode() int {
+	if rm.statusCode == 0 {
+		return http.StatusOK
+	}
+	return rm.statusCode
+}
+
+// RawBody returns the buffered body of the response
+func (rm *ResponseModifier) RawBody() []byte {
+	return rm.body.Bytes()
+}
+
+// Passthrough returns whether the response was already, at least partly,
+// returned to the client
+func (rm *ResponseModifier) Passthrough() bool {
+	return rm.passthrough
+}
+
+// FlushAll writes the buffered response to the client
+func (rm *ResponseModifier) FlushAll() error {
+	return rm.startPassthrough()
+}
+
+func (rm *ResponseModifier) startPassthrough() error {
+	if rm.passthrough {
+		return nil
+	}
+	rm.passthrough = true
+	for k, v := range rm.header {
+		rm.rw.Header()[k] = v
+	}
+	if rm.statusCode != 0 {
+		rm.rw.WriteHeader(rm.statusCode)
+	}
+	if rm.body.Len() > 0 {
+		if _, err := rm.rw.Write(rm.body.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// Package digest identifies content by the hash of its bytes, in the form
+// algorithm:hex such as sha256:6c3c624b58dbbcd3c0dd82b4c53f04194d1247c6eebdaab7c610cf7d66709b3b
+package digest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Canonical is the algorithm of the digests computed by this package
+const Canonical = "sha256"
+
+// Digest is the algorithm and the hex encoded hash of some content
+type Digest string
+
+var validHex = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+// ParseDigest parses and validates a sha256 digest
+func ParseDigest(s string) (Digest, error) {
+	d := Digest(s)
+	return d, d.Validate()
+}
+
+// NewDigestFromHex returns the digest of algorithm alg with the hex hash
+func NewDigestFromHex(alg, hex string) Digest {
+	return Digest(alg + ":" + hex)
+}
+
+// FromBytes returns the digest of p
+func FromBytes(p []byte) Digest {
+	h := sha256.Sum256(p)
+	return NewDigestFromHex(Canonical, hex.EncodeToString(h[:]))
+}
+
+// FromReader returns the digest of the content of rd, read up to EOF
+func FromReader(rd io.Reader) (Digest, error) {
+	d := NewDigester()
+	if _, err := io.Copy(d, rd); err != nil {
+		return "", err
+	}
+	return d.Digest(), nil
+}
+
+// Validate checks that the digest is a sha256 digest with a hash of the
+// right length
+func (d Digest) Validate() error {
+	alg, hex, err := d.split()
+	if err != nil {
+		return err
+	}
+	if alg != Canonical {
+		return fmt.Errorf("Unsupported digest algorithm %q in %q", alg, string(d))
+	}
+	if !validHex.MatchString(hex) {
+		return fmt.Errorf("Invalid digest %q, the hash is not 64 lowercase hex characters", string(d))
+	}
+	return nil
+}
+
+// Algorithm returns the algorithm of the digest, such as sha256
+func (d Digest) Algorithm() (string, error) {
+	alg, _, err := d.split()
+	return alg, err
+}
+
+// Hex returns the hex encoded hash of the digest
+func (d Digest) Hex() (string, error) {
+	_, hex, err := d.split()
+	return hex, err
+}
+
+func (d Digest) String() string {
+	return string(d)
+}
+
+// split returns the algorithm and the hash of the digest, which are
+// separated by a colon
+func (d Digest) split() (string, string, error) {
+	i := strings.Index(string(d), ":")
+	if i < 0 {
+		return "", "", fmt.Errorf("Invalid digest %q, the format is algorithm:hex", string(d))
+	}
+	return string(d[:i]), string(d[i+1:]), nil
+}
+
+// Digester computes the digest of the content written to it
+type Digester struct {
+	hash.Hash
+}
+
+// NewDigester returns a sha256 digester
+func NewDigester() *Digester {
+	return &Digester{Hash: sha256.New()}
+}
+
+// Digest returns the digest of the content written so far
+func (d *Digester) Digest() Digest {
+	return NewDigestFromHex(Canonical, hex.EncodeToString(d.Sum(nil)))
+}
+
+// Verifier checks that the content written to it has an expected digest
+type Verifier struct {
+	expected Digest
+	digester *Digester
+}
+
+// NewVerifier returns a verifier of the content of digest d
+func NewVerifier(d Digest) *Verifier {
+	return &Verifier{expected: d, digester: NewDigester()}
+}
+
+func (v *Verifier) Write(p []byte) (int, error) {
+	return v.digester.Write(p)
+}
+
+// Verified reports whether the content written so far has the expected
+// digest
+func (v *Verifier) Verified() bool {
+	return v.digester.Digest() == v.expected
+}
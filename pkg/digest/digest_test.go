@@ -0,0 +1,69 @@
+package digest
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFromBytes(t *testing.T) {
+	d := FromBytes([]byte("hello\n"))
+	if d != "sha256:5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03" {
+		t.Fatalf("Unexpected digest %s", d)
+	}
+	alg, err := d.Algorithm()
+	if err != nil || alg != "sha256" {
+		t.Fatalf("Unexpected algorithm %s: %v", alg, err)
+	}
+	hex, err := d.Hex()
+	if err != nil || hex != "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03" {
+		t.Fatalf("Unexpected hex %s: %v", hex, err)
+	}
+
+	r, err := FromReader(strings.NewReader("hello\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != d {
+		t.Fatalf("Expected the digest of the reader to be %s, got %s", d, r)
+	}
+}
+
+func TestParseDigest(t *testing.T) {
+	if _, err := ParseDigest("sha256:5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"",
+		"5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03",
+		"md5:5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03",
+		"sha256:5891b5b522d5df086d0ff0b110fbd9d2",
+		"sha256:5891B5B522D5DF086D0FF0B110FBD9D21BB4FC7163AF34D08286A2E846F6BE03",
+	} {
+		if _, err := ParseDigest(s); err == nil {
+			t.Fatalf("Expected an error for digest %q", s)
+		}
+	}
+
+	if _, err := Digest("5891b5b522d5df086d0ff0b110fbd9d2").Hex(); err == nil {
+		t.Fatal("Expected an error for the hex of a digest without algorithm")
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	content := []byte("some layer content")
+	v := NewVerifier(FromBytes(content))
+	if _, err := io.Copy(v, bytes.NewReader(content[:4])); err != nil {
+		t.Fatal(err)
+	}
+	if v.Verified() {
+		t.Fatal("Expected a partial content not to be verified")
+	}
+	if _, err := io.Copy(v, bytes.NewReader(content[4:])); err != nil {
+		t.Fatal(err)
+	}
+	if !v.Verified() {
+		t.Fatal("Expected the content to be verified")
+	}
+}
@@ -81,6 +81,45 @@ func TestForward(t *testing.T) {
 	}
 }
 
+func TestHairpin(t *testing.T) {
+	ip := net.ParseIP("192.168.1.1")
+	port := 1235
+	dstAddr := "172.17.0.2"
+	dstPort := 4322
+	proto := "tcp"
+	_, subnet, _ := net.ParseCIDR("172.17.0.0/16")
+
+	if err := natChain.Hairpin(Insert, ip, port, proto, subnet, dstAddr, dstPort); err != nil {
+		t.Fatal(err)
+	}
+
+	dnatRule := []string{natChain.Name,
+		"-t", string(natChain.Table),
+		"-i", filterChain.Bridge,
+		"-d", ip.String(),
+		"-p", proto,
+		"--dport", strconv.Itoa(port),
+		"-j", "DNAT",
+		"--to-destination", dstAddr + ":" + strconv.Itoa(dstPort),
+	}
+	if !Exists(dnatRule...) {
+		t.Fatalf("DNAT rule does not exist")
+	}
+
+	masqRule := []string{"POSTROUTING",
+		"-t", string(natChain.Table),
+		"-s", subnet.String(),
+		"-d", dstAddr,
+		"-o", filterChain.Bridge,
+		"-p", proto,
+		"--dport", strconv.Itoa(dstPort),
+		"-j", "MASQUERADE",
+	}
+	if !Exists(masqRule...) {
+		t.Fatalf("MASQUERADE rule does not exist")
+	}
+}
+
 func TestLink(t *testing.T) {
 	var err error
 
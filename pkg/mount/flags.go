@@ -1,9 +1,66 @@
 package mount
 
 import (
+	"fmt"
 	"strings"
 )
 
+// flags are the fstab type options setting or clearing mount() flags
+var flags = map[string]struct {
+	clear bool
+	flag  int
+}{
+	"defaults":      {false, 0},
+	"ro":            {false, RDONLY},
+	"rw":            {true, RDONLY},
+	"suid":          {true, NOSUID},
+	"nosuid":        {false, NOSUID},
+	"dev":           {true, NODEV},
+	"nodev":         {false, NODEV},
+	"exec":          {true, NOEXEC},
+	"noexec":        {false, NOEXEC},
+	"sync":          {false, SYNCHRONOUS},
+	"async":         {true, SYNCHRONOUS},
+	"dirsync":       {false, DIRSYNC},
+	"remount":       {false, REMOUNT},
+	"mand":          {false, MANDLOCK},
+	"nomand":        {true, MANDLOCK},
+	"atime":         {true, NOATIME},
+	"noatime":       {false, NOATIME},
+	"diratime":      {true, NODIRATIME},
+	"nodiratime":    {false, NODIRATIME},
+	"bind":          {false, BIND},
+	"rbind":         {false, RBIND},
+	"unbindable":    {false, UNBINDABLE},
+	"runbindable":   {false, RUNBINDABLE},
+	"private":       {false, PRIVATE},
+	"rprivate":      {false, RPRIVATE},
+	"shared":        {false, SHARED},
+	"rshared":       {false, RSHARED},
+	"slave":         {false, SLAVE},
+	"rslave":        {false, RSLAVE},
+	"relatime":      {false, RELATIME},
+	"norelatime":    {true, RELATIME},
+	"strictatime":   {false, STRICTATIME},
+	"nostrictatime": {true, STRICTATIME},
+}
+
+// tmpfsFlags are the flags of the tmpfs mounts of containers, and tmpfsData
+// the keys of their tmpfs data
+var (
+	tmpfsFlags = map[string]bool{
+		"ro": true, "rw": true, "suid": true, "nosuid": true, "dev": true, "nodev": true,
+		"exec": true, "noexec": true, "sync": true, "async": true, "dirsync": true,
+		"mand": true, "nomand": true, "atime": true, "noatime": true,
+		"diratime": true, "nodiratime": true, "relatime": true, "norelatime": true,
+		"strictatime": true, "nostrictatime": true,
+	}
+	tmpfsData = map[string]bool{
+		"size": true, "mode": true, "uid": true, "gid": true,
+		"nr_inodes": true, "nr_blocks": true, "mpol": true,
+	}
+)
+
 // Parse fstab type mount options into mount() flags
 // and device specific data
 func parseOptions(options string) (int, string) {
@@ -12,45 +69,6 @@ func parseOptions(options string) (int, string) {
 		data []string
 	)
 
-	flags := map[string]struct {
-		clear bool
-		flag  int
-	}{
-		"defaults":      {false, 0},
-		"ro":            {false, RDONLY},
-		"rw":            {true, RDONLY},
-		"suid":          {true, NOSUID},
-		"nosuid":        {false, NOSUID},
-		"dev":           {true, NODEV},
-		"nodev":         {false, NODEV},
-		"exec":          {true, NOEXEC},
-		"noexec":        {false, NOEXEC},
-		"sync":          {false, SYNCHRONOUS},
-		"async":         {true, SYNCHRONOUS},
-		"dirsync":       {false, DIRSYNC},
-		"remount":       {false, REMOUNT},
-		"mand":          {false, MANDLOCK},
-		"nomand":        {true, MANDLOCK},
-		"atime":         {true, NOATIME},
-		"noatime":       {false, NOATIME},
-		"diratime":      {true, NODIRATIME},
-		"nodiratime":    {false, NODIRATIME},
-		"bind":          {false, BIND},
-		"rbind":         {false, RBIND},
-		"unbindable":    {false, UNBINDABLE},
-		"runbindable":   {false, RUNBINDABLE},
-		"private":       {false, PRIVATE},
-		"rprivate":      {false, RPRIVATE},
-		"shared":        {false, SHARED},
-		"rshared":       {false, RSHARED},
-		"slave":         {false, SLAVE},
-		"rslave":        {false, RSLAVE},
-		"relatime":      {false, RELATIME},
-		"norelatime":    {true, RELATIME},
-		"strictatime":   {false, STRICTATIME},
-		"nostrictatime": {true, STRICTATIME},
-	}
-
 	for _, o := range strings.Split(options, ",") {
 		// If the option does not exist in the flags table or the flag
 		// is not supported on the platform,
@@ -67,3 +85,31 @@ func parseOptions(options string) (int, string) {
 	}
 	return flag, strings.Join(data, ",")
 }
+
+// ParseTmpfsOptions parses the fstab type options of a tmpfs mount into
+// mount() flags and tmpfs data. The options apply over nosuid, nodev and
+// noexec, and the options tmpfs does not support are an error.
+func ParseTmpfsOptions(options string) (int, string, error) {
+	var (
+		flag = NOSUID | NODEV | NOEXEC
+		data []string
+	)
+	for _, o := range strings.Split(options, ",") {
+		if o == "" {
+			continue
+		}
+		if tmpfsFlags[o] {
+			if f := flags[o]; f.clear {
+				flag &= ^f.flag
+			} else {
+				flag |= f.flag
+			}
+			continue
+		}
+		if !tmpfsData[strings.SplitN(o, "=", 2)[0]] || !strings.Contains(o, "=") {
+			return 0, "", fmt.Errorf("Invalid tmpfs option %q", o)
+		}
+		data = append(data, o)
+	}
+	return flag, strings.Join(data, ","), nil
+}
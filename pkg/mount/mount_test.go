@@ -22,6 +22,25 @@ func TestMountOptionsParsing(t *testing.T) {
 	}
 }
 
+func TestTmpfsOptionsParsing(t *testing.T) {
+	flag, data, err := ParseTmpfsOptions("size=64m,exec,mode=1777,ro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data != "size=64m,mode=1777" {
+		t.Fatalf("Expected size=64m,mode=1777 got %s", data)
+	}
+	if expectedFlag := NOSUID | NODEV | RDONLY; flag != expectedFlag {
+		t.Fatalf("Expected %d got %d", expectedFlag, flag)
+	}
+
+	for _, options := range []string{"bind", "size", "foo=bar", "shared"} {
+		if _, _, err := ParseTmpfsOptions(options); err == nil {
+			t.Fatalf("Expected an error for the tmpfs options %s", options)
+		}
+	}
+}
+
 func TestMounted(t *testing.T) {
 	tmp := path.Join(os.TempDir(), "mount-tests")
 	if err := os.MkdirAll(tmp, 0777); err != nil {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"regexp"
 )
 
@@ -65,3 +66,42 @@ func Update(path, IP, hostname string) error {
 	var re = regexp.MustCompile(fmt.Sprintf("(\\S*)(\\t%s)", regexp.QuoteMeta(hostname)))
 	return ioutil.WriteFile(path, re.ReplaceAll(old, []byte(IP+"$2")), 0644)
 }
+
+// Add appends the records to the hosts file at path
+func Add(path string, recs []Record) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	content := bytes.NewBuffer(nil)
+	for _, r := range recs {
+		if _, err := r.WriteTo(content); err != nil {
+			return err
+		}
+	}
+	_, err = f.Write(content.Bytes())
+	return err
+}
+
+// Delete removes the lines matching the records from the hosts file at path
+func Delete(path string, recs []Record) error {
+	old, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := make(map[string]bool, len(recs))
+	for _, r := range recs {
+		lines[fmt.Sprintf("%s\t%s", r.IP, r.Hosts)] = true
+	}
+
+	content := bytes.NewBuffer(nil)
+	for _, line := range bytes.SplitAfter(old, []byte("\n")) {
+		if lines[string(bytes.TrimSuffix(line, []byte("\n")))] {
+			continue
+		}
+		content.Write(line)
+	}
+	return ioutil.WriteFile(path, content.Bytes(), 0644)
+}
@@ -132,3 +132,49 @@ func TestUpdate(t *testing.T) {
 		t.Fatalf("Expected to find '%s' got '%s'", expected, content)
 	}
 }
+
+func TestAddDelete(t *testing.T) {
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	if err := Build(file.Name(), "10.11.12.13", "testhostname", "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	recs := []Record{
+		{Hosts: "db cache", IP: "10.11.12.14"},
+		{Hosts: "web", IP: "10.11.12.15"},
+	}
+	if err := Add(file.Name(), recs); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "ff02::2\tip6-allrouters\n10.11.12.14\tdb cache\n10.11.12.15\tweb\n"; !bytes.HasSuffix(content, []byte(expected)) {
+		t.Fatalf("Expected to find '%s' got '%s'", expected, content)
+	}
+
+	if err := Delete(file.Name(), recs[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err = ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(content, []byte("db cache")) {
+		t.Fatalf("Expected the record to be deleted, got '%s'", content)
+	}
+	if expected := "10.11.12.13\ttesthostname\n"; !bytes.Contains(content, []byte(expected)) {
+		t.Fatalf("Expected to find '%s' got '%s'", expected, content)
+	}
+	if expected := "10.11.12.15\tweb\n"; !bytes.Contains(content, []byte(expected)) {
+		t.Fatalf("Expected to find '%s' got '%s'", expected, content)
+	}
+}
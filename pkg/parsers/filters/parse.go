@@ -83,3 +83,42 @@ func (filters Args) Match(field, source string) bool {
 	}
 	return false
 }
+
+// ExactMatch returns whether source is one of the values of field, unlike
+// Match the values are not regular expressions
+func (filters Args) ExactMatch(field, source string) bool {
+	fieldValues := filters[field]
+
+	//do not filter if there is no filter set
+	if len(fieldValues) == 0 {
+		return true
+	}
+	for _, value := range fieldValues {
+		if value == source {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchKVList returns whether the map sources, such as the labels of an
+// object, has all the key or key=value values of field
+func (filters Args) MatchKVList(field string, sources map[string]string) bool {
+	fieldValues := filters[field]
+
+	//do not filter if there is no filter set
+	if len(fieldValues) == 0 {
+		return true
+	}
+	for _, name2match := range fieldValues {
+		kv := strings.SplitN(name2match, "=", 2)
+		value, exists := sources[kv[0]]
+		if !exists {
+			return false
+		}
+		if len(kv) == 2 && value != kv[1] {
+			return false
+		}
+	}
+	return true
+}
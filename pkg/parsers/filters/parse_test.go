@@ -76,3 +76,48 @@ func TestEmpty(t *testing.T) {
 		t.Errorf("these should both be empty sets")
 	}
 }
+
+func TestMatchKVList(t *testing.T) {
+	labels := map[string]string{
+		"com.example.team": "storage",
+		"com.example.tier": "",
+	}
+	matching := []Args{
+		{},
+		{"label": {"com.example.team"}},
+		{"label": {"com.example.team=storage"}},
+		{"label": {"com.example.team=storage", "com.example.tier"}},
+		{"label": {"com.example.tier="}},
+	}
+	for _, a := range matching {
+		if !a.MatchKVList("label", labels) {
+			t.Errorf("expected %v to match the labels", a)
+		}
+	}
+	notMatching := []Args{
+		{"label": {"com.example.owner"}},
+		{"label": {"com.example.team=web"}},
+		{"label": {"com.example.team=storage", "com.example.owner"}},
+	}
+	for _, a := range notMatching {
+		if a.MatchKVList("label", labels) {
+			t.Errorf("expected %v not to match the labels", a)
+		}
+		if a.MatchKVList("label", nil) {
+			t.Errorf("expected %v not to match no labels", a)
+		}
+	}
+}
+
+func TestExactMatch(t *testing.T) {
+	if !(Args{}).ExactMatch("health", "unhealthy") {
+		t.Error("expected no filter to match")
+	}
+	a := Args{"health": {"healthy", "starting"}}
+	if !a.ExactMatch("health", "healthy") || !a.ExactMatch("health", "starting") {
+		t.Errorf("expected %v to match its values", a)
+	}
+	if a.ExactMatch("health", "unhealthy") {
+		t.Errorf("expected %v not to match a value containing one of its values", a)
+	}
+}
@@ -66,6 +66,11 @@ func ParseTCPAddr(addr string, defaultAddr string) (string, error) {
 // The tag can be confusing because of a port in a repository name.
 //     Ex: localhost.localdomain:5000/samalba/hipache:latest
 func ParseRepositoryTag(repos string) (string, string) {
+	// a reference by digest, repository@algorithm:hex, has the digest as
+	// tag
+	if n := strings.Index(repos, "@"); n >= 0 {
+		return repos[:n], repos[n+1:]
+	}
 	n := strings.LastIndex(repos, ":")
 	if n < 0 {
 		return repos, ""
@@ -76,6 +81,15 @@ func ParseRepositoryTag(repos string) (string, string) {
 	return repos, ""
 }
 
+// JoinRepositoryTag is the reverse of ParseRepositoryTag, it joins the
+// repository and a digest with @ and a tag with :
+func JoinRepositoryTag(repo, tag string) string {
+	if strings.Contains(tag, ":") {
+		return repo + "@" + tag
+	}
+	return repo + ":" + tag
+}
+
 func PartParser(template, data string) (map[string]string, error) {
 	// ip:public:private
 	var (
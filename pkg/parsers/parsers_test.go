@@ -61,6 +61,18 @@ func TestParseRepositoryTag(t *testing.T) {
 	if repo, tag := ParseRepositoryTag("url:5000/repo:tag"); repo != "url:5000/repo" || tag != "tag" {
 		t.Errorf("Expected repo: '%s' and tag: '%s', got '%s' and '%s'", "url:5000/repo", "tag", repo, tag)
 	}
+	if repo, digest := ParseRepositoryTag("url:5000/repo@sha256:1a2b"); repo != "url:5000/repo" || digest != "sha256:1a2b" {
+		t.Errorf("Expected repo: '%s' and digest: '%s', got '%s' and '%s'", "url:5000/repo", "sha256:1a2b", repo, digest)
+	}
+}
+
+func TestJoinRepositoryTag(t *testing.T) {
+	if name := JoinRepositoryTag("url:5000/repo", "tag"); name != "url:5000/repo:tag" {
+		t.Errorf("Expected '%s', got '%s'", "url:5000/repo:tag", name)
+	}
+	if name := JoinRepositoryTag("url:5000/repo", "sha256:1a2b"); name != "url:5000/repo@sha256:1a2b" {
+		t.Errorf("Expected '%s', got '%s'", "url:5000/repo@sha256:1a2b", name)
+	}
 }
 
 func TestParsePortMapping(t *testing.T) {
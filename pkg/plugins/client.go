@@ -0,0 +1,133 @@
+package plugins
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	versionMimetype = "application/vnd.docker.plugins.v1+json"
+	defaultTimeOut  = 30 * time.Second
+)
+
+// Client is a JSON over HTTP client talking to a plugin.
+type Client struct {
+	http    *http.Client
+	scheme  string
+	addr    string
+	timeOut time.Duration
+}
+
+// NewClient creates a client for the plugin listening on addr, which is
+// either a unix://path or a tcp://host:port address.
+func NewClient(addr string) (*Client, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	tr := &http.Transport{}
+	c := &Client{http: &http.Client{Transport: tr}, scheme: "http", timeOut: defaultTimeOut}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		tr.Dial = func(_, _ string) (net.Conn, error) {
+			return net.Dial("unix", socket)
+		}
+		// the host is ignored when dialing a unix socket but it has to be set
+		c.addr = "plugin"
+	case "tcp":
+		c.addr = u.Host
+	default:
+		return nil, fmt.Errorf("Unsupported plugin address %s, must be unix:// or tcp://", addr)
+	}
+	return c, nil
+}
+
+// Call calls the specified method of the plugin with args as the JSON
+// body of the request and decodes the response into ret. Connection
+// errors are retried with an exponential backoff as the plugin may still
+// be starting.
+func (c *Client) Call(serviceMethod string, args interface{}, ret interface{}) error {
+	var buf bytes.Buffer
+	if args != nil {
+		if err := json.NewEncoder(&buf).Encode(args); err != nil {
+			return err
+		}
+	}
+	body, err := c.callWithRetry(serviceMethod, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if ret == nil {
+		return nil
+	}
+	return json.NewDecoder(body).Decode(ret)
+}
+
+func (c *Client) callWithRetry(serviceMethod string, data []byte) (io.ReadCloser, error) {
+	var (
+		start   = time.Now()
+		retries int
+	)
+	for {
+		req, err := http.NewRequest("POST", "/"+serviceMethod, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", versionMimetype)
+		req.URL.Scheme = c.scheme
+		req.URL.Host = c.addr
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			timeOff := backoff(retries)
+			if time.Since(start)+timeOff > c.timeOut {
+				return nil, err
+			}
+			retries++
+			log.Warnf("Unable to connect to plugin: %s, retrying in %v", c.addr, timeOff)
+			time.Sleep(timeOff)
+			continue
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			b, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", serviceMethod, err)
+			}
+			// plugins report errors as {"Err": "..."}, fall back to the raw body
+			var e struct {
+				Err string
+			}
+			if err := json.Unmarshal(b, &e); err == nil && e.Err != "" {
+				return nil, fmt.Errorf("%s: %s", serviceMethod, e.Err)
+			}
+			return nil, fmt.Errorf("%s: %s", serviceMethod, strings.TrimSpace(string(b)))
+		}
+		return resp.Body, nil
+	}
+}
+
+func backoff(retries int) time.Duration {
+	b, max := 1, int(defaultTimeOut/time.Second)
+	for b < max && retries > 0 {
+		b *= 2
+		retries--
+	}
+	if b > max {
+		b = max
+	}
+	return time.Duration(b) * time.Second
+}
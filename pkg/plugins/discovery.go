@@ -0,0 +1,55 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned when no plugin is registered under a name
+	ErrNotFound = errors.New("Plugin not found")
+
+	socketsPath = "/run/docker/plugins"
+	specsPaths  = []string{"/etc/docker/plugins", "/usr/lib/docker/plugins"}
+)
+
+// scan looks the plugin up by name. A plugin is either a unix socket named
+// <name>.sock in the sockets directory or a <name>.spec file containing the
+// address of the plugin in one of the specs directories.
+func scan(name string) (*Plugin, error) {
+	socket := filepath.Join(socketsPath, name+".sock")
+	if fi, err := os.Stat(socket); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		return newPlugin(name, "unix://"+socket), nil
+	}
+
+	for _, p := range specsPaths {
+		spec := filepath.Join(p, name+".spec")
+		if _, err := os.Stat(spec); err != nil {
+			continue
+		}
+		return readPluginSpec(name, spec)
+	}
+	return nil, ErrNotFound
+}
+
+func readPluginSpec(name, path string) (*Plugin, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	addr := strings.TrimSpace(string(content))
+
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "unix" && u.Scheme != "tcp" {
+		return nil, fmt.Errorf("Unsupported address %s in plugin spec %s", addr, path)
+	}
+	return newPlugin(name, addr), nil
+}
@@ -0,0 +1,10 @@
+package plugins
+
+// VersionMimetype is the Accept header of the plugin requests
+const VersionMimetype = versionMimetype
+
+// SetPaths makes the plugins discovered in dir only
+func SetPaths(dir string) {
+	specsPaths = []string{dir}
+	socketsPath = dir
+}
@@ -0,0 +1,74 @@
+package plugins_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/pkg/plugins"
+	"github.com/docker/docker/pkg/plugins/pluginstest"
+)
+
+func setupPluginServer(t *testing.T) (*httptest.Server, string) {
+	tmp, err := ioutil.TempDir("", "docker-plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/Plugin.Activate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != plugins.VersionMimetype {
+			t.Fatalf("Expected Accept %s, got %s", plugins.VersionMimetype, r.Header.Get("Accept"))
+		}
+		fmt.Fprintln(w, `{"Implements": ["echo"]}`)
+	})
+	mux.HandleFunc("/Echo.Echo", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(b)
+	})
+	mux.HandleFunc("/Echo.Fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintln(w, `{"Err": "something went wrong"}`)
+	})
+	server, addr := pluginstest.NewServer(t, mux)
+	if err := ioutil.WriteFile(filepath.Join(tmp, "echo.spec"), []byte(addr+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	plugins.SetPaths(tmp)
+	return server, tmp
+}
+
+func TestPluginGet(t *testing.T) {
+	server, tmp := setupPluginServer(t)
+	defer server.Close()
+	defer os.RemoveAll(tmp)
+
+	if _, err := plugins.Get("unknown", "echo"); err != plugins.ErrNotFound {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+	if _, err := plugins.Get("echo", "authz"); err != plugins.ErrNotImplements {
+		t.Fatalf("Expected ErrNotImplements, got %v", err)
+	}
+	p, err := plugins.Get("echo", "echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct {
+		Msg string
+	}
+	if err := p.Client.Call("Echo.Echo", map[string]string{"Msg": "hello"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Msg != "hello" {
+		t.Fatalf("Expected hello, got %q", out.Msg)
+	}
+
+	err = p.Client.Call("Echo.Fail", nil, nil)
+	if err == nil || err.Error() != "Echo.Fail: something went wrong" {
+		t.Fatalf("Expected plugin error, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+// Package plugins provides the discovery of, and a client for, out of
+// process plugins speaking JSON over HTTP.
+//
+// On activation the daemon calls the `Plugin.Activate` method of the
+// plugin, which answers with the list of subsystems it implements:
+//
+//	{"Implements": ["authz"]}
+package plugins
+
+import (
+	"errors"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ErrNotImplements is returned when a plugin does not implement the
+// requested subsystem
+var ErrNotImplements = errors.New("Plugin does not implement the requested driver")
+
+// Manifest lists the subsystems implemented by a plugin
+type Manifest struct {
+	Implements []string
+}
+
+// Plugin is an activated plugin
+type Plugin struct {
+	Name     string
+	Addr     string
+	Client   *Client   `json:"-"`
+	Manifest *Manifest `json:"-"`
+}
+
+var storage = struct {
+	sync.Mutex
+	plugins map[string]*Plugin
+}{plugins: make(map[string]*Plugin)}
+
+func newPlugin(name, addr string) *Plugin {
+	return &Plugin{Name: name, Addr: addr}
+}
+
+func (p *Plugin) activate() error {
+	c, err := NewClient(p.Addr)
+	if err != nil {
+		return err
+	}
+	p.Client = c
+
+	m := new(Manifest)
+	if err := p.Client.Call("Plugin.Activate", nil, m); err != nil {
+		return err
+	}
+	log.Debugf("%s's manifest: %v", p.Name, m)
+	p.Manifest = m
+	return nil
+}
+
+// Implements returns whether the plugin implements the given subsystem
+func (p *Plugin) Implements(kind string) bool {
+	if p.Manifest == nil {
+		return false
+	}
+	for _, k := range p.Manifest.Implements {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func load(name string) (*Plugin, error) {
+	storage.Lock()
+	defer storage.Unlock()
+	if pl, ok := storage.plugins[name]; ok {
+		return pl, nil
+	}
+	pl, err := scan(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := pl.activate(); err != nil {
+		return nil, err
+	}
+	storage.plugins[name] = pl
+	return pl, nil
+}
+
+// Get returns the activated plugin registered under name, activating it
+// on first use, provided it implements the given subsystem.
+func Get(name, implements string) (*Plugin, error) {
+	pl, err := load(name)
+	if err != nil {
+		return nil, err
+	}
+	if !pl.Implements(implements) {
+		return nil, ErrNotImplements
+	}
+	return pl, nil
+}
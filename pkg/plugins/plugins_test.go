@@ -0,0 +1,23 @@
+package plugins
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadPluginSpecInvalidAddress(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "docker-plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	spec := filepath.Join(tmp, "bad.spec")
+	if err := ioutil.WriteFile(spec, []byte("http://127.0.0.1:8080"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readPluginSpec("bad", spec); err == nil {
+		t.Fatal("Expected an error for an http:// address")
+	}
+}
@@ -0,0 +1,30 @@
+// Package pluginstest serves fake plugins to the tests of the plugin clients
+package pluginstest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/pkg/plugins"
+)
+
+// NewServer starts a plugin serving the methods of mux, it returns the
+// server and the tcp:// address the plugin clients connect to
+func NewServer(t *testing.T, mux *http.ServeMux) (*httptest.Server, string) {
+	server := httptest.NewServer(mux)
+	return server, strings.Replace(server.URL, "http://", "tcp://", 1)
+}
+
+// NewPlugin starts a plugin serving the methods of mux and returns it as
+// plugins.Get would, without the discovery and the activation
+func NewPlugin(t *testing.T, mux *http.ServeMux) (*httptest.Server, *plugins.Plugin) {
+	server, addr := NewServer(t, mux)
+	c, err := plugins.NewClient(addr)
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return server, &plugins.Plugin{Name: "fake", Addr: server.URL, Client: c}
+}
@@ -0,0 +1,9 @@
+// Package reaper implements a minimal init process which can be injected as
+// PID 1 of a container. It starts the real command as its only child,
+// forwards the signals it receives to it and reaps any zombie left behind by
+// orphaned processes.
+package reaper
+
+// InitPath is where the init binary is mounted inside the container. It is
+// also the argv[0] the init is registered under with reexec.
+const InitPath = "/dev/init"
@@ -0,0 +1,127 @@
+// +build linux
+
+package reaper
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+
+	"github.com/docker/docker/pkg/reexec"
+	"github.com/docker/docker/pkg/term"
+)
+
+func init() {
+	reexec.Register(InitPath, initMain)
+}
+
+func initMain() {
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "init: no command specified")
+		os.Exit(1)
+	}
+
+	code, err := Run(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "init: %s\n", err)
+	}
+	os.Exit(code)
+}
+
+// Run starts args in its own process group, forwards every signal received
+// to it and reaps all exited children until args itself exits. The returned
+// code is the exit status of args, or 128+n when it was killed by signal n.
+func Run(args []string) (int, error) {
+	// Subscribe before starting the child so that no SIGCHLD is missed
+	signals := make(chan os.Signal, 128)
+	signal.Notify(signals)
+	defer signal.Stop(signals)
+
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return 127, err
+	}
+
+	cmd := &exec.Cmd{
+		Path:        path,
+		Args:        args,
+		Stdin:       os.Stdin,
+		Stdout:      os.Stdout,
+		Stderr:      os.Stderr,
+		SysProcAttr: &syscall.SysProcAttr{Setpgid: true},
+	}
+	if err := cmd.Start(); err != nil {
+		return 126, err
+	}
+	pid := cmd.Process.Pid
+
+	// Hand the terminal over to the child so that it can read from it and
+	// receive the signals generated by the tty directly.
+	if term.IsTerminal(os.Stdin.Fd()) {
+		if err := setForeground(os.Stdin.Fd(), pid); err == nil {
+			syscall.Kill(-pid, syscall.SIGCONT)
+		}
+	}
+
+	for s := range signals {
+		sig, ok := s.(syscall.Signal)
+		if !ok {
+			continue
+		}
+		switch sig {
+		case syscall.SIGCHLD:
+			if status, exited := reap(pid); exited {
+				return exitCode(status), nil
+			}
+		case syscall.SIGTTIN, syscall.SIGTTOU:
+			// Job control signals are meant for the init itself
+		default:
+			syscall.Kill(pid, sig)
+		}
+	}
+	return 0, nil
+}
+
+// reap collects every exited child without blocking and reports whether pid
+// was among them, along with its wait status.
+func reap(pid int) (syscall.WaitStatus, bool) {
+	var (
+		childStatus syscall.WaitStatus
+		exited      bool
+	)
+	for {
+		var status syscall.WaitStatus
+		p, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || p <= 0 {
+			return childStatus, exited
+		}
+		if p == pid {
+			childStatus, exited = status, true
+		}
+	}
+}
+
+func exitCode(status syscall.WaitStatus) int {
+	if status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return status.ExitStatus()
+}
+
+func setForeground(fd uintptr, pgrp int) error {
+	pg := int32(pgrp)
+	if _, _, err := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCSPGRP, uintptr(unsafe.Pointer(&pg))); err != 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+// +build linux
+
+package reaper
+
+import (
+	"testing"
+)
+
+func TestRunExitCode(t *testing.T) {
+	code, err := Run([]string{"sh", "-c", "exit 3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 3 {
+		t.Fatalf("Expected exit code 3, got %d", code)
+	}
+}
+
+func TestRunKilledBySignal(t *testing.T) {
+	code, err := Run([]string{"sh", "-c", "kill -KILL $$"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 137 {
+		t.Fatalf("Expected exit code 137, got %d", code)
+	}
+}
+
+func TestRunCommandNotFound(t *testing.T) {
+	code, err := Run([]string{"this-command-does-not-exist"})
+	if err == nil {
+		t.Fatal("Expected an error for a missing command")
+	}
+	if code != 127 {
+		t.Fatalf("Expected exit code 127, got %d", code)
+	}
+}
@@ -0,0 +1,30 @@
+package signal
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestParseSignal(t *testing.T) {
+	for raw, expected := range map[string]syscall.Signal{
+		"9":       syscall.SIGKILL,
+		"KILL":    syscall.SIGKILL,
+		"SIGKILL": syscall.SIGKILL,
+		"sigterm": syscall.SIGTERM,
+		"USR1":    syscall.SIGUSR1,
+	} {
+		sig, err := ParseSignal(raw)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing %q: %s", raw, err)
+		}
+		if sig != expected {
+			t.Fatalf("Expected %q to be parsed as %d, got %d", raw, expected, sig)
+		}
+	}
+
+	for _, raw := range []string{"", "0", "32", "SIGFOO", "-1"} {
+		if _, err := ParseSignal(raw); err == nil {
+			t.Fatalf("Expected an error parsing %q", raw)
+		}
+	}
+}
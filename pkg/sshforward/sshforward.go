@@ -0,0 +1,284 @@
+// Package sshforward forwards the connections to the SSH agents of a client
+// over a single connection to the daemon, such as a hijacked API
+// connection, so that the daemon doesn't need to run on the host of the
+// client. The daemon opens a stream over the connection for each connection
+// to an agent, the client dials the agent and copies the stream to it.
+package sshforward
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// frameOpen opens a stream to the agent named by its payload
+	frameOpen byte = iota
+	// frameData carries the data of a stream
+	frameData
+	// frameClose closes a stream
+	frameClose
+)
+
+const (
+	// headerLen is the length of the header of the frames, the type of the
+	// frame, the stream and the length of the payload
+	headerLen = 9
+	// maxPayload is the largest payload of a frame
+	maxPayload = 32 * 1024
+)
+
+// ErrSessionClosed is returned when a stream is opened on a closed session
+var ErrSessionClosed = errors.New("The SSH forwarding session is closed")
+
+func readFrame(r io.Reader) (byte, uint32, []byte, error) {
+	var header [headerLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(header[5:])
+	if n > maxPayload {
+		return 0, 0, nil, fmt.Errorf("Invalid SSH forwarding frame of %d bytes, the maximum is %d", n, maxPayload)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, 0, nil, err
+	}
+	return header[0], binary.BigEndian.Uint32(header[1:5]), payload, nil
+}
+
+// framer writes the frames of the streams sharing a connection
+type framer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// write writes the frames of a payload, split in frames of maxPayload
+func (f *framer) write(typ byte, stream uint32, payload []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for {
+		n := len(payload)
+		if n > maxPayload {
+			n = maxPayload
+		}
+		buf := make([]byte, headerLen+n)
+		buf[0] = typ
+		binary.BigEndian.PutUint32(buf[1:5], stream)
+		binary.BigEndian.PutUint32(buf[5:9], uint32(n))
+		copy(buf[headerLen:], payload[:n])
+		if _, err := f.w.Write(buf); err != nil {
+			return err
+		}
+		payload = payload[n:]
+		if len(payload) == 0 {
+			return nil
+		}
+	}
+}
+
+// Session is the daemon side of a forwarding connection, it opens the
+// streams to the agents of the client
+type Session struct {
+	conn io.Reader
+	f    *framer
+
+	mu      sync.Mutex
+	next    uint32
+	streams map[uint32]*stream
+	done    chan struct{}
+}
+
+// NewSession starts a session over conn, it ends when conn is closed by
+// the client
+func NewSession(conn io.ReadWriter) *Session {
+	s := &Session{
+		conn:    conn,
+		f:       &framer{w: conn},
+		streams: make(map[uint32]*stream),
+		done:    make(chan struct{}),
+	}
+	go s.readLoop()
+	return s
+}
+
+// Done is closed when the session ends
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Open opens a stream to the agent of the client with the given id
+func (s *Session) Open(agent string) (io.ReadWriteCloser, error) {
+	s.mu.Lock()
+	select {
+	case <-s.done:
+		s.mu.Unlock()
+		return nil, ErrSessionClosed
+	default:
+	}
+	s.next++
+	st := &stream{id: s.next, session: s}
+	st.pr, st.pw = io.Pipe()
+	s.streams[st.id] = st
+	s.mu.Unlock()
+
+	if err := s.f.write(frameOpen, st.id, []byte(agent)); err != nil {
+		st.Close()
+		return nil, err
+	}
+	return st, nil
+}
+
+// readLoop delivers the data of the streams until the connection is
+// closed. A stream whose data isn't read holds up the others, the agents
+// answer the requests of their clients one at a time.
+func (s *Session) readLoop() {
+	var err error
+	for {
+		var (
+			typ     byte
+			id      uint32
+			payload []byte
+		)
+		if typ, id, payload, err = readFrame(s.conn); err != nil {
+			break
+		}
+		s.mu.Lock()
+		st := s.streams[id]
+		s.mu.Unlock()
+		if st == nil {
+			continue
+		}
+		switch typ {
+		case frameData:
+			st.pw.Write(payload)
+		case frameClose:
+			st.pw.Close()
+		}
+	}
+	if err != io.EOF {
+		log.Debugf("SSH forwarding session ended: %v", err)
+	}
+
+	s.mu.Lock()
+	close(s.done)
+	for _, st := range s.streams {
+		st.pw.CloseWithError(ErrSessionClosed)
+	}
+	s.mu.Unlock()
+}
+
+func (s *Session) remove(id uint32) {
+	s.mu.Lock()
+	delete(s.streams, id)
+	s.mu.Unlock()
+}
+
+// stream is a connection to an agent of the client
+type stream struct {
+	id      uint32
+	session *Session
+	pr      *io.PipeReader
+	pw      *io.PipeWriter
+	once    sync.Once
+}
+
+func (st *stream) Read(p []byte) (int, error) {
+	return st.pr.Read(p)
+}
+
+func (st *stream) Write(p []byte) (int, error) {
+	if err := st.session.f.write(frameData, st.id, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (st *stream) Close() error {
+	st.once.Do(func() {
+		st.session.remove(st.id)
+		st.pr.Close()
+		st.session.f.write(frameClose, st.id, nil)
+	})
+	return nil
+}
+
+// Serve is the client side of a forwarding connection, it connects the
+// streams opened by the daemon over conn to the agents returned by dial,
+// until conn is closed.
+func Serve(conn io.ReadWriter, dial func(agent string) (net.Conn, error)) error {
+	var (
+		f     = &framer{w: conn}
+		mu    sync.Mutex
+		conns = make(map[uint32]net.Conn)
+	)
+	defer func() {
+		mu.Lock()
+		for _, c := range conns {
+			c.Close()
+		}
+		mu.Unlock()
+	}()
+
+	for {
+		typ, id, payload, err := readFrame(conn)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		mu.Lock()
+		c := conns[id]
+		mu.Unlock()
+
+		switch typ {
+		case frameOpen:
+			agent := string(payload)
+			c, err := dial(agent)
+			if err != nil {
+				log.Errorf("Error connecting to the SSH agent %s: %v", agent, err)
+				f.write(frameClose, id, nil)
+				continue
+			}
+			mu.Lock()
+			conns[id] = c
+			mu.Unlock()
+			go func() {
+				buf := make([]byte, maxPayload)
+				for {
+					n, err := c.Read(buf)
+					if n > 0 {
+						if f.write(frameData, id, buf[:n]) != nil {
+							break
+						}
+					}
+					if err != nil {
+						break
+					}
+				}
+				f.write(frameClose, id, nil)
+				mu.Lock()
+				delete(conns, id)
+				mu.Unlock()
+				c.Close()
+			}()
+		case frameData:
+			if c != nil {
+				if _, err := c.Write(payload); err != nil {
+					c.Close()
+				}
+			}
+		case frameClose:
+			if c != nil {
+				c.Close()
+			}
+		default:
+			return fmt.Errorf("Invalid SSH forwarding frame type %d", typ)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package sshforward
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// echoAgent answers the requests of its clients with the same bytes
+func echoAgent() func(agent string) (net.Conn, error) {
+	return func(agent string) (net.Conn, error) {
+		if agent != "default" {
+			return nil, fmt.Errorf("no agent %s", agent)
+		}
+		client, server := net.Pipe()
+		go func() {
+			io.Copy(server, server)
+			server.Close()
+		}()
+		return client, nil
+	}
+}
+
+func TestForwardAgent(t *testing.T) {
+	daemonConn, clientConn := net.Pipe()
+	served := make(chan error)
+	go func() {
+		served <- Serve(clientConn, echoAgent())
+	}()
+	session := NewSession(daemonConn)
+
+	for i := 0; i < 2; i++ {
+		st, err := session.Open("default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		request := bytes.Repeat([]byte{byte(i)}, maxPayload+10)
+		go st.Write(request)
+		response := make([]byte, len(request))
+		if _, err := io.ReadFull(st, response); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(request, response) {
+			t.Fatalf("Expected the agent to get the request of stream %d", i)
+		}
+		st.Close()
+	}
+
+	// the streams to an unknown agent are closed by the client
+	st, err := session.Open("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("Expected the stream to an unknown agent to be closed, got %v", err)
+	}
+
+	clientConn.Close()
+	select {
+	case <-session.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the session to end with its connection")
+	}
+	if _, err := session.Open("default"); err != ErrSessionClosed {
+		t.Fatalf("Expected no stream to be opened on a closed session, got %v", err)
+	}
+	if err := <-served; err != nil && err != io.ErrClosedPipe {
+		t.Fatal(err)
+	}
+}
@@ -12,6 +12,8 @@ import (
 type SysInfo struct {
 	MemoryLimit            bool
 	SwapLimit              bool
+	PidsLimit              bool
+	CpuRealtime            bool
 	IPv4ForwardingDisabled bool
 	AppArmor               bool
 }
@@ -37,6 +39,20 @@ func New(quiet bool) *SysInfo {
 		}
 	}
 
+	_, err := cgroups.FindCgroupMountpoint("pids")
+	sysInfo.PidsLimit = err == nil
+	if !sysInfo.PidsLimit && !quiet {
+		log.Printf("WARNING: Your kernel does not support cgroup pids limit.")
+	}
+
+	if cgroupCpuMountpoint, err := cgroups.FindCgroupMountpoint("cpu"); err == nil {
+		_, err := ioutil.ReadFile(path.Join(cgroupCpuMountpoint, "cpu.rt_runtime_us"))
+		sysInfo.CpuRealtime = err == nil
+		if !sysInfo.CpuRealtime && !quiet {
+			log.Printf("WARNING: Your kernel does not support cgroup cpu real-time scheduling.")
+		}
+	}
+
 	// Check if AppArmor seems to be enabled on this system.
 	if _, err := os.Stat("/sys/kernel/security/apparmor"); os.IsNotExist(err) {
 		sysInfo.AppArmor = false
@@ -1,6 +1,7 @@
 package system
 
 import (
+	"strings"
 	"syscall"
 	"unsafe"
 )
@@ -34,6 +35,41 @@ func Lgetxattr(path string, attr string) ([]byte, error) {
 	return dest[:sz], nil
 }
 
+// Returns the names of the xattrs set on path, without following a symlink
+func Llistxattr(path string) ([]string, error) {
+	pathBytes, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dest := make([]byte, 128)
+	destBytes := unsafe.Pointer(&dest[0])
+	sz, _, errno := syscall.Syscall(syscall.SYS_LLISTXATTR, uintptr(unsafe.Pointer(pathBytes)), uintptr(destBytes), uintptr(len(dest)))
+	if errno == syscall.ERANGE {
+		sz, _, errno = syscall.Syscall(syscall.SYS_LLISTXATTR, uintptr(unsafe.Pointer(pathBytes)), 0, 0)
+		if errno == 0 && sz > 0 {
+			dest = make([]byte, sz)
+			destBytes := unsafe.Pointer(&dest[0])
+			sz, _, errno = syscall.Syscall(syscall.SYS_LLISTXATTR, uintptr(unsafe.Pointer(pathBytes)), uintptr(destBytes), uintptr(len(dest)))
+		}
+	}
+	if errno == syscall.ENOTSUP {
+		return nil, nil
+	}
+	if errno != 0 {
+		return nil, errno
+	}
+
+	// the names are NUL terminated
+	var names []string
+	for _, name := range strings.Split(string(dest[:sz]), "\x00") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
 var _zero uintptr
 
 func Lsetxattr(path string, attr string, data []byte, flags int) error {
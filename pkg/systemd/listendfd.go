@@ -2,14 +2,19 @@ package systemd
 
 import (
 	"errors"
+	"fmt"
 	"net"
+	"os"
 	"strconv"
+	"strings"
 
 	"github.com/coreos/go-systemd/activation"
 )
 
 // ListenFD returns the specified socket activated files as a slice of
-// net.Listeners or all of the activated files if "*" is given.
+// net.Listeners or all of the activated files if "*" is given. A socket
+// is specified by its file descriptor number, or by the FileDescriptorName
+// of its unit which systemd passes in LISTEN_FDNAMES.
 func ListenFD(addr string) ([]net.Listener, error) {
 	// socket activation
 	listeners, err := activation.Listeners(false)
@@ -21,19 +26,41 @@ func ListenFD(addr string) ([]net.Listener, error) {
 		return nil, errors.New("No sockets found")
 	}
 
+	var names []string
+	if env := os.Getenv("LISTEN_FDNAMES"); env != "" {
+		names = strings.Split(env, ":")
+	}
+	return selectListeners(listeners, names, addr)
+}
+
+// selectListeners returns the listeners of addr, the listeners are passed
+// from the file descriptor 3 and named after names
+func selectListeners(listeners []net.Listener, names []string, addr string) ([]net.Listener, error) {
 	// default to all fds just like unix:// and tcp://
-	if addr == "" {
-		addr = "*"
+	if addr == "" || addr == "*" {
+		return listeners, nil
 	}
 
-	fdNum, _ := strconv.Atoi(addr)
-	fdOffset := fdNum - 3
-	if (addr != "*") && (len(listeners) < int(fdOffset)+1) {
-		return nil, errors.New("Too few socket activated files passed in")
+	fdNum, err := strconv.Atoi(addr)
+	if err != nil {
+		var named []net.Listener
+		for i, name := range names {
+			if name == addr && i < len(listeners) {
+				named = append(named, listeners[i])
+			}
+		}
+		if len(named) == 0 {
+			return nil, fmt.Errorf("No socket activated file named %s", addr)
+		}
+		return named, nil
 	}
 
-	if addr == "*" {
-		return listeners, nil
+	fdOffset := fdNum - 3
+	if fdOffset < 0 {
+		return nil, fmt.Errorf("Invalid socket activated file %d, they start at 3", fdNum)
+	}
+	if len(listeners) < fdOffset+1 {
+		return nil, errors.New("Too few socket activated files passed in")
 	}
 
 	return []net.Listener{listeners[fdOffset]}, nil
@@ -0,0 +1,46 @@
+package systemd
+
+import (
+	"net"
+	"testing"
+)
+
+type testListener struct {
+	net.Listener
+	fd int
+}
+
+func TestSelectListeners(t *testing.T) {
+	listeners := []net.Listener{&testListener{fd: 3}, &testListener{fd: 4}, &testListener{fd: 5}}
+	names := []string{"docker", "docker-tcp", "docker"}
+
+	for _, c := range []struct {
+		addr     string
+		expected []int
+	}{
+		{"", []int{3, 4, 5}},
+		{"*", []int{3, 4, 5}},
+		{"4", []int{4}},
+		{"docker-tcp", []int{4}},
+		{"docker", []int{3, 5}},
+	} {
+		selected, err := selectListeners(listeners, names, c.addr)
+		if err != nil {
+			t.Fatalf("Unexpected error selecting %q: %v", c.addr, err)
+		}
+		if len(selected) != len(c.expected) {
+			t.Fatalf("Expected %d listeners for %q, got %d", len(c.expected), c.addr, len(selected))
+		}
+		for i, l := range selected {
+			if fd := l.(*testListener).fd; fd != c.expected[i] {
+				t.Fatalf("Expected the listener %d for %q, got %d", c.expected[i], c.addr, fd)
+			}
+		}
+	}
+
+	for _, addr := range []string{"2", "6", "unknown"} {
+		if _, err := selectListeners(listeners, names, addr); err == nil {
+			t.Fatalf("Expected an error selecting %q", addr)
+		}
+	}
+}
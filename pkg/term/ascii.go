@@ -0,0 +1,47 @@
+package term
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultDetachKeys is the default escape sequence to detach from a
+// container, ctrl-p followed by ctrl-q
+const DefaultDetachKeys = "ctrl-p,ctrl-q"
+
+// ToBytes converts a comma separated list of keys into the byte sequence the
+// terminal sends for them. A key is either a single character, such as "a",
+// or "ctrl-" followed by a letter or one of @, [, \, ], ^ and _.
+func ToBytes(keys string) ([]byte, error) {
+	var codes []byte
+	if keys == "" {
+		return codes, nil
+	}
+	for _, key := range strings.Split(keys, ",") {
+		switch {
+		case len(key) == 1:
+			codes = append(codes, key[0])
+		case strings.HasPrefix(strings.ToLower(key), "ctrl-") && len(key) == len("ctrl-")+1:
+			code, err := ctrlCode(key[len(key)-1])
+			if err != nil {
+				return nil, err
+			}
+			codes = append(codes, code)
+		default:
+			return nil, fmt.Errorf("Unknown character: '%s'", key)
+		}
+	}
+	return codes, nil
+}
+
+// ctrlCode returns the byte sent by the terminal for ctrl and the given key
+func ctrlCode(key byte) (byte, error) {
+	switch {
+	case key >= 'a' && key <= 'z':
+		return key - 'a' + 1, nil
+	case key >= '@' && key <= '_':
+		// the upper case letters and @[\]^_
+		return key - '@', nil
+	}
+	return 0, fmt.Errorf("Unknown character: 'ctrl-%c'", key)
+}
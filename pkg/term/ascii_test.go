@@ -0,0 +1,33 @@
+package term
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestToBytes(t *testing.T) {
+	tests := map[string][]byte{
+		"":              {},
+		"ctrl-p,ctrl-q": {16, 17},
+		"ctrl-a":        {1},
+		"CTRL-Z":        {26},
+		"ctrl-@,ctrl-_": {0, 31},
+		"ctrl-[,a":      {27, 'a'},
+		"q,ctrl-\\,z":   {'q', 28, 'z'},
+	}
+	for keys, expected := range tests {
+		codes, err := ToBytes(keys)
+		if err != nil {
+			t.Fatalf("%q: %s", keys, err)
+		}
+		if !bytes.Equal(codes, expected) {
+			t.Fatalf("%q: expected %v, got %v", keys, expected, codes)
+		}
+	}
+
+	for _, keys := range []string{"ctrl", "ctrl-", "ctrl-1", "ab", "ctrl-p,", "alt-p"} {
+		if _, err := ToBytes(keys); err == nil {
+			t.Fatalf("Expected an error for %q", keys)
+		}
+	}
+}
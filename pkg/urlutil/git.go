@@ -11,7 +11,11 @@ var (
 )
 
 // IsGitURL returns true if the provided str is a git repository URL.
+// A "#ref:subdir" fragment may follow the repository.
 func IsGitURL(str string) bool {
+	if i := strings.Index(str, "#"); i >= 0 {
+		str = str[:i]
+	}
 	if IsURL(str) && strings.HasSuffix(str, ".git") {
 		return true
 	}
@@ -9,6 +9,9 @@ var (
 		"git@bitbucket.org:atlassianlabs/atlassian-docker.git",
 		"https://github.com/docker/docker.git",
 		"http://github.com/docker/docker.git",
+		"http://github.com/docker/docker.git#branch",
+		"https://github.com/docker/docker.git#v1.4.1:contrib",
+		"git@github.com:docker/docker.git#:docs",
 	}
 	incompleteGitUrls = []string{
 		"github.com/docker/docker",
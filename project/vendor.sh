@@ -74,3 +74,9 @@ clone git github.com/docker/libcontainer 2d3b5af7486f1a4e80a5ed91859d309b4eebf80
 rm -rf src/github.com/docker/libcontainer/vendor
 eval "$(grep '^clone ' src/github.com/docker/libcontainer/update-vendor.sh | grep -v 'github.com/codegangsta/cli')"
 # we exclude "github.com/codegangsta/cli" here because it's only needed for "nsinit", which Docker doesn't include
+
+# apply the changes to libcontainer that aren't merged upstream yet, in order
+for patch in ../project/vendor-patches/libcontainer/*.patch; do
+	echo "github.com/docker/libcontainer: apply $(basename "$patch")"
+	patch -p1 -s -d src/github.com/docker/libcontainer < "$patch"
+done
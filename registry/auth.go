@@ -45,6 +45,9 @@ type RequestAuthorization struct {
 	resource         string
 	scope            string
 	actions          []string
+	// mountFrom is the repository the blobs are mounted from, the token
+	// also has to allow pulling from it
+	mountFrom string
 
 	tokenLock       sync.Mutex
 	tokenCache      string
@@ -61,6 +64,14 @@ func NewRequestAuthorization(authConfig *AuthConfig, registryEndpoint *Endpoint,
 	}
 }
 
+// ForMount returns an authorization with the actions of auth that also
+// allows pulling from the repository fromRepo, to mount its blobs.
+func (auth *RequestAuthorization) ForMount(fromRepo string) *RequestAuthorization {
+	mountAuth := NewRequestAuthorization(auth.authConfig, auth.registryEndpoint, auth.resource, auth.scope, auth.actions)
+	mountAuth.mountFrom = fromRepo
+	return mountAuth
+}
+
 func (auth *RequestAuthorization) getToken() (string, error) {
 	auth.tokenLock.Lock()
 	defer auth.tokenLock.Unlock()
@@ -89,6 +100,9 @@ func (auth *RequestAuthorization) getToken() (string, error) {
 				params[k] = v
 			}
 			params["scope"] = fmt.Sprintf("%s:%s:%s", auth.resource, auth.scope, strings.Join(auth.actions, ","))
+			if auth.mountFrom != "" {
+				params["scope"] += fmt.Sprintf(" %s:%s:pull", auth.resource, auth.mountFrom)
+			}
 			token, err := getToken(auth.authConfig.Username, auth.authConfig.Password, params, auth.registryEndpoint, client, factory)
 			if err != nil {
 				return "", err
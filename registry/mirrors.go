@@ -0,0 +1,107 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// mirrorProbeInterval is how often the health of the registry mirrors is
+// probed
+var mirrorProbeInterval = 30 * time.Second
+
+// mirrorHealth tracks which registry mirrors are reachable. A mirror is
+// unhealthy once a probe fails, pulls skip it and fall back to the next
+// mirror or to the registry itself until a probe succeeds again.
+type mirrorHealth struct {
+	sync.Mutex
+	probe     func(mirror string) error
+	unhealthy map[string]error
+}
+
+func newMirrorHealth(probe func(mirror string) error) *mirrorHealth {
+	return &mirrorHealth{
+		probe:     probe,
+		unhealthy: make(map[string]error),
+	}
+}
+
+// healthy returns the mirrors which are not known to be unhealthy, in the
+// order they were configured
+func (m *mirrorHealth) healthy(mirrors []string) []string {
+	m.Lock()
+	defer m.Unlock()
+	healthy := make([]string, 0, len(mirrors))
+	for _, mirror := range mirrors {
+		if _, unhealthy := m.unhealthy[mirror]; !unhealthy {
+			healthy = append(healthy, mirror)
+		}
+	}
+	return healthy
+}
+
+// check probes mirror and records whether it is healthy, it returns the
+// error of the probe.
+func (m *mirrorHealth) check(mirror string) error {
+	err := m.probe(mirror)
+	m.Lock()
+	defer m.Unlock()
+	_, wasUnhealthy := m.unhealthy[mirror]
+	if err != nil {
+		if !wasUnhealthy {
+			log.Warnf("Registry mirror %s is unhealthy, falling back to the other mirrors: %v", mirror, err)
+		}
+		m.unhealthy[mirror] = err
+		return err
+	}
+	if wasUnhealthy {
+		log.Infof("Registry mirror %s is healthy again", mirror)
+		delete(m.unhealthy, mirror)
+	}
+	return nil
+}
+
+// checkAll probes all the mirrors at once
+func (m *mirrorHealth) checkAll(mirrors []string) {
+	var wg sync.WaitGroup
+	for _, mirror := range mirrors {
+		wg.Add(1)
+		go func(mirror string) {
+			defer wg.Done()
+			m.check(mirror)
+		}(mirror)
+	}
+	wg.Wait()
+}
+
+// run probes the mirrors every interval, forever
+func (m *mirrorHealth) run(mirrors []string, interval time.Duration) {
+	for {
+		m.checkAll(mirrors)
+		time.Sleep(interval)
+	}
+}
+
+// pingMirror checks that the registry mirror answers the v1 ping
+func pingMirror(mirror string) error {
+	endpoint, err := newEndpoint(mirror, true)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("GET", endpoint.Path("_ping"), nil)
+	if err != nil {
+		return err
+	}
+	resp, _, err := doRequest(req, nil, ConnectTimeout, endpoint.IsSecure)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, req.URL)
+	}
+	return nil
+}
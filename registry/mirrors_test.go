@@ -0,0 +1,47 @@
+package registry
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMirrorHealth(t *testing.T) {
+	down := map[string]bool{}
+	m := newMirrorHealth(func(mirror string) error {
+		if down[mirror] {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	mirrors := []string{"https://mirror-1.com/v1/", "https://mirror-2.com/v1/", "https://mirror-3.com/v1/"}
+
+	m.checkAll(mirrors)
+	if healthy := m.healthy(mirrors); !reflect.DeepEqual(healthy, mirrors) {
+		t.Fatalf("Expected all the mirrors to be healthy, got %v", healthy)
+	}
+
+	down[mirrors[0]] = true
+	if err := m.check(mirrors[0]); err == nil {
+		t.Fatal("Expected the probe of a mirror which is down to fail")
+	}
+	expected := mirrors[1:]
+	if healthy := m.healthy(mirrors); !reflect.DeepEqual(healthy, expected) {
+		t.Fatalf("Expected the healthy mirrors to be %v, got %v", expected, healthy)
+	}
+
+	down[mirrors[0]] = false
+	m.checkAll(mirrors)
+	if healthy := m.healthy(mirrors); !reflect.DeepEqual(healthy, mirrors) {
+		t.Fatalf("Expected the mirror to be healthy again, got %v", healthy)
+	}
+}
+
+func TestPingMirror(t *testing.T) {
+	if err := pingMirror(makeURL("/v1/")); err != nil {
+		t.Fatal(err)
+	}
+	if err := pingMirror("http://127.0.0.1:1/v1/"); err == nil {
+		t.Fatal("Expected the ping of an unreachable mirror to fail")
+	}
+}
@@ -14,14 +14,16 @@ import (
 //  'pull': Download images from any registry (TODO)
 //  'push': Upload images to any registry (TODO)
 type Service struct {
-	Config *ServiceConfig
+	Config  *ServiceConfig
+	mirrors *mirrorHealth
 }
 
 // NewService returns a new instance of Service ready to be
 // installed no an engine.
 func NewService(options *Options) *Service {
 	return &Service{
-		Config: NewServiceConfig(options),
+		Config:  NewServiceConfig(options),
+		mirrors: newMirrorHealth(pingMirror),
 	}
 }
 
@@ -32,6 +34,11 @@ func (s *Service) Install(eng *engine.Engine) error {
 	eng.Register("resolve_repository", s.ResolveRepository)
 	eng.Register("resolve_index", s.ResolveIndex)
 	eng.Register("registry_config", s.GetRegistryConfig)
+	eng.Register("registry_mirror_healthy", s.MirrorHealthy)
+	eng.Register("registry_mirror_failed", s.MirrorFailed)
+	if mirrors := s.Config.IndexConfigs[IndexServerName()].Mirrors; len(mirrors) > 0 {
+		go s.mirrors.run(mirrors, mirrorProbeInterval)
+	}
 	return nil
 }
 
@@ -146,6 +153,11 @@ func (s *Service) ResolveRepository(job *engine.Job) engine.Status {
 	if err != nil {
 		return job.Error(err)
 	}
+	// the unhealthy mirrors are left out, the index info is shared with
+	// the configuration so it is copied
+	index := *repoInfo.Index
+	index.Mirrors = s.mirrors.healthy(index.Mirrors)
+	repoInfo.Index = &index
 
 	out := engine.Env{}
 	err = out.SetJson("repository", repoInfo)
@@ -223,3 +235,31 @@ func (s *Service) GetRegistryConfig(job *engine.Job) engine.Status {
 
 	return engine.StatusOK
 }
+
+// MirrorHealthy fails when the registry mirror is known to be unhealthy.
+//
+// Argument syntax: registry_mirror_healthy MIRROR
+func (s *Service) MirrorHealthy(job *engine.Job) engine.Status {
+	if n := len(job.Args); n != 1 {
+		return job.Errorf("Usage: %s MIRROR", job.Name)
+	}
+	if len(s.mirrors.healthy(job.Args)) == 0 {
+		return job.Errorf("Registry mirror %s is unhealthy", job.Args[0])
+	}
+	return engine.StatusOK
+}
+
+// MirrorFailed probes the registry mirror after a pull from it failed, it
+// fails when the mirror is unhealthy, which is then skipped by the pulls
+// until a probe succeeds again.
+//
+// Argument syntax: registry_mirror_failed MIRROR
+func (s *Service) MirrorFailed(job *engine.Job) engine.Status {
+	if n := len(job.Args); n != 1 {
+		return job.Errorf("Usage: %s MIRROR", job.Name)
+	}
+	if err := s.mirrors.check(job.Args[0]); err != nil {
+		return job.Errorf("Registry mirror %s is unhealthy: %v", job.Args[0], err)
+	}
+	return engine.StatusOK
+}
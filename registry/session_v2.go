@@ -6,6 +6,7 @@ import (
 	"io"
 	"io/ioutil"
 	"strconv"
+	"strings"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/registry/v2"
@@ -63,10 +64,12 @@ func (r *Session) GetV2Authorization(ep *Endpoint, imageName string, readOnly bo
 //  1.c) if anything else, err
 // 2) PUT the created/signed manifest
 //
-func (r *Session) GetV2ImageManifest(ep *Endpoint, imageName, tagName string, auth *RequestAuthorization) ([]byte, error) {
+// GetV2ImageManifest also returns the digest of the manifest reported by
+// the registry in the Docker-Content-Digest header, empty when there is none.
+func (r *Session) GetV2ImageManifest(ep *Endpoint, imageName, tagName string, auth *RequestAuthorization) ([]byte, string, error) {
 	routeURL, err := getV2Builder(ep).BuildManifestURL(imageName, tagName)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	method := "GET"
@@ -74,30 +77,30 @@ func (r *Session) GetV2ImageManifest(ep *Endpoint, imageName, tagName string, au
 
 	req, err := r.reqFactory.NewRequest(method, routeURL, nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	if err := auth.Authorize(req); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	res, _, err := r.doRequest(req)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer res.Body.Close()
 	if res.StatusCode != 200 {
 		if res.StatusCode == 401 {
-			return nil, errLoginRequired
+			return nil, "", errLoginRequired
 		} else if res.StatusCode == 404 {
-			return nil, ErrDoesNotExist
+			return nil, "", ErrDoesNotExist
 		}
-		return nil, utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d trying to fetch for %s:%s", res.StatusCode, imageName, tagName), res)
+		return nil, "", utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d trying to fetch for %s:%s", res.StatusCode, imageName, tagName), res)
 	}
 
 	buf, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return nil, fmt.Errorf("Error while reading the http response: %s", err)
+		return nil, "", fmt.Errorf("Error while reading the http response: %s", err)
 	}
-	return buf, nil
+	return buf, res.Header.Get("Docker-Content-Digest"), nil
 }
 
 // - Succeeded to head image blob (already exists)
@@ -169,38 +172,69 @@ func (r *Session) GetV2ImageBlob(ep *Endpoint, imageName, sumType, sum string, b
 	return err
 }
 
-func (r *Session) GetV2ImageBlobReader(ep *Endpoint, imageName, sumType, sum string, auth *RequestAuthorization) (io.ReadCloser, int64, error) {
+// GetV2ImageBlobReader returns a reader of the blob from offset bytes on,
+// with a range request when offset is not 0. It also returns the offset the
+// reader starts at, which is 0 when the registry doesn't support range
+// requests, and the length of the whole blob.
+func (r *Session) GetV2ImageBlobReader(ep *Endpoint, imageName, sumType, sum string, offset int64, auth *RequestAuthorization) (io.ReadCloser, int64, int64, error) {
 	routeURL, err := getV2Builder(ep).BuildBlobURL(imageName, sumType+":"+sum)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, 0, err
 	}
 
 	method := "GET"
 	log.Debugf("[registry] Calling %q %s", method, routeURL)
 	req, err := r.reqFactory.NewRequest(method, routeURL, nil)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, 0, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
 	}
 	if err := auth.Authorize(req); err != nil {
-		return nil, 0, err
+		return nil, 0, 0, err
 	}
 	res, _, err := r.doRequest(req)
 	if err != nil {
-		return nil, 0, err
-	}
-	if res.StatusCode != 200 {
-		if res.StatusCode == 401 {
-			return nil, 0, errLoginRequired
+		return nil, 0, 0, err
+	}
+	switch res.StatusCode {
+	case 200:
+		offset = 0
+	case 206:
+	case 416:
+		// the blob is no longer than offset, it is complete when it is
+		// exactly offset bytes long
+		res.Body.Close()
+		if total, err := parseContentRangeTotal(res.Header.Get("Content-Range")); err == nil && total == offset {
+			return ioutil.NopCloser(strings.NewReader("")), offset, offset, nil
 		}
-		return nil, 0, utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d trying to pull %s blob - %s:%s", res.StatusCode, imageName, sumType, sum), res)
+		return nil, 0, 0, utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d trying to resume the pull of %s blob - %s:%s at %d", res.StatusCode, imageName, sumType, sum, offset), res)
+	case 401:
+		res.Body.Close()
+		return nil, 0, 0, errLoginRequired
+	default:
+		res.Body.Close()
+		return nil, 0, 0, utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d trying to pull %s blob - %s:%s", res.StatusCode, imageName, sumType, sum), res)
 	}
 	lenStr := res.Header.Get("Content-Length")
 	l, err := strconv.ParseInt(lenStr, 10, 64)
 	if err != nil {
-		return nil, 0, err
+		res.Body.Close()
+		return nil, 0, 0, err
 	}
 
-	return res.Body, l, err
+	return res.Body, offset, offset + l, nil
+}
+
+// parseContentRangeTotal returns the total length of a Content-Range
+// header, such as "bytes */1234"
+func parseContentRangeTotal(contentRange string) (int64, error) {
+	i := strings.LastIndex(contentRange, "/")
+	if !strings.HasPrefix(contentRange, "bytes ") || i < 0 {
+		return 0, fmt.Errorf("Invalid Content-Range %q", contentRange)
+	}
+	return strconv.ParseInt(contentRange[i+1:], 10, 64)
 }
 
 // Push the image to the server for storage.
@@ -260,42 +294,85 @@ func (r *Session) PutV2ImageBlob(ep *Endpoint, imageName, sumType, sumStr string
 	return nil
 }
 
-// Finally Push the (signed) manifest of the blobs we've just pushed
-func (r *Session) PutV2ImageManifest(ep *Endpoint, imageName, tagName string, manifestRdr io.Reader, auth *RequestAuthorization) error {
+// MountV2ImageBlob mounts the blob of the repository fromRepo in the
+// repository imageName of the same registry, instead of uploading it again.
+// It returns false when the registry did not mount the blob, as it does not
+// support mounts or the blob is not readable in fromRepo.
+func (r *Session) MountV2ImageBlob(ep *Endpoint, imageName, sumType, sumStr, fromRepo string, auth *RequestAuthorization) (bool, error) {
+	routeURL, err := getV2Builder(ep).BuildBlobUploadURL(imageName)
+	if err != nil {
+		return false, err
+	}
+
+	method := "POST"
+	log.Debugf("[registry] Calling %q %s to mount %s:%s from %s", method, routeURL, sumType, sumStr, fromRepo)
+	req, err := r.reqFactory.NewRequest(method, routeURL, nil)
+	if err != nil {
+		return false, err
+	}
+	queryParams := req.URL.Query()
+	queryParams.Add("mount", sumType+":"+sumStr)
+	queryParams.Add("from", fromRepo)
+	req.URL.RawQuery = queryParams.Encode()
+	if err := auth.Authorize(req); err != nil {
+		return false, err
+	}
+	res, _, err := r.doRequest(req)
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+
+	switch res.StatusCode {
+	case 201:
+		return true, nil
+	case 202:
+		// the registry started a regular upload instead, it is
+		// abandoned and expires
+		return false, nil
+	case 401:
+		return false, errLoginRequired
+	}
+	return false, utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d trying to mount %s blob - %s:%s from %s", res.StatusCode, imageName, sumType, sumStr, fromRepo), res)
+}
+
+// Finally Push the (signed) manifest of the blobs we've just pushed, the
+// digest of the manifest reported by the registry is returned
+func (r *Session) PutV2ImageManifest(ep *Endpoint, imageName, tagName string, manifestRdr io.Reader, auth *RequestAuthorization) (string, error) {
 	routeURL, err := getV2Builder(ep).BuildManifestURL(imageName, tagName)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	method := "PUT"
 	log.Debugf("[registry] Calling %q %s", method, routeURL)
 	req, err := r.reqFactory.NewRequest(method, routeURL, manifestRdr)
 	if err != nil {
-		return err
+		return "", err
 	}
 	if err := auth.Authorize(req); err != nil {
-		return err
+		return "", err
 	}
 	res, _, err := r.doRequest(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer res.Body.Close()
 
 	// All 2xx and 3xx responses can be accepted for a put.
 	if res.StatusCode >= 400 {
 		if res.StatusCode == 401 {
-			return errLoginRequired
+			return "", errLoginRequired
 		}
 		errBody, err := ioutil.ReadAll(res.Body)
 		if err != nil {
-			return err
+			return "", err
 		}
 		log.Debugf("Unexpected response from server: %q %#v", errBody, res.Header)
-		return utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d trying to push %s:%s manifest", res.StatusCode, imageName, tagName), res)
+		return "", utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d trying to push %s:%s manifest", res.StatusCode, imageName, tagName), res)
 	}
 
-	return nil
+	return res.Header.Get("Docker-Content-Digest"), nil
 }
 
 type remoteTags struct {
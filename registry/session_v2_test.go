@@ -0,0 +1,101 @@
+package registry
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/utils"
+)
+
+func TestGetV2ImageBlobReaderResume(t *testing.T) {
+	blob := []byte("the content of a layer blob")
+	supportsRange := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/v2/foo/bar/blobs/") {
+			http.NotFound(w, r)
+			return
+		}
+		if !supportsRange {
+			r.Header.Del("Range")
+		}
+		http.ServeContent(w, r, "blob", time.Time{}, bytes.NewReader(blob))
+	}))
+	defer server.Close()
+
+	endpoint, err := newEndpoint(server.URL+"/v2/", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewSession(&AuthConfig{}, utils.NewHTTPRequestFactory(), endpoint, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := NewRequestAuthorization(&AuthConfig{}, endpoint, "repository", "foo/bar", []string{"pull"})
+
+	check := func(offset, expectedStart int64) {
+		body, start, length, err := r.GetV2ImageBlobReader(endpoint, "foo/bar", "tarsum.dev+sha256", "1234", offset, auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer body.Close()
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if start != expectedStart || length != int64(len(blob)) {
+			t.Fatalf("Expected the blob from %d of length %d, got %d of length %d", expectedStart, len(blob), start, length)
+		}
+		if !bytes.Equal(data, blob[start:]) {
+			t.Fatalf("Expected %q from offset %d, got %q", blob[start:], offset, data)
+		}
+	}
+
+	check(0, 0)
+	check(10, 10)
+	check(int64(len(blob)), int64(len(blob)))
+
+	supportsRange = false
+	check(10, 0)
+}
+
+func TestMountV2ImageBlob(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v2/foo/app/blobs/uploads/" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("mount") == "tarsum.dev+sha256:1234" && r.URL.Query().Get("from") == "foo/base" {
+			w.WriteHeader(201)
+			return
+		}
+		w.Header().Set("Location", "/v2/foo/app/blobs/uploads/uuid")
+		w.WriteHeader(202)
+	}))
+	defer server.Close()
+
+	endpoint, err := newEndpoint(server.URL+"/v2/", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewSession(&AuthConfig{}, utils.NewHTTPRequestFactory(), endpoint, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := NewRequestAuthorization(&AuthConfig{}, endpoint, "repository", "foo/app", []string{"pull", "push"})
+
+	if mounted, err := r.MountV2ImageBlob(endpoint, "foo/app", "tarsum.dev+sha256", "1234", "foo/base", auth.ForMount("foo/base")); err != nil {
+		t.Fatal(err)
+	} else if !mounted {
+		t.Fatal("Expected the blob to be mounted from foo/base")
+	}
+	if mounted, err := r.MountV2ImageBlob(endpoint, "foo/app", "tarsum.dev+sha256", "1234", "foo/other", auth.ForMount("foo/other")); err != nil {
+		t.Fatal(err)
+	} else if mounted {
+		t.Fatal("Expected the blob not to be mounted from foo/other")
+	}
+}
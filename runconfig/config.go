@@ -1,10 +1,27 @@
 package runconfig
 
 import (
+	"time"
+
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/nat"
 )
 
+// HealthConfig is the HEALTHCHECK of an image, the command which checks that
+// the containers of the image work
+type HealthConfig struct {
+	// Test is the command: {"NONE"} disables the healthcheck of the base
+	// image, {"CMD", args...} runs the command directly and
+	// {"CMD-SHELL", command} runs it with the shell
+	Test []string `json:",omitempty"`
+	// Interval and Timeout are zero for the default of the daemon
+	Interval time.Duration `json:",omitempty"`
+	Timeout  time.Duration `json:",omitempty"`
+	// Retries is the number of consecutive failures after which the
+	// container is unhealthy, zero for the default of the daemon
+	Retries int `json:",omitempty"`
+}
+
 // Note: the Config structure should hold only portable information about the container.
 // Here, "portable" means "independent from the host we are running on".
 // Non-portable information *should* appear in HostConfig.
@@ -33,6 +50,10 @@ type Config struct {
 	NetworkDisabled bool
 	MacAddress      string
 	OnBuild         []string
+	StopSignal      string // Signal sent to the container's main process on stop
+	StopTimeout     *int   `json:",omitempty"` // Seconds to wait after StopSignal before killing; nil uses the daemon default
+	Labels          map[string]string
+	Healthcheck     *HealthConfig `json:",omitempty"`
 }
 
 func ContainerConfigFromJob(job *engine.Job) *Config {
@@ -54,9 +75,16 @@ func ContainerConfigFromJob(job *engine.Job) *Config {
 		WorkingDir:      job.Getenv("WorkingDir"),
 		NetworkDisabled: job.GetenvBool("NetworkDisabled"),
 		MacAddress:      job.Getenv("MacAddress"),
+		StopSignal:      job.Getenv("StopSignal"),
+	}
+	if job.EnvExists("StopTimeout") {
+		stopTimeout := job.GetenvInt("StopTimeout")
+		config.StopTimeout = &stopTimeout
 	}
 	job.GetenvJson("ExposedPorts", &config.ExposedPorts)
 	job.GetenvJson("Volumes", &config.Volumes)
+	job.GetenvJson("Labels", &config.Labels)
+	job.GetenvJson("Healthcheck", &config.Healthcheck)
 	if PortSpecs := job.GetenvList("PortSpecs"); PortSpecs != nil {
 		config.PortSpecs = PortSpecs
 	}
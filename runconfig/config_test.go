@@ -262,3 +262,24 @@ func TestMerge(t *testing.T) {
 	}
 
 }
+
+func TestMergeLabels(t *testing.T) {
+	configImage := &Config{
+		Labels: map[string]string{"a": "image", "b": "image"},
+	}
+	configUser := &Config{
+		Labels: map[string]string{"b": "user", "c": "user"},
+	}
+	if err := Merge(configUser, configImage); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"a": "image", "b": "user", "c": "user"}
+	if len(configUser.Labels) != len(expected) {
+		t.Fatalf("Expected labels %v, found %v", expected, configUser.Labels)
+	}
+	for k, v := range expected {
+		if configUser.Labels[k] != v {
+			t.Fatalf("Expected labels %v, found %v", expected, configUser.Labels)
+		}
+	}
+}
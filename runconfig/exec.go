@@ -2,8 +2,10 @@ package runconfig
 
 import (
 	"fmt"
+	"path"
 
 	"github.com/docker/docker/engine"
+	"github.com/docker/docker/opts"
 	flag "github.com/docker/docker/pkg/mflag"
 	"github.com/docker/docker/utils"
 )
@@ -18,23 +20,30 @@ type ExecConfig struct {
 	AttachStdout bool
 	Detach       bool
 	Cmd          []string
+	Env          []string // Environment variables added to the ones of the container
+	WorkingDir   string   // Working directory of the command, the container's one when empty
+	DetachKeys   *string  `json:",omitempty"` // Escape sequence to detach from the command, empty to disable; nil uses the default
 }
 
 func ExecConfigFromJob(job *engine.Job) (*ExecConfig, error) {
 	execConfig := &ExecConfig{
 		// TODO(vishh): Expose 'User' once it is supported.
 		//User:         job.Getenv("User"),
-		// TODO(vishh): Expose 'Privileged' once it is supported.
-		//Privileged:   job.GetenvBool("Privileged"),
+		Privileged:   job.GetenvBool("Privileged"),
 		Tty:          job.GetenvBool("Tty"),
 		AttachStdin:  job.GetenvBool("AttachStdin"),
 		AttachStderr: job.GetenvBool("AttachStderr"),
 		AttachStdout: job.GetenvBool("AttachStdout"),
+		Env:          job.GetenvList("Env"),
+		WorkingDir:   job.Getenv("WorkingDir"),
 	}
 	cmd := job.GetenvList("Cmd")
 	if len(cmd) == 0 {
 		return nil, fmt.Errorf("No exec command specified")
 	}
+	if execConfig.WorkingDir != "" && !path.IsAbs(execConfig.WorkingDir) {
+		return nil, ErrInvalidWorkingDirectory
+	}
 
 	execConfig.Cmd = cmd
 
@@ -43,29 +52,37 @@ func ExecConfigFromJob(job *engine.Job) (*ExecConfig, error) {
 
 func ParseExec(cmd *flag.FlagSet, args []string) (*ExecConfig, error) {
 	var (
-		flStdin   = cmd.Bool([]string{"i", "-interactive"}, false, "Keep STDIN open even if not attached")
-		flTty     = cmd.Bool([]string{"t", "-tty"}, false, "Allocate a pseudo-TTY")
-		flDetach  = cmd.Bool([]string{"d", "-detach"}, false, "Detached mode: run command in the background")
-		execCmd   []string
-		container string
+		flStdin      = cmd.Bool([]string{"i", "-interactive"}, false, "Keep STDIN open even if not attached")
+		flTty        = cmd.Bool([]string{"t", "-tty"}, false, "Allocate a pseudo-TTY")
+		flDetach     = cmd.Bool([]string{"d", "-detach"}, false, "Detached mode: run command in the background")
+		flPrivileged = cmd.Bool([]string{"-privileged"}, false, "Give extended privileges to the command")
+		flWorkingDir = cmd.String([]string{"w", "-workdir"}, "", "Working directory inside the container")
+		flEnv        = opts.NewListOpts(opts.ValidateEnv)
+		execCmd      []string
+		container    string
 	)
+	cmd.Var(&flEnv, []string{"e", "-env"}, "Set environment variables")
 	cmd.Require(flag.Min, 2)
 	if err := utils.ParseFlags(cmd, args, true); err != nil {
 		return nil, err
 	}
+	if *flWorkingDir != "" && !path.IsAbs(*flWorkingDir) {
+		return nil, ErrInvalidWorkingDirectory
+	}
 	container = cmd.Arg(0)
 	parsedArgs := cmd.Args()
 	execCmd = parsedArgs[1:]
 
 	execConfig := &ExecConfig{
 		// TODO(vishh): Expose '-u' flag once it is supported.
-		User: "",
-		// TODO(vishh): Expose '-p' flag once it is supported.
-		Privileged: false,
+		User:       "",
+		Privileged: *flPrivileged,
 		Tty:        *flTty,
 		Cmd:        execCmd,
 		Container:  container,
 		Detach:     *flDetach,
+		Env:        flEnv.GetAll(),
+		WorkingDir: *flWorkingDir,
 	}
 
 	// If -d is not set, attach to everything by default
@@ -0,0 +1,38 @@
+package runconfig
+
+import (
+	"io/ioutil"
+	"testing"
+
+	flag "github.com/docker/docker/pkg/mflag"
+)
+
+func parseExec(args []string) (*ExecConfig, error) {
+	cmd := flag.NewFlagSet("exec", flag.ContinueOnError)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.Usage = nil
+	return ParseExec(cmd, args)
+}
+
+func TestParseExecOptions(t *testing.T) {
+	execConfig, err := parseExec([]string{"-e", "FOO=bar", "--env=BAZ=qux", "-w", "/tmp", "--privileged", "container", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(execConfig.Env) != 2 || execConfig.Env[0] != "FOO=bar" || execConfig.Env[1] != "BAZ=qux" {
+		t.Fatalf("Unexpected environment %v", execConfig.Env)
+	}
+	if execConfig.WorkingDir != "/tmp" {
+		t.Fatalf("Expected the working directory /tmp, got %q", execConfig.WorkingDir)
+	}
+	if !execConfig.Privileged {
+		t.Fatal("Expected the exec to be privileged")
+	}
+	if execConfig.Container != "container" || len(execConfig.Cmd) != 1 || execConfig.Cmd[0] != "cmd" {
+		t.Fatalf("Unexpected container %q and command %v", execConfig.Container, execConfig.Cmd)
+	}
+
+	if _, err := parseExec([]string{"-w", "tmp", "container", "cmd"}); err != ErrInvalidWorkingDirectory {
+		t.Fatalf("Expected ErrInvalidWorkingDirectory for a relative working directory, got %v", err)
+	}
+}
@@ -29,6 +29,17 @@ func (n NetworkMode) IsNone() bool {
 	return n == "none"
 }
 
+// IsBridge indicates whether container uses the default bridge network
+func (n NetworkMode) IsBridge() bool {
+	return n == "bridge" || n == "" // empty string to support existing containers
+}
+
+// IsUserDefined indicates whether container uses a user-defined network,
+// in which case the mode is the name of the network
+func (n NetworkMode) IsUserDefined() bool {
+	return !(n.IsBridge() || n.IsHost() || n.IsContainer() || n.IsNone())
+}
+
 type IpcMode string
 
 // IsPrivate indicates whether container use it's private ipc stack
@@ -100,27 +111,53 @@ type RestartPolicy struct {
 }
 
 type HostConfig struct {
-	Binds           []string
-	ContainerIDFile string
-	LxcConf         []utils.KeyValuePair
-	Privileged      bool
-	PortBindings    nat.PortMap
-	Links           []string
-	PublishAllPorts bool
-	Dns             []string
-	DnsSearch       []string
-	ExtraHosts      []string
-	VolumesFrom     []string
-	Devices         []DeviceMapping
-	NetworkMode     NetworkMode
-	IpcMode         IpcMode
-	PidMode         PidMode
-	CapAdd          []string
-	CapDrop         []string
-	RestartPolicy   RestartPolicy
-	SecurityOpt     []string
-	ReadonlyRootfs  bool
-	Ulimits         []*ulimit.Ulimit
+	Binds             []string
+	ContainerIDFile   string
+	LxcConf           []utils.KeyValuePair
+	Privileged        bool
+	PortBindings      nat.PortMap
+	Links             []string
+	PublishAllPorts   bool
+	Dns               []string
+	DnsSearch         []string
+	DnsOptions        []string
+	ExtraHosts        []string
+	VolumesFrom       []string
+	Devices           []DeviceMapping
+	NetworkMode       NetworkMode
+	IpcMode           IpcMode
+	PidMode           PidMode
+	CapAdd            []string
+	CapDrop           []string
+	RestartPolicy     RestartPolicy
+	AutoRemove        bool // Remove the container when it exits, done by the daemon
+	SecurityOpt       []string
+	ReadonlyRootfs    bool
+	Ulimits           []*ulimit.Ulimit
+	Init              *bool             `json:",omitempty"` // Run an init inside the container; nil uses the daemon default
+	PidsLimit         int64             // Maximum number of processes in the container; 0 or -1 for unlimited
+	CpuRtPeriod       int64             // CPU real-time period in microseconds
+	CpuRtRuntime      int64             // CPU real-time runtime in microseconds
+	DeviceCgroupRules []string          // Extra rules for the devices cgroup, e.g. "c 189:* rwm"
+	ShmSize           int64             // Size of /dev/shm in bytes; 0 uses the daemon default
+	OomScoreAdj       int               // Adjustment of the OOM killer score, from -1000 to 1000
+	CgroupParent      string            // Parent cgroup, or systemd slice, of the container; empty uses the daemon default
+	NetworkAliases    []string          // Extra names of the container on its user-defined network
+	IPAddress         string            // Address of the container on its user-defined network; empty to allocate one
+	IPv6Address       string            // IPv6 address of the container on its user-defined network; empty to allocate one
+	Networks          []EndpointConfig  // User-defined networks to connect to besides the network mode
+	StorageOpt        map[string]string `json:",omitempty"` // Storage driver options of the writable layer, e.g. size
+	Tmpfs             map[string]string `json:",omitempty"` // Paths of the tmpfs mounts to their options, e.g. size=64m
+	GroupAdd          []string          // Supplementary groups of the processes, by name or gid
+}
+
+// EndpointConfig is a user-defined network a container is connected to on
+// creation, besides the network of its network mode
+type EndpointConfig struct {
+	Network     string
+	IPAddress   string   `json:",omitempty"` // Allocated from the network when empty
+	IPv6Address string   `json:",omitempty"` // Allocated from the network when empty
+	Aliases     []string `json:",omitempty"`
 }
 
 // This is used by the create command when you want to set both the
@@ -152,16 +189,35 @@ func ContainerHostConfigFromJob(job *engine.Job) *HostConfig {
 		IpcMode:         IpcMode(job.Getenv("IpcMode")),
 		PidMode:         PidMode(job.Getenv("PidMode")),
 		ReadonlyRootfs:  job.GetenvBool("ReadonlyRootfs"),
+		AutoRemove:      job.GetenvBool("AutoRemove"),
+		PidsLimit:       job.GetenvInt64("PidsLimit"),
+		CpuRtPeriod:     job.GetenvInt64("CpuRtPeriod"),
+		CpuRtRuntime:    job.GetenvInt64("CpuRtRuntime"),
+		ShmSize:         job.GetenvInt64("ShmSize"),
+		OomScoreAdj:     job.GetenvInt("OomScoreAdj"),
+		CgroupParent:    job.Getenv("CgroupParent"),
+		IPAddress:       job.Getenv("IPAddress"),
+		IPv6Address:     job.Getenv("IPv6Address"),
 	}
 
 	job.GetenvJson("LxcConf", &hostConfig.LxcConf)
 	job.GetenvJson("PortBindings", &hostConfig.PortBindings)
 	job.GetenvJson("Devices", &hostConfig.Devices)
 	job.GetenvJson("RestartPolicy", &hostConfig.RestartPolicy)
+	job.GetenvJson("Networks", &hostConfig.Networks)
+	job.GetenvJson("StorageOpt", &hostConfig.StorageOpt)
+	job.GetenvJson("Tmpfs", &hostConfig.Tmpfs)
 
 	job.GetenvJson("Ulimits", &hostConfig.Ulimits)
 
+	if job.EnvExists("Init") {
+		init := job.GetenvBool("Init")
+		hostConfig.Init = &init
+	}
+
 	hostConfig.SecurityOpt = job.GetenvList("SecurityOpt")
+	hostConfig.DeviceCgroupRules = job.GetenvList("DeviceCgroupRules")
+	hostConfig.GroupAdd = job.GetenvList("GroupAdd")
 	if Binds := job.GetenvList("Binds"); Binds != nil {
 		hostConfig.Binds = Binds
 	}
@@ -174,9 +230,15 @@ func ContainerHostConfigFromJob(job *engine.Job) *HostConfig {
 	if DnsSearch := job.GetenvList("DnsSearch"); DnsSearch != nil {
 		hostConfig.DnsSearch = DnsSearch
 	}
+	if DnsOptions := job.GetenvList("DnsOptions"); DnsOptions != nil {
+		hostConfig.DnsOptions = DnsOptions
+	}
 	if ExtraHosts := job.GetenvList("ExtraHosts"); ExtraHosts != nil {
 		hostConfig.ExtraHosts = ExtraHosts
 	}
+	if NetworkAliases := job.GetenvList("NetworkAliases"); NetworkAliases != nil {
+		hostConfig.NetworkAliases = NetworkAliases
+	}
 	if VolumesFrom := job.GetenvList("VolumesFrom"); VolumesFrom != nil {
 		hostConfig.VolumesFrom = VolumesFrom
 	}
@@ -2,6 +2,7 @@ package runconfig
 
 import (
 	"fmt"
+	"net"
 	"path"
 	"strconv"
 	"strings"
@@ -9,6 +10,7 @@ import (
 	"github.com/docker/docker/nat"
 	"github.com/docker/docker/opts"
 	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/pkg/mount"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/ulimit"
 	"github.com/docker/docker/pkg/units"
@@ -16,22 +18,29 @@ import (
 )
 
 var (
-	ErrInvalidWorkingDirectory          = fmt.Errorf("The working directory is invalid. It needs to be an absolute path.")
-	ErrConflictContainerNetworkAndLinks = fmt.Errorf("Conflicting options: --net=container can't be used with links. This would result in undefined behavior.")
-	ErrConflictContainerNetworkAndDns   = fmt.Errorf("Conflicting options: --net=container can't be used with --dns. This configuration is invalid.")
-	ErrConflictNetworkHostname          = fmt.Errorf("Conflicting options: -h and the network mode (--net)")
-	ErrConflictHostNetworkAndDns        = fmt.Errorf("Conflicting options: --net=host can't be used with --dns. This configuration is invalid.")
-	ErrConflictHostNetworkAndLinks      = fmt.Errorf("Conflicting options: --net=host can't be used with links. This would result in undefined behavior.")
+	ErrInvalidWorkingDirectory            = fmt.Errorf("The working directory is invalid. It needs to be an absolute path.")
+	ErrConflictContainerNetworkAndLinks   = fmt.Errorf("Conflicting options: --net=container can't be used with links. This would result in undefined behavior.")
+	ErrConflictContainerNetworkAndDns     = fmt.Errorf("Conflicting options: --net=container can't be used with --dns. This configuration is invalid.")
+	ErrConflictNetworkHostname            = fmt.Errorf("Conflicting options: -h and the network mode (--net)")
+	ErrConflictHostNetworkAndDns          = fmt.Errorf("Conflicting options: --net=host can't be used with --dns. This configuration is invalid.")
+	ErrConflictHostNetworkAndLinks        = fmt.Errorf("Conflicting options: --net=host can't be used with links. This would result in undefined behavior.")
+	ErrConflictUserDefinedNetworkAndLinks = fmt.Errorf("Conflicting options: links are only supported on the default bridge network.")
+	ErrConflictNetworkAliases             = fmt.Errorf("Conflicting options: --network-alias is only supported on user-defined networks.")
+	ErrConflictNetworkConnect             = fmt.Errorf("Conflicting options: --net-connect can't be used with --net=host, --net=container or --net=none.")
+	ErrConflictNetworkIP                  = fmt.Errorf("Conflicting options: --ip and --ip6 are only supported on user-defined networks.")
+	ErrConflictRestartPolicyAndAutoRemove = fmt.Errorf("Conflicting options: --restart and --rm")
 )
 
 func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSet, error) {
 	var (
 		// FIXME: use utils.ListOpts for attach and volumes?
-		flAttach  = opts.NewListOpts(opts.ValidateAttach)
-		flVolumes = opts.NewListOpts(opts.ValidatePath)
-		flLinks   = opts.NewListOpts(opts.ValidateLink)
-		flEnv     = opts.NewListOpts(opts.ValidateEnv)
-		flDevices = opts.NewListOpts(opts.ValidatePath)
+		flAttach            = opts.NewListOpts(opts.ValidateAttach)
+		flVolumes           = opts.NewListOpts(opts.ValidatePath)
+		flLinks             = opts.NewListOpts(opts.ValidateLink)
+		flEnv               = opts.NewListOpts(opts.ValidateEnv)
+		flLabels            = opts.NewListOpts(opts.ValidateLabel)
+		flDevices           = opts.NewListOpts(opts.ValidatePath)
+		flDeviceCgroupRules = opts.NewListOpts(opts.ValidateDeviceCgroupRule)
 
 		ulimits   = make(map[string]*ulimit.Ulimit)
 		flUlimits = opts.NewUlimitOpt(ulimits)
@@ -40,13 +49,19 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 		flExpose      = opts.NewListOpts(nil)
 		flDns         = opts.NewListOpts(opts.ValidateIPAddress)
 		flDnsSearch   = opts.NewListOpts(opts.ValidateDnsSearch)
+		flDnsOptions  = opts.NewListOpts(nil)
 		flExtraHosts  = opts.NewListOpts(opts.ValidateExtraHost)
+		flAliases     = opts.NewListOpts(opts.ValidateNetworkAlias)
+		flNetConnect  = opts.NewListOpts(nil)
 		flVolumesFrom = opts.NewListOpts(nil)
 		flLxcOpts     = opts.NewListOpts(nil)
 		flEnvFile     = opts.NewListOpts(nil)
 		flCapAdd      = opts.NewListOpts(nil)
 		flCapDrop     = opts.NewListOpts(nil)
 		flSecurityOpt = opts.NewListOpts(nil)
+		flStorageOpt  = opts.NewListOpts(nil)
+		flTmpfs       = opts.NewListOpts(nil)
+		flGroupAdd    = opts.NewListOpts(nil)
 
 		flNetwork         = cmd.Bool([]string{"#n", "#-networking"}, true, "Enable networking for this container")
 		flPrivileged      = cmd.Bool([]string{"#privileged", "-privileged"}, false, "Give extended privileges to this container")
@@ -65,28 +80,47 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 		flCpuset          = cmd.String([]string{"-cpuset"}, "", "CPUs in which to allow execution (0-3, 0,1)")
 		flNetMode         = cmd.String([]string{"-net"}, "bridge", "Set the Network mode for the container")
 		flMacAddress      = cmd.String([]string{"-mac-address"}, "", "Container MAC address (e.g. 92:d0:c6:0a:29:33)")
+		flIPAddress       = cmd.String([]string{"-ip"}, "", "Container IPv4 address on its network (e.g. 10.0.0.5)")
+		flIPv6Address     = cmd.String([]string{"-ip6"}, "", "Container IPv6 address on its network (e.g. fd00::5)")
 		flIpcMode         = cmd.String([]string{"-ipc"}, "", "IPC namespace to use")
 		flRestartPolicy   = cmd.String([]string{"-restart"}, "", "Restart policy to apply when a container exits")
+		flAutoRemove      = cmd.Bool([]string{"#rm", "-rm"}, false, "Automatically remove the container when it exits")
 		flReadonlyRootfs  = cmd.Bool([]string{"-read-only"}, false, "Mount the container's root filesystem as read only")
+		flStopTimeout     = cmd.Int([]string{"-stop-timeout"}, 10, "Seconds to wait for the container to stop before killing it")
+		flInit            = cmd.Bool([]string{"-init"}, false, "Run an init inside the container that forwards signals and reaps processes")
+		flPidsLimit       = cmd.Int64([]string{"-pids-limit"}, 0, "Tune container pids limit (set -1 for unlimited)")
+		flCpuRtPeriod     = cmd.Int64([]string{"-cpu-rt-period"}, 0, "Limit the CPU real-time period in microseconds")
+		flOomScoreAdj     = cmd.Int([]string{"-oom-score-adj"}, 0, "Tune host's OOM preferences (-1000 to 1000)")
+		flShmSize         = cmd.String([]string{"-shm-size"}, "", "Size of /dev/shm (format: <number><optional unit>, where unit = b, k, m or g)")
+		flCpuRtRuntime    = cmd.Int64([]string{"-cpu-rt-runtime"}, 0, "Limit the CPU real-time runtime in microseconds")
+		flCgroupParent    = cmd.String([]string{"-cgroup-parent"}, "", "Optional parent cgroup for the container")
 	)
 
 	cmd.Var(&flAttach, []string{"a", "-attach"}, "Attach to STDIN, STDOUT or STDERR")
 	cmd.Var(&flVolumes, []string{"v", "-volume"}, "Bind mount a volume")
 	cmd.Var(&flLinks, []string{"#link", "-link"}, "Add link to another container")
 	cmd.Var(&flDevices, []string{"-device"}, "Add a host device to the container")
+	cmd.Var(&flDeviceCgroupRules, []string{"-device-cgroup-rule"}, "Add a rule to the cgroup allowed devices list")
 	cmd.Var(&flEnv, []string{"e", "-env"}, "Set environment variables")
+	cmd.Var(&flLabels, []string{"l", "-label"}, "Set metadata on the container (e.g., --label=com.example.key=value)")
 	cmd.Var(&flEnvFile, []string{"-env-file"}, "Read in a file of environment variables")
 	cmd.Var(&flPublish, []string{"p", "-publish"}, "Publish a container's port(s) to the host")
 	cmd.Var(&flExpose, []string{"#expose", "-expose"}, "Expose a port or a range of ports")
 	cmd.Var(&flDns, []string{"#dns", "-dns"}, "Set custom DNS servers")
 	cmd.Var(&flDnsSearch, []string{"-dns-search"}, "Set custom DNS search domains")
+	cmd.Var(&flDnsOptions, []string{"-dns-opt"}, "Set DNS options")
 	cmd.Var(&flExtraHosts, []string{"-add-host"}, "Add a custom host-to-IP mapping (host:ip)")
+	cmd.Var(&flAliases, []string{"-network-alias"}, "Add network-scoped alias for the container")
+	cmd.Var(&flNetConnect, []string{"-net-connect"}, "Also connect to network[,ip=IP][,alias=ALIAS]")
 	cmd.Var(&flVolumesFrom, []string{"#volumes-from", "-volumes-from"}, "Mount volumes from the specified container(s)")
 	cmd.Var(&flLxcOpts, []string{"#lxc-conf", "-lxc-conf"}, "Add custom lxc options")
 	cmd.Var(&flCapAdd, []string{"-cap-add"}, "Add Linux capabilities")
 	cmd.Var(&flCapDrop, []string{"-cap-drop"}, "Drop Linux capabilities")
 	cmd.Var(&flSecurityOpt, []string{"-security-opt"}, "Security Options")
+	cmd.Var(&flStorageOpt, []string{"-storage-opt"}, "Storage driver options for the container (e.g. size=20G)")
 	cmd.Var(flUlimits, []string{"-ulimit"}, "Ulimit options")
+	cmd.Var(&flTmpfs, []string{"-tmpfs"}, "Mount a tmpfs directory (e.g. /run:size=64m,mode=1777)")
+	cmd.Var(&flGroupAdd, []string{"-group-add"}, "Add additional groups to join")
 
 	cmd.Require(flag.Min, 1)
 
@@ -111,7 +145,7 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 		attachStderr = flAttach.Get("stderr")
 	)
 
-	if *flNetMode != "bridge" && *flNetMode != "none" && *flHostname != "" {
+	if mode := NetworkMode(*flNetMode); (mode.IsHost() || mode.IsContainer()) && *flHostname != "" {
 		return nil, nil, cmd, ErrConflictNetworkHostname
 	}
 
@@ -123,6 +157,25 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 		return nil, nil, cmd, ErrConflictContainerNetworkAndLinks
 	}
 
+	if NetworkMode(*flNetMode).IsUserDefined() && flLinks.Len() > 0 {
+		return nil, nil, cmd, ErrConflictUserDefinedNetworkAndLinks
+	}
+
+	if !NetworkMode(*flNetMode).IsUserDefined() && flAliases.Len() > 0 {
+		return nil, nil, cmd, ErrConflictNetworkAliases
+	}
+
+	if !NetworkMode(*flNetMode).IsUserDefined() && (*flIPAddress != "" || *flIPv6Address != "") {
+		return nil, nil, cmd, ErrConflictNetworkIP
+	}
+	if err := validateStaticIP(*flIPAddress, *flIPv6Address); err != nil {
+		return nil, nil, cmd, err
+	}
+
+	if !NetworkMode(*flNetMode).IsPrivate() && flNetConnect.Len() > 0 {
+		return nil, nil, cmd, ErrConflictNetworkConnect
+	}
+
 	if *flNetMode == "host" && flDns.Len() > 0 {
 		return nil, nil, cmd, ErrConflictHostNetworkAndDns
 	}
@@ -162,6 +215,22 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 		}
 	}
 
+	if *flOomScoreAdj < -1000 || *flOomScoreAdj > 1000 {
+		return nil, nil, cmd, fmt.Errorf("Invalid value %d, range for oom score adj is [-1000, 1000]", *flOomScoreAdj)
+	}
+
+	var shmSize int64
+	if *flShmSize != "" {
+		parsedShmSize, err := units.RAMInBytes(*flShmSize)
+		if err != nil {
+			return nil, nil, cmd, err
+		}
+		if parsedShmSize <= 0 {
+			return nil, nil, cmd, fmt.Errorf("Invalid --shm-size: %s", *flShmSize)
+		}
+		shmSize = parsedShmSize
+	}
+
 	var binds []string
 	// add any bind targets to the list of container volumes
 	for bind := range flVolumes.GetMap() {
@@ -196,6 +265,16 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 		return nil, nil, cmd, err
 	}
 
+	tmpfs, err := parseTmpfs(flTmpfs)
+	if err != nil {
+		return nil, nil, cmd, err
+	}
+
+	storageOpt, err := parseStorageOpts(flStorageOpt)
+	if err != nil {
+		return nil, nil, cmd, err
+	}
+
 	var (
 		domainname string
 		hostname   = *flHostname
@@ -269,10 +348,22 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 		return nil, nil, cmd, fmt.Errorf("--net: invalid net mode: %v", err)
 	}
 
+	var endpoints []EndpointConfig
+	for _, value := range flNetConnect.GetAll() {
+		endpoint, err := parseEndpointConfig(value)
+		if err != nil {
+			return nil, nil, cmd, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
 	restartPolicy, err := parseRestartPolicy(*flRestartPolicy)
 	if err != nil {
 		return nil, nil, cmd, err
 	}
+	if *flAutoRemove && (restartPolicy.Name == "always" || restartPolicy.Name == "on-failure") {
+		return nil, nil, cmd, ErrConflictRestartPolicyAndAutoRemove
+	}
 
 	config := &Config{
 		Hostname:        hostname,
@@ -291,6 +382,7 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 		AttachStdout:    attachStdout,
 		AttachStderr:    attachStderr,
 		Env:             envVariables,
+		Labels:          convertKVStringsToMap(flLabels.GetAll()),
 		Cmd:             runCmd,
 		Image:           image,
 		Volumes:         flVolumes.GetMap(),
@@ -300,27 +392,61 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 	}
 
 	hostConfig := &HostConfig{
-		Binds:           binds,
-		ContainerIDFile: *flContainerIDFile,
-		LxcConf:         lxcConf,
-		Privileged:      *flPrivileged,
-		PortBindings:    portBindings,
-		Links:           flLinks.GetAll(),
-		PublishAllPorts: *flPublishAll,
-		Dns:             flDns.GetAll(),
-		DnsSearch:       flDnsSearch.GetAll(),
-		ExtraHosts:      flExtraHosts.GetAll(),
-		VolumesFrom:     flVolumesFrom.GetAll(),
-		NetworkMode:     netMode,
-		IpcMode:         ipcMode,
-		PidMode:         pidMode,
-		Devices:         deviceMappings,
-		CapAdd:          flCapAdd.GetAll(),
-		CapDrop:         flCapDrop.GetAll(),
-		RestartPolicy:   restartPolicy,
-		SecurityOpt:     flSecurityOpt.GetAll(),
-		ReadonlyRootfs:  *flReadonlyRootfs,
-		Ulimits:         flUlimits.GetList(),
+		Binds:             binds,
+		ContainerIDFile:   *flContainerIDFile,
+		LxcConf:           lxcConf,
+		Privileged:        *flPrivileged,
+		PortBindings:      portBindings,
+		Links:             flLinks.GetAll(),
+		PublishAllPorts:   *flPublishAll,
+		Dns:               flDns.GetAll(),
+		DnsSearch:         flDnsSearch.GetAll(),
+		DnsOptions:        flDnsOptions.GetAll(),
+		ExtraHosts:        flExtraHosts.GetAll(),
+		VolumesFrom:       flVolumesFrom.GetAll(),
+		NetworkMode:       netMode,
+		IpcMode:           ipcMode,
+		PidMode:           pidMode,
+		Devices:           deviceMappings,
+		DeviceCgroupRules: flDeviceCgroupRules.GetAll(),
+		CapAdd:            flCapAdd.GetAll(),
+		CapDrop:           flCapDrop.GetAll(),
+		RestartPolicy:     restartPolicy,
+		AutoRemove:        *flAutoRemove,
+		SecurityOpt:       flSecurityOpt.GetAll(),
+		ReadonlyRootfs:    *flReadonlyRootfs,
+		Ulimits:           flUlimits.GetList(),
+		PidsLimit:         *flPidsLimit,
+		CpuRtPeriod:       *flCpuRtPeriod,
+		CpuRtRuntime:      *flCpuRtRuntime,
+		ShmSize:           shmSize,
+		OomScoreAdj:       *flOomScoreAdj,
+		CgroupParent:      *flCgroupParent,
+		NetworkAliases:    flAliases.GetAll(),
+		Networks:          endpoints,
+		IPAddress:         *flIPAddress,
+		IPv6Address:       *flIPv6Address,
+		StorageOpt:        storageOpt,
+		Tmpfs:             tmpfs,
+		GroupAdd:          flGroupAdd.GetAll(),
+	}
+
+	if cmd.IsSet("-stop-timeout") {
+		if *flStopTimeout < 0 {
+			return nil, nil, cmd, fmt.Errorf("Invalid value for --stop-timeout: %d", *flStopTimeout)
+		}
+		config.StopTimeout = flStopTimeout
+	}
+
+	if *flCpuRtPeriod < 0 || *flCpuRtRuntime < 0 {
+		return nil, nil, cmd, fmt.Errorf("CPU real-time period and runtime cannot be negative")
+	}
+	if *flCpuRtPeriod != 0 && *flCpuRtRuntime > *flCpuRtPeriod {
+		return nil, nil, cmd, fmt.Errorf("CPU real-time runtime cannot be higher than the CPU real-time period")
+	}
+
+	if cmd.IsSet("-init") {
+		hostConfig.Init = flInit
 	}
 
 	// When allocating stdin in attached mode, close stdin at client disconnect
@@ -386,6 +512,16 @@ func parseDriverOpts(opts opts.ListOpts) (map[string][]string, error) {
 	return out, nil
 }
 
+// convertKVStringsToMap converts a list of validated key=value strings into a map
+func convertKVStringsToMap(values []string) map[string]string {
+	result := make(map[string]string, len(values))
+	for _, value := range values {
+		kv := strings.SplitN(value, "=", 2)
+		result[kv[0]] = kv[1]
+	}
+	return result
+}
+
 func parseKeyValueOpts(opts opts.ListOpts) ([]utils.KeyValuePair, error) {
 	out := make([]utils.KeyValuePair, opts.Len())
 	for i, o := range opts.GetAll() {
@@ -398,6 +534,54 @@ func parseKeyValueOpts(opts opts.ListOpts) ([]utils.KeyValuePair, error) {
 	return out, nil
 }
 
+// parseStorageOpts returns the key=value storage options as a map
+func parseStorageOpts(opts opts.ListOpts) (map[string]string, error) {
+	if opts.Len() == 0 {
+		return nil, nil
+	}
+	out := make(map[string]string, opts.Len())
+	for _, o := range opts.GetAll() {
+		k, v, err := parsers.ParseKeyValueOpt(o)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid storage option %s, the format is key=value", o)
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+// parseTmpfs returns the path:options tmpfs mounts as a map of the paths to
+// their options
+func parseTmpfs(opts opts.ListOpts) (map[string]string, error) {
+	if opts.Len() == 0 {
+		return nil, nil
+	}
+	out := make(map[string]string, opts.Len())
+	for _, t := range opts.GetAll() {
+		dest, options := t, ""
+		if i := strings.Index(t, ":"); i >= 0 {
+			dest, options = t[:i], t[i+1:]
+		}
+		if err := ValidateTmpfs(dest, options); err != nil {
+			return nil, err
+		}
+		out[dest] = options
+	}
+	return out, nil
+}
+
+// ValidateTmpfs returns an error when a tmpfs mount of a container is not on
+// an absolute path other than the root, or its options are not tmpfs options
+func ValidateTmpfs(dest, options string) error {
+	if !path.IsAbs(dest) || path.Clean(dest) == "/" {
+		return fmt.Errorf("Invalid --tmpfs %s: the path must be absolute and not /", dest)
+	}
+	if _, _, err := mount.ParseTmpfsOptions(options); err != nil {
+		return fmt.Errorf("Invalid --tmpfs %s: %v", dest, err)
+	}
+	return nil
+}
+
 func parseNetMode(netMode string) (NetworkMode, error) {
 	parts := strings.Split(netMode, ":")
 	switch mode := parts[0]; mode {
@@ -407,11 +591,63 @@ func parseNetMode(netMode string) (NetworkMode, error) {
 			return "", fmt.Errorf("invalid container format container:<name|id>")
 		}
 	default:
-		return "", fmt.Errorf("invalid --net: %s", netMode)
+		// the name of a user-defined network
+		if len(parts) > 1 || mode == "" {
+			return "", fmt.Errorf("invalid --net: %s", netMode)
+		}
 	}
 	return NetworkMode(netMode), nil
 }
 
+// validateStaticIP checks the IPv4 and IPv6 addresses requested for a
+// container, either can be empty
+func validateStaticIP(ipv4, ipv6 string) error {
+	if ip := net.ParseIP(ipv4); ipv4 != "" && (ip == nil || ip.To4() == nil) {
+		return fmt.Errorf("%s is not a valid IPv4 address", ipv4)
+	}
+	if ip := net.ParseIP(ipv6); ipv6 != "" && (ip == nil || ip.To4() != nil) {
+		return fmt.Errorf("%s is not a valid IPv6 address", ipv6)
+	}
+	return nil
+}
+
+// parseEndpointConfig parses a --net-connect value, the name of a network
+// optionally followed by the addresses and the aliases of the container on
+// it: network[,ip=ip][,ip6=ip6][,alias=alias...]
+func parseEndpointConfig(value string) (EndpointConfig, error) {
+	fields := strings.Split(value, ",")
+	endpoint := EndpointConfig{Network: fields[0]}
+	if endpoint.Network == "" || strings.Contains(endpoint.Network, ":") || !NetworkMode(endpoint.Network).IsUserDefined() {
+		return endpoint, fmt.Errorf("invalid --net-connect: %s is not a user-defined network", endpoint.Network)
+	}
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return endpoint, fmt.Errorf("invalid --net-connect option %s", field)
+		}
+		switch kv[0] {
+		case "ip":
+			if err := validateStaticIP(kv[1], ""); err != nil {
+				return endpoint, err
+			}
+			endpoint.IPAddress = kv[1]
+		case "ip6":
+			if err := validateStaticIP("", kv[1]); err != nil {
+				return endpoint, err
+			}
+			endpoint.IPv6Address = kv[1]
+		case "alias":
+			if _, err := opts.ValidateNetworkAlias(kv[1]); err != nil {
+				return endpoint, err
+			}
+			endpoint.Aliases = append(endpoint.Aliases, kv[1])
+		default:
+			return endpoint, fmt.Errorf("invalid --net-connect option %s", field)
+		}
+	}
+	return endpoint, nil
+}
+
 func ParseDevice(device string) (DeviceMapping, error) {
 	src := ""
 	dst := ""
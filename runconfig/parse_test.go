@@ -57,3 +57,237 @@ func TestNetHostname(t *testing.T) {
 		t.Fatalf("Expected error ErrConflictNetworkHostname, got: %s", err)
 	}
 }
+
+func TestParseUserDefinedNetwork(t *testing.T) {
+	_, hostConfig, _, err := parseRun([]string{"-h=name", "--net=mynet", "img", "cmd"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if hostConfig.NetworkMode != "mynet" || !hostConfig.NetworkMode.IsUserDefined() {
+		t.Fatalf("Expected user-defined network mode mynet, got %q", hostConfig.NetworkMode)
+	}
+
+	if _, _, _, err := parseRun([]string{"--net=mynet", "--link=a:b", "img", "cmd"}); err != ErrConflictUserDefinedNetworkAndLinks {
+		t.Fatalf("Expected error ErrConflictUserDefinedNetworkAndLinks, got: %v", err)
+	}
+
+	if _, _, _, err := parseRun([]string{"--net=foo:bar", "img", "cmd"}); err == nil {
+		t.Fatal("Expected an error for --net=foo:bar")
+	}
+
+	_, hostConfig, _, err = parseRun([]string{"--net=mynet", "--network-alias=db", "--network-alias=cache", "img", "cmd"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(hostConfig.NetworkAliases) != 2 || hostConfig.NetworkAliases[0] != "db" || hostConfig.NetworkAliases[1] != "cache" {
+		t.Fatalf("Expected network aliases [db cache], got %v", hostConfig.NetworkAliases)
+	}
+
+	if _, _, _, err := parseRun([]string{"--network-alias=db", "img", "cmd"}); err != ErrConflictNetworkAliases {
+		t.Fatalf("Expected error ErrConflictNetworkAliases, got: %v", err)
+	}
+
+	_, hostConfig, _, err = parseRun([]string{"--net-connect=back,ip=10.0.0.5,alias=db,alias=cache", "--net-connect=front", "img", "cmd"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(hostConfig.Networks) != 2 {
+		t.Fatalf("Expected 2 networks, got %v", hostConfig.Networks)
+	}
+	if back := hostConfig.Networks[0]; back.Network != "back" || back.IPAddress != "10.0.0.5" || len(back.Aliases) != 2 || back.Aliases[1] != "cache" {
+		t.Fatalf("Unexpected endpoint %+v", back)
+	}
+	if front := hostConfig.Networks[1]; front.Network != "front" || front.IPAddress != "" || front.Aliases != nil {
+		t.Fatalf("Unexpected endpoint %+v", front)
+	}
+
+	if _, _, _, err := parseRun([]string{"--net=host", "--net-connect=back", "img", "cmd"}); err != ErrConflictNetworkConnect {
+		t.Fatalf("Expected error ErrConflictNetworkConnect, got: %v", err)
+	}
+	for _, value := range []string{"bridge", "back,ip=foo", "back,mtu=1500", "back,alias=-db", ",ip=10.0.0.5"} {
+		if _, _, _, err := parseRun([]string{"--net-connect=" + value, "img", "cmd"}); err == nil {
+			t.Fatalf("Expected an error for --net-connect=%s", value)
+		}
+	}
+
+	_, hostConfig, _, err = parseRun([]string{"--net=mynet", "--ip=10.0.0.5", "--ip6=fd00::5", "--net-connect=back,ip6=fd00:1::5", "img", "cmd"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if hostConfig.IPAddress != "10.0.0.5" || hostConfig.IPv6Address != "fd00::5" || hostConfig.Networks[0].IPv6Address != "fd00:1::5" {
+		t.Fatalf("Unexpected static addresses %+v", hostConfig)
+	}
+	if _, _, _, err := parseRun([]string{"--ip=10.0.0.5", "img", "cmd"}); err != ErrConflictNetworkIP {
+		t.Fatalf("Expected error ErrConflictNetworkIP, got: %v", err)
+	}
+	for _, args := range [][]string{{"--ip=fd00::5"}, {"--ip6=10.0.0.5"}, {"--ip=foo"}, {"--net-connect=back,ip6=10.0.0.5"}} {
+		if _, _, _, err := parseRun(append([]string{"--net=mynet"}, append(args, "img", "cmd")...)); err == nil {
+			t.Fatalf("Expected an error for %v", args)
+		}
+	}
+
+	for _, mode := range []NetworkMode{"", "bridge", "host", "none", "container:other"} {
+		if mode.IsUserDefined() {
+			t.Fatalf("Expected %q not to be a user-defined network", mode)
+		}
+	}
+}
+
+func TestParseStopTimeout(t *testing.T) {
+	config, _, _, err := parseRun([]string{"img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.StopTimeout != nil {
+		t.Fatalf("Expected no stop timeout by default, got %d", *config.StopTimeout)
+	}
+
+	config, _, _, err = parseRun([]string{"--stop-timeout=30", "img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.StopTimeout == nil || *config.StopTimeout != 30 {
+		t.Fatalf("Expected a stop timeout of 30, got %v", config.StopTimeout)
+	}
+
+	if _, _, _, err := parseRun([]string{"--stop-timeout=-1", "img", "cmd"}); err == nil {
+		t.Fatal("Expected an error for a negative stop timeout")
+	}
+}
+
+func TestParsePidsLimit(t *testing.T) {
+	_, hostConfig, _, err := parseRun([]string{"--pids-limit=100", "img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hostConfig.PidsLimit != 100 {
+		t.Fatalf("Expected a pids limit of 100, got %d", hostConfig.PidsLimit)
+	}
+}
+
+func TestParseCpuRealtime(t *testing.T) {
+	_, hostConfig, _, err := parseRun([]string{"--cpu-rt-period=1000000", "--cpu-rt-runtime=950000", "img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hostConfig.CpuRtPeriod != 1000000 || hostConfig.CpuRtRuntime != 950000 {
+		t.Fatalf("Unexpected real-time settings: period %d, runtime %d", hostConfig.CpuRtPeriod, hostConfig.CpuRtRuntime)
+	}
+
+	if _, _, _, err := parseRun([]string{"--cpu-rt-period=1000", "--cpu-rt-runtime=2000", "img", "cmd"}); err == nil {
+		t.Fatal("Expected an error when the runtime is higher than the period")
+	}
+	if _, _, _, err := parseRun([]string{"--cpu-rt-runtime=-1", "img", "cmd"}); err == nil {
+		t.Fatal("Expected an error for a negative runtime")
+	}
+}
+
+func TestParseShmSize(t *testing.T) {
+	_, hostConfig, _, err := parseRun([]string{"--shm-size=128m", "img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hostConfig.ShmSize != 128*1024*1024 {
+		t.Fatalf("Expected a shm size of 128m, got %d", hostConfig.ShmSize)
+	}
+
+	if _, _, _, err := parseRun([]string{"--shm-size=abc", "img", "cmd"}); err == nil {
+		t.Fatal("Expected an error for an invalid shm size")
+	}
+}
+
+func TestParseOomScoreAdj(t *testing.T) {
+	_, hostConfig, _, err := parseRun([]string{"--oom-score-adj=-500", "img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hostConfig.OomScoreAdj != -500 {
+		t.Fatalf("Expected an oom score adjustment of -500, got %d", hostConfig.OomScoreAdj)
+	}
+
+	for _, value := range []string{"-1001", "1001"} {
+		if _, _, _, err := parseRun([]string{"--oom-score-adj=" + value, "img", "cmd"}); err == nil {
+			t.Fatalf("Expected an error for oom score adjustment %s", value)
+		}
+	}
+}
+
+func TestParseCgroupParent(t *testing.T) {
+	_, hostConfig, _, err := parseRun([]string{"--cgroup-parent=/site/batch", "img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hostConfig.CgroupParent != "/site/batch" {
+		t.Fatalf("Expected cgroup parent /site/batch, got %q", hostConfig.CgroupParent)
+	}
+}
+
+func TestParseAutoRemove(t *testing.T) {
+	_, hostConfig, _, err := parseRun([]string{"--rm", "img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hostConfig.AutoRemove {
+		t.Fatal("Expected AutoRemove to be set")
+	}
+
+	for _, policy := range []string{"always", "on-failure"} {
+		if _, _, _, err := parseRun([]string{"--rm", "--restart=" + policy, "img", "cmd"}); err != ErrConflictRestartPolicyAndAutoRemove {
+			t.Fatalf("Expected a conflict between --rm and --restart=%s, got %v", policy, err)
+		}
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	config, _, _, err := parseRun([]string{"-l", "com.example.a=1", "--label=com.example.b=", "img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Labels) != 2 || config.Labels["com.example.a"] != "1" || config.Labels["com.example.b"] != "" {
+		t.Fatalf("Unexpected labels %v", config.Labels)
+	}
+
+	if _, _, _, err := parseRun([]string{"--label=com.example.a", "img", "cmd"}); err == nil {
+		t.Fatal("Expected an error for a label without a value")
+	}
+}
+
+func TestParseStorageOpt(t *testing.T) {
+	_, hostConfig, _, err := parseRun([]string{"--storage-opt", "size=20G", "img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hostConfig.StorageOpt) != 1 || hostConfig.StorageOpt["size"] != "20G" {
+		t.Fatalf("Unexpected storage options %v", hostConfig.StorageOpt)
+	}
+
+	if _, _, _, err := parseRun([]string{"--storage-opt", "size", "img", "cmd"}); err == nil {
+		t.Fatal("Expected an error for a storage option without a value")
+	}
+}
+
+func TestParseTmpfs(t *testing.T) {
+	_, hostConfig, _, err := parseRun([]string{"--tmpfs", "/run:size=64m,mode=1777", "--tmpfs", "/tmp", "img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hostConfig.Tmpfs) != 2 || hostConfig.Tmpfs["/run"] != "size=64m,mode=1777" || hostConfig.Tmpfs["/tmp"] != "" {
+		t.Fatalf("Unexpected tmpfs mounts %v", hostConfig.Tmpfs)
+	}
+
+	for _, tmpfs := range []string{"run", "/", "/run:foo=bar"} {
+		if _, _, _, err := parseRun([]string{"--tmpfs", tmpfs, "img", "cmd"}); err == nil {
+			t.Fatalf("Expected an error for --tmpfs %s", tmpfs)
+		}
+	}
+}
+
+func TestParseGroupAdd(t *testing.T) {
+	_, hostConfig, _, err := parseRun([]string{"--group-add", "audio", "--group-add", "777", "img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hostConfig.GroupAdd) != 2 || hostConfig.GroupAdd[0] != "audio" || hostConfig.GroupAdd[1] != "777" {
+		t.Fatalf("Unexpected additional groups %v", hostConfig.GroupAdd)
+	}
+}
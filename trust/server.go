@@ -0,0 +1,85 @@
+package trust
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The signed targets of the repositories are stored on a trust server, an
+// HTTP server which serves them on GET and stores them on PUT at
+// /v2/<repository>/_trust/targets.json. It isn't a Notary server: the
+// targets are the only document, signed with the single key of the
+// repository.
+
+// maxTargetsSize is the maximum size of the signed targets of a repository
+const maxTargetsSize = 1 << 20
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// NoTargetsError is returned when the trust server has no signed targets
+// for a repository
+type NoTargetsError struct {
+	Repository string
+}
+
+func (e NoTargetsError) Error() string {
+	return fmt.Sprintf("No trust data for %s", e.Repository)
+}
+
+// TargetsURL returns the URL of the signed targets of repository on the
+// trust server
+func TargetsURL(server, repository string) string {
+	return fmt.Sprintf("%s/v2/%s/_trust/targets.json", strings.TrimRight(server, "/"), repository)
+}
+
+// FetchTargets returns the signed targets of repository from the trust
+// server, they still have to be verified. The username and the password
+// are sent to the server when the username is set.
+func FetchTargets(server, repository, username, password string) ([]byte, error) {
+	req, err := http.NewRequest("GET", TargetsURL(server, repository), nil)
+	if err != nil {
+		return nil, err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error contacting the trust server %s: %v", server, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, NoTargetsError{repository}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error fetching the trust data for %s: status %d from %s", repository, resp.StatusCode, server)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxTargetsSize))
+}
+
+// PublishTargets stores the signed targets of repository on the trust
+// server
+func PublishTargets(server, repository, username, password string, signed []byte) error {
+	req, err := http.NewRequest("PUT", TargetsURL(server, repository), bytes.NewReader(signed))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error contacting the trust server %s: %v", server, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Error publishing the trust data for %s: status %d from %s", repository, resp.StatusCode, server)
+	}
+	return nil
+}
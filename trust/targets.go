@@ -0,0 +1,118 @@
+package trust
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/pkg/digest"
+	"github.com/docker/libtrust"
+)
+
+// TargetsExpiry is how long the targets signed by a publisher are valid
+const TargetsExpiry = 3 * 365 * 24 * time.Hour
+
+// ErrTargetsKeyMismatch is returned when the targets of a repository are
+// signed with another key than the trusted key of the repository
+var ErrTargetsKeyMismatch = errors.New("The trust data is not signed with the trusted key of the repository")
+
+// Targets are the digests of the manifests of the tags of a repository,
+// signed by the publisher of the repository. The content trust clients pull
+// and run the tags by the digests of their targets.
+type Targets struct {
+	// Repository is the canonical name of the repository
+	Repository string
+	// Version increases each time the targets are signed, the clients
+	// refuse older versions than the last they verified
+	Version int
+	Expires time.Time
+	// Tags maps the tags to the digests of their manifests
+	Tags map[string]digest.Digest
+}
+
+// NewTargets returns empty targets of the repository
+func NewTargets(repository string) *Targets {
+	return &Targets{
+		Repository: repository,
+		Tags:       make(map[string]digest.Digest),
+	}
+}
+
+// SignTargets signs the next version of the targets with key, it returns
+// the signed JSON.
+func SignTargets(targets *Targets, key libtrust.PrivateKey) ([]byte, error) {
+	targets.Version++
+	targets.Expires = time.Now().Add(TargetsExpiry).UTC()
+	content, err := json.MarshalIndent(targets, "", "   ")
+	if err != nil {
+		return nil, err
+	}
+	js, err := libtrust.NewJSONSignature(content)
+	if err != nil {
+		return nil, err
+	}
+	if err := js.Sign(key); err != nil {
+		return nil, err
+	}
+	return js.PrettySignature("signatures")
+}
+
+// VerifyTargets verifies the signed targets of repository. They have to
+// be signed with trustedKey, or with any key when trustedKey is nil as the
+// key of a repository is trusted on first use, and have a version of at
+// least minVersion. It returns the targets and the key they are signed
+// with.
+func VerifyTargets(signed []byte, repository string, trustedKey libtrust.PublicKey, minVersion int) (*Targets, libtrust.PublicKey, error) {
+	js, err := libtrust.ParsePrettySignature(signed, "signatures")
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid trust data for %s: %v", repository, err)
+	}
+	keys, err := js.Verify()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid signature of the trust data for %s: %v", repository, err)
+	}
+	if len(keys) == 0 {
+		return nil, nil, fmt.Errorf("The trust data for %s is not signed", repository)
+	}
+	signer := keys[0]
+	if trustedKey != nil {
+		signer = nil
+		for _, key := range keys {
+			if key.KeyID() == trustedKey.KeyID() {
+				signer = key
+				break
+			}
+		}
+		if signer == nil {
+			return nil, nil, ErrTargetsKeyMismatch
+		}
+	}
+
+	payload, err := js.Payload()
+	if err != nil {
+		return nil, nil, err
+	}
+	targets := &Targets{}
+	if err := json.Unmarshal(payload, targets); err != nil {
+		return nil, nil, fmt.Errorf("Invalid trust data for %s: %v", repository, err)
+	}
+	if targets.Repository != repository {
+		return nil, nil, fmt.Errorf("The trust data for %s is for another repository: %s", repository, targets.Repository)
+	}
+	if targets.Version < minVersion {
+		return nil, nil, fmt.Errorf("The trust data for %s is older than the last verified, version %d < %d", repository, targets.Version, minVersion)
+	}
+	if time.Now().After(targets.Expires) {
+		return nil, nil, fmt.Errorf("The trust data for %s expired on %s", repository, targets.Expires)
+	}
+	for tag, dgst := range targets.Tags {
+		if err := dgst.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("Invalid digest of %s:%s in the trust data: %v", repository, tag, err)
+		}
+	}
+	if targets.Tags == nil {
+		targets.Tags = make(map[string]digest.Digest)
+	}
+	return targets, signer, nil
+}
@@ -0,0 +1,64 @@
+package trust
+
+import (
+	"testing"
+
+	"github.com/docker/docker/pkg/digest"
+	"github.com/docker/libtrust"
+)
+
+func TestSignAndVerifyTargets(t *testing.T) {
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repository := "docker.io/foo/bar"
+	dgst := digest.FromBytes([]byte("manifest"))
+
+	targets := NewTargets(repository)
+	targets.Tags["latest"] = dgst
+	signed, err := SignTargets(targets, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the key is trusted on first use
+	verified, signer, err := VerifyTargets(signed, repository, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer.KeyID() != key.KeyID() {
+		t.Fatalf("Expected the targets to be signed with %s, got %s", key.KeyID(), signer.KeyID())
+	}
+	if verified.Version != 1 || verified.Tags["latest"] != dgst {
+		t.Fatalf("Unexpected targets %+v", verified)
+	}
+
+	if _, _, err := VerifyTargets(signed, repository, key.PublicKey(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := VerifyTargets(signed, repository, otherKey.PublicKey(), 0); err != ErrTargetsKeyMismatch {
+		t.Fatalf("Expected the targets signed with another key to be refused, got %v", err)
+	}
+	if _, _, err := VerifyTargets(signed, "docker.io/foo/other", nil, 0); err == nil {
+		t.Fatal("Expected the targets of another repository to be refused")
+	}
+	if _, _, err := VerifyTargets(signed, repository, nil, 2); err == nil {
+		t.Fatal("Expected older targets than the last verified to be refused")
+	}
+
+	tampered := []byte(string(signed))
+	for i := range tampered {
+		if tampered[i] == '1' {
+			tampered[i] = '2'
+			break
+		}
+	}
+	if _, _, err := VerifyTargets(tampered, repository, nil, 0); err == nil {
+		t.Fatal("Expected tampered targets to be refused")
+	}
+}
@@ -76,16 +76,44 @@ func (p *JSONProgress) String() string {
 	return pbBox + numbersBox + timeLeftBox
 }
 
+// JSONBuildStep is the structured progress of a build, it is sent once each
+// step of the Dockerfile is done
+type JSONBuildStep struct {
+	Step        int           `json:"step"`
+	Instruction string        `json:"instruction"`
+	Stage       int           `json:"stage"`
+	StageName   string        `json:"stageName,omitempty"`
+	Cached      bool          `json:"cached"`
+	Image       string        `json:"image,omitempty"`  // the layer produced by the step
+	Size        int64         `json:"size"`             // the size of the layer
+	Digest      string        `json:"digest,omitempty"` // the tarsum of the layer, in reproducible builds
+	Duration    time.Duration `json:"duration"`         // in nanoseconds
+	Error       string        `json:"error,omitempty"`
+}
+
 type JSONMessage struct {
-	Stream          string        `json:"stream,omitempty"`
-	Status          string        `json:"status,omitempty"`
-	Progress        *JSONProgress `json:"progressDetail,omitempty"`
-	ProgressMessage string        `json:"progress,omitempty"` //deprecated
-	ID              string        `json:"id,omitempty"`
-	From            string        `json:"from,omitempty"`
-	Time            int64         `json:"time,omitempty"`
-	Error           *JSONError    `json:"errorDetail,omitempty"`
-	ErrorMessage    string        `json:"error,omitempty"` //deprecated
+	Stream          string         `json:"stream,omitempty"`
+	Status          string         `json:"status,omitempty"`
+	Progress        *JSONProgress  `json:"progressDetail,omitempty"`
+	ProgressMessage string         `json:"progress,omitempty"` //deprecated
+	ID              string         `json:"id,omitempty"`
+	From            string         `json:"from,omitempty"`
+	Time            int64          `json:"time,omitempty"`
+	Error           *JSONError     `json:"errorDetail,omitempty"`
+	ErrorMessage    string         `json:"error,omitempty"` //deprecated
+	BuildStep       *JSONBuildStep `json:"buildStep,omitempty"`
+	// Type, Action and Actor are the structured fields of the events,
+	// Status, ID and From are kept for the older clients
+	Type   string          `json:",omitempty"`
+	Action string          `json:",omitempty"`
+	Actor  *JSONEventActor `json:",omitempty"`
+}
+
+// JSONEventActor is the object of an event, with its attributes such as the
+// image, the name and the labels of a container
+type JSONEventActor struct {
+	ID         string
+	Attributes map[string]string `json:",omitempty"`
 }
 
 func (jm *JSONMessage) Display(out io.Writer, isTerminal bool) error {
@@ -95,6 +123,10 @@ func (jm *JSONMessage) Display(out io.Writer, isTerminal bool) error {
 		}
 		return jm.Error
 	}
+	if jm.BuildStep != nil && jm.Stream == "" && jm.Status == "" {
+		// only meant for the programs parsing the stream
+		return nil
+	}
 	var endl string
 	if isTerminal && jm.Stream == "" && jm.Progress != nil {
 		// <ESC>[2K = erase entire current line
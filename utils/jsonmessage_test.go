@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -36,3 +38,14 @@ func TestProgress(t *testing.T) {
 		t.Fatalf("Expected %q, got %q", expected, jp4.String())
 	}
 }
+
+func TestDisplayJSONMessagesStreamSkipsBuildSteps(t *testing.T) {
+	in := `{"stream":"Step 0 : FROM busybox\n"}` + "\r\n" + `{"buildStep":{"step":0,"instruction":"FROM busybox"}}` + "\r\n"
+	out := &bytes.Buffer{}
+	if err := DisplayJSONMessagesStream(strings.NewReader(in), out, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Step 0 : FROM busybox\n" {
+		t.Fatalf("Expected the build steps not to be displayed, got %q", out.String())
+	}
+}
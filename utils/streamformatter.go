@@ -79,6 +79,19 @@ func (sf *StreamFormatter) FormatProgress(id, action string, progress *JSONProgr
 	return []byte(action + " " + progress.String() + endl)
 }
 
+// FormatBuildStep formats the structured progress of a build step, it is
+// only sent in JSON streams
+func (sf *StreamFormatter) FormatBuildStep(step *JSONBuildStep) []byte {
+	if !sf.json {
+		return nil
+	}
+	b, err := json.Marshal(&JSONMessage{BuildStep: step})
+	if err != nil {
+		return sf.FormatError(err)
+	}
+	return append(b, streamNewlineBytes...)
+}
+
 func (sf *StreamFormatter) Json() bool {
 	return sf.json
 }
@@ -23,6 +23,17 @@ func TestFormatStatus(t *testing.T) {
 	}
 }
 
+func TestFormatBuildStep(t *testing.T) {
+	sf := NewStreamFormatter(true)
+	res := sf.FormatBuildStep(&JSONBuildStep{Step: 1, Instruction: "RUN true", Cached: true, Image: "abc", Size: 10, Duration: 5})
+	if string(res) != `{"buildStep":{"step":1,"instruction":"RUN true","stage":0,"cached":true,"image":"abc","size":10,"duration":5}}`+"\r\n" {
+		t.Fatalf("%q", res)
+	}
+	if res := NewStreamFormatter(false).FormatBuildStep(&JSONBuildStep{}); res != nil {
+		t.Fatalf("Expected no build step in a plain stream, got %q", res)
+	}
+}
+
 func TestFormatSimpleError(t *testing.T) {
 	sf := NewStreamFormatter(true)
 	res := sf.FormatError(errors.New("Error for formatter"))
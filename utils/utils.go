@@ -171,26 +171,45 @@ func ValidateID(id string) error {
 	return nil
 }
 
-// Code c/c from io.Copy() modified to handle escape sequence
-func CopyEscapable(dst io.Writer, src io.ReadCloser) (written int64, err error) {
-	buf := make([]byte, 32*1024)
+// Code c/c from io.Copy() modified to handle escape sequence: src is closed
+// as soon as the keys sequence is read from it, unless keys is empty.
+func CopyEscapable(dst io.Writer, src io.ReadCloser, keys []byte) (written int64, err error) {
+	if len(keys) == 0 {
+		return io.Copy(dst, src)
+	}
+	var (
+		buf = make([]byte, 32*1024)
+		out = make([]byte, 0, len(buf)+len(keys))
+		// held is the number of keys of the sequence read so far, they are
+		// only written if the sequence turns out to be incomplete
+		held    int
+		escaped bool
+	)
 	for {
 		nr, er := src.Read(buf)
 		if nr > 0 {
 			// ---- Docker addition
-			// char 16 is C-p
-			if nr == 1 && buf[0] == 16 {
-				nr, er = src.Read(buf)
-				// char 17 is C-q
-				if nr == 1 && buf[0] == 17 {
-					if err := src.Close(); err != nil {
-						return 0, err
+			out = out[:0]
+			for _, b := range buf[0:nr] {
+				if b == keys[held] {
+					held++
+					if escaped = held == len(keys); escaped {
+						break
 					}
-					return 0, nil
+					continue
 				}
+				if held > 0 {
+					out = append(out, keys[:held]...)
+					held = 0
+					if b == keys[0] {
+						held = 1
+						continue
+					}
+				}
+				out = append(out, b)
 			}
 			// ---- End of docker
-			nw, ew := dst.Write(buf[0:nr])
+			nw, ew := dst.Write(out)
 			if nw > 0 {
 				written += int64(nw)
 			}
@@ -198,12 +217,24 @@ func CopyEscapable(dst io.Writer, src io.ReadCloser) (written int64, err error)
 				err = ew
 				break
 			}
-			if nr != nw {
+			if len(out) != nw {
 				err = io.ErrShortWrite
 				break
 			}
+			if escaped {
+				if err := src.Close(); err != nil {
+					return written, err
+				}
+				return written, nil
+			}
 		}
 		if er == io.EOF {
+			// the keys held at the end of the input are no sequence
+			if held > 0 {
+				nw, ew := dst.Write(keys[:held])
+				written += int64(nw)
+				err = ew
+			}
 			break
 		}
 		if er != nil {
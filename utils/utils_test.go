@@ -2,6 +2,7 @@ package utils
 
 import (
 	"bytes"
+	"io"
 	"os"
 	"strings"
 	"testing"
@@ -122,3 +123,56 @@ func TestWriteCounter(t *testing.T) {
 		t.Error("Wrong message written")
 	}
 }
+
+type chunkReader struct {
+	chunks []string
+	closed bool
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
+
+func (r *chunkReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestCopyEscapable(t *testing.T) {
+	tests := []struct {
+		chunks []string
+		keys   string
+		out    string
+		closed bool
+	}{
+		{[]string{"abc", "\x10", "\x11", "def"}, "\x10\x11", "abc", true},
+		{[]string{"ab\x10\x11cd"}, "\x10\x11", "ab", true},
+		{[]string{"ab\x10", "c\x10\x11"}, "\x10\x11", "ab\x10c", true},
+		{[]string{"\x10\x10", "\x11"}, "\x10\x11", "\x10", true},
+		{[]string{"ab\x10\x11cd"}, "", "ab\x10\x11cd", false},
+		{[]string{"x", "y", "z"}, "xz", "xyz", false},
+		{[]string{"abc"}, "\x10\x11", "abc", false},
+		{[]string{"ab", "\x10"}, "\x10\x11", "ab\x10", false},
+	}
+	for i, test := range tests {
+		src := &chunkReader{chunks: test.chunks}
+		dst := bytes.NewBuffer(nil)
+		if _, err := CopyEscapable(dst, src, []byte(test.keys)); err != nil {
+			t.Fatalf("Test %d: %s", i, err)
+		}
+		if dst.String() != test.out {
+			t.Fatalf("Test %d: expected %q, got %q", i, test.out, dst.String())
+		}
+		if src.closed != test.closed {
+			t.Fatalf("Test %d: expected the source to be closed: %v", i, test.closed)
+		}
+	}
+}
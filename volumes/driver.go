@@ -0,0 +1,112 @@
+package volumes
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/docker/docker/pkg/plugins"
+)
+
+// DefaultDriver is the driver of the named volumes created without one, it
+// keeps them on the host like the anonymous volumes
+const DefaultDriver = "local"
+
+// Driver manages the storage of the named volumes of a given type
+type Driver interface {
+	// Create creates the storage of the volume, the driver must accept a
+	// volume it already has
+	Create(v *Volume) error
+	// Remove removes the volume and its data
+	Remove(v *Volume) error
+	// Mount makes the volume available on the host for a container and
+	// returns its path, each Mount is paired with an Unmount
+	Mount(v *Volume) (string, error)
+	// Unmount is called when a container using the volume stops
+	Unmount(v *Volume) error
+	// Path returns the path of the volume on the host, or "" when it is
+	// not mounted
+	Path(v *Volume) (string, error)
+}
+
+// Lister is implemented by the drivers which may have volumes the daemon
+// didn't create, such as the volumes of a storage shared by several hosts
+type Lister interface {
+	// Get returns whether the driver has a volume named name
+	Get(name string) (bool, error)
+	// List returns the names of the volumes of the driver
+	List() ([]string, error)
+}
+
+var (
+	drivers     = make(map[string]Driver)
+	driversLock sync.Mutex
+)
+
+// Register makes a volume driver available under name
+func Register(name string, driver Driver) error {
+	driversLock.Lock()
+	defer driversLock.Unlock()
+	if _, exists := drivers[name]; exists || name == DefaultDriver {
+		return fmt.Errorf("Volume driver already registered %s", name)
+	}
+	drivers[name] = driver
+	return nil
+}
+
+// GetDriver returns the volume driver registered under name, or the volume
+// driver plugin name, which is registered on its first use
+func GetDriver(name string) (Driver, error) {
+	driversLock.Lock()
+	driver, exists := drivers[name]
+	driversLock.Unlock()
+	if exists {
+		return driver, nil
+	}
+	// the plugin is activated without the lock, the activation can take
+	// a while and must not hold up the other volumes
+	driver, err := getRemoteDriver(name)
+	switch err {
+	case nil:
+	case plugins.ErrNotFound:
+		driversLock.Lock()
+		defer driversLock.Unlock()
+		return nil, fmt.Errorf("Unknown volume driver %s, available drivers: %v", name, driverNames())
+	case plugins.ErrNotImplements:
+		return nil, fmt.Errorf("Plugin %s is not a volume driver", name)
+	default:
+		return nil, fmt.Errorf("Volume driver %s: %v", name, err)
+	}
+
+	driversLock.Lock()
+	defer driversLock.Unlock()
+	if registered, exists := drivers[name]; exists {
+		return registered, nil
+	}
+	drivers[name] = driver
+	return driver, nil
+}
+
+// registeredListers returns the registered drivers which may have volumes
+// the daemon didn't create, by name
+func registeredListers() map[string]Lister {
+	driversLock.Lock()
+	defer driversLock.Unlock()
+	listers := make(map[string]Lister)
+	for name, driver := range drivers {
+		if l, ok := driver.(Lister); ok {
+			listers[name] = l
+		}
+	}
+	return listers
+}
+
+// driverNames must be called with driversLock held
+func driverNames() []string {
+	names := []string{DefaultDriver}
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,152 @@
+package volumes
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/pkg/mount"
+)
+
+// localDriver keeps the named volumes in directories of the graph driver of
+// the volumes, like the anonymous volumes. A filesystem is mounted on the
+// directory of the volumes created with the type, device and o options,
+// like mount -t type -o o device.
+type localDriver struct {
+	driver graphdriver.Driver
+	// mounts counts the containers using the mounted volumes, by ID
+	mounts map[string]int
+	lock   sync.Mutex
+}
+
+func newLocalDriver(driver graphdriver.Driver) *localDriver {
+	return &localDriver{
+		driver: driver,
+		mounts: make(map[string]int),
+	}
+}
+
+var validLocalOptions = map[string]struct{}{"type": {}, "device": {}, "o": {}}
+
+func validateLocalOptions(options map[string]string) error {
+	for key := range options {
+		if _, ok := validLocalOptions[key]; !ok {
+			return fmt.Errorf("Invalid option %s for volume driver %s, the options are type, device and o", key, DefaultDriver)
+		}
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	if options["type"] == "" {
+		return fmt.Errorf("Volume driver %s needs the type of the filesystem to mount", DefaultDriver)
+	}
+	if options["device"] == "" && options["type"] != "tmpfs" {
+		return fmt.Errorf("Volume driver %s needs the device to mount a %s filesystem", DefaultDriver, options["type"])
+	}
+	return nil
+}
+
+func (d *localDriver) Create(v *Volume) error {
+	if err := validateLocalOptions(v.Options); err != nil {
+		return err
+	}
+	return d.driver.Create(v.ID, "")
+}
+
+func (d *localDriver) Remove(v *Volume) error {
+	if len(v.Options) > 0 {
+		// the filesystem may be left mounted by a daemon which didn't stop
+		// cleanly
+		path, err := d.Path(v)
+		if err != nil {
+			return err
+		}
+		if err := mount.Unmount(path); err != nil {
+			return err
+		}
+	}
+	return d.driver.Remove(v.ID)
+}
+
+func (d *localDriver) Mount(v *Volume) (string, error) {
+	path, err := d.Path(v)
+	if err != nil || len(v.Options) == 0 {
+		return path, err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.mounts[v.ID] == 0 {
+		if err := mountLocalVolume(v.Options, path); err != nil {
+			return "", fmt.Errorf("Error mounting volume %s: %v", v.name(), err)
+		}
+	}
+	d.mounts[v.ID]++
+	return path, nil
+}
+
+func (d *localDriver) Unmount(v *Volume) error {
+	if len(v.Options) == 0 {
+		return nil
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.mounts[v.ID] > 1 {
+		d.mounts[v.ID]--
+		return nil
+	}
+	delete(d.mounts, v.ID)
+	path, err := d.Path(v)
+	if err != nil {
+		return err
+	}
+	if err := mount.Unmount(path); err != nil {
+		return fmt.Errorf("Error unmounting volume %s: %v", v.name(), err)
+	}
+	return nil
+}
+
+func (d *localDriver) Path(v *Volume) (string, error) {
+	path, err := d.driver.Get(v.ID, "")
+	if err != nil {
+		return "", fmt.Errorf("Driver %s failed to get volume rootfs %s: %v", d.driver, v.ID, err)
+	}
+	return path, nil
+}
+
+// mountLocalVolume mounts the filesystem of the options on path, it is left
+// alone when it is already mounted
+func mountLocalVolume(options map[string]string, path string) error {
+	fsType, device, data := options["type"], options["device"], options["o"]
+	if device == "" {
+		device = fsType
+	}
+	if fsType == "nfs" || fsType == "nfs4" {
+		// the kernel needs the address of the server rather than its name
+		var err error
+		if data, err = resolveAddr(data); err != nil {
+			return err
+		}
+	}
+	return mount.Mount(device, path, fsType, data)
+}
+
+// resolveAddr replaces the host name of the addr= option of data with its
+// address
+func resolveAddr(data string) (string, error) {
+	opts := strings.Split(data, ",")
+	for i, opt := range opts {
+		if !strings.HasPrefix(opt, "addr=") {
+			continue
+		}
+		addr, err := net.ResolveIPAddr("ip", strings.TrimPrefix(opt, "addr="))
+		if err != nil {
+			return "", err
+		}
+		opts[i] = "addr=" + addr.String()
+	}
+	return strings.Join(opts, ","), nil
+}
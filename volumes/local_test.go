@@ -0,0 +1,45 @@
+package volumes
+
+import "testing"
+
+func TestValidateLocalOptions(t *testing.T) {
+	valid := []map[string]string{
+		nil,
+		{"type": "tmpfs"},
+		{"type": "tmpfs", "device": "tmpfs", "o": "size=64m,uid=1000"},
+		{"type": "ext4", "device": "/dev/sdb1"},
+		{"type": "nfs", "device": ":/export/data", "o": "addr=10.0.0.1,rw"},
+	}
+	for _, options := range valid {
+		if err := validateLocalOptions(options); err != nil {
+			t.Fatalf("Expected %v to be valid: %v", options, err)
+		}
+	}
+	invalid := []map[string]string{
+		{"size": "1G"},
+		{"device": "/dev/sdb1"},
+		{"type": "ext4"},
+		{"type": "tmpfs", "o": "size=64m", "uid": "1000"},
+	}
+	for _, options := range invalid {
+		if err := validateLocalOptions(options); err == nil {
+			t.Fatalf("Expected %v to be refused", options)
+		}
+	}
+}
+
+func TestResolveAddr(t *testing.T) {
+	data, err := resolveAddr("rw,addr=127.0.0.1,vers=4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data != "rw,addr=127.0.0.1,vers=4" {
+		t.Fatalf("Expected the options to be kept, got %s", data)
+	}
+	if data, err := resolveAddr("rw"); err != nil || data != "rw" {
+		t.Fatalf("Expected the options without addr to be kept, got %s, %v", data, err)
+	}
+	if _, err := resolveAddr("addr=invalid..host"); err == nil {
+		t.Fatal("Expected an invalid host to be refused")
+	}
+}
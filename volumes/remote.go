@@ -0,0 +1,128 @@
+package volumes
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/pkg/plugins"
+)
+
+// VolumeDriverImplements is the subsystem implemented by the volume driver
+// plugins, they are used like the built-in driver with -d
+const VolumeDriverImplements = "VolumeDriver"
+
+// remoteDriver is a volume driver plugin, the volumes are known to the
+// plugin by name
+type remoteDriver struct {
+	name   string
+	plugin *plugins.Plugin
+}
+
+type volumeRequest struct {
+	Name string
+}
+
+type createVolumeRequest struct {
+	Name string
+	Opts map[string]string
+}
+
+// volumeResponse is the answer of the plugin, which may report an error
+// with a 200 status
+type volumeResponse struct {
+	Mountpoint string
+	Err        string
+}
+
+type remoteVolume struct {
+	Name       string
+	Mountpoint string
+}
+
+type getVolumeResponse struct {
+	Volume *remoteVolume
+	Err    string
+}
+
+type listVolumesResponse struct {
+	Volumes []remoteVolume
+	Err     string
+}
+
+func getRemoteDriver(name string) (Driver, error) {
+	p, err := plugins.Get(name, VolumeDriverImplements)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteDriver{name: name, plugin: p}, nil
+}
+
+// call calls the method of the plugin and returns the error it reported,
+// either with an error status or in the Err field of the answer
+func (d *remoteDriver) call(method string, req interface{}) (*volumeResponse, error) {
+	var res volumeResponse
+	if err := d.plugin.Client.Call("VolumeDriver."+method, req, &res); err != nil {
+		return nil, err
+	}
+	if res.Err != "" {
+		return nil, fmt.Errorf("VolumeDriver.%s: %s", method, res.Err)
+	}
+	return &res, nil
+}
+
+func (d *remoteDriver) Create(v *Volume) error {
+	_, err := d.call("Create", &createVolumeRequest{Name: v.Name, Opts: v.Options})
+	return err
+}
+
+func (d *remoteDriver) Remove(v *Volume) error {
+	_, err := d.call("Remove", &volumeRequest{Name: v.Name})
+	return err
+}
+
+func (d *remoteDriver) Mount(v *Volume) (string, error) {
+	res, err := d.call("Mount", &volumeRequest{Name: v.Name})
+	if err != nil {
+		return "", err
+	}
+	if res.Mountpoint == "" {
+		return "", fmt.Errorf("Volume driver %s returned no mountpoint for volume %s", d.name, v.Name)
+	}
+	return res.Mountpoint, nil
+}
+
+func (d *remoteDriver) Unmount(v *Volume) error {
+	_, err := d.call("Unmount", &volumeRequest{Name: v.Name})
+	return err
+}
+
+func (d *remoteDriver) Path(v *Volume) (string, error) {
+	res, err := d.call("Path", &volumeRequest{Name: v.Name})
+	if err != nil {
+		return "", err
+	}
+	return res.Mountpoint, nil
+}
+
+func (d *remoteDriver) Get(name string) (bool, error) {
+	var res getVolumeResponse
+	if err := d.plugin.Client.Call("VolumeDriver.Get", &volumeRequest{Name: name}, &res); err != nil {
+		return false, err
+	}
+	// the plugins report a missing volume as an error
+	return res.Err == "" && res.Volume != nil, nil
+}
+
+func (d *remoteDriver) List() ([]string, error) {
+	var res listVolumesResponse
+	if err := d.plugin.Client.Call("VolumeDriver.List", nil, &res); err != nil {
+		return nil, err
+	}
+	if res.Err != "" {
+		return nil, fmt.Errorf("VolumeDriver.List: %s", res.Err)
+	}
+	names := make([]string, 0, len(res.Volumes))
+	for _, v := range res.Volumes {
+		names = append(names, v.Name)
+	}
+	return names, nil
+}
@@ -0,0 +1,193 @@
+package volumes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/pkg/plugins/pluginstest"
+)
+
+func TestRemoteDriver(t *testing.T) {
+	var created createVolumeRequest
+	mounts := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/VolumeDriver.Create", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&created)
+		fmt.Fprintln(w, "{}")
+	})
+	mux.HandleFunc("/VolumeDriver.Mount", func(w http.ResponseWriter, r *http.Request) {
+		mounts++
+		fmt.Fprintln(w, `{"Mountpoint": "/mnt/nfs/data"}`)
+	})
+	mux.HandleFunc("/VolumeDriver.Unmount", func(w http.ResponseWriter, r *http.Request) {
+		mounts--
+		fmt.Fprintln(w, "{}")
+	})
+	mux.HandleFunc("/VolumeDriver.Remove", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"Err": "volume data is in use"}`)
+	})
+	mux.HandleFunc("/VolumeDriver.Get", func(w http.ResponseWriter, r *http.Request) {
+		var req volumeRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Name != "data" {
+			fmt.Fprintf(w, `{"Err": "no such volume %s"}`, req.Name)
+			return
+		}
+		fmt.Fprintln(w, `{"Volume": {"Name": "data", "Mountpoint": "/mnt/nfs/data"}}`)
+	})
+	mux.HandleFunc("/VolumeDriver.List", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"Volumes": [{"Name": "data"}, {"Name": "logs"}]}`)
+	})
+	server, p := pluginstest.NewPlugin(t, mux)
+	defer server.Close()
+
+	d := &remoteDriver{name: "fake", plugin: p}
+	v := &Volume{Name: "data", Options: map[string]string{"share": "nfs1:/export"}}
+	if err := d.Create(v); err != nil {
+		t.Fatal(err)
+	}
+	if created.Name != "data" || created.Opts["share"] != "nfs1:/export" {
+		t.Fatalf("Unexpected create request %+v", created)
+	}
+
+	path, err := d.Mount(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/mnt/nfs/data" {
+		t.Fatalf("Expected the mountpoint of the plugin, got %s", path)
+	}
+	if err := d.Unmount(v); err != nil {
+		t.Fatal(err)
+	}
+	if mounts != 0 {
+		t.Fatalf("Expected the volume to be unmounted, %d mounts left", mounts)
+	}
+
+	if err := d.Remove(v); err == nil || err.Error() != "VolumeDriver.Remove: volume data is in use" {
+		t.Fatalf("Expected the error of the plugin, got %v", err)
+	}
+
+	if exists, err := d.Get("data"); err != nil || !exists {
+		t.Fatalf("Expected volume data to exist, got %v, %v", exists, err)
+	}
+	if exists, err := d.Get("unknown"); err != nil || exists {
+		t.Fatalf("Expected volume unknown not to exist, got %v, %v", exists, err)
+	}
+	names, err := d.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "data" || names[1] != "logs" {
+		t.Fatalf("Unexpected volumes %v", names)
+	}
+}
+
+func TestRemoteDriverNoMountpoint(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/VolumeDriver.Mount", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "{}")
+	})
+	server, p := pluginstest.NewPlugin(t, mux)
+	defer server.Close()
+
+	d := &remoteDriver{name: "fake", plugin: p}
+	if _, err := d.Mount(&Volume{Name: "data"}); err == nil || !strings.Contains(err.Error(), "no mountpoint") {
+		t.Fatalf("Expected a missing mountpoint to be refused, got %v", err)
+	}
+}
+
+func TestRepositoryCallsPluginUnlocked(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "volumes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	repo, err := newRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the plugin fails the calls made while the repository is locked
+	unlocked := func(w http.ResponseWriter) bool {
+		done := make(chan struct{})
+		go func() {
+			repo.GetByID("")
+			close(done)
+		}()
+		select {
+		case <-done:
+			return true
+		case <-time.After(time.Second):
+			fmt.Fprintln(w, `{"Err": "called with the repository locked"}`)
+			return false
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/VolumeDriver.Mount", func(w http.ResponseWriter, r *http.Request) {
+		if unlocked(w) {
+			fmt.Fprintln(w, `{"Mountpoint": "/mnt/nfs/data"}`)
+		}
+	})
+	mux.HandleFunc("/VolumeDriver.Path", func(w http.ResponseWriter, r *http.Request) {
+		var req volumeRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		// the volumes created through the repository are added under its lock
+		if req.Name == "scratch" || unlocked(w) {
+			fmt.Fprintln(w, `{"Mountpoint": ""}`)
+		}
+	})
+	mux.HandleFunc("/VolumeDriver.List", func(w http.ResponseWriter, r *http.Request) {
+		if unlocked(w) {
+			fmt.Fprintln(w, `{"Volumes": [{"Name": "data"}]}`)
+		}
+	})
+	mux.HandleFunc("/VolumeDriver.Create", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{}`)
+	})
+	mux.HandleFunc("/VolumeDriver.Remove", func(w http.ResponseWriter, r *http.Request) {
+		if unlocked(w) {
+			fmt.Fprintln(w, `{}`)
+		}
+	})
+	server, p := pluginstest.NewPlugin(t, mux)
+	defer server.Close()
+	if err := Register("unlockedplugin", &remoteDriver{name: "unlockedplugin", plugin: p}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		driversLock.Lock()
+		delete(drivers, "unlockedplugin")
+		driversLock.Unlock()
+	}()
+
+	list := repo.List()
+	if len(list) != 1 || list[0].Name != "data" || list[0].Driver != "unlockedplugin" {
+		t.Fatalf("Expected the volume of the plugin to be listed, got %v", list)
+	}
+	if repo.GetByID(list[0].ID) != list[0] {
+		t.Fatal("Expected the volume to be found by its ID")
+	}
+	if path, err := repo.Mount(list[0]); err != nil || path != "/mnt/nfs/data" {
+		t.Fatalf("Expected the volume to be mounted, got %s, %v", path, err)
+	}
+	if pruned, _ := repo.Prune(func(*Volume) bool { return true }); len(pruned) != 0 {
+		t.Fatalf("Expected the adopted volume not to be pruned, got %v", pruned)
+	}
+
+	if _, err := repo.Create("scratch", "unlockedplugin", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if pruned, _ := repo.Prune(func(*Volume) bool { return true }); len(pruned) != 1 || pruned[0].Name != "scratch" {
+		t.Fatalf("Expected the volume of the plugin to be pruned, got %v", pruned)
+	}
+	if _, err := repo.GetNamed("scratch"); err == nil {
+		t.Fatal("Expected the pruned volume to be removed from the repository")
+	}
+}
@@ -5,16 +5,25 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"sync"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/daemon/graphdriver"
 	"github.com/docker/docker/pkg/common"
+	"github.com/docker/docker/pkg/directory"
 )
 
+const validVolumeNameChars = `[a-zA-Z0-9][a-zA-Z0-9_.-]`
+
+var validVolumeNamePattern = regexp.MustCompile(`^` + validVolumeNameChars + `+$`)
+
+// Repository stores the volumes, by ID
 type Repository struct {
 	configPath string
 	driver     graphdriver.Driver
+	local      *localDriver
 	volumes    map[string]*Volume
 	lock       sync.Mutex
 }
@@ -32,6 +41,7 @@ func NewRepository(configPath string, driver graphdriver.Driver) (*Repository, e
 
 	repo := &Repository{
 		driver:     driver,
+		local:      newLocalDriver(driver),
 		configPath: abspath,
 		volumes:    make(map[string]*Volume),
 	}
@@ -117,19 +127,57 @@ func (r *Repository) Get(path string) *Volume {
 	return vol
 }
 
+// GetByID returns the volume of the given ID, or nil
+func (r *Repository) GetByID(id string) *Volume {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.volumes[id]
+}
+
 func (r *Repository) get(path string) *Volume {
 	path, err := filepath.EvalSymlinks(path)
 	if err != nil {
 		return nil
 	}
-	return r.volumes[filepath.Clean(path)]
+	path = filepath.Clean(path)
+	for _, v := range r.volumes {
+		if v.Path == path {
+			return v
+		}
+	}
+	return nil
+}
+
+func (r *Repository) getByName(name string) *Volume {
+	for _, v := range r.volumes {
+		if v.Name != "" && v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// getByNameOrID returns the named volume name, or the anonymous volume of
+// ID name, the anonymous volumes are listed by their ID
+func (r *Repository) getByNameOrID(name string) *Volume {
+	if v := r.getByName(name); v != nil {
+		return v
+	}
+	if v, exists := r.volumes[name]; exists && v.Name == "" && !v.IsBindMount {
+		return v
+	}
+	return nil
 }
 
 func (r *Repository) add(volume *Volume) error {
-	if vol := r.get(volume.Path); vol != nil {
+	if volume.Name != "" {
+		if vol := r.getByName(volume.Name); vol != nil {
+			return fmt.Errorf("Volume exists: %s", volume.Name)
+		}
+	} else if vol := r.get(volume.Path); vol != nil {
 		return fmt.Errorf("Volume exists: %s", volume.ID)
 	}
-	r.volumes[volume.Path] = volume
+	r.volumes[volume.ID] = volume
 	return nil
 }
 
@@ -144,6 +192,9 @@ func (r *Repository) Delete(path string) error {
 	if volume == nil {
 		return fmt.Errorf("Volume %s does not exist", path)
 	}
+	if volume.Name != "" {
+		return fmt.Errorf("Volume %s is a named volume, it is removed with docker volume rm", volume.Name)
+	}
 
 	containers := volume.Containers()
 	if len(containers) > 0 {
@@ -162,7 +213,7 @@ func (r *Repository) Delete(path string) error {
 		}
 	}
 
-	delete(r.volumes, volume.Path)
+	delete(r.volumes, volume.ID)
 	return nil
 }
 
@@ -193,3 +244,318 @@ func (r *Repository) FindOrCreateVolume(path string, writable bool) (*Volume, er
 
 	return r.newVolume(path, writable)
 }
+
+// getDriver returns the driver of the named volumes of type name, the
+// anonymous volumes have no driver and are kept by the local driver
+func (r *Repository) getDriver(name string) (Driver, error) {
+	if name == DefaultDriver || name == "" {
+		return r.local, nil
+	}
+	return GetDriver(name)
+}
+
+// Create creates a named volume with driver, a random name is given to the
+// volume when name is empty. The options are passed to the driver, the
+// labels are kept with the volume.
+func (r *Repository) Create(name, driverName string, options, labels map[string]string) (*Volume, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.create(name, driverName, options, labels)
+}
+
+func (r *Repository) create(name, driverName string, options, labels map[string]string) (*Volume, error) {
+	id := common.GenerateRandomID()
+	if name == "" {
+		name = id
+	}
+	if !validVolumeNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("Invalid volume name (%s), only %s are allowed", name, validVolumeNameChars)
+	}
+	if v := r.getByName(name); v != nil {
+		return nil, fmt.Errorf("Volume with name %s already exists", name)
+	}
+	if driverName == "" {
+		driverName = DefaultDriver
+	}
+	driver, err := r.getDriver(driverName)
+	if err != nil {
+		return nil, err
+	}
+
+	v := r.newNamedVolume(id, name, driverName, options)
+	v.Labels = labels
+	if err := driver.Create(v); err != nil {
+		return nil, err
+	}
+	if err := r.addNamed(v, driver); err != nil {
+		driver.Remove(v)
+		return nil, err
+	}
+	return v, nil
+}
+
+// adopt adds the volume name the driver had before the daemon used it
+func (r *Repository) adopt(name, driverName string) (*Volume, error) {
+	driver, err := r.getDriver(driverName)
+	if err != nil {
+		return nil, err
+	}
+	v := r.newNamedVolume(common.GenerateRandomID(), name, driverName, nil)
+	v.Adopted = true
+	if err := r.addNamed(v, driver); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (r *Repository) newNamedVolume(id, name, driverName string, options map[string]string) *Volume {
+	return &Volume{
+		ID:         id,
+		Name:       name,
+		Driver:     driverName,
+		Options:    options,
+		Writable:   true,
+		repository: r,
+		containers: make(map[string]struct{}),
+		configPath: filepath.Join(r.configPath, id),
+	}
+}
+
+// addNamed stores the named volume v the driver created
+func (r *Repository) addNamed(v *Volume, driver Driver) error {
+	var err error
+	if v.Path, err = driver.Path(v); err != nil {
+		return err
+	}
+	if err := v.initialize(); err != nil {
+		os.RemoveAll(v.configPath)
+		return err
+	}
+	return r.add(v)
+}
+
+// IsNamed returns whether the source of a -v source:dest volume is the name
+// of a named volume rather than a host path
+func IsNamed(source string) bool {
+	return !filepath.IsAbs(source) && validVolumeNamePattern.MatchString(source)
+}
+
+// GetNamed looks a named volume up by name, or an anonymous volume by ID.
+// The volumes of the drivers which may have volumes the daemon didn't create
+// are added on first use.
+func (r *Repository) GetNamed(name string) (*Volume, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.getNamed(name)
+}
+
+func (r *Repository) getNamed(name string) (*Volume, error) {
+	if v := r.getByNameOrID(name); v != nil {
+		return v, nil
+	}
+	for driverName, l := range registeredListers() {
+		exists, err := l.Get(name)
+		if err != nil {
+			log.Debugf("Error looking volume %s up in driver %s: %v", name, driverName, err)
+			continue
+		}
+		if exists {
+			return r.adopt(name, driverName)
+		}
+	}
+	return nil, fmt.Errorf("No such volume: %s", name)
+}
+
+// FindOrCreateNamed returns the named volume name, which is created with
+// the default driver when it doesn't exist
+func (r *Repository) FindOrCreateNamed(name string) (*Volume, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if v, err := r.getNamed(name); err == nil {
+		return v, nil
+	}
+	return r.create(name, DefaultDriver, nil, nil)
+}
+
+// List returns the named volumes and the anonymous volumes sorted by name,
+// the anonymous volumes are named by their ID. The volumes of the drivers
+// which may have volumes the daemon didn't create are added to the list.
+// The drivers are called without holding the lock of the repository.
+func (r *Repository) List() []*Volume {
+	for driverName, l := range registeredListers() {
+		names, err := l.List()
+		if err != nil {
+			log.Errorf("Error listing the volumes of driver %s: %v", driverName, err)
+			continue
+		}
+		for _, name := range names {
+			if r.hasNamed(name) {
+				continue
+			}
+			if _, err := r.adoptUnlocked(name, driverName); err != nil {
+				log.Errorf("Error adding volume %s of driver %s: %v", name, driverName, err)
+			}
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var list []*Volume
+	for _, v := range r.volumes {
+		if !v.IsBindMount {
+			list = append(list, v)
+		}
+	}
+	sort.Sort(byName(list))
+	return list
+}
+
+func (r *Repository) hasNamed(name string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.getByName(name) != nil
+}
+
+// adoptUnlocked is adopt called without the lock of the repository, the
+// path of the volume is asked to its driver before the lock is taken
+func (r *Repository) adoptUnlocked(name, driverName string) (*Volume, error) {
+	driver, err := r.getDriver(driverName)
+	if err != nil {
+		return nil, err
+	}
+	v := r.newNamedVolume(common.GenerateRandomID(), name, driverName, nil)
+	v.Adopted = true
+	if v.Path, err = driver.Path(v); err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if existing := r.getByName(name); existing != nil {
+		return existing, nil
+	}
+	if err := v.initialize(); err != nil {
+		os.RemoveAll(v.configPath)
+		return nil, err
+	}
+	return v, r.add(v)
+}
+
+// Remove removes a named volume, or an anonymous volume by ID, which no
+// container uses, along with its data
+func (r *Repository) Remove(name string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	v := r.getByNameOrID(name)
+	if v == nil {
+		return fmt.Errorf("No such volume: %s", name)
+	}
+	if containers := v.Containers(); len(containers) > 0 {
+		return fmt.Errorf("Volume %s is being used and cannot be removed: used by containers %s", v.name(), containers)
+	}
+	return r.remove(v)
+}
+
+func (r *Repository) remove(v *Volume) error {
+	if err := r.destroy(v); err != nil {
+		return err
+	}
+	delete(r.volumes, v.ID)
+	return nil
+}
+
+// destroy removes the data of v with its driver and its configuration, it
+// doesn't need the lock of the repository
+func (r *Repository) destroy(v *Volume) error {
+	driver, err := r.getDriver(v.Driver)
+	if err != nil {
+		return err
+	}
+	if err := driver.Remove(v); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.RemoveAll(v.configPath)
+}
+
+// Prune removes the volumes, named or anonymous, which no container uses and
+// which are accepted by filter. The volumes adopted from the drivers are
+// kept, the daemon didn't create them. It returns the removed volumes sorted by name
+// and the space reclaimed, which is only known for the local volumes. The
+// volumes are taken out of the repository under its lock, so that no
+// container gets them, and their drivers are called without it; a volume
+// its driver failed to remove is put back.
+func (r *Repository) Prune(filter func(*Volume) bool) ([]*Volume, uint64) {
+	var candidates []*Volume
+	r.lock.Lock()
+	for id, v := range r.volumes {
+		if v.IsBindMount || v.Adopted || !v.IsDangling() || !filter(v) {
+			continue
+		}
+		candidates = append(candidates, v)
+		delete(r.volumes, id)
+	}
+	r.lock.Unlock()
+
+	var (
+		pruned    []*Volume
+		reclaimed uint64
+	)
+	for _, v := range candidates {
+		var size int64
+		if v.Driver == "" || v.Driver == DefaultDriver {
+			// the size is only reported, the volume is removed regardless
+			size, _ = directory.Size(v.Path)
+		}
+		if err := r.destroy(v); err != nil {
+			log.Errorf("Error removing volume %s: %v", v.name(), err)
+			r.lock.Lock()
+			if err := r.add(v); err != nil {
+				log.Errorf("Error restoring volume %s: %v", v.name(), err)
+			}
+			r.lock.Unlock()
+			continue
+		}
+		pruned = append(pruned, v)
+		reclaimed += uint64(size)
+	}
+	sort.Sort(byName(pruned))
+	return pruned, reclaimed
+}
+
+// Mount mounts the named volume v for a container and returns its path on
+// the host. The driver is called without holding the lock of the
+// repository.
+func (r *Repository) Mount(v *Volume) (string, error) {
+	driver, err := r.getDriver(v.Driver)
+	if err != nil {
+		return "", err
+	}
+	path, err := driver.Mount(v)
+	if err != nil {
+		return "", err
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if v.Path != path {
+		v.Path = path
+		if err := v.toDisk(); err != nil {
+			return "", err
+		}
+	}
+	return path, nil
+}
+
+// Unmount is called when a container which mounted the named volume v stops
+func (r *Repository) Unmount(v *Volume) error {
+	driver, err := r.getDriver(v.Driver)
+	if err != nil {
+		return err
+	}
+	return driver.Unmount(v)
+}
+
+type byName []*Volume
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byName) Less(i, j int) bool { return s[i].name() < s[j].name() }
@@ -152,6 +152,153 @@ func TestRepositoryDelete(t *testing.T) {
 
 }
 
+func TestRepositoryNamed(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "volumes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	repo, err := newRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := repo.Create("data", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Driver != DefaultDriver {
+		t.Fatalf("Expected the default driver, got %s", v.Driver)
+	}
+	expected := filepath.Join(root, "repo-graph", "vfs", "dir", v.ID)
+	if v.Path != expected {
+		t.Fatalf("expected new path to be created in %s, got %s", expected, v.Path)
+	}
+	if _, err := repo.Create("data", "", nil, nil); err == nil {
+		t.Fatal("Expected a duplicate volume name to be refused")
+	}
+	if _, err := repo.Create("logs", "", map[string]string{"size": "1G"}, nil); err == nil {
+		t.Fatal("Expected an unknown option of the local driver to be refused")
+	}
+	if _, err := repo.Create("/data", "", nil, nil); err == nil {
+		t.Fatal("Expected an invalid volume name to be refused")
+	}
+
+	found, err := repo.FindOrCreateNamed("data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found != v {
+		t.Fatalf("expected get to return same volume")
+	}
+	if err := repo.Delete(v.Path); err == nil {
+		t.Fatal("Expected a named volume not to be deleted with the volumes of a container")
+	}
+
+	anonymous, err := repo.Create("", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if anonymous.Name != anonymous.ID {
+		t.Fatalf("Expected the volume to be named after its ID, got %s", anonymous.Name)
+	}
+	if list := repo.List(); len(list) != 2 || list[0].Name > list[1].Name {
+		t.Fatalf("Expected 2 volumes sorted by name, got %v", list)
+	}
+
+	v.AddContainer("1234")
+	if err := repo.Remove("data"); err == nil {
+		t.Fatal("expected volume remove to fail due to container refs")
+	}
+	v.RemoveContainer("1234")
+	if err := repo.Remove("data"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetNamed("data"); err == nil {
+		t.Fatal("expected volume to not exist")
+	}
+	if _, err := os.Stat(v.Path); err == nil {
+		t.Fatalf("expected volume files to be removed")
+	}
+
+	// the named volumes are restored
+	repo, err = newRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetNamed(anonymous.Name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRepositoryPrune(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "volumes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	repo, err := newRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Create("keep", "", nil, map[string]string{"keep": ""}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := repo.Create("data", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(data.Path, "file"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	used, err := repo.Create("used", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	used.AddContainer("1234")
+	anonymous, err := repo.FindOrCreateVolume("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bind, err := repo.FindOrCreateVolume(filepath.Join(root, "bind"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if list := repo.List(); len(list) != 4 {
+		t.Fatalf("Expected the bind mount not to be listed, got %v", list)
+	}
+	if _, err := repo.GetNamed(anonymous.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetNamed(bind.ID); err == nil {
+		t.Fatal("Expected the bind mount not to be found")
+	}
+
+	pruned, reclaimed := repo.Prune(func(v *Volume) bool {
+		_, keep := v.Labels["keep"]
+		return !keep
+	})
+	if len(pruned) != 2 || pruned[0] != anonymous && pruned[1] != anonymous || pruned[0] != data && pruned[1] != data {
+		t.Fatalf("Expected the unused volumes to be pruned, got %v", pruned)
+	}
+	if reclaimed != 4 {
+		t.Fatalf("Expected 4 bytes to be reclaimed, got %d", reclaimed)
+	}
+	for _, name := range []string{"keep", "used"} {
+		if _, err := repo.GetNamed(name); err != nil {
+			t.Fatalf("Expected volume %s to be kept: %v", name, err)
+		}
+	}
+	if repo.Get(bind.Path) == nil {
+		t.Fatal("Expected the bind mount to be kept")
+	}
+	if _, err := os.Stat(data.Path); err == nil {
+		t.Fatal("expected volume files to be removed")
+	}
+}
+
 func newRepo(root string) (*Repository, error) {
 	configPath := filepath.Join(root, "repo-config")
 	graphDir := filepath.Join(root, "repo-graph")
@@ -18,13 +18,26 @@ type Volume struct {
 	Path        string
 	IsBindMount bool
 	Writable    bool
-	containers  map[string]struct{}
-	configPath  string
-	repository  *Repository
-	lock        sync.Mutex
+	// Name, Driver, Options and Labels are set for the named volumes, which
+	// are managed by a volume driver and kept until they are removed. The
+	// Path of a volume of a plugin is the one it was last mounted at.
+	Name    string            `json:",omitempty"`
+	Driver  string            `json:",omitempty"`
+	Options map[string]string `json:",omitempty"`
+	Labels  map[string]string `json:",omitempty"`
+	// Adopted is set for the named volumes the driver had before the daemon
+	// used them, they are never pruned
+	Adopted bool `json:",omitempty"`
+
+	containers map[string]struct{}
+	configPath string
+	repository *Repository
+	lock       sync.Mutex
 }
 
-func (v *Volume) Export(resource, name string) (io.ReadCloser, error) {
+// Export returns a tar of the resource of the volume, renamed to name when it
+// is set. includeXattrs archives all the extended attributes of the files.
+func (v *Volume) Export(resource, name string, includeXattrs bool) (io.ReadCloser, error) {
 	if v.IsBindMount && filepath.Base(resource) == name {
 		name = ""
 	}
@@ -47,9 +60,10 @@ func (v *Volume) Export(resource, name string) (io.ReadCloser, error) {
 		basePath = path.Dir(basePath)
 	}
 	return archive.TarWithOptions(basePath, &archive.TarOptions{
-		Compression:  archive.Uncompressed,
-		Name:         name,
-		IncludeFiles: filter,
+		Compression:   archive.Uncompressed,
+		Name:          name,
+		IncludeFiles:  filter,
+		IncludeXattrs: includeXattrs,
 	})
 }
 
@@ -74,6 +88,21 @@ func (v *Volume) Containers() []string {
 	return containers
 }
 
+// name returns the name of a named volume, or the ID of an anonymous volume
+func (v *Volume) name() string {
+	if v.Name == "" {
+		return v.ID
+	}
+	return v.Name
+}
+
+// IsDangling returns whether no container uses the volume
+func (v *Volume) IsDangling() bool {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	return len(v.containers) == 0
+}
+
 func (v *Volume) RemoveContainer(containerId string) {
 	v.lock.Lock()
 	delete(v.containers, containerId)
@@ -90,9 +119,11 @@ func (v *Volume) initialize() error {
 	v.lock.Lock()
 	defer v.lock.Unlock()
 
-	if _, err := os.Stat(v.Path); err != nil && os.IsNotExist(err) {
-		if err := os.MkdirAll(v.Path, 0755); err != nil {
-			return err
+	if v.Name == "" {
+		if _, err := os.Stat(v.Path); err != nil && os.IsNotExist(err) {
+			if err := os.MkdirAll(v.Path, 0755); err != nil {
+				return err
+			}
 		}
 	}
 